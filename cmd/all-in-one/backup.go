@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/getlawrence/lawrence-oss/internal/backup"
+	"github.com/getlawrence/lawrence-oss/internal/config"
+	"github.com/getlawrence/lawrence-oss/internal/storage/applicationstore"
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore"
+)
+
+// backupStores returns the stores included in backups
+func backupStores(appStoreFactory *applicationstore.Factory, telemetryStoreFactory *telemetrystore.Factory) ([]backup.Store, error) {
+	appBackupper, err := appStoreFactory.Backupper()
+	if err != nil {
+		return nil, err
+	}
+	telemetryBackupper, err := telemetryStoreFactory.Backupper()
+	if err != nil {
+		return nil, err
+	}
+	return []backup.Store{
+		{Name: "application", Type: appStoreFactory.GetStorageType(), Backupper: appBackupper},
+		{Name: "telemetry", Type: telemetryStoreFactory.GetStorageType(), Backupper: telemetryBackupper},
+	}, nil
+}
+
+// backupCommand returns the backup subcommand. The running server holds the
+// databases, so the snapshot is taken by the server and streamed through its API.
+func backupCommand() *cobra.Command {
+	var server, output string
+
+	cmd := &cobra.Command{
+		Use:     "backup",
+		Short:   "Back up the application and telemetry stores of a running server",
+		Example: `  lawrence backup -o lawrence-backup.tar.gz`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output == "" {
+				output = fmt.Sprintf("lawrence-backup-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+			}
+
+			resp, err := http.Post(apiURL(server)+"/api/v1/backup", "application/json", nil)
+			if err != nil {
+				return fmt.Errorf("failed to reach Lawrence server: %w", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return apiError(resp)
+			}
+
+			var out io.Writer = os.Stdout
+			if output != "-" {
+				file, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				defer file.Close()
+				out = file
+			}
+
+			written, err := io.Copy(out, resp.Body)
+			if err != nil {
+				return fmt.Errorf("failed to write backup: %w", err)
+			}
+			if output != "-" {
+				fmt.Fprintf(os.Stderr, "Wrote %d byte backup to %s\n", written, output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&server, "server", "", "Lawrence API URL (defaults to the configured HTTP port on localhost)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file, - for stdout (defaults to a timestamped file)")
+
+	return cmd
+}
+
+// restoreCommand returns the restore subcommand. Restoring replaces the
+// database files, so it runs against the configured stores directly and the
+// server must be stopped.
+func restoreCommand() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <archive>",
+		Short: "Restore the application and telemetry stores from a backup",
+		Long: `Restore replaces the configured application and telemetry stores with the
+contents of a backup archive. Stop the Lawrence server before restoring.
+
+The schema versions in the archive are validated and every store's snapshot is
+staged next to it before any store is replaced, so a snapshot that fails to
+restore leaves all stores unchanged. Only a failure while swapping the staged
+snapshots in can leave some stores replaced, which the error reports.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadConfig(viper.GetString("config"))
+			if err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+
+			if !force {
+				for _, path := range []string{cfg.Storage.App.Path, cfg.Storage.Telemetry.Path} {
+					if _, err := os.Stat(path); err == nil {
+						return fmt.Errorf("%s already exists, use --force to replace it", path)
+					}
+				}
+			}
+
+			appStoreFactory, err := applicationstore.NewFactoryFromAppConfig(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to create application store factory: %w", err)
+			}
+			telemetryStoreFactory, err := telemetrystore.NewFactoryFromAppConfig(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to create telemetry store factory: %w", err)
+			}
+			stores, err := backupStores(appStoreFactory, telemetryStoreFactory)
+			if err != nil {
+				return err
+			}
+
+			file, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to open backup: %w", err)
+			}
+			defer file.Close()
+
+			manifest, err := backup.Restore(context.Background(), file, stores)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Restored backup of Lawrence %s created at %s\n",
+				manifest.LawrenceVersion, manifest.CreatedAt.Format(time.RFC3339))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Replace existing database files")
+
+	return cmd
+}
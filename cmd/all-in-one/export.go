@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/getlawrence/lawrence-oss/internal/config"
+)
+
+// exportCommand returns the export subcommand. The running server holds the
+// telemetry database, so the export is streamed through its API.
+func exportCommand() *cobra.Command {
+	var (
+		server, signal, format, output string
+		start, end                     string
+		agentID, groupID, serviceName  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export raw telemetry for a time range to Parquet, NDJSON or CSV",
+		Example: `  lawrence export --signal logs --start 24h --format ndjson -o logs.ndjson
+  lawrence export --signal metrics --start 2024-01-01T00:00:00Z --end 2024-01-02T00:00:00Z --service checkout`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			now := time.Now()
+			endTime, err := parseTimeFlag(end, now)
+			if err != nil {
+				return fmt.Errorf("invalid --end: %w", err)
+			}
+			startTime, err := parseTimeFlag(start, now)
+			if err != nil {
+				return fmt.Errorf("invalid --start: %w", err)
+			}
+
+			request := map[string]interface{}{
+				"signal":     signal,
+				"format":     format,
+				"start_time": startTime,
+				"end_time":   endTime,
+			}
+			if agentID != "" {
+				request["agent_id"] = agentID
+			}
+			if groupID != "" {
+				request["group_id"] = groupID
+			}
+			if serviceName != "" {
+				request["service_name"] = serviceName
+			}
+			body, err := json.Marshal(request)
+			if err != nil {
+				return fmt.Errorf("failed to encode export request: %w", err)
+			}
+
+			resp, err := http.Post(apiURL(server)+"/api/v1/telemetry/export", "application/json", bytes.NewReader(body))
+			if err != nil {
+				return fmt.Errorf("failed to reach Lawrence server: %w", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return apiError(resp)
+			}
+
+			var out io.Writer = os.Stdout
+			if output != "" && output != "-" {
+				file, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				defer file.Close()
+				out = file
+			}
+
+			written, err := io.Copy(out, resp.Body)
+			if err != nil {
+				return fmt.Errorf("failed to write export: %w", err)
+			}
+			if output != "" && output != "-" {
+				fmt.Fprintf(os.Stderr, "Exported %d bytes of %s to %s\n", written, signal, output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&server, "server", "", "Lawrence API URL (defaults to the configured HTTP port on localhost)")
+	cmd.Flags().StringVar(&signal, "signal", "", "Signal to export: metrics, logs or traces")
+	cmd.Flags().StringVar(&format, "format", "parquet", "Output format: parquet, ndjson or csv")
+	cmd.Flags().StringVar(&start, "start", "1h", "Range start as RFC3339 or a duration before now, like 24h or 7d")
+	cmd.Flags().StringVar(&end, "end", "0s", "Range end as RFC3339 or a duration before now")
+	cmd.Flags().StringVar(&agentID, "agent", "", "Only export telemetry from this agent ID")
+	cmd.Flags().StringVar(&groupID, "group", "", "Only export telemetry from this group ID")
+	cmd.Flags().StringVar(&serviceName, "service", "", "Only export telemetry from this service")
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "Output file, - for stdout")
+	_ = cmd.MarkFlagRequired("signal")
+
+	return cmd
+}
+
+// parseTimeFlag parses an RFC3339 time or a duration before now
+func parseTimeFlag(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := config.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC3339 time or duration, got %q", value)
+	}
+	return now.Add(-d), nil
+}
+
+// apiURL returns the Lawrence API URL, falling back to the HTTP port of the
+// loaded configuration
+func apiURL(server string) string {
+	if server != "" {
+		return server
+	}
+	port := config.DefaultConfig().Server.HTTPPort
+	if cfg, err := config.LoadConfig(viper.GetString("config")); err == nil {
+		port = cfg.Server.HTTPPort
+	}
+	return fmt.Sprintf("http://localhost:%d", port)
+}
+
+// apiError converts an error response of the Lawrence API to an error
+func apiError(resp *http.Response) error {
+	var body struct {
+		Error   string `json:"error"`
+		Details string `json:"details"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error == "" {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	if body.Details != "" {
+		return fmt.Errorf("server returned %s: %s: %s", resp.Status, body.Error, body.Details)
+	}
+	return fmt.Errorf("server returned %s: %s", resp.Status, body.Error)
+}
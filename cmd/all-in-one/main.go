@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -14,14 +15,21 @@ import (
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
+	"github.com/getlawrence/lawrence-oss/internal/alerting"
 	"github.com/getlawrence/lawrence-oss/internal/api"
 	"github.com/getlawrence/lawrence-oss/internal/config"
 	"github.com/getlawrence/lawrence-oss/internal/metrics"
 	"github.com/getlawrence/lawrence-oss/internal/opamp"
+	"github.com/getlawrence/lawrence-oss/internal/otlp/processor"
 	"github.com/getlawrence/lawrence-oss/internal/otlp/receiver"
+	"github.com/getlawrence/lawrence-oss/internal/query"
+	"github.com/getlawrence/lawrence-oss/internal/recording"
+	"github.com/getlawrence/lawrence-oss/internal/rollup"
+	"github.com/getlawrence/lawrence-oss/internal/sampling"
 	"github.com/getlawrence/lawrence-oss/internal/services"
 	"github.com/getlawrence/lawrence-oss/internal/storage/applicationstore"
 	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore"
+	"github.com/getlawrence/lawrence-oss/internal/usage"
 	"github.com/getlawrence/lawrence-oss/internal/utils"
 	"github.com/getlawrence/lawrence-oss/internal/worker"
 )
@@ -47,6 +55,10 @@ func main() {
 	// Add subcommands
 	rootCmd.AddCommand(versionCommand())
 	rootCmd.AddCommand(configCommand())
+	rootCmd.AddCommand(exportCommand())
+	rootCmd.AddCommand(backupCommand())
+	rootCmd.AddCommand(restoreCommand())
+	rootCmd.AddCommand(migrateCommand())
 
 	// Add flags
 	rootCmd.PersistentFlags().String("config", "./lawrence.yaml", "Path to configuration file")
@@ -104,8 +116,13 @@ func runLawrence(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
+	registry := prometheus.NewRegistry()
+	metricsFactory := metrics.NewPrometheusFactory("lawrence", registry)
+
 	// Create telemetry store using meta factory
-	telemetryStoreFactory, err := telemetrystore.NewFactoryFromAppConfig(config)
+	telemetryStoreConfig := telemetrystore.ConfigFrom(config)
+	telemetryStoreConfig.MetricsFactory = metricsFactory
+	telemetryStoreFactory, err := telemetrystore.NewFactory(telemetryStoreConfig)
 	if err != nil {
 		logger.Fatal("Failed to create telemetry store factory", zap.Error(err))
 	}
@@ -134,8 +151,6 @@ func runLawrence(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
-	registry := prometheus.NewRegistry()
-	metricsFactory := metrics.NewPrometheusFactory("lawrence", registry)
 	opampMetrics := metrics.NewOpAMPMetrics(metricsFactory)
 	otlpMetrics := metrics.NewOTLPMetrics(metricsFactory)
 
@@ -177,6 +192,57 @@ func runLawrence(cmd *cobra.Command, args []string) error {
 
 	// Initialize worker pool for async telemetry processing (with agentService for enrichment)
 	workerPool := worker.NewPool(config.Worker.QueueSize, config.Worker.Workers, workerTimeout, telemetryWriter, agentService, logger)
+
+	// Account ingestion usage per agent, group and service
+	if config.Usage.Enabled {
+		usageTracker := newUsageTracker(config, telemetryWriter, logger)
+		usageTracker.Start()
+		workerPool.SetUsageRecorder(usageTracker)
+		// Deferred before the pool's Stop so it runs after the queue is drained
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := usageTracker.Stop(ctx); err != nil {
+				logger.Error("Failed to stop usage tracker", zap.Error(err))
+			}
+		}()
+	}
+
+	// Apply ingest-time processing rules managed through the API
+	ruleEngine := processor.NewRuleEngine(logger)
+	ruleService := services.NewProcessingRuleService(appStore, ruleEngine, logger)
+	if err := ruleService.ReloadProcessingRules(context.Background()); err != nil {
+		logger.Error("Failed to load processing rules", zap.Error(err))
+	}
+	workerPool.SetRuleEngine(ruleEngine)
+
+	// Mask PII in logs and spans before they are stored
+	if config.Redaction.Enabled {
+		redactor, err := newRedactor(config, metrics.NewRedactionMetrics(metricsFactory), logger)
+		if err != nil {
+			return fmt.Errorf("failed to create redactor: %w", err)
+		}
+		workerPool.SetRedactor(redactor)
+	}
+
+	// Buffer spans per trace and keep only the traces selected by the sampling policies
+	if config.Sampling.Tail.Enabled {
+		tailSampler, err := newTailSampler(config, telemetryWriter, metrics.NewSamplingMetrics(metricsFactory), logger)
+		if err != nil {
+			return fmt.Errorf("failed to create tail sampler: %w", err)
+		}
+		tailSampler.Start()
+		workerPool.SetTraceSampler(tailSampler)
+		// Deferred before the pool's Stop so buffered traces are decided after the queue is drained
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := tailSampler.Stop(ctx); err != nil {
+				logger.Error("Failed to stop tail sampler", zap.Error(err))
+			}
+		}()
+	}
+
 	workerPool.Start()
 	defer func() {
 		if err := workerPool.Stop(30 * time.Second); err != nil {
@@ -221,8 +287,56 @@ func runLawrence(cmd *cobra.Command, args []string) error {
 		_ = httpServer.Stop(ctx)
 	}()
 
+	retentionPolicy, err := newRetentionPolicy(config)
+	if err != nil {
+		return fmt.Errorf("failed to create retention policy: %w", err)
+	}
+
+	// Evaluate recording rules from configuration and the API, writing their
+	// results back as metrics
+	configRecordingRules, err := recordingRulesFromConfig(config)
+	if err != nil {
+		return fmt.Errorf("invalid recording rules: %w", err)
+	}
+	recordingManager := recording.NewManager(query.NewExecutor(telemetryService, logger), telemetryWriter, metrics.NewRecordingRuleMetrics(metricsFactory), logger)
+	recordingService := services.NewRecordingRuleService(appStore, configRecordingRules, recordingManager, logger)
+	if err := recordingService.ReloadRecordingRules(context.Background()); err != nil {
+		logger.Error("Failed to load recording rules", zap.Error(err))
+	}
+
+	// Evaluate alert rules from the API and notify the configured receivers
+	alertingMetrics := metrics.NewAlertingMetrics(metricsFactory)
+	alertDispatcher, err := newAlertDispatcher(config, alertingMetrics, logger)
+	if err != nil {
+		return fmt.Errorf("invalid alerting configuration: %w", err)
+	}
+	alertEngine := alerting.NewEngine(query.NewExecutor(telemetryService, logger), alertDispatcher, alertingMetrics, logger)
+	if config.Alerting.Fleet.Enabled {
+		addFleetAlerts(alertEngine, config, agents, agentService, telemetryService, logger)
+	}
+	alertService := services.NewAlertService(appStore, alertEngine, logger)
+	if err := alertService.ReloadAlerting(context.Background()); err != nil {
+		logger.Error("Failed to load alert rules", zap.Error(err))
+	}
+
+	serverOptions := []api.ServerOption{
+		api.WithProcessingRuleService(ruleService),
+		api.WithRecordingRuleService(recordingService),
+		api.WithAlertService(alertService),
+		api.WithRetentionPolicy(retentionPolicy),
+	}
+	if stores, err := backupStores(appStoreFactory, telemetryStoreFactory); err != nil {
+		logger.Warn("Backups are disabled", zap.Error(err))
+	} else {
+		serverOptions = append(serverOptions, api.WithBackup(stores, version))
+	}
+	if config.QueryCache.Enabled {
+		queryCache := newQueryCache(config, metrics.NewQueryCacheMetrics(metricsFactory), logger)
+		serverOptions = append(serverOptions, api.WithQueryCache(queryCache))
+	}
+
 	// Initialize HTTP API server
-	apiServer := api.NewServer(agentService, telemetryService, configSender, logger)
+	apiServer := api.NewServer(agentService, telemetryService, configSender, logger, serverOptions...)
 
 	// Start API server in a goroutine
 	go func() {
@@ -237,8 +351,39 @@ func runLawrence(cmd *cobra.Command, args []string) error {
 	}()
 
 	// Start background services
-	go startRollupGenerator(telemetryService, config, logger)
-	go startCleanupTask(telemetryService, config, logger)
+	if config.Rollups.Enabled {
+		rollupScheduler, err := newRollupScheduler(config, telemetryService, metrics.NewRollupMetrics(metricsFactory), logger)
+		if err != nil {
+			return fmt.Errorf("failed to create rollup scheduler: %w", err)
+		}
+		rollupScheduler.Start()
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := rollupScheduler.Stop(ctx); err != nil {
+				logger.Error("Failed to stop rollup scheduler", zap.Error(err))
+			}
+		}()
+	} else {
+		logger.Info("Rollup generation is disabled")
+	}
+	recordingManager.Start()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := recordingManager.Stop(ctx); err != nil {
+			logger.Error("Failed to stop recording rule manager", zap.Error(err))
+		}
+	}()
+	alertEngine.Start()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := alertEngine.Stop(ctx); err != nil {
+			logger.Error("Failed to stop alert rule engine", zap.Error(err))
+		}
+	}()
+	go startCleanupTask(telemetryService, retentionPolicy, config, logger)
 
 	logger.Info("Lawrence OSS is running",
 		zap.Int("opamp_port", config.Server.OpAMPPort),
@@ -284,61 +429,375 @@ func configCommand() *cobra.Command {
 	}
 }
 
-// startRollupGenerator periodically generates rollups for metrics
-func startRollupGenerator(telemetryService services.TelemetryQueryService, config *config.Config, logger *zap.Logger) {
-	if !config.Rollups.Enabled {
-		logger.Info("Rollup generation is disabled")
-		return
+// newUsageTracker creates the ingestion usage tracker from configuration
+func newUsageTracker(config *config.Config, writer usage.Writer, logger *zap.Logger) *usage.Tracker {
+	bucketSize, flushInterval := usageIntervals(config, logger)
+	return usage.NewTracker(writer, bucketSize, flushInterval, logger)
+}
+
+// usageIntervals returns the configured usage bucket size and flush interval
+func usageIntervals(config *config.Config, logger *zap.Logger) (time.Duration, time.Duration) {
+	bucketSize, err := time.ParseDuration(config.Usage.BucketSize)
+	if err != nil {
+		bucketSize = time.Minute
+		logger.Warn("Failed to parse usage bucket size, using default", zap.Error(err))
 	}
+	flushInterval, err := time.ParseDuration(config.Usage.FlushInterval)
+	if err != nil {
+		flushInterval = 30 * time.Second
+		logger.Warn("Failed to parse usage flush interval, using default", zap.Error(err))
+	}
+	return bucketSize, flushInterval
+}
 
-	logger.Info("Starting rollup generator")
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
+// newRedactor creates the PII redactor from configuration
+func newRedactor(config *config.Config, redactionMetrics *metrics.RedactionMetrics, logger *zap.Logger) (*processor.Redactor, error) {
+	groups := make(map[string]processor.RedactionPolicy, len(config.Redaction.Groups))
+	for group, policy := range config.Redaction.Groups {
+		groups[group] = toRedactionPolicy(policy)
+	}
+	return processor.NewRedactor(toRedactionPolicy(config.Redaction.Default), groups, redactionMetrics, logger)
+}
 
-	for range ticker.C {
-		ctx := context.Background()
-		now := time.Now()
+// toRedactionPolicy converts a redaction policy from configuration
+func toRedactionPolicy(policy config.RedactionPolicyConfig) processor.RedactionPolicy {
+	patterns := make([]processor.CustomPattern, len(policy.CustomPatterns))
+	for i, pattern := range policy.CustomPatterns {
+		patterns[i] = processor.CustomPattern{Name: pattern.Name, Pattern: pattern.Pattern}
+	}
+	return processor.RedactionPolicy{
+		Disabled:       policy.Disabled,
+		Detectors:      policy.Detectors,
+		CustomPatterns: patterns,
+		Replacement:    policy.Replacement,
+	}
+}
 
-		// Generate rollups based on time intervals
-		if err := generateRollup(ctx, telemetryService, "1m", now, logger); err != nil {
-			logger.Error("Failed to generate 1m rollup", zap.Error(err))
-		}
+// newTailSampler creates the tail-based trace sampler from configuration
+func newTailSampler(config *config.Config, writer sampling.Writer, samplingMetrics *metrics.SamplingMetrics, logger *zap.Logger) (*sampling.TailSampler, error) {
+	tailConfig := config.Sampling.Tail
+	decisionWait, err := time.ParseDuration(tailConfig.DecisionWait)
+	if err != nil {
+		decisionWait = 10 * time.Second
+		logger.Warn("Failed to parse tail sampling decision wait, using default", zap.Error(err))
+	}
 
-		if now.Minute()%5 == 0 {
-			if err := generateRollup(ctx, telemetryService, "5m", now, logger); err != nil {
-				logger.Error("Failed to generate 5m rollup", zap.Error(err))
+	policies := make([]sampling.Policy, 0, len(tailConfig.Policies))
+	for _, policy := range tailConfig.Policies {
+		var threshold time.Duration
+		if policy.Threshold != "" {
+			threshold, err = time.ParseDuration(policy.Threshold)
+			if err != nil {
+				return nil, fmt.Errorf("invalid threshold for sampling policy %q: %w", policy.Name, err)
 			}
 		}
+		policies = append(policies, sampling.Policy{
+			Name:      policy.Name,
+			Type:      sampling.PolicyType(policy.Type),
+			Threshold: threshold,
+			Key:       policy.Key,
+			Values:    policy.Values,
+			Rate:      policy.Rate,
+		})
+	}
+
+	return sampling.NewTailSampler(sampling.Config{
+		DecisionWait:     decisionWait,
+		MaxBufferedSpans: tailConfig.MaxBufferedSpans,
+		DecidedCacheSize: tailConfig.DecidedCacheSize,
+		Policies:         policies,
+	}, writer, samplingMetrics, logger)
+}
+
+// newRollupScheduler creates the rollup scheduler from configuration
+func newRollupScheduler(config *config.Config, store rollup.Store, rollupMetrics *metrics.RollupMetrics, logger *zap.Logger) (*rollup.Scheduler, error) {
+	delay, err := time.ParseDuration(config.Rollups.Delay)
+	if err != nil {
+		delay = 30 * time.Second
+		logger.Warn("Failed to parse rollup delay, using default", zap.Error(err))
+	}
+	maxBackfill, err := time.ParseDuration(config.Rollups.MaxBackfill)
+	if err != nil {
+		maxBackfill = 24 * time.Hour
+		logger.Warn("Failed to parse rollup max backfill, using default", zap.Error(err))
+	}
+
+	return rollup.NewScheduler(rollup.Config{
+		Schedules: map[services.RollupInterval]string{
+			services.RollupInterval1m: config.Rollups.Interval1m,
+			services.RollupInterval5m: config.Rollups.Interval5m,
+			services.RollupInterval1h: config.Rollups.Interval1h,
+			services.RollupInterval1d: config.Rollups.Interval1d,
+		},
+		Delay:       delay,
+		MaxBackfill: maxBackfill,
+	}, store, rollupMetrics, logger)
+}
+
+// newQueryCache creates the range query results cache, falling back to the
+// cache defaults for settings that are empty or fail to parse
+func newQueryCache(cfg *config.Config, cacheMetrics *metrics.QueryCacheMetrics, logger *zap.Logger) *query.ResultsCache {
+	cacheConfig := cfg.QueryCache
+	parse := func(name, value string) time.Duration {
+		if value == "" {
+			return 0
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			logger.Warn("Failed to parse query cache duration, using default", zap.String("setting", name), zap.Error(err))
+			return 0
+		}
+		return d
+	}
+
+	var maxBytes int64
+	if cacheConfig.MaxSize != "" {
+		size, err := config.ParseByteSize(cacheConfig.MaxSize)
+		if err != nil {
+			logger.Warn("Failed to parse query cache max size, using default", zap.Error(err))
+		} else {
+			maxBytes = size
+		}
+	}
+
+	return query.NewResultsCache(query.CacheConfig{
+		MaxBytes:  maxBytes,
+		ChunkSize: parse("chunk_size", cacheConfig.ChunkSize),
+		Freshness: parse("freshness", cacheConfig.Freshness),
+	}, cacheMetrics)
+}
+
+// recordingRulesFromConfig converts the recording rules of the configuration,
+// checking their expressions parse
+func recordingRulesFromConfig(cfg *config.Config) ([]*services.RecordingRule, error) {
+	rules := make([]*services.RecordingRule, 0, len(cfg.RecordingRules))
+	seen := make(map[string]bool, len(cfg.RecordingRules))
+	for _, ruleConfig := range cfg.RecordingRules {
+		interval := ruleConfig.Interval
+		if interval == "" {
+			interval = "1m"
+		}
+		rule := &services.RecordingRule{
+			ID:          "config-" + ruleConfig.Name,
+			Name:        ruleConfig.Name,
+			Description: ruleConfig.Description,
+			Expr:        ruleConfig.Expr,
+			Interval:    interval,
+			Labels:      ruleConfig.Labels,
+			Enabled:     true,
+		}
+		if seen[rule.ID] {
+			return nil, fmt.Errorf("duplicate recording rule %q", rule.Name)
+		}
+		seen[rule.ID] = true
+
+		if err := services.ValidateRecordingRule(rule); err != nil {
+			return nil, fmt.Errorf("recording rule %q: %w", rule.Name, err)
+		}
+		if _, err := query.NewParser(rule.Expr).Parse(); err != nil {
+			return nil, fmt.Errorf("recording rule %q: invalid expression: %w", rule.Name, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// addFleetAlerts adds the built-in fleet health checks to the alert engine
+func addFleetAlerts(engine *alerting.Engine, cfg *config.Config, agents *opamp.Agents, agentService services.AgentService, telemetryService services.TelemetryQueryService, logger *zap.Logger) {
+	fleetConfig := cfg.Alerting.Fleet
+	parse := func(name, value string, fallback time.Duration) time.Duration {
+		if value == "" {
+			return fallback
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			logger.Warn("Failed to parse fleet alert duration, using default", zap.String("setting", name), zap.Error(err))
+			return fallback
+		}
+		return d
+	}
+
+	// Telemetry is seen through usage records, which show up a bucket and a
+	// flush after it was received
+	noTelemetryAfter := parse("no_telemetry_after", fleetConfig.NoTelemetryAfter, 0)
+	if noTelemetryAfter > 0 && !cfg.Usage.Enabled {
+		logger.Warn("Usage tracking is disabled, skipping the no telemetry fleet check")
+		noTelemetryAfter = 0
+	}
+	if noTelemetryAfter > 0 {
+		bucketSize, flushInterval := usageIntervals(cfg, logger)
+		if delay := bucketSize + flushInterval; noTelemetryAfter < delay {
+			logger.Warn("No telemetry threshold is shorter than the usage bucket size and flush interval, raising it",
+				zap.Duration("no_telemetry_after", noTelemetryAfter),
+				zap.Duration("threshold", delay))
+			noTelemetryAfter = delay
+		}
+	}
+
+	checker := alerting.NewFleetChecker(alerting.FleetConfig{
+		OfflineAfter:     parse("offline_after", fleetConfig.OfflineAfter, 0),
+		NoTelemetryAfter: noTelemetryAfter,
+		MinAgentVersion:  fleetConfig.MinAgentVersion,
+	}, agents, agentService, telemetryService, logger)
+	engine.AddSource("fleet", checker, parse("interval", fleetConfig.Interval, time.Minute), parse("for", fleetConfig.For, 2*time.Minute))
+}
+
+// newAlertDispatcher creates the alert dispatcher and its receivers from configuration
+func newAlertDispatcher(cfg *config.Config, alertingMetrics *metrics.AlertingMetrics, logger *zap.Logger) (*alerting.Dispatcher, error) {
+	alertingConfig := cfg.Alerting
+	parse := func(name, value string, fallback time.Duration) time.Duration {
+		if value == "" {
+			return fallback
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			logger.Warn("Failed to parse alerting duration, using default", zap.String("setting", name), zap.Error(err))
+			return fallback
+		}
+		return d
+	}
+	repeatInterval := parse("repeat_interval", alertingConfig.RepeatInterval, 4*time.Hour)
+
+	groupBy := alertingConfig.GroupBy
+	if len(groupBy) == 0 {
+		groupBy = []string{"alertname"}
+	}
+
+	receivers := make([]alerting.Receiver, 0, len(alertingConfig.Receivers))
+	seen := make(map[string]bool, len(alertingConfig.Receivers))
+	for _, receiverConfig := range alertingConfig.Receivers {
+		if receiverConfig.Name == "" || receiverConfig.URL == "" {
+			return nil, fmt.Errorf("alert receivers need a name and a url")
+		}
+		if seen[receiverConfig.Name] {
+			return nil, fmt.Errorf("duplicate alert receiver %q", receiverConfig.Name)
+		}
+		seen[receiverConfig.Name] = true
 
-		if now.Minute() == 0 {
-			if err := generateRollup(ctx, telemetryService, "1h", now, logger); err != nil {
-				logger.Error("Failed to generate 1h rollup", zap.Error(err))
+		timeout := 10 * time.Second
+		if receiverConfig.Timeout != "" {
+			var err error
+			if timeout, err = time.ParseDuration(receiverConfig.Timeout); err != nil {
+				return nil, fmt.Errorf("invalid timeout for alert receiver %q: %w", receiverConfig.Name, err)
 			}
 		}
+		client := &http.Client{Timeout: timeout}
+
+		var notifier alerting.Notifier
+		switch receiverConfig.Type {
+		case "webhook", "":
+			notifier = alerting.NewWebhookNotifier(receiverConfig.URL, receiverConfig.Headers, client)
+		case "alertmanager":
+			// Firing alerts stay valid across a few missed repeats
+			notifier = alerting.NewAlertmanagerNotifier(receiverConfig.URL, receiverConfig.Headers, client, 3*repeatInterval)
+		default:
+			return nil, fmt.Errorf("unknown type %q for alert receiver %q", receiverConfig.Type, receiverConfig.Name)
+		}
+		receivers = append(receivers, alerting.Receiver{
+			Name:       receiverConfig.Name,
+			Notifier:   notifier,
+			MaxRetries: receiverConfig.MaxRetries,
+		})
 	}
+
+	return alerting.NewDispatcher(alerting.DispatcherConfig{
+		GroupBy:        groupBy,
+		GroupWait:      parse("group_wait", alertingConfig.GroupWait, 30*time.Second),
+		GroupInterval:  parse("group_interval", alertingConfig.GroupInterval, 5*time.Minute),
+		RepeatInterval: repeatInterval,
+		Receivers:      receivers,
+	}, alertingMetrics, logger), nil
 }
 
-// generateRollup generates a single rollup for the given interval
-func generateRollup(ctx context.Context, telemetryService services.TelemetryQueryService, interval string, now time.Time, logger *zap.Logger) error {
-	// TODO: Implement rollup generation
-	logger.Debug("Generating rollup", zap.String("interval", interval))
-	return nil
+// newRetentionPolicy builds the retention policy from configuration
+func newRetentionPolicy(cfg *config.Config) (services.RetentionPolicy, error) {
+	retention := cfg.Retention
+	var policy services.RetentionPolicy
+	var err error
+
+	parse := func(name, value string) time.Duration {
+		if err != nil || value == "" {
+			return 0
+		}
+		var d time.Duration
+		if d, err = config.ParseDuration(value); err != nil {
+			err = fmt.Errorf("invalid %s retention: %w", name, err)
+		}
+		return d
+	}
+
+	policy.RawMetrics = parse("raw_metrics", retention.RawMetrics)
+	policy.RawLogs = parse("raw_logs", retention.RawLogs)
+	policy.RawTraces = parse("raw_traces", retention.RawTraces)
+	policy.Usage = parse("usage", retention.Usage)
+	policy.Rollups = map[services.RollupInterval]time.Duration{
+		services.RollupInterval1m: parse("rollups_1m", retention.Rollups1m),
+		services.RollupInterval5m: parse("rollups_5m", retention.Rollups5m),
+		services.RollupInterval1h: parse("rollups_1h", retention.Rollups1h),
+		services.RollupInterval1d: parse("rollups_1d", retention.Rollups1d),
+	}
+	for i, override := range retention.Overrides {
+		if override.Group == "" && override.Service == "" {
+			return policy, fmt.Errorf("retention override %d must set a group or a service", i)
+		}
+		policy.Overrides = append(policy.Overrides, services.RetentionOverride{
+			Group:      override.Group,
+			Service:    override.Service,
+			RawMetrics: parse("override raw_metrics", override.RawMetrics),
+			RawLogs:    parse("override raw_logs", override.RawLogs),
+			RawTraces:  parse("override raw_traces", override.RawTraces),
+		})
+	}
+	if err != nil {
+		return policy, err
+	}
+
+	if retention.MaxDiskSize != "" {
+		policy.MaxDiskBytes, err = config.ParseByteSize(retention.MaxDiskSize)
+		if err != nil {
+			return policy, fmt.Errorf("invalid max_disk_size: %w", err)
+		}
+	}
+
+	return policy, nil
 }
 
-// startCleanupTask periodically cleans up old data
-func startCleanupTask(telemetryService services.TelemetryQueryService, config *config.Config, logger *zap.Logger) {
-	logger.Info("Starting cleanup task")
-	ticker := time.NewTicker(1 * time.Hour)
+// startCleanupTask periodically seals old days into cold partitions and
+// applies the retention policy
+func startCleanupTask(telemetryService services.TelemetryQueryService, policy services.RetentionPolicy, config *config.Config, logger *zap.Logger) {
+	interval, err := time.ParseDuration(config.Retention.Interval)
+	if err != nil || interval <= 0 {
+		interval = time.Hour
+		logger.Warn("Failed to parse retention interval, using default", zap.String("interval", config.Retention.Interval))
+	}
+
+	logger.Info("Starting cleanup task", zap.Duration("interval", interval))
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	hotDays := config.Storage.Telemetry.HotDays
 	for range ticker.C {
 		ctx := context.Background()
-		retention := 24 * time.Hour // TODO: Parse from config
 
-		if err := telemetryService.CleanupOldData(ctx, retention); err != nil {
-			logger.Error("Failed to cleanup old telemetry data", zap.Error(err))
-		} else {
-			logger.Debug("Cleaned up old telemetry data", zap.Duration("retention", retention))
+		// Today is always hot; hot_days includes it
+		if hotDays > 0 {
+			before := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-hotDays)
+			if _, err := telemetryService.SealPartitions(ctx, before); err != nil {
+				logger.Error("Failed to seal telemetry partitions", zap.Error(err))
+			}
+		}
+
+		report, err := telemetryService.ApplyRetention(ctx, policy, false)
+		if err != nil {
+			logger.Error("Failed to apply retention policy", zap.Error(err))
+			continue
+		}
+		if report.EvictionCutoff != nil {
+			logger.Warn("Evicted telemetry over the disk cap",
+				zap.Int64("disk_usage_bytes", report.DiskUsageBytes),
+				zap.Int64("max_disk_bytes", report.MaxDiskBytes),
+				zap.Time("cutoff", *report.EvictionCutoff))
 		}
+		logger.Debug("Applied retention policy", zap.Int64("disk_usage_bytes", report.DiskUsageBytes))
 	}
 }
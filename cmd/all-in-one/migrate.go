@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/getlawrence/lawrence-oss/internal/config"
+	"github.com/getlawrence/lawrence-oss/internal/storage/applicationstore"
+	apptypes "github.com/getlawrence/lawrence-oss/internal/storage/applicationstore/types"
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore"
+	telemetrytypes "github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+// migrationStatus is the schema migration status shared by both stores
+type migrationStatus struct {
+	Version     int
+	Description string
+	SQL         string
+	AppliedAt   *time.Time
+}
+
+// migrationTarget is a store whose schema the migrate command manages
+type migrationTarget struct {
+	name    string
+	status  func(ctx context.Context) ([]migrationStatus, error)
+	migrate func(ctx context.Context, dryRun bool) ([]migrationStatus, error)
+}
+
+// migrateCommand returns the migrate subcommand. Migrations need exclusive
+// access to the database files, so the server must be stopped; the server
+// also applies pending migrations itself when it starts.
+func migrateCommand() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply pending schema migrations to the application and telemetry stores",
+		Long: `Migrate applies pending schema migrations to the configured application and
+telemetry stores. Stop the Lawrence server before migrating. Use --dry-run to
+print the pending migrations without applying them.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := migrationTargets()
+			if err != nil {
+				return err
+			}
+
+			for _, target := range targets {
+				migrations, err := target.migrate(context.Background(), dryRun)
+				if err != nil {
+					return fmt.Errorf("failed to migrate %s store: %w", target.name, err)
+				}
+				if len(migrations) == 0 {
+					fmt.Printf("The %s store schema is up to date\n", target.name)
+					continue
+				}
+				printMigrations(target.name, migrations, dryRun, "Applied")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print pending migrations without applying them")
+	cmd.AddCommand(migrateStatusCommand())
+	cmd.AddCommand(migrateDownCommand())
+
+	return cmd
+}
+
+// migrateStatusCommand returns the migrate status subcommand
+func migrateStatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show applied and pending schema migrations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := migrationTargets()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "STORE\tVERSION\tAPPLIED\tDESCRIPTION")
+			for _, target := range targets {
+				migrations, err := target.status(context.Background())
+				if err != nil {
+					return fmt.Errorf("failed to read %s store migrations: %w", target.name, err)
+				}
+				for _, migration := range migrations {
+					applied := "pending"
+					if migration.AppliedAt != nil {
+						applied = migration.AppliedAt.Format(time.RFC3339)
+					}
+					fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", target.name, migration.Version, applied, migration.Description)
+				}
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// migrateDownCommand returns the migrate down subcommand. Only the
+// application store supports rolling back; telemetry migrations are
+// forward only.
+func migrateDownCommand() *cobra.Command {
+	var (
+		version int
+		dryRun  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "down",
+		Short: "Roll back application store schema migrations to an earlier version",
+		Long: `Down undoes the application store migrations above --to, newest first, so an
+earlier release can run against the database. Stop the Lawrence server first.
+Rolling back drops the tables and columns those migrations added.`,
+		Example: `  lawrence migrate down --to 1 --dry-run`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadMigrationConfig()
+			if err != nil {
+				return err
+			}
+			factory, err := applicationstore.NewFactoryFromAppConfig(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to create application store factory: %w", err)
+			}
+			migrator, err := factory.Migrator()
+			if err != nil {
+				return err
+			}
+
+			migrations, err := migrator.Rollback(context.Background(), version, dryRun)
+			if err != nil {
+				return fmt.Errorf("failed to roll back application store: %w", err)
+			}
+			if len(migrations) == 0 {
+				fmt.Printf("The application store schema is already at version %d or earlier\n", version)
+				return nil
+			}
+			printMigrations("application", fromAppMigrations(migrations), dryRun, "Rolled back")
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&version, "to", 0, "Schema version to roll back to")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the migrations to roll back without running them")
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+// printMigrations prints migrations that ran, or with dryRun their statements
+func printMigrations(store string, migrations []migrationStatus, dryRun bool, verb string) {
+	for _, migration := range migrations {
+		if dryRun {
+			fmt.Printf("-- %s store migration %d: %s\n%s\n", store, migration.Version, migration.Description, migration.SQL)
+		} else {
+			fmt.Printf("%s %s store migration %d: %s\n", verb, store, migration.Version, migration.Description)
+		}
+	}
+}
+
+// migrationTargets returns the configured stores that have schema migrations
+func migrationTargets() ([]migrationTarget, error) {
+	cfg, err := loadMigrationConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []migrationTarget
+
+	appStoreFactory, err := applicationstore.NewFactoryFromAppConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create application store factory: %w", err)
+	}
+	// Stores without a persistent schema, like memory, have nothing to migrate
+	if migrator, err := appStoreFactory.Migrator(); err == nil {
+		targets = append(targets, migrationTarget{
+			name: "application",
+			status: func(ctx context.Context) ([]migrationStatus, error) {
+				migrations, err := migrator.MigrationStatus(ctx)
+				return fromAppMigrations(migrations), err
+			},
+			migrate: func(ctx context.Context, dryRun bool) ([]migrationStatus, error) {
+				migrations, err := migrator.Migrate(ctx, dryRun)
+				return fromAppMigrations(migrations), err
+			},
+		})
+	}
+
+	telemetryStoreFactory, err := telemetrystore.NewFactoryFromAppConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create telemetry store factory: %w", err)
+	}
+	if migrator, err := telemetryStoreFactory.Migrator(); err == nil {
+		targets = append(targets, migrationTarget{
+			name: "telemetry",
+			status: func(ctx context.Context) ([]migrationStatus, error) {
+				migrations, err := migrator.MigrationStatus(ctx)
+				return fromTelemetryMigrations(migrations), err
+			},
+			migrate: func(ctx context.Context, dryRun bool) ([]migrationStatus, error) {
+				migrations, err := migrator.Migrate(ctx, dryRun)
+				return fromTelemetryMigrations(migrations), err
+			},
+		})
+	}
+
+	return targets, nil
+}
+
+func loadMigrationConfig() (*config.Config, error) {
+	cfg, err := config.LoadConfig(viper.GetString("config"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+func fromAppMigrations(migrations []apptypes.MigrationStatus) []migrationStatus {
+	result := make([]migrationStatus, len(migrations))
+	for i, migration := range migrations {
+		result[i] = migrationStatus(migration)
+	}
+	return result
+}
+
+func fromTelemetryMigrations(migrations []telemetrytypes.MigrationStatus) []migrationStatus {
+	result := make([]migrationStatus, len(migrations))
+	for i, migration := range migrations {
+		result[i] = migrationStatus(migration)
+	}
+	return result
+}
@@ -116,7 +116,7 @@ func (ts *TestServer) initMemoryStorage() {
 
 	// For telemetry, use a temp file for DuckDB
 	telemetryDBPath := filepath.Join(ts.tempDir, "telemetry-mem.db")
-	telemetryFactory := duckdb.NewFactory(telemetryDBPath)
+	telemetryFactory := duckdb.NewFactory(telemetryDBPath, duckdb.Options{})
 	if err := telemetryFactory.Initialize(ts.logger); err != nil {
 		ts.t.Fatalf("Failed to initialize memory telemetry store: %v", err)
 	}
@@ -155,7 +155,7 @@ func (ts *TestServer) initDatabaseStorage() {
 	ts.appStoreFactory = appFactory
 
 	// Telemetry store
-	telemetryFactory := duckdb.NewFactory(telemetryDBPath)
+	telemetryFactory := duckdb.NewFactory(telemetryDBPath, duckdb.Options{})
 	if err := telemetryFactory.Initialize(ts.logger); err != nil {
 		ts.t.Fatalf("Failed to initialize DuckDB telemetry store: %v", err)
 	}
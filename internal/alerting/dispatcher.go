@@ -0,0 +1,320 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/metrics"
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// maxRetryBackoff caps the delay between delivery attempts
+const maxRetryBackoff = 30 * time.Second
+
+// Receiver is a named notification destination
+type Receiver struct {
+	Name     string
+	Notifier Notifier
+	// MaxRetries is how many times a failed delivery is retried
+	MaxRetries int
+}
+
+// DispatcherConfig controls how alerts are grouped into notifications
+type DispatcherConfig struct {
+	// GroupBy lists the labels whose values group alerts into one notification
+	GroupBy []string
+	// GroupWait is how long a new group waits for more alerts before its first notification
+	GroupWait time.Duration
+	// GroupInterval is how long a group waits before notifying about changes
+	GroupInterval time.Duration
+	// RepeatInterval is how long a group waits before repeating an unchanged notification
+	RepeatInterval time.Duration
+	Receivers      []Receiver
+}
+
+// Dispatcher groups firing and resolved alerts, drops those muted by a
+// silence and notifies every receiver once per change of a group, repeating
+// unchanged firing groups every repeat interval
+type Dispatcher struct {
+	config       DispatcherConfig
+	metrics      *metrics.AlertingMetrics
+	logger       *zap.Logger
+	retryBackoff time.Duration
+	mu           sync.Mutex
+	groups       map[string]*alertGroup
+	silences     []*silence
+	wg           sync.WaitGroup
+}
+
+// alertGroup is the set of alerts sharing the values of the group-by labels
+type alertGroup struct {
+	key       string
+	labels    map[string]string
+	alerts    map[string]*services.Alert
+	createdAt time.Time
+	// flushedAt is when the group was last notified, zero before the first notification
+	flushedAt time.Time
+	// notified holds the state each alert had in the last notification
+	notified map[string]services.AlertState
+}
+
+// NewDispatcher creates a new alert dispatcher
+func NewDispatcher(config DispatcherConfig, alertingMetrics *metrics.AlertingMetrics, logger *zap.Logger) *Dispatcher {
+	if alertingMetrics == nil {
+		alertingMetrics = metrics.NewAlertingMetrics(metrics.NullFactory)
+	}
+	return &Dispatcher{
+		config:       config,
+		metrics:      alertingMetrics,
+		logger:       logger,
+		retryBackoff: time.Second,
+		groups:       make(map[string]*alertGroup),
+	}
+}
+
+// SetSilences replaces the silences muting notifications
+func (d *Dispatcher) SetSilences(silences []*services.Silence, now time.Time) error {
+	compiled, err := compileSilences(silences, now)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.silences = compiled
+	d.mu.Unlock()
+	return nil
+}
+
+// SilencedBy returns the IDs of the silences muting alerts with the given labels
+func (d *Dispatcher) SilencedBy(labels map[string]string, now time.Time) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.silencedBy(labels, now)
+}
+
+func (d *Dispatcher) silencedBy(labels map[string]string, now time.Time) []string {
+	var ids []string
+	for _, s := range d.silences {
+		if s.mutes(labels, now) {
+			ids = append(ids, s.id)
+		}
+	}
+	return ids
+}
+
+// Add adds firing and resolved alerts to their groups. Firing alerts refresh
+// the alerts already known; pending alerts are ignored.
+func (d *Dispatcher) Add(alerts []*services.Alert, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, alert := range alerts {
+		if alert.State == services.AlertStatePending {
+			continue
+		}
+		key, labels := d.groupKey(alert.Labels)
+		group, ok := d.groups[key]
+		if !ok {
+			group = &alertGroup{
+				key:       key,
+				labels:    labels,
+				alerts:    make(map[string]*services.Alert),
+				createdAt: now,
+				notified:  make(map[string]services.AlertState),
+			}
+			d.groups[key] = group
+		}
+		group.alerts[alert.Fingerprint] = copyAlert(alert)
+	}
+}
+
+// groupKey returns the key and labels of the group an alert belongs to
+func (d *Dispatcher) groupKey(labels map[string]string) (string, map[string]string) {
+	groupLabels := make(map[string]string, len(d.config.GroupBy))
+	parts := make([]string, 0, len(d.config.GroupBy))
+	for _, name := range d.config.GroupBy {
+		groupLabels[name] = labels[name]
+		parts = append(parts, fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+	return "{" + strings.Join(parts, ",") + "}", groupLabels
+}
+
+// Flush notifies the receivers of every group that is due at now. A new
+// group is due once its group wait has passed, a notified group once its
+// group interval has passed, and it is notified if its alerts changed since
+// the last notification or its repeat interval has passed while firing.
+// Notifications are delivered in the background.
+func (d *Dispatcher) Flush(ctx context.Context, now time.Time) {
+	d.mu.Lock()
+	var notifications []*Notification
+	for key, group := range d.groups {
+		if now.Before(d.dueAt(group)) {
+			continue
+		}
+		alerts, changed := d.plan(group, now)
+		repeat := !group.flushedAt.IsZero() && !now.Before(group.flushedAt.Add(d.config.RepeatInterval))
+		if len(alerts) > 0 && (changed || (repeat && hasFiring(alerts))) {
+			notifications = append(notifications, &Notification{
+				GroupKey:    group.key,
+				GroupLabels: group.labels,
+				Alerts:      alerts,
+				Timestamp:   now,
+			})
+			group.flushedAt = now
+			group.notified = make(map[string]services.AlertState, len(alerts))
+			for _, alert := range alerts {
+				group.notified[alert.Fingerprint] = alert.State
+			}
+		}
+
+		// Resolved alerts are only reported once
+		for fingerprint, alert := range group.alerts {
+			if alert.State == services.AlertStateResolved {
+				delete(group.alerts, fingerprint)
+				delete(group.notified, fingerprint)
+			}
+		}
+		if len(group.alerts) == 0 {
+			delete(d.groups, key)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, notification := range notifications {
+		for _, receiver := range d.config.Receivers {
+			n := *notification
+			n.Receiver = receiver.Name
+			d.wg.Add(1)
+			go func(receiver Receiver) {
+				defer d.wg.Done()
+				d.deliver(ctx, receiver, &n)
+			}(receiver)
+		}
+	}
+}
+
+// NextFlush returns the earliest time a group with unnotified changes or a
+// firing group waiting to be repeated is due, or the zero time if none is
+func (d *Dispatcher) NextFlush(now time.Time) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var next time.Time
+	for _, group := range d.groups {
+		alerts, changed := d.plan(group, now)
+		var due time.Time
+		switch {
+		case changed:
+			due = d.dueAt(group)
+		case hasFiring(alerts) && !group.flushedAt.IsZero():
+			due = group.flushedAt.Add(d.config.RepeatInterval)
+		default:
+			continue
+		}
+		if next.IsZero() || due.Before(next) {
+			next = due
+		}
+	}
+	return next
+}
+
+// dueAt returns when a group may next be notified
+func (d *Dispatcher) dueAt(group *alertGroup) time.Time {
+	if group.flushedAt.IsZero() {
+		return group.createdAt.Add(d.config.GroupWait)
+	}
+	return group.flushedAt.Add(d.config.GroupInterval)
+}
+
+// plan returns the alerts of a group a notification at now would hold,
+// sorted by fingerprint, and whether they differ from the last notification.
+// Silenced alerts are left out, as are resolved alerts that were never
+// notified as firing.
+func (d *Dispatcher) plan(group *alertGroup, now time.Time) ([]*services.Alert, bool) {
+	alerts := make([]*services.Alert, 0, len(group.alerts))
+	changed := false
+	for fingerprint, alert := range group.alerts {
+		if len(d.silencedBy(alert.Labels, now)) > 0 {
+			continue
+		}
+		notified, ok := group.notified[fingerprint]
+		if alert.State == services.AlertStateResolved && notified != services.AlertStateFiring {
+			continue
+		}
+		if !ok || notified != alert.State {
+			changed = true
+		}
+		alerts = append(alerts, copyAlert(alert))
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Fingerprint < alerts[j].Fingerprint })
+	return alerts, changed
+}
+
+// deliver sends a notification to a receiver, retrying retryable failures
+// with exponential backoff
+func (d *Dispatcher) deliver(ctx context.Context, receiver Receiver, n *Notification) {
+	backoff := d.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := receiver.Notifier.Notify(ctx, n)
+		if err == nil {
+			d.metrics.Notifications.Inc(1)
+			d.logger.Debug("Delivered alert notification",
+				zap.String("receiver", receiver.Name),
+				zap.String("group", n.GroupKey),
+				zap.Int("alerts", len(n.Alerts)))
+			return
+		}
+
+		var retryable *retryableError
+		if !errors.As(err, &retryable) || attempt >= receiver.MaxRetries || ctx.Err() != nil {
+			d.metrics.NotificationFailures.Inc(1)
+			d.logger.Warn("Failed to deliver alert notification",
+				zap.String("receiver", receiver.Name),
+				zap.String("group", n.GroupKey),
+				zap.Int("attempts", attempt+1),
+				zap.Error(err))
+			return
+		}
+
+		d.metrics.NotificationRetries.Inc(1)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// wait blocks until notifications in flight have been delivered or given up
+func (d *Dispatcher) wait() {
+	d.wg.Wait()
+}
+
+// hasFiring reports whether any of the alerts fires
+func hasFiring(alerts []*services.Alert) bool {
+	for _, alert := range alerts {
+		if alert.State == services.AlertStateFiring {
+			return true
+		}
+	}
+	return false
+}
+
+// copyAlert copies an alert; labels and annotations are never modified in
+// place, so they are shared
+func copyAlert(alert *services.Alert) *services.Alert {
+	alertCopy := *alert
+	return &alertCopy
+}
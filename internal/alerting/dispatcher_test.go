@@ -0,0 +1,163 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package alerting
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+func firingAlert(labels map[string]string, activeAt time.Time) *services.Alert {
+	return &services.Alert{
+		Fingerprint: fingerprint(labels),
+		Labels:      labels,
+		State:       services.AlertStateFiring,
+		ActiveAt:    activeAt,
+	}
+}
+
+func newTestDispatcher(notifier Notifier, maxRetries int) *Dispatcher {
+	d := NewDispatcher(DispatcherConfig{
+		GroupBy:        []string{"alertname", "service"},
+		GroupWait:      30 * time.Second,
+		GroupInterval:  5 * time.Minute,
+		RepeatInterval: time.Hour,
+		Receivers:      []Receiver{{Name: "test", Notifier: notifier, MaxRetries: maxRetries}},
+	}, nil, zap.NewNop())
+	d.retryBackoff = time.Millisecond
+	return d
+}
+
+func TestDispatcher_GroupsAndDeduplicates(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	notifier := &stubNotifier{}
+	d := newTestDispatcher(notifier, 0)
+	ctx := context.Background()
+
+	a := firingAlert(map[string]string{"alertname": "HighErrorRate", "service": "api", "instance": "a"}, now)
+	b := firingAlert(map[string]string{"alertname": "HighErrorRate", "service": "api", "instance": "b"}, now)
+	c := firingAlert(map[string]string{"alertname": "HighErrorRate", "service": "web"}, now)
+	d.Add([]*services.Alert{a, b, c}, now)
+
+	// Nothing is sent before the group wait has passed
+	d.Flush(ctx, now.Add(10*time.Second))
+	d.wait()
+	assert.Empty(t, notifier.delivered())
+	assert.Equal(t, now.Add(30*time.Second), d.NextFlush(now))
+
+	d.Flush(ctx, now.Add(30*time.Second))
+	d.wait()
+	delivered := notifier.delivered()
+	require.Len(t, delivered, 2)
+	sizes := map[string]int{}
+	for _, n := range delivered {
+		sizes[n.GroupKey] = len(n.Alerts)
+		assert.Equal(t, "test", n.Receiver)
+	}
+	assert.Equal(t, map[string]int{
+		`{alertname="HighErrorRate",service="api"}`: 2,
+		`{alertname="HighErrorRate",service="web"}`: 1,
+	}, sizes)
+
+	// Refreshing unchanged alerts does not notify again until the repeat interval
+	d.Add([]*services.Alert{a, b, c}, now.Add(6*time.Minute))
+	d.Flush(ctx, now.Add(6*time.Minute))
+	d.wait()
+	assert.Len(t, notifier.delivered(), 2)
+	assert.Equal(t, now.Add(30*time.Second+time.Hour), d.NextFlush(now.Add(6*time.Minute)))
+
+	d.Flush(ctx, now.Add(30*time.Second+time.Hour))
+	d.wait()
+	assert.Len(t, notifier.delivered(), 4)
+
+	// A change waits for the group interval and is sent once
+	resolvedAt := now.Add(61 * time.Minute)
+	resolved := *b
+	resolved.State = services.AlertStateResolved
+	resolved.ResolvedAt = &resolvedAt
+	d.Add([]*services.Alert{&resolved}, resolvedAt)
+	d.Flush(ctx, resolvedAt)
+	d.wait()
+	assert.Len(t, notifier.delivered(), 4)
+
+	d.Flush(ctx, now.Add(30*time.Second+time.Hour+5*time.Minute))
+	d.Flush(ctx, now.Add(30*time.Second+time.Hour+10*time.Minute))
+	d.wait()
+	delivered = notifier.delivered()
+	require.Len(t, delivered, 5)
+	require.Len(t, delivered[4].Alerts, 2)
+	assert.Equal(t, services.AlertStateFiring, delivered[4].Status())
+}
+
+func TestDispatcher_Silences(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	notifier := &stubNotifier{}
+	d := newTestDispatcher(notifier, 0)
+	ctx := context.Background()
+
+	require.NoError(t, d.SetSilences([]*services.Silence{
+		{
+			ID:       "silence-1",
+			Matchers: []services.SilenceMatcher{{Name: "service", Operator: "=~", Value: "api|auth"}},
+			StartsAt: now,
+			EndsAt:   now.Add(time.Hour),
+		},
+		{
+			ID:       "expired",
+			Matchers: []services.SilenceMatcher{{Name: "service", Operator: "=", Value: "web"}},
+			StartsAt: now.Add(-2 * time.Hour),
+			EndsAt:   now.Add(-time.Hour),
+		},
+	}, now))
+
+	api := firingAlert(map[string]string{"alertname": "HighErrorRate", "service": "api"}, now)
+	web := firingAlert(map[string]string{"alertname": "HighErrorRate", "service": "web"}, now)
+	assert.Equal(t, []string{"silence-1"}, d.SilencedBy(api.Labels, now))
+	assert.Empty(t, d.SilencedBy(web.Labels, now))
+
+	d.Add([]*services.Alert{api, web}, now)
+	d.Flush(ctx, now.Add(time.Minute))
+	d.wait()
+	delivered := notifier.delivered()
+	require.Len(t, delivered, 1)
+	assert.Equal(t, "web", delivered[0].Alerts[0].Labels["service"])
+
+	// Once the silence ends the alert is notified
+	d.Add([]*services.Alert{api, web}, now.Add(time.Hour))
+	d.Flush(ctx, now.Add(time.Hour))
+	d.wait()
+	delivered = notifier.delivered()
+	require.Len(t, delivered, 2)
+	assert.Equal(t, "api", delivered[1].Alerts[0].Labels["service"])
+}
+
+func TestDispatcher_RetriesDelivery(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	alert := firingAlert(map[string]string{"alertname": "HighErrorRate", "service": "api"}, now)
+
+	notifier := &stubNotifier{failures: 2, err: &retryableError{err: errors.New("receiver returned status 503")}}
+	d := newTestDispatcher(notifier, 3)
+	d.Add([]*services.Alert{alert}, now)
+	d.Flush(context.Background(), now.Add(time.Minute))
+	d.wait()
+	assert.Len(t, notifier.delivered(), 1)
+	assert.Equal(t, 3, notifier.attempts)
+
+	// Client errors are not retried
+	notifier = &stubNotifier{failures: 1, err: errors.New("receiver returned status 400")}
+	d = newTestDispatcher(notifier, 3)
+	d.Add([]*services.Alert{alert}, now)
+	d.Flush(context.Background(), now.Add(time.Minute))
+	d.wait()
+	assert.Empty(t, notifier.delivered())
+	assert.Equal(t, 1, notifier.attempts)
+}
@@ -0,0 +1,542 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/metrics"
+	"github.com/getlawrence/lawrence-oss/internal/query"
+	"github.com/getlawrence/lawrence-oss/internal/schedule"
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// Querier executes parsed Lawrence QL queries
+type Querier interface {
+	Execute(ctx context.Context, q query.Query, execCtx *query.ExecutionContext) ([]query.QueryResult, *query.QueryMeta, error)
+}
+
+// AlertSource produces alerts from state other than Lawrence QL
+// expressions, such as the built-in fleet health checks
+type AlertSource interface {
+	// Check returns the alerts active at now. Every alert needs an
+	// alertname label.
+	Check(ctx context.Context, now time.Time) ([]SourceAlert, error)
+}
+
+// SourceAlert is an alert reported active by an alert source
+type SourceAlert struct {
+	Labels      map[string]string
+	Annotations map[string]string
+	Value       float64
+}
+
+// Engine evaluates the enabled alert rules and the alert sources, each on
+// its own interval, tracks the pending and firing alerts they produce and
+// hands firing and resolved alerts to the dispatcher. It implements
+// services.AlertEvaluator.
+type Engine struct {
+	querier    Querier
+	dispatcher *Dispatcher
+	metrics    *metrics.AlertingMetrics
+	logger     *zap.Logger
+	now        func() time.Time
+	mu         sync.Mutex
+	rules      map[string]*ruleState
+	sources    map[string]*ruleState
+	loop       *schedule.Loop
+}
+
+// ruleState is an enabled rule with its parsed expression and annotation
+// templates, or an alert source, with its next evaluation, status and active
+// alerts
+type ruleState struct {
+	schedule.Entry
+	rule        services.AlertRule
+	query       query.Query
+	source      AlertSource
+	forDuration time.Duration
+	annotations map[string]*template.Template
+	status      services.AlertRuleStatus
+	// alerts holds the pending and firing alerts keyed by fingerprint
+	alerts map[string]*services.Alert
+}
+
+// NewEngine creates a new alert rule engine notifying through the dispatcher
+func NewEngine(querier Querier, dispatcher *Dispatcher, alertingMetrics *metrics.AlertingMetrics, logger *zap.Logger) *Engine {
+	if alertingMetrics == nil {
+		alertingMetrics = metrics.NewAlertingMetrics(metrics.NullFactory)
+	}
+	e := &Engine{
+		querier:    querier,
+		dispatcher: dispatcher,
+		metrics:    alertingMetrics,
+		logger:     logger,
+		now:        time.Now,
+		rules:      make(map[string]*ruleState),
+		sources:    make(map[string]*ruleState),
+	}
+	e.loop = schedule.NewLoop(func() time.Time { return e.now() }, e.nextRun, e.run)
+	return e
+}
+
+// AddSource evaluates an alert source every interval alongside the alert
+// rules. Its alerts fire once they stayed active for the for duration. Sources
+// are added before the engine is started.
+func (e *Engine) AddSource(name string, source AlertSource, interval, forDuration time.Duration) {
+	e.mu.Lock()
+	e.sources[name] = &ruleState{
+		Entry:       schedule.Entry{Interval: interval, Next: e.now()},
+		rule:        services.AlertRule{Name: name},
+		source:      source,
+		forDuration: forDuration,
+		status:      services.AlertRuleStatus{Health: services.RuleHealthUnknown},
+		alerts:      make(map[string]*services.Alert),
+	}
+	e.mu.Unlock()
+	e.loop.Reload()
+}
+
+// LoadAlertRules replaces the evaluated rule set with the enabled rules.
+// Rules whose definition did not change keep their schedule, status and
+// alerts; new and changed rules are evaluated on the next run. Firing alerts
+// of removed and changed rules are resolved. The rule set is left unchanged
+// if any rule fails to parse.
+func (e *Engine) LoadAlertRules(rules []*services.AlertRule) error {
+	now := e.now()
+	loaded := make(map[string]*ruleState, len(rules))
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		state, err := newRuleState(rule)
+		if err != nil {
+			return fmt.Errorf("alert rule %q: %w", rule.Name, err)
+		}
+		state.Next = now
+		loaded[rule.ID] = state
+	}
+
+	var resolved []*services.Alert
+	e.mu.Lock()
+	for id, existing := range e.rules {
+		if state, ok := loaded[id]; ok && sameDefinition(&existing.rule, &state.rule) {
+			loaded[id] = existing
+			continue
+		}
+		resolved = append(resolved, resolveAll(existing, now)...)
+	}
+	e.rules = loaded
+	e.mu.Unlock()
+
+	e.dispatcher.Add(resolved, now)
+	e.metrics.ActiveRules.Update(int64(len(loaded)))
+	e.loop.Reload()
+	return nil
+}
+
+// LoadSilences replaces the silences muting notifications
+func (e *Engine) LoadSilences(silences []*services.Silence) error {
+	if err := e.dispatcher.SetSilences(silences, e.now()); err != nil {
+		return err
+	}
+	e.loop.Reload()
+	return nil
+}
+
+// AlertRuleStatus returns the status of an enabled rule, or nil for rules
+// that are disabled or unknown
+func (e *Engine) AlertRuleStatus(id string) *services.AlertRuleStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state, ok := e.rules[id]
+	if !ok {
+		return nil
+	}
+	status := state.status
+	return &status
+}
+
+// Alerts returns the pending and firing alerts of every rule and source,
+// ordered by alert name and fingerprint, with the silences muting them
+func (e *Engine) Alerts() []*services.Alert {
+	now := e.now()
+	e.mu.Lock()
+	alerts := make([]*services.Alert, 0)
+	for _, state := range e.states() {
+		for _, alert := range state.alerts {
+			alerts = append(alerts, copyAlert(alert))
+		}
+	}
+	e.mu.Unlock()
+
+	for _, alert := range alerts {
+		alert.SilencedBy = e.dispatcher.SilencedBy(alert.Labels, now)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Labels["alertname"] != alerts[j].Labels["alertname"] {
+			return alerts[i].Labels["alertname"] < alerts[j].Labels["alertname"]
+		}
+		return alerts[i].Fingerprint < alerts[j].Fingerprint
+	})
+	return alerts
+}
+
+// states returns the rule and source states. The caller holds e.mu.
+func (e *Engine) states() []*ruleState {
+	states := make([]*ruleState, 0, len(e.rules)+len(e.sources))
+	for _, state := range e.rules {
+		states = append(states, state)
+	}
+	for _, state := range e.sources {
+		states = append(states, state)
+	}
+	return states
+}
+
+// newRuleState parses the expression, durations and annotations of a rule
+func newRuleState(rule *services.AlertRule) (*ruleState, error) {
+	if err := services.ValidateAlertRule(rule); err != nil {
+		return nil, err
+	}
+	interval, err := time.ParseDuration(rule.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interval %q: %w", rule.Interval, err)
+	}
+	var forDuration time.Duration
+	if rule.For != "" {
+		if forDuration, err = time.ParseDuration(rule.For); err != nil {
+			return nil, fmt.Errorf("invalid for duration %q: %w", rule.For, err)
+		}
+	}
+	parsed, err := query.NewParser(rule.Expr).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+
+	annotations := make(map[string]*template.Template, len(rule.Annotations))
+	for name, text := range rule.Annotations {
+		tmpl, err := services.ParseAnnotationTemplate(name, text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %q: %w", name, err)
+		}
+		annotations[name] = tmpl
+	}
+
+	return &ruleState{
+		Entry:       schedule.Entry{Interval: interval},
+		rule:        *rule,
+		query:       parsed,
+		forDuration: forDuration,
+		annotations: annotations,
+		status:      services.AlertRuleStatus{Health: services.RuleHealthUnknown},
+		alerts:      make(map[string]*services.Alert),
+	}, nil
+}
+
+// sameDefinition reports whether two rules evaluate and label alerts the same way
+func sameDefinition(a, b *services.AlertRule) bool {
+	return a.Name == b.Name && a.Expr == b.Expr && a.Operator == b.Operator &&
+		a.Threshold == b.Threshold && a.For == b.For && a.Interval == b.Interval &&
+		maps.Equal(a.Labels, b.Labels) && maps.Equal(a.Annotations, b.Annotations)
+}
+
+// Start evaluates each rule when it is due and flushes the dispatcher until
+// the engine is stopped
+func (e *Engine) Start() {
+	e.logger.Info("Starting alert rule engine")
+	e.loop.Start()
+}
+
+// Stop stops the engine, cancelling an in-progress evaluation and pending
+// notification retries
+func (e *Engine) Stop(ctx context.Context) error {
+	if err := e.loop.Stop(ctx); err != nil {
+		return err
+	}
+	return schedule.Wait(ctx, e.dispatcher.wait)
+}
+
+// nextRun returns the earliest time a rule, a source or a notification group is due,
+// or a minute from now without either
+func (e *Engine) nextRun(now time.Time) time.Time {
+	e.mu.Lock()
+	next := schedule.Next(now, slices.Values(e.states()))
+	e.mu.Unlock()
+
+	if flush := e.dispatcher.NextFlush(now); !flush.IsZero() && flush.Before(next) {
+		next = flush
+	}
+	return next
+}
+
+// run evaluates every rule and source due at now, one at a time, then
+// notifies the groups that are due
+func (e *Engine) run(ctx context.Context, now time.Time) {
+	e.mu.Lock()
+	due := schedule.Due(now, slices.Values(e.states()))
+	e.mu.Unlock()
+
+	for _, state := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		e.evaluate(ctx, state, now)
+	}
+
+	e.mu.Lock()
+	firing := 0
+	for _, state := range e.states() {
+		for _, alert := range state.alerts {
+			if alert.State == services.AlertStateFiring {
+				firing++
+			}
+		}
+	}
+	e.mu.Unlock()
+	e.metrics.FiringAlerts.Update(int64(firing))
+
+	e.dispatcher.Flush(ctx, now)
+}
+
+// evaluate runs a rule or source once, advances its alerts and hands firing and
+// resolved alerts to the dispatcher. Alerts are left unchanged when the
+// evaluation fails.
+func (e *Engine) evaluate(ctx context.Context, state *ruleState, now time.Time) {
+	start := time.Now()
+	active, err := e.activeSamples(ctx, state, now)
+	duration := time.Since(start)
+
+	e.metrics.Evaluations.Inc(1)
+	e.metrics.EvaluationDuration.Record(duration)
+	status := services.AlertRuleStatus{
+		Health:         services.RuleHealthOK,
+		LastEvaluation: &now,
+		LastDuration:   duration,
+	}
+	if err != nil {
+		e.metrics.EvaluationFailures.Inc(1)
+		status.Health = services.RuleHealthError
+		status.LastError = err.Error()
+		e.logger.Warn("Failed to evaluate alert rule",
+			zap.String("rule_id", state.rule.ID),
+			zap.String("name", state.rule.Name),
+			zap.Error(err))
+	}
+
+	var notify []*services.Alert
+	e.mu.Lock()
+	if err == nil {
+		for fingerprint, sample := range active {
+			alert, ok := state.alerts[fingerprint]
+			if !ok {
+				alert = &services.Alert{
+					Fingerprint: fingerprint,
+					RuleID:      state.rule.ID,
+					Labels:      sample.labels,
+					State:       services.AlertStatePending,
+					ActiveAt:    now,
+				}
+				state.alerts[fingerprint] = alert
+			}
+			alert.Value = sample.value
+			alert.Annotations = sample.annotations
+			if alert.State == services.AlertStatePending && now.Sub(alert.ActiveAt) >= state.forDuration {
+				firedAt := now
+				alert.State = services.AlertStateFiring
+				alert.FiredAt = &firedAt
+			}
+		}
+		for fingerprint, alert := range state.alerts {
+			if _, ok := active[fingerprint]; ok {
+				continue
+			}
+			delete(state.alerts, fingerprint)
+			if alert.State == services.AlertStateFiring {
+				resolvedAt := now
+				alert.State = services.AlertStateResolved
+				alert.ResolvedAt = &resolvedAt
+				notify = append(notify, alert)
+			}
+		}
+	}
+	for _, alert := range state.alerts {
+		if alert.State == services.AlertStateFiring {
+			notify = append(notify, copyAlert(alert))
+		}
+	}
+	status.ActiveAlerts = len(state.alerts)
+	state.status = status
+	e.mu.Unlock()
+
+	e.dispatcher.Add(notify, now)
+}
+
+// activeSample is a sample of a rule's expression that compares true
+// against its threshold, or an alert reported by a source, with the labels
+// and annotations of the alert it produces
+type activeSample struct {
+	labels      map[string]string
+	annotations map[string]string
+	value       float64
+}
+
+// activeSamples evaluates the expression of a rule and returns the samples
+// that compare true against its threshold, keyed by alert fingerprint
+func (e *Engine) activeSamples(ctx context.Context, state *ruleState, now time.Time) (map[string]activeSample, error) {
+	if state.source != nil {
+		return sourceSamples(ctx, state.source, now)
+	}
+
+	results, _, err := e.querier.Execute(ctx, state.query, &query.ExecutionContext{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute expression: %w", err)
+	}
+
+	samples := query.InstantVector(results)
+	if len(samples) == 0 && len(results) > 0 {
+		return nil, fmt.Errorf("expression returned %d log or trace results, alert rules need numeric samples", len(results))
+	}
+
+	active := make(map[string]activeSample)
+	for _, sample := range samples {
+		value, ok := query.SampleValue(sample.Value)
+		if !ok || !compare(state.rule.Operator, value, state.rule.Threshold) {
+			continue
+		}
+		labels := alertLabels(&state.rule, sample.Labels)
+		active[fingerprint(labels)] = activeSample{
+			labels:      labels,
+			annotations: state.expand(labels, value),
+			value:       value,
+		}
+	}
+	return active, nil
+}
+
+// sourceSamples checks an alert source and returns its active alerts keyed
+// by fingerprint
+func sourceSamples(ctx context.Context, source AlertSource, now time.Time) (map[string]activeSample, error) {
+	alerts, err := source.Check(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check source: %w", err)
+	}
+
+	active := make(map[string]activeSample, len(alerts))
+	for _, alert := range alerts {
+		if alert.Labels["alertname"] == "" {
+			return nil, fmt.Errorf("source returned an alert without an alertname label")
+		}
+		active[fingerprint(alert.Labels)] = activeSample{
+			labels:      alert.Labels,
+			annotations: alert.Annotations,
+			value:       alert.Value,
+		}
+	}
+	return active, nil
+}
+
+// expand renders the annotations of a rule for an alert. A template that
+// fails to render is replaced by the error.
+func (s *ruleState) expand(labels map[string]string, value float64) map[string]string {
+	if len(s.annotations) == 0 {
+		return nil
+	}
+	data := struct {
+		Labels map[string]string
+		Value  float64
+	}{Labels: labels, Value: value}
+
+	annotations := make(map[string]string, len(s.annotations))
+	for name, tmpl := range s.annotations {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			annotations[name] = fmt.Sprintf("<error expanding template: %v>", err)
+			continue
+		}
+		annotations[name] = b.String()
+	}
+	return annotations
+}
+
+// resolveAll resolves the firing alerts of a rule that is no longer evaluated
+func resolveAll(state *ruleState, now time.Time) []*services.Alert {
+	var resolved []*services.Alert
+	for _, alert := range state.alerts {
+		if alert.State != services.AlertStateFiring {
+			continue
+		}
+		resolvedAt := now
+		alert.State = services.AlertStateResolved
+		alert.ResolvedAt = &resolvedAt
+		resolved = append(resolved, alert)
+	}
+	return resolved
+}
+
+// alertLabels returns the labels of the alert a sample produces: the
+// sample's labels without the metric name, overridden by the rule's labels,
+// and alertname
+func alertLabels(rule *services.AlertRule, sampleLabels map[string]string) map[string]string {
+	labels := make(map[string]string, len(sampleLabels)+len(rule.Labels)+1)
+	for key, value := range sampleLabels {
+		if key != "name" {
+			labels[key] = value
+		}
+	}
+	for key, value := range rule.Labels {
+		labels[key] = value
+	}
+	labels["alertname"] = rule.Name
+	return labels
+}
+
+// fingerprint identifies an alert by a hash of its sorted labels
+func fingerprint(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	for _, key := range keys {
+		h.Write([]byte(key))
+		h.Write([]byte{0xff})
+		h.Write([]byte(labels[key]))
+		h.Write([]byte{0xff})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// compare applies an alert rule operator to a sample value and the threshold
+func compare(operator string, value, threshold float64) bool {
+	switch operator {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case "==":
+		return value == threshold
+	case "!=":
+		return value != threshold
+	}
+	return false
+}
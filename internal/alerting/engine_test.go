@@ -0,0 +1,265 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package alerting
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/query"
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// stubQuerier returns fixed results
+type stubQuerier struct {
+	results []query.QueryResult
+	err     error
+}
+
+func (q *stubQuerier) Execute(ctx context.Context, parsed query.Query, execCtx *query.ExecutionContext) ([]query.QueryResult, *query.QueryMeta, error) {
+	return q.results, &query.QueryMeta{}, q.err
+}
+
+// stubNotifier captures delivered notifications and fails the first
+// failures deliveries with err
+type stubNotifier struct {
+	mu            sync.Mutex
+	notifications []*Notification
+	failures      int
+	err           error
+	attempts      int
+}
+
+func (n *stubNotifier) Notify(ctx context.Context, notification *Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.attempts++
+	if n.attempts <= n.failures {
+		return n.err
+	}
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+func (n *stubNotifier) delivered() []*Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*Notification(nil), n.notifications...)
+}
+
+func makeAlertRule(id string) *services.AlertRule {
+	return &services.AlertRule{
+		ID:          id,
+		Name:        "HighErrorRate",
+		Expr:        `sum(rate(metrics{metric="errors_total"} [5m])) by (service)`,
+		Operator:    ">",
+		Threshold:   1,
+		For:         "2m",
+		Interval:    "1m",
+		Labels:      map[string]string{"severity": "page"},
+		Annotations: map[string]string{"summary": "{{ .Labels.service }} errors at {{ .Value }}/s"},
+		Enabled:     true,
+	}
+}
+
+func sample(labels map[string]string, value float64) query.QueryResult {
+	return query.QueryResult{
+		Type:   query.TelemetryTypeMetrics,
+		Labels: labels,
+		Value:  value,
+		Data:   map[string]interface{}{"function": "sum"},
+	}
+}
+
+func newTestEngine(querier Querier, notifier Notifier, now time.Time) *Engine {
+	dispatcher := NewDispatcher(DispatcherConfig{
+		GroupBy:        []string{"alertname"},
+		RepeatInterval: time.Hour,
+		Receivers:      []Receiver{{Name: "test", Notifier: notifier}},
+	}, nil, zap.NewNop())
+	e := NewEngine(querier, dispatcher, nil, zap.NewNop())
+	e.now = func() time.Time { return now }
+	return e
+}
+
+func TestEngine_AlertLifecycle(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	querier := &stubQuerier{results: []query.QueryResult{
+		sample(map[string]string{"service": "api", "name": "errors_total"}, 2.5),
+		sample(map[string]string{"service": "web"}, 0.5),
+	}}
+	notifier := &stubNotifier{}
+	e := newTestEngine(querier, notifier, now)
+	require.NoError(t, e.LoadAlertRules([]*services.AlertRule{makeAlertRule("rule-1")}))
+
+	// Only the sample above the threshold is an alert, pending until the for duration passed
+	e.run(context.Background(), now)
+	alerts := e.Alerts()
+	require.Len(t, alerts, 1)
+	alert := alerts[0]
+	assert.Equal(t, services.AlertStatePending, alert.State)
+	assert.Equal(t, map[string]string{"alertname": "HighErrorRate", "service": "api", "severity": "page"}, alert.Labels)
+	assert.Equal(t, "api errors at 2.5/s", alert.Annotations["summary"])
+	assert.Equal(t, 1, e.AlertRuleStatus("rule-1").ActiveAlerts)
+
+	e.run(context.Background(), now.Add(time.Minute))
+	assert.Equal(t, services.AlertStatePending, e.Alerts()[0].State)
+
+	e.run(context.Background(), now.Add(2*time.Minute))
+	alerts = e.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, services.AlertStateFiring, alerts[0].State)
+	assert.Equal(t, now, alerts[0].ActiveAt)
+	e.dispatcher.wait()
+	delivered := notifier.delivered()
+	require.Len(t, delivered, 1)
+	assert.Equal(t, services.AlertStateFiring, delivered[0].Status())
+	assert.Equal(t, `{alertname="HighErrorRate"}`, delivered[0].GroupKey)
+
+	// The alert resolves once its sample no longer crosses the threshold
+	querier.results = []query.QueryResult{sample(map[string]string{"service": "api"}, 0.2)}
+	e.run(context.Background(), now.Add(3*time.Minute))
+	assert.Empty(t, e.Alerts())
+	e.dispatcher.wait()
+	delivered = notifier.delivered()
+	require.Len(t, delivered, 2)
+	assert.Equal(t, services.AlertStateResolved, delivered[1].Status())
+	require.NotNil(t, delivered[1].Alerts[0].ResolvedAt)
+	assert.Equal(t, now.Add(3*time.Minute), *delivered[1].Alerts[0].ResolvedAt)
+}
+
+func TestEngine_PendingAlertsAreNotNotified(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	querier := &stubQuerier{results: []query.QueryResult{sample(map[string]string{"service": "api"}, 5)}}
+	notifier := &stubNotifier{}
+	e := newTestEngine(querier, notifier, now)
+	require.NoError(t, e.LoadAlertRules([]*services.AlertRule{makeAlertRule("rule-1")}))
+
+	e.run(context.Background(), now)
+	querier.results = nil
+	e.run(context.Background(), now.Add(time.Minute))
+	e.dispatcher.wait()
+	assert.Empty(t, e.Alerts())
+	assert.Empty(t, notifier.delivered())
+}
+
+func TestEngine_EvaluationFailureKeepsAlerts(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	querier := &stubQuerier{results: []query.QueryResult{sample(map[string]string{"service": "api"}, 5)}}
+	rule := makeAlertRule("rule-1")
+	rule.For = ""
+	e := newTestEngine(querier, &stubNotifier{}, now)
+	require.NoError(t, e.LoadAlertRules([]*services.AlertRule{rule}))
+
+	e.run(context.Background(), now)
+	require.Equal(t, services.AlertStateFiring, e.Alerts()[0].State)
+
+	querier.err = errors.New("query timed out")
+	e.run(context.Background(), now.Add(time.Minute))
+	status := e.AlertRuleStatus("rule-1")
+	assert.Equal(t, services.RuleHealthError, status.Health)
+	assert.Contains(t, status.LastError, "query timed out")
+	assert.Len(t, e.Alerts(), 1)
+
+	// Raw logs are not samples a rule can compare
+	querier.err = nil
+	querier.results = []query.QueryResult{{Type: query.TelemetryTypeLogs, Value: "connection refused"}}
+	e.run(context.Background(), now.Add(2*time.Minute))
+	assert.Contains(t, e.AlertRuleStatus("rule-1").LastError, "need numeric samples")
+}
+
+func TestEngine_LoadAlertRules(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	querier := &stubQuerier{results: []query.QueryResult{sample(map[string]string{"service": "api"}, 5)}}
+	notifier := &stubNotifier{}
+	rule := makeAlertRule("rule-1")
+	rule.For = ""
+	e := newTestEngine(querier, notifier, now)
+	require.NoError(t, e.LoadAlertRules([]*services.AlertRule{rule}))
+	e.run(context.Background(), now)
+	e.dispatcher.wait()
+	require.Len(t, e.Alerts(), 1)
+
+	// Reloading an unchanged rule keeps its alerts
+	require.NoError(t, e.LoadAlertRules([]*services.AlertRule{rule}))
+	assert.Len(t, e.Alerts(), 1)
+
+	// An invalid rule leaves the rule set unchanged
+	invalid := makeAlertRule("rule-2")
+	invalid.Expr = "sum(metrics{"
+	assert.ErrorContains(t, e.LoadAlertRules([]*services.AlertRule{invalid}), "invalid expression")
+	assert.Len(t, e.Alerts(), 1)
+
+	// Removing a rule resolves its firing alerts
+	require.NoError(t, e.LoadAlertRules(nil))
+	assert.Empty(t, e.Alerts())
+	assert.Nil(t, e.AlertRuleStatus("rule-1"))
+	e.run(context.Background(), now.Add(time.Minute))
+	e.dispatcher.wait()
+	delivered := notifier.delivered()
+	require.Len(t, delivered, 2)
+	assert.Equal(t, services.AlertStateResolved, delivered[1].Status())
+}
+
+// stubSource reports fixed alerts
+type stubSource struct {
+	alerts []SourceAlert
+}
+
+func (s *stubSource) Check(ctx context.Context, now time.Time) ([]SourceAlert, error) {
+	return s.alerts, nil
+}
+
+func TestEngine_Source(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	source := &stubSource{alerts: []SourceAlert{{
+		Labels:      map[string]string{"alertname": "AgentOffline", "agent_id": "agent-1"},
+		Annotations: map[string]string{"summary": "Agent agent-1 is offline"},
+		Value:       600,
+	}}}
+	notifier := &stubNotifier{}
+	e := newTestEngine(&stubQuerier{}, notifier, now)
+	e.AddSource("fleet", source, time.Minute, 2*time.Minute)
+
+	e.run(context.Background(), now)
+	alerts := e.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, services.AlertStatePending, alerts[0].State)
+	assert.Empty(t, alerts[0].RuleID)
+	assert.Equal(t, "Agent agent-1 is offline", alerts[0].Annotations["summary"])
+
+	// Sources are not rules and survive reloading the rules
+	require.NoError(t, e.LoadAlertRules(nil))
+	e.run(context.Background(), now.Add(2*time.Minute))
+	require.Len(t, e.Alerts(), 1)
+	assert.Equal(t, services.AlertStateFiring, e.Alerts()[0].State)
+	e.dispatcher.wait()
+	require.Len(t, notifier.delivered(), 1)
+
+	source.alerts = nil
+	e.run(context.Background(), now.Add(3*time.Minute))
+	assert.Empty(t, e.Alerts())
+	e.dispatcher.wait()
+	delivered := notifier.delivered()
+	require.Len(t, delivered, 2)
+	assert.Equal(t, services.AlertStateResolved, delivered[1].Status())
+}
+
+func TestCompare(t *testing.T) {
+	assert.True(t, compare(">", 2, 1))
+	assert.False(t, compare(">", 1, 1))
+	assert.True(t, compare(">=", 1, 1))
+	assert.True(t, compare("<", 0, 1))
+	assert.True(t, compare("<=", 1, 1))
+	assert.True(t, compare("==", 1, 1))
+	assert.True(t, compare("!=", 0, 1))
+	assert.False(t, compare("=>", 2, 1))
+}
@@ -0,0 +1,424 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/open-telemetry/opamp-go/protobufs"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/getlawrence/lawrence-oss/internal/opamp"
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// Alert names of the built-in fleet health alerts
+const (
+	AlertAgentOffline            = "AgentOffline"
+	AlertAgentRemoteConfigFailed = "AgentRemoteConfigFailed"
+	AlertAgentComponentUnhealthy = "AgentComponentUnhealthy"
+	AlertAgentConfigDrift        = "AgentConfigDrift"
+	AlertAgentVersionOutdated    = "AgentVersionOutdated"
+	AlertAgentNoTelemetry        = "AgentNoTelemetry"
+)
+
+// ConnectedAgents returns the agents connected over OpAMP
+type ConnectedAgents interface {
+	GetAllAgentsReadonlyClone() map[uuid.UUID]*opamp.Agent
+}
+
+// AgentStore returns the known agents and the configs assigned to them
+type AgentStore interface {
+	ListAgents(ctx context.Context) ([]*services.Agent, error)
+	GetLatestConfigForAgent(ctx context.Context, agentID uuid.UUID) (*services.Config, error)
+	GetLatestConfigForGroup(ctx context.Context, groupID string) (*services.Config, error)
+}
+
+// UsageReader returns the ingestion usage of the telemetry store
+type UsageReader interface {
+	GetTopUsage(ctx context.Context, query services.UsageQuery) ([]services.UsageSummary, error)
+}
+
+// FleetConfig sets the thresholds of the fleet health checks. A zero
+// duration or an empty minimum version disables the check. Telemetry is
+// seen through usage records, so NoTelemetryAfter needs usage tracking and
+// should cover the delay before usage is flushed.
+type FleetConfig struct {
+	OfflineAfter     time.Duration
+	NoTelemetryAfter time.Duration
+	MinAgentVersion  string
+}
+
+// FleetChecker is an alert source raising built-in alerts from the OpAMP
+// state of the fleet: agents offline, failing to apply their remote config,
+// reporting unhealthy components, running a config other than the one
+// assigned to them, running an outdated version or connected without
+// sending telemetry.
+type FleetChecker struct {
+	cfg       FleetConfig
+	connected ConnectedAgents
+	store     AgentStore
+	usage     UsageReader
+	logger    *zap.Logger
+	mu        sync.Mutex
+	// connectedSince holds when each connected agent was first seen connected
+	connectedSince map[uuid.UUID]time.Time
+}
+
+// NewFleetChecker creates a new fleet health alert source
+func NewFleetChecker(cfg FleetConfig, connected ConnectedAgents, store AgentStore, usage UsageReader, logger *zap.Logger) *FleetChecker {
+	return &FleetChecker{
+		cfg:            cfg,
+		connected:      connected,
+		store:          store,
+		usage:          usage,
+		logger:         logger,
+		connectedSince: make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Check returns the fleet health alerts active at now
+func (c *FleetChecker) Check(ctx context.Context, now time.Time) ([]SourceAlert, error) {
+	stored, err := c.store.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	connected := c.connected.GetAllAgentsReadonlyClone()
+	since := c.trackConnected(connected, now)
+
+	var alerts []SourceAlert
+	known := make(map[uuid.UUID]*services.Agent, len(stored))
+	for _, agent := range stored {
+		known[agent.ID] = agent
+		if _, ok := connected[agent.ID]; ok || c.cfg.OfflineAfter <= 0 {
+			continue
+		}
+		if offline := now.Sub(agent.LastSeen); offline >= c.cfg.OfflineAfter {
+			alerts = append(alerts, SourceAlert{
+				Labels: agentLabels(AlertAgentOffline, "critical", agent, nil),
+				Annotations: map[string]string{
+					"summary":     fmt.Sprintf("Agent %s is offline", agentName(agent, nil)),
+					"description": fmt.Sprintf("Agent has not been connected since %s", agent.LastSeen.UTC().Format(time.RFC3339)),
+				},
+				Value: offline.Seconds(),
+			})
+		}
+	}
+
+	var reporting map[string]bool
+	if c.cfg.NoTelemetryAfter > 0 {
+		if reporting, err = c.reportingAgents(ctx, now); err != nil {
+			return nil, err
+		}
+	}
+
+	for id, agent := range connected {
+		record := known[id]
+		alerts = append(alerts, c.statusAlerts(record, agent)...)
+		if alert, ok := c.driftAlert(ctx, record, agent); ok {
+			alerts = append(alerts, alert)
+		}
+		if alert, ok := c.versionAlert(record, agent); ok {
+			alerts = append(alerts, alert)
+		}
+		if reporting != nil && !reporting[id.String()] {
+			connectedFor := now.Sub(since[id])
+			if !agent.StartedAt.IsZero() && now.Sub(agent.StartedAt) < connectedFor {
+				connectedFor = now.Sub(agent.StartedAt)
+			}
+			if connectedFor >= c.cfg.NoTelemetryAfter {
+				alerts = append(alerts, SourceAlert{
+					Labels: agentLabels(AlertAgentNoTelemetry, "warning", record, agent),
+					Annotations: map[string]string{
+						"summary":     fmt.Sprintf("Agent %s sends no telemetry", agentName(record, agent)),
+						"description": fmt.Sprintf("No telemetry was received from the connected agent in the last %s", c.cfg.NoTelemetryAfter),
+					},
+					Value: connectedFor.Seconds(),
+				})
+			}
+		}
+	}
+	return alerts, nil
+}
+
+// trackConnected records when agents were first seen connected, forgetting
+// disconnected agents, and returns the connection times
+func (c *FleetChecker) trackConnected(connected map[uuid.UUID]*opamp.Agent, now time.Time) map[uuid.UUID]time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id := range c.connectedSince {
+		if _, ok := connected[id]; !ok {
+			delete(c.connectedSince, id)
+		}
+	}
+	since := make(map[uuid.UUID]time.Time, len(connected))
+	for id := range connected {
+		if _, ok := c.connectedSince[id]; !ok {
+			c.connectedSince[id] = now
+		}
+		since[id] = c.connectedSince[id]
+	}
+	return since
+}
+
+// reportingAgents returns the IDs of the agents that sent telemetry within
+// the no telemetry threshold
+func (c *FleetChecker) reportingAgents(ctx context.Context, now time.Time) (map[string]bool, error) {
+	usage, err := c.usage.GetTopUsage(ctx, services.UsageQuery{
+		GroupBy:   services.UsageGroupByAgent,
+		StartTime: now.Add(-c.cfg.NoTelemetryAfter),
+		EndTime:   now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agent usage: %w", err)
+	}
+
+	reporting := make(map[string]bool, len(usage))
+	for _, summary := range usage {
+		if summary.Records > 0 {
+			reporting[summary.Key] = true
+		}
+	}
+	return reporting, nil
+}
+
+// statusAlerts returns the alerts for a failed remote config and the
+// unhealthy components reported by a connected agent
+func (c *FleetChecker) statusAlerts(stored *services.Agent, agent *opamp.Agent) []SourceAlert {
+	if agent.Status == nil {
+		return nil
+	}
+	name := agentName(stored, agent)
+
+	var alerts []SourceAlert
+	if status := agent.Status.RemoteConfigStatus; status != nil &&
+		status.Status == protobufs.RemoteConfigStatuses_RemoteConfigStatuses_FAILED {
+		alerts = append(alerts, SourceAlert{
+			Labels: agentLabels(AlertAgentRemoteConfigFailed, "warning", stored, agent),
+			Annotations: map[string]string{
+				"summary":     fmt.Sprintf("Agent %s failed to apply its remote config", name),
+				"description": status.ErrorMessage,
+			},
+			Value: 1,
+		})
+	}
+
+	health := agent.Status.Health
+	if health == nil {
+		return alerts
+	}
+	unhealthy := make(map[string]string)
+	unhealthyComponents("", health.ComponentHealthMap, unhealthy)
+	if len(unhealthy) == 0 && !health.Healthy && health.LastError != "" {
+		unhealthy[""] = health.LastError
+	}
+	components := make([]string, 0, len(unhealthy))
+	for component := range unhealthy {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	for _, component := range components {
+		labels := agentLabels(AlertAgentComponentUnhealthy, "warning", stored, agent)
+		summary := fmt.Sprintf("Agent %s is unhealthy", name)
+		if component != "" {
+			labels["component"] = component
+			summary = fmt.Sprintf("Component %s of agent %s is unhealthy", component, name)
+		}
+		alerts = append(alerts, SourceAlert{
+			Labels: labels,
+			Annotations: map[string]string{
+				"summary":     summary,
+				"description": unhealthy[component],
+			},
+			Value: 1,
+		})
+	}
+	return alerts
+}
+
+// unhealthyComponents collects the unhealthy components without unhealthy
+// subcomponents, keyed by their path, with their last error
+func unhealthyComponents(prefix string, components map[string]*protobufs.ComponentHealth, unhealthy map[string]string) {
+	for name, component := range components {
+		if component == nil || component.Healthy {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "/" + name
+		}
+		before := len(unhealthy)
+		unhealthyComponents(path, component.ComponentHealthMap, unhealthy)
+		if len(unhealthy) == before {
+			unhealthy[path] = component.LastError
+		}
+	}
+}
+
+// driftAlert returns an alert when the effective config reported by a
+// connected agent differs from the config assigned to it or its group.
+// Agents without an assigned config or that do not report their effective
+// config are not checked.
+func (c *FleetChecker) driftAlert(ctx context.Context, stored *services.Agent, agent *opamp.Agent) (SourceAlert, bool) {
+	if agent.EffectiveConfig == "" {
+		return SourceAlert{}, false
+	}
+
+	assigned, err := c.store.GetLatestConfigForAgent(ctx, agent.InstanceId)
+	if err == nil && assigned == nil && agent.GroupID != nil && *agent.GroupID != "" {
+		assigned, err = c.store.GetLatestConfigForGroup(ctx, *agent.GroupID)
+	}
+	if err != nil {
+		c.logger.Warn("Failed to get assigned config for agent",
+			zap.String("agent_id", agent.InstanceIdStr),
+			zap.Error(err))
+		return SourceAlert{}, false
+	}
+	if assigned == nil || sameConfig(assigned.Content, agent.EffectiveConfig) {
+		return SourceAlert{}, false
+	}
+
+	return SourceAlert{
+		Labels: agentLabels(AlertAgentConfigDrift, "warning", stored, agent),
+		Annotations: map[string]string{
+			"summary":     fmt.Sprintf("Agent %s runs a config other than the one assigned to it", agentName(stored, agent)),
+			"description": fmt.Sprintf("The effective config differs from config %s version %d", assigned.ID, assigned.Version),
+		},
+		Value: 1,
+	}, true
+}
+
+// versionAlert returns an alert when a connected agent runs a version below
+// the minimum. Agents with an unknown version are not checked.
+func (c *FleetChecker) versionAlert(stored *services.Agent, agent *opamp.Agent) (SourceAlert, bool) {
+	if c.cfg.MinAgentVersion == "" || stored == nil {
+		return SourceAlert{}, false
+	}
+	order, ok := compareVersions(stored.Version, c.cfg.MinAgentVersion)
+	if !ok || order >= 0 {
+		return SourceAlert{}, false
+	}
+
+	labels := agentLabels(AlertAgentVersionOutdated, "warning", stored, agent)
+	labels["version"] = stored.Version
+	return SourceAlert{
+		Labels: labels,
+		Annotations: map[string]string{
+			"summary":     fmt.Sprintf("Agent %s runs an outdated version", agentName(stored, agent)),
+			"description": fmt.Sprintf("Version %s is below the minimum version %s", stored.Version, c.cfg.MinAgentVersion),
+		},
+		Value: 1,
+	}, true
+}
+
+// agentLabels returns the labels of a fleet health alert for an agent known
+// from storage, OpAMP or both
+func agentLabels(alertname, severity string, stored *services.Agent, agent *opamp.Agent) map[string]string {
+	labels := map[string]string{
+		"alertname": alertname,
+		"severity":  severity,
+	}
+	var groupID *string
+	if stored != nil {
+		labels["agent_id"] = stored.ID.String()
+		labels["agent_name"] = stored.Name
+		groupID = stored.GroupID
+	}
+	if agent != nil {
+		labels["agent_id"] = agent.InstanceIdStr
+		if agent.GroupID != nil {
+			groupID = agent.GroupID
+		}
+	}
+	if groupID != nil && *groupID != "" {
+		labels["group_id"] = *groupID
+	}
+	if labels["agent_name"] == "" {
+		delete(labels, "agent_name")
+	}
+	return labels
+}
+
+// agentName returns the name of an agent, or its ID if it has no name
+func agentName(stored *services.Agent, agent *opamp.Agent) string {
+	if stored != nil && stored.Name != "" {
+		return stored.Name
+	}
+	if agent != nil {
+		return agent.InstanceIdStr
+	}
+	return stored.ID.String()
+}
+
+// sameConfig reports whether two collector configs are equal, comparing
+// their YAML structure when both parse and their text otherwise
+func sameConfig(a, b string) bool {
+	var parsedA, parsedB interface{}
+	if yaml.Unmarshal([]byte(a), &parsedA) == nil && yaml.Unmarshal([]byte(b), &parsedB) == nil {
+		return reflect.DeepEqual(parsedA, parsedB)
+	}
+	return strings.TrimSpace(a) == strings.TrimSpace(b)
+}
+
+// compareVersions compares two dotted numeric versions like "v0.96.1",
+// ignoring pre-release and build suffixes. It returns false when either
+// version does not parse.
+func compareVersions(a, b string) (int, bool) {
+	partsA, ok := parseVersion(a)
+	if !ok {
+		return 0, false
+	}
+	partsB, ok := parseVersion(b)
+	if !ok {
+		return 0, false
+	}
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var x, y int
+		if i < len(partsA) {
+			x = partsA[i]
+		}
+		if i < len(partsB) {
+			y = partsB[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+// parseVersion splits a version into its numeric parts
+func parseVersion(version string) ([]int, bool) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	if version == "" {
+		return nil, false
+	}
+
+	fields := strings.Split(version, ".")
+	parts := make([]int, len(fields))
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package alerting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/open-telemetry/opamp-go/protobufs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/opamp"
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// stubConnectedAgents returns fixed connected agents
+type stubConnectedAgents map[uuid.UUID]*opamp.Agent
+
+func (a stubConnectedAgents) GetAllAgentsReadonlyClone() map[uuid.UUID]*opamp.Agent {
+	return a
+}
+
+// stubAgentStore returns fixed agents and configs
+type stubAgentStore struct {
+	agents       []*services.Agent
+	agentConfigs map[uuid.UUID]*services.Config
+	groupConfigs map[string]*services.Config
+}
+
+func (s *stubAgentStore) ListAgents(ctx context.Context) ([]*services.Agent, error) {
+	return s.agents, nil
+}
+
+func (s *stubAgentStore) GetLatestConfigForAgent(ctx context.Context, agentID uuid.UUID) (*services.Config, error) {
+	return s.agentConfigs[agentID], nil
+}
+
+func (s *stubAgentStore) GetLatestConfigForGroup(ctx context.Context, groupID string) (*services.Config, error) {
+	return s.groupConfigs[groupID], nil
+}
+
+// stubUsageReader returns fixed usage
+type stubUsageReader struct {
+	usage []services.UsageSummary
+	query services.UsageQuery
+}
+
+func (u *stubUsageReader) GetTopUsage(ctx context.Context, query services.UsageQuery) ([]services.UsageSummary, error) {
+	u.query = query
+	return u.usage, nil
+}
+
+// alertsByName indexes source alerts by alert name and agent
+func alertsByName(alerts []SourceAlert) map[string]SourceAlert {
+	indexed := make(map[string]SourceAlert, len(alerts))
+	for _, alert := range alerts {
+		key := alert.Labels["alertname"] + "/" + alert.Labels["agent_name"]
+		if component := alert.Labels["component"]; component != "" {
+			key += "/" + component
+		}
+		indexed[key] = alert
+	}
+	return indexed
+}
+
+func TestFleetChecker_Check(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	group := "edge"
+	offlineID, disconnectedID, brokenID, healthyID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	store := &stubAgentStore{
+		agents: []*services.Agent{
+			{ID: offlineID, Name: "offline", LastSeen: now.Add(-10 * time.Minute), Version: "0.90.0"},
+			{ID: disconnectedID, Name: "disconnected", LastSeen: now.Add(-2 * time.Minute)},
+			{ID: brokenID, Name: "broken", LastSeen: now, Version: "0.90.0", GroupID: &group},
+			{ID: healthyID, Name: "healthy", LastSeen: now, Version: "v0.96.1", GroupID: &group},
+		},
+		agentConfigs: map[uuid.UUID]*services.Config{
+			brokenID: {ID: "config-1", Version: 2, Content: "receivers:\n  otlp: {}\n"},
+		},
+		groupConfigs: map[string]*services.Config{
+			group: {ID: "config-2", Version: 1, Content: "exporters:\n  debug: {}\nreceivers:\n  otlp: {}\n"},
+		},
+	}
+	connected := stubConnectedAgents{
+		brokenID: {
+			InstanceId:      brokenID,
+			InstanceIdStr:   brokenID.String(),
+			GroupID:         &group,
+			EffectiveConfig: "receivers:\n  hostmetrics: {}\n",
+			Status: &protobufs.AgentToServer{
+				RemoteConfigStatus: &protobufs.RemoteConfigStatus{
+					Status:       protobufs.RemoteConfigStatuses_RemoteConfigStatuses_FAILED,
+					ErrorMessage: "unknown receiver",
+				},
+				Health: &protobufs.ComponentHealth{
+					ComponentHealthMap: map[string]*protobufs.ComponentHealth{
+						"pipeline:traces": {
+							ComponentHealthMap: map[string]*protobufs.ComponentHealth{
+								"exporter:otlp":  {LastError: "connection refused"},
+								"receiver:otlp":  {Healthy: true},
+								"processor:noop": {Healthy: true},
+							},
+						},
+						"pipeline:metrics": {Healthy: true},
+					},
+				},
+			},
+		},
+		healthyID: {
+			InstanceId:    healthyID,
+			InstanceIdStr: healthyID.String(),
+			GroupID:       &group,
+			// Formatting differences are not drift
+			EffectiveConfig: "receivers: {otlp: {}}\nexporters: {debug: {}}\n",
+			Status: &protobufs.AgentToServer{
+				Health: &protobufs.ComponentHealth{Healthy: true},
+			},
+		},
+	}
+	usage := &stubUsageReader{usage: []services.UsageSummary{{Key: healthyID.String(), Records: 10}}}
+
+	checker := NewFleetChecker(FleetConfig{
+		OfflineAfter:     5 * time.Minute,
+		NoTelemetryAfter: 10 * time.Minute,
+		MinAgentVersion:  "0.96.0",
+	}, connected, store, usage, zap.NewNop())
+
+	alerts, err := checker.Check(context.Background(), now)
+	require.NoError(t, err)
+	indexed := alertsByName(alerts)
+	assert.Len(t, indexed, 5)
+
+	offline, ok := indexed["AgentOffline/offline"]
+	require.True(t, ok)
+	assert.Equal(t, "critical", offline.Labels["severity"])
+	assert.Equal(t, offlineID.String(), offline.Labels["agent_id"])
+	assert.Equal(t, (10 * time.Minute).Seconds(), offline.Value)
+
+	failed, ok := indexed["AgentRemoteConfigFailed/broken"]
+	require.True(t, ok)
+	assert.Equal(t, "unknown receiver", failed.Annotations["description"])
+	assert.Equal(t, group, failed.Labels["group_id"])
+
+	unhealthy, ok := indexed["AgentComponentUnhealthy/broken/pipeline:traces/exporter:otlp"]
+	require.True(t, ok)
+	assert.Equal(t, "connection refused", unhealthy.Annotations["description"])
+
+	// The agent's own config takes precedence over its group's
+	drift, ok := indexed["AgentConfigDrift/broken"]
+	require.True(t, ok)
+	assert.Contains(t, drift.Annotations["description"], "config-1 version 2")
+
+	outdated, ok := indexed["AgentVersionOutdated/broken"]
+	require.True(t, ok)
+	assert.Equal(t, "0.90.0", outdated.Labels["version"])
+
+	// Agents connected without telemetry alert once the threshold passed
+	alerts, err = checker.Check(context.Background(), now.Add(10*time.Minute))
+	require.NoError(t, err)
+	indexed = alertsByName(alerts)
+	assert.Contains(t, indexed, "AgentNoTelemetry/broken")
+	assert.NotContains(t, indexed, "AgentNoTelemetry/healthy")
+	assert.Equal(t, services.UsageGroupByAgent, usage.query.GroupBy)
+	assert.Equal(t, now, usage.query.StartTime)
+}
+
+func TestFleetChecker_DisabledChecks(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	id := uuid.New()
+	store := &stubAgentStore{agents: []*services.Agent{{ID: id, Name: "offline", LastSeen: now.Add(-time.Hour), Version: "0.1.0"}}}
+
+	checker := NewFleetChecker(FleetConfig{}, stubConnectedAgents{}, store, &stubUsageReader{}, zap.NewNop())
+	alerts, err := checker.Check(context.Background(), now)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		order int
+		ok    bool
+	}{
+		{"0.96.0", "0.96.0", 0, true},
+		{"v0.96.1", "0.96.0", 1, true},
+		{"0.9.0", "0.96.0", -1, true},
+		{"1.0", "1.0.0", 0, true},
+		{"0.96.0-rc.1", "0.96.0", 0, true},
+		{"unknown", "0.96.0", 0, false},
+		{"0.96.0", "", 0, false},
+	}
+	for _, tt := range tests {
+		order, ok := compareVersions(tt.a, tt.b)
+		assert.Equal(t, tt.ok, ok, "%s vs %s", tt.a, tt.b)
+		assert.Equal(t, tt.order, order, "%s vs %s", tt.a, tt.b)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// Notification is a group of alerts delivered to a receiver at once. It
+// holds the firing alerts of the group and the alerts resolved since the
+// previous notification.
+type Notification struct {
+	Receiver    string
+	GroupKey    string
+	GroupLabels map[string]string
+	Alerts      []*services.Alert
+	Timestamp   time.Time
+}
+
+// Status returns firing if any alert of the notification fires, otherwise resolved
+func (n *Notification) Status() services.AlertState {
+	for _, alert := range n.Alerts {
+		if alert.State == services.AlertStateFiring {
+			return services.AlertStateFiring
+		}
+	}
+	return services.AlertStateResolved
+}
+
+// Notifier delivers notifications to a single destination
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// retryableError marks delivery failures worth retrying, such as connection
+// errors and 5xx or 429 responses
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// WebhookNotifier posts notifications as JSON in the format of the
+// Alertmanager webhook receiver, so existing webhook integrations work
+// unchanged
+type WebhookNotifier struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to a generic webhook
+func NewWebhookNotifier(url string, headers map[string]string, client *http.Client) *WebhookNotifier {
+	return &WebhookNotifier{url: url, headers: headers, client: client}
+}
+
+// webhookMessage is the payload of a webhook notification
+type webhookMessage struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	Alerts            []webhookAlert    `json:"alerts"`
+}
+
+// webhookAlert is a single alert of a webhook notification
+type webhookAlert struct {
+	Status      string            `json:"status"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+	Fingerprint string            `json:"fingerprint"`
+}
+
+// Notify posts the notification to the webhook
+func (w *WebhookNotifier) Notify(ctx context.Context, n *Notification) error {
+	message := webhookMessage{
+		Version:           "4",
+		GroupKey:          n.GroupKey,
+		Status:            string(n.Status()),
+		Receiver:          n.Receiver,
+		GroupLabels:       n.GroupLabels,
+		CommonLabels:      commonValues(n.Alerts, func(a *services.Alert) map[string]string { return a.Labels }),
+		CommonAnnotations: commonValues(n.Alerts, func(a *services.Alert) map[string]string { return a.Annotations }),
+		Alerts:            make([]webhookAlert, 0, len(n.Alerts)),
+	}
+	for _, alert := range n.Alerts {
+		item := webhookAlert{
+			Status:      string(alert.State),
+			Labels:      alert.Labels,
+			Annotations: alert.Annotations,
+			StartsAt:    alert.ActiveAt,
+			Fingerprint: alert.Fingerprint,
+		}
+		if alert.ResolvedAt != nil {
+			item.EndsAt = *alert.ResolvedAt
+		}
+		message.Alerts = append(message.Alerts, item)
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook notification: %w", err)
+	}
+	return postJSON(ctx, w.client, w.url, w.headers, body)
+}
+
+// AlertmanagerNotifier posts alerts to the v2 API of an Alertmanager or a
+// compatible endpoint, which applies its own routing and deduplication
+type AlertmanagerNotifier struct {
+	url      string
+	headers  map[string]string
+	client   *http.Client
+	validFor time.Duration
+}
+
+// NewAlertmanagerNotifier creates a notifier posting to an Alertmanager at
+// the given base URL. Firing alerts are sent with an end validFor after the
+// notification, so Alertmanager resolves them if Lawrence stops repeating
+// them; validFor should be a multiple of the repeat interval.
+func NewAlertmanagerNotifier(url string, headers map[string]string, client *http.Client, validFor time.Duration) *AlertmanagerNotifier {
+	url = strings.TrimSuffix(url, "/")
+	if !strings.HasSuffix(url, "/api/v2/alerts") {
+		url += "/api/v2/alerts"
+	}
+	return &AlertmanagerNotifier{url: url, headers: headers, client: client, validFor: validFor}
+}
+
+// postableAlert is an alert in the format of the Alertmanager v2 API
+type postableAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+// Notify posts the alerts of the notification to Alertmanager
+func (a *AlertmanagerNotifier) Notify(ctx context.Context, n *Notification) error {
+	alerts := make([]postableAlert, 0, len(n.Alerts))
+	for _, alert := range n.Alerts {
+		item := postableAlert{
+			Labels:      alert.Labels,
+			Annotations: alert.Annotations,
+			StartsAt:    alert.ActiveAt,
+			EndsAt:      n.Timestamp.Add(a.validFor),
+		}
+		if alert.ResolvedAt != nil {
+			item.EndsAt = *alert.ResolvedAt
+		}
+		alerts = append(alerts, item)
+	}
+
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alertmanager alerts: %w", err)
+	}
+	return postJSON(ctx, a.client, a.url, a.headers, body)
+}
+
+// postJSON posts a JSON body, marking connection errors and 5xx or 429
+// responses as retryable
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Lawrence")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return &retryableError{err: fmt.Errorf("failed to send request: %w", err)}
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("receiver returned status %s", resp.Status)
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return &retryableError{err: err}
+	}
+	return err
+}
+
+// commonValues returns the key-value pairs shared by every alert
+func commonValues(alerts []*services.Alert, values func(*services.Alert) map[string]string) map[string]string {
+	if len(alerts) == 0 {
+		return map[string]string{}
+	}
+	common := maps.Clone(values(alerts[0]))
+	if common == nil {
+		common = map[string]string{}
+	}
+	for _, alert := range alerts[1:] {
+		other := values(alert)
+		for key, value := range common {
+			if other[key] != value {
+				delete(common, key)
+			}
+		}
+	}
+	return common
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+func testNotification(now time.Time) *Notification {
+	resolvedAt := now
+	return &Notification{
+		Receiver:    "oncall",
+		GroupKey:    `{alertname="HighErrorRate"}`,
+		GroupLabels: map[string]string{"alertname": "HighErrorRate"},
+		Timestamp:   now,
+		Alerts: []*services.Alert{
+			{
+				Fingerprint: "a",
+				Labels:      map[string]string{"alertname": "HighErrorRate", "service": "api"},
+				Annotations: map[string]string{"summary": "api is failing"},
+				State:       services.AlertStateFiring,
+				ActiveAt:    now.Add(-10 * time.Minute),
+			},
+			{
+				Fingerprint: "b",
+				Labels:      map[string]string{"alertname": "HighErrorRate", "service": "web"},
+				State:       services.AlertStateResolved,
+				ActiveAt:    now.Add(-20 * time.Minute),
+				ResolvedAt:  &resolvedAt,
+			},
+		},
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	var message webhookMessage
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL, map[string]string{"Authorization": "Bearer token"}, server.Client())
+	require.NoError(t, notifier.Notify(context.Background(), testNotification(now)))
+
+	assert.Equal(t, "Bearer token", authorization)
+	assert.Equal(t, "4", message.Version)
+	assert.Equal(t, "firing", message.Status)
+	assert.Equal(t, "oncall", message.Receiver)
+	assert.Equal(t, map[string]string{"alertname": "HighErrorRate"}, message.CommonLabels)
+	require.Len(t, message.Alerts, 2)
+	assert.Equal(t, "resolved", message.Alerts[1].Status)
+	assert.True(t, message.Alerts[1].EndsAt.Equal(now))
+}
+
+func TestAlertmanagerNotifier(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	var path string
+	var alerts []postableAlert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&alerts))
+	}))
+	defer server.Close()
+
+	notifier := NewAlertmanagerNotifier(server.URL+"/", nil, server.Client(), 3*time.Hour)
+	require.NoError(t, notifier.Notify(context.Background(), testNotification(now)))
+
+	assert.Equal(t, "/api/v2/alerts", path)
+	require.Len(t, alerts, 2)
+	// Firing alerts stay valid for a while, resolved alerts end when they resolved
+	assert.True(t, alerts[0].EndsAt.Equal(now.Add(3*time.Hour)))
+	assert.True(t, alerts[1].EndsAt.Equal(now))
+}
+
+func TestPostJSON_RetryableErrors(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	var retryable *retryableError
+	err := postJSON(context.Background(), server.Client(), server.URL, nil, []byte("{}"))
+	assert.True(t, errors.As(err, &retryable))
+
+	status = http.StatusTooManyRequests
+	err = postJSON(context.Background(), server.Client(), server.URL, nil, []byte("{}"))
+	assert.True(t, errors.As(err, &retryable))
+
+	status = http.StatusBadRequest
+	err = postJSON(context.Background(), server.Client(), server.URL, nil, []byte("{}"))
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &retryable))
+}
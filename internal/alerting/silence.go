@@ -0,0 +1,84 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package alerting
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// silence is a silence with its regular expressions compiled
+type silence struct {
+	id       string
+	startsAt time.Time
+	endsAt   time.Time
+	matchers []matcher
+}
+
+// matcher matches a single label; a missing label matches as empty
+type matcher struct {
+	name     string
+	operator string
+	value    string
+	re       *regexp.Regexp
+}
+
+// compileSilences compiles the matchers of each silence, skipping those
+// that have already ended
+func compileSilences(silences []*services.Silence, now time.Time) ([]*silence, error) {
+	compiled := make([]*silence, 0, len(silences))
+	for _, s := range silences {
+		if !now.Before(s.EndsAt) {
+			continue
+		}
+		c := &silence{id: s.ID, startsAt: s.StartsAt, endsAt: s.EndsAt}
+		for _, m := range s.Matchers {
+			cm := matcher{name: m.Name, operator: m.Operator, value: m.Value}
+			switch m.Operator {
+			case "=", "!=":
+			case "=~", "!~":
+				re, err := regexp.Compile("^(?:" + m.Value + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("silence %s: invalid matcher regex %q: %w", s.ID, m.Value, err)
+				}
+				cm.re = re
+			default:
+				return nil, fmt.Errorf("silence %s: invalid matcher operator %q", s.ID, m.Operator)
+			}
+			c.matchers = append(c.matchers, cm)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// mutes reports whether the silence is active at the given time and matches the labels
+func (s *silence) mutes(labels map[string]string, at time.Time) bool {
+	if at.Before(s.startsAt) || !at.Before(s.endsAt) {
+		return false
+	}
+	for _, m := range s.matchers {
+		if !m.matches(labels[m.name]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *matcher) matches(value string) bool {
+	switch m.operator {
+	case "=":
+		return value == m.value
+	case "!=":
+		return value != m.value
+	case "=~":
+		return m.re.MatchString(value)
+	case "!~":
+		return !m.re.MatchString(value)
+	}
+	return false
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/query"
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// defaultAlertInterval is used when an alert rule has no interval
+const defaultAlertInterval = "1m"
+
+// AlertHandlers handles alert rule, silence and alert API endpoints
+type AlertHandlers struct {
+	alertService services.AlertService
+	logger       *zap.Logger
+}
+
+// NewAlertHandlers creates a new alert handlers instance
+func NewAlertHandlers(alertService services.AlertService, logger *zap.Logger) *AlertHandlers {
+	return &AlertHandlers{
+		alertService: alertService,
+		logger:       logger,
+	}
+}
+
+// AlertRuleRequest represents the request to create or update an alert rule
+type AlertRuleRequest struct {
+	Name        string            `json:"name" binding:"required"`
+	Description string            `json:"description"`
+	Expr        string            `json:"expr" binding:"required"`
+	Operator    string            `json:"operator" binding:"required"`
+	Threshold   *float64          `json:"threshold" binding:"required"`
+	For         string            `json:"for"`
+	Interval    string            `json:"interval"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	Enabled     *bool             `json:"enabled"`
+}
+
+// toRule converts the request to a service rule, enabling it and
+// evaluating it every minute by default
+func (r *AlertRuleRequest) toRule(id string) *services.AlertRule {
+	enabled := true
+	if r.Enabled != nil {
+		enabled = *r.Enabled
+	}
+	interval := r.Interval
+	if interval == "" {
+		interval = defaultAlertInterval
+	}
+	return &services.AlertRule{
+		ID:          id,
+		Name:        r.Name,
+		Description: r.Description,
+		Expr:        r.Expr,
+		Operator:    r.Operator,
+		Threshold:   *r.Threshold,
+		For:         r.For,
+		Interval:    interval,
+		Labels:      r.Labels,
+		Annotations: r.Annotations,
+		Enabled:     enabled,
+	}
+}
+
+// SilenceRequest represents the request to create a silence. The silence
+// starts now unless starts_at is given and ends at ends_at or after duration.
+type SilenceRequest struct {
+	Matchers  []services.SilenceMatcher `json:"matchers" binding:"required"`
+	StartsAt  *time.Time                `json:"starts_at"`
+	EndsAt    *time.Time                `json:"ends_at"`
+	Duration  string                    `json:"duration"` // Duration string like "2h"
+	CreatedBy string                    `json:"created_by"`
+	Comment   string                    `json:"comment"`
+}
+
+// toSilence converts the request to a service silence
+func (r *SilenceRequest) toSilence(now time.Time) (*services.Silence, error) {
+	silence := &services.Silence{
+		Matchers:  r.Matchers,
+		StartsAt:  now,
+		CreatedBy: r.CreatedBy,
+		Comment:   r.Comment,
+	}
+	if r.StartsAt != nil {
+		silence.StartsAt = *r.StartsAt
+	}
+
+	switch {
+	case r.EndsAt != nil && r.Duration != "":
+		return nil, fmt.Errorf("set either ends_at or duration, not both")
+	case r.EndsAt != nil:
+		silence.EndsAt = *r.EndsAt
+	case r.Duration != "":
+		duration, err := time.ParseDuration(r.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q: %w", r.Duration, err)
+		}
+		silence.EndsAt = silence.StartsAt.Add(duration)
+	default:
+		return nil, fmt.Errorf("ends_at or duration is required")
+	}
+
+	if err := services.ValidateSilence(silence); err != nil {
+		return nil, err
+	}
+	if !silence.EndsAt.After(now) {
+		return nil, fmt.Errorf("silence end %s is in the past", silence.EndsAt.Format(time.RFC3339))
+	}
+	return silence, nil
+}
+
+// validateAlertRule checks a rule and parses its expression
+func validateAlertRule(rule *services.AlertRule) error {
+	if err := services.ValidateAlertRule(rule); err != nil {
+		return err
+	}
+	if _, err := query.NewParser(rule.Expr).Parse(); err != nil {
+		return fmt.Errorf("invalid expression: %w", err)
+	}
+	return nil
+}
+
+// HandleListAlerts handles GET /api/v1/alerts
+func (h *AlertHandlers) HandleListAlerts(c *gin.Context) {
+	alerts, err := h.alertService.ListAlerts(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to list alerts", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"alerts": alerts,
+		"count":  len(alerts),
+	})
+}
+
+// HandleListAlertRules handles GET /api/v1/alerts/rules
+func (h *AlertHandlers) HandleListAlertRules(c *gin.Context) {
+	rules, err := h.alertService.ListAlertRules(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to list alert rules", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alert rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// HandleGetAlertRule handles GET /api/v1/alerts/rules/:id
+func (h *AlertHandlers) HandleGetAlertRule(c *gin.Context) {
+	ruleID := c.Param("id")
+
+	rule, err := h.alertService.GetAlertRule(c.Request.Context(), ruleID)
+	if err != nil {
+		h.logger.Error("Failed to get alert rule", zap.String("rule_id", ruleID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alert rule"})
+		return
+	}
+
+	if rule == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// HandleCreateAlertRule handles POST /api/v1/alerts/rules
+func (h *AlertHandlers) HandleCreateAlertRule(c *gin.Context) {
+	var req AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	rule := req.toRule("")
+	if err := validateAlertRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert rule", "details": err.Error()})
+		return
+	}
+
+	created, err := h.alertService.CreateAlertRule(c.Request.Context(), rule)
+	if err != nil {
+		h.logger.Error("Failed to create alert rule", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert rule", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// HandleUpdateAlertRule handles PUT /api/v1/alerts/rules/:id
+func (h *AlertHandlers) HandleUpdateAlertRule(c *gin.Context) {
+	ruleID := c.Param("id")
+
+	var req AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	rule := req.toRule(ruleID)
+	if err := validateAlertRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert rule", "details": err.Error()})
+		return
+	}
+
+	updated, err := h.alertService.UpdateAlertRule(c.Request.Context(), rule)
+	if err != nil {
+		if err.Error() == "alert rule not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+			return
+		}
+		h.logger.Error("Failed to update alert rule", zap.String("rule_id", ruleID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert rule", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// HandleDeleteAlertRule handles DELETE /api/v1/alerts/rules/:id
+func (h *AlertHandlers) HandleDeleteAlertRule(c *gin.Context) {
+	ruleID := c.Param("id")
+
+	if err := h.alertService.DeleteAlertRule(c.Request.Context(), ruleID); err != nil {
+		if err.Error() == "alert rule not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+			return
+		}
+		h.logger.Error("Failed to delete alert rule", zap.String("rule_id", ruleID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rule deleted successfully"})
+}
+
+// HandleListSilences handles GET /api/v1/alerts/silences
+func (h *AlertHandlers) HandleListSilences(c *gin.Context) {
+	silences, err := h.alertService.ListSilences(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to list silences", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch silences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"silences": silences,
+		"count":    len(silences),
+	})
+}
+
+// HandleCreateSilence handles POST /api/v1/alerts/silences
+func (h *AlertHandlers) HandleCreateSilence(c *gin.Context) {
+	var req SilenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	silence, err := req.toSilence(time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid silence", "details": err.Error()})
+		return
+	}
+
+	created, err := h.alertService.CreateSilence(c.Request.Context(), silence)
+	if err != nil {
+		h.logger.Error("Failed to create silence", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create silence", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// HandleDeleteSilence handles DELETE /api/v1/alerts/silences/:id
+func (h *AlertHandlers) HandleDeleteSilence(c *gin.Context) {
+	silenceID := c.Param("id")
+
+	if err := h.alertService.DeleteSilence(c.Request.Context(), silenceID); err != nil {
+		if err.Error() == "silence not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Silence not found"})
+			return
+		}
+		h.logger.Error("Failed to delete silence", zap.String("silence_id", silenceID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete silence"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Silence deleted successfully"})
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+	"github.com/getlawrence/lawrence-oss/internal/storage/applicationstore/memory"
+)
+
+func setupAlertHandlersTest() *AlertHandlers {
+	alertService := services.NewAlertService(memory.NewStore(), nil, zap.NewNop())
+	return NewAlertHandlers(alertService, zap.NewNop())
+}
+
+func TestHandleCreateAlertRule_Success(t *testing.T) {
+	handlers := setupAlertHandlersTest()
+
+	threshold := 0.0
+	body, _ := json.Marshal(AlertRuleRequest{
+		Name:      "AgentsMissing",
+		Expr:      `count(metrics{metric="up"})`,
+		Operator:  "==",
+		Threshold: &threshold,
+		For:       "5m",
+	})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/alerts/rules", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handlers.HandleCreateAlertRule(c)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created services.AlertRule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.ID)
+	assert.True(t, created.Enabled, "rules are enabled by default")
+	assert.Equal(t, "1m", created.Interval)
+}
+
+func TestHandleCreateAlertRule_Invalid(t *testing.T) {
+	handlers := setupAlertHandlersTest()
+
+	threshold := 1.0
+	body, _ := json.Marshal(AlertRuleRequest{
+		Name:      "HighErrorRate",
+		Expr:      `sum(metrics{metric="errors"}`,
+		Operator:  ">",
+		Threshold: &threshold,
+	})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/alerts/rules", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handlers.HandleCreateAlertRule(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid expression")
+}
+
+func TestHandleCreateSilence(t *testing.T) {
+	handlers := setupAlertHandlersTest()
+
+	body, _ := json.Marshal(SilenceRequest{
+		Matchers: []services.SilenceMatcher{{Name: "service", Operator: "=", Value: "api"}},
+		Duration: "2h",
+		Comment:  "Deploy",
+	})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/alerts/silences", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handlers.HandleCreateSilence(c)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created services.Silence
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.True(t, created.EndsAt.Equal(created.StartsAt.Add(2*time.Hour)))
+
+	// A silence needs an end
+	body, _ = json.Marshal(SilenceRequest{
+		Matchers: []services.SilenceMatcher{{Name: "service", Operator: "=", Value: "api"}},
+	})
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/alerts/silences", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handlers.HandleCreateSilence(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "ends_at or duration is required")
+}
+
+func TestHandleDeleteSilence_NotFound(t *testing.T) {
+	handlers := setupAlertHandlersTest()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("DELETE", "/api/v1/alerts/silences/missing", nil)
+	c.Params = gin.Params{{Key: "id", Value: "missing"}}
+
+	handlers.HandleDeleteSilence(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
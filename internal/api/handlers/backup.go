@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/backup"
+)
+
+// BackupHandlers handles backup API endpoints
+type BackupHandlers struct {
+	stores  []backup.Store
+	version string
+	logger  *zap.Logger
+}
+
+// NewBackupHandlers creates a new backup handlers instance
+func NewBackupHandlers(stores []backup.Store, version string, logger *zap.Logger) *BackupHandlers {
+	return &BackupHandlers{
+		stores:  stores,
+		version: version,
+		logger:  logger,
+	}
+}
+
+// HandleCreateBackup handles POST /api/v1/backup
+func (h *BackupHandlers) HandleCreateBackup(c *gin.Context) {
+	filename := fmt.Sprintf("lawrence-backup-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	w := &attachmentWriter{c: c, contentType: "application/gzip", filename: filename}
+
+	// Every store is snapshotted before the archive is written, so a failed
+	// snapshot is still reported as JSON
+	manifest, err := backup.Create(c.Request.Context(), w, h.stores, h.version)
+	if err != nil {
+		h.logger.Error("Failed to create backup", zap.Error(err))
+		if !w.started {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create backup", "details": err.Error()})
+		}
+		return
+	}
+
+	w.start()
+	h.logger.Info("Created backup", zap.Int("stores", len(manifest.Stores)))
+}
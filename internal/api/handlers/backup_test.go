@@ -0,0 +1,88 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/backup"
+)
+
+// stubBackupper writes a single file or fails
+type stubBackupper struct {
+	err      error
+	restored bool
+}
+
+func (s *stubBackupper) SchemaVersion() int { return 1 }
+
+func (s *stubBackupper) Backup(ctx context.Context, dir string) error {
+	if s.err != nil {
+		return s.err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "data"), []byte("data"), 0o644)
+}
+
+func (s *stubBackupper) PrepareRestore(ctx context.Context, dir string) error { return nil }
+
+func (s *stubBackupper) CommitRestore() error {
+	s.restored = true
+	return nil
+}
+
+func (s *stubBackupper) DiscardRestore() error { return nil }
+
+func performBackup(handlers *BackupHandlers) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/backup", nil)
+	handlers.HandleCreateBackup(c)
+	return w
+}
+
+func TestHandleCreateBackup_StreamsArchive(t *testing.T) {
+	handlers := NewBackupHandlers([]backup.Store{
+		{Name: "application", Type: "sqlite", Backupper: &stubBackupper{}},
+	}, "1.2.3", zap.NewNop())
+
+	w := performBackup(handlers)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/gzip", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `attachment; filename="lawrence-backup-`)
+
+	store := &stubBackupper{}
+	manifest, err := backup.Restore(context.Background(), bytes.NewReader(w.Body.Bytes()),
+		[]backup.Store{{Name: "application", Type: "sqlite", Backupper: store}})
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3", manifest.LawrenceVersion)
+	assert.True(t, store.restored)
+}
+
+func TestHandleCreateBackup_StoreFailure(t *testing.T) {
+	handlers := NewBackupHandlers([]backup.Store{
+		{Name: "telemetry", Type: "duckdb", Backupper: &stubBackupper{err: errors.New("disk full")}},
+	}, "1.2.3", zap.NewNop())
+
+	w := performBackup(handlers)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// ExportRequest represents the request for exporting raw telemetry
+type ExportRequest struct {
+	Signal      string    `json:"signal" binding:"required,oneof=metrics logs traces"`
+	Format      string    `json:"format" binding:"omitempty,oneof=parquet ndjson csv"`
+	AgentID     *string   `json:"agent_id" binding:"omitempty,uuid"`
+	GroupID     *string   `json:"group_id" binding:"omitempty,uuid"`
+	ServiceName *string   `json:"service_name"`
+	StartTime   time.Time `json:"start_time" binding:"required"`
+	EndTime     time.Time `json:"end_time" binding:"required"`
+}
+
+// exportContentTypes maps export formats to response content types
+var exportContentTypes = map[services.ExportFormat]string{
+	services.ExportFormatParquet: "application/vnd.apache.parquet",
+	services.ExportFormatNDJSON:  "application/x-ndjson",
+	services.ExportFormatCSV:     "text/csv",
+}
+
+// HandleExport handles POST /api/v1/telemetry/export
+func (h *TelemetryHandlers) HandleExport(c *gin.Context) {
+	var req ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	if req.EndTime.Before(req.StartTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_time must not be before start_time"})
+		return
+	}
+
+	format := services.ExportFormat(req.Format)
+	if format == "" {
+		format = services.ExportFormatParquet
+	}
+
+	// Convert agent ID from string to UUID
+	var agentID *uuid.UUID
+	if req.AgentID != nil {
+		parsedID, err := uuid.Parse(*req.AgentID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agent ID format"})
+			return
+		}
+		agentID = &parsedID
+	}
+
+	query := services.ExportQuery{
+		Signal:      services.ExportSignal(req.Signal),
+		Format:      format,
+		AgentID:     agentID,
+		GroupID:     req.GroupID,
+		ServiceName: req.ServiceName,
+		StartTime:   req.StartTime,
+		EndTime:     req.EndTime,
+	}
+
+	filename := fmt.Sprintf("%s-%s-%s.%s", req.Signal,
+		req.StartTime.UTC().Format("20060102T150405Z"),
+		req.EndTime.UTC().Format("20060102T150405Z"),
+		format)
+	w := &attachmentWriter{c: c, contentType: exportContentTypes[format], filename: filename}
+
+	rows, err := h.telemetryService.Export(c.Request.Context(), query, w)
+	if err != nil {
+		h.logger.Error("Failed to export telemetry", zap.Error(err), zap.Int64("rows", rows))
+		// Once streaming has started the status is already sent, so the
+		// client sees a truncated body instead
+		if !w.started {
+			c.JSON(queryErrorStatus(err), gin.H{"error": "Failed to export telemetry", "details": err.Error()})
+		}
+		return
+	}
+
+	w.start()
+	h.logger.Info("Exported telemetry",
+		zap.String("signal", req.Signal),
+		zap.String("format", string(format)),
+		zap.Int64("rows", rows))
+}
+
+// attachmentWriter streams a file download, sending the headers with the
+// first write so errors before any data can still be reported as JSON
+type attachmentWriter struct {
+	c           *gin.Context
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (w *attachmentWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	w.c.Header("Content-Type", w.contentType)
+	w.c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", w.filename))
+	w.c.Status(http.StatusOK)
+	w.c.Writer.WriteHeaderNow()
+}
+
+func (w *attachmentWriter) Write(p []byte) (int, error) {
+	w.start()
+	return w.c.Writer.Write(p)
+}
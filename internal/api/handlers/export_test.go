@@ -0,0 +1,100 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// exportTelemetryService writes a fixed body or fails before writing
+type exportTelemetryService struct {
+	services.TelemetryQueryService
+	queries []services.ExportQuery
+	err     error
+}
+
+func (s *exportTelemetryService) Export(ctx context.Context, query services.ExportQuery, w io.Writer) (int64, error) {
+	s.queries = append(s.queries, query)
+	if s.err != nil {
+		return 0, s.err
+	}
+	_, err := io.WriteString(w, "{\"body\":\"a\"}\n{\"body\":\"b\"}\n")
+	return 2, err
+}
+
+func performExport(handlers *TelemetryHandlers, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/telemetry/export", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handlers.HandleExport(c)
+	return w
+}
+
+func TestHandleExport_StreamsAttachment(t *testing.T) {
+	service := &exportTelemetryService{}
+	handlers := NewTelemetryHandlers(service, zap.NewNop())
+
+	w := performExport(handlers, `{"signal": "logs", "format": "ndjson", "service_name": "api",
+		"start_time": "2024-01-01T00:00:00Z", "end_time": "2024-01-02T00:00:00Z"}`)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="logs-20240101T000000Z-20240102T000000Z.ndjson"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "{\"body\":\"a\"}\n{\"body\":\"b\"}\n", w.Body.String())
+
+	require.Len(t, service.queries, 1)
+	assert.Equal(t, services.ExportSignalLogs, service.queries[0].Signal)
+	assert.Equal(t, "api", *service.queries[0].ServiceName)
+}
+
+func TestHandleExport_DefaultsToParquet(t *testing.T) {
+	service := &exportTelemetryService{}
+	handlers := NewTelemetryHandlers(service, zap.NewNop())
+
+	w := performExport(handlers, `{"signal": "traces", "start_time": "2024-01-01T00:00:00Z", "end_time": "2024-01-02T00:00:00Z"}`)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/vnd.apache.parquet", w.Header().Get("Content-Type"))
+	assert.Equal(t, services.ExportFormatParquet, service.queries[0].Format)
+}
+
+func TestHandleExport_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+	}{
+		{"invalid signal", `{"signal": "events", "start_time": "2024-01-01T00:00:00Z", "end_time": "2024-01-02T00:00:00Z"}`, nil, http.StatusBadRequest},
+		{"invalid format", `{"signal": "logs", "format": "xlsx", "start_time": "2024-01-01T00:00:00Z", "end_time": "2024-01-02T00:00:00Z"}`, nil, http.StatusBadRequest},
+		{"reversed range", `{"signal": "logs", "start_time": "2024-01-02T00:00:00Z", "end_time": "2024-01-01T00:00:00Z"}`, nil, http.StatusBadRequest},
+		{"store failure", `{"signal": "logs", "start_time": "2024-01-01T00:00:00Z", "end_time": "2024-01-02T00:00:00Z"}`, errors.New("disk I/O error"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers := NewTelemetryHandlers(&exportTelemetryService{err: tt.err}, zap.NewNop())
+
+			w := performExport(handlers, tt.body)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+			assert.Empty(t, w.Header().Get("Content-Disposition"))
+		})
+	}
+}
@@ -22,11 +22,12 @@ type LawrenceQLHandlers struct {
 	logger           *zap.Logger
 }
 
-// NewLawrenceQLHandlers creates a new Lawrence QL handlers instance
-func NewLawrenceQLHandlers(telemetryService services.TelemetryQueryService, logger *zap.Logger) *LawrenceQLHandlers {
+// NewLawrenceQLHandlers creates a new Lawrence QL handlers instance. Range
+// queries reuse the results held by the cache, if any.
+func NewLawrenceQLHandlers(telemetryService services.TelemetryQueryService, queryCache *query.ResultsCache, logger *zap.Logger) *LawrenceQLHandlers {
 	return &LawrenceQLHandlers{
 		telemetryService: telemetryService,
-		executor:         query.NewExecutor(telemetryService, logger),
+		executor:         query.NewExecutor(telemetryService, logger).WithResultsCache(queryCache),
 		logger:           logger,
 	}
 }
@@ -39,12 +40,19 @@ type LawrenceQLRequest struct {
 	Limit     int        `json:"limit,omitempty"`
 	AgentID   *string    `json:"agent_id,omitempty"`
 	GroupID   *string    `json:"group_id,omitempty"`
+	// DisableRollups forces metrics queries to read raw data
+	DisableRollups bool `json:"disable_rollups,omitempty"`
+	// Step makes the request a range query evaluated at this resolution,
+	// such as 30s or 1m
+	Step string `json:"step,omitempty"`
 }
 
 // LawrenceQLResponse represents a Lawrence QL query response
 type LawrenceQLResponse struct {
 	Results []query.QueryResult `json:"results"`
-	Meta    query.QueryMeta     `json:"meta"`
+	// Series holds the results of range queries
+	Series []query.Series  `json:"series,omitempty"`
+	Meta   query.QueryMeta `json:"meta"`
 }
 
 // ValidateQueryRequest represents a query validation request
@@ -88,9 +96,10 @@ func (h *LawrenceQLHandlers) HandleLawrenceQLQuery(c *gin.Context) {
 
 	// Build execution context
 	execCtx := &query.ExecutionContext{
-		StartTime: req.StartTime,
-		EndTime:   req.EndTime,
-		Limit:     req.Limit,
+		StartTime:      req.StartTime,
+		EndTime:        req.EndTime,
+		Limit:          req.Limit,
+		DisableRollups: req.DisableRollups,
 	}
 
 	// Parse agent ID if provided
@@ -108,6 +117,15 @@ func (h *LawrenceQLHandlers) HandleLawrenceQLQuery(c *gin.Context) {
 		execCtx.GroupID = req.GroupID
 	}
 
+	if req.Step != "" {
+		step, err := query.ParseDuration(req.Step)
+		if err != nil || step <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid step", "details": "step must be a positive duration such as 30s or 1m"})
+			return
+		}
+		execCtx.Step = step
+	}
+
 	// Set default limit if not provided
 	if execCtx.Limit == 0 {
 		execCtx.Limit = 1000
@@ -117,19 +135,21 @@ func (h *LawrenceQLHandlers) HandleLawrenceQLQuery(c *gin.Context) {
 	}
 
 	// Execute the query
-	results, meta, err := h.executor.Execute(c.Request.Context(), parsedQuery, execCtx)
+	response := LawrenceQLResponse{Results: []query.QueryResult{}}
+	var meta *query.QueryMeta
+	if execCtx.Step > 0 {
+		response.Series, meta, err = h.executor.ExecuteRange(c.Request.Context(), parsedQuery, execCtx)
+	} else {
+		response.Results, meta, err = h.executor.Execute(c.Request.Context(), parsedQuery, execCtx)
+	}
 	if err != nil {
 		h.logger.Error("Failed to execute Lawrence QL query",
 			zap.Error(err),
 			zap.String("query", req.Query))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to execute query", "details": err.Error()})
+		c.JSON(queryErrorStatus(err), gin.H{"error": "Failed to execute query", "details": err.Error()})
 		return
 	}
-
-	response := LawrenceQLResponse{
-		Results: results,
-		Meta:    *meta,
-	}
+	response.Meta = *meta
 
 	c.JSON(http.StatusOK, response)
 }
@@ -223,6 +243,20 @@ func (h *LawrenceQLHandlers) HandleGetTemplates(c *gin.Context) {
 			Query:       `logs{body=~".*error.*"} [1h]`,
 			Category:    "logs",
 		},
+		{
+			ID:          "logs-pipeline",
+			Name:        "Parse JSON Logs",
+			Description: "Extract fields from JSON logs and filter on them",
+			Query:       `logs{service="api"} |= "error" | json | status >= 500 | line_format "{{.msg}}" [1h]`,
+			Category:    "logs",
+		},
+		{
+			ID:          "logs-count-over-time",
+			Name:        "Log Volume by Level",
+			Description: "Count log lines per level",
+			Query:       `count_over_time(logs{service="api"} | logfmt [5m]) by (level)`,
+			Category:    "logs",
+		},
 		{
 			ID:          "traces-service",
 			Name:        "Traces by Service",
@@ -230,6 +264,13 @@ func (h *LawrenceQLHandlers) HandleGetTemplates(c *gin.Context) {
 			Query:       `traces{service="api"} [30m]`,
 			Category:    "traces",
 		},
+		{
+			ID:          "traces-slow-errors",
+			Name:        "Slow Failing Downstream Spans",
+			Description: "Find traces where a frontend request leads to a slow failing database span",
+			Query:       `traces{service="frontend"} >> traces{service="db", status="error", duration > 500ms} [30m]`,
+			Category:    "traces",
+		},
 		{
 			ID:          "metrics-sum",
 			Name:        "Sum Metrics",
@@ -261,8 +302,22 @@ func (h *LawrenceQLHandlers) HandleGetTemplates(c *gin.Context) {
 		{
 			ID:          "metrics-histogram",
 			Name:        "Histogram Quantiles",
-			Description: "Calculate histogram quantiles (p50, p95, p99)",
-			Query:       `histogram_quantile(metrics{metric="response_time"} [5m])`,
+			Description: "Calculate the p99 from histogram buckets per service",
+			Query:       `histogram_quantile(0.99, metrics{metric="response_time"} [5m]) by (service)`,
+			Category:    "functions",
+		},
+		{
+			ID:          "metrics-error-ratio",
+			Name:        "Error Ratio by Team",
+			Description: "Divide errors by requests per service and label the result with the owning team",
+			Query:       `metrics{metric="errors_total"} / on(service) metrics{metric="requests_total"} * on(service) group_left(team) metrics{metric="service_owner"}`,
+			Category:    "functions",
+		},
+		{
+			ID:          "metrics-topk",
+			Name:        "Busiest Services",
+			Description: "Show the five services with the highest request rate",
+			Query:       `topk(5, rate(metrics{metric="requests_total"} [5m]))`,
 			Category:    "functions",
 		},
 	}
@@ -324,8 +379,68 @@ func (h *LawrenceQLHandlers) HandleGetFunctions(c *gin.Context) {
 		},
 		{
 			Name:        "histogram_quantile",
-			Description: "Calculates histogram quantiles (p50, p90, p95, p99)",
-			Example:     "histogram_quantile(metrics{metric=\"response_time\"} [5m])",
+			Description: "Estimates a quantile from histogram buckets; without a quantile returns p50, p90, p95 and p99",
+			Example:     "histogram_quantile(0.99, metrics{metric=\"response_time\"} [5m])",
+		},
+		{
+			Name:        "count_over_time",
+			Description: "Counts log lines per label set; rate() of logs returns lines per second",
+			Example:     "count_over_time(logs{service=\"api\"} |= \"error\" [5m]) by (level)",
+		},
+		{
+			Name:        "avg_over_time",
+			Description: "Averages the samples of each series over the time range",
+			Example:     "avg_over_time(metrics{metric=\"cpu_usage\"} [5m])",
+		},
+		{
+			Name:        "quantile_over_time",
+			Description: "Calculates a quantile of the samples of each series over the time range",
+			Example:     "quantile_over_time(0.95, metrics{metric=\"response_time\"} [5m])",
+		},
+		{
+			Name:        "delta",
+			Description: "Calculates the difference between the first and last sample of each gauge series",
+			Example:     "delta(metrics{metric=\"queue_depth\"} [1h])",
+		},
+		{
+			Name:        "deriv",
+			Description: "Calculates the per-second derivative of each gauge series using linear regression",
+			Example:     "deriv(metrics{metric=\"memory_usage\"} [10m])",
+		},
+		{
+			Name:        "predict_linear",
+			Description: "Predicts the value of each gauge series a number of seconds ahead",
+			Example:     "predict_linear(metrics{metric=\"disk_usage\"} [1h], 3600)",
+		},
+		{
+			Name:        "topk",
+			Description: "Returns the k series with the highest values",
+			Example:     "topk(5, metrics{metric=\"cpu_usage\"})",
+		},
+		{
+			Name:        "bottomk",
+			Description: "Returns the k series with the lowest values",
+			Example:     "bottomk(3, metrics{metric=\"free_memory\"}) by (region)",
+		},
+		{
+			Name:        "abs",
+			Description: "Returns the absolute value of each sample",
+			Example:     "abs(delta(metrics{metric=\"temperature\"} [1h]))",
+		},
+		{
+			Name:        "clamp",
+			Description: "Limits each sample to a minimum and a maximum",
+			Example:     "clamp(metrics{metric=\"cpu_usage\"}, 0, 100)",
+		},
+		{
+			Name:        "label_replace",
+			Description: "Sets a label from a regular expression match on another label",
+			Example:     "label_replace(metrics{metric=\"up\"}, \"host\", \"$1\", \"instance\", \"(.*):.*\")",
+		},
+		{
+			Name:        "absent",
+			Description: "Returns 1 when the query has no results, to alert on missing series",
+			Example:     "absent(metrics{metric=\"heartbeat\", service=\"api\"})",
 		},
 	}
 
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// ProcessingRuleHandlers handles ingest-time processing rule API endpoints
+type ProcessingRuleHandlers struct {
+	ruleService services.ProcessingRuleService
+	logger      *zap.Logger
+}
+
+// NewProcessingRuleHandlers creates a new processing rule handlers instance
+func NewProcessingRuleHandlers(ruleService services.ProcessingRuleService, logger *zap.Logger) *ProcessingRuleHandlers {
+	return &ProcessingRuleHandlers{
+		ruleService: ruleService,
+		logger:      logger,
+	}
+}
+
+// ProcessingRuleRequest represents the request to create or update a processing rule
+type ProcessingRuleRequest struct {
+	Name        string                   `json:"name" binding:"required"`
+	Description string                   `json:"description"`
+	Signal      services.RuleSignal      `json:"signal" binding:"required"`
+	Enabled     *bool                    `json:"enabled"`
+	Priority    int                      `json:"priority"`
+	Conditions  []services.RuleCondition `json:"conditions"`
+	Actions     []services.RuleAction    `json:"actions" binding:"required"`
+}
+
+// toRule converts the request to a service rule, enabling it by default
+func (r *ProcessingRuleRequest) toRule(id string) *services.ProcessingRule {
+	enabled := true
+	if r.Enabled != nil {
+		enabled = *r.Enabled
+	}
+	return &services.ProcessingRule{
+		ID:          id,
+		Name:        r.Name,
+		Description: r.Description,
+		Signal:      r.Signal,
+		Enabled:     enabled,
+		Priority:    r.Priority,
+		Conditions:  r.Conditions,
+		Actions:     r.Actions,
+	}
+}
+
+// HandleListRules handles GET /api/v1/processing-rules
+func (h *ProcessingRuleHandlers) HandleListRules(c *gin.Context) {
+	rules, err := h.ruleService.ListProcessingRules(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to list processing rules", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch processing rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// HandleGetRule handles GET /api/v1/processing-rules/:id
+func (h *ProcessingRuleHandlers) HandleGetRule(c *gin.Context) {
+	ruleID := c.Param("id")
+
+	rule, err := h.ruleService.GetProcessingRule(c.Request.Context(), ruleID)
+	if err != nil {
+		h.logger.Error("Failed to get processing rule", zap.String("rule_id", ruleID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch processing rule"})
+		return
+	}
+
+	if rule == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Processing rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// HandleCreateRule handles POST /api/v1/processing-rules
+func (h *ProcessingRuleHandlers) HandleCreateRule(c *gin.Context) {
+	var req ProcessingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	rule := req.toRule("")
+	if err := services.ValidateProcessingRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid processing rule", "details": err.Error()})
+		return
+	}
+
+	created, err := h.ruleService.CreateProcessingRule(c.Request.Context(), rule)
+	if err != nil {
+		h.logger.Error("Failed to create processing rule", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create processing rule", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// HandleUpdateRule handles PUT /api/v1/processing-rules/:id
+func (h *ProcessingRuleHandlers) HandleUpdateRule(c *gin.Context) {
+	ruleID := c.Param("id")
+
+	var req ProcessingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	rule := req.toRule(ruleID)
+	if err := services.ValidateProcessingRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid processing rule", "details": err.Error()})
+		return
+	}
+
+	updated, err := h.ruleService.UpdateProcessingRule(c.Request.Context(), rule)
+	if err != nil {
+		if err.Error() == "processing rule not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Processing rule not found"})
+			return
+		}
+		h.logger.Error("Failed to update processing rule", zap.String("rule_id", ruleID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update processing rule", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// HandleDeleteRule handles DELETE /api/v1/processing-rules/:id
+func (h *ProcessingRuleHandlers) HandleDeleteRule(c *gin.Context) {
+	ruleID := c.Param("id")
+
+	if err := h.ruleService.DeleteProcessingRule(c.Request.Context(), ruleID); err != nil {
+		if err.Error() == "processing rule not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Processing rule not found"})
+			return
+		}
+		h.logger.Error("Failed to delete processing rule", zap.String("rule_id", ruleID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete processing rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Processing rule deleted successfully"})
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+	"github.com/getlawrence/lawrence-oss/internal/storage/applicationstore/memory"
+)
+
+func setupProcessingRuleHandlersTest() *ProcessingRuleHandlers {
+	ruleService := services.NewProcessingRuleService(memory.NewStore(), nil, zap.NewNop())
+	return NewProcessingRuleHandlers(ruleService, zap.NewNop())
+}
+
+func TestHandleCreateRule_Success(t *testing.T) {
+	handlers := setupProcessingRuleHandlersTest()
+
+	body, _ := json.Marshal(ProcessingRuleRequest{
+		Name:   "drop-debug",
+		Signal: services.RuleSignalLogs,
+		Conditions: []services.RuleCondition{
+			{Field: "severity_text", Operator: services.RuleOperatorEquals, Value: "DEBUG"},
+		},
+		Actions: []services.RuleAction{{Type: services.RuleActionDrop}},
+	})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/processing-rules", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handlers.HandleCreateRule(c)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created services.ProcessingRule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.ID)
+	assert.True(t, created.Enabled, "rules are enabled by default")
+}
+
+func TestHandleCreateRule_InvalidRule(t *testing.T) {
+	handlers := setupProcessingRuleHandlersTest()
+
+	body, _ := json.Marshal(ProcessingRuleRequest{
+		Name:    "mask-spans",
+		Signal:  services.RuleSignalTraces,
+		Actions: []services.RuleAction{{Type: services.RuleActionMaskBody, Pattern: "secret"}},
+	})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/processing-rules", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handlers.HandleCreateRule(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "only available for logs")
+}
+
+func TestHandleDeleteRule_NotFound(t *testing.T) {
+	handlers := setupProcessingRuleHandlersTest()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("DELETE", "/api/v1/processing-rules/missing", nil)
+	c.Params = gin.Params{{Key: "id", Value: "missing"}}
+
+	handlers.HandleDeleteRule(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/query"
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// defaultRecordingInterval is used when a recording rule has no interval
+const defaultRecordingInterval = "1m"
+
+// RecordingRuleHandlers handles recording rule API endpoints
+type RecordingRuleHandlers struct {
+	ruleService services.RecordingRuleService
+	logger      *zap.Logger
+}
+
+// NewRecordingRuleHandlers creates a new recording rule handlers instance
+func NewRecordingRuleHandlers(ruleService services.RecordingRuleService, logger *zap.Logger) *RecordingRuleHandlers {
+	return &RecordingRuleHandlers{
+		ruleService: ruleService,
+		logger:      logger,
+	}
+}
+
+// RecordingRuleRequest represents the request to create or update a recording rule
+type RecordingRuleRequest struct {
+	Name        string            `json:"name" binding:"required"`
+	Description string            `json:"description"`
+	Expr        string            `json:"expr" binding:"required"`
+	Interval    string            `json:"interval"`
+	Labels      map[string]string `json:"labels"`
+	Enabled     *bool             `json:"enabled"`
+}
+
+// toRule converts the request to a service rule, enabling it and
+// evaluating it every minute by default
+func (r *RecordingRuleRequest) toRule(id string) *services.RecordingRule {
+	enabled := true
+	if r.Enabled != nil {
+		enabled = *r.Enabled
+	}
+	interval := r.Interval
+	if interval == "" {
+		interval = defaultRecordingInterval
+	}
+	return &services.RecordingRule{
+		ID:          id,
+		Name:        r.Name,
+		Description: r.Description,
+		Expr:        r.Expr,
+		Interval:    interval,
+		Labels:      r.Labels,
+		Enabled:     enabled,
+	}
+}
+
+// validateRecordingRule checks a rule and parses its expression
+func validateRecordingRule(rule *services.RecordingRule) error {
+	if err := services.ValidateRecordingRule(rule); err != nil {
+		return err
+	}
+	if _, err := query.NewParser(rule.Expr).Parse(); err != nil {
+		return fmt.Errorf("invalid expression: %w", err)
+	}
+	return nil
+}
+
+// HandleListRecordingRules handles GET /api/v1/recording-rules
+func (h *RecordingRuleHandlers) HandleListRecordingRules(c *gin.Context) {
+	rules, err := h.ruleService.ListRecordingRules(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to list recording rules", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recording rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// HandleGetRecordingRule handles GET /api/v1/recording-rules/:id
+func (h *RecordingRuleHandlers) HandleGetRecordingRule(c *gin.Context) {
+	ruleID := c.Param("id")
+
+	rule, err := h.ruleService.GetRecordingRule(c.Request.Context(), ruleID)
+	if err != nil {
+		h.logger.Error("Failed to get recording rule", zap.String("rule_id", ruleID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recording rule"})
+		return
+	}
+
+	if rule == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recording rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// HandleCreateRecordingRule handles POST /api/v1/recording-rules
+func (h *RecordingRuleHandlers) HandleCreateRecordingRule(c *gin.Context) {
+	var req RecordingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	rule := req.toRule("")
+	if err := validateRecordingRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recording rule", "details": err.Error()})
+		return
+	}
+
+	created, err := h.ruleService.CreateRecordingRule(c.Request.Context(), rule)
+	if err != nil {
+		h.logger.Error("Failed to create recording rule", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recording rule", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// HandleUpdateRecordingRule handles PUT /api/v1/recording-rules/:id
+func (h *RecordingRuleHandlers) HandleUpdateRecordingRule(c *gin.Context) {
+	ruleID := c.Param("id")
+
+	var req RecordingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	rule := req.toRule(ruleID)
+	if err := validateRecordingRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recording rule", "details": err.Error()})
+		return
+	}
+
+	updated, err := h.ruleService.UpdateRecordingRule(c.Request.Context(), rule)
+	if err != nil {
+		switch err.Error() {
+		case "recording rule not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recording rule not found"})
+			return
+		case "recording rule is defined in configuration":
+			c.JSON(http.StatusConflict, gin.H{"error": "Recording rule is defined in configuration and cannot be changed"})
+			return
+		}
+		h.logger.Error("Failed to update recording rule", zap.String("rule_id", ruleID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recording rule", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// HandleDeleteRecordingRule handles DELETE /api/v1/recording-rules/:id
+func (h *RecordingRuleHandlers) HandleDeleteRecordingRule(c *gin.Context) {
+	ruleID := c.Param("id")
+
+	if err := h.ruleService.DeleteRecordingRule(c.Request.Context(), ruleID); err != nil {
+		switch err.Error() {
+		case "recording rule not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recording rule not found"})
+			return
+		case "recording rule is defined in configuration":
+			c.JSON(http.StatusConflict, gin.H{"error": "Recording rule is defined in configuration and cannot be deleted"})
+			return
+		}
+		h.logger.Error("Failed to delete recording rule", zap.String("rule_id", ruleID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete recording rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recording rule deleted successfully"})
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+	"github.com/getlawrence/lawrence-oss/internal/storage/applicationstore/memory"
+)
+
+func setupRecordingRuleHandlersTest() *RecordingRuleHandlers {
+	configRules := []*services.RecordingRule{{
+		ID:       "config-service:errors:ratio",
+		Name:     "service:errors:ratio",
+		Expr:     `metrics{metric="errors"} / metrics{metric="requests"}`,
+		Interval: "1m",
+		Enabled:  true,
+	}}
+	ruleService := services.NewRecordingRuleService(memory.NewStore(), configRules, nil, zap.NewNop())
+	return NewRecordingRuleHandlers(ruleService, zap.NewNop())
+}
+
+func TestHandleCreateRecordingRule_Success(t *testing.T) {
+	handlers := setupRecordingRuleHandlersTest()
+
+	body, _ := json.Marshal(RecordingRuleRequest{
+		Name: "service:requests:rate5m",
+		Expr: `sum(rate(metrics{metric="requests_total"} [5m])) by (service)`,
+	})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/recording-rules", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handlers.HandleCreateRecordingRule(c)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created services.RecordingRule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.ID)
+	assert.True(t, created.Enabled, "rules are enabled by default")
+	assert.Equal(t, "1m", created.Interval)
+	assert.Equal(t, services.RecordingRuleSourceAPI, created.Source)
+}
+
+func TestHandleCreateRecordingRule_InvalidExpression(t *testing.T) {
+	handlers := setupRecordingRuleHandlersTest()
+
+	body, _ := json.Marshal(RecordingRuleRequest{
+		Name: "service:requests:rate5m",
+		Expr: `sum(rate(metrics{metric="requests_total"`,
+	})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/recording-rules", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handlers.HandleCreateRecordingRule(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid expression")
+}
+
+func TestHandleDeleteRecordingRule_ConfigRule(t *testing.T) {
+	handlers := setupRecordingRuleHandlersTest()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("DELETE", "/api/v1/recording-rules/config-service:errors:ratio", nil)
+	c.Params = gin.Params{{Key: "id", Value: "config-service:errors:ratio"}}
+
+	handlers.HandleDeleteRecordingRule(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
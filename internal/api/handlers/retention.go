@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// RetentionHandlers handles data retention API endpoints
+type RetentionHandlers struct {
+	telemetryService services.TelemetryQueryService
+	policy           services.RetentionPolicy
+	logger           *zap.Logger
+}
+
+// NewRetentionHandlers creates a new retention handlers instance
+func NewRetentionHandlers(telemetryService services.TelemetryQueryService, policy services.RetentionPolicy, logger *zap.Logger) *RetentionHandlers {
+	return &RetentionHandlers{
+		telemetryService: telemetryService,
+		policy:           policy,
+		logger:           logger,
+	}
+}
+
+// RetentionPolicyResponse represents the configured retention policy.
+// Durations are formatted strings; an empty duration keeps data indefinitely.
+type RetentionPolicyResponse struct {
+	RawMetrics   string                      `json:"raw_metrics"`
+	RawLogs      string                      `json:"raw_logs"`
+	RawTraces    string                      `json:"raw_traces"`
+	Rollups      map[string]string           `json:"rollups"`
+	Usage        string                      `json:"usage"`
+	Overrides    []RetentionOverrideResponse `json:"overrides"`
+	MaxDiskBytes int64                       `json:"max_disk_bytes,omitempty"`
+}
+
+// RetentionOverrideResponse represents a group or service retention override
+type RetentionOverrideResponse struct {
+	Group      string `json:"group,omitempty"`
+	Service    string `json:"service,omitempty"`
+	RawMetrics string `json:"raw_metrics,omitempty"`
+	RawLogs    string `json:"raw_logs,omitempty"`
+	RawTraces  string `json:"raw_traces,omitempty"`
+}
+
+// HandleGetPolicy handles GET /api/v1/retention/policy
+func (h *RetentionHandlers) HandleGetPolicy(c *gin.Context) {
+	response := RetentionPolicyResponse{
+		RawMetrics:   formatRetention(h.policy.RawMetrics),
+		RawLogs:      formatRetention(h.policy.RawLogs),
+		RawTraces:    formatRetention(h.policy.RawTraces),
+		Rollups:      make(map[string]string, len(h.policy.Rollups)),
+		Usage:        formatRetention(h.policy.Usage),
+		Overrides:    make([]RetentionOverrideResponse, len(h.policy.Overrides)),
+		MaxDiskBytes: h.policy.MaxDiskBytes,
+	}
+	for interval, retention := range h.policy.Rollups {
+		response.Rollups[string(interval)] = formatRetention(retention)
+	}
+	for i, override := range h.policy.Overrides {
+		response.Overrides[i] = RetentionOverrideResponse{
+			Group:      override.Group,
+			Service:    override.Service,
+			RawMetrics: formatRetention(override.RawMetrics),
+			RawLogs:    formatRetention(override.RawLogs),
+			RawTraces:  formatRetention(override.RawTraces),
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// HandleDryRun handles POST /api/v1/retention/dry-run
+func (h *RetentionHandlers) HandleDryRun(c *gin.Context) {
+	report, err := h.telemetryService.ApplyRetention(c.Request.Context(), h.policy, true)
+	if err != nil {
+		h.logger.Error("Failed to run retention dry run", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run retention dry run", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func formatRetention(retention time.Duration) string {
+	if retention <= 0 {
+		return ""
+	}
+	return retention.String()
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// retentionTelemetryService records retention runs
+type retentionTelemetryService struct {
+	services.TelemetryQueryService
+	dryRuns []bool
+}
+
+func (s *retentionTelemetryService) ApplyRetention(ctx context.Context, policy services.RetentionPolicy, dryRun bool) (*services.RetentionReport, error) {
+	s.dryRuns = append(s.dryRuns, dryRun)
+	return &services.RetentionReport{
+		DryRun: dryRun,
+		Tables: []services.TableRetention{{Table: "logs", ExpiredRows: 3}},
+	}, nil
+}
+
+func testRetentionPolicy() services.RetentionPolicy {
+	return services.RetentionPolicy{
+		RawLogs: 24 * time.Hour,
+		Rollups: map[services.RollupInterval]time.Duration{services.RollupInterval1m: 7 * 24 * time.Hour},
+		Overrides: []services.RetentionOverride{
+			{Service: "checkout", RawLogs: 72 * time.Hour},
+		},
+	}
+}
+
+func TestHandleGetRetentionPolicy(t *testing.T) {
+	handlers := NewRetentionHandlers(&retentionTelemetryService{}, testRetentionPolicy(), zap.NewNop())
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/v1/retention/policy", nil)
+
+	handlers.HandleGetPolicy(c)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response RetentionPolicyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "24h0m0s", response.RawLogs)
+	assert.Empty(t, response.RawMetrics, "unset retention keeps data indefinitely")
+	assert.Equal(t, "168h0m0s", response.Rollups["1m"])
+	require.Len(t, response.Overrides, 1)
+	assert.Equal(t, "checkout", response.Overrides[0].Service)
+}
+
+func TestHandleRetentionDryRun(t *testing.T) {
+	service := &retentionTelemetryService{}
+	handlers := NewRetentionHandlers(service, testRetentionPolicy(), zap.NewNop())
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/retention/dry-run", nil)
+
+	handlers.HandleDryRun(c)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []bool{true}, service.dryRuns)
+	var report services.RetentionReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.True(t, report.DryRun)
+	assert.Equal(t, int64(3), report.Tables[0].ExpiredRows)
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"time"
 
@@ -25,6 +27,15 @@ func NewTelemetryHandlers(telemetryService services.TelemetryQueryService, logge
 	}
 }
 
+// queryErrorStatus returns the status for a failed telemetry query: a gateway
+// timeout when the store gave up on a slow query, otherwise an internal error
+func queryErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
 // QueryMetricsRequest represents the request for querying metrics
 type QueryMetricsRequest struct {
 	AgentID    *string   `json:"agent_id" binding:"omitempty,uuid"`
@@ -115,7 +126,7 @@ func (h *TelemetryHandlers) HandleQueryMetrics(c *gin.Context) {
 	metrics, err := h.telemetryService.QueryMetrics(c.Request.Context(), query)
 	if err != nil {
 		h.logger.Error("Failed to query metrics", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query metrics"})
+		c.JSON(queryErrorStatus(err), gin.H{"error": "Failed to query metrics"})
 		return
 	}
 
@@ -167,7 +178,7 @@ func (h *TelemetryHandlers) HandleQueryLogs(c *gin.Context) {
 	logs, err := h.telemetryService.QueryLogs(c.Request.Context(), query)
 	if err != nil {
 		h.logger.Error("Failed to query logs", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query logs"})
+		c.JSON(queryErrorStatus(err), gin.H{"error": "Failed to query logs"})
 		return
 	}
 
@@ -218,7 +229,7 @@ func (h *TelemetryHandlers) HandleQueryTraces(c *gin.Context) {
 	traces, err := h.telemetryService.QueryTraces(c.Request.Context(), query)
 	if err != nil {
 		h.logger.Error("Failed to query traces", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query traces"})
+		c.JSON(queryErrorStatus(err), gin.H{"error": "Failed to query traces"})
 		return
 	}
 
@@ -0,0 +1,57 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// failingTelemetryService fails every log query with the given error
+type failingTelemetryService struct {
+	services.TelemetryQueryService
+	err error
+}
+
+func (s *failingTelemetryService) QueryLogs(ctx context.Context, query services.LogQuery) ([]services.Log, error) {
+	return nil, s.err
+}
+
+func TestHandleQueryLogs_ErrorStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"timeout", fmt.Errorf("failed to query logs: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{"failure", errors.New("disk I/O error"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers := NewTelemetryHandlers(&failingTelemetryService{err: tt.err}, zap.NewNop())
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			body := `{"start_time": "2024-01-01T00:00:00Z", "end_time": "2024-01-02T00:00:00Z"}`
+			c.Request = httptest.NewRequest("POST", "/api/v1/telemetry/logs/query", strings.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handlers.HandleQueryLogs(c)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
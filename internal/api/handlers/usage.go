@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// UsageHandlers handles ingestion usage API endpoints
+type UsageHandlers struct {
+	telemetryService services.TelemetryQueryService
+	logger           *zap.Logger
+}
+
+// NewUsageHandlers creates a new usage handlers instance
+func NewUsageHandlers(telemetryService services.TelemetryQueryService, logger *zap.Logger) *UsageHandlers {
+	return &UsageHandlers{
+		telemetryService: telemetryService,
+		logger:           logger,
+	}
+}
+
+// TopUsageResponse represents the top-N usage response
+type TopUsageResponse struct {
+	By        string                  `json:"by"`
+	Order     string                  `json:"order"`
+	StartTime time.Time               `json:"start_time"`
+	EndTime   time.Time               `json:"end_time"`
+	Usage     []services.UsageSummary `json:"usage"`
+	Count     int                     `json:"count"`
+}
+
+// HandleGetTopUsage handles GET /api/v1/usage/top
+func (h *UsageHandlers) HandleGetTopUsage(c *gin.Context) {
+	by := c.DefaultQuery("by", string(services.UsageGroupByAgent))
+	switch services.UsageGroupBy(by) {
+	case services.UsageGroupByAgent, services.UsageGroupByGroup, services.UsageGroupByService:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group by, must be one of agent, group, service"})
+		return
+	}
+
+	order := c.DefaultQuery("order", string(services.UsageOrderByBytes))
+	switch services.UsageOrderBy(order) {
+	case services.UsageOrderByBytes, services.UsageOrderByRecords:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order, must be one of bytes, records"})
+		return
+	}
+
+	// Parse limit
+	limit := 10
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err != nil || parsedLimit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = parsedLimit
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	// Parse time range, defaulting to the last 24 hours
+	endTime := time.Now()
+	if endStr := c.Query("end_time"); endStr != "" {
+		parsed, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_time format", "details": err.Error()})
+			return
+		}
+		endTime = parsed
+	}
+	startTime := endTime.Add(-24 * time.Hour)
+	if startStr := c.Query("start_time"); startStr != "" {
+		parsed, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_time format", "details": err.Error()})
+			return
+		}
+		startTime = parsed
+	}
+
+	query := services.UsageQuery{
+		GroupBy:   services.UsageGroupBy(by),
+		OrderBy:   services.UsageOrderBy(order),
+		StartTime: startTime,
+		EndTime:   endTime,
+		Limit:     limit,
+	}
+
+	if signal := c.Query("signal"); signal != "" {
+		switch signal {
+		case "traces", "metrics", "logs":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signal, must be one of traces, metrics, logs"})
+			return
+		}
+		query.Signal = &signal
+	}
+
+	if agentIDStr := c.Query("agent_id"); agentIDStr != "" {
+		parsed, err := uuid.Parse(agentIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agent ID format"})
+			return
+		}
+		query.AgentID = &parsed
+	}
+
+	if groupID := c.Query("group_id"); groupID != "" {
+		query.GroupID = &groupID
+	}
+
+	usage, err := h.telemetryService.GetTopUsage(c.Request.Context(), query)
+	if err != nil {
+		h.logger.Error("Failed to get top usage", zap.Error(err))
+		c.JSON(queryErrorStatus(err), gin.H{"error": "Failed to get usage", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, TopUsageResponse{
+		By:        by,
+		Order:     order,
+		StartTime: startTime,
+		EndTime:   endTime,
+		Usage:     usage,
+		Count:     len(usage),
+	})
+}
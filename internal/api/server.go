@@ -14,7 +14,9 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/getlawrence/lawrence-oss/internal/api/handlers"
+	"github.com/getlawrence/lawrence-oss/internal/backup"
 	"github.com/getlawrence/lawrence-oss/internal/metrics"
+	"github.com/getlawrence/lawrence-oss/internal/query"
 	"github.com/getlawrence/lawrence-oss/internal/services"
 )
 
@@ -32,14 +34,67 @@ type Server struct {
 	agentService     services.AgentService
 	telemetryService services.TelemetryQueryService
 	commander        AgentCommander
+	ruleService      services.ProcessingRuleService
+	recordingService services.RecordingRuleService
+	alertService     services.AlertService
+	queryCache       *query.ResultsCache
+	retentionPolicy  *services.RetentionPolicy
+	backupStores     []backup.Store
+	version          string
 	logger           *zap.Logger
 	httpServer       *http.Server
 	metrics          *metrics.APIMetrics
 	registry         *prometheus.Registry
 }
 
+// ServerOption configures optional API server components
+type ServerOption func(*Server)
+
+// WithProcessingRuleService enables the processing rule management endpoints
+func WithProcessingRuleService(ruleService services.ProcessingRuleService) ServerOption {
+	return func(s *Server) {
+		s.ruleService = ruleService
+	}
+}
+
+// WithRecordingRuleService enables the recording rule management endpoints
+func WithRecordingRuleService(recordingService services.RecordingRuleService) ServerOption {
+	return func(s *Server) {
+		s.recordingService = recordingService
+	}
+}
+
+// WithAlertService enables the alert rule, silence and alert endpoints
+func WithAlertService(alertService services.AlertService) ServerOption {
+	return func(s *Server) {
+		s.alertService = alertService
+	}
+}
+
+// WithQueryCache makes range queries reuse the results held by the cache
+func WithQueryCache(cache *query.ResultsCache) ServerOption {
+	return func(s *Server) {
+		s.queryCache = cache
+	}
+}
+
+// WithRetentionPolicy enables the retention policy and dry-run endpoints
+func WithRetentionPolicy(policy services.RetentionPolicy) ServerOption {
+	return func(s *Server) {
+		s.retentionPolicy = &policy
+	}
+}
+
+// WithBackup enables the backup endpoint for the given stores
+func WithBackup(stores []backup.Store, version string) ServerOption {
+	return func(s *Server) {
+		s.backupStores = stores
+		s.version = version
+	}
+}
+
 // NewServer creates a new API server
-func NewServer(agentService services.AgentService, telemetryService services.TelemetryQueryService, commander AgentCommander, logger *zap.Logger) *Server {
+func NewServer(agentService services.AgentService, telemetryService services.TelemetryQueryService, commander AgentCommander, logger *zap.Logger, opts ...ServerOption) *Server {
 	// Set Gin to release mode for production
 	gin.SetMode(gin.ReleaseMode)
 
@@ -65,6 +120,10 @@ func NewServer(agentService services.AgentService, telemetryService services.Tel
 		registry:         registry,
 	}
 
+	for _, opt := range opts {
+		opt(server)
+	}
+
 	// Add metrics middleware
 	router.Use(server.metricsMiddleware())
 
@@ -102,10 +161,11 @@ func (s *Server) registerRoutes() {
 	agentHandlers := handlers.NewAgentHandlers(s.agentService, s.commander, s.logger)
 	configHandlers := handlers.NewConfigHandlers(s.agentService, s.commander, s.logger)
 	telemetryHandlers := handlers.NewTelemetryHandlers(s.telemetryService, s.logger)
-	lawrenceQLHandlers := handlers.NewLawrenceQLHandlers(s.telemetryService, s.logger)
+	lawrenceQLHandlers := handlers.NewLawrenceQLHandlers(s.telemetryService, s.queryCache, s.logger)
 	groupHandlers := handlers.NewGroupHandlers(s.agentService, s.commander, s.logger)
 	topologyHandlers := handlers.NewTopologyHandlers(s.agentService, s.telemetryService, s.logger)
 	healthHandlers := handlers.NewHealthHandlers(s.agentService, s.telemetryService, s.logger)
+	usageHandlers := handlers.NewUsageHandlers(s.telemetryService, s.logger)
 
 	// Metrics endpoint
 	s.router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{})))
@@ -155,6 +215,9 @@ func (s *Server) registerRoutes() {
 			telemetry.POST("/query/suggestions", lawrenceQLHandlers.HandleGetSuggestions)
 			telemetry.GET("/query/templates", lawrenceQLHandlers.HandleGetTemplates)
 			telemetry.GET("/query/functions", lawrenceQLHandlers.HandleGetFunctions)
+
+			// Export endpoint
+			telemetry.POST("/export", telemetryHandlers.HandleExport)
 		}
 
 		// Group routes
@@ -178,6 +241,71 @@ func (s *Server) registerRoutes() {
 			topology.GET("/agent/:id", topologyHandlers.HandleGetAgentTopology)
 			topology.GET("/group/:id", topologyHandlers.HandleGetGroupTopology)
 		}
+
+		// Usage routes
+		usage := v1.Group("/usage")
+		{
+			usage.GET("/top", usageHandlers.HandleGetTopUsage)
+		}
+
+		// Processing rule routes
+		if s.ruleService != nil {
+			ruleHandlers := handlers.NewProcessingRuleHandlers(s.ruleService, s.logger)
+			rules := v1.Group("/processing-rules")
+			{
+				rules.GET("", ruleHandlers.HandleListRules)
+				rules.POST("", ruleHandlers.HandleCreateRule)
+				rules.GET("/:id", ruleHandlers.HandleGetRule)
+				rules.PUT("/:id", ruleHandlers.HandleUpdateRule)
+				rules.DELETE("/:id", ruleHandlers.HandleDeleteRule)
+			}
+		}
+
+		// Recording rule routes
+		if s.recordingService != nil {
+			recordingHandlers := handlers.NewRecordingRuleHandlers(s.recordingService, s.logger)
+			recordingRules := v1.Group("/recording-rules")
+			{
+				recordingRules.GET("", recordingHandlers.HandleListRecordingRules)
+				recordingRules.POST("", recordingHandlers.HandleCreateRecordingRule)
+				recordingRules.GET("/:id", recordingHandlers.HandleGetRecordingRule)
+				recordingRules.PUT("/:id", recordingHandlers.HandleUpdateRecordingRule)
+				recordingRules.DELETE("/:id", recordingHandlers.HandleDeleteRecordingRule)
+			}
+		}
+
+		// Alerting routes
+		if s.alertService != nil {
+			alertHandlers := handlers.NewAlertHandlers(s.alertService, s.logger)
+			alerts := v1.Group("/alerts")
+			{
+				alerts.GET("", alertHandlers.HandleListAlerts)
+				alerts.GET("/rules", alertHandlers.HandleListAlertRules)
+				alerts.POST("/rules", alertHandlers.HandleCreateAlertRule)
+				alerts.GET("/rules/:id", alertHandlers.HandleGetAlertRule)
+				alerts.PUT("/rules/:id", alertHandlers.HandleUpdateAlertRule)
+				alerts.DELETE("/rules/:id", alertHandlers.HandleDeleteAlertRule)
+				alerts.GET("/silences", alertHandlers.HandleListSilences)
+				alerts.POST("/silences", alertHandlers.HandleCreateSilence)
+				alerts.DELETE("/silences/:id", alertHandlers.HandleDeleteSilence)
+			}
+		}
+
+		// Retention routes
+		if s.retentionPolicy != nil {
+			retentionHandlers := handlers.NewRetentionHandlers(s.telemetryService, *s.retentionPolicy, s.logger)
+			retention := v1.Group("/retention")
+			{
+				retention.GET("/policy", retentionHandlers.HandleGetPolicy)
+				retention.POST("/dry-run", retentionHandlers.HandleDryRun)
+			}
+		}
+
+		// Backup routes
+		if len(s.backupStores) > 0 {
+			backupHandlers := handlers.NewBackupHandlers(s.backupStores, s.version, s.logger)
+			v1.POST("/backup", backupHandlers.HandleCreateBackup)
+		}
 	}
 
 	// Serve static files for the UI
@@ -0,0 +1,300 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FormatVersion is the version of the archive layout written by this build
+const FormatVersion = 1
+
+// manifestFile is the name of the manifest within an archive
+const manifestFile = "manifest.json"
+
+// Backupper takes and restores snapshots of a single store
+type Backupper interface {
+	// SchemaVersion returns the schema version created by this build of the store.
+	SchemaVersion() int
+
+	// Backup writes a consistent snapshot of the running store into dir.
+	Backup(ctx context.Context, dir string) error
+
+	// PrepareRestore stages the snapshot in dir without replacing the store.
+	PrepareRestore(ctx context.Context, dir string) error
+
+	// CommitRestore replaces the store with the snapshot staged by PrepareRestore.
+	CommitRestore() error
+
+	// DiscardRestore removes the snapshot staged by PrepareRestore.
+	DiscardRestore() error
+}
+
+// Store is a store included in backups
+type Store struct {
+	// Name identifies the store within the archive, like "application"
+	Name string
+	// Type is the configured storage type, like "sqlite"
+	Type string
+	Backupper
+}
+
+// Manifest describes the contents of a backup archive
+type Manifest struct {
+	FormatVersion   int             `json:"format_version"`
+	LawrenceVersion string          `json:"lawrence_version"`
+	CreatedAt       time.Time       `json:"created_at"`
+	Stores          []StoreManifest `json:"stores"`
+}
+
+// StoreManifest describes a single store within a backup archive
+type StoreManifest struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	SchemaVersion int    `json:"schema_version"`
+}
+
+// Store returns the manifest entry for the named store
+func (m *Manifest) Store(name string) (StoreManifest, bool) {
+	for _, store := range m.Stores {
+		if store.Name == name {
+			return store, true
+		}
+	}
+	return StoreManifest{}, false
+}
+
+// Create snapshots every store and writes them to w as a gzipped tar archive
+// with a manifest. Each store is snapshotted into its own directory first, so
+// a failing store does not leave a partial archive behind the manifest.
+func Create(ctx context.Context, w io.Writer, stores []Store, lawrenceVersion string) (*Manifest, error) {
+	dir, err := os.MkdirTemp("", "lawrence-backup-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest := &Manifest{
+		FormatVersion:   FormatVersion,
+		LawrenceVersion: lawrenceVersion,
+		CreatedAt:       time.Now().UTC(),
+		Stores:          make([]StoreManifest, 0, len(stores)),
+	}
+	for _, store := range stores {
+		if err := store.Backup(ctx, filepath.Join(dir, store.Name)); err != nil {
+			return nil, fmt.Errorf("failed to back up %s store: %w", store.Name, err)
+		}
+		manifest.Stores = append(manifest.Stores, StoreManifest{
+			Name:          store.Name,
+			Type:          store.Type,
+			SchemaVersion: store.SchemaVersion(),
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if err := writeArchive(w, dir); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return manifest, nil
+}
+
+// Restore extracts an archive written by Create and replaces every store with
+// its snapshot. All versions are validated and every snapshot is staged before
+// any store is replaced, so a snapshot that fails to restore leaves every store
+// as it was. Only a failure while swapping the staged snapshots in can leave
+// some stores replaced, which the error names.
+func Restore(ctx context.Context, r io.Reader, stores []Store) (*Manifest, error) {
+	dir, err := os.MkdirTemp("", "lawrence-restore-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create restore directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := extractArchive(r, dir); err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if err := Validate(&manifest, stores); err != nil {
+		return &manifest, err
+	}
+
+	for i, store := range stores {
+		if err := store.PrepareRestore(ctx, filepath.Join(dir, store.Name)); err != nil {
+			discardRestores(stores[:i+1])
+			return &manifest, fmt.Errorf("failed to restore %s store: %w", store.Name, err)
+		}
+	}
+
+	replaced := make([]string, 0, len(stores))
+	for i, store := range stores {
+		if err := store.CommitRestore(); err != nil {
+			discardRestores(stores[i:])
+			if len(replaced) > 0 {
+				return &manifest, fmt.Errorf("failed to restore %s store after replacing the %s store: %w",
+					store.Name, strings.Join(replaced, ", "), err)
+			}
+			return &manifest, fmt.Errorf("failed to restore %s store: %w", store.Name, err)
+		}
+		replaced = append(replaced, store.Name)
+	}
+	return &manifest, nil
+}
+
+// discardRestores removes the snapshots staged for stores. Failing to remove
+// one leaves a stale file next to the store, which the next restore replaces.
+func discardRestores(stores []Store) {
+	for _, store := range stores {
+		_ = store.DiscardRestore()
+	}
+}
+
+// Validate checks that a backup can be restored into the given stores. A
+// snapshot from an older schema is accepted since the store migrates it when
+// opened; a newer schema is rejected.
+func Validate(manifest *Manifest, stores []Store) error {
+	if manifest.FormatVersion != FormatVersion {
+		return fmt.Errorf("unsupported backup format version %d, expected %d", manifest.FormatVersion, FormatVersion)
+	}
+	for _, store := range stores {
+		entry, ok := manifest.Store(store.Name)
+		if !ok {
+			return fmt.Errorf("backup does not contain the %s store", store.Name)
+		}
+		if entry.Type != store.Type {
+			return fmt.Errorf("backup of %s store has type %s, but %s is configured", store.Name, entry.Type, store.Type)
+		}
+		if entry.SchemaVersion > store.SchemaVersion() {
+			return fmt.Errorf("backup of %s store has schema version %d, newer than supported version %d",
+				store.Name, entry.SchemaVersion, store.SchemaVersion())
+		}
+	}
+	return nil
+}
+
+// writeArchive writes the contents of dir to w as a gzipped tar archive
+func writeArchive(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || file == dir {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// extractArchive extracts a gzipped tar archive into dir, rejecting entries
+// that would escape it
+func extractArchive(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid archive entry %q", header.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				_ = f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported archive entry %q", header.Name)
+		}
+	}
+}
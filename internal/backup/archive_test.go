@@ -0,0 +1,195 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fileStore backs up a single file and records what it staged and restored
+type fileStore struct {
+	content    string
+	version    int
+	err        error
+	prepareErr error
+	commitErr  error
+	staged     string
+	restored   string
+	discarded  bool
+}
+
+func (s *fileStore) SchemaVersion() int { return s.version }
+
+func (s *fileStore) Backup(ctx context.Context, dir string) error {
+	if s.err != nil {
+		return s.err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "nested"), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "nested", "data"), []byte(s.content), 0o644)
+}
+
+func (s *fileStore) PrepareRestore(ctx context.Context, dir string) error {
+	if s.prepareErr != nil {
+		return s.prepareErr
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "nested", "data"))
+	if err != nil {
+		return err
+	}
+	s.staged = string(data)
+	return nil
+}
+
+func (s *fileStore) CommitRestore() error {
+	if s.commitErr != nil {
+		return s.commitErr
+	}
+	s.restored = s.staged
+	return nil
+}
+
+func (s *fileStore) DiscardRestore() error {
+	s.staged = ""
+	s.discarded = true
+	return nil
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	ctx := context.Background()
+	var archive bytes.Buffer
+	manifest, err := Create(ctx, &archive, []Store{
+		{Name: "application", Type: "sqlite", Backupper: &fileStore{content: "agents", version: 1}},
+		{Name: "telemetry", Type: "duckdb", Backupper: &fileStore{content: "metrics", version: 2}},
+	}, "1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersion, manifest.FormatVersion)
+	assert.Equal(t, "1.2.3", manifest.LawrenceVersion)
+
+	application := &fileStore{version: 1}
+	telemetry := &fileStore{version: 3}
+	restored, err := Restore(ctx, &archive, []Store{
+		{Name: "application", Type: "sqlite", Backupper: application},
+		{Name: "telemetry", Type: "duckdb", Backupper: telemetry},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "agents", application.restored)
+	assert.Equal(t, "metrics", telemetry.restored)
+
+	entry, ok := restored.Store("telemetry")
+	require.True(t, ok)
+	assert.Equal(t, 2, entry.SchemaVersion)
+}
+
+func TestCreate_StoreFailure(t *testing.T) {
+	var archive bytes.Buffer
+	_, err := Create(context.Background(), &archive, []Store{
+		{Name: "telemetry", Type: "duckdb", Backupper: &fileStore{err: errors.New("disk full")}},
+	}, "1.2.3")
+	assert.ErrorContains(t, err, "failed to back up telemetry store: disk full")
+	assert.Zero(t, archive.Len())
+}
+
+func TestRestore_ValidatesBeforeApplying(t *testing.T) {
+	ctx := context.Background()
+	var archive bytes.Buffer
+	_, err := Create(ctx, &archive, []Store{
+		{Name: "application", Type: "sqlite", Backupper: &fileStore{content: "agents", version: 1}},
+		{Name: "telemetry", Type: "duckdb", Backupper: &fileStore{content: "metrics", version: 2}},
+	}, "1.2.3")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		stores []Store
+		err    string
+	}{
+		{
+			name:   "newer schema",
+			stores: []Store{{Name: "telemetry", Type: "duckdb", Backupper: &fileStore{version: 1}}},
+			err:    "schema version 2, newer than supported version 1",
+		},
+		{
+			name:   "type mismatch",
+			stores: []Store{{Name: "telemetry", Type: "clickhouse", Backupper: &fileStore{version: 2}}},
+			err:    "has type duckdb, but clickhouse is configured",
+		},
+		{
+			name:   "missing store",
+			stores: []Store{{Name: "config", Type: "sqlite", Backupper: &fileStore{version: 1}}},
+			err:    "does not contain the config store",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			application := &fileStore{version: 1}
+			stores := append([]Store{{Name: "application", Type: "sqlite", Backupper: application}}, tt.stores...)
+
+			_, err := Restore(ctx, bytes.NewReader(archive.Bytes()), stores)
+			assert.ErrorContains(t, err, tt.err)
+			assert.Empty(t, application.restored)
+		})
+	}
+}
+
+func TestRestore_StagesEveryStoreBeforeReplacingAny(t *testing.T) {
+	ctx := context.Background()
+	var archive bytes.Buffer
+	_, err := Create(ctx, &archive, []Store{
+		{Name: "application", Type: "sqlite", Backupper: &fileStore{content: "agents", version: 1}},
+		{Name: "telemetry", Type: "duckdb", Backupper: &fileStore{content: "metrics", version: 2}},
+	}, "1.2.3")
+	require.NoError(t, err)
+
+	t.Run("prepare failure", func(t *testing.T) {
+		application := &fileStore{version: 1}
+		telemetry := &fileStore{version: 2, prepareErr: errors.New("disk full")}
+		_, err := Restore(ctx, bytes.NewReader(archive.Bytes()), []Store{
+			{Name: "application", Type: "sqlite", Backupper: application},
+			{Name: "telemetry", Type: "duckdb", Backupper: telemetry},
+		})
+		assert.EqualError(t, err, "failed to restore telemetry store: disk full")
+		assert.Empty(t, application.restored)
+		assert.True(t, application.discarded)
+		assert.True(t, telemetry.discarded)
+	})
+
+	t.Run("commit failure", func(t *testing.T) {
+		application := &fileStore{version: 1}
+		telemetry := &fileStore{version: 2, commitErr: errors.New("disk full")}
+		_, err := Restore(ctx, bytes.NewReader(archive.Bytes()), []Store{
+			{Name: "application", Type: "sqlite", Backupper: application},
+			{Name: "telemetry", Type: "duckdb", Backupper: telemetry},
+		})
+		assert.EqualError(t, err, "failed to restore telemetry store after replacing the application store: disk full")
+		assert.Equal(t, "agents", application.restored)
+		assert.True(t, telemetry.discarded)
+	})
+}
+
+func TestRestore_RejectsPathTraversal(t *testing.T) {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "../escape", Typeflag: tar.TypeReg, Mode: 0o644, Size: 1}))
+	_, err := tw.Write([]byte("x"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	_, err = Restore(context.Background(), &archive, nil)
+	assert.ErrorContains(t, err, `invalid archive entry "../escape"`)
+}
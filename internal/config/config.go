@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -10,13 +12,19 @@ import (
 
 // Config represents the application configuration
 type Config struct {
-	Server    ServerConfig    `yaml:"server"`
-	OTLP      OTLPConfig      `yaml:"otlp"`
-	Storage   StorageConfig   `yaml:"storage"`
-	Retention RetentionConfig `yaml:"retention"`
-	Rollups   RollupsConfig   `yaml:"rollups"`
-	Logging   LoggingConfig   `yaml:"logging"`
-	Worker    WorkerConfig    `yaml:"worker"`
+	Server         ServerConfig          `yaml:"server"`
+	OTLP           OTLPConfig            `yaml:"otlp"`
+	Storage        StorageConfig         `yaml:"storage"`
+	Retention      RetentionConfig       `yaml:"retention"`
+	Rollups        RollupsConfig         `yaml:"rollups"`
+	Logging        LoggingConfig         `yaml:"logging"`
+	Worker         WorkerConfig          `yaml:"worker"`
+	Usage          UsageConfig           `yaml:"usage"`
+	Redaction      RedactionConfig       `yaml:"redaction"`
+	Sampling       SamplingConfig        `yaml:"sampling"`
+	RecordingRules []RecordingRuleConfig `yaml:"recording_rules"`
+	Alerting       AlertingConfig        `yaml:"alerting"`
+	QueryCache     QueryCacheConfig      `yaml:"query_cache"`
 }
 
 // ServerConfig contains server configuration
@@ -47,23 +55,53 @@ type AppStorageConfig struct {
 
 // TelemetryStorageConfig contains telemetry storage configuration
 type TelemetryStorageConfig struct {
-	Type string `yaml:"type"`
-	Path string `yaml:"path"`
+	Type     string `yaml:"type"`
+	Path     string `yaml:"path"`
+	ColdPath string `yaml:"cold_path"` // Directory for sealed Parquet partitions
+	HotDays  int    `yaml:"hot_days"`  // Days kept in the database before sealing; 0 disables sealing
+
+	ReadConnections int    `yaml:"read_connections"` // Size of the query connection pool
+	QueryTimeout    string `yaml:"query_timeout"`    // Upper bound for a single query; empty disables it
 }
 
-// RetentionConfig contains data retention configuration
+// RetentionConfig contains data retention configuration. Durations accept
+// day suffixes like "7d"; an empty duration keeps data indefinitely.
 type RetentionConfig struct {
+	RawMetrics  string                    `yaml:"raw_metrics"`
+	RawLogs     string                    `yaml:"raw_logs"`
+	RawTraces   string                    `yaml:"raw_traces"`
+	Rollups1m   string                    `yaml:"rollups_1m"`
+	Rollups5m   string                    `yaml:"rollups_5m"`
+	Rollups1h   string                    `yaml:"rollups_1h"`
+	Rollups1d   string                    `yaml:"rollups_1d"`
+	Usage       string                    `yaml:"usage"`
+	Interval    string                    `yaml:"interval"`      // How often retention is applied
+	MaxDiskSize string                    `yaml:"max_disk_size"` // Size string like "10GB"; empty disables the cap
+	Overrides   []RetentionOverrideConfig `yaml:"overrides"`
+}
+
+// RetentionOverrideConfig replaces raw retention for a group, a service or
+// both. Empty durations inherit the defaults.
+type RetentionOverrideConfig struct {
+	Group      string `yaml:"group"` // Group ID or group name
+	Service    string `yaml:"service"`
 	RawMetrics string `yaml:"raw_metrics"`
 	RawLogs    string `yaml:"raw_logs"`
-	Rollups1m  string `yaml:"rollups_1m"`
-	Rollups5m  string `yaml:"rollups_5m"`
+	RawTraces  string `yaml:"raw_traces"`
 }
 
-// RollupsConfig contains rollup configuration
+// RollupsConfig contains rollup configuration. Intervals are cron
+// expressions; an empty interval uses the tier's default schedule.
 type RollupsConfig struct {
 	Enabled    bool   `yaml:"enabled"`
 	Interval1m string `yaml:"interval_1m"`
 	Interval5m string `yaml:"interval_5m"`
+	Interval1h string `yaml:"interval_1h"`
+	Interval1d string `yaml:"interval_1d"`
+	// Delay is how long after a minute closes before it is rolled up
+	Delay string `yaml:"delay"`
+	// MaxBackfill bounds how far back missed windows are generated after downtime
+	MaxBackfill string `yaml:"max_backfill"`
 }
 
 // LoggingConfig contains logging configuration
@@ -79,6 +117,112 @@ type WorkerConfig struct {
 	Timeout   string `yaml:"timeout"` // Duration string like "5s", "1m"
 }
 
+// UsageConfig contains ingestion usage accounting configuration
+type UsageConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	BucketSize    string `yaml:"bucket_size"`    // Duration string like "1m", "1h"
+	FlushInterval string `yaml:"flush_interval"` // Duration string like "30s"
+}
+
+// RedactionConfig contains PII redaction configuration
+type RedactionConfig struct {
+	Enabled bool                             `yaml:"enabled"`
+	Default RedactionPolicyConfig            `yaml:"default"`
+	Groups  map[string]RedactionPolicyConfig `yaml:"groups"` // Keyed by group ID or group name
+}
+
+// RedactionPolicyConfig contains the redaction settings applied to a group
+type RedactionPolicyConfig struct {
+	Disabled       bool                     `yaml:"disabled"`
+	Detectors      []string                 `yaml:"detectors"` // Empty means all built-in detectors
+	CustomPatterns []RedactionPatternConfig `yaml:"custom_patterns"`
+	Replacement    string                   `yaml:"replacement"`
+}
+
+// RedactionPatternConfig contains a named custom redaction pattern
+type RedactionPatternConfig struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+}
+
+// SamplingConfig contains trace sampling configuration
+type SamplingConfig struct {
+	Tail TailSamplingConfig `yaml:"tail"`
+}
+
+// TailSamplingConfig contains tail-based trace sampling configuration
+type TailSamplingConfig struct {
+	Enabled          bool                   `yaml:"enabled"`
+	DecisionWait     string                 `yaml:"decision_wait"` // Duration string like "10s"
+	MaxBufferedSpans int                    `yaml:"max_buffered_spans"`
+	DecidedCacheSize int                    `yaml:"decided_cache_size"`
+	Policies         []SamplingPolicyConfig `yaml:"policies"`
+}
+
+// SamplingPolicyConfig contains a single tail sampling policy
+type SamplingPolicyConfig struct {
+	Name      string   `yaml:"name"`
+	Type      string   `yaml:"type"`      // errors, latency, attribute, probabilistic
+	Threshold string   `yaml:"threshold"` // Duration string for latency policies
+	Key       string   `yaml:"key"`
+	Values    []string `yaml:"values"`
+	Rate      float64  `yaml:"rate"`
+}
+
+// RecordingRuleConfig contains a recording rule. Rules defined in
+// configuration are evaluated alongside those created through the API but
+// cannot be changed through it.
+type RecordingRuleConfig struct {
+	Name        string            `yaml:"name"` // Metric name the results are written as
+	Description string            `yaml:"description"`
+	Expr        string            `yaml:"expr"`     // Lawrence QL expression
+	Interval    string            `yaml:"interval"` // Duration string like "1m"; defaults to 1m
+	Labels      map[string]string `yaml:"labels"`
+}
+
+// AlertingConfig contains alert notification configuration. Alert rules and
+// silences are managed through the API; every notification group is sent to
+// all receivers.
+type AlertingConfig struct {
+	GroupBy        []string              `yaml:"group_by"`        // Labels grouping alerts into one notification
+	GroupWait      string                `yaml:"group_wait"`      // Duration string; wait before a new group's first notification
+	GroupInterval  string                `yaml:"group_interval"`  // Duration string; wait before notifying changes of a group
+	RepeatInterval string                `yaml:"repeat_interval"` // Duration string; wait before repeating an unchanged group
+	Receivers      []AlertReceiverConfig `yaml:"receivers"`
+	Fleet          FleetAlertsConfig     `yaml:"fleet"`
+}
+
+// FleetAlertsConfig contains the built-in fleet health alerts raised from
+// the OpAMP state of the agents. An empty threshold disables its check.
+type FleetAlertsConfig struct {
+	Enabled          bool   `yaml:"enabled"`
+	Interval         string `yaml:"interval"`           // Duration string; how often the fleet is checked
+	For              string `yaml:"for"`                // Duration string; how long a problem persists before firing
+	OfflineAfter     string `yaml:"offline_after"`      // Duration string; disconnected agents alert after this
+	NoTelemetryAfter string `yaml:"no_telemetry_after"` // Duration string; connected agents without telemetry alert after this
+	MinAgentVersion  string `yaml:"min_agent_version"`  // Agents below this version alert, e.g. "0.96.0"
+}
+
+// AlertReceiverConfig contains a notification destination
+type AlertReceiverConfig struct {
+	Name       string            `yaml:"name"`
+	Type       string            `yaml:"type"` // webhook, alertmanager
+	URL        string            `yaml:"url"`  // Alertmanager base URL for alertmanager receivers
+	Headers    map[string]string `yaml:"headers"`
+	Timeout    string            `yaml:"timeout"` // Duration string like "10s"
+	MaxRetries int               `yaml:"max_retries"`
+}
+
+// QueryCacheConfig contains the range query results cache configuration.
+// Range queries are split into aligned chunks; chunks older than the
+// freshness threshold are cached and only the recent tail is queried.
+type QueryCacheConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	MaxSize   string `yaml:"max_size"`   // Size string like "256MB"
+	ChunkSize string `yaml:"chunk_size"` // Duration string like "1h"
+	Freshness string `yaml:"freshness"`  // Duration string; newer chunks are always queried
+}
+
 // LoadConfig loads configuration from a YAML file
 func LoadConfig(path string) (*Config, error) {
 	// Read file
@@ -113,20 +257,34 @@ func DefaultConfig() *Config {
 				Path: "./data/app.db",
 			},
 			Telemetry: TelemetryStorageConfig{
-				Type: "duckdb",
-				Path: "./data/telemetry.db",
+				Type:     "duckdb",
+				Path:     "./data/telemetry.db",
+				ColdPath: "./data/cold",
+				HotDays:  2,
+
+				ReadConnections: 4,
+				QueryTimeout:    "30s",
 			},
 		},
 		Retention: RetentionConfig{
 			RawMetrics: "24h",
 			RawLogs:    "24h",
+			RawTraces:  "24h",
 			Rollups1m:  "7d",
 			Rollups5m:  "30d",
+			Rollups1h:  "90d",
+			Rollups1d:  "365d",
+			Usage:      "30d",
+			Interval:   "1h",
 		},
 		Rollups: RollupsConfig{
-			Enabled:    true,
-			Interval1m: "*/1 * * * *",
-			Interval5m: "*/5 * * * *",
+			Enabled:     true,
+			Interval1m:  "*/1 * * * *",
+			Interval5m:  "*/5 * * * *",
+			Interval1h:  "0 * * * *",
+			Interval1d:  "0 0 * * *",
+			Delay:       "30s",
+			MaxBackfill: "24h",
 		},
 		Logging: LoggingConfig{
 			Level:  "info",
@@ -137,6 +295,50 @@ func DefaultConfig() *Config {
 			Workers:   3,
 			Timeout:   "5s",
 		},
+		Usage: UsageConfig{
+			Enabled:       true,
+			BucketSize:    "1m",
+			FlushInterval: "30s",
+		},
+		Redaction: RedactionConfig{
+			Enabled: false,
+			Default: RedactionPolicyConfig{
+				Detectors:   []string{"email", "credit_card", "jwt", "bearer_token", "ipv4", "ipv6"},
+				Replacement: "[REDACTED]",
+			},
+		},
+		Sampling: SamplingConfig{
+			Tail: TailSamplingConfig{
+				Enabled:          false,
+				DecisionWait:     "10s",
+				MaxBufferedSpans: 100000,
+				DecidedCacheSize: 50000,
+				Policies: []SamplingPolicyConfig{
+					{Name: "errors", Type: "errors"},
+					{Name: "slow", Type: "latency", Threshold: "1s"},
+					{Name: "baseline", Type: "probabilistic", Rate: 0.1},
+				},
+			},
+		},
+		Alerting: AlertingConfig{
+			GroupBy:        []string{"alertname"},
+			GroupWait:      "30s",
+			GroupInterval:  "5m",
+			RepeatInterval: "4h",
+			Fleet: FleetAlertsConfig{
+				Enabled:          true,
+				Interval:         "1m",
+				For:              "2m",
+				OfflineAfter:     "5m",
+				NoTelemetryAfter: "10m",
+			},
+		},
+		QueryCache: QueryCacheConfig{
+			Enabled:   true,
+			MaxSize:   "256MB",
+			ChunkSize: "1h",
+			Freshness: "10m",
+		},
 	}
 }
 
@@ -170,3 +372,32 @@ func ParseDuration(s string) (time.Duration, error) {
 
 	return duration, nil
 }
+
+// ParseByteSize parses a size string like "512MB" or "10GB" using binary
+// multiples. A bare number is a size in bytes.
+func ParseByteSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"TB", 1 << 40},
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	size, err := strconv.ParseFloat(value, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size format: %s", s)
+	}
+	return int64(size * float64(multiplier)), nil
+}
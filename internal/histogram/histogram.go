@@ -0,0 +1,320 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package histogram merges metric distributions and estimates quantiles from
+// explicit bucket histograms, exponential histograms and summaries.
+package histogram
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+// Buckets is a contiguous range of exponential histogram buckets. Counts[i]
+// holds the bucket with index Offset+i.
+type Buckets struct {
+	Offset int32   `json:"offset"`
+	Counts []int64 `json:"counts"`
+}
+
+// Exponential is an OTLP exponential histogram. Bucket i of the positive range
+// covers (base^i, base^(i+1)] and bucket i of the negative range covers
+// [-base^(i+1), -base^i), where base = 2^(2^-Scale).
+type Exponential struct {
+	Scale         int32   `json:"scale"`
+	ZeroCount     int64   `json:"zero_count"`
+	ZeroThreshold float64 `json:"zero_threshold"`
+	Positive      Buckets `json:"positive"`
+	Negative      Buckets `json:"negative"`
+}
+
+// QuantileValue is a quantile reported by a summary
+type QuantileValue struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// Count returns the number of observations in the histogram
+func (e *Exponential) Count() int64 {
+	count := e.ZeroCount
+	for _, c := range e.Positive.Counts {
+		count += c
+	}
+	for _, c := range e.Negative.Counts {
+		count += c
+	}
+	return count
+}
+
+// MaxBuckets bounds the buckets in each range of a merged histogram. As in
+// the OpenTelemetry SDK, merges downscale until both ranges fit.
+const MaxBuckets = 160
+
+// minScale is the lowest scale, at which every float64 fits in a few buckets
+const minScale = -10
+
+// Merge adds another histogram to e. Histograms with different scales are
+// merged at the lower of the two, lowered further until the merged ranges
+// span at most MaxBuckets buckets. This loses resolution but no observations.
+func (e *Exponential) Merge(other Exponential) {
+	// An empty histogram takes the scale of the first one merged into it
+	if e.Count() == 0 {
+		e.Scale = other.Scale
+		e.Positive, e.Negative = Buckets{}, Buckets{}
+	}
+	scale := min(e.Scale, other.Scale)
+	for scale > minScale && (mergedSpan(e.Positive, e.Scale, other.Positive, other.Scale, scale) > MaxBuckets ||
+		mergedSpan(e.Negative, e.Scale, other.Negative, other.Scale, scale) > MaxBuckets) {
+		scale--
+	}
+	if scale < e.Scale {
+		e.Positive = e.Positive.downscale(e.Scale - scale)
+		e.Negative = e.Negative.downscale(e.Scale - scale)
+		e.Scale = scale
+	}
+
+	shift := other.Scale - e.Scale
+	for i, c := range other.Positive.Counts {
+		e.Positive.add((other.Positive.Offset+int32(i))>>shift, c)
+	}
+	for i, c := range other.Negative.Counts {
+		e.Negative.add((other.Negative.Offset+int32(i))>>shift, c)
+	}
+	e.ZeroCount += other.ZeroCount
+	if other.ZeroThreshold > e.ZeroThreshold {
+		e.ZeroThreshold = other.ZeroThreshold
+	}
+}
+
+// Delta returns the observations e holds beyond prev, an earlier cumulative
+// snapshot of the same series. It reports false if e cannot have grown from
+// prev, as after a reset.
+func (e Exponential) Delta(prev Exponential) (Exponential, bool) {
+	if prev.Scale < e.Scale || prev.ZeroThreshold != e.ZeroThreshold || prev.ZeroCount > e.ZeroCount {
+		return Exponential{}, false
+	}
+	positive, ok := e.Positive.subtract(prev.Positive.downscale(prev.Scale - e.Scale))
+	if !ok {
+		return Exponential{}, false
+	}
+	negative, ok := e.Negative.subtract(prev.Negative.downscale(prev.Scale - e.Scale))
+	if !ok {
+		return Exponential{}, false
+	}
+	return Exponential{
+		Scale:         e.Scale,
+		ZeroCount:     e.ZeroCount - prev.ZeroCount,
+		ZeroThreshold: e.ZeroThreshold,
+		Positive:      positive,
+		Negative:      negative,
+	}, true
+}
+
+// Quantile estimates the q-quantile by linear interpolation within the
+// bucket holding the target rank. It returns NaN for an empty histogram.
+func (e *Exponential) Quantile(q float64) float64 {
+	total := e.Count()
+	if total == 0 || math.IsNaN(q) {
+		return math.NaN()
+	}
+	if q < 0 {
+		return math.Inf(-1)
+	}
+	if q > 1 {
+		return math.Inf(1)
+	}
+
+	rank := q * float64(total)
+	var cumulative float64
+	// Negative buckets hold the smallest values, highest index first
+	for i := len(e.Negative.Counts) - 1; i >= 0; i-- {
+		count := float64(e.Negative.Counts[i])
+		if count > 0 && cumulative+count >= rank {
+			index := e.Negative.Offset + int32(i)
+			lower, upper := -bucketBoundary(index+1, e.Scale), -bucketBoundary(index, e.Scale)
+			return interpolate(lower, upper, rank-cumulative, count)
+		}
+		cumulative += count
+	}
+	if count := float64(e.ZeroCount); count > 0 && cumulative+count >= rank {
+		return interpolate(-e.ZeroThreshold, e.ZeroThreshold, rank-cumulative, count)
+	}
+	cumulative += float64(e.ZeroCount)
+	for i, c := range e.Positive.Counts {
+		count := float64(c)
+		if count > 0 && cumulative+count >= rank {
+			index := e.Positive.Offset + int32(i)
+			return interpolate(bucketBoundary(index, e.Scale), bucketBoundary(index+1, e.Scale), rank-cumulative, count)
+		}
+		cumulative += count
+	}
+	// Rounding left the rank past the last bucket
+	if n := len(e.Positive.Counts); n > 0 {
+		return bucketBoundary(e.Positive.Offset+int32(n), e.Scale)
+	}
+	return e.ZeroThreshold
+}
+
+// ExplicitQuantile estimates the q-quantile of an explicit bucket histogram.
+// Bucket i counts values up to bounds[i] and the last bucket has no upper
+// bound. As in Prometheus, the first bucket starts at zero when its upper
+// bound is positive, and ranks in the last bucket return the highest bound.
+// It returns NaN when there are no observations or the layout is invalid.
+func ExplicitQuantile(q float64, counts []int64, bounds []float64) float64 {
+	if len(counts) == 0 || len(counts) != len(bounds)+1 || math.IsNaN(q) {
+		return math.NaN()
+	}
+	var total int64
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 {
+		return math.NaN()
+	}
+	if q < 0 {
+		return math.Inf(-1)
+	}
+	if q > 1 {
+		return math.Inf(1)
+	}
+
+	rank := q * float64(total)
+	var cumulative float64
+	for i, c := range counts {
+		count := float64(c)
+		if count == 0 || cumulative+count < rank {
+			cumulative += count
+			continue
+		}
+		if i == len(bounds) {
+			return bounds[len(bounds)-1]
+		}
+		upper := bounds[i]
+		lower := 0.0
+		if i > 0 {
+			lower = bounds[i-1]
+		} else if upper <= 0 {
+			return upper
+		}
+		return interpolate(lower, upper, rank-cumulative, count)
+	}
+	if len(bounds) == 0 {
+		return math.NaN()
+	}
+	return bounds[len(bounds)-1]
+}
+
+// SummaryQuantile estimates the q-quantile from the quantiles a summary
+// reported, interpolating linearly between the nearest two. Quantiles outside
+// the reported range return the nearest reported value.
+func SummaryQuantile(q float64, values []QuantileValue) float64 {
+	if len(values) == 0 || math.IsNaN(q) {
+		return math.NaN()
+	}
+	sorted := make([]QuantileValue, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Quantile < sorted[j].Quantile })
+
+	if q <= sorted[0].Quantile {
+		return sorted[0].Value
+	}
+	for i := 1; i < len(sorted); i++ {
+		if q <= sorted[i].Quantile {
+			prev, next := sorted[i-1], sorted[i]
+			if next.Quantile == prev.Quantile {
+				return next.Value
+			}
+			return prev.Value + (next.Value-prev.Value)*(q-prev.Quantile)/(next.Quantile-prev.Quantile)
+		}
+	}
+	return sorted[len(sorted)-1].Value
+}
+
+// bucketBoundary returns the lower boundary of a positive bucket index
+func bucketBoundary(index, scale int32) float64 {
+	return math.Exp2(float64(index) * math.Exp2(-float64(scale)))
+}
+
+// interpolate returns the value at rank within a bucket of count observations
+func interpolate(lower, upper, rank, count float64) float64 {
+	return lower + (upper-lower)*(rank/count)
+}
+
+// add adds count observations to the bucket with the given index, growing
+// the range as needed
+func (b *Buckets) add(index int32, count int64) {
+	if count == 0 {
+		return
+	}
+	if len(b.Counts) == 0 {
+		b.Offset = index
+		b.Counts = []int64{count}
+		return
+	}
+	if index < b.Offset {
+		grown := make([]int64, int(b.Offset-index)+len(b.Counts))
+		copy(grown[b.Offset-index:], b.Counts)
+		b.Counts = grown
+		b.Offset = index
+	}
+	if i := int(index - b.Offset); i >= len(b.Counts) {
+		b.Counts = append(b.Counts, make([]int64, i-len(b.Counts)+1)...)
+	}
+	b.Counts[index-b.Offset] += count
+}
+
+// subtract returns the counts of b less those of prev, or false if prev
+// holds observations b does not
+func (b Buckets) subtract(prev Buckets) (Buckets, bool) {
+	out := Buckets{Offset: b.Offset, Counts: slices.Clone(b.Counts)}
+	for i, c := range prev.Counts {
+		if c == 0 {
+			continue
+		}
+		j := int(prev.Offset + int32(i) - b.Offset)
+		if j < 0 || j >= len(out.Counts) || out.Counts[j] < c {
+			return Buckets{}, false
+		}
+		out.Counts[j] -= c
+	}
+	return out, true
+}
+
+// mergedSpan returns the number of buckets spanned by a and b, at scales
+// aScale and bScale, once both are downscaled to scale
+func mergedSpan(a Buckets, aScale int32, b Buckets, bScale int32, scale int32) int64 {
+	var low, high int32
+	empty := true
+	for _, r := range []struct {
+		buckets Buckets
+		shift   int32
+	}{{a, aScale - scale}, {b, bScale - scale}} {
+		if len(r.buckets.Counts) == 0 {
+			continue
+		}
+		first := r.buckets.Offset >> r.shift
+		last := (r.buckets.Offset + int32(len(r.buckets.Counts)) - 1) >> r.shift
+		if empty || first < low {
+			low = first
+		}
+		if empty || last > high {
+			high = last
+		}
+		empty = false
+	}
+	if empty {
+		return 0
+	}
+	return int64(high) - int64(low) + 1
+}
+
+// downscale returns the buckets at a scale lower by shift. Each bucket merges
+// into the one at index >> shift.
+func (b Buckets) downscale(shift int32) Buckets {
+	var out Buckets
+	for i, c := range b.Counts {
+		out.add((b.Offset+int32(i))>>shift, c)
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package histogram
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponential_Quantile(t *testing.T) {
+	// At scale 0 positive bucket i covers (2^i, 2^(i+1)]
+	h := Exponential{
+		Scale:    0,
+		Positive: Buckets{Offset: 0, Counts: []int64{2, 2}},
+		Negative: Buckets{Offset: 1, Counts: []int64{1}},
+	}
+	h.ZeroCount = 1
+
+	assert.Equal(t, int64(6), h.Count())
+	assert.InDelta(t, -3, h.Quantile(0.5/6), 1e-9, "middle of the [-4, -2) bucket")
+	assert.InDelta(t, 1.5, h.Quantile(0.5), 1e-9, "middle of the (1, 2] bucket")
+	assert.InDelta(t, 4, h.Quantile(1), 1e-9)
+	assert.True(t, math.IsInf(h.Quantile(1.5), 1))
+	assert.True(t, math.IsNaN((&Exponential{}).Quantile(0.5)))
+}
+
+func TestExponential_MergeAcrossScales(t *testing.T) {
+	var merged Exponential
+	merged.Merge(Exponential{Scale: 1, Positive: Buckets{Offset: 2, Counts: []int64{1, 1, 1}}})
+	assert.Equal(t, int32(1), merged.Scale, "an empty histogram adopts the first scale")
+
+	// Scale 1 buckets 2, 3 and 4 become scale 0 buckets 1, 1 and 2
+	merged.Merge(Exponential{Scale: 0, ZeroCount: 2, ZeroThreshold: 0.5, Positive: Buckets{Offset: 0, Counts: []int64{4}}})
+	assert.Equal(t, int32(0), merged.Scale)
+	assert.Equal(t, Buckets{Offset: 0, Counts: []int64{4, 2, 1}}, merged.Positive)
+	assert.Equal(t, int64(2), merged.ZeroCount)
+	assert.Equal(t, 0.5, merged.ZeroThreshold)
+
+	// A finer histogram is downscaled into the merged one
+	merged.Merge(Exponential{Scale: 2, Negative: Buckets{Offset: -5, Counts: []int64{3}}})
+	assert.Equal(t, Buckets{Offset: -2, Counts: []int64{3}}, merged.Negative)
+	assert.Equal(t, int64(12), merged.Count())
+}
+
+func TestExponential_MergeBoundsBuckets(t *testing.T) {
+	var merged Exponential
+	merged.Merge(Exponential{Scale: 3, Positive: Buckets{Offset: 0, Counts: []int64{1}}})
+
+	// An outlier far from the merged range downscales instead of allocating
+	// a bucket for every index in between
+	merged.Merge(Exponential{Scale: 3, Positive: Buckets{Offset: 4000, Counts: []int64{1}}})
+	assert.LessOrEqual(t, len(merged.Positive.Counts), MaxBuckets)
+	assert.Less(t, merged.Scale, int32(3))
+	assert.Equal(t, int64(2), merged.Count())
+	assert.Greater(t, merged.Quantile(1), math.Exp2(400), "the outlier keeps its magnitude")
+
+	// Histograms that fit keep their scale
+	var fits Exponential
+	fits.Merge(Exponential{Scale: 3, Positive: Buckets{Offset: 0, Counts: []int64{1}}})
+	fits.Merge(Exponential{Scale: 3, Positive: Buckets{Offset: MaxBuckets - 1, Counts: []int64{1}}})
+	assert.Equal(t, int32(3), fits.Scale)
+	assert.Len(t, fits.Positive.Counts, MaxBuckets)
+}
+
+func TestExponential_Delta(t *testing.T) {
+	prev := Exponential{Scale: 1, ZeroCount: 1, Positive: Buckets{Offset: 2, Counts: []int64{1, 2}}}
+	cur := Exponential{Scale: 1, ZeroCount: 3, Positive: Buckets{Offset: 1, Counts: []int64{1, 1, 5}}}
+
+	delta, ok := cur.Delta(prev)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), delta.ZeroCount)
+	assert.Equal(t, Buckets{Offset: 1, Counts: []int64{1, 0, 3}}, delta.Positive)
+
+	// A snapshot downscaled since prev is compared at its own scale
+	downscaled := Exponential{Scale: 0, ZeroCount: 1, Positive: Buckets{Offset: 1, Counts: []int64{4}}}
+	delta, ok = downscaled.Delta(prev)
+	assert.True(t, ok)
+	assert.Equal(t, Buckets{Offset: 1, Counts: []int64{1}}, delta.Positive)
+
+	// Fewer observations than before are a reset
+	_, ok = prev.Delta(cur)
+	assert.False(t, ok)
+}
+
+func TestExplicitQuantile(t *testing.T) {
+	counts := []int64{10, 20, 10, 0}
+	bounds := []float64{1, 2, 4}
+
+	assert.InDelta(t, 0.5, ExplicitQuantile(0.125, counts, bounds), 1e-9)
+	assert.InDelta(t, 1.5, ExplicitQuantile(0.5, counts, bounds), 1e-9)
+	assert.InDelta(t, 4, ExplicitQuantile(1, counts, bounds), 1e-9)
+	assert.Equal(t, 4.0, ExplicitQuantile(0.99, []int64{0, 0, 0, 5}, bounds), "overflow bucket returns the highest bound")
+	assert.True(t, math.IsNaN(ExplicitQuantile(0.5, []int64{1, 2}, bounds)), "mismatched layout")
+	assert.True(t, math.IsNaN(ExplicitQuantile(0.5, []int64{0, 0, 0, 0}, bounds)))
+}
+
+func TestSummaryQuantile(t *testing.T) {
+	values := []QuantileValue{{Quantile: 0.99, Value: 90}, {Quantile: 0.5, Value: 10}, {Quantile: 0.9, Value: 50}}
+
+	assert.Equal(t, 10.0, SummaryQuantile(0.5, values))
+	assert.InDelta(t, 30, SummaryQuantile(0.7, values), 1e-9)
+	assert.Equal(t, 10.0, SummaryQuantile(0.1, values))
+	assert.Equal(t, 90.0, SummaryQuantile(1, values))
+	assert.True(t, math.IsNaN(SummaryQuantile(0.5, nil)))
+}
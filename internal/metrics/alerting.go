@@ -0,0 +1,23 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+// AlertingMetrics tracks alert rule evaluation and notification delivery
+type AlertingMetrics struct {
+	Evaluations          Counter `metric:"alert_rule_evaluations_total" tags:"component=alerting" help:"Total number of alert rule evaluations"`
+	EvaluationFailures   Counter `metric:"alert_rule_evaluation_failures_total" tags:"component=alerting" help:"Total number of failed alert rule evaluations"`
+	EvaluationDuration   Timer   `metric:"alert_rule_evaluation_duration_seconds" tags:"component=alerting" help:"Alert rule evaluation duration in seconds"`
+	ActiveRules          Gauge   `metric:"alert_rules" tags:"component=alerting" help:"Current number of enabled alert rules"`
+	FiringAlerts         Gauge   `metric:"alerts_firing" tags:"component=alerting" help:"Current number of firing alerts"`
+	Notifications        Counter `metric:"alert_notifications_total" tags:"component=alerting" help:"Total number of alert notifications delivered"`
+	NotificationFailures Counter `metric:"alert_notification_failures_total" tags:"component=alerting" help:"Total number of alert notifications that failed after all retries"`
+	NotificationRetries  Counter `metric:"alert_notification_retries_total" tags:"component=alerting" help:"Total number of retried alert notification attempts"`
+}
+
+// NewAlertingMetrics creates and initializes alerting metrics
+func NewAlertingMetrics(factory Factory) *AlertingMetrics {
+	m := &AlertingMetrics{}
+	MustInit(m, factory, nil)
+	return m
+}
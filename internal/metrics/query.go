@@ -0,0 +1,20 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+// QueryCacheMetrics tracks the range query results cache
+type QueryCacheMetrics struct {
+	Hits      Counter `metric:"query_cache_hits_total" tags:"component=query" help:"Total number of range query chunks served from the results cache"`
+	Misses    Counter `metric:"query_cache_misses_total" tags:"component=query" help:"Total number of range query chunks not found in the results cache"`
+	Evictions Counter `metric:"query_cache_evictions_total" tags:"component=query" help:"Total number of chunks evicted from the results cache"`
+	Entries   Gauge   `metric:"query_cache_entries" tags:"component=query" help:"Current number of chunks in the results cache"`
+	Bytes     Gauge   `metric:"query_cache_bytes" tags:"component=query" help:"Current estimated size of the results cache in bytes"`
+}
+
+// NewQueryCacheMetrics creates and initializes query cache metrics
+func NewQueryCacheMetrics(factory Factory) *QueryCacheMetrics {
+	m := &QueryCacheMetrics{}
+	MustInit(m, factory, nil)
+	return m
+}
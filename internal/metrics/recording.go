@@ -0,0 +1,20 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+// RecordingRuleMetrics tracks the evaluation of recording rules
+type RecordingRuleMetrics struct {
+	Evaluations        Counter `metric:"recording_rule_evaluations_total" tags:"component=recording" help:"Total number of recording rule evaluations"`
+	Failures           Counter `metric:"recording_rule_evaluation_failures_total" tags:"component=recording" help:"Total number of failed recording rule evaluations"`
+	SamplesWritten     Counter `metric:"recording_rule_samples_total" tags:"component=recording" help:"Total number of samples written by recording rules"`
+	EvaluationDuration Timer   `metric:"recording_rule_evaluation_duration_seconds" tags:"component=recording" help:"Recording rule evaluation duration in seconds"`
+	ActiveRules        Gauge   `metric:"recording_rules" tags:"component=recording" help:"Current number of enabled recording rules"`
+}
+
+// NewRecordingRuleMetrics creates and initializes recording rule metrics
+func NewRecordingRuleMetrics(factory Factory) *RecordingRuleMetrics {
+	m := &RecordingRuleMetrics{}
+	MustInit(m, factory, nil)
+	return m
+}
@@ -0,0 +1,25 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+// RedactionMetrics tracks values masked by the PII redaction processor
+type RedactionMetrics struct {
+	EmailRedactions       Counter `metric:"redactions_total" tags:"component=redaction,detector=email" help:"Total number of values redacted by a detector"`
+	CreditCardRedactions  Counter `metric:"redactions_total" tags:"component=redaction,detector=credit_card" help:"Total number of values redacted by a detector"`
+	JWTRedactions         Counter `metric:"redactions_total" tags:"component=redaction,detector=jwt" help:"Total number of values redacted by a detector"`
+	BearerTokenRedactions Counter `metric:"redactions_total" tags:"component=redaction,detector=bearer_token" help:"Total number of values redacted by a detector"`
+	IPv4Redactions        Counter `metric:"redactions_total" tags:"component=redaction,detector=ipv4" help:"Total number of values redacted by a detector"`
+	IPv6Redactions        Counter `metric:"redactions_total" tags:"component=redaction,detector=ipv6" help:"Total number of values redacted by a detector"`
+	CustomRedactions      Counter `metric:"redactions_total" tags:"component=redaction,detector=custom" help:"Total number of values redacted by a detector"`
+
+	LogsRedacted  Counter `metric:"redaction_records_total" tags:"component=redaction,signal=logs" help:"Total number of records with at least one redaction"`
+	SpansRedacted Counter `metric:"redaction_records_total" tags:"component=redaction,signal=traces" help:"Total number of records with at least one redaction"`
+}
+
+// NewRedactionMetrics creates and initializes redaction metrics
+func NewRedactionMetrics(factory Factory) *RedactionMetrics {
+	m := &RedactionMetrics{}
+	MustInit(m, factory, nil)
+	return m
+}
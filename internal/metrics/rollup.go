@@ -0,0 +1,26 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+// RollupMetrics tracks the rollup scheduler
+type RollupMetrics struct {
+	Windows1m Counter `metric:"rollup_windows_total" tags:"component=rollup,interval=1m" help:"Total number of rollup windows generated per interval"`
+	Windows5m Counter `metric:"rollup_windows_total" tags:"component=rollup,interval=5m" help:"Total number of rollup windows generated per interval"`
+	Windows1h Counter `metric:"rollup_windows_total" tags:"component=rollup,interval=1h" help:"Total number of rollup windows generated per interval"`
+	Windows1d Counter `metric:"rollup_windows_total" tags:"component=rollup,interval=1d" help:"Total number of rollup windows generated per interval"`
+
+	Failures1m Counter `metric:"rollup_failures_total" tags:"component=rollup,interval=1m" help:"Total number of failed rollup windows per interval"`
+	Failures5m Counter `metric:"rollup_failures_total" tags:"component=rollup,interval=5m" help:"Total number of failed rollup windows per interval"`
+	Failures1h Counter `metric:"rollup_failures_total" tags:"component=rollup,interval=1h" help:"Total number of failed rollup windows per interval"`
+	Failures1d Counter `metric:"rollup_failures_total" tags:"component=rollup,interval=1d" help:"Total number of failed rollup windows per interval"`
+
+	Backfilled Counter `metric:"rollup_backfilled_windows_total" tags:"component=rollup" help:"Total number of rollup windows generated after their scheduled run was missed"`
+}
+
+// NewRollupMetrics creates and initializes rollup metrics
+func NewRollupMetrics(factory Factory) *RollupMetrics {
+	m := &RollupMetrics{}
+	MustInit(m, factory, nil)
+	return m
+}
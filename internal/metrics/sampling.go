@@ -0,0 +1,31 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+// SamplingMetrics tracks decisions made by the tail-based trace sampler
+type SamplingMetrics struct {
+	TracesKept    Counter `metric:"tail_sampling_traces_total" tags:"component=sampling,decision=kept" help:"Total number of traces decided by the tail sampler"`
+	TracesDropped Counter `metric:"tail_sampling_traces_total" tags:"component=sampling,decision=dropped" help:"Total number of traces decided by the tail sampler"`
+	SpansKept     Counter `metric:"tail_sampling_spans_total" tags:"component=sampling,decision=kept" help:"Total number of spans decided by the tail sampler"`
+	SpansDropped  Counter `metric:"tail_sampling_spans_total" tags:"component=sampling,decision=dropped" help:"Total number of spans decided by the tail sampler"`
+
+	KeptByErrors        Counter `metric:"tail_sampling_policy_matches_total" tags:"component=sampling,policy=errors" help:"Total number of kept traces per matching policy type"`
+	KeptByLatency       Counter `metric:"tail_sampling_policy_matches_total" tags:"component=sampling,policy=latency" help:"Total number of kept traces per matching policy type"`
+	KeptByAttribute     Counter `metric:"tail_sampling_policy_matches_total" tags:"component=sampling,policy=attribute" help:"Total number of kept traces per matching policy type"`
+	KeptByProbabilistic Counter `metric:"tail_sampling_policy_matches_total" tags:"component=sampling,policy=probabilistic" help:"Total number of kept traces per matching policy type"`
+
+	EarlyDecisions Counter `metric:"tail_sampling_early_decisions_total" tags:"component=sampling" help:"Total number of traces decided before the decision window because the buffer was full"`
+	LateSpans      Counter `metric:"tail_sampling_late_spans_total" tags:"component=sampling" help:"Total number of spans received after their trace was decided"`
+	WriteErrors    Counter `metric:"tail_sampling_write_errors_total" tags:"component=sampling" help:"Total number of failed writes of sampled spans"`
+
+	BufferedTraces Gauge `metric:"tail_sampling_buffered_traces" tags:"component=sampling" help:"Current number of traces waiting for a decision"`
+	BufferedSpans  Gauge `metric:"tail_sampling_buffered_spans" tags:"component=sampling" help:"Current number of spans waiting for a decision"`
+}
+
+// NewSamplingMetrics creates and initializes tail sampling metrics
+func NewSamplingMetrics(factory Factory) *SamplingMetrics {
+	m := &SamplingMetrics{}
+	MustInit(m, factory, nil)
+	return m
+}
@@ -0,0 +1,22 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+// StorageMetrics tracks telemetry store connections
+type StorageMetrics struct {
+	WriterLockWait Timer `metric:"storage_writer_lock_wait_seconds" tags:"component=storage" help:"Time spent waiting for the telemetry store writer connection"`
+	ReaderWait     Timer `metric:"storage_reader_wait_seconds" tags:"component=storage" help:"Time spent waiting for a telemetry store read connection"`
+
+	AppendedRows Counter `metric:"storage_appended_rows_total" tags:"component=storage" help:"Total number of rows appended to the telemetry store"`
+
+	QueryTimeouts      Counter `metric:"storage_query_timeouts_total" tags:"component=storage" help:"Total number of telemetry queries that exceeded the query timeout"`
+	QueryCancellations Counter `metric:"storage_query_cancellations_total" tags:"component=storage" help:"Total number of telemetry queries cancelled by the caller"`
+}
+
+// NewStorageMetrics creates and initializes storage metrics
+func NewStorageMetrics(factory Factory) *StorageMetrics {
+	m := &StorageMetrics{}
+	MustInit(m, factory, nil)
+	return m
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package otlp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+)
+
+// Attribute values keep the type of the OTLP AnyValue they were parsed from:
+// string, bool, int64, float64, []byte, []interface{} or map[string]interface{}.
+
+// AttributeString returns the string form of an attribute value. Strings are
+// returned as is, scalars are formatted and arrays and maps are JSON-encoded.
+func AttributeString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case json.Number:
+		return v.String()
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(encoded)
+	}
+}
+
+// AttributeFloat returns the numeric value of an attribute. Numeric strings
+// are accepted so data stored before attributes were typed still compares.
+func AttributeFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// StringAttribute returns an attribute as a string if it is present
+func StringAttribute(attrs map[string]interface{}, key string) (string, bool) {
+	value, ok := attrs[key]
+	if !ok {
+		return "", false
+	}
+	return AttributeString(value), true
+}
+
+// CloneAttributes returns a shallow copy of an attribute map
+func CloneAttributes(attrs map[string]interface{}) map[string]interface{} {
+	cloned := make(map[string]interface{}, len(attrs))
+	for k, v := range attrs {
+		cloned[k] = v
+	}
+	return cloned
+}
+
+// StringifyAttributes returns the string form of every attribute, for use as labels
+func StringifyAttributes(attrs map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		result[k] = AttributeString(v)
+	}
+	return result
+}
@@ -1,8 +1,8 @@
 package parser
 
 import (
-	"bytes"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/getlawrence/lawrence-oss/internal/otlp"
@@ -28,12 +28,6 @@ type OTLPTracesData struct {
 	Traces []otlp.TraceData
 }
 
-type OTLPMetricsData struct {
-	Sums       []otlp.MetricSumData
-	Gauges     []otlp.MetricGaugeData
-	Histograms []otlp.MetricHistogramData
-}
-
 type OTLPLogsData struct {
 	Logs []otlp.LogData
 }
@@ -124,15 +118,13 @@ func (p *OTLPParser) ParseTraces(data []byte) ([]otlp.TraceData, error) {
 }
 
 // ParseMetrics parses OTLP metrics data
-func (p *OTLPParser) ParseMetrics(data []byte) ([]otlp.MetricSumData, []otlp.MetricGaugeData, []otlp.MetricHistogramData, error) {
+func (p *OTLPParser) ParseMetrics(data []byte) (*otlp.MetricsData, error) {
 	var request colmetricspb.ExportMetricsServiceRequest
 	if err := proto.Unmarshal(data, &request); err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to unmarshal metrics: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal metrics: %w", err)
 	}
 
-	var sumMetrics []otlp.MetricSumData
-	var gaugeMetrics []otlp.MetricGaugeData
-	var histogramMetrics []otlp.MetricHistogramData
+	metrics := &otlp.MetricsData{}
 
 	for _, resourceMetrics := range request.ResourceMetrics {
 		resource := resourceMetrics.Resource
@@ -178,7 +170,7 @@ func (p *OTLPParser) ParseMetrics(data []byte) ([]otlp.MetricSumData, []otlp.Met
 							GroupID:                groupID,
 							GroupName:              groupName,
 						}
-						sumMetrics = append(sumMetrics, metricData)
+						metrics.Sums = append(metrics.Sums, metricData)
 					}
 
 				case *metricspb.Metric_Gauge:
@@ -208,7 +200,7 @@ func (p *OTLPParser) ParseMetrics(data []byte) ([]otlp.MetricSumData, []otlp.Met
 							GroupID:                groupID,
 							GroupName:              groupName,
 						}
-						gaugeMetrics = append(gaugeMetrics, metricData)
+						metrics.Gauges = append(metrics.Gauges, metricData)
 					}
 
 				case *metricspb.Metric_Histogram:
@@ -242,14 +234,87 @@ func (p *OTLPParser) ParseMetrics(data []byte) ([]otlp.MetricSumData, []otlp.Met
 							GroupID:                groupID,
 							GroupName:              groupName,
 						}
-						histogramMetrics = append(histogramMetrics, metricData)
+						metrics.Histograms = append(metrics.Histograms, metricData)
+					}
+
+				case *metricspb.Metric_ExponentialHistogram:
+					histogram := metric.GetExponentialHistogram()
+					for _, dp := range histogram.DataPoints {
+						attrs := attributesToMap(dp.Attributes)
+						metricData := otlp.MetricExponentialHistogramData{
+							ResourceAttributes:     resourceAttrs,
+							ResourceSchemaUrl:      "",
+							ScopeName:              scope.Name,
+							ScopeVersion:           scope.Version,
+							ScopeAttributes:        scopeAttrs,
+							ScopeDroppedAttrCount:  scope.DroppedAttributesCount,
+							ScopeSchemaUrl:         "",
+							ServiceName:            serviceName,
+							MetricName:             metric.Name,
+							MetricDescription:      metric.Description,
+							MetricUnit:             metric.Unit,
+							Attributes:             attrs,
+							StartTimeUnix:          time.Unix(0, int64(dp.StartTimeUnixNano)),
+							TimeUnix:               time.Unix(0, int64(dp.TimeUnixNano)),
+							Count:                  dp.Count,
+							Sum:                    dp.GetSum(),
+							Scale:                  dp.Scale,
+							ZeroCount:              dp.ZeroCount,
+							ZeroThreshold:          dp.ZeroThreshold,
+							PositiveOffset:         dp.GetPositive().GetOffset(),
+							PositiveBucketCounts:   dp.GetPositive().GetBucketCounts(),
+							NegativeOffset:         dp.GetNegative().GetOffset(),
+							NegativeBucketCounts:   dp.GetNegative().GetBucketCounts(),
+							Flags:                  uint32(dp.Flags),
+							Min:                    dp.GetMin(),
+							Max:                    dp.GetMax(),
+							AggregationTemporality: int32(histogram.AggregationTemporality),
+							AgentID:                extractedAgentID,
+							GroupID:                groupID,
+							GroupName:              groupName,
+						}
+						metrics.ExponentialHistograms = append(metrics.ExponentialHistograms, metricData)
+					}
+
+				case *metricspb.Metric_Summary:
+					summary := metric.GetSummary()
+					for _, dp := range summary.DataPoints {
+						attrs := attributesToMap(dp.Attributes)
+						quantiles := make([]otlp.SummaryQuantileValue, len(dp.QuantileValues))
+						for i, qv := range dp.QuantileValues {
+							quantiles[i] = otlp.SummaryQuantileValue{Quantile: qv.Quantile, Value: qv.Value}
+						}
+						metricData := otlp.MetricSummaryData{
+							ResourceAttributes:    resourceAttrs,
+							ResourceSchemaUrl:     "",
+							ScopeName:             scope.Name,
+							ScopeVersion:          scope.Version,
+							ScopeAttributes:       scopeAttrs,
+							ScopeDroppedAttrCount: scope.DroppedAttributesCount,
+							ScopeSchemaUrl:        "",
+							ServiceName:           serviceName,
+							MetricName:            metric.Name,
+							MetricDescription:     metric.Description,
+							MetricUnit:            metric.Unit,
+							Attributes:            attrs,
+							StartTimeUnix:         time.Unix(0, int64(dp.StartTimeUnixNano)),
+							TimeUnix:              time.Unix(0, int64(dp.TimeUnixNano)),
+							Count:                 dp.Count,
+							Sum:                   dp.Sum,
+							QuantileValues:        quantiles,
+							Flags:                 uint32(dp.Flags),
+							AgentID:               extractedAgentID,
+							GroupID:               groupID,
+							GroupName:             groupName,
+						}
+						metrics.Summaries = append(metrics.Summaries, metricData)
 					}
 				}
 			}
 		}
 	}
 
-	return sumMetrics, gaugeMetrics, histogramMetrics, nil
+	return metrics, nil
 }
 
 // ParseLogs parses OTLP logs data
@@ -308,8 +373,9 @@ func (p *OTLPParser) ParseLogs(data []byte) ([]otlp.LogData, error) {
 
 // Helper functions
 
-func attributesToMap(attrs []*commonpb.KeyValue) map[string]string {
-	result := make(map[string]string)
+// attributesToMap converts OTLP key-values to a map of typed values
+func attributesToMap(attrs []*commonpb.KeyValue) map[string]interface{} {
+	result := make(map[string]interface{}, len(attrs))
 	for _, attr := range attrs {
 		if attr.Key != "" {
 			result[attr.Key] = getAttributeValue(attr.Value)
@@ -318,48 +384,50 @@ func attributesToMap(attrs []*commonpb.KeyValue) map[string]string {
 	return result
 }
 
-func getAttributeValue(value *commonpb.AnyValue) string {
+// getAttributeValue converts an OTLP AnyValue to its Go representation:
+// string, bool, int64, float64, []byte, []interface{} or map[string]interface{}.
+// NaN and infinite doubles have no JSON form and are kept as strings.
+func getAttributeValue(value *commonpb.AnyValue) interface{} {
+	if value == nil {
+		return nil
+	}
 	switch v := value.Value.(type) {
 	case *commonpb.AnyValue_StringValue:
 		return v.StringValue
 	case *commonpb.AnyValue_BoolValue:
-		if v.BoolValue {
-			return "true"
-		}
-		return "false"
+		return v.BoolValue
 	case *commonpb.AnyValue_IntValue:
-		return fmt.Sprintf("%d", v.IntValue)
+		return v.IntValue
 	case *commonpb.AnyValue_DoubleValue:
-		return fmt.Sprintf("%f", v.DoubleValue)
+		if math.IsNaN(v.DoubleValue) || math.IsInf(v.DoubleValue, 0) {
+			return otlp.AttributeString(v.DoubleValue)
+		}
+		return v.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return v.BytesValue
 	case *commonpb.AnyValue_ArrayValue:
-		// Convert array to string representation
-		var items []string
-		for _, item := range v.ArrayValue.Values {
+		items := make([]interface{}, 0, len(v.ArrayValue.GetValues()))
+		for _, item := range v.ArrayValue.GetValues() {
 			items = append(items, getAttributeValue(item))
 		}
-		return fmt.Sprintf("[%s]", joinStrings(items, ","))
+		return items
 	case *commonpb.AnyValue_KvlistValue:
-		// Convert key-value list to string representation
-		var pairs []string
-		for _, kv := range v.KvlistValue.Values {
-			pairs = append(pairs, fmt.Sprintf("%s=%s", kv.Key, getAttributeValue(kv.Value)))
-		}
-		return fmt.Sprintf("{%s}", joinStrings(pairs, ","))
+		return attributesToMap(v.KvlistValue.GetValues())
 	default:
-		return ""
+		return nil
 	}
 }
 
-func getServiceName(attrs map[string]string) string {
-	if serviceName, exists := attrs["service.name"]; exists {
+func getServiceName(attrs map[string]interface{}) string {
+	if serviceName, exists := otlp.StringAttribute(attrs, "service.name"); exists {
 		return serviceName
 	}
 	return "unknown-service"
 }
 
 // getAgentID extracts agent ID from service.instance.id attribute
-func getAgentID(attrs map[string]string) string {
-	if agentID, exists := attrs["service.instance.id"]; exists && agentID != "" {
+func getAgentID(attrs map[string]interface{}) string {
+	if agentID, exists := otlp.StringAttribute(attrs, "service.instance.id"); exists && agentID != "" {
 		return agentID
 	}
 	return "default"
@@ -367,16 +435,16 @@ func getAgentID(attrs map[string]string) string {
 
 // extractGroupInfo extracts group ID and name from resource attributes
 // OSS version: simplified without backend group resolution
-func extractGroupInfo(attrs map[string]string) (groupID, groupName string) {
+func extractGroupInfo(attrs map[string]interface{}) (groupID, groupName string) {
 	// Check if we have a group_id in the attributes
-	if id, exists := attrs["agent.group_id"]; exists && id != "" {
+	if id, exists := otlp.StringAttribute(attrs, "agent.group_id"); exists && id != "" {
 		if len(id) >= 8 && len(id) <= 128 {
 			groupID = id
 		}
 	}
 
 	// Check if we have a group_name for display
-	if name, exists := attrs["agent.group_name"]; exists && name != "" {
+	if name, exists := otlp.StringAttribute(attrs, "agent.group_name"); exists && name != "" {
 		if len(name) <= 128 && isValidGroupName(name) {
 			groupName = name
 		}
@@ -435,23 +503,6 @@ func getLogBody(logRecord *logspb.LogRecord) string {
 	return ""
 }
 
-func joinStrings(strs []string, sep string) string {
-	if len(strs) == 0 {
-		return ""
-	}
-	if len(strs) == 1 {
-		return strs[0]
-	}
-
-	var buf bytes.Buffer
-	buf.WriteString(strs[0])
-	for i := 1; i < len(strs); i++ {
-		buf.WriteString(sep)
-		buf.WriteString(strs[i])
-	}
-	return buf.String()
-}
-
 // getNumberDataPointValue extracts the value from a NumberDataPoint
 func getNumberDataPointValue(dp *metricspb.NumberDataPoint) float64 {
 	if dp == nil {
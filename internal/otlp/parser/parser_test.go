@@ -4,6 +4,7 @@
 package parser
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -12,6 +13,8 @@ import (
 	"go.uber.org/zap"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+
 	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
 	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
@@ -128,6 +131,52 @@ func TestParseTraces_Success(t *testing.T) {
 	assert.NotEmpty(t, trace.SpanId)
 }
 
+func TestParseTraces_TypedAttributes(t *testing.T) {
+	parser := setupParserTest()
+
+	request := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{Attributes: makeResourceAttributes()},
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Scope: &commonpb.InstrumentationScope{Name: "test-scope"},
+				Spans: []*tracepb.Span{{
+					TraceId: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+					SpanId:  []byte{1, 2, 3, 4, 5, 6, 7, 8},
+					Name:    "typed",
+					Attributes: []*commonpb.KeyValue{
+						{Key: "http.status_code", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 503}}},
+						{Key: "ratio", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: 0.25}}},
+						{Key: "score", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: math.NaN()}}},
+						{Key: "limit", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: math.Inf(1)}}},
+						{Key: "cache.hit", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: true}}},
+						{Key: "tags", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{
+							Values: []*commonpb.AnyValue{
+								{Value: &commonpb.AnyValue_StringValue{StringValue: "a"}},
+								{Value: &commonpb.AnyValue_IntValue{IntValue: 2}},
+							},
+						}}}},
+					},
+				}},
+			}},
+		}},
+	}
+
+	data, err := proto.Marshal(request)
+	require.NoError(t, err)
+
+	traces, err := parser.ParseTraces(data)
+	require.NoError(t, err)
+	require.Len(t, traces, 1)
+
+	attrs := traces[0].SpanAttributes
+	assert.Equal(t, int64(503), attrs["http.status_code"])
+	assert.Equal(t, 0.25, attrs["ratio"])
+	assert.Equal(t, "NaN", attrs["score"])
+	assert.Equal(t, "+Inf", attrs["limit"])
+	assert.Equal(t, true, attrs["cache.hit"])
+	assert.Equal(t, []interface{}{"a", int64(2)}, attrs["tags"])
+}
+
 func TestParseTraces_InvalidData(t *testing.T) {
 	parser := setupParserTest()
 
@@ -206,8 +255,9 @@ func TestParseMetrics_Sum(t *testing.T) {
 	data, err := proto.Marshal(request)
 	require.NoError(t, err)
 
-	sums, gauges, histograms, err := parser.ParseMetrics(data)
+	metrics, err := parser.ParseMetrics(data)
 	require.NoError(t, err)
+	sums, gauges, histograms := metrics.Sums, metrics.Gauges, metrics.Histograms
 	require.Len(t, sums, 1)
 	assert.Empty(t, gauges)
 	assert.Empty(t, histograms)
@@ -262,8 +312,9 @@ func TestParseMetrics_Gauge(t *testing.T) {
 	data, err := proto.Marshal(request)
 	require.NoError(t, err)
 
-	sums, gauges, histograms, err := parser.ParseMetrics(data)
+	metrics, err := parser.ParseMetrics(data)
 	require.NoError(t, err)
+	sums, gauges, histograms := metrics.Sums, metrics.Gauges, metrics.Histograms
 	assert.Empty(t, sums)
 	require.Len(t, gauges, 1)
 	assert.Empty(t, histograms)
@@ -316,8 +367,9 @@ func TestParseMetrics_Histogram(t *testing.T) {
 	data, err := proto.Marshal(request)
 	require.NoError(t, err)
 
-	sums, gauges, histograms, err := parser.ParseMetrics(data)
+	metrics, err := parser.ParseMetrics(data)
 	require.NoError(t, err)
+	sums, gauges, histograms := metrics.Sums, metrics.Gauges, metrics.Histograms
 	assert.Empty(t, sums)
 	assert.Empty(t, gauges)
 	require.Len(t, histograms, 1)
@@ -330,10 +382,103 @@ func TestParseMetrics_Histogram(t *testing.T) {
 	assert.Len(t, histogram.ExplicitBounds, 3)
 }
 
+func TestParseMetrics_ExponentialHistogramAndSummary(t *testing.T) {
+	parser := setupParserTest()
+
+	now := time.Now()
+	request := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{
+			{
+				Resource: &resourcepb.Resource{
+					Attributes: makeResourceAttributes(),
+				},
+				ScopeMetrics: []*metricspb.ScopeMetrics{
+					{
+						Scope: &commonpb.InstrumentationScope{Name: "test-scope"},
+						Metrics: []*metricspb.Metric{
+							{
+								Name: "test.exponential",
+								Unit: "ms",
+								Data: &metricspb.Metric_ExponentialHistogram{
+									ExponentialHistogram: &metricspb.ExponentialHistogram{
+										AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+										DataPoints: []*metricspb.ExponentialHistogramDataPoint{
+											{
+												TimeUnixNano:  uint64(now.UnixNano()),
+												Count:         7,
+												Sum:           proto.Float64(20),
+												Scale:         2,
+												ZeroCount:     1,
+												ZeroThreshold: 0.001,
+												Positive:      &metricspb.ExponentialHistogramDataPoint_Buckets{Offset: 3, BucketCounts: []uint64{2, 3}},
+												Negative:      &metricspb.ExponentialHistogramDataPoint_Buckets{Offset: -1, BucketCounts: []uint64{1}},
+												Min:           proto.Float64(-0.5),
+												Max:           proto.Float64(4),
+											},
+										},
+									},
+								},
+							},
+							{
+								Name: "test.summary",
+								Data: &metricspb.Metric_Summary{
+									Summary: &metricspb.Summary{
+										DataPoints: []*metricspb.SummaryDataPoint{
+											{
+												TimeUnixNano: uint64(now.UnixNano()),
+												Count:        10,
+												Sum:          55,
+												QuantileValues: []*metricspb.SummaryDataPoint_ValueAtQuantile{
+													{Quantile: 0.5, Value: 5},
+													{Quantile: 0.99, Value: 10},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	data, err := proto.Marshal(request)
+	require.NoError(t, err)
+
+	metrics, err := parser.ParseMetrics(data)
+	require.NoError(t, err)
+	require.Len(t, metrics.ExponentialHistograms, 1)
+	require.Len(t, metrics.Summaries, 1)
+	assert.Equal(t, 2, metrics.Len())
+
+	exponential := metrics.ExponentialHistograms[0]
+	assert.Equal(t, "test.exponential", exponential.MetricName)
+	assert.Equal(t, "ms", exponential.MetricUnit)
+	assert.Equal(t, uint64(7), exponential.Count)
+	assert.Equal(t, 20.0, exponential.Sum)
+	assert.Equal(t, int32(2), exponential.Scale)
+	assert.Equal(t, uint64(1), exponential.ZeroCount)
+	assert.Equal(t, int32(3), exponential.PositiveOffset)
+	assert.Equal(t, []uint64{2, 3}, exponential.PositiveBucketCounts)
+	assert.Equal(t, int32(-1), exponential.NegativeOffset)
+	assert.Equal(t, []uint64{1}, exponential.NegativeBucketCounts)
+	assert.Equal(t, -0.5, exponential.Min)
+	assert.Equal(t, int32(1), exponential.AggregationTemporality)
+	assert.Equal(t, "test-agent-id", exponential.AgentID)
+
+	summary := metrics.Summaries[0]
+	assert.Equal(t, "test.summary", summary.MetricName)
+	assert.Equal(t, uint64(10), summary.Count)
+	assert.Equal(t, 55.0, summary.Sum)
+	assert.Equal(t, []otlp.SummaryQuantileValue{{Quantile: 0.5, Value: 5}, {Quantile: 0.99, Value: 10}}, summary.QuantileValues)
+}
+
 func TestParseMetrics_InvalidData(t *testing.T) {
 	parser := setupParserTest()
 
-	_, _, _, err := parser.ParseMetrics([]byte("invalid data"))
+	_, err := parser.ParseMetrics([]byte("invalid data"))
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to unmarshal metrics")
 }
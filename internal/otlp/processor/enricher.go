@@ -31,20 +31,35 @@ func (e *Enricher) EnrichTraces(ctx context.Context, traces []otlp.TraceData) {
 }
 
 // EnrichMetrics enriches metrics with group information
-func (e *Enricher) EnrichMetrics(ctx context.Context, sums []otlp.MetricSumData, gauges []otlp.MetricGaugeData, histograms []otlp.MetricHistogramData) {
+func (e *Enricher) EnrichMetrics(ctx context.Context, metrics *otlp.MetricsData) {
 	// Enrich sums
-	for i := range sums {
-		e.enrichTelemetry(ctx, &sums[i].AgentID, &sums[i].GroupID, &sums[i].GroupName)
+	for i := range metrics.Sums {
+		m := &metrics.Sums[i]
+		e.enrichTelemetry(ctx, &m.AgentID, &m.GroupID, &m.GroupName)
 	}
 
 	// Enrich gauges
-	for i := range gauges {
-		e.enrichTelemetry(ctx, &gauges[i].AgentID, &gauges[i].GroupID, &gauges[i].GroupName)
+	for i := range metrics.Gauges {
+		m := &metrics.Gauges[i]
+		e.enrichTelemetry(ctx, &m.AgentID, &m.GroupID, &m.GroupName)
 	}
 
 	// Enrich histograms
-	for i := range histograms {
-		e.enrichTelemetry(ctx, &histograms[i].AgentID, &histograms[i].GroupID, &histograms[i].GroupName)
+	for i := range metrics.Histograms {
+		m := &metrics.Histograms[i]
+		e.enrichTelemetry(ctx, &m.AgentID, &m.GroupID, &m.GroupName)
+	}
+
+	// Enrich exponential histograms
+	for i := range metrics.ExponentialHistograms {
+		m := &metrics.ExponentialHistograms[i]
+		e.enrichTelemetry(ctx, &m.AgentID, &m.GroupID, &m.GroupName)
+	}
+
+	// Enrich summaries
+	for i := range metrics.Summaries {
+		m := &metrics.Summaries[i]
+		e.enrichTelemetry(ctx, &m.AgentID, &m.GroupID, &m.GroupName)
 	}
 }
 
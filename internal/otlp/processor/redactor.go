@@ -0,0 +1,373 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package processor
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/metrics"
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+)
+
+// Built-in PII detector names
+const (
+	DetectorEmail       = "email"
+	DetectorCreditCard  = "credit_card"
+	DetectorJWT         = "jwt"
+	DetectorBearerToken = "bearer_token"
+	DetectorIPv4        = "ipv4"
+	DetectorIPv6        = "ipv6"
+)
+
+// builtinDetectors lists the built-in detectors in the order they are applied.
+// Bearer tokens run after JWTs so a JWT in an authorization header is counted
+// once, and IPv6 runs before IPv4 so IPv4-mapped addresses are counted once.
+var builtinDetectors = []string{
+	DetectorEmail,
+	DetectorJWT,
+	DetectorBearerToken,
+	DetectorCreditCard,
+	DetectorIPv6,
+	DetectorIPv4,
+}
+
+var (
+	emailPattern       = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	creditCardPattern  = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+	jwtPattern         = regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+	bearerTokenPattern = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+	ipv4Pattern        = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`)
+	// ipv6Candidate matches colon-separated tokens; candidates are validated
+	// with netip so times and identifiers like "std::io" are left alone
+	ipv6Candidate = regexp.MustCompile(`[0-9A-Za-z.]*:[0-9A-Za-z.:]*`)
+)
+
+// RedactionPolicy configures which detectors are applied to a group's telemetry
+type RedactionPolicy struct {
+	// Disabled turns redaction off for the group
+	Disabled bool
+	// Detectors lists the built-in detectors to apply. Empty means all of them.
+	Detectors []string
+	// CustomPatterns are additional regular expressions to mask
+	CustomPatterns []CustomPattern
+	// Replacement is the text substituted for detected values
+	Replacement string
+}
+
+// CustomPattern is a named user-supplied regular expression
+type CustomPattern struct {
+	Name    string
+	Pattern string
+}
+
+// Redactor masks personally identifiable information in log bodies, log
+// attributes and span attributes before they are written to storage.
+// Policies are selected per record by group ID or group name.
+type Redactor struct {
+	defaultPolicy *compiledPolicy
+	groupPolicies map[string]*compiledPolicy
+	metrics       *metrics.RedactionMetrics
+	logger        *zap.Logger
+}
+
+// compiledPolicy is a redaction policy with its detectors resolved
+type compiledPolicy struct {
+	detectors   []*detector
+	replacement string
+}
+
+// detector finds one kind of sensitive value
+type detector struct {
+	name  string
+	regex *regexp.Regexp
+	// mask returns the masked form of a match, or false to keep the match
+	mask    func(match, replacement string) (string, bool)
+	counter metrics.Counter
+}
+
+// NewRedactor creates a new redactor. Group policies are keyed by group ID or
+// group name; records without a matching group use the default policy.
+func NewRedactor(defaultPolicy RedactionPolicy, groupPolicies map[string]RedactionPolicy, redactionMetrics *metrics.RedactionMetrics, logger *zap.Logger) (*Redactor, error) {
+	if redactionMetrics == nil {
+		redactionMetrics = metrics.NewRedactionMetrics(metrics.NullFactory)
+	}
+
+	r := &Redactor{
+		groupPolicies: make(map[string]*compiledPolicy, len(groupPolicies)),
+		metrics:       redactionMetrics,
+		logger:        logger,
+	}
+
+	var err error
+	if r.defaultPolicy, err = r.compilePolicy(defaultPolicy); err != nil {
+		return nil, fmt.Errorf("default redaction policy: %w", err)
+	}
+	for group, policy := range groupPolicies {
+		compiled, err := r.compilePolicy(policy)
+		if err != nil {
+			return nil, fmt.Errorf("redaction policy for group %q: %w", group, err)
+		}
+		r.groupPolicies[group] = compiled
+	}
+
+	logger.Info("Loaded redaction policies",
+		zap.Bool("default_enabled", r.defaultPolicy != nil),
+		zap.Int("group_policies", len(r.groupPolicies)))
+	return r, nil
+}
+
+// compilePolicy resolves detector names and compiles custom patterns.
+// A disabled policy compiles to nil.
+func (r *Redactor) compilePolicy(policy RedactionPolicy) (*compiledPolicy, error) {
+	if policy.Disabled {
+		return nil, nil
+	}
+
+	names := policy.Detectors
+	if len(names) == 0 {
+		names = builtinDetectors
+	}
+	enabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !isBuiltinDetector(name) {
+			return nil, fmt.Errorf("unknown detector %q", name)
+		}
+		enabled[name] = true
+	}
+
+	compiled := &compiledPolicy{replacement: replacementOrDefault(policy.Replacement)}
+	for _, name := range builtinDetectors {
+		if enabled[name] {
+			compiled.detectors = append(compiled.detectors, r.builtinDetector(name))
+		}
+	}
+	for _, custom := range policy.CustomPatterns {
+		regex, err := regexp.Compile(custom.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for %q: %w", custom.Name, err)
+		}
+		compiled.detectors = append(compiled.detectors, &detector{
+			name:    custom.Name,
+			regex:   regex,
+			counter: r.metrics.CustomRedactions,
+		})
+	}
+	return compiled, nil
+}
+
+// builtinDetector returns the detector for a built-in name
+func (r *Redactor) builtinDetector(name string) *detector {
+	switch name {
+	case DetectorEmail:
+		return &detector{name: name, regex: emailPattern, counter: r.metrics.EmailRedactions}
+	case DetectorCreditCard:
+		return &detector{name: name, regex: creditCardPattern, mask: maskCreditCard, counter: r.metrics.CreditCardRedactions}
+	case DetectorJWT:
+		return &detector{name: name, regex: jwtPattern, counter: r.metrics.JWTRedactions}
+	case DetectorBearerToken:
+		return &detector{name: name, regex: bearerTokenPattern, mask: maskBearerToken, counter: r.metrics.BearerTokenRedactions}
+	case DetectorIPv4:
+		return &detector{name: name, regex: ipv4Pattern, counter: r.metrics.IPv4Redactions}
+	case DetectorIPv6:
+		return &detector{name: name, regex: ipv6Candidate, mask: maskIPv6, counter: r.metrics.IPv6Redactions}
+	}
+	return nil
+}
+
+// isBuiltinDetector reports whether name is a built-in detector
+func isBuiltinDetector(name string) bool {
+	for _, builtin := range builtinDetectors {
+		if name == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+// policyFor returns the policy for a record's group, or nil if redaction is disabled
+func (r *Redactor) policyFor(groupID, groupName string) *compiledPolicy {
+	if groupID != "" {
+		if policy, ok := r.groupPolicies[groupID]; ok {
+			return policy
+		}
+	}
+	if groupName != "" {
+		if policy, ok := r.groupPolicies[groupName]; ok {
+			return policy
+		}
+	}
+	return r.defaultPolicy
+}
+
+// RedactLogs masks sensitive values in log bodies and attributes in place and
+// returns the number of values redacted
+func (r *Redactor) RedactLogs(logs []otlp.LogData) int {
+	total := 0
+	for i := range logs {
+		l := &logs[i]
+		policy := r.policyFor(l.GroupID, l.GroupName)
+		if policy == nil {
+			continue
+		}
+
+		body, count := policy.redact(l.Body)
+		l.Body = body
+		count += policy.redactAttributes(l.LogAttributes)
+
+		if count > 0 {
+			r.metrics.LogsRedacted.Inc(1)
+			total += count
+		}
+	}
+	return total
+}
+
+// RedactTraces masks sensitive values in span attributes, status messages and
+// event attributes in place and returns the number of values redacted
+func (r *Redactor) RedactTraces(traces []otlp.TraceData) int {
+	total := 0
+	for i := range traces {
+		t := &traces[i]
+		policy := r.policyFor(t.GroupID, t.GroupName)
+		if policy == nil {
+			continue
+		}
+
+		statusMessage, count := policy.redact(t.StatusMessage)
+		t.StatusMessage = statusMessage
+		count += policy.redactAttributes(t.SpanAttributes)
+		for j := range t.Events {
+			count += policy.redactAttributes(t.Events[j].Attributes)
+		}
+
+		if count > 0 {
+			r.metrics.SpansRedacted.Inc(1)
+			total += count
+		}
+	}
+	return total
+}
+
+// redactAttributes masks string attribute values in place, including strings
+// nested in arrays and maps. Attribute maps of individual records are never
+// shared, so no copy is needed.
+func (p *compiledPolicy) redactAttributes(attrs map[string]interface{}) int {
+	total := 0
+	for key, value := range attrs {
+		redacted, n := p.redactValue(value)
+		if n > 0 {
+			attrs[key] = redacted
+			total += n
+		}
+	}
+	return total
+}
+
+// redactValue masks a single typed attribute value. Only strings can hold
+// the detected formats; other scalars are returned unchanged.
+func (p *compiledPolicy) redactValue(value interface{}) (interface{}, int) {
+	switch v := value.(type) {
+	case string:
+		return p.redact(v)
+	case []interface{}:
+		total := 0
+		for i, item := range v {
+			redacted, n := p.redactValue(item)
+			if n > 0 {
+				v[i] = redacted
+				total += n
+			}
+		}
+		return v, total
+	case map[string]interface{}:
+		return v, p.redactAttributes(v)
+	}
+	return value, 0
+}
+
+// redact applies every detector to a value and returns the masked value and
+// the number of matches replaced
+func (p *compiledPolicy) redact(value string) (string, int) {
+	if value == "" {
+		return value, 0
+	}
+
+	total := 0
+	for _, d := range p.detectors {
+		count := 0
+		value = d.regex.ReplaceAllStringFunc(value, func(match string) string {
+			if d.mask == nil {
+				count++
+				return p.replacement
+			}
+			masked, ok := d.mask(match, p.replacement)
+			if !ok {
+				return match
+			}
+			count++
+			return masked
+		})
+		if count > 0 {
+			d.counter.Inc(int64(count))
+			total += count
+		}
+	}
+	return value, total
+}
+
+// maskCreditCard masks digit sequences that pass the Luhn checksum
+func maskCreditCard(match, replacement string) (string, bool) {
+	digits := make([]byte, 0, len(match))
+	for i := 0; i < len(match); i++ {
+		if match[i] >= '0' && match[i] <= '9' {
+			digits = append(digits, match[i])
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 || !luhnValid(digits) {
+		return "", false
+	}
+	return replacement, true
+}
+
+// luhnValid reports whether a digit sequence passes the Luhn checksum
+func luhnValid(digits []byte) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// maskBearerToken keeps the "Bearer" scheme and masks the token
+func maskBearerToken(match, replacement string) (string, bool) {
+	scheme := match[:strings.IndexFunc(match, unicode.IsSpace)]
+	return scheme + " " + replacement, true
+}
+
+// maskIPv6 masks candidates that parse as IPv6 addresses
+func maskIPv6(match, replacement string) (string, bool) {
+	if strings.Count(match, ":") < 2 {
+		return "", false
+	}
+	addr, err := netip.ParseAddr(match)
+	if err != nil || !addr.Is6() {
+		return "", false
+	}
+	return replacement, true
+}
@@ -0,0 +1,391 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// RuleEngine applies ingest-time processing rules to parsed telemetry.
+// The active rule set can be replaced at any time through LoadRules.
+type RuleEngine struct {
+	mu     sync.RWMutex
+	rules  map[services.RuleSignal][]*compiledRule
+	logger *zap.Logger
+}
+
+// compiledRule is a processing rule with its regular expressions compiled
+type compiledRule struct {
+	conditions []compiledCondition
+	actions    []compiledAction
+}
+
+type compiledCondition struct {
+	field    string
+	operator services.RuleOperator
+	value    string
+	regex    *regexp.Regexp
+}
+
+type compiledAction struct {
+	services.RuleAction
+	regex *regexp.Regexp
+}
+
+// ruleTarget exposes a single record to rule evaluation. Attribute maps may be
+// shared between records parsed from the same resource or scope, so they are
+// copied before the first modification.
+type ruleTarget struct {
+	field           func(name string) (string, bool)
+	attributes      *map[string]interface{}
+	resource        *map[string]interface{}
+	body            *string
+	attributesOwned bool
+	resourceOwned   bool
+}
+
+// NewRuleEngine creates a new rule engine with no active rules
+func NewRuleEngine(logger *zap.Logger) *RuleEngine {
+	return &RuleEngine{
+		rules:  make(map[services.RuleSignal][]*compiledRule),
+		logger: logger,
+	}
+}
+
+// LoadRules compiles and activates the given rule set, replacing the current
+// one. Disabled rules are skipped. If any rule fails to compile, the current
+// rule set is left untouched.
+func (e *RuleEngine) LoadRules(rules []*services.ProcessingRule) error {
+	compiled := make(map[services.RuleSignal][]*compiledRule)
+
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		cr, err := compileRule(rule)
+		if err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		compiled[rule.Signal] = append(compiled[rule.Signal], cr)
+	}
+
+	e.mu.Lock()
+	e.rules = compiled
+	e.mu.Unlock()
+
+	e.logger.Info("Loaded processing rules",
+		zap.Int("traces", len(compiled[services.RuleSignalTraces])),
+		zap.Int("metrics", len(compiled[services.RuleSignalMetrics])),
+		zap.Int("logs", len(compiled[services.RuleSignalLogs])))
+	return nil
+}
+
+// compileRule validates a rule and compiles its regular expressions
+func compileRule(rule *services.ProcessingRule) (*compiledRule, error) {
+	if err := services.ValidateProcessingRule(rule); err != nil {
+		return nil, err
+	}
+
+	cr := &compiledRule{}
+	for _, cond := range rule.Conditions {
+		cc := compiledCondition{field: cond.Field, operator: cond.Operator, value: cond.Value}
+		if cond.Operator == services.RuleOperatorRegex || cond.Operator == services.RuleOperatorNotRegex {
+			cc.regex = regexp.MustCompile(cond.Value)
+		}
+		cr.conditions = append(cr.conditions, cc)
+	}
+	for _, action := range rule.Actions {
+		ca := compiledAction{RuleAction: action}
+		if action.Type == services.RuleActionMaskBody {
+			ca.regex = regexp.MustCompile(action.Pattern)
+		}
+		cr.actions = append(cr.actions, ca)
+	}
+	return cr, nil
+}
+
+// rulesFor returns the active rules for a signal
+func (e *RuleEngine) rulesFor(signal services.RuleSignal) []*compiledRule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.rules[signal]
+}
+
+// ProcessTraces applies trace rules and returns the spans that were not dropped
+func (e *RuleEngine) ProcessTraces(traces []otlp.TraceData) []otlp.TraceData {
+	rules := e.rulesFor(services.RuleSignalTraces)
+	if len(rules) == 0 {
+		return traces
+	}
+
+	kept := traces[:0]
+	for i := range traces {
+		t := &traces[i]
+		target := &ruleTarget{
+			field: func(name string) (string, bool) {
+				switch name {
+				case "span_name":
+					return t.SpanName, true
+				case "status_code":
+					return t.StatusCode, true
+				}
+				return commonField(name, t.ServiceName, t.AgentID, t.GroupID, t.GroupName)
+			},
+			attributes: &t.SpanAttributes,
+			resource:   &t.ResourceAttributes,
+		}
+		if e.apply(rules, target) {
+			kept = append(kept, *t)
+		}
+	}
+	return kept
+}
+
+// ProcessMetrics applies metric rules and removes the data points that were dropped
+func (e *RuleEngine) ProcessMetrics(metrics *otlp.MetricsData) {
+	rules := e.rulesFor(services.RuleSignalMetrics)
+	if len(rules) == 0 {
+		return
+	}
+
+	keptSums := metrics.Sums[:0]
+	for i := range metrics.Sums {
+		m := &metrics.Sums[i]
+		if e.apply(rules, metricTarget(m.MetricName, m.ServiceName, m.AgentID, m.GroupID, m.GroupName, &m.Attributes, &m.ResourceAttributes)) {
+			keptSums = append(keptSums, *m)
+		}
+	}
+	metrics.Sums = keptSums
+
+	keptGauges := metrics.Gauges[:0]
+	for i := range metrics.Gauges {
+		m := &metrics.Gauges[i]
+		if e.apply(rules, metricTarget(m.MetricName, m.ServiceName, m.AgentID, m.GroupID, m.GroupName, &m.Attributes, &m.ResourceAttributes)) {
+			keptGauges = append(keptGauges, *m)
+		}
+	}
+	metrics.Gauges = keptGauges
+
+	keptHistograms := metrics.Histograms[:0]
+	for i := range metrics.Histograms {
+		m := &metrics.Histograms[i]
+		if e.apply(rules, metricTarget(m.MetricName, m.ServiceName, m.AgentID, m.GroupID, m.GroupName, &m.Attributes, &m.ResourceAttributes)) {
+			keptHistograms = append(keptHistograms, *m)
+		}
+	}
+	metrics.Histograms = keptHistograms
+
+	keptExponential := metrics.ExponentialHistograms[:0]
+	for i := range metrics.ExponentialHistograms {
+		m := &metrics.ExponentialHistograms[i]
+		if e.apply(rules, metricTarget(m.MetricName, m.ServiceName, m.AgentID, m.GroupID, m.GroupName, &m.Attributes, &m.ResourceAttributes)) {
+			keptExponential = append(keptExponential, *m)
+		}
+	}
+	metrics.ExponentialHistograms = keptExponential
+
+	keptSummaries := metrics.Summaries[:0]
+	for i := range metrics.Summaries {
+		m := &metrics.Summaries[i]
+		if e.apply(rules, metricTarget(m.MetricName, m.ServiceName, m.AgentID, m.GroupID, m.GroupName, &m.Attributes, &m.ResourceAttributes)) {
+			keptSummaries = append(keptSummaries, *m)
+		}
+	}
+	metrics.Summaries = keptSummaries
+}
+
+// ProcessLogs applies log rules and returns the log records that were not dropped
+func (e *RuleEngine) ProcessLogs(logs []otlp.LogData) []otlp.LogData {
+	rules := e.rulesFor(services.RuleSignalLogs)
+	if len(rules) == 0 {
+		return logs
+	}
+
+	kept := logs[:0]
+	for i := range logs {
+		l := &logs[i]
+		target := &ruleTarget{
+			field: func(name string) (string, bool) {
+				switch name {
+				case "body":
+					return l.Body, true
+				case "severity_text":
+					return l.SeverityText, true
+				}
+				return commonField(name, l.ServiceName, l.AgentID, l.GroupID, l.GroupName)
+			},
+			attributes: &l.LogAttributes,
+			resource:   &l.ResourceAttributes,
+			body:       &l.Body,
+		}
+		if e.apply(rules, target) {
+			kept = append(kept, *l)
+		}
+	}
+	return kept
+}
+
+// metricTarget builds a rule target for any metric data point type
+func metricTarget(metricName, serviceName, agentID, groupID, groupName string, attributes, resource *map[string]interface{}) *ruleTarget {
+	return &ruleTarget{
+		field: func(name string) (string, bool) {
+			if name == "metric_name" {
+				return metricName, true
+			}
+			return commonField(name, serviceName, agentID, groupID, groupName)
+		},
+		attributes: attributes,
+		resource:   resource,
+	}
+}
+
+// commonField resolves the fields shared by all signals
+func commonField(name, serviceName, agentID, groupID, groupName string) (string, bool) {
+	switch name {
+	case "service_name":
+		return serviceName, true
+	case "agent_id":
+		return agentID, true
+	case "group_id":
+		return groupID, groupID != ""
+	case "group_name":
+		return groupName, groupName != ""
+	}
+	return "", false
+}
+
+// apply evaluates the rules against a record in order. It returns false if
+// the record should be dropped.
+func (e *RuleEngine) apply(rules []*compiledRule, target *ruleTarget) bool {
+	for _, rule := range rules {
+		if !rule.matches(target) {
+			continue
+		}
+		for _, action := range rule.actions {
+			if action.Type == services.RuleActionDrop {
+				return false
+			}
+			target.applyAction(action)
+		}
+	}
+	return true
+}
+
+// matches reports whether all conditions of the rule match the record
+func (r *compiledRule) matches(target *ruleTarget) bool {
+	for _, cond := range r.conditions {
+		value, ok := target.lookup(cond.field)
+		switch cond.operator {
+		case services.RuleOperatorExists:
+			if !ok {
+				return false
+			}
+		case services.RuleOperatorNotExists:
+			if ok {
+				return false
+			}
+		case services.RuleOperatorEquals:
+			if !ok || value != cond.value {
+				return false
+			}
+		case services.RuleOperatorNotEquals:
+			if ok && value == cond.value {
+				return false
+			}
+		case services.RuleOperatorRegex:
+			if !ok || !cond.regex.MatchString(value) {
+				return false
+			}
+		case services.RuleOperatorNotRegex:
+			if ok && cond.regex.MatchString(value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// lookup resolves a condition field against the record. Typed attribute
+// values are compared by their string form.
+func (t *ruleTarget) lookup(field string) (string, bool) {
+	if key, ok := strings.CutPrefix(field, "attributes."); ok {
+		return otlp.StringAttribute(*t.attributes, key)
+	}
+	if key, ok := strings.CutPrefix(field, "resource."); ok {
+		return otlp.StringAttribute(*t.resource, key)
+	}
+	return t.field(field)
+}
+
+// attributeMap returns the attribute map for the action scope, copied so it
+// can be modified without affecting other records
+func (t *ruleTarget) attributeMap(scope services.RuleScope) map[string]interface{} {
+	if scope == services.RuleScopeResource {
+		if !t.resourceOwned {
+			*t.resource = otlp.CloneAttributes(*t.resource)
+			t.resourceOwned = true
+		}
+		return *t.resource
+	}
+	if !t.attributesOwned {
+		*t.attributes = otlp.CloneAttributes(*t.attributes)
+		t.attributesOwned = true
+	}
+	return *t.attributes
+}
+
+// applyAction applies a single non-drop action to the record
+func (t *ruleTarget) applyAction(action compiledAction) {
+	switch action.Type {
+	case services.RuleActionMaskBody:
+		if t.body != nil {
+			*t.body = action.regex.ReplaceAllString(*t.body, replacementOrDefault(action.Replacement))
+		}
+		return
+	}
+
+	// Avoid copying the map when the key is absent
+	var current map[string]interface{}
+	if action.Scope == services.RuleScopeResource {
+		current = *t.resource
+	} else {
+		current = *t.attributes
+	}
+	value, exists := current[action.Key]
+	if !exists {
+		return
+	}
+
+	attrs := t.attributeMap(action.Scope)
+	switch action.Type {
+	case services.RuleActionDeleteAttribute:
+		delete(attrs, action.Key)
+	case services.RuleActionRenameAttribute:
+		delete(attrs, action.Key)
+		attrs[action.NewKey] = value
+	case services.RuleActionHashAttribute:
+		sum := sha256.Sum256([]byte(otlp.AttributeString(value)))
+		attrs[action.Key] = hex.EncodeToString(sum[:])
+	case services.RuleActionRedactAttribute:
+		attrs[action.Key] = replacementOrDefault(action.Replacement)
+	}
+}
+
+// replacementOrDefault returns the configured replacement or the default redaction text
+func replacementOrDefault(replacement string) string {
+	if replacement == "" {
+		return services.DefaultRedactionText
+	}
+	return replacement
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+func newTestEngine(t *testing.T, rules ...*services.ProcessingRule) *RuleEngine {
+	engine := NewRuleEngine(zap.NewNop())
+	for _, rule := range rules {
+		rule.Enabled = true
+	}
+	require.NoError(t, engine.LoadRules(rules))
+	return engine
+}
+
+func TestRuleEngine_NoRulesPassThrough(t *testing.T) {
+	engine := NewRuleEngine(zap.NewNop())
+	logs := []otlp.LogData{{Body: "hello"}}
+
+	assert.Equal(t, logs, engine.ProcessLogs(logs))
+}
+
+func TestRuleEngine_DropDebugLogs(t *testing.T) {
+	engine := newTestEngine(t, &services.ProcessingRule{
+		Name:   "drop-debug",
+		Signal: services.RuleSignalLogs,
+		Conditions: []services.RuleCondition{
+			{Field: "severity_text", Operator: services.RuleOperatorEquals, Value: "DEBUG"},
+		},
+		Actions: []services.RuleAction{{Type: services.RuleActionDrop}},
+	})
+
+	logs := []otlp.LogData{
+		{SeverityText: "DEBUG", Body: "noisy"},
+		{SeverityText: "INFO", Body: "kept"},
+		{SeverityText: "DEBUG", Body: "noisy"},
+	}
+
+	kept := engine.ProcessLogs(logs)
+	require.Len(t, kept, 1)
+	assert.Equal(t, "kept", kept[0].Body)
+}
+
+func TestRuleEngine_AttributeActions(t *testing.T) {
+	engine := newTestEngine(t, &services.ProcessingRule{
+		Name:   "sanitize-spans",
+		Signal: services.RuleSignalTraces,
+		Actions: []services.RuleAction{
+			{Type: services.RuleActionDeleteAttribute, Key: "http.request.header.authorization"},
+			{Type: services.RuleActionHashAttribute, Key: "user.id"},
+			{Type: services.RuleActionRenameAttribute, Key: "http.url", NewKey: "url.full"},
+			{Type: services.RuleActionRedactAttribute, Key: "db.statement"},
+			{Type: services.RuleActionRedactAttribute, Key: "host.name", Scope: services.RuleScopeResource, Replacement: "***"},
+		},
+	})
+
+	traces := []otlp.TraceData{{
+		SpanAttributes: map[string]interface{}{
+			"http.request.header.authorization": "Bearer abc",
+			"user.id":                           "42",
+			"http.url":                          "https://example.com",
+			"db.statement":                      "SELECT * FROM users",
+		},
+		ResourceAttributes: map[string]interface{}{"host.name": "prod-1"},
+	}}
+
+	kept := engine.ProcessTraces(traces)
+	require.Len(t, kept, 1)
+
+	sum := sha256.Sum256([]byte("42"))
+	assert.Equal(t, map[string]interface{}{
+		"user.id":      hex.EncodeToString(sum[:]),
+		"url.full":     "https://example.com",
+		"db.statement": services.DefaultRedactionText,
+	}, kept[0].SpanAttributes)
+	assert.Equal(t, map[string]interface{}{"host.name": "***"}, kept[0].ResourceAttributes)
+}
+
+func TestRuleEngine_SharedResourceAttributesAreCopied(t *testing.T) {
+	engine := newTestEngine(t, &services.ProcessingRule{
+		Name:   "redact-api-host",
+		Signal: services.RuleSignalLogs,
+		Conditions: []services.RuleCondition{
+			{Field: "attributes.component", Operator: services.RuleOperatorEquals, Value: "auth"},
+		},
+		Actions: []services.RuleAction{
+			{Type: services.RuleActionDeleteAttribute, Key: "host.name", Scope: services.RuleScopeResource},
+		},
+	})
+
+	// The parser shares one resource map between all records of a resource
+	resource := map[string]interface{}{"host.name": "prod-1"}
+	logs := []otlp.LogData{
+		{ResourceAttributes: resource, LogAttributes: map[string]interface{}{"component": "auth"}},
+		{ResourceAttributes: resource, LogAttributes: map[string]interface{}{"component": "billing"}},
+	}
+
+	kept := engine.ProcessLogs(logs)
+	require.Len(t, kept, 2)
+	assert.Empty(t, kept[0].ResourceAttributes)
+	assert.Equal(t, "prod-1", kept[1].ResourceAttributes["host.name"])
+	assert.Equal(t, "prod-1", resource["host.name"])
+}
+
+func TestRuleEngine_TypedAttributes(t *testing.T) {
+	engine := newTestEngine(t, &services.ProcessingRule{
+		Name:   "drop-health-checks",
+		Signal: services.RuleSignalTraces,
+		Conditions: []services.RuleCondition{
+			{Field: "attributes.http.status_code", Operator: services.RuleOperatorEquals, Value: "200"},
+			{Field: "attributes.synthetic", Operator: services.RuleOperatorEquals, Value: "true"},
+		},
+		Actions: []services.RuleAction{{Type: services.RuleActionDrop}},
+	})
+
+	traces := []otlp.TraceData{
+		{SpanAttributes: map[string]interface{}{"http.status_code": int64(200), "synthetic": true}},
+		{SpanAttributes: map[string]interface{}{"http.status_code": int64(503), "synthetic": true}},
+	}
+
+	kept := engine.ProcessTraces(traces)
+	require.Len(t, kept, 1)
+	assert.Equal(t, int64(503), kept[0].SpanAttributes["http.status_code"])
+}
+
+func TestRuleEngine_MaskBody(t *testing.T) {
+	engine := newTestEngine(t, &services.ProcessingRule{
+		Name:   "mask-passwords",
+		Signal: services.RuleSignalLogs,
+		Conditions: []services.RuleCondition{
+			{Field: "body", Operator: services.RuleOperatorRegex, Value: `password=`},
+		},
+		Actions: []services.RuleAction{
+			{Type: services.RuleActionMaskBody, Pattern: `password=\S+`, Replacement: "password=****"},
+		},
+	})
+
+	logs := []otlp.LogData{{Body: "login user=bob password=hunter2 ok"}}
+	kept := engine.ProcessLogs(logs)
+	require.Len(t, kept, 1)
+	assert.Equal(t, "login user=bob password=**** ok", kept[0].Body)
+}
+
+func TestRuleEngine_Conditions(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition services.RuleCondition
+		dropped   bool
+	}{
+		{"equals match", services.RuleCondition{Field: "metric_name", Operator: services.RuleOperatorEquals, Value: "debug.queue"}, true},
+		{"equals no match", services.RuleCondition{Field: "metric_name", Operator: services.RuleOperatorEquals, Value: "other"}, false},
+		{"not equals", services.RuleCondition{Field: "service_name", Operator: services.RuleOperatorNotEquals, Value: "api"}, false},
+		{"regex", services.RuleCondition{Field: "metric_name", Operator: services.RuleOperatorRegex, Value: `^debug\.`}, true},
+		{"not regex", services.RuleCondition{Field: "metric_name", Operator: services.RuleOperatorNotRegex, Value: `^debug\.`}, false},
+		{"exists", services.RuleCondition{Field: "attributes.queue", Operator: services.RuleOperatorExists}, true},
+		{"not exists", services.RuleCondition{Field: "resource.k8s.pod.name", Operator: services.RuleOperatorNotExists}, true},
+		{"missing group", services.RuleCondition{Field: "group_id", Operator: services.RuleOperatorExists}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newTestEngine(t, &services.ProcessingRule{
+				Name:       "drop",
+				Signal:     services.RuleSignalMetrics,
+				Conditions: []services.RuleCondition{tt.condition},
+				Actions:    []services.RuleAction{{Type: services.RuleActionDrop}},
+			})
+
+			gauges := []otlp.MetricGaugeData{{
+				MetricName:  "debug.queue",
+				ServiceName: "api",
+				Attributes:  map[string]interface{}{"queue": "jobs"},
+			}}
+			metrics := &otlp.MetricsData{Gauges: gauges}
+			engine.ProcessMetrics(metrics)
+			assert.Equal(t, tt.dropped, len(metrics.Gauges) == 0)
+		})
+	}
+}
+
+func TestRuleEngine_RulesScopedToSignal(t *testing.T) {
+	engine := newTestEngine(t, &services.ProcessingRule{
+		Name:    "drop-all-logs",
+		Signal:  services.RuleSignalLogs,
+		Actions: []services.RuleAction{{Type: services.RuleActionDrop}},
+	})
+
+	traces := []otlp.TraceData{{SpanName: "GET /"}}
+	assert.Len(t, engine.ProcessTraces(traces), 1)
+	assert.Empty(t, engine.ProcessLogs([]otlp.LogData{{Body: "x"}}))
+}
+
+func TestRuleEngine_LoadRulesSkipsDisabledAndRejectsInvalid(t *testing.T) {
+	engine := NewRuleEngine(zap.NewNop())
+	dropAll := &services.ProcessingRule{
+		Name:    "drop-all-logs",
+		Signal:  services.RuleSignalLogs,
+		Enabled: false,
+		Actions: []services.RuleAction{{Type: services.RuleActionDrop}},
+	}
+	require.NoError(t, engine.LoadRules([]*services.ProcessingRule{dropAll}))
+	assert.Len(t, engine.ProcessLogs([]otlp.LogData{{Body: "x"}}), 1)
+
+	// An invalid rule leaves the active set untouched
+	dropAll.Enabled = true
+	require.NoError(t, engine.LoadRules([]*services.ProcessingRule{dropAll}))
+	invalid := &services.ProcessingRule{
+		Name:    "bad",
+		Signal:  services.RuleSignalLogs,
+		Enabled: true,
+		Actions: []services.RuleAction{{Type: services.RuleActionMaskBody, Pattern: "("}},
+	}
+	require.Error(t, engine.LoadRules([]*services.ProcessingRule{invalid}))
+	assert.Empty(t, engine.ProcessLogs([]otlp.LogData{{Body: "x"}}))
+}
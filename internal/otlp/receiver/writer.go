@@ -12,7 +12,7 @@ type TelemetryWriter interface {
 	WriteTraces(ctx context.Context, traces []otlp.TraceData) error
 
 	// WriteMetrics writes metric data to storage
-	WriteMetrics(ctx context.Context, sums []otlp.MetricSumData, gauges []otlp.MetricGaugeData, histograms []otlp.MetricHistogramData) error
+	WriteMetrics(ctx context.Context, metrics *otlp.MetricsData) error
 
 	// WriteLogs writes log data to storage
 	WriteLogs(ctx context.Context, logs []otlp.LogData) error
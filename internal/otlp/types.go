@@ -15,12 +15,12 @@ type LogData struct {
 	ServiceName        string
 	Body               string
 	ResourceSchemaUrl  string
-	ResourceAttributes map[string]string
+	ResourceAttributes map[string]interface{}
 	ScopeSchemaUrl     string
 	ScopeName          string
 	ScopeVersion       string
-	ScopeAttributes    map[string]string
-	LogAttributes      map[string]string
+	ScopeAttributes    map[string]interface{}
+	LogAttributes      map[string]interface{}
 	AgentID            string
 	GroupID            string
 	GroupName          string
@@ -36,11 +36,11 @@ type TraceData struct {
 	SpanName           string
 	SpanKind           int32
 	ServiceName        string
-	ResourceAttributes map[string]string
+	ResourceAttributes map[string]interface{}
 	ScopeName          string
 	ScopeVersion       string
-	ScopeAttributes    map[string]string
-	SpanAttributes     map[string]string
+	ScopeAttributes    map[string]interface{}
+	SpanAttributes     map[string]interface{}
 	Duration           int64
 	StatusCode         string
 	StatusMessage      string
@@ -55,7 +55,7 @@ type TraceData struct {
 type EventData struct {
 	Name       string
 	Timestamp  time.Time
-	Attributes map[string]string
+	Attributes map[string]interface{}
 }
 
 // LinkData represents span link data
@@ -63,23 +63,23 @@ type LinkData struct {
 	TraceId    string
 	SpanId     string
 	TraceState string
-	Attributes map[string]string
+	Attributes map[string]interface{}
 }
 
 // MetricSumData represents sum/counter metric data for storage insertion
 type MetricSumData struct {
-	ResourceAttributes     map[string]string
+	ResourceAttributes     map[string]interface{}
 	ResourceSchemaUrl      string
 	ScopeName              string
 	ScopeVersion           string
-	ScopeAttributes        map[string]string
+	ScopeAttributes        map[string]interface{}
 	ScopeDroppedAttrCount  uint32
 	ScopeSchemaUrl         string
 	ServiceName            string
 	MetricName             string
 	MetricDescription      string
 	MetricUnit             string
-	Attributes             map[string]string
+	Attributes             map[string]interface{}
 	StartTimeUnix          time.Time
 	TimeUnix               time.Time
 	Value                  float64
@@ -93,18 +93,18 @@ type MetricSumData struct {
 
 // MetricGaugeData represents gauge metric data for storage insertion
 type MetricGaugeData struct {
-	ResourceAttributes     map[string]string
+	ResourceAttributes     map[string]interface{}
 	ResourceSchemaUrl      string
 	ScopeName              string
 	ScopeVersion           string
-	ScopeAttributes        map[string]string
+	ScopeAttributes        map[string]interface{}
 	ScopeDroppedAttrCount  uint32
 	ScopeSchemaUrl         string
 	ServiceName            string
 	MetricName             string
 	MetricDescription      string
 	MetricUnit             string
-	Attributes             map[string]string
+	Attributes             map[string]interface{}
 	StartTimeUnix          time.Time
 	TimeUnix               time.Time
 	Value                  float64
@@ -118,18 +118,18 @@ type MetricGaugeData struct {
 
 // MetricHistogramData represents histogram metric data for storage insertion
 type MetricHistogramData struct {
-	ResourceAttributes     map[string]string
+	ResourceAttributes     map[string]interface{}
 	ResourceSchemaUrl      string
 	ScopeName              string
 	ScopeVersion           string
-	ScopeAttributes        map[string]string
+	ScopeAttributes        map[string]interface{}
 	ScopeDroppedAttrCount  uint32
 	ScopeSchemaUrl         string
 	ServiceName            string
 	MetricName             string
 	MetricDescription      string
 	MetricUnit             string
-	Attributes             map[string]string
+	Attributes             map[string]interface{}
 	StartTimeUnix          time.Time
 	TimeUnix               time.Time
 	Count                  uint64
@@ -145,3 +145,126 @@ type MetricHistogramData struct {
 	GroupID                string
 	GroupName              string
 }
+
+// MetricExponentialHistogramData represents exponential histogram metric data
+// for storage insertion
+type MetricExponentialHistogramData struct {
+	ResourceAttributes     map[string]interface{}
+	ResourceSchemaUrl      string
+	ScopeName              string
+	ScopeVersion           string
+	ScopeAttributes        map[string]interface{}
+	ScopeDroppedAttrCount  uint32
+	ScopeSchemaUrl         string
+	ServiceName            string
+	MetricName             string
+	MetricDescription      string
+	MetricUnit             string
+	Attributes             map[string]interface{}
+	StartTimeUnix          time.Time
+	TimeUnix               time.Time
+	Count                  uint64
+	Sum                    float64
+	Scale                  int32
+	ZeroCount              uint64
+	ZeroThreshold          float64
+	PositiveOffset         int32
+	PositiveBucketCounts   []uint64
+	NegativeOffset         int32
+	NegativeBucketCounts   []uint64
+	Flags                  uint32
+	Min                    float64
+	Max                    float64
+	AggregationTemporality int32
+	AgentID                string
+	GroupID                string
+	GroupName              string
+}
+
+// SummaryQuantileValue is a quantile reported by a summary data point
+type SummaryQuantileValue struct {
+	Quantile float64
+	Value    float64
+}
+
+// MetricSummaryData represents summary metric data for storage insertion
+type MetricSummaryData struct {
+	ResourceAttributes    map[string]interface{}
+	ResourceSchemaUrl     string
+	ScopeName             string
+	ScopeVersion          string
+	ScopeAttributes       map[string]interface{}
+	ScopeDroppedAttrCount uint32
+	ScopeSchemaUrl        string
+	ServiceName           string
+	MetricName            string
+	MetricDescription     string
+	MetricUnit            string
+	Attributes            map[string]interface{}
+	StartTimeUnix         time.Time
+	TimeUnix              time.Time
+	Count                 uint64
+	Sum                   float64
+	QuantileValues        []SummaryQuantileValue
+	Flags                 uint32
+	AgentID               string
+	GroupID               string
+	GroupName             string
+}
+
+// MetricsData holds the data points of one metrics payload by metric type
+type MetricsData struct {
+	Sums                  []MetricSumData
+	Gauges                []MetricGaugeData
+	Histograms            []MetricHistogramData
+	ExponentialHistograms []MetricExponentialHistogramData
+	Summaries             []MetricSummaryData
+}
+
+// Len returns the number of data points of all types
+func (m *MetricsData) Len() int {
+	return len(m.Sums) + len(m.Gauges) + len(m.Histograms) + len(m.ExponentialHistograms) + len(m.Summaries)
+}
+
+// UsageData represents ingestion usage for a single agent/group/service and
+// signal within a time bucket
+type UsageData struct {
+	BucketStart time.Time
+	Signal      string
+	AgentID     string
+	GroupID     string
+	GroupName   string
+	ServiceName string
+	Records     int64
+	Bytes       int64
+}
+
+// Aggregation temporality names stored with sums and histograms
+const (
+	TemporalityUnspecified = "unspecified"
+	TemporalityDelta       = "delta"
+	TemporalityCumulative  = "cumulative"
+)
+
+// TemporalityName returns the name of an OTLP aggregation temporality
+func TemporalityName(temporality int32) string {
+	switch temporality {
+	case 1:
+		return TemporalityDelta
+	case 2:
+		return TemporalityCumulative
+	default:
+		return TemporalityUnspecified
+	}
+}
+
+// spanKindNames maps OTLP span kinds to the names stored with spans
+var spanKindNames = []string{"unspecified", "internal", "server", "client", "producer", "consumer"}
+
+// SpanKindName returns the name of an OTLP span kind
+func SpanKindName(kind int32) string {
+	if kind < 0 || int(kind) >= len(spanKindNames) {
+		return spanKindNames[0]
+	}
+	return spanKindNames[kind]
+}
@@ -0,0 +1,289 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"container/list"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/getlawrence/lawrence-oss/internal/metrics"
+)
+
+// Results cache defaults
+const (
+	defaultCacheMaxBytes  = 256 << 20
+	defaultCacheChunkSize = time.Hour
+	defaultCacheFreshness = 10 * time.Minute
+)
+
+// CacheConfig configures the range query results cache
+type CacheConfig struct {
+	// MaxBytes bounds the estimated size of the cached results; the least
+	// recently used chunks are evicted beyond it
+	MaxBytes int64
+	// ChunkSize is the length of the aligned chunks range queries are split
+	// into. It is rounded up to a multiple of the step of each query.
+	ChunkSize time.Duration
+	// Freshness is how long before now a chunk has to end to be cached.
+	// Newer chunks may still receive samples and are always queried.
+	Freshness time.Duration
+}
+
+// ResultsCache holds the results of range queries in time aligned chunks,
+// keyed by the normalized query, its scope and step. Range queries reuse
+// the cached chunks they overlap and only evaluate the chunks that are
+// missing or too recent to cache.
+type ResultsCache struct {
+	maxBytes  int64
+	chunkSize time.Duration
+	freshness time.Duration
+	metrics   *metrics.QueryCacheMetrics
+	now       func() time.Time
+	mu        sync.Mutex
+	entries   map[string]*list.Element
+	// lru orders the entries from most to least recently used
+	lru  *list.List
+	size int64
+}
+
+// cacheEntry is the result of one chunk of a range query
+type cacheEntry struct {
+	key    string
+	series []Series
+	size   int64
+}
+
+// NewResultsCache creates a new range query results cache
+func NewResultsCache(cfg CacheConfig, cacheMetrics *metrics.QueryCacheMetrics) *ResultsCache {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultCacheMaxBytes
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = defaultCacheChunkSize
+	}
+	if cfg.Freshness <= 0 {
+		cfg.Freshness = defaultCacheFreshness
+	}
+	if cacheMetrics == nil {
+		cacheMetrics = metrics.NewQueryCacheMetrics(metrics.NullFactory)
+	}
+	return &ResultsCache{
+		maxBytes:  cfg.MaxBytes,
+		chunkSize: cfg.ChunkSize,
+		freshness: cfg.Freshness,
+		metrics:   cacheMetrics,
+		now:       time.Now,
+		entries:   make(map[string]*list.Element),
+		lru:       list.New(),
+	}
+}
+
+// executeRange evaluates a range query between start and end chunk by
+// chunk. Chunks that ended more than the freshness threshold ago are served
+// from the cache or evaluated and cached, consecutive missing chunks in a
+// single evaluation; the remaining recent tail is always evaluated. It
+// returns the series and the number of chunks served from the cache.
+func (c *ResultsCache) executeRange(v *ExecutorVisitor, query Query, start, end time.Time) ([]Series, int, error) {
+	normalized, err := NormalizeQuery(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	step := v.execCtx.Step
+	chunk := alignedChunkSize(c.chunkSize, step)
+	key := rangeCacheKey(normalized, v.execCtx)
+	fresh := c.now().Add(-c.freshness)
+
+	defer v.enterPlan(planExecutor, fmt.Sprintf("evaluate in chunks of %s, caching chunks older than %s", chunk, c.freshness))()
+
+	var parts []Series
+	hits := 0
+	var missingFrom time.Time
+	missing := false
+	// evaluateMissing evaluates and caches the missing chunks before to, at
+	// most maxRangeSteps steps at a time
+	evaluateMissing := func(to time.Time) error {
+		if !missing {
+			return nil
+		}
+		missing = false
+		batch := chunk * time.Duration(max(1, maxRangeSteps/int(chunk/step)))
+		for from := missingFrom; from.Before(to); from = from.Add(batch) {
+			batchEnd := from.Add(batch)
+			if batchEnd.After(to) {
+				batchEnd = to
+			}
+			series, err := v.evaluateRange(query, from, batchEnd.Add(-time.Nanosecond))
+			if err != nil {
+				return err
+			}
+			for chunkStart := from; chunkStart.Before(batchEnd); chunkStart = chunkStart.Add(chunk) {
+				c.put(chunkCacheKey(key, chunkStart), clipSeries(series, chunkStart, chunkStart.Add(chunk-time.Nanosecond)))
+			}
+			parts = append(parts, clipSeries(series, start, end)...)
+		}
+		return nil
+	}
+
+	from := time.Unix(0, start.UnixNano()/int64(chunk)*int64(chunk)).UTC()
+	for ; !from.After(end) && !from.Add(chunk).After(fresh); from = from.Add(chunk) {
+		cached, ok := c.get(chunkCacheKey(key, from))
+		if !ok {
+			if !missing {
+				missing = true
+				missingFrom = from
+			}
+			continue
+		}
+		if err := evaluateMissing(from); err != nil {
+			return nil, 0, err
+		}
+		hits++
+		parts = append(parts, clipSeries(cached, start, end)...)
+	}
+	if err := evaluateMissing(from); err != nil {
+		return nil, 0, err
+	}
+
+	if hits > 0 {
+		v.addPlan(planExecutor, fmt.Sprintf("reuse %d cached chunks", hits))
+	}
+	if !from.After(end) {
+		tailStart := from
+		if start.After(tailStart) {
+			tailStart = start
+		}
+		series, err := v.evaluateRange(query, tailStart, end)
+		if err != nil {
+			return nil, 0, err
+		}
+		parts = append(parts, series...)
+	}
+	return mergeSeries(parts), hits, nil
+}
+
+// get returns the cached series of a chunk and marks it recently used
+func (c *ResultsCache) get(key string) ([]Series, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.metrics.Misses.Inc(1)
+		return nil, false
+	}
+	c.metrics.Hits.Inc(1)
+	c.lru.MoveToFront(element)
+	return element.Value.(*cacheEntry).series, true
+}
+
+// put caches the series of a chunk, evicting the least recently used chunks
+// to stay within the size limit. Chunks larger than the limit are not cached.
+func (c *ResultsCache) put(key string, series []Series) {
+	size := seriesSize(key, series)
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, series: series, size: size})
+	c.size += size
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+		c.metrics.Evictions.Inc(1)
+	}
+	c.metrics.Entries.Update(int64(len(c.entries)))
+	c.metrics.Bytes.Update(c.size)
+}
+
+// remove drops an entry. The caller holds c.mu.
+func (c *ResultsCache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+// alignedChunkSize rounds the chunk size up to a multiple of the step so
+// that chunk boundaries are evaluation timestamps
+func alignedChunkSize(chunkSize, step time.Duration) time.Duration {
+	steps := (chunkSize + step - 1) / step
+	if steps > maxRangeSteps {
+		steps = maxRangeSteps
+	}
+	return steps * step
+}
+
+// rangeCacheKey identifies the results of a normalized query evaluated at
+// a step within the scope of the execution context
+func rangeCacheKey(normalized string, execCtx *ExecutionContext) string {
+	var agentID, groupID string
+	if execCtx.AgentID != nil {
+		agentID = execCtx.AgentID.String()
+	}
+	if execCtx.GroupID != nil {
+		groupID = *execCtx.GroupID
+	}
+	return fmt.Sprintf("%s|step=%s|agent=%s|group=%s|limit=%d|rollups=%t",
+		normalized, execCtx.Step, agentID, groupID, execCtx.Limit, !execCtx.DisableRollups)
+}
+
+// chunkCacheKey identifies the chunk of a range query starting at start
+func chunkCacheKey(key string, start time.Time) string {
+	return fmt.Sprintf("%s|chunk=%d", key, start.UnixNano())
+}
+
+// clipSeries returns the points of series between start and end, dropping
+// series without any
+func clipSeries(series []Series, start, end time.Time) []Series {
+	clipped := make([]Series, 0, len(series))
+	for _, s := range series {
+		var points []Point
+		for _, p := range s.Points {
+			if !p.Timestamp.Before(start) && !p.Timestamp.After(end) {
+				points = append(points, p)
+			}
+		}
+		if len(points) > 0 {
+			clipped = append(clipped, Series{Type: s.Type, Labels: s.Labels, Points: points})
+		}
+	}
+	return clipped
+}
+
+// mergeSeries joins the points of series sharing a label set, in the order
+// given. The merged series own their labels and points.
+func mergeSeries(parts []Series) []Series {
+	byKey := make(map[string]int)
+	var merged []Series
+	for _, s := range parts {
+		key := labelsKey(s.Labels)
+		i, ok := byKey[key]
+		if !ok {
+			i = len(merged)
+			byKey[key] = i
+			merged = append(merged, Series{Type: s.Type, Labels: maps.Clone(s.Labels)})
+		}
+		merged[i].Points = append(merged[i].Points, s.Points...)
+	}
+	return merged
+}
+
+// seriesSize estimates the memory held by a cached chunk
+func seriesSize(key string, series []Series) int64 {
+	size := int64(len(key)) + 128
+	for _, s := range series {
+		size += 64 + int64(len(s.Points))*32
+		for name, value := range s.Labels {
+			size += int64(len(name)+len(value)) + 32
+		}
+	}
+	return size
+}
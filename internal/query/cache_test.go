@@ -0,0 +1,124 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	normalize := func(input string) string {
+		parsed, err := NewParser(input).Parse()
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", input, err)
+		}
+		normalized, err := NormalizeQuery(parsed)
+		if err != nil {
+			t.Fatalf("Failed to normalize %q: %v", input, err)
+		}
+		return normalized
+	}
+
+	a := normalize(`sum(rate(metrics{service="api", metric="requests"} [5m])) by (service, host)`)
+	b := normalize(`sum(rate(metrics{metric="requests",service="api"}[5m]))by(host,service)`)
+	if a != b {
+		t.Errorf("Expected formatting not to matter, got %q and %q", a, b)
+	}
+	if c := normalize(`sum(rate(metrics{metric="requests",service="web"}[5m])) by (host,service)`); c == a {
+		t.Errorf("Expected different selectors to normalize differently, got %q", c)
+	}
+
+	matching := normalize(`metrics{metric="errors"} / on(service, region) group_left(team) metrics{metric="requests"}`)
+	if want := `(metrics{metric="errors"} limit 1000 / on(region,service) group_left(team) metrics{metric="requests"} limit 1000)`; matching != want {
+		t.Errorf("Expected %q, got %q", want, matching)
+	}
+}
+
+func TestExecuteRange_ReusesCachedChunks(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Hour)
+	values := make([]float64, 181)
+	for i := range values {
+		values[i] = float64(i * 10)
+	}
+	service := &stubTelemetryService{metrics: counterSeries(start, "a", values...)}
+
+	cache := NewResultsCache(CacheConfig{ChunkSize: time.Hour, Freshness: 10 * time.Minute}, nil)
+	cache.now = func() time.Time { return end.Add(time.Minute) }
+	executor := NewExecutor(service, zap.NewNop()).WithResultsCache(cache)
+	parsed, err := NewParser(`sum(rate(metrics{metric="requests"} [5m]))`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	execute := func(from time.Time) ([]Series, *QueryMeta) {
+		series, meta, err := executor.ExecuteRange(context.Background(), parsed, &ExecutionContext{
+			StartTime: &from,
+			EndTime:   &end,
+			Step:      time.Minute,
+		})
+		if err != nil {
+			t.Fatalf("Failed to execute range query: %v", err)
+		}
+		return series, meta
+	}
+
+	expected := executeRangeQuery(t, &stubTelemetryService{metrics: service.metrics}, `sum(rate(metrics{metric="requests"} [5m]))`, start, end, time.Minute)
+
+	// The first two hours are evaluated at once and cached, the last hour
+	// is too recent to cache
+	series, meta := execute(start)
+	if !reflect.DeepEqual(series, expected) {
+		t.Errorf("Expected cached execution to match uncached results")
+	}
+	if meta.CachedChunks != 0 || len(service.metricQueries) != 2 {
+		t.Errorf("Expected two evaluations and no cached chunks, got %d queries and %d chunks", len(service.metricQueries), meta.CachedChunks)
+	}
+
+	// Repeating the query only evaluates the recent tail
+	series, meta = execute(start)
+	if !reflect.DeepEqual(series, expected) {
+		t.Errorf("Expected results from cached chunks to match uncached results")
+	}
+	if meta.CachedChunks != 2 || len(service.metricQueries) != 3 {
+		t.Errorf("Expected two cached chunks and one evaluation, got %d chunks and %d queries", meta.CachedChunks, len(service.metricQueries)-2)
+	}
+
+	// A range within the cached chunks is clipped from them
+	series, meta = execute(start.Add(90 * time.Minute))
+	if meta.CachedChunks != 1 || len(series) != 1 || !series[0].Points[0].Timestamp.Equal(start.Add(90*time.Minute)) {
+		t.Errorf("Expected the range to start in the cached chunk, got %d chunks and %+v", meta.CachedChunks, series)
+	}
+}
+
+func TestResultsCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	series := []Series{{Type: TelemetryTypeMetrics, Labels: map[string]string{"host": "a"}, Points: make([]Point, 10)}}
+	size := seriesSize("a", series)
+	cache := NewResultsCache(CacheConfig{MaxBytes: 2 * size}, nil)
+
+	cache.put("a", series)
+	cache.put("b", series)
+	cache.get("a")
+	cache.put("c", series)
+
+	if _, ok := cache.get("b"); ok {
+		t.Error("Expected the least recently used chunk to be evicted")
+	}
+	if _, ok := cache.get("a"); !ok {
+		t.Error("Expected the recently used chunk to be kept")
+	}
+	if cache.size > 2*size {
+		t.Errorf("Expected the cache to stay within %d bytes, got %d", 2*size, cache.size)
+	}
+
+	// Chunks larger than the cache are not stored
+	cache.put("large", []Series{{Points: make([]Point, 1000)}})
+	if _, ok := cache.get("large"); ok {
+		t.Error("Expected a chunk larger than the cache not to be stored")
+	}
+}
@@ -7,8 +7,12 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"time"
 
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
 	"github.com/getlawrence/lawrence-oss/internal/services"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -17,6 +21,7 @@ import (
 // Executor executes Lawrence QL queries
 type Executor struct {
 	telemetryService services.TelemetryQueryService
+	cache            *ResultsCache
 	logger           *zap.Logger
 }
 
@@ -28,6 +33,13 @@ func NewExecutor(telemetryService services.TelemetryQueryService, logger *zap.Lo
 	}
 }
 
+// WithResultsCache makes range queries reuse the chunks of earlier results
+// held by the cache. A nil cache disables caching.
+func (e *Executor) WithResultsCache(cache *ResultsCache) *Executor {
+	e.cache = cache
+	return e
+}
+
 // Execute executes a Lawrence QL query and returns results
 func (e *Executor) Execute(ctx context.Context, query Query, execCtx *ExecutionContext) ([]QueryResult, *QueryMeta, error) {
 	startTime := time.Now()
@@ -38,6 +50,9 @@ func (e *Executor) Execute(ctx context.Context, query Query, execCtx *ExecutionC
 		ctx:      ctx,
 		execCtx:  execCtx,
 	}
+	if q, ok := query.(*TelemetryQuery); ok {
+		visitor.rollupSelector = q
+	}
 
 	results, err := query.Accept(visitor)
 	if err != nil {
@@ -53,7 +68,8 @@ func (e *Executor) Execute(ctx context.Context, query Query, execCtx *ExecutionC
 		ExecutionTime: time.Since(startTime),
 		RowCount:      len(queryResults),
 		QueryType:     fmt.Sprintf("%T", query),
-		UsedRollups:   false, // TODO: detect rollup usage
+		UsedRollups:   visitor.usedRollups,
+		Plan:          visitor.planString(),
 	}
 
 	return queryResults, meta, nil
@@ -61,9 +77,16 @@ func (e *Executor) Execute(ctx context.Context, query Query, execCtx *ExecutionC
 
 // ExecutorVisitor implements QueryVisitor to execute queries
 type ExecutorVisitor struct {
-	executor *Executor
-	ctx      context.Context
-	execCtx  *ExecutionContext
+	executor    *Executor
+	ctx         context.Context
+	execCtx     *ExecutionContext
+	usedRollups bool
+	// rollupSelector is the selector that may be answered from rollups: a
+	// plain selector, or the operand of an aggregation valid over rollups
+	rollupSelector *TelemetryQuery
+	// plan holds the executed steps and planParent the step being executed
+	plan       []*planNode
+	planParent *planNode
 }
 
 // VisitTelemetryQuery executes a telemetry query
@@ -85,49 +108,37 @@ func (v *ExecutorVisitor) VisitTelemetryQuery(q *TelemetryQuery) (interface{}, e
 
 // executeMetricsQuery executes a metrics query
 func (v *ExecutorVisitor) executeMetricsQuery(q *TelemetryQuery, startTime, endTime time.Time) ([]QueryResult, error) {
-	// Build metric query
-	metricQuery := services.MetricQuery{
-		StartTime: startTime,
-		EndTime:   endTime,
-		Limit:     q.Limit,
+	metricQuery, err := v.planMetricQuery(q, startTime, endTime)
+	if err != nil {
+		return nil, err
 	}
 
-	// Apply selectors
-	for label, selector := range q.Selectors {
-		switch label {
-		case "agent_id":
-			if v.execCtx.AgentID != nil {
-				metricQuery.AgentID = v.execCtx.AgentID
-			} else if selector.Operator == SelectorOpEqual {
-				agentID, err := uuid.Parse(selector.Value)
-				if err != nil {
-					return nil, fmt.Errorf("invalid agent_id: %v", err)
-				}
-				metricQuery.AgentID = &agentID
-			}
-		case "group_id":
-			if v.execCtx.GroupID != nil {
-				metricQuery.GroupID = v.execCtx.GroupID
-			} else if selector.Operator == SelectorOpEqual {
-				metricQuery.GroupID = &selector.Value
-			}
-		case "metric", "name", "metric_name":
-			if selector.Operator == SelectorOpEqual {
-				metricQuery.MetricName = &selector.Value
-				v.executor.logger.Debug("Setting metric name filter", zap.String("metric_name", selector.Value))
-			}
+	// Long ranges are answered from the coarsest adequate rollup tier where
+	// window averages keyed on agent, group and name are what is asked for
+	if tier, ok := v.selectRollupTier(q, startTime, endTime); ok && q == v.rollupSelector {
+		results, used, err := v.executeRollupQuery(metricQuery, tier)
+		if err != nil {
+			return nil, err
+		}
+		if used {
+			return results, nil
 		}
 	}
 
-	// Execute query
+	return v.executeRawMetricsQuery(metricQuery)
+}
+
+// executeRawMetricsQuery queries raw metric points
+func (v *ExecutorVisitor) executeRawMetricsQuery(metricQuery services.MetricQuery) ([]QueryResult, error) {
 	v.executor.logger.Debug("Querying metrics",
 		zap.Any("metric_name", metricQuery.MetricName),
 		zap.Time("start_time", metricQuery.StartTime),
 		zap.Time("end_time", metricQuery.EndTime),
 		zap.Int("limit", metricQuery.Limit))
+	v.addPlan(planStorage, "scan metrics "+describeMetricQuery(metricQuery)+describeLimit(metricQuery.Limit))
 	metrics, err := v.executor.telemetryService.QueryMetrics(v.ctx, metricQuery)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query metrics: %v", err)
+		return nil, fmt.Errorf("failed to query metrics: %w", err)
 	}
 
 	v.executor.logger.Debug("Query returned metrics", zap.Int("count", len(metrics)))
@@ -135,168 +146,460 @@ func (v *ExecutorVisitor) executeMetricsQuery(q *TelemetryQuery, startTime, endT
 	// Convert to QueryResults
 	results := make([]QueryResult, 0, len(metrics))
 	for _, metric := range metrics {
-		// Apply additional filters (regex, etc.) - but exclude special selectors that were already applied in the query
-		labelSelectors := make(map[string]*Selector)
-		for label, selector := range q.Selectors {
-			// Skip selectors that were already applied in the database query
-			if label != "agent_id" && label != "group_id" && label != "metric" && label != "name" && label != "metric_name" {
-				labelSelectors[label] = selector
-			}
+		metadata := metricMetadata(metric)
+		data := map[string]interface{}{
+			"name":        metric.Name,
+			"type":        metric.Type,
+			"agent_id":    metric.AgentID.String(),
+			"config_hash": metric.ConfigHash,
 		}
-
-		if !v.matchesSelectors(metric.Labels, labelSelectors) {
-			continue
+		for key, value := range metadata {
+			data[key] = value
+		}
+		if metric.StartTime != nil {
+			data["start_time"] = *metric.StartTime
 		}
+		addDistribution(data, metric)
 
 		results = append(results, QueryResult{
-			Type:      TelemetryTypeMetrics,
-			Timestamp: metric.Timestamp,
-			Labels:    metric.Labels,
-			Value:     metric.Value,
-			Data: map[string]interface{}{
-				"name":        metric.Name,
-				"type":        metric.Type,
-				"agent_id":    metric.AgentID.String(),
-				"config_hash": metric.ConfigHash,
-			},
+			Type:       TelemetryTypeMetrics,
+			Timestamp:  metric.Timestamp,
+			Labels:     metric.Labels,
+			Value:      metric.Value,
+			Data:       data,
+			Attributes: metric.MetricAttributes,
 		})
 	}
 
 	return results, nil
 }
 
-// executeLogsQuery executes a logs query
-func (v *ExecutorVisitor) executeLogsQuery(q *TelemetryQuery, startTime, endTime time.Time) ([]QueryResult, error) {
-	// Build log query
-	logQuery := services.LogQuery{
-		StartTime: startTime,
-		EndTime:   endTime,
-		Limit:     q.Limit,
+// addDistribution adds the observations of histograms and summaries to
+// result data
+func addDistribution(data map[string]interface{}, metric services.Metric) {
+	switch metric.Type {
+	case services.MetricTypeHistogram, services.MetricTypeExponentialHistogram, services.MetricTypeSummary:
+		data["count"] = metric.Count
+		data["sum"] = metric.Sum
+	}
+	if len(metric.BucketCounts) > 0 {
+		data["bucket_counts"] = metric.BucketCounts
+		data["explicit_bounds"] = metric.ExplicitBounds
+	}
+	if metric.Exponential != nil {
+		data["exponential"] = metric.Exponential
+	}
+	if len(metric.Quantiles) > 0 {
+		data["quantiles"] = metric.Quantiles
+	}
+}
+
+// isRollupSelector reports whether a metrics selector names a field that
+// rollups keep
+func isRollupSelector(label string) bool {
+	switch label {
+	case "agent_id", "group_id", "metric", "name", "metric_name":
+		return true
+	}
+	return false
+}
+
+// metricMetadata returns the OTLP metadata of a metric that selectors match
+// in place of attributes with the same name. Unset fields are left out.
+func metricMetadata(metric services.Metric) map[string]interface{} {
+	metadata := nonEmptyMetadata(map[string]interface{}{
+		"unit":          metric.Unit,
+		"scope_name":    metric.ScopeName,
+		"scope_version": metric.ScopeVersion,
+		"temporality":   metric.Temporality,
+	})
+	if metric.Type == services.MetricTypeCounter {
+		metadata["monotonic"] = metric.IsMonotonic
+	}
+	return metadata
+}
+
+// nonEmptyMetadata drops empty strings from metadata
+func nonEmptyMetadata(metadata map[string]interface{}) map[string]interface{} {
+	for key, value := range metadata {
+		if value == "" {
+			delete(metadata, key)
+		}
+	}
+	return metadata
+}
+
+// withMetadata returns the attributes overlaid with metadata for matching
+// selectors, leaving the attributes unchanged
+func withMetadata(attributes, metadata map[string]interface{}) map[string]interface{} {
+	if len(metadata) == 0 {
+		return attributes
+	}
+	merged := make(map[string]interface{}, len(attributes)+len(metadata))
+	for key, value := range attributes {
+		merged[key] = value
+	}
+	for key, value := range metadata {
+		merged[key] = value
+	}
+	return merged
+}
+
+// rollupTier is a rollup interval that queries can be routed to
+type rollupTier struct {
+	interval services.RollupInterval
+	window   time.Duration
+}
+
+// rollupTiers lists rollup tiers from coarsest to finest
+var rollupTiers = []rollupTier{
+	{services.RollupInterval1d, 24 * time.Hour},
+	{services.RollupInterval1h, time.Hour},
+	{services.RollupInterval5m, 5 * time.Minute},
+	{services.RollupInterval1m, time.Minute},
+}
+
+// rollupMinPoints is the number of windows a tier must provide over the
+// query range to be adequate. Shorter ranges are answered from raw data.
+const rollupMinPoints = 60
+
+// selectRollupTier returns the coarsest tier that still yields enough points
+// for the range. Rollups only keep agent, group and metric name, so queries
+// filtering on other labels or on OTLP metadata always use raw data.
+func (v *ExecutorVisitor) selectRollupTier(q *TelemetryQuery, startTime, endTime time.Time) (rollupTier, bool) {
+	if v.execCtx.DisableRollups {
+		return rollupTier{}, false
+	}
+	for _, selector := range q.Selectors {
+		if !isRollupSelector(selector.Label) || selector.Operator != SelectorOpEqual {
+			return rollupTier{}, false
+		}
 	}
 
-	// Apply selectors
-	for label, selector := range q.Selectors {
+	queryRange := endTime.Sub(startTime)
+	for _, tier := range rollupTiers {
+		if queryRange >= tier.window*rollupMinPoints {
+			return tier, true
+		}
+	}
+	return rollupTier{}, false
+}
+
+// rollupAggregations are the aggregations that are valid over the window
+// averages of rollups
+var rollupAggregations = map[string]bool{"avg": true, "min": true, "max": true}
+
+// rollupOperand returns the selector of an aggregation that may be answered
+// from rollups: avg, min or max of a metrics selector, grouped by nothing
+// but the agent, group and metric name rollups are keyed on
+func rollupOperand(function string, by []string, query Query) (*TelemetryQuery, bool) {
+	q, ok := query.(*TelemetryQuery)
+	if !ok || q.Type != TelemetryTypeMetrics || !rollupAggregations[function] {
+		return nil, false
+	}
+	for _, label := range by {
 		switch label {
-		case "agent_id":
-			if v.execCtx.AgentID != nil {
-				logQuery.AgentID = v.execCtx.AgentID
-			} else if selector.Operator == SelectorOpEqual {
-				agentID, err := uuid.Parse(selector.Value)
-				if err != nil {
-					return nil, fmt.Errorf("invalid agent_id: %v", err)
-				}
-				logQuery.AgentID = &agentID
-			}
-		case "group_id":
-			if v.execCtx.GroupID != nil {
-				logQuery.GroupID = v.execCtx.GroupID
-			} else if selector.Operator == SelectorOpEqual {
-				logQuery.GroupID = &selector.Value
-			}
-		case "severity", "level":
-			if selector.Operator == SelectorOpEqual {
-				logQuery.Severity = &selector.Value
-			}
-		case "body", "message", "search":
-			if selector.Operator == SelectorOpEqual || selector.Operator == SelectorOpRegex {
-				logQuery.Search = &selector.Value
+		case "agent_id", "group_id", "name":
+		default:
+			return nil, false
+		}
+	}
+	return q, true
+}
+
+// executeRollupQuery answers a metrics query from a rollup tier. Windows
+// after the newest rollup, which the scheduler has not generated yet, are
+// filled from raw data. It reports false if the tier has no data for the
+// range so the caller can fall back to raw data.
+func (v *ExecutorVisitor) executeRollupQuery(metricQuery services.MetricQuery, tier rollupTier) ([]QueryResult, bool, error) {
+	rollups, err := v.executor.telemetryService.QueryRollups(v.ctx, services.RollupQuery{
+		AgentID:    metricQuery.AgentID,
+		GroupID:    metricQuery.GroupID,
+		MetricName: metricQuery.MetricName,
+		StartTime:  metricQuery.StartTime,
+		EndTime:    metricQuery.EndTime,
+		Interval:   tier.interval,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query rollups: %w", err)
+	}
+	if len(rollups) == 0 {
+		return nil, false, nil
+	}
+
+	v.executor.logger.Debug("Query routed to rollups",
+		zap.String("interval", string(tier.interval)),
+		zap.Int("count", len(rollups)))
+	v.usedRollups = true
+	v.addPlan(planStorage, fmt.Sprintf("scan rollups_%s %s", tier.interval, describeMetricQuery(metricQuery)))
+
+	covered := metricQuery.StartTime
+	for _, r := range rollups {
+		if end := r.WindowStart.Add(tier.window); end.After(covered) {
+			covered = end
+		}
+	}
+
+	// Raw points are newer than every rollup. They are rolled up into
+	// windows of the tier the same way, and come first in the newest-first
+	// result order.
+	if covered.Before(metricQuery.EndTime) {
+		tailQuery := metricQuery
+		tailQuery.StartTime = covered
+		v.addPlan(planStorage, fmt.Sprintf("scan metrics %s%s into %s windows", describeMetricQuery(tailQuery), describeLimit(tailQuery.Limit), tier.interval))
+		tail, err := v.executor.telemetryService.QueryMetrics(v.ctx, tailQuery)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to query metrics: %w", err)
+		}
+		rollups = append(rollUp(tail, tier), rollups...)
+	}
+
+	results := make([]QueryResult, len(rollups))
+	for i, r := range rollups {
+		results[i] = rollupToResult(r)
+	}
+	return results, true, nil
+}
+
+// rollUp aggregates raw points into the windows of a rollup tier per agent,
+// group and metric name, newest window first
+func rollUp(metrics []services.Metric, tier rollupTier) []services.Rollup {
+	byWindow := make(map[string]*services.Rollup)
+	var windows []*services.Rollup
+	for _, metric := range metrics {
+		windowStart := metric.Timestamp.Truncate(tier.window)
+		var groupID string
+		if metric.GroupID != nil {
+			groupID = *metric.GroupID
+		}
+		key := fmt.Sprintf("%d|%s|%s|%s", windowStart.UnixNano(), metric.AgentID, groupID, metric.Name)
+		r, ok := byWindow[key]
+		if !ok {
+			agentID := metric.AgentID
+			r = &services.Rollup{
+				WindowStart: windowStart,
+				AgentID:     &agentID,
+				GroupID:     metric.GroupID,
+				MetricName:  metric.Name,
+				MetricType:  string(metric.Type),
+				Interval:    tier.interval,
+				Min:         metric.Value,
+				Max:         metric.Value,
 			}
+			byWindow[key] = r
+			windows = append(windows, r)
+		}
+		r.Count++
+		r.Sum += metric.Value
+		r.Min = min(r.Min, metric.Value)
+		r.Max = max(r.Max, metric.Value)
+	}
+
+	rollups := make([]services.Rollup, len(windows))
+	for i, r := range windows {
+		r.Avg = r.Sum / float64(r.Count)
+		rollups[i] = *r
+	}
+	sort.SliceStable(rollups, func(i, j int) bool { return rollups[i].WindowStart.After(rollups[j].WindowStart) })
+	return rollups
+}
+
+// rollupToResult converts a rollup window to a query result labelled with
+// the agent, group and metric name it aggregates. The value is the window
+// average; the other aggregates and the interval are returned in Data.
+func rollupToResult(r services.Rollup) QueryResult {
+	data := map[string]interface{}{
+		"name":     r.MetricName,
+		"type":     r.MetricType,
+		"interval": string(r.Interval),
+		"count":    r.Count,
+		"sum":      r.Sum,
+		"min":      r.Min,
+		"max":      r.Max,
+	}
+	labels := map[string]string{"name": r.MetricName}
+	if r.AgentID != nil {
+		data["agent_id"] = r.AgentID.String()
+		if *r.AgentID != uuid.Nil {
+			labels["agent_id"] = r.AgentID.String()
+		}
+	}
+	if r.GroupID != nil {
+		data["group_id"] = *r.GroupID
+		labels["group_id"] = *r.GroupID
+	}
+	if len(r.BucketCounts) > 0 {
+		data["bucket_counts"] = r.BucketCounts
+		data["explicit_bounds"] = r.ExplicitBounds
+	}
+	if r.Exponential != nil {
+		data["exponential"] = r.Exponential
+	}
+	if len(r.Quantiles) > 0 {
+		data["quantiles"] = r.Quantiles
+	}
+
+	return QueryResult{
+		Type:      TelemetryTypeMetrics,
+		Timestamp: r.WindowStart,
+		Labels:    labels,
+		Value:     r.Avg,
+		Data:      data,
+	}
+}
+
+// executeLogsQuery executes a logs query
+func (v *ExecutorVisitor) executeLogsQuery(q *TelemetryQuery, startTime, endTime time.Time) ([]QueryResult, error) {
+	results, _, err := v.scanLogs(q, startTime, endTime)
+	return results, err
+}
+
+// scanLogs executes a logs query and also reports whether the scan stopped
+// at its limit, so that lines past it were not read
+func (v *ExecutorVisitor) scanLogs(q *TelemetryQuery, startTime, endTime time.Time) ([]QueryResult, bool, error) {
+	logQuery, err := v.planLogQuery(q, startTime, endTime)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Run the pipeline stages the store does not evaluate over the scanned
+	// lines, reading past the limit when stages drop lines
+	stages := q.Pipeline[pushedStages(q.Pipeline):]
+	if len(stages) > 0 {
+		defer v.enterPlan(planExecutor, "pipeline "+describePipeline(stages)+describeLimit(q.Limit))()
+		if filtersLines(stages) && logQuery.Limit < pipelineSampleLimit {
+			logQuery.Limit = pipelineSampleLimit
 		}
 	}
 
 	// Execute query
+	v.addPlan(planStorage, "scan logs "+describeLogQuery(logQuery)+describeLimit(logQuery.Limit))
 	logs, err := v.executor.telemetryService.QueryLogs(v.ctx, logQuery)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query logs: %v", err)
+		return nil, false, fmt.Errorf("failed to query logs: %w", err)
 	}
+	truncated := logQuery.Limit > 0 && len(logs) >= logQuery.Limit
 
 	// Convert to QueryResults
 	results := make([]QueryResult, 0, len(logs))
 	for _, log := range logs {
-		// Apply additional filters
-		if !v.matchesSelectors(convertToStringMap(log.LogAttributes), q.Selectors) {
-			continue
-		}
-
 		results = append(results, QueryResult{
 			Type:      TelemetryTypeLogs,
 			Timestamp: log.Timestamp,
 			Labels:    convertToStringMap(log.LogAttributes),
 			Value:     log.Body,
 			Data: map[string]interface{}{
-				"severity":    log.SeverityText,
-				"agent_id":    log.AgentID.String(),
-				"config_hash": log.ConfigHash,
+				"severity":      log.SeverityText,
+				"agent_id":      log.AgentID.String(),
+				"config_hash":   log.ConfigHash,
+				"scope_name":    log.ScopeName,
+				"scope_version": log.ScopeVersion,
+				"flags":         log.Flags,
 			},
+			Attributes: log.LogAttributes,
 		})
 	}
 
-	return results, nil
+	if len(stages) > 0 {
+		if results, err = v.runPipeline(stages, results); err != nil {
+			return nil, false, err
+		}
+		if q.Limit > 0 && len(results) > q.Limit {
+			results = results[:q.Limit]
+		}
+	}
+
+	return results, truncated, nil
 }
 
-// executeTracesQuery executes a traces query
+// executeTracesQuery executes a traces query as a trace search and returns
+// the matching spans
 func (v *ExecutorVisitor) executeTracesQuery(q *TelemetryQuery, startTime, endTime time.Time) ([]QueryResult, error) {
-	// Build trace query
-	traceQuery := services.TraceQuery{
-		StartTime: startTime,
-		EndTime:   endTime,
-		Limit:     q.Limit,
+	search, err := v.planTraceSearch(q, q.Limit)
+	if err != nil {
+		return nil, err
 	}
+	search.StartTime, search.EndTime = startTime, endTime
 
-	// Apply selectors
-	for label, selector := range q.Selectors {
-		switch label {
-		case "agent_id":
-			if v.execCtx.AgentID != nil {
-				traceQuery.AgentID = v.execCtx.AgentID
-			} else if selector.Operator == SelectorOpEqual {
-				agentID, err := uuid.Parse(selector.Value)
-				if err != nil {
-					return nil, fmt.Errorf("invalid agent_id: %v", err)
-				}
-				traceQuery.AgentID = &agentID
-			}
-		case "group_id":
-			if v.execCtx.GroupID != nil {
-				traceQuery.GroupID = v.execCtx.GroupID
-			} else if selector.Operator == SelectorOpEqual {
-				traceQuery.GroupID = &selector.Value
-			}
-		case "trace_id":
-			if selector.Operator == SelectorOpEqual {
-				traceQuery.TraceID = &selector.Value
-			}
+	v.addPlan(planStorage, "search traces "+describeTraceSearch(search)+describeLimit(search.Limit))
+	matches, err := v.executor.telemetryService.SearchTraces(v.ctx, search)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search traces: %w", err)
+	}
+
+	// Convert to QueryResults
+	var results []QueryResult
+	for _, match := range matches {
+		for _, span := range match.Spans {
+			results = append(results, spanResult(span))
 		}
 	}
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
 
-	// Execute query
-	traces, err := v.executor.telemetryService.QueryTraces(v.ctx, traceQuery)
+	return results, nil
+}
+
+// spanResult converts a span to a query result
+func spanResult(trace services.Trace) QueryResult {
+	return QueryResult{
+		Type:       TelemetryTypeTraces,
+		Timestamp:  trace.Timestamp,
+		Labels:     convertToStringMap(trace.Attributes),
+		Attributes: trace.Attributes,
+		Value:      trace.Duration,
+		Data: map[string]interface{}{
+			"trace_id":       trace.TraceID,
+			"span_id":        trace.SpanID,
+			"parent_span_id": trace.ParentSpanID,
+			"name":           trace.Name,
+			"service_name":   trace.ServiceName,
+			"status_code":    trace.StatusCode,
+			"status_message": trace.StatusMessage,
+			"span_kind":      trace.SpanKind,
+			"trace_state":    trace.TraceState,
+			"scope_name":     trace.ScopeName,
+			"scope_version":  trace.ScopeVersion,
+			"agent_id":       trace.AgentID.String(),
+			"config_hash":    trace.ConfigHash,
+		},
+	}
+}
+
+// VisitStructuralQuery executes a structural query in the telemetry store.
+// It returns one result per matching trace, newest first, whose value is
+// the number of spans that matched and whose data holds those spans.
+func (v *ExecutorVisitor) VisitStructuralQuery(s *StructuralQuery) (interface{}, error) {
+	search, err := v.planTraceSearch(s, v.execCtx.Limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query traces: %v", err)
+		return nil, err
 	}
 
-	// Convert to QueryResults
-	results := make([]QueryResult, 0, len(traces))
-	for _, trace := range traces {
-		// Apply additional filters
-		if !v.matchesSelectors(trace.Attributes, q.Selectors) {
-			continue
-		}
+	v.addPlan(planStorage, "search traces "+describeTraceSearch(search)+describeLimit(search.Limit))
+	matches, err := v.executor.telemetryService.SearchTraces(v.ctx, search)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search traces: %w", err)
+	}
 
+	results := make([]QueryResult, 0, len(matches))
+	for _, match := range matches {
+		spans := make([]QueryResult, len(match.Spans))
+		var newest time.Time
+		for i, span := range match.Spans {
+			spans[i] = spanResult(span)
+			if span.Timestamp.After(newest) {
+				newest = span.Timestamp
+			}
+		}
 		results = append(results, QueryResult{
 			Type:      TelemetryTypeTraces,
-			Timestamp: trace.Timestamp,
-			Labels:    trace.Attributes,
-			Value:     trace.Duration,
+			Timestamp: newest,
+			Labels:    map[string]string{"trace_id": match.TraceID},
+			Value:     len(match.Spans),
 			Data: map[string]interface{}{
-				"trace_id":       trace.TraceID,
-				"span_id":        trace.SpanID,
-				"parent_span_id": trace.ParentSpanID,
-				"name":           trace.Name,
-				"status_code":    trace.StatusCode,
-				"status_message": trace.StatusMessage,
-				"agent_id":       trace.AgentID.String(),
-				"config_hash":    trace.ConfigHash,
+				"trace_id": match.TraceID,
+				"spans":    spans,
 			},
 		})
 	}
@@ -304,51 +607,97 @@ func (v *ExecutorVisitor) executeTracesQuery(q *TelemetryQuery, startTime, endTi
 	return results, nil
 }
 
-// VisitBinaryOp executes a binary operation
+// VisitBinaryOp executes a binary operation. Between numeric samples it
+// pairs the latest sample of each series of one operand with the matching
+// series of the other, or applies a number operand to every series. Raw
+// logs and spans are merged by + and or, and intersected by time by and.
 func (v *ExecutorVisitor) VisitBinaryOp(b *BinaryOp) (interface{}, error) {
+	defer v.enterPlan(planExecutor, describeBinaryOp(b.Operator, b.Matching))()
+
+	leftScalar, leftIsScalar := scalarValue(b.Left)
+	rightScalar, rightIsScalar := scalarValue(b.Right)
+	if leftIsScalar && rightIsScalar {
+		return nil, fmt.Errorf("binary operation %s requires at least one query operand", b.Operator)
+	}
+
 	// Execute left and right queries
-	leftResults, err := b.Left.Accept(v)
-	if err != nil {
-		return nil, err
+	var leftQueryResults, rightQueryResults []QueryResult
+	if !leftIsScalar {
+		leftResults, err := b.Left.Accept(v)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		leftQueryResults, ok = leftResults.([]QueryResult)
+		if !ok {
+			return nil, fmt.Errorf("left operand did not return query results")
+		}
 	}
-	leftQueryResults, ok := leftResults.([]QueryResult)
-	if !ok {
-		return nil, fmt.Errorf("left operand did not return query results")
+	if !rightIsScalar {
+		rightResults, err := b.Right.Accept(v)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		rightQueryResults, ok = rightResults.([]QueryResult)
+		if !ok {
+			return nil, fmt.Errorf("right operand did not return query results")
+		}
 	}
 
-	rightResults, err := b.Right.Accept(v)
-	if err != nil {
-		return nil, err
-	}
-	rightQueryResults, ok := rightResults.([]QueryResult)
-	if !ok {
-		return nil, fmt.Errorf("right operand did not return query results")
+	switch {
+	case leftIsScalar:
+		return binaryScalar(b.Operator, instantVector(rightQueryResults), leftScalar, true)
+	case rightIsScalar:
+		return binaryScalar(b.Operator, instantVector(leftQueryResults), rightScalar, false)
+	case slices.ContainsFunc(leftQueryResults, isRaw) || slices.ContainsFunc(rightQueryResults, isRaw):
+		return v.combineRaw(b.Operator, leftQueryResults, rightQueryResults)
 	}
+	return binaryVectors(b.Operator, b.Matching, instantVector(leftQueryResults), instantVector(rightQueryResults))
+}
+
+// isRaw reports whether a result is a raw log line or span
+func isRaw(r QueryResult) bool {
+	return !isSample(r)
+}
 
-	// Apply binary operation
-	switch b.Operator {
-	case BinaryOpAdd:
+// combineRaw applies a binary operator to raw logs and spans
+func (v *ExecutorVisitor) combineRaw(op BinaryOperator, left, right []QueryResult) ([]QueryResult, error) {
+	switch op {
+	case BinaryOpAdd, BinaryOpOr:
 		// Merge results
-		return append(leftQueryResults, rightQueryResults...), nil
+		return append(left, right...), nil
 	case BinaryOpAnd:
 		// Intersection based on timestamp and labels
-		return v.intersectResults(leftQueryResults, rightQueryResults), nil
-	case BinaryOpOr:
-		// Union - just merge
-		return append(leftQueryResults, rightQueryResults...), nil
+		return v.intersectResults(left, right), nil
 	default:
-		return nil, fmt.Errorf("unsupported binary operator: %s", b.Operator)
+		return nil, fmt.Errorf("binary operator %s requires numeric operands, not raw logs or traces", op)
 	}
 }
 
 // VisitFunctionCall executes a function call
 func (v *ExecutorVisitor) VisitFunctionCall(f *FunctionCall) (interface{}, error) {
-	if len(f.Args) == 0 {
-		return nil, fmt.Errorf("function %s requires at least one argument", f.Name)
+	params, arg, err := functionOperands(f.Name, f.Args)
+	if err != nil {
+		return nil, err
 	}
+	function, err := resolveFunction(f.Name, params, arg)
+	if err != nil {
+		return nil, err
+	}
+	if isOverTime(f.Name, arg) {
+		return v.overTime(f.Name, arg, nil)
+	}
+	if results, ok, err := v.pushDownFunction(f); ok || err != nil {
+		return results, err
+	}
+	if q, ok := rollupOperand(f.Name, nil, arg); ok {
+		v.rollupSelector = q
+	}
+	defer v.enterPlan(planExecutor, f.Name)()
 
 	// Execute the argument query
-	argResults, err := f.Args[0].Accept(v)
+	argResults, err := arg.Accept(v)
 	if err != nil {
 		return nil, err
 	}
@@ -356,11 +705,8 @@ func (v *ExecutorVisitor) VisitFunctionCall(f *FunctionCall) (interface{}, error
 	if !ok {
 		return nil, fmt.Errorf("function argument did not return query results")
 	}
-
-	// Apply function
-	function, ok := GetFunction(f.Name)
-	if !ok {
-		return nil, fmt.Errorf("unknown function: %s", f.Name)
+	if vectorFunctions[f.Name] {
+		queryResults = instantVector(queryResults)
 	}
 
 	return function.Apply(queryResults)
@@ -368,6 +714,21 @@ func (v *ExecutorVisitor) VisitFunctionCall(f *FunctionCall) (interface{}, error
 
 // VisitAggregation executes an aggregation query
 func (v *ExecutorVisitor) VisitAggregation(a *Aggregation) (interface{}, error) {
+	function, err := resolveFunction(a.Function, aggregationParams(a), a.Query)
+	if err != nil {
+		return nil, err
+	}
+	if isOverTime(a.Function, a.Query) {
+		return v.overTime(a.Function, a.Query, a.By)
+	}
+	if results, ok, err := v.pushDownAggregation(a.Query, a.Function, a.By); ok || err != nil {
+		return results, err
+	}
+	if q, ok := rollupOperand(a.Function, a.By, a.Query); ok {
+		v.rollupSelector = q
+	}
+	defer v.enterPlan(planExecutor, describeBy(a.Function, a.By))()
+
 	// Execute the inner query
 	queryResults, err := a.Query.Accept(v)
 	if err != nil {
@@ -377,11 +738,8 @@ func (v *ExecutorVisitor) VisitAggregation(a *Aggregation) (interface{}, error)
 	if !ok {
 		return nil, fmt.Errorf("aggregation query did not return query results")
 	}
-
-	// Apply aggregation
-	function, ok := GetFunction(a.Function)
-	if !ok {
-		return nil, fmt.Errorf("unknown aggregation function: %s", a.Function)
+	if vectorFunctions[a.Function] {
+		results = instantVector(results)
 	}
 
 	// Group by labels if specified
@@ -393,6 +751,17 @@ func (v *ExecutorVisitor) VisitAggregation(a *Aggregation) (interface{}, error)
 	return function.Apply(results)
 }
 
+// VisitNumberLiteral rejects numbers outside of function arguments and
+// binary operations
+func (v *ExecutorVisitor) VisitNumberLiteral(n *NumberLiteral) (interface{}, error) {
+	return nil, fmt.Errorf("number %v can only be used as a function argument or operand", n.Value)
+}
+
+// VisitStringLiteral rejects strings outside of function arguments
+func (v *ExecutorVisitor) VisitStringLiteral(s *StringLiteral) (interface{}, error) {
+	return nil, fmt.Errorf("string %q can only be used as a function argument", s.Value)
+}
+
 // getTimeRange determines the time range for the query
 func (v *ExecutorVisitor) getTimeRange(q *TelemetryQuery) (time.Time, time.Time) {
 	now := time.Now()
@@ -416,18 +785,20 @@ func (v *ExecutorVisitor) getTimeRange(q *TelemetryQuery) (time.Time, time.Time)
 	return now.Add(-5 * time.Minute), now
 }
 
-// matchesSelectors checks if labels match all selectors
-func (v *ExecutorVisitor) matchesSelectors(labels map[string]string, selectors map[string]*Selector) bool {
-	for label, selector := range selectors {
-		value, ok := labels[label]
+// matchesSelectors checks if typed attributes match all selectors. Number
+// literals are compared numerically; everything else by string form.
+func (v *ExecutorVisitor) matchesSelectors(attributes map[string]interface{}, selectors map[string]*Selector) bool {
+	for _, selector := range selectors {
+		raw, ok := attributes[selector.Label]
+		value := otlp.AttributeString(raw)
 
 		switch selector.Operator {
 		case SelectorOpEqual:
-			if !ok || value != selector.Value {
+			if !ok || !selectorEquals(selector, raw, value) {
 				return false
 			}
 		case SelectorOpNotEqual:
-			if ok && value == selector.Value {
+			if ok && selectorEquals(selector, raw, value) {
 				return false
 			}
 		case SelectorOpRegex:
@@ -446,11 +817,51 @@ func (v *ExecutorVisitor) matchesSelectors(labels map[string]string, selectors m
 			if err != nil || matched {
 				return false
 			}
+		case SelectorOpGT, SelectorOpGTE, SelectorOpLT, SelectorOpLTE:
+			if !ok || !compareNumeric(selector, raw) {
+				return false
+			}
 		}
 	}
 	return true
 }
 
+// selectorEquals compares an attribute value with a selector value
+func selectorEquals(selector *Selector, raw interface{}, value string) bool {
+	if selector.Numeric {
+		if number, ok := otlp.AttributeFloat(raw); ok {
+			expected, _ := strconv.ParseFloat(selector.Value, 64)
+			return number == expected
+		}
+	}
+	return value == selector.Value
+}
+
+// compareNumeric applies an ordering selector to a numeric attribute value.
+// Non-numeric values never match.
+func compareNumeric(selector *Selector, raw interface{}) bool {
+	number, ok := otlp.AttributeFloat(raw)
+	if !ok {
+		return false
+	}
+	expected, err := strconv.ParseFloat(selector.Value, 64)
+	if err != nil {
+		return false
+	}
+
+	switch selector.Operator {
+	case SelectorOpGT:
+		return number > expected
+	case SelectorOpGTE:
+		return number >= expected
+	case SelectorOpLT:
+		return number < expected
+	case SelectorOpLTE:
+		return number <= expected
+	}
+	return false
+}
+
 // intersectResults returns results that appear in both sets based on timestamp and labels
 func (v *ExecutorVisitor) intersectResults(left, right []QueryResult) []QueryResult {
 	results := []QueryResult{}
@@ -544,11 +955,7 @@ func (v *ExecutorVisitor) groupAndAggregate(results []QueryResult, byLabels []st
 	return aggregated, nil
 }
 
-// convertToStringMap converts map[string]interface{} to map[string]string
+// convertToStringMap converts typed attributes to string labels
 func convertToStringMap(m map[string]interface{}) map[string]string {
-	result := make(map[string]string)
-	for k, v := range m {
-		result[k] = fmt.Sprintf("%v", v)
-	}
-	return result
+	return otlp.StringifyAttributes(m)
 }
@@ -0,0 +1,566 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// stubTelemetryService serves fixed metrics and rollups and records the
+// queries it receives. Metrics are filtered the way the store filters them.
+type stubTelemetryService struct {
+	services.TelemetryQueryService
+	metrics          []services.Metric
+	rollups          []services.Rollup
+	aggregates       []services.Aggregate
+	metricQueries    []services.MetricQuery
+	rollupQueries    []services.RollupQuery
+	aggregateQueries []services.MetricAggregateQuery
+	increaseQueries  []services.MetricQuery
+	logs             []services.Log
+	logQueries       []services.LogQuery
+	logAggregates    []services.LogAggregateQuery
+	traceMatches     []services.TraceMatch
+	traceSearches    []services.TraceSearchQuery
+}
+
+func (s *stubTelemetryService) QueryMetrics(ctx context.Context, query services.MetricQuery) ([]services.Metric, error) {
+	s.metricQueries = append(s.metricQueries, query)
+	var metrics []services.Metric
+	for _, metric := range s.metrics {
+		if query.Limit > 0 && len(metrics) == query.Limit {
+			break
+		}
+		if stubMatches(metric, query) {
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics, nil
+}
+
+func (s *stubTelemetryService) QueryRollups(ctx context.Context, query services.RollupQuery) ([]services.Rollup, error) {
+	s.rollupQueries = append(s.rollupQueries, query)
+	rollups := make([]services.Rollup, len(s.rollups))
+	for i, r := range s.rollups {
+		r.Interval = query.Interval
+		rollups[i] = r
+	}
+	return rollups, nil
+}
+
+func (s *stubTelemetryService) AggregateMetrics(ctx context.Context, query services.MetricAggregateQuery) ([]services.Aggregate, error) {
+	s.aggregateQueries = append(s.aggregateQueries, query)
+	return s.aggregates, nil
+}
+
+func (s *stubTelemetryService) QueryMetricIncrease(ctx context.Context, query services.MetricQuery) ([]services.MetricIncrease, error) {
+	s.increaseQueries = append(s.increaseQueries, query)
+	// Points are summarized per series like the store does
+	points := make(map[string][]QueryResult)
+	var series []services.Metric
+	for _, metric := range s.metrics {
+		if !stubMatches(metric, query) {
+			continue
+		}
+		key := fmt.Sprintf("%s|%s|%s|%s", metric.Name, metric.AgentID, metric.ServiceName, labelsKey(metric.Labels))
+		if _, ok := points[key]; !ok {
+			series = append(series, metric)
+		}
+		data := map[string]interface{}{"temporality": metric.Temporality, "monotonic": metric.IsMonotonic}
+		if metric.StartTime != nil {
+			data["start_time"] = *metric.StartTime
+		}
+		points[key] = append(points[key], QueryResult{Timestamp: metric.Timestamp, Value: metric.Value, Data: data})
+	}
+
+	summaries := make([]services.MetricIncrease, 0, len(series))
+	for _, metric := range series {
+		stats, err := seriesStats(points[fmt.Sprintf("%s|%s|%s|%s", metric.Name, metric.AgentID, metric.ServiceName, labelsKey(metric.Labels))])
+		if err != nil {
+			return nil, err
+		}
+		stats.MetricName, stats.AgentID, stats.ServiceName, stats.Labels = metric.Name, metric.AgentID, metric.ServiceName, metric.Labels
+		summaries = append(summaries, stats)
+	}
+	return summaries, nil
+}
+
+func (s *stubTelemetryService) QueryLogs(ctx context.Context, query services.LogQuery) ([]services.Log, error) {
+	s.logQueries = append(s.logQueries, query)
+	var logs []services.Log
+	for _, log := range s.logs {
+		if len(logs) == query.Limit {
+			break
+		}
+		if stubFilters(withMetadata(log.LogAttributes, map[string]interface{}{"body": log.Body}), query.Filters) {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+func (s *stubTelemetryService) AggregateLogs(ctx context.Context, query services.LogAggregateQuery) ([]services.Aggregate, error) {
+	s.logAggregates = append(s.logAggregates, query)
+	// Logs are counted per group of the by fields, and per series if asked
+	groups := make(map[string]int)
+	var aggregates []services.Aggregate
+	for _, log := range s.logs {
+		if !stubFilters(withMetadata(log.LogAttributes, map[string]interface{}{"body": log.Body}), query.Query.Filters) {
+			continue
+		}
+		attributes := convertToStringMap(log.LogAttributes)
+		labels := make(map[string]string)
+		for _, field := range query.By {
+			if value, ok := attributes[field]; ok {
+				labels[field] = value
+			}
+		}
+		if query.BySeries {
+			for name, value := range attributes {
+				labels[name] = value
+			}
+			if log.AgentID != uuid.Nil {
+				labels["agent_id"] = log.AgentID.String()
+			}
+		}
+		key := labelsKey(labels)
+		i, ok := groups[key]
+		if !ok {
+			i = len(aggregates)
+			groups[key] = i
+			aggregates = append(aggregates, services.Aggregate{Labels: labels})
+		}
+		aggregates[i].Value++
+		aggregates[i].Count++
+		if log.Timestamp.After(aggregates[i].Timestamp) {
+			aggregates[i].Timestamp = log.Timestamp
+		}
+	}
+	return aggregates, nil
+}
+
+func (s *stubTelemetryService) SearchTraces(ctx context.Context, query services.TraceSearchQuery) ([]services.TraceMatch, error) {
+	s.traceSearches = append(s.traceSearches, query)
+	return s.traceMatches, nil
+}
+
+// stubMatches applies the metadata fields and filters of a query
+func stubMatches(metric services.Metric, query services.MetricQuery) bool {
+	for _, field := range []struct {
+		want *string
+		got  string
+	}{
+		{query.MetricName, metric.Name},
+		{query.Unit, metric.Unit},
+		{query.ScopeName, metric.ScopeName},
+		{query.Temporality, metric.Temporality},
+	} {
+		if field.want != nil && *field.want != field.got {
+			return false
+		}
+	}
+	return stubFilters(withMetadata(metric.MetricAttributes, metricMetadata(metric)), query.Filters)
+}
+
+// stubFilters applies storage filters to attributes
+func stubFilters(attributes map[string]interface{}, filters []services.Filter) bool {
+	selectors := make(map[string]*Selector, len(filters))
+	for i, filter := range filters {
+		selectors[fmt.Sprint(i)] = &Selector{
+			Label:    filter.Field,
+			Operator: SelectorOperator(filter.Operator),
+			Value:    filter.Value,
+			Numeric:  filter.Numeric,
+		}
+	}
+	visitor := &ExecutorVisitor{}
+	return visitor.matchesSelectors(attributes, selectors)
+}
+
+func executeQuery(t *testing.T, service services.TelemetryQueryService, input string, start, end time.Time) ([]QueryResult, *QueryMeta) {
+	parsed, err := NewParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	results, meta, err := NewExecutor(service, zap.NewNop()).Execute(context.Background(), parsed, &ExecutionContext{
+		StartTime: &start,
+		EndTime:   &end,
+	})
+	if err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	return results, meta
+}
+
+func TestExecutor_RoutesLongRangesToRollups(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		rangeLen time.Duration
+		interval services.RollupInterval
+	}{
+		{"two hours uses 1m", 2 * time.Hour, services.RollupInterval1m},
+		{"one day uses 5m", 24 * time.Hour, services.RollupInterval5m},
+		{"one week uses 1h", 7 * 24 * time.Hour, services.RollupInterval1h},
+		{"ninety days uses 1d", 90 * 24 * time.Hour, services.RollupInterval1d},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &stubTelemetryService{
+				rollups: []services.Rollup{{WindowStart: end.Add(-2 * time.Hour), MetricName: "cpu", Count: 4, Sum: 10, Avg: 2.5}},
+			}
+			_, meta := executeQuery(t, service, `metrics{metric="cpu"}`, end.Add(-tt.rangeLen), end)
+
+			if !meta.UsedRollups {
+				t.Fatal("Expected query to use rollups")
+			}
+			if len(service.rollupQueries) != 1 || service.rollupQueries[0].Interval != tt.interval {
+				t.Fatalf("Expected one %s rollup query, got %+v", tt.interval, service.rollupQueries)
+			}
+		})
+	}
+}
+
+func TestExecutor_RollupResultsWithRawTail(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	windowStart := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	service := &stubTelemetryService{
+		rollups: []services.Rollup{{WindowStart: windowStart, MetricName: "cpu", MetricType: "gauge", Count: 4, Sum: 10, Avg: 2.5, Min: 1, Max: 4}},
+		metrics: []services.Metric{{Timestamp: end.Add(-time.Minute), Name: "cpu", Value: 7}},
+	}
+
+	results, meta := executeQuery(t, service, `metrics{metric="cpu"}`, end.Add(-24*time.Hour), end)
+	if !meta.UsedRollups {
+		t.Fatal("Expected query to use rollups")
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected raw tail and rollup results, got %d", len(results))
+	}
+	if results[0].Value != 7.0 || results[1].Value != 2.5 {
+		t.Errorf("Expected raw point before rollup window, got %v and %v", results[0].Value, results[1].Value)
+	}
+	if results[1].Data["max"] != 4.0 || results[1].Data["interval"] != "5m" {
+		t.Errorf("Unexpected rollup data: %v", results[1].Data)
+	}
+	// The raw tail is rolled up into windows of the same tier and series
+	if results[0].Data["interval"] != "5m" || !results[0].Timestamp.Equal(end.Add(-5*time.Minute)) || results[0].Labels["name"] != "cpu" || results[1].Labels["name"] != "cpu" {
+		t.Errorf("Expected the raw tail as a rollup window, got %+v", results[0])
+	}
+
+	// The raw tail starts where the newest rollup window ends
+	if len(service.metricQueries) != 1 || !service.metricQueries[0].StartTime.Equal(windowStart.Add(5*time.Minute)) {
+		t.Fatalf("Expected raw query from end of newest window, got %+v", service.metricQueries)
+	}
+}
+
+func TestExecutor_RoutesOnlyRollupAggregations(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	agentA, agentB := uuid.New(), uuid.New()
+	rollups := []services.Rollup{
+		{WindowStart: end.Add(-2 * time.Hour), AgentID: &agentA, MetricName: "cpu", MetricType: "gauge", Count: 4, Sum: 8, Avg: 2},
+		{WindowStart: end.Add(-2 * time.Hour), AgentID: &agentB, MetricName: "cpu", MetricType: "gauge", Count: 4, Sum: 40, Avg: 10},
+	}
+
+	// avg, min and max by the labels rollups are keyed on use rollups
+	service := &stubTelemetryService{rollups: rollups}
+	results, meta := executeQuery(t, service, `max(metrics{metric="cpu"}) by (agent_id)`, end.Add(-24*time.Hour), end)
+	if !meta.UsedRollups || len(results) != 2 {
+		t.Fatalf("Expected a rollup result per agent, got %+v", results)
+	}
+	for _, r := range results {
+		if want := map[string]float64{agentA.String(): 2, agentB.String(): 10}[r.Labels["agent_id"]]; r.Value != want {
+			t.Errorf("Unexpected max for agent %s: %v", r.Labels["agent_id"], r.Value)
+		}
+	}
+
+	// Other aggregations, groupings and functions read raw data
+	for _, input := range []string{
+		`sum(metrics{metric="cpu"}) by (agent_id)`,
+		`avg(metrics{metric="cpu"}) by (service_name)`,
+		`count(metrics{metric="cpu"})`,
+		`histogram_quantile(0.9, metrics{metric="cpu"} [5m])`,
+		`abs(metrics{metric="cpu"})`,
+	} {
+		service := &stubTelemetryService{rollups: rollups}
+		parsed, err := NewParser(input).Parse()
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", input, err)
+		}
+		start := end.Add(-24 * time.Hour)
+		_, meta, _ := NewExecutor(service, zap.NewNop()).Execute(context.Background(), parsed, &ExecutionContext{StartTime: &start, EndTime: &end})
+		if len(service.rollupQueries) != 0 || (meta != nil && meta.UsedRollups) {
+			t.Errorf("Expected %q to read raw data, got rollup queries %+v", input, service.rollupQueries)
+		}
+	}
+}
+
+func TestExecutor_UsesRawData(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query string
+		start time.Time
+	}{
+		{"short range", `metrics{metric="cpu"}`, end.Add(-30 * time.Minute)},
+		{"attribute selector", `metrics{metric="cpu", host="a"}`, end.Add(-24 * time.Hour)},
+		{"regex selector", `metrics{metric=~"cpu.*"}`, end.Add(-24 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &stubTelemetryService{rollups: []services.Rollup{{WindowStart: end.Add(-time.Hour)}}}
+			_, meta := executeQuery(t, service, tt.query, tt.start, end)
+			if meta.UsedRollups || len(service.rollupQueries) != 0 {
+				t.Errorf("Expected raw query, got rollup queries %+v", service.rollupQueries)
+			}
+		})
+	}
+
+	// An empty rollup tier falls back to raw data
+	service := &stubTelemetryService{}
+	_, meta := executeQuery(t, service, `metrics{metric="cpu"}`, end.Add(-24*time.Hour), end)
+	if meta.UsedRollups || len(service.metricQueries) != 1 {
+		t.Errorf("Expected fallback to raw data, got %d metric queries", len(service.metricQueries))
+	}
+}
+
+func TestExecutor_MetadataSelectors(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	service := &stubTelemetryService{
+		metrics: []services.Metric{
+			{Timestamp: end.Add(-time.Minute), Name: "requests", Type: services.MetricTypeCounter, Value: 3,
+				Unit: "1", ScopeName: "otelhttp", ScopeVersion: "0.49.0", Temporality: "delta", IsMonotonic: true},
+			{Timestamp: end.Add(-time.Minute), Name: "requests", Type: services.MetricTypeCounter, Value: 40,
+				Unit: "1", ScopeName: "otelgrpc", Temporality: "cumulative", IsMonotonic: true,
+				MetricAttributes: map[string]interface{}{"scope_name": "shadowed"}},
+		},
+	}
+
+	tests := []struct {
+		query string
+		value float64
+	}{
+		{`metrics{metric="requests", temporality="delta"}`, 3},
+		{`metrics{metric="requests", scope_name="otelgrpc"}`, 40},
+		{`metrics{metric="requests", scope_version=~"0.49.*"}`, 3},
+		{`metrics{metric="requests", scope_name!="otelhttp", monotonic="true"}`, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, meta := executeQuery(t, service, tt.query, end.Add(-24*time.Hour), end)
+			if meta.UsedRollups {
+				t.Error("Expected metadata selectors to use raw data")
+			}
+			if len(results) != 1 || results[0].Value != tt.value {
+				t.Fatalf("Expected one result with value %v, got %+v", tt.value, results)
+			}
+		})
+	}
+
+	query := service.metricQueries[0]
+	if query.Temporality == nil || *query.Temporality != "delta" {
+		t.Errorf("Expected temporality to be pushed down, got %+v", query)
+	}
+
+	results, _ := executeQuery(t, service, `metrics{metric="requests", temporality="delta"}`, end.Add(-time.Hour), end)
+	data := results[0].Data
+	if data["unit"] != "1" || data["scope_name"] != "otelhttp" || data["temporality"] != "delta" || data["monotonic"] != true {
+		t.Errorf("Expected metadata in result data, got %v", data)
+	}
+}
+
+func TestExecutor_PushesSelectorsDown(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	service := &stubTelemetryService{
+		metrics: []services.Metric{
+			{Timestamp: end.Add(-time.Minute), Name: "cpu", Value: 1, MetricAttributes: map[string]interface{}{"host": "prod-1", "env": "prod"}},
+			{Timestamp: end.Add(-time.Minute), Name: "cpu", Value: 2, MetricAttributes: map[string]interface{}{"host": "prod-2", "env": "dev"}},
+		},
+	}
+
+	results, meta := executeQuery(t, service, `metrics{metric="cpu", host=~"prod-.*", env!="dev"}`, end.Add(-time.Hour), end)
+	if len(results) != 1 || results[0].Value != 1.0 {
+		t.Fatalf("Expected one matching result, got %+v", results)
+	}
+
+	query := service.metricQueries[0]
+	if query.MetricName == nil || *query.MetricName != "cpu" {
+		t.Errorf("Expected metric name to be pushed down, got %+v", query)
+	}
+	expected := []services.Filter{
+		{Field: "env", Operator: services.FilterOpNotEqual, Value: "dev"},
+		{Field: "host", Operator: services.FilterOpRegex, Value: "prod-.*"},
+	}
+	if fmt.Sprint(query.Filters) != fmt.Sprint(expected) {
+		t.Errorf("Expected filters %v, got %v", expected, query.Filters)
+	}
+
+	plan := `[storage] scan metrics where metric_name = "cpu" and env != "dev" and host =~ "prod-.*" ` +
+		`and time 2024-01-08T11:30:00Z..2024-01-08T12:30:00Z limit 1000`
+	if meta.Plan != plan {
+		t.Errorf("Unexpected plan:\n%s", meta.Plan)
+	}
+}
+
+func TestExecutor_PushesAggregationsDown(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	service := &stubTelemetryService{
+		aggregates: []services.Aggregate{
+			{Labels: map[string]string{"service_name": "api"}, Value: 12, Count: 3, Timestamp: end},
+		},
+	}
+
+	results, meta := executeQuery(t, service, `sum(metrics{metric="cpu", host!="a"} [5m]) by (service_name)`, end.Add(-time.Hour), end)
+	if len(service.metricQueries) != 0 || len(service.aggregateQueries) != 1 {
+		t.Fatalf("Expected one aggregate query and no raw scan, got %d and %d", len(service.aggregateQueries), len(service.metricQueries))
+	}
+	query := service.aggregateQueries[0]
+	if query.Function != services.AggregateSum || len(query.By) != 1 || query.By[0] != "service_name" || len(query.Query.Filters) != 1 {
+		t.Errorf("Unexpected aggregate query: %+v", query)
+	}
+	if len(results) != 1 || results[0].Value != 12.0 || results[0].Labels["service_name"] != "api" || results[0].Data["count"] != 3 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+	if !strings.HasPrefix(meta.Plan, "[storage] aggregate sum by (service_name) over metrics where") {
+		t.Errorf("Unexpected plan:\n%s", meta.Plan)
+	}
+
+	// Aggregations over rollups stay in the executor
+	service = &stubTelemetryService{rollups: []services.Rollup{{WindowStart: end.Add(-2 * time.Hour), MetricName: "cpu", Avg: 2}}}
+	_, meta = executeQuery(t, service, `avg(metrics{metric="cpu"} [5m])`, end.Add(-7*24*time.Hour), end)
+	if len(service.aggregateQueries) != 0 || !meta.UsedRollups {
+		t.Fatalf("Expected rollup query aggregated by the executor, got %+v", service.aggregateQueries)
+	}
+	if !strings.HasPrefix(meta.Plan, "[executor] avg\n  [storage] scan rollups_1h where metric_name = \"cpu\"") {
+		t.Errorf("Unexpected plan:\n%s", meta.Plan)
+	}
+}
+
+func TestExecutor_PushesIncreaseDown(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	var metrics []services.Metric
+	for i, value := range []float64{10, 20, 5, 8} {
+		metrics = append(metrics, services.Metric{
+			Timestamp: end.Add(time.Duration(i-4) * time.Minute), Name: "requests", Value: value,
+			Type: services.MetricTypeCounter, Temporality: "cumulative", IsMonotonic: true,
+		})
+	}
+	service := &stubTelemetryService{metrics: metrics}
+
+	results, meta := executeQuery(t, service, `increase(metrics{metric="requests"} [5m])`, end.Add(-30*time.Minute), end)
+	if len(service.increaseQueries) != 1 || len(service.metricQueries) != 0 {
+		t.Fatalf("Expected increase to be summarized by the store, got %d raw queries", len(service.metricQueries))
+	}
+	if len(results) != 1 || results[0].Value != 18.0 {
+		t.Errorf("Expected increase of 18 across the reset, got %+v", results)
+	}
+	if !strings.HasPrefix(meta.Plan, "[executor] increase\n  [storage] summarize metrics where metric_name = \"requests\"") {
+		t.Errorf("Unexpected plan:\n%s", meta.Plan)
+	}
+}
+
+func TestExecutor_RateIgnoresRollups(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	start := end.Add(-2 * time.Hour)
+	// A delta counter reporting 60 requests a minute, one per second
+	var metrics []services.Metric
+	for ts := start.Add(time.Minute); !ts.After(end); ts = ts.Add(time.Minute) {
+		windowStart := ts.Add(-time.Minute)
+		metrics = append(metrics, services.Metric{
+			Timestamp: ts, StartTime: &windowStart, Name: "requests", Value: 60,
+			Type: services.MetricTypeCounter, Temporality: "delta", IsMonotonic: true,
+		})
+	}
+	service := &stubTelemetryService{
+		metrics: metrics,
+		rollups: []services.Rollup{{WindowStart: start, MetricName: "requests", MetricType: "sum", Count: 60, Sum: 3600, Avg: 60}},
+	}
+
+	results, meta := executeQuery(t, service, `rate(metrics{metric="requests"})`, start, end)
+	if meta.UsedRollups || len(service.rollupQueries) != 0 || len(service.increaseQueries) != 1 {
+		t.Fatalf("Expected rate to be summarized from raw points, got %d rollup queries", len(service.rollupQueries))
+	}
+	if len(results) != 1 || math.Abs(results[0].Value.(float64)-1) > 1e-9 {
+		t.Errorf("Expected a rate of 1/s, got %+v", results)
+	}
+}
+
+func TestExecutor_IncreasePerSeries(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	start := end.Add(-4 * time.Minute)
+	// Interleaved, the two counters would look like a reset at every point
+	metrics := append(counterSeries(start, "a", 100, 110, 120, 130), counterSeries(start, "b", 5, 6, 7, 8)...)
+
+	pushed := &stubTelemetryService{metrics: metrics}
+	results, _ := executeQuery(t, pushed, `increase(metrics{metric="requests"} [5m])`, end.Add(-30*time.Minute), end)
+	if len(pushed.increaseQueries) != 1 {
+		t.Fatalf("Expected increase to be summarized by the store, got %d summaries", len(pushed.increaseQueries))
+	}
+
+	// Over raw points, such as from rollups, the executor evaluates increase
+	raw, _ := executeQuery(t, &stubTelemetryService{metrics: metrics}, `metrics{metric="requests"} [5m]`, end.Add(-30*time.Minute), end)
+	inMemory, err := perSeriesIncrease("increase", raw)
+	if err != nil {
+		t.Fatalf("increase() failed: %v", err)
+	}
+
+	for name, results := range map[string][]QueryResult{"pushed down": results, "in memory": inMemory} {
+		values := make(map[string]float64)
+		for _, r := range results {
+			if r.Labels["name"] != "requests" {
+				t.Errorf("%s: expected the series labels, got %v", name, r.Labels)
+			}
+			values[r.Labels["host"]] = r.Value.(float64)
+		}
+		if len(results) != 2 || values["a"] != 30 || values["b"] != 3 {
+			t.Errorf("%s: expected an increase of 30 for a and 3 for b, got %+v", name, results)
+		}
+	}
+}
+
+func TestExecutor_HistogramQuantileByLabels(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	histogramMetric := func(service string, counts []int64) services.Metric {
+		return services.Metric{
+			Timestamp: end.Add(-time.Minute), Name: "latency", Type: services.MetricTypeHistogram, Temporality: "delta",
+			Labels: map[string]string{"service": service}, BucketCounts: counts, ExplicitBounds: []float64{0.1, 0.5, 1},
+		}
+	}
+	service := &stubTelemetryService{metrics: []services.Metric{
+		histogramMetric("api", []int64{10, 0, 0, 0}),
+		histogramMetric("api", []int64{0, 10, 0, 0}),
+		histogramMetric("db", []int64{0, 0, 4, 0}),
+	}}
+
+	results, _ := executeQuery(t, service, `histogram_quantile(0.9, metrics{metric="latency"} [5m]) by (service)`, end.Add(-30*time.Minute), end)
+	if len(results) != 2 {
+		t.Fatalf("Expected one quantile per service, got %+v", results)
+	}
+	values := make(map[string]float64)
+	for _, r := range results {
+		if r.Labels["quantile"] != "0.9" {
+			t.Errorf("Expected quantile label 0.9, got %v", r.Labels)
+		}
+		values[r.Labels["service"]] = r.Value.(float64)
+	}
+	// Rank 18 of 20 falls 8/10 into (0.1, 0.5] for api, and 3.6/4 into
+	// (0.5, 1] for db
+	if math.Abs(values["api"]-0.42) > 1e-9 || math.Abs(values["db"]-0.95) > 1e-9 {
+		t.Errorf("Unexpected quantiles: %v", values)
+	}
+}
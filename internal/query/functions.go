@@ -6,8 +6,14 @@ package query
 import (
 	"fmt"
 	"math"
+	"regexp"
 	"sort"
+	"strconv"
 	"time"
+
+	"github.com/getlawrence/lawrence-oss/internal/histogram"
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/services"
 )
 
 // Function represents a built-in query function
@@ -26,6 +32,30 @@ var functions = map[string]*Function{
 	"rate":               rateFunction(),
 	"increase":           increaseFunction(),
 	"histogram_quantile": histogramQuantileFunction(),
+	"count_over_time":    countOverTimeFunction(),
+	"avg_over_time":      avgOverTimeFunction(),
+	"delta":              deltaFunction(),
+	"deriv":              derivFunction(),
+	"abs":                absFunction(),
+}
+
+// windowFunctions evaluate each series over the samples in its window
+var windowFunctions = map[string]bool{
+	"avg_over_time":      true,
+	"quantile_over_time": true,
+	"delta":              true,
+	"deriv":              true,
+	"predict_linear":     true,
+}
+
+// vectorFunctions evaluate the latest sample of each series
+var vectorFunctions = map[string]bool{
+	"topk":          true,
+	"bottomk":       true,
+	"abs":           true,
+	"clamp":         true,
+	"label_replace": true,
+	"absent":        true,
 }
 
 // GetFunction returns a function by name
@@ -246,164 +276,257 @@ func rateFunction() *Function {
 		Name:        "rate",
 		Description: "Calculates the per-second rate of increase (only works with metrics)",
 		Apply: func(results []QueryResult) (interface{}, error) {
-			if len(results) == 0 {
-				return nil, fmt.Errorf("rate() requires at least one result")
-			}
-
-			// Check if this is metrics data
-			if results[0].Type != "metrics" {
-				return nil, fmt.Errorf("rate() function can only be used with metrics, not %s", results[0].Type)
-			}
+			return perSeriesIncrease("rate", results)
+		},
+	}
+}
 
-			if len(results) < 2 {
-				return nil, fmt.Errorf("rate() requires at least 2 data points, got %d", len(results))
-			}
+// increaseFunction calculates the total increase over time range
+func increaseFunction() *Function {
+	return &Function{
+		Name:        "increase",
+		Description: "Calculates the total increase over the time range (only works with metrics)",
+		Apply: func(results []QueryResult) (interface{}, error) {
+			return perSeriesIncrease("increase", results)
+		},
+	}
+}
 
-			// Sort by timestamp
-			sort.Slice(results, func(i, j int) bool {
-				return results[i].Timestamp.Before(results[j].Timestamp)
-			})
+// perSeriesIncrease calculates rate or increase of each series in results
+func perSeriesIncrease(fn string, results []QueryResult) ([]QueryResult, error) {
+	if len(results) > 0 && results[0].Type != TelemetryTypeMetrics {
+		return nil, fmt.Errorf("%s() function can only be used with metrics, not %s", fn, results[0].Type)
+	}
 
-			first := results[0]
-			last := results[len(results)-1]
+	bySeries := make(map[string][]QueryResult)
+	var keys []string
+	for _, r := range results {
+		key := labelsKey(seriesLabels(r))
+		if _, ok := bySeries[key]; !ok {
+			keys = append(keys, key)
+		}
+		bySeries[key] = append(bySeries[key], r)
+	}
 
-			firstVal, err := toFloat64(first.Value)
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert first value to number: %v", err)
-			}
+	summaries := make([]services.MetricIncrease, 0, len(keys))
+	for _, key := range keys {
+		samples := bySeries[key]
+		stats, err := seriesStats(samples)
+		if err != nil {
+			return nil, err
+		}
+		stats.Labels = seriesLabels(samples[0])
+		summaries = append(summaries, stats)
+	}
+	return increasesResults(fn, TelemetryTypeMetrics, summaries)
+}
 
-			lastVal, err := toFloat64(last.Value)
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert last value to number: %v", err)
-			}
+// increasesResults returns rate or increase of every series summarized,
+// labeled with the series labels. Series without enough points for a value
+// are left out; if no series has one, the first error is returned.
+func increasesResults(fn string, resultType TelemetryType, summaries []services.MetricIncrease) ([]QueryResult, error) {
+	if len(summaries) == 0 {
+		_, err := increaseFromStats(fn, services.MetricIncrease{})
+		return nil, err
+	}
 
-			timeDiff := last.Timestamp.Sub(first.Timestamp).Seconds()
-			if timeDiff == 0 {
-				return nil, fmt.Errorf("rate() requires time difference between data points")
-			}
+	var results []QueryResult
+	var firstErr error
+	for _, stats := range summaries {
+		increase, err := increaseFromStats(fn, stats)
+		if err == nil {
+			var increased []QueryResult
+			increased, err = increaseResults(fn, resultType, stats.Labels, increase)
+			results = append(results, increased...)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if len(results) == 0 {
+		return nil, firstErr
+	}
+	return results, nil
+}
 
-			rate := (lastVal - firstVal) / timeDiff
+// increaseResults returns the result of rate or increase for the increase of
+// a series
+func increaseResults(fn string, resultType TelemetryType, labels map[string]string, increase increaseResult) ([]QueryResult, error) {
+	if fn == "increase" {
+		return []QueryResult{{
+			Type:      resultType,
+			Timestamp: increase.end,
+			Labels:    labels,
+			Value:     increase.value,
+			Data: map[string]interface{}{
+				"function":    "increase",
+				"temporality": increase.temporality,
+			},
+		}}, nil
+	}
 
-			return []QueryResult{{
-				Type:      first.Type,
-				Timestamp: last.Timestamp,
-				Labels:    first.Labels,
-				Value:     rate,
-				Data: map[string]interface{}{
-					"function":  "rate",
-					"time_span": timeDiff,
-				},
-			}}, nil
-		},
+	if increase.span == 0 {
+		return nil, fmt.Errorf("rate() requires time difference between data points")
 	}
+	return []QueryResult{{
+		Type:      resultType,
+		Timestamp: increase.end,
+		Labels:    labels,
+		Value:     increase.value / increase.span,
+		Data: map[string]interface{}{
+			"function":    "rate",
+			"time_span":   increase.span,
+			"temporality": increase.temporality,
+		},
+	}}, nil
 }
 
-// increaseFunction calculates the total increase over time range
-func increaseFunction() *Function {
-	return &Function{
-		Name:        "increase",
-		Description: "Calculates the total increase over the time range (only works with metrics)",
-		Apply: func(results []QueryResult) (interface{}, error) {
-			if len(results) == 0 {
-				return nil, fmt.Errorf("increase() requires at least one result")
-			}
+// increaseResult is the increase of a series over the time it covers
+type increaseResult struct {
+	value       float64
+	span        float64
+	end         time.Time
+	temporality string
+}
 
-			// Check if this is metrics data
-			if results[0].Type != "metrics" {
-				return nil, fmt.Errorf("increase() function can only be used with metrics, not %s", results[0].Type)
-			}
+// seriesIncrease calculates how much a metric series grew
+func seriesIncrease(fn string, results []QueryResult) (increaseResult, error) {
+	if len(results) > 0 && results[0].Type != "metrics" {
+		return increaseResult{}, fmt.Errorf("%s() function can only be used with metrics, not %s", fn, results[0].Type)
+	}
+	stats, err := seriesStats(results)
+	if err != nil {
+		return increaseResult{}, err
+	}
+	return increaseFromStats(fn, stats)
+}
 
-			if len(results) < 2 {
-				return nil, fmt.Errorf("increase() requires at least 2 data points, got %d", len(results))
+// seriesStats summarizes metric results the way the store summarizes points
+// for rate and increase
+func seriesStats(results []QueryResult) (services.MetricIncrease, error) {
+	stats := services.MetricIncrease{Points: int64(len(results))}
+	if len(results) == 0 {
+		return stats, nil
+	}
+
+	// Sort by timestamp
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Timestamp.Before(results[j].Timestamp)
+	})
+
+	temporalities := make(map[string]bool)
+	for i, r := range results {
+		value, err := toFloat64(r.Value)
+		if err != nil {
+			return stats, fmt.Errorf("failed to convert value to number: %v", err)
+		}
+		if temporality := resultString(r, "temporality"); !temporalities[temporality] {
+			temporalities[temporality] = true
+			stats.Temporalities = append(stats.Temporalities, temporality)
+		}
+
+		if i > 0 {
+			if value < stats.LastValue {
+				// Counter reset, the value counts up from zero again
+				stats.ResetIncrease += value
+			} else {
+				stats.ResetIncrease += value - stats.LastValue
 			}
+		}
+		stats.Sum += value
+		stats.LastValue = value
+	}
 
-			// Sort by timestamp
-			sort.Slice(results, func(i, j int) bool {
-				return results[i].Timestamp.Before(results[j].Timestamp)
-			})
+	first := results[0]
+	stats.FirstTimestamp = first.Timestamp
+	stats.LastTimestamp = results[len(results)-1].Timestamp
+	stats.FirstValue, _ = toFloat64(first.Value)
+	if start, ok := first.Data["start_time"].(time.Time); ok {
+		stats.FirstStartTime = &start
+	}
+	stats.Monotonic, _ = first.Data["monotonic"].(bool)
+	return stats, nil
+}
 
-			first := results[0]
-			last := results[len(results)-1]
+// increaseFromStats calculates how much a metric series grew. Delta sums
+// report the change since the previous point, so their values are summed;
+// the span starts at the first point's start time, or the first point is
+// dropped when the exporter did not set one. Cumulative monotonic sums
+// restart from zero when the process restarts, so a decrease is treated as a
+// reset. Other series use the difference between the last and first value.
+func increaseFromStats(fn string, stats services.MetricIncrease) (increaseResult, error) {
+	if stats.Points == 0 {
+		return increaseResult{}, fmt.Errorf("%s() requires at least one result", fn)
+	}
+	if len(stats.Temporalities) > 1 {
+		return increaseResult{}, fmt.Errorf("%s() cannot mix series with different temporalities", fn)
+	}
 
-			firstVal, err := toFloat64(first.Value)
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert first value to number: %v", err)
-			}
+	increase := increaseResult{end: stats.LastTimestamp}
+	if len(stats.Temporalities) == 1 {
+		increase.temporality = stats.Temporalities[0]
+	}
 
-			lastVal, err := toFloat64(last.Value)
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert last value to number: %v", err)
+	if increase.temporality == otlp.TemporalityDelta {
+		start := stats.FirstTimestamp
+		increase.value = stats.Sum
+		if stats.FirstStartTime != nil {
+			start = *stats.FirstStartTime
+		} else {
+			if stats.Points < 2 {
+				return increaseResult{}, fmt.Errorf("%s() requires at least 2 data points, got %d", fn, stats.Points)
 			}
+			// Without a start time the window the first delta covers is
+			// unknown, so it only marks the start of the span
+			increase.value -= stats.FirstValue
+		}
+		increase.span = stats.LastTimestamp.Sub(start).Seconds()
+		return increase, nil
+	}
 
-			increase := lastVal - firstVal
+	if stats.Points < 2 {
+		return increaseResult{}, fmt.Errorf("%s() requires at least 2 data points, got %d", fn, stats.Points)
+	}
+	increase.span = stats.LastTimestamp.Sub(stats.FirstTimestamp).Seconds()
 
-			return []QueryResult{{
-				Type:      first.Type,
-				Timestamp: last.Timestamp,
-				Labels:    first.Labels,
-				Value:     increase,
-				Data: map[string]interface{}{
-					"function": "increase",
-				},
-			}}, nil
-		},
+	if stats.Monotonic {
+		increase.value = stats.ResetIncrease
+	} else {
+		increase.value = stats.LastValue - stats.FirstValue
 	}
+	return increase, nil
 }
 
-// histogramQuantileFunction calculates histogram quantiles
+// resultString returns a string field of a result's data, or an empty string
+func resultString(r QueryResult, key string) string {
+	value, _ := r.Data[key].(string)
+	return value
+}
+
+// histogramQuantileFunction calculates the p50, p90, p95 and p99 quantiles
+// when histogram_quantile is called without a quantile argument
 func histogramQuantileFunction() *Function {
 	return &Function{
 		Name:        "histogram_quantile",
-		Description: "Calculates histogram quantile (e.g., p95, p99)",
+		Description: "Estimates a quantile from histogram buckets (e.g., histogram_quantile(0.99, ...))",
 		Apply: func(results []QueryResult) (interface{}, error) {
-			if len(results) == 0 {
-				return []QueryResult{}, nil
-			}
-
-			// Extract values and sort
-			values := make([]float64, 0, len(results))
-			for _, r := range results {
-				val, err := toFloat64(r.Value)
-				if err != nil {
-					continue
-				}
-				values = append(values, val)
-			}
-
-			if len(values) == 0 {
+			estimate := quantileEstimator(results)
+			if estimate == nil {
 				return []QueryResult{}, nil
 			}
 
-			sort.Float64s(values)
-
 			// Calculate common quantiles
 			quantiles := map[string]float64{
-				"p50": calculateQuantile(values, 0.5),
-				"p90": calculateQuantile(values, 0.9),
-				"p95": calculateQuantile(values, 0.95),
-				"p99": calculateQuantile(values, 0.99),
+				"p50": estimate(0.5),
+				"p90": estimate(0.9),
+				"p95": estimate(0.95),
+				"p99": estimate(0.99),
 			}
 
 			// Return results for each quantile
 			quantileResults := make([]QueryResult, 0, len(quantiles))
 			for label, value := range quantiles {
-				labels := make(map[string]string)
-				if len(results) > 0 && results[0].Labels != nil {
-					for k, v := range results[0].Labels {
-						labels[k] = v
-					}
-				}
-				labels["quantile"] = label
-
-				quantileResults = append(quantileResults, QueryResult{
-					Type:      results[0].Type,
-					Timestamp: results[0].Timestamp,
-					Labels:    labels,
-					Value:     value,
-					Data: map[string]interface{}{
-						"function": "histogram_quantile",
-					},
-				})
+				quantileResults = append(quantileResults, quantileResult(results, label, value))
 			}
 
 			return quantileResults, nil
@@ -411,6 +534,408 @@ func histogramQuantileFunction() *Function {
 	}
 }
 
+// histogramQuantileAt returns histogram_quantile for a single quantile
+func histogramQuantileAt(q float64) (*Function, error) {
+	if q < 0 || q > 1 || math.IsNaN(q) {
+		return nil, fmt.Errorf("histogram_quantile quantile must be between 0 and 1, got %v", q)
+	}
+	return &Function{
+		Name:        "histogram_quantile",
+		Description: histogramQuantileFunction().Description,
+		Apply: func(results []QueryResult) (interface{}, error) {
+			estimate := quantileEstimator(results)
+			if estimate == nil {
+				return []QueryResult{}, nil
+			}
+			return []QueryResult{quantileResult(results, strconv.FormatFloat(q, 'g', -1, 64), estimate(q))}, nil
+		},
+	}, nil
+}
+
+// resolveFunction returns the function a call applies, bound to its number
+// and string parameters and, for absent, to its query
+func resolveFunction(name string, params []Query, arg Query) (*Function, error) {
+	if build, ok := functionBuilders[name]; ok {
+		return build(params, arg)
+	}
+	if len(params) > 0 {
+		return nil, fmt.Errorf("function %s does not take a number or string argument", name)
+	}
+	function, ok := GetFunction(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown function: %s", name)
+	}
+	return function, nil
+}
+
+// functionBuilders bind the functions that take parameters, or whose
+// results depend on their query, to the arguments of a call
+var functionBuilders = map[string]func(params []Query, arg Query) (*Function, error){
+	"histogram_quantile": func(params []Query, _ Query) (*Function, error) {
+		if len(params) == 0 {
+			return histogramQuantileFunction(), nil
+		}
+		q, err := numberParams("histogram_quantile", params, 1)
+		if err != nil {
+			return nil, err
+		}
+		return histogramQuantileAt(q[0])
+	},
+	"topk": func(params []Query, _ Query) (*Function, error) {
+		k, err := numberParams("topk", params, 1)
+		if err != nil {
+			return nil, err
+		}
+		return selectKFunction("topk", k[0]), nil
+	},
+	"bottomk": func(params []Query, _ Query) (*Function, error) {
+		k, err := numberParams("bottomk", params, 1)
+		if err != nil {
+			return nil, err
+		}
+		return selectKFunction("bottomk", k[0]), nil
+	},
+	"quantile_over_time": func(params []Query, _ Query) (*Function, error) {
+		q, err := numberParams("quantile_over_time", params, 1)
+		if err != nil {
+			return nil, err
+		}
+		return quantileOverTimeFunction(q[0])
+	},
+	"predict_linear": func(params []Query, _ Query) (*Function, error) {
+		t, err := numberParams("predict_linear", params, 1)
+		if err != nil {
+			return nil, err
+		}
+		return predictLinearFunction(t[0]), nil
+	},
+	"clamp": func(params []Query, _ Query) (*Function, error) {
+		bounds, err := numberParams("clamp", params, 2)
+		if err != nil {
+			return nil, err
+		}
+		return clampFunction(bounds[0], bounds[1]), nil
+	},
+	"label_replace": func(params []Query, _ Query) (*Function, error) {
+		args, err := stringParams("label_replace", params, 4)
+		if err != nil {
+			return nil, err
+		}
+		return labelReplaceFunction(args[0], args[1], args[2], args[3])
+	},
+	"absent": func(params []Query, arg Query) (*Function, error) {
+		if len(params) > 0 {
+			return nil, fmt.Errorf("function absent does not take a number or string argument")
+		}
+		return absentFunction(arg), nil
+	},
+}
+
+// numberParams returns the values of a call's parameters, which must be
+// count numbers
+func numberParams(name string, params []Query, count int) ([]float64, error) {
+	if len(params) != count {
+		return nil, fmt.Errorf("function %s takes %d number argument(s), got %d", name, count, len(params))
+	}
+	values := make([]float64, count)
+	for i, param := range params {
+		number, ok := param.(*NumberLiteral)
+		if !ok {
+			return nil, fmt.Errorf("function %s expects number arguments", name)
+		}
+		values[i] = number.Value
+	}
+	return values, nil
+}
+
+// stringParams returns the values of a call's parameters, which must be
+// count strings
+func stringParams(name string, params []Query, count int) ([]string, error) {
+	if len(params) != count {
+		return nil, fmt.Errorf("function %s takes %d string argument(s), got %d", name, count, len(params))
+	}
+	values := make([]string, count)
+	for i, param := range params {
+		str, ok := param.(*StringLiteral)
+		if !ok {
+			return nil, fmt.Errorf("function %s expects string arguments", name)
+		}
+		values[i] = str.Value
+	}
+	return values, nil
+}
+
+// aggregationParams returns the parameters of an aggregation
+func aggregationParams(a *Aggregation) []Query {
+	if a.Param == nil {
+		return nil
+	}
+	return []Query{a.Param}
+}
+
+// quantileResult returns a histogram_quantile result with the labels of the
+// first input result and the given quantile label
+func quantileResult(results []QueryResult, quantile string, value float64) QueryResult {
+	labels := make(map[string]string)
+	for k, v := range results[0].Labels {
+		labels[k] = v
+	}
+	labels["quantile"] = quantile
+
+	return QueryResult{
+		Type:      results[0].Type,
+		Timestamp: results[0].Timestamp,
+		Labels:    labels,
+		Value:     value,
+		Data: map[string]interface{}{
+			"function": "histogram_quantile",
+		},
+	}
+}
+
+// quantileEstimator returns a function estimating quantiles of the
+// observations in results, or nil if they hold none. Exponential histograms
+// are merged and interpolated within their buckets, as are explicit bucket
+// histograms. Summaries interpolate between the quantiles they report, and
+// other metrics estimate the quantiles from their sample values.
+func quantileEstimator(results []QueryResult) func(q float64) float64 {
+	if len(results) == 0 {
+		return nil
+	}
+	if merged := mergeExponential(results); merged != nil {
+		return merged.Quantile
+	}
+	if counts, bounds, ok := mergeExplicitBuckets(results); ok {
+		var total int64
+		for _, c := range counts {
+			total += c
+		}
+		if total == 0 || len(bounds) == 0 {
+			return nil
+		}
+		return func(q float64) float64 { return histogram.ExplicitQuantile(q, counts, bounds) }
+	}
+	if summary := mergeSummaryQuantiles(results); len(summary) > 0 {
+		return func(q float64) float64 { return histogram.SummaryQuantile(q, summary) }
+	}
+
+	// Extract values and sort
+	values := make([]float64, 0, len(results))
+	for _, r := range results {
+		val, err := toFloat64(r.Value)
+		if err != nil {
+			continue
+		}
+		values = append(values, val)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Float64s(values)
+	return func(q float64) float64 { return calculateQuantile(values, q) }
+}
+
+// explicitBuckets is an explicit bucket histogram sample
+type explicitBuckets struct {
+	counts []int64
+	bounds []float64
+}
+
+// mergeExplicitBuckets merges the explicit bucket histograms among results
+// across series and time. It reports false if there are none.
+//
+// Each series contributes the observations made within the results: the
+// increase between its first and last snapshot for cumulative histograms,
+// or the sum of all samples otherwise. Histograms with different bucket
+// layouts are merged onto the union of their bounds; every bucket is kept
+// whole in the merged bucket with its upper bound.
+func mergeExplicitBuckets(results []QueryResult) ([]int64, []float64, bool) {
+	bySeries := make(map[string][]QueryResult)
+	var keys []string
+	for _, r := range results {
+		counts, _ := r.Data["bucket_counts"].([]int64)
+		bounds, _ := r.Data["explicit_bounds"].([]float64)
+		if len(counts) == 0 || len(counts) != len(bounds)+1 {
+			continue
+		}
+		key := labelsKey(seriesLabels(r))
+		if _, ok := bySeries[key]; !ok {
+			keys = append(keys, key)
+		}
+		bySeries[key] = append(bySeries[key], r)
+	}
+	if len(keys) == 0 {
+		return nil, nil, false
+	}
+
+	var observed []explicitBuckets
+	for _, key := range keys {
+		observed = append(observed, seriesBuckets(bySeries[key])...)
+	}
+
+	boundSet := make(map[float64]bool)
+	var bounds []float64
+	for _, h := range observed {
+		for _, b := range h.bounds {
+			if !boundSet[b] {
+				boundSet[b] = true
+				bounds = append(bounds, b)
+			}
+		}
+	}
+	sort.Float64s(bounds)
+
+	counts := make([]int64, len(bounds)+1)
+	for _, h := range observed {
+		for i, c := range h.counts {
+			if i == len(h.bounds) {
+				counts[len(bounds)] += c
+				continue
+			}
+			counts[sort.SearchFloat64s(bounds, h.bounds[i])] += c
+		}
+	}
+	return counts, bounds, true
+}
+
+// seriesBuckets returns the observations of one series' histogram samples.
+// Cumulative samples contribute their increase over the previous sample; a
+// drop in any bucket or a new layout is a reset and counts the sample whole.
+// A lone cumulative sample counts whole as well.
+func seriesBuckets(samples []QueryResult) []explicitBuckets {
+	sort.SliceStable(samples, func(a, b int) bool {
+		return samples[a].Timestamp.Before(samples[b].Timestamp)
+	})
+
+	buckets := make([]explicitBuckets, len(samples))
+	for i, r := range samples {
+		buckets[i] = explicitBuckets{
+			counts: r.Data["bucket_counts"].([]int64),
+			bounds: r.Data["explicit_bounds"].([]float64),
+		}
+	}
+	if len(samples) == 1 || resultString(samples[len(samples)-1], "temporality") != otlp.TemporalityCumulative {
+		return buckets
+	}
+
+	increases := make([]explicitBuckets, 0, len(buckets)-1)
+	for i := 1; i < len(buckets); i++ {
+		prev, cur := buckets[i-1], buckets[i]
+		delta := explicitBuckets{counts: make([]int64, len(cur.counts)), bounds: cur.bounds}
+		reset := !equalBounds(prev.bounds, cur.bounds)
+		for j := range cur.counts {
+			if reset {
+				break
+			}
+			delta.counts[j] = cur.counts[j] - prev.counts[j]
+			reset = delta.counts[j] < 0
+		}
+		if reset {
+			delta.counts = cur.counts
+		}
+		increases = append(increases, delta)
+	}
+	return increases
+}
+
+// equalBounds reports whether two histograms share a bucket layout
+func equalBounds(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeExponential merges the exponential histograms among results across
+// series and time, or returns nil if they hold no observations. As with
+// explicit buckets, cumulative series contribute their increase within the
+// results rather than every snapshot.
+func mergeExponential(results []QueryResult) *histogram.Exponential {
+	bySeries := make(map[string][]QueryResult)
+	var keys []string
+	for _, r := range results {
+		if h, ok := r.Data["exponential"].(*histogram.Exponential); !ok || h == nil {
+			continue
+		}
+		key := labelsKey(seriesLabels(r))
+		if _, ok := bySeries[key]; !ok {
+			keys = append(keys, key)
+		}
+		bySeries[key] = append(bySeries[key], r)
+	}
+
+	merged := &histogram.Exponential{}
+	for _, key := range keys {
+		for _, h := range seriesExponential(bySeries[key]) {
+			merged.Merge(h)
+		}
+	}
+	if merged.Count() == 0 {
+		return nil
+	}
+	return merged
+}
+
+// seriesExponential returns the observations of one series' exponential
+// histogram samples. Cumulative samples contribute their increase over the
+// previous sample; a sample that cannot have grown from the previous one is
+// a reset and counts whole. A lone cumulative sample counts whole as well.
+func seriesExponential(samples []QueryResult) []histogram.Exponential {
+	sort.SliceStable(samples, func(a, b int) bool {
+		return samples[a].Timestamp.Before(samples[b].Timestamp)
+	})
+
+	snapshots := make([]histogram.Exponential, len(samples))
+	for i, r := range samples {
+		snapshots[i] = *r.Data["exponential"].(*histogram.Exponential)
+	}
+	if len(samples) == 1 || resultString(samples[len(samples)-1], "temporality") != otlp.TemporalityCumulative {
+		return snapshots
+	}
+
+	increases := make([]histogram.Exponential, 0, len(snapshots)-1)
+	for i := 1; i < len(snapshots); i++ {
+		delta, ok := snapshots[i].Delta(snapshots[i-1])
+		if !ok {
+			delta = snapshots[i]
+		}
+		increases = append(increases, delta)
+	}
+	return increases
+}
+
+// mergeSummaryQuantiles averages the quantiles of the summaries among
+// results, weighting each summary by its count
+func mergeSummaryQuantiles(results []QueryResult) []histogram.QuantileValue {
+	weighted := make(map[float64]float64)
+	weights := make(map[float64]float64)
+	for _, r := range results {
+		quantiles, ok := r.Data["quantiles"].([]histogram.QuantileValue)
+		if !ok {
+			continue
+		}
+		weight := 1.0
+		if count, ok := r.Data["count"].(int64); ok && count > 0 {
+			weight = float64(count)
+		}
+		for _, q := range quantiles {
+			weighted[q.Quantile] += q.Value * weight
+			weights[q.Quantile] += weight
+		}
+	}
+
+	merged := make([]histogram.QuantileValue, 0, len(weights))
+	for q, weight := range weights {
+		merged = append(merged, histogram.QuantileValue{Quantile: q, Value: weighted[q] / weight})
+	}
+	return merged
+}
+
 // calculateQuantile calculates the quantile value from sorted values
 func calculateQuantile(sortedValues []float64, quantile float64) float64 {
 	if len(sortedValues) == 0 {
@@ -430,6 +955,332 @@ func calculateQuantile(sortedValues []float64, quantile float64) float64 {
 	return sortedValues[lower]*(1-weight) + sortedValues[upper]*weight
 }
 
+// perSeries evaluates a window function over the samples of each series,
+// oldest first, skipping series it has no value for. Results drop the
+// metric name and are timestamped with the latest sample of their series.
+func perSeries(name string, results []QueryResult, evaluate func(samples []QueryResult) (float64, bool)) []QueryResult {
+	bySeries := make(map[string][]QueryResult)
+	labels := make(map[string]map[string]string)
+	for _, r := range results {
+		if _, err := toFloat64(r.Value); err != nil {
+			continue
+		}
+		series := seriesLabels(r)
+		key := labelsKey(series)
+		if _, ok := labels[key]; !ok {
+			labels[key] = withoutLabels(series, "name")
+		}
+		bySeries[key] = append(bySeries[key], r)
+	}
+
+	keys := make([]string, 0, len(bySeries))
+	for key := range bySeries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	evaluated := make([]QueryResult, 0, len(keys))
+	for _, key := range keys {
+		samples := bySeries[key]
+		sort.SliceStable(samples, func(a, b int) bool {
+			return samples[a].Timestamp.Before(samples[b].Timestamp)
+		})
+		value, ok := evaluate(samples)
+		if !ok {
+			continue
+		}
+		latest := samples[len(samples)-1]
+		evaluated = append(evaluated, QueryResult{
+			Type:      latest.Type,
+			Timestamp: latest.Timestamp,
+			Labels:    labels[key],
+			Value:     value,
+			Data: map[string]interface{}{
+				"function": name,
+			},
+		})
+	}
+	return evaluated
+}
+
+// sampleValues returns the values of samples
+func sampleValues(samples []QueryResult) []float64 {
+	values := make([]float64, len(samples))
+	for i, r := range samples {
+		values[i], _ = toFloat64(r.Value)
+	}
+	return values
+}
+
+// avgOverTimeFunction averages the samples of each series
+func avgOverTimeFunction() *Function {
+	return &Function{
+		Name:        "avg_over_time",
+		Description: "Averages the samples of each series over the time range",
+		Apply: func(results []QueryResult) (interface{}, error) {
+			return perSeries("avg_over_time", results, func(samples []QueryResult) (float64, bool) {
+				sum := 0.0
+				for _, value := range sampleValues(samples) {
+					sum += value
+				}
+				return sum / float64(len(samples)), true
+			}), nil
+		},
+	}
+}
+
+// quantileOverTimeFunction returns a quantile of the samples of each series
+func quantileOverTimeFunction(q float64) (*Function, error) {
+	if q < 0 || q > 1 || math.IsNaN(q) {
+		return nil, fmt.Errorf("quantile_over_time quantile must be between 0 and 1, got %v", q)
+	}
+	return &Function{
+		Name:        "quantile_over_time",
+		Description: "Returns a quantile of the samples of each series over the time range (e.g., quantile_over_time(0.9, ...))",
+		Apply: func(results []QueryResult) (interface{}, error) {
+			return perSeries("quantile_over_time", results, func(samples []QueryResult) (float64, bool) {
+				values := sampleValues(samples)
+				sort.Float64s(values)
+				return calculateQuantile(values, q), true
+			}), nil
+		},
+	}, nil
+}
+
+// deltaFunction returns the difference between the last and first sample
+// of each series, for gauges
+func deltaFunction() *Function {
+	return &Function{
+		Name:        "delta",
+		Description: "Calculates the difference between the last and first sample of each gauge series",
+		Apply: func(results []QueryResult) (interface{}, error) {
+			return perSeries("delta", results, func(samples []QueryResult) (float64, bool) {
+				if len(samples) < 2 {
+					return 0, false
+				}
+				values := sampleValues(samples)
+				return values[len(values)-1] - values[0], true
+			}), nil
+		},
+	}
+}
+
+// derivFunction returns the per-second derivative of each series by
+// simple linear regression
+func derivFunction() *Function {
+	return &Function{
+		Name:        "deriv",
+		Description: "Calculates the per-second derivative of each gauge series by linear regression",
+		Apply: func(results []QueryResult) (interface{}, error) {
+			return perSeries("deriv", results, func(samples []QueryResult) (float64, bool) {
+				slope, _, ok := linearRegression(samples)
+				return slope, ok
+			}), nil
+		},
+	}
+}
+
+// predictLinearFunction predicts the value of each series t seconds after
+// its latest sample by simple linear regression
+func predictLinearFunction(t float64) *Function {
+	return &Function{
+		Name:        "predict_linear",
+		Description: "Predicts the value of each gauge series t seconds ahead by linear regression (e.g., predict_linear(..., 3600))",
+		Apply: func(results []QueryResult) (interface{}, error) {
+			return perSeries("predict_linear", results, func(samples []QueryResult) (float64, bool) {
+				slope, intercept, ok := linearRegression(samples)
+				return intercept + slope*t, ok
+			}), nil
+		},
+	}
+}
+
+// linearRegression fits a line through samples by least squares. The
+// intercept is the value of the line at the latest sample. It reports
+// false for fewer than two samples or samples all at the same time.
+func linearRegression(samples []QueryResult) (slope, intercept float64, ok bool) {
+	if len(samples) < 2 {
+		return 0, 0, false
+	}
+	latest := samples[len(samples)-1].Timestamp
+	n := float64(len(samples))
+	var sumX, sumY, sumXY, sumX2 float64
+	for i, value := range sampleValues(samples) {
+		x := samples[i].Timestamp.Sub(latest).Seconds()
+		sumX += x
+		sumY += value
+		sumXY += x * value
+		sumX2 += x * x
+	}
+	variance := n*sumX2 - sumX*sumX
+	if variance == 0 {
+		return 0, 0, false
+	}
+	slope = (n*sumXY - sumX*sumY) / variance
+	intercept = (sumY - slope*sumX) / n
+	return slope, intercept, true
+}
+
+// mapValues applies a function to the value of each sample of a vector,
+// dropping the metric name
+func mapValues(name string, vector []QueryResult, apply func(float64) float64) []QueryResult {
+	results := make([]QueryResult, 0, len(vector))
+	for _, r := range vector {
+		value, err := toFloat64(r.Value)
+		if err != nil {
+			continue
+		}
+		results = append(results, QueryResult{
+			Type:      r.Type,
+			Timestamp: r.Timestamp,
+			Labels:    withoutLabels(r.Labels, "name"),
+			Value:     apply(value),
+			Data: map[string]interface{}{
+				"function": name,
+			},
+		})
+	}
+	return results
+}
+
+// absFunction returns the absolute value of each sample
+func absFunction() *Function {
+	return &Function{
+		Name:        "abs",
+		Description: "Returns the absolute value of each series",
+		Apply: func(results []QueryResult) (interface{}, error) {
+			return mapValues("abs", results, math.Abs), nil
+		},
+	}
+}
+
+// clampFunction limits each sample to the range between min and max. Like
+// PromQL it returns nothing when min is greater than max.
+func clampFunction(min, max float64) *Function {
+	return &Function{
+		Name:        "clamp",
+		Description: "Limits the value of each series to a range (e.g., clamp(..., 0, 100))",
+		Apply: func(results []QueryResult) (interface{}, error) {
+			if min > max {
+				return []QueryResult{}, nil
+			}
+			return mapValues("clamp", results, func(value float64) float64 {
+				return math.Max(min, math.Min(max, value))
+			}), nil
+		},
+	}
+}
+
+// selectKFunction returns topk or bottomk, keeping the k samples with the
+// largest or smallest values. Ties are broken by labels.
+func selectKFunction(name string, k float64) *Function {
+	description := "Returns the k series with the largest values (e.g., topk(5, ...))"
+	if name == "bottomk" {
+		description = "Returns the k series with the smallest values (e.g., bottomk(5, ...))"
+	}
+	return &Function{
+		Name:        name,
+		Description: description,
+		Apply: func(results []QueryResult) (interface{}, error) {
+			type ranked struct {
+				result QueryResult
+				value  float64
+				key    string
+			}
+			candidates := make([]ranked, 0, len(results))
+			for _, r := range results {
+				value, err := toFloat64(r.Value)
+				if err != nil || math.IsNaN(value) {
+					continue
+				}
+				candidates = append(candidates, ranked{result: r, value: value, key: labelsKey(r.Labels)})
+			}
+			sort.Slice(candidates, func(i, j int) bool {
+				if candidates[i].value != candidates[j].value {
+					return (candidates[i].value > candidates[j].value) == (name == "topk")
+				}
+				return candidates[i].key < candidates[j].key
+			})
+
+			count := int(math.Max(0, math.Min(k, float64(len(candidates)))))
+			selected := make([]QueryResult, count)
+			for i := range selected {
+				selected[i] = candidates[i].result
+			}
+			return selected, nil
+		},
+	}
+}
+
+// labelReplaceFunction sets the dst label of each sample to the expanded
+// replacement when the regex matches the whole value of the src label. An
+// empty result removes dst; samples that don't match are kept unchanged.
+func labelReplaceFunction(dst, replacement, src, pattern string) (*Function, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid label_replace regex: %w", err)
+	}
+	if dst == "" {
+		return nil, fmt.Errorf("label_replace requires a destination label")
+	}
+	return &Function{
+		Name:        "label_replace",
+		Description: "Sets a label from a regex match on another label (e.g., label_replace(..., \"host\", \"$1\", \"instance\", \"(.*):.*\"))",
+		Apply: func(results []QueryResult) (interface{}, error) {
+			replaced := make([]QueryResult, len(results))
+			for i, r := range results {
+				value := r.Labels[src]
+				if match := re.FindStringSubmatchIndex(value); match != nil {
+					labels := withoutLabels(r.Labels, dst)
+					if expanded := string(re.ExpandString(nil, replacement, value, match)); expanded != "" {
+						labels[dst] = expanded
+					}
+					r.Labels = labels
+				}
+				replaced[i] = r
+			}
+			return replaced, nil
+		},
+	}, nil
+}
+
+// absentFunction returns a sample with value 1 when its query has no
+// results, labelled with the equality selectors of the query like PromQL
+func absentFunction(arg Query) *Function {
+	resultType := TelemetryTypeMetrics
+	labels := make(map[string]string)
+	if q, ok := arg.(*TelemetryQuery); ok {
+		resultType = q.Type
+		for _, selector := range q.Selectors {
+			switch selector.Label {
+			case "metric", "name", "metric_name":
+				continue
+			}
+			if selector.Operator == SelectorOpEqual {
+				labels[selector.Label] = selector.Value
+			}
+		}
+	}
+	return &Function{
+		Name:        "absent",
+		Description: "Returns 1 when the query has no results, for alerting on missing data",
+		Apply: func(results []QueryResult) (interface{}, error) {
+			if len(results) > 0 {
+				return []QueryResult{}, nil
+			}
+			return []QueryResult{{
+				Type:      resultType,
+				Timestamp: time.Now().UTC(),
+				Labels:    withoutLabels(labels),
+				Value:     1.0,
+				Data: map[string]interface{}{
+					"function": "absent",
+				},
+			}}, nil
+		},
+	}
+}
+
 // toFloat64 converts various types to float64
 func toFloat64(val interface{}) (float64, error) {
 	switch v := val.(type) {
@@ -461,10 +1312,15 @@ func toFloat64(val interface{}) (float64, error) {
 
 // GetFunctionSuggestions returns function suggestions for auto-completion
 func GetFunctionSuggestions() []string {
-	suggestions := make([]string, 0, len(functions))
+	suggestions := make([]string, 0, len(functions)+len(functionBuilders))
 	for name := range functions {
 		suggestions = append(suggestions, name+"(")
 	}
+	for name := range functionBuilders {
+		if _, ok := functions[name]; !ok {
+			suggestions = append(suggestions, name+"(")
+		}
+	}
 	sort.Strings(suggestions)
 	return suggestions
 }
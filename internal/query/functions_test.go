@@ -0,0 +1,280 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getlawrence/lawrence-oss/internal/histogram"
+)
+
+// counterPoints returns metric results one minute apart with the given data
+func counterPoints(start time.Time, data map[string]interface{}, values ...float64) []QueryResult {
+	results := make([]QueryResult, len(values))
+	for i, value := range values {
+		results[i] = QueryResult{
+			Type:      TelemetryTypeMetrics,
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+			Value:     value,
+			Data:      data,
+		}
+	}
+	return results
+}
+
+func applyFunction(t *testing.T, name string, results []QueryResult) float64 {
+	fn, ok := GetFunction(name)
+	if !ok {
+		t.Fatalf("Function %s not found", name)
+	}
+	out, err := fn.Apply(results)
+	if err != nil {
+		t.Fatalf("%s() failed: %v", name, err)
+	}
+	return out.([]QueryResult)[0].Value.(float64)
+}
+
+func TestIncrease_Temporality(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	cumulative := map[string]interface{}{"type": "counter", "temporality": "cumulative", "monotonic": true}
+	delta := map[string]interface{}{"type": "counter", "temporality": "delta", "monotonic": true}
+	deltaWithStart := map[string]interface{}{"type": "counter", "temporality": "delta", "monotonic": true,
+		"start_time": start.Add(-time.Minute)}
+	upDown := map[string]interface{}{"type": "counter", "temporality": "cumulative", "monotonic": false}
+
+	tests := []struct {
+		name     string
+		results  []QueryResult
+		increase float64
+		rate     float64
+	}{
+		{"cumulative", counterPoints(start, cumulative, 10, 15, 25), 15, 15.0 / 120},
+		{"cumulative with reset", counterPoints(start, cumulative, 10, 20, 5, 8), 18, 18.0 / 180},
+		{"delta without start time", counterPoints(start, delta, 4, 6, 8), 14, 14.0 / 120},
+		{"delta with start time", counterPoints(start, deltaWithStart, 4, 6, 8), 18, 18.0 / 180},
+		{"non-monotonic sum", counterPoints(start, upDown, 10, 4, 6), -4, -4.0 / 120},
+		{"gauge", counterPoints(start, map[string]interface{}{"type": "gauge"}, 3, 9, 1), -2, -2.0 / 120},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyFunction(t, "increase", tt.results); got != tt.increase {
+				t.Errorf("Expected increase %v, got %v", tt.increase, got)
+			}
+			if got := applyFunction(t, "rate", tt.results); got != tt.rate {
+				t.Errorf("Expected rate %v, got %v", tt.rate, got)
+			}
+		})
+	}
+}
+
+func TestRate_RejectsMixedTemporality(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	results := append(
+		counterPoints(start, map[string]interface{}{"temporality": "delta"}, 1),
+		counterPoints(start.Add(time.Minute), map[string]interface{}{"temporality": "cumulative"}, 2)...,
+	)
+
+	fn, _ := GetFunction("rate")
+	_, err := fn.Apply(results)
+	if err == nil || !strings.Contains(err.Error(), "cannot mix series with different temporalities") {
+		t.Errorf("Expected mixed temporality error, got %v", err)
+	}
+}
+
+// quantilesByLabel applies histogram_quantile and returns the value of each quantile label
+func quantilesByLabel(t *testing.T, results []QueryResult) map[string]float64 {
+	fn, _ := GetFunction("histogram_quantile")
+	out, err := fn.Apply(results)
+	if err != nil {
+		t.Fatalf("histogram_quantile() failed: %v", err)
+	}
+	values := make(map[string]float64)
+	for _, r := range out.([]QueryResult) {
+		values[r.Labels["quantile"]] = r.Value.(float64)
+	}
+	return values
+}
+
+func TestHistogramQuantile_ExponentialHistograms(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	// Buckets (1, 2] and (2, 4] with two observations each per point
+	data := map[string]interface{}{
+		"type": "exponential_histogram",
+		"exponential": &histogram.Exponential{
+			Positive: histogram.Buckets{Offset: 0, Counts: []int64{2, 2}},
+		},
+	}
+	quantiles := quantilesByLabel(t, counterPoints(start, data, 3, 3))
+
+	if quantiles["p50"] != 2 {
+		t.Errorf("Expected p50 2, got %v", quantiles["p50"])
+	}
+	if math.Abs(quantiles["p90"]-3.6) > 1e-9 {
+		t.Errorf("Expected p90 3.6, got %v", quantiles["p90"])
+	}
+}
+
+func TestHistogramQuantile_CumulativeExponentialHistograms(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	exponentialPoint := func(offset time.Duration, host, temporality string, counts ...int64) QueryResult {
+		return QueryResult{
+			Type:      TelemetryTypeMetrics,
+			Timestamp: start.Add(offset),
+			Labels:    map[string]string{"host": host},
+			Data: map[string]interface{}{
+				"name": "latency", "type": "exponential_histogram", "temporality": temporality,
+				"exponential": &histogram.Exponential{Positive: histogram.Buckets{Offset: 0, Counts: counts}},
+			},
+		}
+	}
+	results := []QueryResult{
+		// Cumulative series contribute the four observations made in
+		// between their snapshots, all in (1, 2]
+		exponentialPoint(2*time.Minute, "a", "cumulative", 14, 0),
+		exponentialPoint(0, "a", "cumulative", 10, 0),
+		exponentialPoint(time.Minute, "a", "cumulative", 12, 0),
+		// Delta samples are summed: four in (2, 4]
+		exponentialPoint(0, "b", "delta", 0, 2),
+		exponentialPoint(time.Minute, "b", "delta", 0, 2),
+	}
+	quantiles := quantilesByLabel(t, results)
+	if quantiles["p50"] != 2 {
+		t.Errorf("Expected p50 2, got %v", quantiles["p50"])
+	}
+
+	// Fewer observations than the previous snapshot are a counter reset
+	merged := mergeExponential([]QueryResult{
+		exponentialPoint(0, "a", "cumulative", 10, 0),
+		exponentialPoint(time.Minute, "a", "cumulative", 1, 0),
+	})
+	if merged == nil || merged.Count() != 1 {
+		t.Errorf("Expected the reset sample counted whole, got %+v", merged)
+	}
+}
+
+func TestHistogramQuantile_Summaries(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	results := []QueryResult{
+		{Timestamp: start, Data: map[string]interface{}{"type": "summary", "count": int64(1), "quantiles": []histogram.QuantileValue{
+			{Quantile: 0.5, Value: 10}, {Quantile: 0.9, Value: 20}, {Quantile: 0.99, Value: 30},
+		}}},
+		{Timestamp: start, Data: map[string]interface{}{"type": "summary", "count": int64(3), "quantiles": []histogram.QuantileValue{
+			{Quantile: 0.5, Value: 20}, {Quantile: 0.9, Value: 40}, {Quantile: 0.99, Value: 50},
+		}}},
+	}
+	quantiles := quantilesByLabel(t, results)
+
+	// Quantiles are averaged weighted by count
+	expected := map[string]float64{"p50": 17.5, "p90": 35, "p95": 35 + 10*0.05/0.09, "p99": 45}
+	for label, want := range expected {
+		if math.Abs(quantiles[label]-want) > 1e-9 {
+			t.Errorf("Expected %s %v, got %v", label, want, quantiles[label])
+		}
+	}
+}
+
+func TestHistogramQuantile_ExplicitBuckets(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	bucketPoint := func(offset time.Duration, host, temporality string, counts []int64, bounds []float64) QueryResult {
+		return QueryResult{
+			Type:      TelemetryTypeMetrics,
+			Timestamp: start.Add(offset),
+			Labels:    map[string]string{"host": host},
+			Data: map[string]interface{}{
+				"name": "latency", "type": "histogram", "temporality": temporality,
+				"bucket_counts": counts, "explicit_bounds": bounds,
+			},
+		}
+	}
+	results := []QueryResult{
+		// Cumulative series contribute the observations made in between
+		// their snapshots: two in (0, 1] and two in (1, 2]
+		bucketPoint(time.Minute, "a", "cumulative", []int64{7, 3, 0}, []float64{1, 2}),
+		bucketPoint(0, "a", "cumulative", []int64{5, 1, 0}, []float64{1, 2}),
+		// Delta samples are summed: four in (2, 4]
+		bucketPoint(0, "b", "delta", []int64{0, 0, 2, 0}, []float64{1, 2, 4}),
+		bucketPoint(time.Minute, "b", "delta", []int64{0, 0, 2, 0}, []float64{1, 2, 4}),
+	}
+
+	for q, want := range map[float64]float64{0.5: 2, 0.75: 3, 0.25: 1} {
+		fn, err := histogramQuantileAt(q)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		out, err := fn.Apply(results)
+		if err != nil {
+			t.Fatalf("histogram_quantile() failed: %v", err)
+		}
+		quantiles := out.([]QueryResult)
+		if len(quantiles) != 1 || math.Abs(quantiles[0].Value.(float64)-want) > 1e-9 {
+			t.Errorf("Expected quantile %v to be %v, got %+v", q, want, quantiles)
+		}
+	}
+
+	// A drop in any bucket is a counter reset
+	counts, bounds, ok := mergeExplicitBuckets([]QueryResult{
+		bucketPoint(0, "a", "cumulative", []int64{5, 1, 0}, []float64{1, 2}),
+		bucketPoint(time.Minute, "a", "cumulative", []int64{1, 0, 0}, []float64{1, 2}),
+	})
+	if !ok || len(bounds) != 2 || counts[0] != 1 || counts[1] != 0 {
+		t.Errorf("Expected the reset sample counted whole, got %v %v", counts, bounds)
+	}
+
+	if _, err := histogramQuantileAt(1.5); err == nil {
+		t.Error("Expected quantiles above 1 to be rejected")
+	}
+}
+
+func TestWindowFunctions(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	gauge := map[string]interface{}{"type": "gauge", "name": "queue_depth"}
+	samples := counterPoints(start, gauge, 10, 16, 13, 25)
+
+	tests := []struct {
+		name     string
+		params   []Query
+		expected float64
+	}{
+		{"avg_over_time", nil, 16},
+		{"quantile_over_time", []Query{&NumberLiteral{Value: 0.5}}, 14.5},
+		{"delta", nil, 15},
+		// The least squares fit of 10, 16, 13, 25 rises 4.2 per minute and
+		// reaches 22.3 at the latest sample
+		{"deriv", nil, 4.2 / 60},
+		{"predict_linear", []Query{&NumberLiteral{Value: 120}}, 22.3 + 8.4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, err := resolveFunction(tt.name, tt.params, nil)
+			if err != nil {
+				t.Fatalf("Failed to resolve %s: %v", tt.name, err)
+			}
+			out, err := fn.Apply(samples)
+			if err != nil {
+				t.Fatalf("%s() failed: %v", tt.name, err)
+			}
+			results := out.([]QueryResult)
+			if len(results) != 1 || math.Abs(results[0].Value.(float64)-tt.expected) > 1e-9 {
+				t.Fatalf("Expected %v, got %+v", tt.expected, results)
+			}
+			if _, ok := results[0].Labels["name"]; ok || !results[0].Timestamp.Equal(start.Add(3*time.Minute)) {
+				t.Errorf("Expected the latest timestamp without the metric name, got %+v", results[0])
+			}
+		})
+	}
+
+	if _, err := resolveFunction("quantile_over_time", []Query{&NumberLiteral{Value: 2}}, nil); err == nil {
+		t.Error("Expected a quantile above 1 to be rejected")
+	}
+	if _, err := resolveFunction("clamp", []Query{&NumberLiteral{Value: 1}}, nil); err == nil {
+		t.Error("Expected clamp without a maximum to be rejected")
+	}
+	if _, err := resolveFunction("abs", []Query{&NumberLiteral{Value: 1}}, nil); err == nil {
+		t.Error("Expected abs with a parameter to be rejected")
+	}
+}
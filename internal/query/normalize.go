@@ -0,0 +1,203 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NormalizeQuery renders a parsed query in a canonical form: selectors and
+// grouping labels sorted, literals and durations in a single spelling and
+// every operation parenthesized. Queries that differ only in formatting
+// render the same.
+func NormalizeQuery(q Query) (string, error) {
+	normalized, err := q.Accept(normalizer{})
+	if err != nil {
+		return "", err
+	}
+	return normalized.(string), nil
+}
+
+// normalizer implements QueryVisitor to render the canonical form of a query
+type normalizer struct{}
+
+// render renders a subquery
+func (n normalizer) render(q Query) (string, error) {
+	normalized, err := q.Accept(n)
+	if err != nil {
+		return "", err
+	}
+	return normalized.(string), nil
+}
+
+// VisitTelemetryQuery renders a selector with its pipeline, range and limit
+func (n normalizer) VisitTelemetryQuery(q *TelemetryQuery) (interface{}, error) {
+	var b strings.Builder
+	b.WriteString(string(q.Type))
+	b.WriteString("{")
+	for i, selector := range sortedSelectors(q.Selectors) {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(normalizeSelector(selector))
+	}
+	b.WriteString("}")
+
+	for _, stage := range q.Pipeline {
+		b.WriteString(" ")
+		b.WriteString(normalizeStage(stage))
+	}
+	if q.Duration > 0 {
+		fmt.Fprintf(&b, " [%s]", q.Duration)
+	}
+	if q.StartTime != nil || q.EndTime != nil {
+		fmt.Fprintf(&b, " @%s,%s", normalizeTime(q.StartTime), normalizeTime(q.EndTime))
+	}
+	if q.Limit > 0 {
+		fmt.Fprintf(&b, " limit %d", q.Limit)
+	}
+	return b.String(), nil
+}
+
+// VisitStructuralQuery renders both span sets and their relation
+func (n normalizer) VisitStructuralQuery(s *StructuralQuery) (interface{}, error) {
+	left, err := n.render(s.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.render(s.Right)
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf("(%s %s %s)", left, s.Operator, right), nil
+}
+
+// VisitBinaryOp renders both operands, the operator and vector matching
+func (n normalizer) VisitBinaryOp(b *BinaryOp) (interface{}, error) {
+	left, err := n.render(b.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.render(b.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	operator := string(b.Operator)
+	if m := b.Matching; m != nil {
+		keyword := "ignoring"
+		if m.On {
+			keyword = "on"
+		}
+		operator += fmt.Sprintf(" %s(%s)", keyword, sortedLabels(m.Labels))
+		switch m.Card {
+		case MatchManyToOne:
+			operator += fmt.Sprintf(" group_left(%s)", sortedLabels(m.Include))
+		case MatchOneToMany:
+			operator += fmt.Sprintf(" group_right(%s)", sortedLabels(m.Include))
+		}
+	}
+	return fmt.Sprintf("(%s %s %s)", left, operator, right), nil
+}
+
+// VisitFunctionCall renders a function and its arguments
+func (n normalizer) VisitFunctionCall(f *FunctionCall) (interface{}, error) {
+	args := make([]string, len(f.Args))
+	for i, arg := range f.Args {
+		rendered, err := n.render(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = rendered
+	}
+	return fmt.Sprintf("%s(%s)", f.Name, strings.Join(args, ", ")), nil
+}
+
+// VisitAggregation renders an aggregation, its parameter and grouping
+func (n normalizer) VisitAggregation(a *Aggregation) (interface{}, error) {
+	inner, err := n.render(a.Query)
+	if err != nil {
+		return nil, err
+	}
+	if a.Param != nil {
+		inner = normalizeNumber(a.Param.Value) + ", " + inner
+	}
+	normalized := fmt.Sprintf("%s(%s)", a.Function, inner)
+	if len(a.By) > 0 {
+		normalized += fmt.Sprintf(" by (%s)", sortedLabels(a.By))
+	}
+	return normalized, nil
+}
+
+// VisitNumberLiteral renders a number in its shortest form
+func (n normalizer) VisitNumberLiteral(l *NumberLiteral) (interface{}, error) {
+	return normalizeNumber(l.Value), nil
+}
+
+// VisitStringLiteral renders a quoted string
+func (n normalizer) VisitStringLiteral(l *StringLiteral) (interface{}, error) {
+	return strconv.Quote(l.Value), nil
+}
+
+// normalizeSelector renders a label selector, quoting values that are not
+// number literals
+func normalizeSelector(selector *Selector) string {
+	value := strconv.Quote(selector.Value)
+	if selector.Numeric {
+		value = selector.Value
+	}
+	return fmt.Sprintf("%s%s%s", selector.Label, selector.Operator, value)
+}
+
+// normalizeStage renders a logs pipeline stage
+func normalizeStage(stage *PipelineStage) string {
+	switch stage.Kind {
+	case StageLineFilter:
+		operator := map[SelectorOperator]string{
+			SelectorOpEqual:    "|=",
+			SelectorOpNotEqual: "!=",
+			SelectorOpRegex:    "|~",
+			SelectorOpNotRegex: "!~",
+		}[stage.Operator]
+		return fmt.Sprintf("%s %s", operator, strconv.Quote(stage.Value))
+	case StageParser:
+		if stage.Value != "" {
+			return fmt.Sprintf("| %s %s", stage.Parser, strconv.Quote(stage.Value))
+		}
+		return "| " + stage.Parser
+	case StageLabelFilter:
+		filters := make([]string, len(stage.Filters))
+		for i, filter := range stage.Filters {
+			filters[i] = normalizeSelector(filter)
+		}
+		return "| " + strings.Join(filters, ", ")
+	case StageLineFormat:
+		return "| line_format " + strconv.Quote(stage.Value)
+	}
+	return fmt.Sprintf("| %s", stage.Kind)
+}
+
+// normalizeNumber renders a number in its shortest form
+func normalizeNumber(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// normalizeTime renders an optional absolute time
+func normalizeTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// sortedLabels renders a sorted, comma separated copy of labels
+func sortedLabels(labels []string) string {
+	sorted := slices.Clone(labels)
+	slices.Sort(sorted)
+	return strings.Join(sorted, ",")
+}
@@ -6,8 +6,10 @@ package query
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 )
 
@@ -54,8 +56,13 @@ const (
 	TokenGTE      // >=
 	TokenAnd      // and
 	TokenOr       // or
+	TokenUnless   // unless
 	TokenBy       // by
 	TokenPipe     // |
+	TokenContains // |=
+	TokenMatches  // |~
+	TokenDescend  // >>
+	TokenTilde    // ~
 )
 
 // NewParser creates a new parser for the given input
@@ -121,6 +128,18 @@ func (p *Parser) tokenize() error {
 				p.tokens = append(p.tokens, Token{Type: TokenEqual, Value: "==", Pos: pos})
 				pos += 2
 				continue
+			case ">>":
+				p.tokens = append(p.tokens, Token{Type: TokenDescend, Value: ">>", Pos: pos})
+				pos += 2
+				continue
+			case "|=":
+				p.tokens = append(p.tokens, Token{Type: TokenContains, Value: "|=", Pos: pos})
+				pos += 2
+				continue
+			case "|~":
+				p.tokens = append(p.tokens, Token{Type: TokenMatches, Value: "|~", Pos: pos})
+				pos += 2
+				continue
 			}
 		}
 
@@ -171,6 +190,9 @@ func (p *Parser) tokenize() error {
 		case '|':
 			p.tokens = append(p.tokens, Token{Type: TokenPipe, Value: "|", Pos: pos})
 			pos++
+		case '~':
+			p.tokens = append(p.tokens, Token{Type: TokenTilde, Value: "~", Pos: pos})
+			pos++
 		case '"':
 			// String literal
 			start := pos
@@ -189,9 +211,10 @@ func (p *Parser) tokenize() error {
 			p.tokens = append(p.tokens, Token{Type: TokenString, Value: input[start+1 : pos-1], Pos: start})
 		default:
 			// Identifier or number
-			if isAlpha(input[pos]) {
+			if isAlpha(input[pos]) || input[pos] == '_' {
 				start := pos
-				for pos < len(input) && (isAlphaNumeric(input[pos]) || input[pos] == '_') {
+				// Dots are allowed inside identifiers for attribute keys like http.status_code
+				for pos < len(input) && (isAlphaNumeric(input[pos]) || input[pos] == '_' || input[pos] == '.') {
 					pos++
 				}
 				value := input[start:pos]
@@ -203,6 +226,8 @@ func (p *Parser) tokenize() error {
 					tokenType = TokenAnd
 				case "or":
 					tokenType = TokenOr
+				case "unless":
+					tokenType = TokenUnless
 				case "by":
 					tokenType = TokenBy
 				}
@@ -213,8 +238,10 @@ func (p *Parser) tokenize() error {
 				for pos < len(input) && (isDigit(input[pos]) || input[pos] == '.') {
 					pos++
 				}
-				// Check if this is followed by a duration unit (s, m, h, d)
-				if pos < len(input) && (input[pos] == 's' || input[pos] == 'm' || input[pos] == 'h' || input[pos] == 'd') {
+				// Check if this is followed by a duration unit (ms, us, ns, s, m, h, d)
+				if pos+1 < len(input) && (input[pos] == 'm' || input[pos] == 'u' || input[pos] == 'n') && input[pos+1] == 's' {
+					pos += 2
+				} else if pos < len(input) && (input[pos] == 's' || input[pos] == 'm' || input[pos] == 'h' || input[pos] == 'd') {
 					pos++
 				}
 				p.tokens = append(p.tokens, Token{Type: TokenNumber, Value: input[start:pos], Pos: start})
@@ -233,8 +260,37 @@ func (p *Parser) parseQuery() (Query, error) {
 	return p.parseBinaryOp()
 }
 
+// binaryPrecedence ranks binary operators from or, which binds loosest, to
+// multiplication and division. Operators of equal precedence associate to
+// the left.
+var binaryPrecedence = map[BinaryOperator]int{
+	BinaryOpOr:       1,
+	BinaryOpAnd:      2,
+	BinaryOpUnless:   2,
+	BinaryOpEqual:    3,
+	BinaryOpNotEqual: 3,
+	BinaryOpLT:       3,
+	BinaryOpGT:       3,
+	BinaryOpLTE:      3,
+	BinaryOpGTE:      3,
+	BinaryOpAdd:      4,
+	BinaryOpSubtract: 4,
+	BinaryOpMultiply: 5,
+	BinaryOpDivide:   5,
+}
+
+// structuralPrecedence is the precedence of structural operators, that of
+// the comparison > they share a token with
+const structuralPrecedence = 3
+
 // parseBinaryOp parses binary operations
 func (p *Parser) parseBinaryOp() (Query, error) {
+	return p.parseBinaryExpr(1)
+}
+
+// parseBinaryExpr parses binary operations whose operators have at least
+// the given precedence
+func (p *Parser) parseBinaryExpr(minPrecedence int) (Query, error) {
 	left, err := p.parsePrimary()
 	if err != nil {
 		return nil, err
@@ -242,8 +298,22 @@ func (p *Parser) parseBinaryOp() (Query, error) {
 
 	for {
 		token := p.peek()
-		if token.Type == TokenEOF {
-			break
+
+		// Structural operators between traces queries
+		if structural, ok := structuralOperator(token, left); ok {
+			if structuralPrecedence < minPrecedence {
+				return left, nil
+			}
+			p.consume()
+			right, err := p.parseBinaryExpr(structuralPrecedence + 1)
+			if err != nil {
+				return nil, err
+			}
+			if !isTracesQuery(right) {
+				return nil, fmt.Errorf("structural operator '%s' at position %d requires traces queries", token.Value, token.Pos)
+			}
+			left = &StructuralQuery{Left: left, Operator: structural, Right: right}
+			continue
 		}
 
 		var op BinaryOperator
@@ -260,6 +330,15 @@ func (p *Parser) parseBinaryOp() (Query, error) {
 			op = BinaryOpAnd
 		case TokenOr:
 			op = BinaryOpOr
+		case TokenUnless:
+			op = BinaryOpUnless
+		case TokenEqual:
+			if token.Value != "==" {
+				return left, nil
+			}
+			op = BinaryOpEqual
+		case TokenNotEqual:
+			op = BinaryOpNotEqual
 		case TokenLT:
 			op = BinaryOpLT
 		case TokenGT:
@@ -268,12 +347,22 @@ func (p *Parser) parseBinaryOp() (Query, error) {
 			op = BinaryOpLTE
 		case TokenGTE:
 			op = BinaryOpGTE
+		case TokenDescend, TokenTilde:
+			return nil, fmt.Errorf("structural operator '%s' at position %d requires traces queries", token.Value, token.Pos)
 		default:
 			return left, nil
 		}
+		precedence := binaryPrecedence[op]
+		if precedence < minPrecedence {
+			return left, nil
+		}
 
 		p.consume()
-		right, err := p.parsePrimary()
+		matching, err := p.parseVectorMatching(op)
+		if err != nil {
+			return nil, err
+		}
+		right, err := p.parseBinaryExpr(precedence + 1)
 		if err != nil {
 			return nil, err
 		}
@@ -282,10 +371,106 @@ func (p *Parser) parseBinaryOp() (Query, error) {
 			Left:     left,
 			Operator: op,
 			Right:    right,
+			Matching: matching,
+		}
+	}
+}
+
+// parseVectorMatching parses the clause after a binary operator pairing the
+// series of its operands: on(labels) or ignoring(labels), optionally
+// followed by group_left(labels) or group_right(labels). It returns nil if
+// there is none.
+func (p *Parser) parseVectorMatching(op BinaryOperator) (*VectorMatching, error) {
+	token := p.peek()
+	if token.Type != TokenIdentifier || (token.Value != "on" && token.Value != "ignoring") {
+		return nil, nil
+	}
+	p.consume()
+	labels, err := p.parseLabelList(token.Value)
+	if err != nil {
+		return nil, err
+	}
+	matching := &VectorMatching{Card: MatchOneToOne, On: token.Value == "on", Labels: labels}
+
+	token = p.peek()
+	if token.Type != TokenIdentifier || (token.Value != "group_left" && token.Value != "group_right") {
+		return matching, nil
+	}
+	if isSetOperator(op) {
+		return nil, fmt.Errorf("%s at position %d cannot be used with set operator '%s'", token.Value, token.Pos, op)
+	}
+	p.consume()
+	matching.Card = MatchManyToOne
+	if token.Value == "group_right" {
+		matching.Card = MatchOneToMany
+	}
+	if p.peek().Type == TokenLParen {
+		if matching.Include, err = p.parseLabelList(token.Value); err != nil {
+			return nil, err
+		}
+	}
+	return matching, nil
+}
+
+// parseLabelList parses a parenthesized, comma separated list of labels
+// following a keyword such as by or on
+func (p *Parser) parseLabelList(keyword string) ([]string, error) {
+	if p.peek().Type != TokenLParen {
+		return nil, fmt.Errorf("expected '(' after '%s' at position %d", keyword, p.peek().Pos)
+	}
+	p.consume()
+
+	labels := []string{}
+	for p.peek().Type != TokenRParen {
+		labelToken := p.consume()
+		if labelToken.Type != TokenIdentifier {
+			return nil, fmt.Errorf("expected label identifier at position %d", labelToken.Pos)
+		}
+		labels = append(labels, labelToken.Value)
+
+		if p.peek().Type == TokenComma {
+			p.consume()
+		} else if p.peek().Type != TokenRParen {
+			return nil, fmt.Errorf("expected ',' or ')' at position %d", p.peek().Pos)
 		}
 	}
+	p.consume() // consume ')'
+	return labels, nil
+}
+
+// isSetOperator reports whether an operator combines series by their
+// presence rather than their values
+func isSetOperator(op BinaryOperator) bool {
+	return op == BinaryOpAnd || op == BinaryOpOr || op == BinaryOpUnless
+}
 
-	return left, nil
+// structuralOperator returns the structural operator a token stands for
+// after a traces query: > for children, >> for descendants and ~ for
+// siblings
+func structuralOperator(token Token, left Query) (StructuralOperator, bool) {
+	if !isTracesQuery(left) {
+		return "", false
+	}
+	switch token.Type {
+	case TokenGT:
+		return StructuralChild, true
+	case TokenDescend:
+		return StructuralDescendant, true
+	case TokenTilde:
+		return StructuralSibling, true
+	}
+	return "", false
+}
+
+// isTracesQuery reports whether a query selects spans
+func isTracesQuery(q Query) bool {
+	switch q := q.(type) {
+	case *TelemetryQuery:
+		return q.Type == TelemetryTypeTraces
+	case *StructuralQuery:
+		return true
+	}
+	return false
 }
 
 // parsePrimary parses primary expressions
@@ -302,6 +487,27 @@ func (p *Parser) parsePrimary() (Query, error) {
 		return p.parseFunctionCall()
 	}
 
+	// Scalars, such as the quantile of histogram_quantile or an operand of
+	// arithmetic, which may be negative
+	if token.Type == TokenNumber || (token.Type == TokenMinus && p.peekAt(1).Type == TokenNumber) {
+		sign := 1.0
+		if p.consume().Type == TokenMinus {
+			sign = -1
+			token = p.consume()
+		}
+		value, err := strconv.ParseFloat(token.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' at position %d", token.Value, token.Pos)
+		}
+		return &NumberLiteral{Value: sign * value}, nil
+	}
+
+	// String function parameter, e.g. the replacement of label_replace
+	if token.Type == TokenString {
+		p.consume()
+		return &StringLiteral{Value: token.Value}, nil
+	}
+
 	// Parenthesized expression
 	if token.Type == TokenLParen {
 		p.consume()
@@ -341,13 +547,22 @@ func (p *Parser) parseTelemetryQuery() (*TelemetryQuery, error) {
 	}
 	p.consume()
 
+	// Selectors are keyed by label. A repeated label, as in a numeric range
+	// like {code>=200, code<300}, is keyed by label and operator.
 	selectors := make(map[string]*Selector)
 	for p.peek().Type != TokenRBrace {
 		selector, err := p.parseSelector()
 		if err != nil {
 			return nil, err
 		}
-		selectors[selector.Label] = selector
+		key := selector.Label
+		if existing, exists := selectors[key]; exists && existing.Operator != selector.Operator {
+			key += string(selector.Operator)
+		}
+		if _, exists := selectors[key]; exists {
+			return nil, fmt.Errorf("duplicate selector '%s%s' at position %d", selector.Label, selector.Operator, p.peek().Pos)
+		}
+		selectors[key] = selector
 
 		if p.peek().Type == TokenComma {
 			p.consume()
@@ -358,19 +573,24 @@ func (p *Parser) parseTelemetryQuery() (*TelemetryQuery, error) {
 	p.consume() // consume '}'
 
 	// Parse duration: [5m]
-	var duration time.Duration
-	if p.peek().Type == TokenLBracket {
-		p.consume()
-		durationToken := p.consume()
-		var err error
-		duration, err = parseDuration(durationToken.Value)
-		if err != nil {
-			return nil, fmt.Errorf("invalid duration '%s' at position %d: %v", durationToken.Value, durationToken.Pos, err)
-		}
-		if p.peek().Type != TokenRBracket {
-			return nil, fmt.Errorf("expected ']' at position %d", p.peek().Pos)
+	duration, err := p.parseRange()
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the logs pipeline, which the duration may also follow as in
+	// logs{service="api"} |= "error" [5m]
+	pipeline, err := p.parsePipeline()
+	if err != nil {
+		return nil, err
+	}
+	if len(pipeline) > 0 && telemetryType != TelemetryTypeLogs {
+		return nil, fmt.Errorf("pipelines are only supported for logs, not %s", telemetryType)
+	}
+	if duration == 0 {
+		if duration, err = p.parseRange(); err != nil {
+			return nil, err
 		}
-		p.consume()
 	}
 
 	return &TelemetryQuery{
@@ -378,10 +598,130 @@ func (p *Parser) parseTelemetryQuery() (*TelemetryQuery, error) {
 		Selectors: selectors,
 		Duration:  duration,
 		Limit:     1000, // default
+		Pipeline:  pipeline,
 	}, nil
 }
 
-// parseSelector parses a selector: label=value or label=~"regex"
+// parseRange parses an optional duration: [5m]
+func (p *Parser) parseRange() (time.Duration, error) {
+	if p.peek().Type != TokenLBracket {
+		return 0, nil
+	}
+	p.consume()
+	durationToken := p.consume()
+	duration, err := parseDuration(durationToken.Value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s' at position %d: %v", durationToken.Value, durationToken.Pos, err)
+	}
+	if p.peek().Type != TokenRBracket {
+		return 0, fmt.Errorf("expected ']' at position %d", p.peek().Pos)
+	}
+	p.consume()
+	return duration, nil
+}
+
+// parsePipeline parses logs pipeline stages: line filters such as
+// |= "text", != "text", |~ "regex" and !~ "regex", and stages after a pipe:
+// | json, | logfmt, | regexp "pattern", | line_format "template" and label
+// filters like | status >= 500, method = "GET"
+func (p *Parser) parsePipeline() ([]*PipelineStage, error) {
+	var stages []*PipelineStage
+	for {
+		token := p.peek()
+		var op SelectorOperator
+		switch token.Type {
+		case TokenContains:
+			op = SelectorOpEqual
+		case TokenNotEqual:
+			// Otherwise != compares the query with another operand
+			if p.peekAt(1).Type != TokenString {
+				return stages, nil
+			}
+			op = SelectorOpNotEqual
+		case TokenMatches:
+			op = SelectorOpRegex
+		case TokenNotRegex:
+			op = SelectorOpNotRegex
+		case TokenPipe:
+			p.consume()
+			stage, err := p.parsePipelineStage()
+			if err != nil {
+				return nil, err
+			}
+			stages = append(stages, stage)
+			continue
+		default:
+			return stages, nil
+		}
+
+		p.consume()
+		valueToken := p.consume()
+		if valueToken.Type != TokenString {
+			return nil, fmt.Errorf("expected string after '%s' at position %d", token.Value, valueToken.Pos)
+		}
+		if op == SelectorOpRegex || op == SelectorOpNotRegex {
+			if _, err := regexp.Compile(valueToken.Value); err != nil {
+				return nil, fmt.Errorf("invalid line filter regex at position %d: %v", valueToken.Pos, err)
+			}
+		}
+		stages = append(stages, &PipelineStage{Kind: StageLineFilter, Operator: op, Value: valueToken.Value})
+	}
+}
+
+// parsePipelineStage parses the stage after a pipe
+func (p *Parser) parsePipelineStage() (*PipelineStage, error) {
+	token := p.peek()
+	if token.Type != TokenIdentifier {
+		return nil, fmt.Errorf("expected pipeline stage at position %d", token.Pos)
+	}
+
+	switch token.Value {
+	case "json", "logfmt":
+		p.consume()
+		return &PipelineStage{Kind: StageParser, Parser: token.Value}, nil
+	case "regexp", "regex":
+		p.consume()
+		patternToken := p.consume()
+		if patternToken.Type != TokenString {
+			return nil, fmt.Errorf("expected regexp pattern at position %d", patternToken.Pos)
+		}
+		pattern, err := regexp.Compile(patternToken.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp pattern at position %d: %v", patternToken.Pos, err)
+		}
+		if !slices.ContainsFunc(pattern.SubexpNames(), func(name string) bool { return name != "" }) {
+			return nil, fmt.Errorf("regexp pattern at position %d has no named capture groups", patternToken.Pos)
+		}
+		return &PipelineStage{Kind: StageParser, Parser: "regexp", Value: patternToken.Value}, nil
+	case "line_format":
+		p.consume()
+		templateToken := p.consume()
+		if templateToken.Type != TokenString {
+			return nil, fmt.Errorf("expected line_format template at position %d", templateToken.Pos)
+		}
+		if _, err := template.New("line_format").Parse(templateToken.Value); err != nil {
+			return nil, fmt.Errorf("invalid line_format template at position %d: %v", templateToken.Pos, err)
+		}
+		return &PipelineStage{Kind: StageLineFormat, Value: templateToken.Value}, nil
+	}
+
+	// Label filters, all of which must match
+	stage := &PipelineStage{Kind: StageLabelFilter}
+	for {
+		selector, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		stage.Filters = append(stage.Filters, selector)
+
+		if p.peek().Type != TokenComma {
+			return stage, nil
+		}
+		p.consume()
+	}
+}
+
+// parseSelector parses a selector: label=value, label=~"regex" or label>number
 func (p *Parser) parseSelector() (*Selector, error) {
 	labelToken := p.consume()
 	if labelToken.Type != TokenIdentifier {
@@ -399,20 +739,55 @@ func (p *Parser) parseSelector() (*Selector, error) {
 		op = SelectorOpRegex
 	case TokenNotRegex:
 		op = SelectorOpNotRegex
+	case TokenGT:
+		op = SelectorOpGT
+	case TokenGTE:
+		op = SelectorOpGTE
+	case TokenLT:
+		op = SelectorOpLT
+	case TokenLTE:
+		op = SelectorOpLTE
 	default:
 		return nil, fmt.Errorf("expected selector operator at position %d", opToken.Pos)
 	}
 
-	valueToken := p.consume()
-	if valueToken.Type != TokenString && valueToken.Type != TokenIdentifier {
-		return nil, fmt.Errorf("expected value at position %d", valueToken.Pos)
+	// Numbers may be negative
+	negative := false
+	if p.peek().Type == TokenMinus {
+		p.consume()
+		negative = true
 	}
 
-	return &Selector{
+	valueToken := p.consume()
+	selector := &Selector{
 		Label:    labelToken.Value,
 		Operator: op,
 		Value:    valueToken.Value,
-	}, nil
+	}
+	switch valueToken.Type {
+	case TokenString, TokenIdentifier:
+		if negative {
+			return nil, fmt.Errorf("expected number at position %d", valueToken.Pos)
+		}
+	case TokenNumber:
+		if negative {
+			selector.Value = "-" + selector.Value
+		}
+		_, err := strconv.ParseFloat(selector.Value, 64)
+		selector.Numeric = err == nil
+		// Durations compare in nanoseconds, the unit of span durations
+		if duration, err := parseDuration(valueToken.Value); !selector.Numeric && !negative && err == nil {
+			selector.Value = strconv.FormatInt(int64(duration), 10)
+			selector.Numeric = true
+		}
+	default:
+		return nil, fmt.Errorf("expected value at position %d", valueToken.Pos)
+	}
+
+	if err := selector.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid selector '%s' at position %d: %v", selector.Label, labelToken.Pos, err)
+	}
+	return selector, nil
 }
 
 // parseFunctionCall parses a function call: func(query)
@@ -447,31 +822,26 @@ func (p *Parser) parseFunctionCall() (Query, error) {
 	// Check for aggregation with 'by' clause: sum(...) by (label1, label2)
 	if p.peek().Type == TokenBy {
 		p.consume()
-		if p.peek().Type != TokenLParen {
-			return nil, fmt.Errorf("expected '(' after 'by' at position %d", p.peek().Pos)
+		byLabels, err := p.parseLabelList("by")
+		if err != nil {
+			return nil, err
 		}
-		p.consume()
-
-		byLabels := []string{}
-		for p.peek().Type != TokenRParen {
-			labelToken := p.consume()
-			if labelToken.Type != TokenIdentifier {
-				return nil, fmt.Errorf("expected label identifier at position %d", labelToken.Pos)
-			}
-			byLabels = append(byLabels, labelToken.Value)
 
-			if p.peek().Type == TokenComma {
-				p.consume()
-			} else if p.peek().Type != TokenRParen {
-				return nil, fmt.Errorf("expected ',' or ')' at position %d", p.peek().Pos)
+		params, query, err := functionOperands(nameToken.Value, args)
+		if err != nil {
+			return nil, err
+		}
+		var param *NumberLiteral
+		if len(params) > 0 {
+			if param, _ = params[0].(*NumberLiteral); param == nil || len(params) > 1 {
+				return nil, fmt.Errorf("aggregation %s takes at most one number besides its query", nameToken.Value)
 			}
 		}
-		p.consume() // consume ')'
-
 		return &Aggregation{
 			Function: nameToken.Value,
-			Query:    args[0],
+			Query:    query,
 			By:       byLabels,
+			Param:    param,
 		}, nil
 	}
 
@@ -481,6 +851,28 @@ func (p *Parser) parseFunctionCall() (Query, error) {
 	}, nil
 }
 
+// functionOperands splits function arguments into the query the function
+// applies to and its number and string parameters, in order
+func functionOperands(name string, args []Query) ([]Query, Query, error) {
+	var params []Query
+	var query Query
+	for _, arg := range args {
+		switch arg.(type) {
+		case *NumberLiteral, *StringLiteral:
+			params = append(params, arg)
+		default:
+			if query != nil {
+				return nil, nil, fmt.Errorf("function %s takes a single query argument", name)
+			}
+			query = arg
+		}
+	}
+	if query == nil {
+		return nil, nil, fmt.Errorf("function %s requires a query argument", name)
+	}
+	return params, query, nil
+}
+
 // peek returns the current token without consuming it
 func (p *Parser) peek() Token {
 	if p.pos >= len(p.tokens) {
@@ -489,6 +881,15 @@ func (p *Parser) peek() Token {
 	return p.tokens[p.pos]
 }
 
+// peekAt returns the token offset tokens after the current one without
+// consuming anything
+func (p *Parser) peekAt(offset int) Token {
+	if p.pos+offset >= len(p.tokens) {
+		return Token{Type: TokenEOF, Value: "", Pos: len(p.input)}
+	}
+	return p.tokens[p.pos+offset]
+}
+
 // consume returns the current token and advances
 func (p *Parser) consume() Token {
 	token := p.peek()
@@ -496,12 +897,27 @@ func (p *Parser) consume() Token {
 	return token
 }
 
+// ParseDuration parses a Lawrence QL duration such as 30s, 5m or 7d
+func ParseDuration(s string) (time.Duration, error) {
+	return parseDuration(s)
+}
+
 // parseDuration parses a duration string (e.g., "5m", "1h", "7d")
 func parseDuration(s string) (time.Duration, error) {
 	if len(s) < 2 {
 		return 0, fmt.Errorf("invalid duration format")
 	}
 
+	for suffix, unit := range map[string]time.Duration{"ms": time.Millisecond, "us": time.Microsecond, "ns": time.Nanosecond} {
+		if valueStr, ok := strings.CutSuffix(s, suffix); ok {
+			value, err := strconv.ParseInt(valueStr, 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(value) * unit, nil
+		}
+	}
+
 	valueStr := s[:len(s)-1]
 	unit := s[len(s)-1]
 
@@ -560,6 +976,7 @@ func GetQuerySuggestions(queryStr string, cursorPos int) []string {
 		"count(",
 		"rate(",
 		"increase(",
+		"count_over_time(",
 	}
 
 	// Filter based on what's already typed
@@ -580,11 +997,16 @@ func GetQuerySuggestions(queryStr string, cursorPos int) []string {
 
 // ValidateSelector validates a selector value based on operator
 func (s *Selector) Validate() error {
-	if s.Operator == SelectorOpRegex || s.Operator == SelectorOpNotRegex {
+	switch s.Operator {
+	case SelectorOpRegex, SelectorOpNotRegex:
 		_, err := regexp.Compile(s.Value)
 		if err != nil {
 			return fmt.Errorf("invalid regex pattern: %v", err)
 		}
+	case SelectorOpGT, SelectorOpGTE, SelectorOpLT, SelectorOpLTE:
+		if !s.Numeric {
+			return fmt.Errorf("operator %s requires a number, got %q", s.Operator, s.Value)
+		}
 	}
 	return nil
 }
@@ -4,6 +4,7 @@
 package query
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -158,6 +159,91 @@ func TestParser_ParseAggregation(t *testing.T) {
 	}
 }
 
+func TestParser_ParseQuantileArgument(t *testing.T) {
+	query, err := NewParser(`histogram_quantile(0.99, metrics{metric="latency"} [5m]) by (service)`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	aggregation, ok := query.(*Aggregation)
+	if !ok {
+		t.Fatalf("Expected Aggregation, got %T", query)
+	}
+	if aggregation.Param == nil || aggregation.Param.Value != 0.99 {
+		t.Errorf("Expected quantile parameter 0.99, got %+v", aggregation.Param)
+	}
+	if _, ok := aggregation.Query.(*TelemetryQuery); !ok {
+		t.Errorf("Expected the selector as aggregated query, got %T", aggregation.Query)
+	}
+
+	query, err = NewParser(`histogram_quantile(0.5, metrics{metric="latency"})`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	call, ok := query.(*FunctionCall)
+	if !ok || len(call.Args) != 2 {
+		t.Fatalf("Expected function call with two arguments, got %+v", query)
+	}
+	if number, ok := call.Args[0].(*NumberLiteral); !ok || number.Value != 0.5 {
+		t.Errorf("Expected number literal 0.5, got %+v", call.Args[0])
+	}
+
+	for _, input := range []string{
+		`histogram_quantile(5m, metrics{metric="latency"})`,
+		`histogram_quantile(metrics{metric="latency"}, metrics{metric="latency"}) by (service)`,
+		`histogram_quantile() by (service)`,
+	} {
+		if _, err := NewParser(input).Parse(); err == nil {
+			t.Errorf("Expected error for %s", input)
+		}
+	}
+}
+
+func TestParser_ParseLogPipeline(t *testing.T) {
+	query, err := NewParser(`logs{service="api"} |= "error" !~ "debug" | json | status >= 500, method = "GET" | line_format "{{.msg}}" [5m]`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	telemetryQuery, ok := query.(*TelemetryQuery)
+	if !ok {
+		t.Fatalf("Expected TelemetryQuery, got %T", query)
+	}
+	if telemetryQuery.Duration != 5*time.Minute {
+		t.Errorf("Expected duration after the pipeline, got %v", telemetryQuery.Duration)
+	}
+
+	stages := telemetryQuery.Pipeline
+	if len(stages) != 5 {
+		t.Fatalf("Expected 5 stages, got %d", len(stages))
+	}
+	if stages[0].Kind != StageLineFilter || stages[0].Operator != SelectorOpEqual || stages[0].Value != "error" {
+		t.Errorf("Unexpected line filter: %+v", stages[0])
+	}
+	if stages[1].Kind != StageLineFilter || stages[1].Operator != SelectorOpNotRegex {
+		t.Errorf("Unexpected line filter: %+v", stages[1])
+	}
+	if stages[2].Kind != StageParser || stages[2].Parser != "json" {
+		t.Errorf("Unexpected parser: %+v", stages[2])
+	}
+	if stages[3].Kind != StageLabelFilter || len(stages[3].Filters) != 2 || !stages[3].Filters[0].Numeric {
+		t.Errorf("Unexpected label filter: %+v", stages[3])
+	}
+	if stages[4].Kind != StageLineFormat || stages[4].Value != "{{.msg}}" {
+		t.Errorf("Unexpected line_format: %+v", stages[4])
+	}
+
+	for _, input := range []string{
+		`metrics{metric="cpu"} | json`,
+		`logs{} |~ "("`,
+		`logs{} | regexp "no groups"`,
+		`logs{} | line_format "{{.msg"`,
+		`logs{} |= error`,
+	} {
+		if _, err := NewParser(input).Parse(); err == nil {
+			t.Errorf("Expected error for %s", input)
+		}
+	}
+}
+
 func TestParser_ParseBinaryOp(t *testing.T) {
 	input := `metrics{service="api"} [5m] + metrics{service="web"} [5m]`
 	parser := NewParser(input)
@@ -194,6 +280,228 @@ func TestParser_ParseBinaryOp(t *testing.T) {
 	}
 }
 
+func TestParser_BinaryPrecedence(t *testing.T) {
+	query, err := NewParser(`metrics{metric="a"} + metrics{metric="b"} * 2 > 10 and metrics{metric="c"} or metrics{metric="d"}`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+
+	// ((a + (b * 2)) > 10 and c) or d
+	or, ok := query.(*BinaryOp)
+	if !ok || or.Operator != BinaryOpOr {
+		t.Fatalf("Expected or at the root, got %+v", query)
+	}
+	and, ok := or.Left.(*BinaryOp)
+	if !ok || and.Operator != BinaryOpAnd {
+		t.Fatalf("Expected and below or, got %+v", or.Left)
+	}
+	gt, ok := and.Left.(*BinaryOp)
+	if !ok || gt.Operator != BinaryOpGT {
+		t.Fatalf("Expected > below and, got %+v", and.Left)
+	}
+	if threshold, ok := gt.Right.(*NumberLiteral); !ok || threshold.Value != 10 {
+		t.Errorf("Expected 10 on the right of >, got %+v", gt.Right)
+	}
+	add, ok := gt.Left.(*BinaryOp)
+	if !ok || add.Operator != BinaryOpAdd {
+		t.Fatalf("Expected + below >, got %+v", gt.Left)
+	}
+	if mul, ok := add.Right.(*BinaryOp); !ok || mul.Operator != BinaryOpMultiply {
+		t.Errorf("Expected * on the right of +, got %+v", add.Right)
+	}
+
+	// Subtraction associates to the left and numbers may be negative
+	query, err = NewParser(`metrics{metric="a"} - 1 - -2`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	outer := query.(*BinaryOp)
+	if right, ok := outer.Right.(*NumberLiteral); !ok || right.Value != -2 {
+		t.Errorf("Expected -2 on the right, got %+v", outer.Right)
+	}
+	if _, ok := outer.Left.(*BinaryOp); !ok {
+		t.Errorf("Expected a - 1 on the left, got %+v", outer.Left)
+	}
+}
+
+func TestParser_ParseVectorMatching(t *testing.T) {
+	query, err := NewParser(`metrics{metric="errors"} / on(service, code) group_left(team) metrics{metric="owners"}`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	op := query.(*BinaryOp)
+	matching := op.Matching
+	if matching == nil || !matching.On || matching.Card != MatchManyToOne ||
+		strings.Join(matching.Labels, ",") != "service,code" || strings.Join(matching.Include, ",") != "team" {
+		t.Fatalf("Unexpected matching: %+v", matching)
+	}
+
+	query, err = NewParser(`metrics{metric="a"} == ignoring(code) group_right metrics{metric="b"}`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	op = query.(*BinaryOp)
+	if op.Operator != BinaryOpEqual || op.Matching.On || op.Matching.Card != MatchOneToMany || len(op.Matching.Include) != 0 {
+		t.Errorf("Unexpected comparison: %+v %+v", op, op.Matching)
+	}
+
+	// != compares unless a string follows, which makes it a line filter
+	query, err = NewParser(`metrics{metric="a"} != 0`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	if op, ok := query.(*BinaryOp); !ok || op.Operator != BinaryOpNotEqual {
+		t.Errorf("Expected != comparison, got %+v", query)
+	}
+	query, err = NewParser(`logs{} != "debug"`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	if logs, ok := query.(*TelemetryQuery); !ok || len(logs.Pipeline) != 1 {
+		t.Errorf("Expected a line filter, got %+v", query)
+	}
+
+	if _, err := NewParser(`metrics{} and on(service) group_left metrics{}`).Parse(); err == nil {
+		t.Error("Expected group_left with a set operator to be rejected")
+	}
+}
+
+func TestParser_ParseFunctionParameters(t *testing.T) {
+	query, err := NewParser(`label_replace(metrics{metric="up"}, "host", "$1", "instance", "(.*):.*")`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	params, arg, err := functionOperands("label_replace", query.(*FunctionCall).Args)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := arg.(*TelemetryQuery); !ok || len(params) != 4 || params[1].(*StringLiteral).Value != "$1" {
+		t.Errorf("Unexpected operands: %+v %+v", params, arg)
+	}
+
+	query, err = NewParser(`topk(3, metrics{metric="cpu"}) by (service)`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	if agg, ok := query.(*Aggregation); !ok || agg.Function != "topk" || agg.Param == nil || agg.Param.Value != 3 {
+		t.Errorf("Expected topk aggregation with k=3, got %+v", query)
+	}
+
+	for _, input := range []string{
+		`topk(metrics{}, metrics{}) by (service)`,
+		`topk(1, "a", metrics{}) by (service)`,
+		`sum(1) by (service)`,
+	} {
+		if _, err := NewParser(input).Parse(); err == nil {
+			t.Errorf("Expected %s to be rejected", input)
+		}
+	}
+}
+
+func TestParser_ParseStructuralQuery(t *testing.T) {
+	input := `traces{service="frontend"} > traces{service="checkout"} >> traces{status="error", duration > 500ms}`
+	query, err := NewParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+
+	// Structural operators associate to the left
+	outer, ok := query.(*StructuralQuery)
+	if !ok || outer.Operator != StructuralDescendant {
+		t.Fatalf("Expected descendant StructuralQuery, got %+v", query)
+	}
+	inner, ok := outer.Left.(*StructuralQuery)
+	if !ok || inner.Operator != StructuralChild {
+		t.Fatalf("Expected child StructuralQuery on the left, got %+v", outer.Left)
+	}
+
+	right := outer.Right.(*TelemetryQuery)
+	duration := right.Selectors["duration"]
+	if duration == nil || duration.Operator != SelectorOpGT || duration.Value != "500000000" || !duration.Numeric {
+		t.Errorf("Expected duration in nanoseconds, got %+v", duration)
+	}
+
+	query, err = NewParser(`traces{name="GET /cart"} ~ traces{name="GET /checkout"}`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	if sibling, ok := query.(*StructuralQuery); !ok || sibling.Operator != StructuralSibling {
+		t.Errorf("Expected sibling StructuralQuery, got %+v", query)
+	}
+
+	// Greater than between metrics remains a comparison
+	query, err = NewParser(`metrics{metric="a"} > metrics{metric="b"}`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	if op, ok := query.(*BinaryOp); !ok || op.Operator != BinaryOpGT {
+		t.Errorf("Expected BinaryOp, got %+v", query)
+	}
+}
+
+func TestParser_ParseNumericSelectors(t *testing.T) {
+	input := `traces{http.status_code>=500, http.status_code<600, retries=-1} [15m]`
+	parser := NewParser(input)
+	query, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+
+	telemetryQuery := query.(*TelemetryQuery)
+	if len(telemetryQuery.Selectors) != 3 {
+		t.Fatalf("Expected 3 selectors, got %d", len(telemetryQuery.Selectors))
+	}
+
+	expected := map[SelectorOperator]string{SelectorOpGTE: "500", SelectorOpLT: "600", SelectorOpEqual: "-1"}
+	for _, selector := range telemetryQuery.Selectors {
+		value, ok := expected[selector.Operator]
+		if !ok {
+			t.Errorf("Unexpected operator %s", selector.Operator)
+			continue
+		}
+		if selector.Value != value || !selector.Numeric {
+			t.Errorf("Expected numeric value %s for %s, got %s (numeric=%v)", value, selector.Operator, selector.Value, selector.Numeric)
+		}
+	}
+}
+
+func TestExecutor_MatchesTypedSelectors(t *testing.T) {
+	visitor := &ExecutorVisitor{}
+	attributes := map[string]interface{}{
+		"http.status_code": int64(503),
+		"duration_ms":      12.5,
+		"legacy.code":      "404",
+		"cache.hit":        true,
+	}
+
+	tests := []struct {
+		input string
+		match bool
+	}{
+		{`traces{http.status_code>=500, http.status_code<600} [5m]`, true},
+		{`traces{http.status_code>503} [5m]`, false},
+		{`traces{http.status_code=503.0} [5m]`, true},
+		{`traces{duration_ms<=12.5} [5m]`, true},
+		{`traces{legacy.code<500} [5m]`, true},
+		{`traces{cache.hit="true"} [5m]`, true},
+		{`traces{cache.hit>0} [5m]`, false},
+		{`traces{missing<1} [5m]`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			query, err := NewParser(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("Failed to parse query: %v", err)
+			}
+			matched := visitor.matchesSelectors(attributes, query.(*TelemetryQuery).Selectors)
+			if matched != tt.match {
+				t.Errorf("Expected match=%v, got %v", tt.match, matched)
+			}
+		})
+	}
+}
+
 func TestParser_InvalidSyntax(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -203,6 +511,10 @@ func TestParser_InvalidSyntax(t *testing.T) {
 		{"invalid operator", "metrics{service#\"api\"} [5m]"},
 		{"unterminated string", "metrics{service=\"api} [5m]"},
 		{"unknown type", "unknown{} [5m]"},
+		{"comparison with string", "traces{code>\"500\"} [5m]"},
+		{"duplicate selector", "traces{code>1, code>2} [5m]"},
+		{"structural operator on metrics", "metrics{} >> traces{}"},
+		{"structural operand not traces", "traces{} ~ logs{}"},
 	}
 
 	for _, tt := range tests {
@@ -225,6 +537,9 @@ func TestParseDuration(t *testing.T) {
 		{"10m", 10 * time.Minute},
 		{"2h", 2 * time.Hour},
 		{"7d", 7 * 24 * time.Hour},
+		{"500ms", 500 * time.Millisecond},
+		{"250us", 250 * time.Microsecond},
+		{"100ns", 100 * time.Nanosecond},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,554 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// pipelineSampleLimit caps the raw logs read by pipelines that filter lines
+// in memory and by metric queries over logs. Metric queries fail rather
+// than count fewer lines when a scan reaches it.
+const pipelineSampleLimit = 100000
+
+// Label values set on lines a parser could not parse, as in LogQL
+const (
+	errorLabel           = "__error__"
+	jsonParserError      = "JSONParserErr"
+	logfmtParserError    = "LogfmtParserErr"
+	extractedLabelSuffix = "_extracted"
+)
+
+// pushedStages returns the number of leading line filters of a pipeline,
+// which the telemetry store evaluates as filters on the log body
+func pushedStages(pipeline []*PipelineStage) int {
+	for i, stage := range pipeline {
+		if stage.Kind != StageLineFilter {
+			return i
+		}
+	}
+	return len(pipeline)
+}
+
+// lineFilter returns the body filter of a line filter stage. Contains
+// filters match the quoted value as a regex.
+func lineFilter(stage *PipelineStage) services.Filter {
+	switch stage.Operator {
+	case SelectorOpEqual:
+		return services.Filter{Field: "body", Operator: services.FilterOpRegex, Value: regexp.QuoteMeta(stage.Value)}
+	case SelectorOpNotEqual:
+		return services.Filter{Field: "body", Operator: services.FilterOpNotRegex, Value: regexp.QuoteMeta(stage.Value)}
+	default:
+		return services.Filter{Field: "body", Operator: services.FilterOperator(stage.Operator), Value: stage.Value}
+	}
+}
+
+// filtersLines reports whether any stage may drop lines
+func filtersLines(stages []*PipelineStage) bool {
+	for _, stage := range stages {
+		if stage.Kind == StageLineFilter || stage.Kind == StageLabelFilter {
+			return true
+		}
+	}
+	return false
+}
+
+// describePipeline describes pipeline stages in query syntax
+func describePipeline(stages []*PipelineStage) string {
+	parts := make([]string, 0, len(stages))
+	for _, stage := range stages {
+		switch stage.Kind {
+		case StageLineFilter:
+			op := map[SelectorOperator]string{
+				SelectorOpEqual: "|=", SelectorOpNotEqual: "!=", SelectorOpRegex: "|~", SelectorOpNotRegex: "!~",
+			}[stage.Operator]
+			parts = append(parts, fmt.Sprintf("%s %q", op, stage.Value))
+		case StageParser:
+			if stage.Value != "" {
+				parts = append(parts, fmt.Sprintf("| %s %q", stage.Parser, stage.Value))
+			} else {
+				parts = append(parts, "| "+stage.Parser)
+			}
+		case StageLabelFilter:
+			filters := make([]string, len(stage.Filters))
+			for i, filter := range stage.Filters {
+				value := fmt.Sprintf("%q", filter.Value)
+				if filter.Numeric {
+					value = filter.Value
+				}
+				filters[i] = fmt.Sprintf("%s %s %s", filter.Label, filter.Operator, value)
+			}
+			parts = append(parts, "| "+strings.Join(filters, ", "))
+		case StageLineFormat:
+			parts = append(parts, fmt.Sprintf("| line_format %q", stage.Value))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// compiledStage is a pipeline stage with its pattern or template compiled
+type compiledStage struct {
+	*PipelineStage
+	pattern  *regexp.Regexp
+	template *template.Template
+	filters  map[string]*Selector
+}
+
+// compilePipeline compiles the patterns and templates of pipeline stages
+func compilePipeline(stages []*PipelineStage) ([]compiledStage, error) {
+	compiled := make([]compiledStage, len(stages))
+	for i, stage := range stages {
+		compiled[i] = compiledStage{PipelineStage: stage}
+		var err error
+		switch stage.Kind {
+		case StageLineFilter:
+			pattern := stage.Value
+			if stage.Operator == SelectorOpEqual || stage.Operator == SelectorOpNotEqual {
+				pattern = regexp.QuoteMeta(pattern)
+			}
+			compiled[i].pattern, err = regexp.Compile(pattern)
+		case StageParser:
+			if stage.Parser == "regexp" {
+				compiled[i].pattern, err = regexp.Compile(stage.Value)
+			}
+		case StageLabelFilter:
+			compiled[i].filters = make(map[string]*Selector, len(stage.Filters))
+			for j, filter := range stage.Filters {
+				compiled[i].filters[strconv.Itoa(j)+filter.Label] = filter
+			}
+		case StageLineFormat:
+			compiled[i].template, err = template.New("line_format").Option("missingkey=zero").Parse(stage.Value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile pipeline stage: %w", err)
+		}
+	}
+	return compiled, nil
+}
+
+// runPipeline applies pipeline stages to log results. Each result's line is
+// its body and its labels are its attributes; parsers add the fields they
+// extract as labels, with a suffix if an attribute has the same name.
+func (v *ExecutorVisitor) runPipeline(stages []*PipelineStage, results []QueryResult) ([]QueryResult, error) {
+	compiled, err := compilePipeline(stages)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := results[:0]
+	for _, r := range results {
+		line := otlp.AttributeString(r.Value)
+		labels := otlp.CloneAttributes(r.Attributes)
+		keep := true
+		for _, stage := range compiled {
+			switch stage.Kind {
+			case StageLineFilter:
+				matched := stage.pattern.MatchString(line)
+				keep = matched == (stage.Operator == SelectorOpEqual || stage.Operator == SelectorOpRegex)
+			case StageParser:
+				extractLabels(stage, line, r.Attributes, labels)
+			case StageLabelFilter:
+				keep = v.matchesSelectors(labels, stage.filters)
+			case StageLineFormat:
+				var formatted bytes.Buffer
+				if err := stage.template.Execute(&formatted, convertToStringMap(labels)); err != nil {
+					return nil, fmt.Errorf("failed to format line: %w", err)
+				}
+				line = formatted.String()
+			}
+			if !keep {
+				break
+			}
+		}
+		if !keep {
+			continue
+		}
+
+		r.Value = line
+		r.Labels = convertToStringMap(labels)
+		r.Attributes = labels
+		kept = append(kept, r)
+	}
+	return kept, nil
+}
+
+// extractLabels runs a parser stage over a line and adds the fields it
+// extracts to labels
+func extractLabels(stage compiledStage, line string, attributes, labels map[string]interface{}) {
+	fields := make(map[string]interface{})
+	switch stage.Parser {
+	case "json":
+		if err := parseJSONFields(line, fields); err != nil {
+			labels[errorLabel] = jsonParserError
+			return
+		}
+	case "logfmt":
+		if err := parseLogfmtFields(line, fields); err != nil {
+			labels[errorLabel] = logfmtParserError
+			return
+		}
+	case "regexp":
+		match := stage.pattern.FindStringSubmatch(line)
+		if match == nil {
+			return
+		}
+		for i, name := range stage.pattern.SubexpNames() {
+			if name != "" {
+				fields[name] = match[i]
+			}
+		}
+	}
+
+	for key, value := range fields {
+		if _, exists := attributes[key]; exists {
+			key += extractedLabelSuffix
+		}
+		labels[key] = value
+	}
+}
+
+// parseJSONFields extracts the fields of a JSON object line. Nested objects
+// are flattened with keys joined by '_' and arrays are skipped.
+func parseJSONFields(line string, fields map[string]interface{}) error {
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return fmt.Errorf("failed to parse JSON line: %w", err)
+	}
+	flattenJSON("", object, fields)
+	return nil
+}
+
+// flattenJSON adds the fields of a JSON object under a key prefix
+func flattenJSON(prefix string, object map[string]interface{}, fields map[string]interface{}) {
+	for key, value := range object {
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flattenJSON(key, v, fields)
+		case []interface{}:
+			continue
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				fields[key] = i
+			} else if f, err := v.Float64(); err == nil {
+				fields[key] = f
+			}
+		case nil:
+			fields[key] = ""
+		default:
+			fields[key] = v
+		}
+	}
+}
+
+// parseLogfmtFields extracts the key=value pairs of a logfmt line. Values
+// may be quoted, and keys without a value are empty.
+func parseLogfmtFields(line string, fields map[string]interface{}) error {
+	pos := 0
+	for pos < len(line) {
+		if line[pos] == ' ' || line[pos] == '\t' {
+			pos++
+			continue
+		}
+
+		start := pos
+		for pos < len(line) && line[pos] != '=' && line[pos] != ' ' && line[pos] != '\t' {
+			pos++
+		}
+		key := line[start:pos]
+		if key == "" || strings.ContainsRune(key, '"') {
+			return fmt.Errorf("invalid logfmt key at position %d", start)
+		}
+		if pos >= len(line) || line[pos] != '=' {
+			fields[key] = ""
+			continue
+		}
+		pos++ // '='
+
+		if pos < len(line) && line[pos] == '"' {
+			end := pos + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return fmt.Errorf("unterminated logfmt value at position %d", pos)
+			}
+			value, err := strconv.Unquote(line[pos : end+1])
+			if err != nil {
+				return fmt.Errorf("invalid logfmt value at position %d: %w", pos, err)
+			}
+			fields[key] = value
+			pos = end + 1
+			continue
+		}
+
+		start = pos
+		for pos < len(line) && line[pos] != ' ' && line[pos] != '\t' {
+			pos++
+		}
+		fields[key] = line[start:pos]
+	}
+	return nil
+}
+
+// overTimeLabels returns the labels a metric query over logs counts a
+// result under: its series labels, or only the by labels when grouping
+func overTimeLabels(r QueryResult, by []string) map[string]string {
+	labels := seriesLabels(r)
+	if len(by) == 0 {
+		return labels
+	}
+	grouped := make(map[string]string, len(by))
+	for _, label := range by {
+		if value, ok := labels[label]; ok {
+			grouped[label] = value
+		}
+	}
+	return grouped
+}
+
+// countOverTime counts results per label set, or per distinct combination
+// of the by labels. Each count is timestamped with its latest result.
+func countOverTime(results []QueryResult, by []string) []QueryResult {
+	counts := make(map[string]*QueryResult)
+	var keys []string
+	for _, r := range results {
+		labels := overTimeLabels(r, by)
+		key := labelsKey(labels)
+		counted, ok := counts[key]
+		if !ok {
+			counted = &QueryResult{
+				Type:   r.Type,
+				Labels: labels,
+				Value:  0.0,
+				Data:   map[string]interface{}{"function": "count_over_time"},
+			}
+			counts[key] = counted
+			keys = append(keys, key)
+		}
+		counted.Value = counted.Value.(float64) + 1
+		if r.Timestamp.After(counted.Timestamp) {
+			counted.Timestamp = r.Timestamp
+		}
+	}
+
+	sort.Strings(keys)
+	counted := make([]QueryResult, len(keys))
+	for i, key := range keys {
+		counted[i] = *counts[key]
+	}
+	return counted
+}
+
+// countOverTimeFunction returns the function counting the results of each
+// series
+func countOverTimeFunction() *Function {
+	return &Function{
+		Name:        "count_over_time",
+		Description: "Counts the log lines or samples of each series over the time range",
+		Apply: func(results []QueryResult) (interface{}, error) {
+			return countOverTime(results, nil), nil
+		},
+	}
+}
+
+// isOverTime reports whether a function call counts log lines over time:
+// count_over_time of anything, or rate of a logs selector
+func isOverTime(function string, arg Query) bool {
+	if function == "count_over_time" {
+		return true
+	}
+	q, ok := arg.(*TelemetryQuery)
+	return ok && function == "rate" && q.Type == TelemetryTypeLogs
+}
+
+// overTime evaluates count_over_time, or the per second rate of log lines,
+// per series or per distinct combination of the by labels
+func (v *ExecutorVisitor) overTime(function string, arg Query, by []string) ([]QueryResult, error) {
+	defer v.enterPlan(planExecutor, describeBy(function, by))()
+
+	q, isSelector := arg.(*TelemetryQuery)
+	var counted []QueryResult
+	if isSelector {
+		var err error
+		if counted, err = v.countSelector(q, by); err != nil {
+			return nil, err
+		}
+	} else {
+		argResults, err := arg.Accept(v)
+		if err != nil {
+			return nil, err
+		}
+		results, ok := argResults.([]QueryResult)
+		if !ok {
+			return nil, fmt.Errorf("function argument did not return query results")
+		}
+		counted = countOverTime(results, by)
+	}
+	if function != "rate" {
+		return counted, nil
+	}
+
+	startTime, endTime := v.getTimeRange(q)
+	seconds := endTime.Sub(startTime).Seconds()
+	if seconds <= 0 {
+		return nil, fmt.Errorf("rate requires a time range")
+	}
+	for i := range counted {
+		counted[i].Value = counted[i].Value.(float64) / seconds
+		counted[i].Data["function"] = "rate"
+	}
+	return counted, nil
+}
+
+// countSelector counts every result of a selector rather than the default
+// result limit. Logs the store can filter entirely are counted by the
+// store; other selectors are scanned up to pipelineSampleLimit results and
+// fail past it rather than undercount.
+func (v *ExecutorVisitor) countSelector(q *TelemetryQuery, by []string) ([]QueryResult, error) {
+	startTime, endTime := v.getTimeRange(q)
+	if q.Type == TelemetryTypeLogs && pushedStages(q.Pipeline) == len(q.Pipeline) {
+		return v.countLogs(q, by, startTime, endTime)
+	}
+
+	scan := *q
+	scan.Limit = pipelineSampleLimit
+	var results []QueryResult
+	var truncated bool
+	var err error
+	if q.Type == TelemetryTypeLogs {
+		results, truncated, err = v.scanLogs(&scan, startTime, endTime)
+	} else {
+		var scanned interface{}
+		scanned, err = v.VisitTelemetryQuery(&scan)
+		results, _ = scanned.([]QueryResult)
+		truncated = len(results) >= pipelineSampleLimit
+	}
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		return nil, sampleLimitError(pipelineSampleLimit)
+	}
+	return countOverTime(results, by), nil
+}
+
+// countLogs counts the logs of a selector in the store, per series or per
+// distinct combination of the by labels
+func (v *ExecutorVisitor) countLogs(q *TelemetryQuery, by []string, startTime, endTime time.Time) ([]QueryResult, error) {
+	logQuery, err := v.planLogQuery(q, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	logQuery.Limit = 0
+
+	v.addPlan(planStorage, fmt.Sprintf("count logs %s", describeLogQuery(logQuery)))
+	aggregates, err := v.executor.telemetryService.AggregateLogs(v.ctx, services.LogAggregateQuery{
+		Query:    logQuery,
+		Function: services.AggregateCount,
+		By:       by,
+		BySeries: len(by) == 0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count logs: %w", err)
+	}
+
+	counted := make([]QueryResult, len(aggregates))
+	for i, aggregate := range aggregates {
+		counted[i] = QueryResult{
+			Type:      TelemetryTypeLogs,
+			Timestamp: aggregate.Timestamp,
+			Labels:    aggregate.Labels,
+			Value:     aggregate.Value,
+			Data:      map[string]interface{}{"function": "count_over_time"},
+		}
+	}
+	sort.SliceStable(counted, func(i, j int) bool {
+		return labelsKey(counted[i].Labels) < labelsKey(counted[j].Labels)
+	})
+	return counted, nil
+}
+
+// sampleLimitError reports a query that matched more samples than it reads,
+// so that values computed from them would be wrong
+func sampleLimitError(limit int) error {
+	return fmt.Errorf("query matches more than %d samples, narrow the selector or the time range", limit)
+}
+
+// logsOverTime counts the log lines of each series, or of each distinct
+// combination of the by labels, within the window before every step
+func (v *RangeVisitor) logsOverTime(function string, arg Query, by []string) ([]Series, error) {
+	q, ok := arg.(*TelemetryQuery)
+	if !ok || q.Type != TelemetryTypeLogs {
+		return nil, fmt.Errorf("%s() requires a logs selector in range queries", function)
+	}
+	window := rangeWindow(q)
+	defer v.executor.enterPlan(planExecutor, fmt.Sprintf("%s per step over %s windows", describeBy(function, by), window))()
+	if len(v.steps) == 0 {
+		return nil, nil
+	}
+
+	scan := *q
+	scan.Limit = pipelineSampleLimit
+	results, truncated, err := v.executor.scanLogs(&scan, v.steps[0].Add(-window), v.steps[len(v.steps)-1])
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		return nil, sampleLimitError(pipelineSampleLimit)
+	}
+
+	byKey := make(map[string]*Series)
+	timestamps := make(map[string][]time.Time)
+	var keys []string
+	for _, r := range results {
+		labels := overTimeLabels(r, by)
+		key := labelsKey(labels)
+		if _, ok := byKey[key]; !ok {
+			byKey[key] = &Series{Type: TelemetryTypeLogs, Labels: labels}
+			keys = append(keys, key)
+		}
+		timestamps[key] = append(timestamps[key], r.Timestamp)
+	}
+
+	series := make([]Series, 0, len(keys))
+	for _, key := range keys {
+		s, lines := byKey[key], timestamps[key]
+		sort.Slice(lines, func(i, j int) bool { return lines[i].Before(lines[j]) })
+		for _, t := range v.steps {
+			first := sort.Search(len(lines), func(i int) bool { return lines[i].After(t.Add(-window)) })
+			next := sort.Search(len(lines), func(i int) bool { return lines[i].After(t) })
+			if next == first {
+				continue
+			}
+			value := float64(next - first)
+			if function == "rate" {
+				value /= window.Seconds()
+			}
+			s.Points = append(s.Points, Point{Timestamp: t, Value: value})
+		}
+		if len(s.Points) > 0 {
+			series = append(series, *s)
+		}
+	}
+	return series, nil
+}
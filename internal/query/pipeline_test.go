@@ -0,0 +1,173 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// logLines returns logs one minute apart with the given service attribute
+func logLines(start time.Time, service string, bodies ...string) []services.Log {
+	logs := make([]services.Log, len(bodies))
+	for i, body := range bodies {
+		logs[i] = services.Log{
+			Timestamp:     start.Add(time.Duration(i) * time.Minute),
+			Body:          body,
+			LogAttributes: map[string]interface{}{"service": service},
+		}
+	}
+	return logs
+}
+
+func TestExecutor_LogPipeline(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	service := &stubTelemetryService{logs: logLines(end.Add(-10*time.Minute), "api",
+		`{"status": 200, "msg": "ok", "http": {"method": "GET"}}`,
+		`{"status": 503, "msg": "upstream error", "http": {"method": "POST"}}`,
+		`{"status": 500, "msg": "handler error", "service": "checkout"}`,
+		`not json error`,
+	)}
+
+	results, meta := executeQuery(t, service, `logs{} |= "error" | json | status >= 500 | line_format "{{.http_method}} {{.msg}}"`, end.Add(-time.Hour), end)
+	if len(results) != 2 {
+		t.Fatalf("Expected the two server errors, got %+v", results)
+	}
+	if results[0].Value != "POST upstream error" || results[1].Value != " handler error" {
+		t.Errorf("Unexpected formatted lines: %q, %q", results[0].Value, results[1].Value)
+	}
+	// Extracted fields don't replace attributes of the same name
+	if results[1].Labels["service"] != "api" || results[1].Labels["service_extracted"] != "checkout" || results[1].Labels["status"] != "500" {
+		t.Errorf("Unexpected labels: %v", results[1].Labels)
+	}
+
+	// The leading line filter is evaluated by the store, which reads past
+	// the result limit for the stages that drop lines in memory
+	query := service.logQueries[0]
+	if len(query.Filters) != 1 || query.Filters[0].Field != "body" || query.Filters[0].Value != "error" || query.Limit != pipelineSampleLimit {
+		t.Errorf("Unexpected log query: %+v", query)
+	}
+	if !strings.HasPrefix(meta.Plan, `[executor] pipeline | json | status >= 500 | line_format "{{.http_method}} {{.msg}}" limit 1000`+"\n  [storage] scan logs where body =~ \"error\"") {
+		t.Errorf("Unexpected plan:\n%s", meta.Plan)
+	}
+
+	// Lines a parser fails on are labelled with the error
+	results, _ = executeQuery(t, service, `logs{} != "status" | json | __error__ != ""`, end.Add(-time.Hour), end)
+	if len(results) != 1 || results[0].Labels["__error__"] != "JSONParserErr" {
+		t.Errorf("Expected the unparsable line, got %+v", results)
+	}
+}
+
+func TestExecutor_LogPipelineParsers(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	service := &stubTelemetryService{logs: logLines(end.Add(-10*time.Minute), "api",
+		`level=info msg="request served" duration=12ms`,
+		`level=error msg="request failed" retry`,
+	)}
+
+	results, _ := executeQuery(t, service, `logs{} | logfmt | level =~ "err.*"`, end.Add(-time.Hour), end)
+	if len(results) != 1 || results[0].Labels["msg"] != "request failed" || results[0].Labels["retry"] != "" {
+		t.Errorf("Unexpected logfmt results: %+v", results)
+	}
+
+	results, _ = executeQuery(t, service, `logs{} | regexp "duration=(?P<ms>\d+)ms" | ms < 20`, end.Add(-time.Hour), end)
+	if len(results) != 1 || results[0].Labels["ms"] != "12" {
+		t.Errorf("Unexpected regexp results: %+v", results)
+	}
+}
+
+func TestExecutor_CountOverTime(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	service := &stubTelemetryService{logs: logLines(end.Add(-10*time.Minute), "api",
+		"level=info", "level=error", "level=error", "level=info", "level=error",
+	)}
+
+	results, meta := executeQuery(t, service, `count_over_time(logs{service="api"} | logfmt [10m]) by (level)`, end.Add(-10*time.Minute), end)
+	if len(results) != 2 {
+		t.Fatalf("Expected a count per level, got %+v", results)
+	}
+	if results[0].Labels["level"] != "error" || results[0].Value != 3.0 || results[1].Value != 2.0 {
+		t.Errorf("Unexpected counts: %+v", results)
+	}
+	if service.logQueries[0].Limit != pipelineSampleLimit {
+		t.Errorf("Expected every line to be counted, got limit %d", service.logQueries[0].Limit)
+	}
+	if !strings.HasPrefix(meta.Plan, "[executor] count_over_time by (level)\n  [executor] pipeline | logfmt") {
+		t.Errorf("Unexpected plan:\n%s", meta.Plan)
+	}
+
+	// Line filters alone are counted by the store
+	results, meta = executeQuery(t, service, `rate(logs{} |= "error")`, end.Add(-10*time.Minute), end)
+	if len(results) != 1 || results[0].Value != 3.0/600 || results[0].Labels["service"] != "api" {
+		t.Errorf("Unexpected rate: %+v", results)
+	}
+	if len(service.logAggregates) != 1 || !service.logAggregates[0].BySeries || service.logAggregates[0].Query.Limit != 0 {
+		t.Errorf("Expected the lines to be counted per series by the store, got %+v", service.logAggregates)
+	}
+	if !strings.Contains(meta.Plan, `[storage] count logs where body =~ "error"`) {
+		t.Errorf("Unexpected plan:\n%s", meta.Plan)
+	}
+
+	series := executeRangeQuery(t, service, `count_over_time(logs{} | logfmt [2m]) by (level)`, end.Add(-10*time.Minute), end.Add(-5*time.Minute), time.Minute)
+	if len(series) != 2 || series[0].Labels["level"] != "error" {
+		t.Fatalf("Expected a series per level, got %+v", series)
+	}
+	// Errors are logged at 12:21, 12:22 and 12:24
+	if got := pointValues(series[0].Points); len(got) != 5 || got[0] != 1 || got[1] != 2 || got[2] != 1 || got[4] != 1 {
+		t.Errorf("Unexpected error counts: %v", got)
+	}
+}
+
+func TestExecutor_CountOverTimeSampleLimit(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	bodies := make([]string, pipelineSampleLimit)
+	for i := range bodies {
+		bodies[i] = "level=info"
+	}
+	service := &stubTelemetryService{logs: logLines(end.Add(-time.Hour), "api", bodies...)}
+
+	// Lines filtered in memory can't all be read, counting fewer is an error
+	start := end.Add(-2 * time.Hour)
+	for _, input := range []string{
+		`count_over_time(logs{} | logfmt [1h])`,
+		`rate(logs{} | logfmt | level="info")`,
+	} {
+		parsed, err := NewParser(input).Parse()
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", input, err)
+		}
+		_, _, err = NewExecutor(service, zap.NewNop()).Execute(context.Background(), parsed, &ExecutionContext{
+			StartTime: &start,
+			EndTime:   &end,
+		})
+		if err == nil || !strings.Contains(err.Error(), "narrow the selector") {
+			t.Errorf("Expected %q to fail at the sample limit, got %v", input, err)
+		}
+	}
+
+	parsed, err := NewParser(`count_over_time(logs{} [1h])`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	_, _, err = NewExecutor(service, zap.NewNop()).ExecuteRange(context.Background(), parsed, &ExecutionContext{
+		StartTime: &start,
+		EndTime:   &end,
+		Step:      time.Minute,
+	})
+	if err == nil || !strings.Contains(err.Error(), "narrow the selector") {
+		t.Errorf("Expected the range query to fail at the sample limit, got %v", err)
+	}
+
+	// The store counts every line
+	results, _ := executeQuery(t, service, `count_over_time(logs{} [1h])`, start, end)
+	if len(results) != 1 || results[0].Value != float64(pipelineSampleLimit) {
+		t.Errorf("Expected every line to be counted, got %+v", results)
+	}
+}
@@ -0,0 +1,520 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+	"github.com/google/uuid"
+)
+
+// Plan step locations
+const (
+	planStorage  = "storage"
+	planExecutor = "executor"
+)
+
+// planNode is a step of a query plan. Storage steps are evaluated by the
+// telemetry store and executor steps in memory over their children's results.
+type planNode struct {
+	location string
+	step     string
+	children []*planNode
+}
+
+// String renders the plan one step per line, children indented below their
+// parent
+func (n *planNode) String() string {
+	var b strings.Builder
+	n.render(&b, 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (n *planNode) render(b *strings.Builder, depth int) {
+	fmt.Fprintf(b, "%s[%s] %s\n", strings.Repeat("  ", depth), n.location, n.step)
+	for _, child := range n.children {
+		child.render(b, depth+1)
+	}
+}
+
+// enterPlan adds a step below the current one and makes it current until
+// the returned function is called
+func (v *ExecutorVisitor) enterPlan(location, step string) func() {
+	node := &planNode{location: location, step: step}
+	parent := v.planParent
+	if parent == nil {
+		v.plan = append(v.plan, node)
+	} else {
+		parent.children = append(parent.children, node)
+	}
+	v.planParent = node
+	return func() { v.planParent = parent }
+}
+
+// addPlan adds a step without children below the current one
+func (v *ExecutorVisitor) addPlan(location, step string) {
+	v.enterPlan(location, step)()
+}
+
+// planString renders the plan of an executed query
+func (v *ExecutorVisitor) planString() string {
+	steps := make([]string, len(v.plan))
+	for i, node := range v.plan {
+		steps[i] = node.String()
+	}
+	return strings.Join(steps, "\n")
+}
+
+// sortedSelectors returns selectors ordered by label so plans and filters
+// are deterministic
+func sortedSelectors(selectors map[string]*Selector) []*Selector {
+	sorted := make([]*Selector, 0, len(selectors))
+	for _, selector := range selectors {
+		sorted = append(sorted, selector)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Label != sorted[j].Label {
+			return sorted[i].Label < sorted[j].Label
+		}
+		return sorted[i].Operator < sorted[j].Operator
+	})
+	return sorted
+}
+
+// selectorFilter converts a selector to a storage filter on the named field
+func selectorFilter(field string, selector *Selector) services.Filter {
+	return services.Filter{
+		Field:    field,
+		Operator: services.FilterOperator(selector.Operator),
+		Value:    selector.Value,
+		Numeric:  selector.Numeric,
+	}
+}
+
+// planScope compiles agent_id and group_id selectors. The execution context
+// overrides them, and selectors other than equality become filters. It
+// reports false for other labels.
+func (v *ExecutorVisitor) planScope(selector *Selector, agentID **uuid.UUID, groupID **string, filters *[]services.Filter) (bool, error) {
+	switch selector.Label {
+	case "agent_id":
+		if v.execCtx.AgentID != nil {
+			*agentID = v.execCtx.AgentID
+		} else if selector.Operator == SelectorOpEqual {
+			id, err := uuid.Parse(selector.Value)
+			if err != nil {
+				return true, fmt.Errorf("invalid agent_id: %v", err)
+			}
+			*agentID = &id
+		} else {
+			*filters = append(*filters, selectorFilter(selector.Label, selector))
+		}
+		return true, nil
+	case "group_id":
+		if v.execCtx.GroupID != nil {
+			*groupID = v.execCtx.GroupID
+		} else if selector.Operator == SelectorOpEqual {
+			value := selector.Value
+			*groupID = &value
+		} else {
+			*filters = append(*filters, selectorFilter(selector.Label, selector))
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// planMetricQuery compiles the selectors of a metrics query to a storage
+// query. Equality on indexed metadata uses the dedicated fields and every
+// other selector, including those on attributes, becomes a filter.
+func (v *ExecutorVisitor) planMetricQuery(q *TelemetryQuery, startTime, endTime time.Time) (services.MetricQuery, error) {
+	metricQuery := services.MetricQuery{
+		StartTime: startTime,
+		EndTime:   endTime,
+		Limit:     q.Limit,
+	}
+
+	for _, selector := range sortedSelectors(q.Selectors) {
+		scoped, err := v.planScope(selector, &metricQuery.AgentID, &metricQuery.GroupID, &metricQuery.Filters)
+		if err != nil {
+			return metricQuery, err
+		}
+		if scoped {
+			continue
+		}
+
+		value := selector.Value
+		var field **string
+		switch selector.Label {
+		case "metric", "name", "metric_name":
+			field = &metricQuery.MetricName
+		case "unit":
+			field = &metricQuery.Unit
+		case "scope_name":
+			field = &metricQuery.ScopeName
+		case "temporality":
+			field = &metricQuery.Temporality
+		}
+		if field != nil && selector.Operator == SelectorOpEqual {
+			*field = &value
+			continue
+		}
+		metricQuery.Filters = append(metricQuery.Filters, selectorFilter(selector.Label, selector))
+	}
+
+	return metricQuery, nil
+}
+
+// planLogQuery compiles the selectors of a logs query to a storage query.
+// Equality on body, message or search is a substring search.
+func (v *ExecutorVisitor) planLogQuery(q *TelemetryQuery, startTime, endTime time.Time) (services.LogQuery, error) {
+	logQuery := services.LogQuery{
+		StartTime: startTime,
+		EndTime:   endTime,
+		Limit:     q.Limit,
+	}
+
+	for _, selector := range sortedSelectors(q.Selectors) {
+		scoped, err := v.planScope(selector, &logQuery.AgentID, &logQuery.GroupID, &logQuery.Filters)
+		if err != nil {
+			return logQuery, err
+		}
+		if scoped {
+			continue
+		}
+
+		value := selector.Value
+		switch selector.Label {
+		case "severity", "level":
+			if selector.Operator == SelectorOpEqual {
+				logQuery.Severity = &value
+				continue
+			}
+		case "body", "message", "search":
+			if selector.Operator == SelectorOpEqual {
+				logQuery.Search = &value
+				continue
+			}
+			logQuery.Filters = append(logQuery.Filters, selectorFilter("body", selector))
+			continue
+		}
+		logQuery.Filters = append(logQuery.Filters, selectorFilter(selector.Label, selector))
+	}
+
+	// Leading line filters of the pipeline become body filters
+	for _, stage := range q.Pipeline[:pushedStages(q.Pipeline)] {
+		logQuery.Filters = append(logQuery.Filters, lineFilter(stage))
+	}
+
+	return logQuery, nil
+}
+
+// describeMetricQuery describes the conditions of a storage metrics query
+func describeMetricQuery(q services.MetricQuery) string {
+	var conditions []string
+	conditions = appendCondition(conditions, "agent_id", uuidString(q.AgentID))
+	conditions = appendCondition(conditions, "group_id", q.GroupID)
+	conditions = appendCondition(conditions, "metric_name", q.MetricName)
+	conditions = appendCondition(conditions, "unit", q.Unit)
+	conditions = appendCondition(conditions, "scope_name", q.ScopeName)
+	conditions = appendCondition(conditions, "temporality", q.Temporality)
+	return describeConditions(conditions, q.Filters, q.StartTime, q.EndTime)
+}
+
+// describeLogQuery describes the conditions of a storage logs query
+func describeLogQuery(q services.LogQuery) string {
+	var conditions []string
+	conditions = appendCondition(conditions, "agent_id", uuidString(q.AgentID))
+	conditions = appendCondition(conditions, "group_id", q.GroupID)
+	conditions = appendCondition(conditions, "severity", q.Severity)
+	if q.Search != nil {
+		conditions = append(conditions, fmt.Sprintf("body contains %q", *q.Search))
+	}
+	return describeConditions(conditions, q.Filters, q.StartTime, q.EndTime)
+}
+
+func appendCondition(conditions []string, field string, value *string) []string {
+	if value == nil {
+		return conditions
+	}
+	return append(conditions, fmt.Sprintf("%s = %q", field, *value))
+}
+
+func uuidString(id *uuid.UUID) *string {
+	if id == nil {
+		return nil
+	}
+	value := id.String()
+	return &value
+}
+
+func describeConditions(conditions []string, filters []services.Filter, start, end time.Time) string {
+	for _, filter := range filters {
+		value := fmt.Sprintf("%q", filter.Value)
+		if filter.Numeric {
+			value = filter.Value
+		}
+		conditions = append(conditions, fmt.Sprintf("%s %s %s", filter.Field, filter.Operator, value))
+	}
+	conditions = append(conditions, fmt.Sprintf("time %s..%s", start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339)))
+	return "where " + strings.Join(conditions, " and ")
+}
+
+// planTraceSearch compiles a traces query or a structural query over traces
+// queries to a storage trace search. The search covers the widest time
+// range of its traces queries and Limit counts traces.
+func (v *ExecutorVisitor) planTraceSearch(q Query, limit int) (services.TraceSearchQuery, error) {
+	search := services.TraceSearchQuery{
+		AgentID: v.execCtx.AgentID,
+		GroupID: v.execCtx.GroupID,
+		Limit:   limit,
+	}
+	spans, err := v.planSpanSet(q, &search)
+	if err != nil {
+		return search, err
+	}
+	search.Spans = spans
+	return search, nil
+}
+
+// planSpanSet compiles the selectors of traces queries to span sets. Scope
+// selectors apply to the spans of their own query, so agent_id and
+// group_id become filters.
+func (v *ExecutorVisitor) planSpanSet(q Query, search *services.TraceSearchQuery) (services.SpanSet, error) {
+	if sq, ok := q.(*StructuralQuery); ok {
+		left, err := v.planSpanSet(sq.Left, search)
+		if err != nil {
+			return services.SpanSet{}, err
+		}
+		right, err := v.planSpanSet(sq.Right, search)
+		if err != nil {
+			return services.SpanSet{}, err
+		}
+		return services.SpanSet{Operator: services.StructuralOperator(sq.Operator), Left: &left, Right: &right}, nil
+	}
+	tq, ok := q.(*TelemetryQuery)
+	if !ok || tq.Type != TelemetryTypeTraces {
+		return services.SpanSet{}, fmt.Errorf("structural operators require traces queries")
+	}
+
+	startTime, endTime := v.getTimeRange(tq)
+	if search.StartTime.IsZero() || startTime.Before(search.StartTime) {
+		search.StartTime = startTime
+	}
+	if endTime.After(search.EndTime) {
+		search.EndTime = endTime
+	}
+
+	var set services.SpanSet
+	for _, selector := range sortedSelectors(tq.Selectors) {
+		var agentID *uuid.UUID
+		var groupID *string
+		scoped, err := v.planScope(selector, &agentID, &groupID, &set.Filters)
+		if err != nil {
+			return set, err
+		}
+		if scoped {
+			if agentID != nil {
+				set.Filters = append(set.Filters, services.Filter{Field: "agent_id", Operator: services.FilterOpEqual, Value: agentID.String()})
+			}
+			if groupID != nil {
+				set.Filters = append(set.Filters, services.Filter{Field: "group_id", Operator: services.FilterOpEqual, Value: *groupID})
+			}
+			continue
+		}
+		set.Filters = append(set.Filters, spanFilter(selector))
+	}
+	return set, nil
+}
+
+// spanFilter converts a selector of a traces query to a storage filter.
+// Status equality accepts ok, error and unset for the OpenTelemetry status
+// codes.
+func spanFilter(selector *Selector) services.Filter {
+	filter := selectorFilter(selector.Label, selector)
+	switch selector.Label {
+	case "status", "status_code":
+		if selector.Operator == SelectorOpEqual || selector.Operator == SelectorOpNotEqual {
+			switch status := strings.ToUpper(selector.Value); status {
+			case "OK", "ERROR", "UNSET":
+				filter.Value = "STATUS_CODE_" + status
+			}
+		}
+	}
+	return filter
+}
+
+// describeSpanSet describes the conditions of a span set
+func describeSpanSet(set services.SpanSet) string {
+	if set.Operator == "" {
+		var conditions []string
+		for _, filter := range set.Filters {
+			value := fmt.Sprintf("%q", filter.Value)
+			if filter.Numeric {
+				value = filter.Value
+			}
+			conditions = append(conditions, fmt.Sprintf("%s %s %s", filter.Field, filter.Operator, value))
+		}
+		return "{" + strings.Join(conditions, ", ") + "}"
+	}
+	return fmt.Sprintf("(%s %s %s)", describeSpanSet(*set.Left), set.Operator, describeSpanSet(*set.Right))
+}
+
+// describeTraceSearch describes the conditions of a storage trace search
+func describeTraceSearch(q services.TraceSearchQuery) string {
+	conditions := []string{"spans " + describeSpanSet(q.Spans)}
+	conditions = appendCondition(conditions, "agent_id", uuidString(q.AgentID))
+	conditions = appendCondition(conditions, "group_id", q.GroupID)
+	return describeConditions(conditions, nil, q.StartTime, q.EndTime)
+}
+
+// describeLimit describes the row limit of a storage query
+func describeLimit(limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" limit %d", limit)
+}
+
+// describeBy describes the grouping of an aggregation
+func describeBy(function string, by []string) string {
+	if len(by) == 0 {
+		return function
+	}
+	return fmt.Sprintf("%s by (%s)", function, strings.Join(by, ", "))
+}
+
+// storeAggregates are the aggregations the telemetry store can evaluate
+var storeAggregates = map[string]bool{"sum": true, "avg": true, "min": true, "max": true, "count": true}
+
+// pushDownAggregation evaluates an aggregation of a plain selector in the
+// telemetry store. Metrics support every store aggregate and logs only
+// count. It reports false when the executor has to evaluate it instead,
+// including for aggregations answered from rollups.
+func (v *ExecutorVisitor) pushDownAggregation(query Query, function string, by []string) ([]QueryResult, bool, error) {
+	q, ok := query.(*TelemetryQuery)
+	if !ok || !storeAggregates[function] {
+		return nil, false, nil
+	}
+	startTime, endTime := v.getTimeRange(q)
+	step := describeBy(function, by)
+
+	var aggregates []services.Aggregate
+	switch q.Type {
+	case TelemetryTypeMetrics:
+		if _, ok := rollupOperand(function, by, q); ok {
+			if _, ok := v.selectRollupTier(q, startTime, endTime); ok {
+				return nil, false, nil
+			}
+		}
+		metricQuery, err := v.planMetricQuery(q, startTime, endTime)
+		if err != nil {
+			return nil, true, err
+		}
+		v.addPlan(planStorage, fmt.Sprintf("aggregate %s over metrics %s%s", step, describeMetricQuery(metricQuery), describeLimit(metricQuery.Limit)))
+		aggregates, err = v.executor.telemetryService.AggregateMetrics(v.ctx, services.MetricAggregateQuery{
+			Query:    metricQuery,
+			Function: services.AggregateFunction(function),
+			By:       by,
+		})
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to aggregate metrics: %w", err)
+		}
+	case TelemetryTypeLogs:
+		if function != "count" || pushedStages(q.Pipeline) < len(q.Pipeline) {
+			return nil, false, nil
+		}
+		logQuery, err := v.planLogQuery(q, startTime, endTime)
+		if err != nil {
+			return nil, true, err
+		}
+		v.addPlan(planStorage, fmt.Sprintf("aggregate %s over logs %s%s", step, describeLogQuery(logQuery), describeLimit(logQuery.Limit)))
+		aggregates, err = v.executor.telemetryService.AggregateLogs(v.ctx, services.LogAggregateQuery{
+			Query:    logQuery,
+			Function: services.AggregateFunction(function),
+			By:       by,
+		})
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to aggregate logs: %w", err)
+		}
+	default:
+		return nil, false, nil
+	}
+
+	// Results have the shape of the executor's aggregate functions
+	results := make([]QueryResult, len(aggregates))
+	for i, aggregate := range aggregates {
+		data := map[string]interface{}{"function": function}
+		if function == "sum" || function == "avg" {
+			data["count"] = int(aggregate.Count)
+		}
+		results[i] = QueryResult{
+			Type:      q.Type,
+			Timestamp: aggregate.Timestamp,
+			Labels:    aggregate.Labels,
+			Value:     aggregate.Value,
+			Data:      data,
+		}
+	}
+	return results, true, nil
+}
+
+// pushDownFunction evaluates a function of a plain selector in the telemetry
+// store. Aggregate functions are aggregations without grouping, and rate and
+// increase are calculated from point statistics the store summarizes.
+func (v *ExecutorVisitor) pushDownFunction(f *FunctionCall) ([]QueryResult, bool, error) {
+	if len(f.Args) != 1 {
+		return nil, false, nil
+	}
+	if storeAggregates[f.Name] {
+		return v.pushDownAggregation(f.Args[0], f.Name, nil)
+	}
+	if f.Name != "rate" && f.Name != "increase" {
+		return nil, false, nil
+	}
+
+	q, ok := f.Args[0].(*TelemetryQuery)
+	if !ok || q.Type != TelemetryTypeMetrics {
+		return nil, false, nil
+	}
+	// Rollups average away the temporality and series of counters, so rate
+	// and increase always summarize raw points, however long the range
+	startTime, endTime := v.getTimeRange(q)
+	metricQuery, err := v.planMetricQuery(q, startTime, endTime)
+	if err != nil {
+		return nil, true, err
+	}
+
+	defer v.enterPlan(planExecutor, f.Name)()
+	v.addPlan(planStorage, "summarize metrics "+describeMetricQuery(metricQuery))
+	summaries, err := v.executor.telemetryService.QueryMetricIncrease(v.ctx, metricQuery)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to query metric increase: %w", err)
+	}
+
+	for i := range summaries {
+		summaries[i].Labels = increaseLabels(summaries[i])
+	}
+	results, err := increasesResults(f.Name, TelemetryTypeMetrics, summaries)
+	return results, true, err
+}
+
+// increaseLabels returns the labels of a series summarized by the store, the
+// way seriesLabels labels the series of raw points
+func increaseLabels(stats services.MetricIncrease) map[string]string {
+	labels := make(map[string]string, len(stats.Labels)+2)
+	for key, value := range stats.Labels {
+		labels[key] = value
+	}
+	if stats.MetricName != "" {
+		labels["name"] = stats.MetricName
+	}
+	if stats.AgentID != uuid.Nil {
+		labels["agent_id"] = stats.AgentID.String()
+	}
+	return labels
+}
@@ -0,0 +1,713 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defaultLookback is how far back a range query looks for the latest sample
+// of a series, and the window of rate and increase without an explicit range
+const defaultLookback = 5 * time.Minute
+
+// maxRangeSteps caps the number of evaluation timestamps of a range query
+const maxRangeSteps = 11000
+
+// rangeSampleLimit caps the raw samples a range query reads for a selector.
+// Selectors matching more fail rather than lose the points of some series.
+const rangeSampleLimit = 1000000
+
+// ExecuteRange evaluates a Lawrence QL query at every step between the start
+// and end of the execution context, returning one series per label set.
+// Evaluation timestamps are multiples of the step. Selectors take the latest
+// sample of each series within their [range], five minutes by default.
+func (e *Executor) ExecuteRange(ctx context.Context, query Query, execCtx *ExecutionContext) ([]Series, *QueryMeta, error) {
+	startTime := time.Now()
+	if execCtx.Step <= 0 {
+		return nil, nil, fmt.Errorf("range queries require a positive step")
+	}
+
+	end := startTime
+	if execCtx.EndTime != nil {
+		end = *execCtx.EndTime
+	}
+	start := end.Add(-time.Hour)
+	if execCtx.StartTime != nil {
+		start = *execCtx.StartTime
+	}
+	if _, err := stepTimestamps(start, end, execCtx.Step); err != nil {
+		return nil, nil, err
+	}
+
+	executor := &ExecutorVisitor{executor: e, ctx: ctx, execCtx: execCtx}
+	var series []Series
+	var cachedChunks int
+	var err error
+	if e.cache != nil {
+		series, cachedChunks, err = e.cache.executeRange(executor, query, start, end)
+	} else {
+		series, err = executor.evaluateRange(query, start, end)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(series, func(i, j int) bool { return labelsKey(series[i].Labels) < labelsKey(series[j].Labels) })
+
+	meta := &QueryMeta{
+		ExecutionTime: time.Since(startTime),
+		RowCount:      len(series),
+		QueryType:     fmt.Sprintf("%T", query),
+		Plan:          executor.planString(),
+		CachedChunks:  cachedChunks,
+	}
+
+	return series, meta, nil
+}
+
+// evaluateRange evaluates a query at the steps between start and end
+func (v *ExecutorVisitor) evaluateRange(query Query, start, end time.Time) ([]Series, error) {
+	steps, err := stepTimestamps(start, end, v.execCtx.Step)
+	if err != nil {
+		return nil, err
+	}
+
+	visitor := &RangeVisitor{executor: v, steps: steps, sampleLimit: rangeSampleLimit}
+	leave := v.enterPlan(planExecutor, fmt.Sprintf("evaluate every %s at %d steps", v.execCtx.Step, len(steps)))
+	results, err := query.Accept(visitor)
+	leave()
+	if err != nil {
+		return nil, err
+	}
+
+	series, ok := results.([]Series)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type from range query execution")
+	}
+	return series, nil
+}
+
+// stepTimestamps returns the multiples of step between start and end
+func stepTimestamps(start, end time.Time, step time.Duration) ([]time.Time, error) {
+	if end.Before(start) {
+		return nil, fmt.Errorf("range query end is before its start")
+	}
+	first := (start.UnixNano() + int64(step) - 1) / int64(step) * int64(step)
+	count := (end.UnixNano()-first)/int64(step) + 1
+	if count > maxRangeSteps {
+		return nil, fmt.Errorf("range query has %d steps, more than the maximum of %d", count, maxRangeSteps)
+	}
+
+	steps := make([]time.Time, 0, count)
+	for t := first; t <= end.UnixNano(); t += int64(step) {
+		steps = append(steps, time.Unix(0, t).UTC())
+	}
+	return steps, nil
+}
+
+// RangeVisitor implements QueryVisitor to evaluate range queries. Every
+// node evaluates to series with a point at the steps where it has a value.
+type RangeVisitor struct {
+	executor *ExecutorVisitor
+	steps    []time.Time
+	// sampleLimit caps the raw samples read for a selector
+	sampleLimit int
+}
+
+// sampleSeries holds the raw samples of one label set, oldest first
+type sampleSeries struct {
+	labels  map[string]string
+	samples []QueryResult
+}
+
+// VisitTelemetryQuery evaluates a selector to the latest sample of each
+// series within the lookback window of every step
+func (v *RangeVisitor) VisitTelemetryQuery(q *TelemetryQuery) (interface{}, error) {
+	lookback := rangeWindow(q)
+	defer v.executor.enterPlan(planExecutor, fmt.Sprintf("latest sample per series within %s", lookback))()
+
+	sampled, err := v.fetchSeries(q, lookback)
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]Series, 0, len(sampled))
+	for _, s := range sampled {
+		var points []Point
+		next := 0
+		for _, t := range v.steps {
+			for next < len(s.samples) && !s.samples[next].Timestamp.After(t) {
+				next++
+			}
+			if next == 0 {
+				continue
+			}
+			latest := s.samples[next-1]
+			if !latest.Timestamp.After(t.Add(-lookback)) {
+				continue
+			}
+			value, err := toFloat64(latest.Value)
+			if err != nil {
+				continue
+			}
+			points = append(points, Point{Timestamp: t, Value: value})
+		}
+		if len(points) > 0 {
+			series = append(series, Series{Type: q.Type, Labels: s.labels, Points: points})
+		}
+	}
+	return series, nil
+}
+
+// VisitBinaryOp applies a binary operation at every step to the points of
+// the series of both operands, matching them like instant queries
+func (v *RangeVisitor) VisitBinaryOp(b *BinaryOp) (interface{}, error) {
+	defer v.executor.enterPlan(planExecutor, describeBinaryOp(b.Operator, b.Matching))()
+
+	leftScalar, leftIsScalar := scalarValue(b.Left)
+	rightScalar, rightIsScalar := scalarValue(b.Right)
+	if leftIsScalar && rightIsScalar {
+		return nil, fmt.Errorf("binary operation %s requires at least one query operand", b.Operator)
+	}
+
+	var left, right []Series
+	var err error
+	if !leftIsScalar {
+		if left, err = v.evaluate(b.Left); err != nil {
+			return nil, err
+		}
+	}
+	if !rightIsScalar {
+		if right, err = v.evaluate(b.Right); err != nil {
+			return nil, err
+		}
+	}
+
+	return v.stepwise([][]Series{left, right}, func(vectors [][]QueryResult) ([]QueryResult, error) {
+		switch {
+		case leftIsScalar:
+			return binaryScalar(b.Operator, vectors[1], leftScalar, true)
+		case rightIsScalar:
+			return binaryScalar(b.Operator, vectors[0], rightScalar, false)
+		}
+		return binaryVectors(b.Operator, b.Matching, vectors[0], vectors[1])
+	})
+}
+
+// VisitFunctionCall evaluates rate, increase and the other window functions
+// per series over the window before every step, histogram_quantile over
+// the same window across all series, and aggregate and vector functions
+// across all series at every step
+func (v *RangeVisitor) VisitFunctionCall(f *FunctionCall) (interface{}, error) {
+	params, arg, err := functionOperands(f.Name, f.Args)
+	if err != nil {
+		return nil, err
+	}
+	function, err := resolveFunction(f.Name, params, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	if isOverTime(f.Name, arg) {
+		return v.logsOverTime(f.Name, arg, nil)
+	}
+	switch {
+	case f.Name == "rate" || f.Name == "increase":
+		q, ok := arg.(*TelemetryQuery)
+		if !ok {
+			return nil, fmt.Errorf("%s() requires a selector in range queries", f.Name)
+		}
+		return v.seriesIncrease(f.Name, q)
+	case f.Name == "histogram_quantile":
+		return v.seriesQuantile(params, arg, nil)
+	case windowFunctions[f.Name]:
+		q, ok := arg.(*TelemetryQuery)
+		if !ok {
+			return nil, fmt.Errorf("%s() requires a selector in range queries", f.Name)
+		}
+		return v.seriesFunction(function, q)
+	case vectorFunctions[f.Name]:
+		defer v.executor.enterPlan(planExecutor, f.Name+" per step")()
+		series, err := v.evaluate(arg)
+		if err != nil {
+			return nil, err
+		}
+		return v.stepwise([][]Series{series}, func(vectors [][]QueryResult) ([]QueryResult, error) {
+			return appliedResults(function, vectors[0])
+		})
+	}
+
+	if !storeAggregates[f.Name] {
+		return nil, fmt.Errorf("function %s is not supported in range queries", f.Name)
+	}
+	defer v.executor.enterPlan(planExecutor, f.Name+" per step")()
+	series, err := v.evaluate(arg)
+	if err != nil {
+		return nil, err
+	}
+	return v.aggregate(f.Name, nil, series), nil
+}
+
+// VisitAggregation aggregates the series of the inner query at every step,
+// one series per distinct combination of the by labels
+func (v *RangeVisitor) VisitAggregation(a *Aggregation) (interface{}, error) {
+	params := aggregationParams(a)
+	function, err := resolveFunction(a.Function, params, a.Query)
+	if err != nil {
+		return nil, err
+	}
+	if a.Function == "histogram_quantile" {
+		return v.seriesQuantile(params, a.Query, a.By)
+	}
+	if isOverTime(a.Function, a.Query) {
+		return v.logsOverTime(a.Function, a.Query, a.By)
+	}
+	if vectorFunctions[a.Function] {
+		defer v.executor.enterPlan(planExecutor, describeBy(a.Function, a.By)+" per step")()
+		series, err := v.evaluate(a.Query)
+		if err != nil {
+			return nil, err
+		}
+		return v.stepwise([][]Series{series}, func(vectors [][]QueryResult) ([]QueryResult, error) {
+			if len(a.By) > 0 {
+				return v.executor.groupAndAggregate(vectors[0], a.By, function)
+			}
+			return appliedResults(function, vectors[0])
+		})
+	}
+	if !storeAggregates[a.Function] {
+		return nil, fmt.Errorf("aggregation %s is not supported in range queries", a.Function)
+	}
+	defer v.executor.enterPlan(planExecutor, describeBy(a.Function, a.By)+" per step")()
+
+	series, err := v.evaluate(a.Query)
+	if err != nil {
+		return nil, err
+	}
+	return v.aggregate(a.Function, a.By, series), nil
+}
+
+// VisitNumberLiteral rejects numbers outside of function arguments and
+// binary operations
+func (v *RangeVisitor) VisitNumberLiteral(n *NumberLiteral) (interface{}, error) {
+	return nil, fmt.Errorf("number %v can only be used as a function argument or operand", n.Value)
+}
+
+// VisitStringLiteral rejects strings outside of function arguments
+func (v *RangeVisitor) VisitStringLiteral(s *StringLiteral) (interface{}, error) {
+	return nil, fmt.Errorf("string %q can only be used as a function argument", s.Value)
+}
+
+// VisitStructuralQuery rejects structural queries, which select traces
+// rather than series
+func (v *RangeVisitor) VisitStructuralQuery(s *StructuralQuery) (interface{}, error) {
+	return nil, fmt.Errorf("structural trace queries are not supported in range queries")
+}
+
+// evaluate evaluates a query node to series
+func (v *RangeVisitor) evaluate(q Query) ([]Series, error) {
+	results, err := q.Accept(v)
+	if err != nil {
+		return nil, err
+	}
+	series, ok := results.([]Series)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type from range query execution")
+	}
+	return series, nil
+}
+
+// seriesIncrease calculates rate or increase of each series over the window
+// before every step. Counter resets within a window are handled like in
+// instant queries, and steps with too few samples have no point.
+func (v *RangeVisitor) seriesIncrease(fn string, q *TelemetryQuery) ([]Series, error) {
+	return v.seriesWindows(fn, q, func(windowed []QueryResult) (float64, bool) {
+		increase, err := seriesIncrease(fn, windowed)
+		if err != nil {
+			return 0, false
+		}
+		results, err := increaseResults(fn, q.Type, nil, increase)
+		if err != nil {
+			return 0, false
+		}
+		return results[0].Value.(float64), true
+	})
+}
+
+// seriesFunction evaluates a window function such as avg_over_time per
+// series over the window before every step. Like in instant queries the
+// results drop the metric name.
+func (v *RangeVisitor) seriesFunction(function *Function, q *TelemetryQuery) ([]Series, error) {
+	series, err := v.seriesWindows(function.Name, q, func(windowed []QueryResult) (float64, bool) {
+		applied, err := appliedResults(function, windowed)
+		if err != nil || len(applied) == 0 {
+			return 0, false
+		}
+		value, err := toFloat64(applied[0].Value)
+		return value, err == nil
+	})
+	for i := range series {
+		series[i].Labels = withoutLabels(series[i].Labels, "name")
+	}
+	return series, err
+}
+
+// seriesWindows evaluates a function of the samples of each series within
+// the window before every step. Steps without samples, or that the function
+// has no value for, have no point.
+func (v *RangeVisitor) seriesWindows(fn string, q *TelemetryQuery, evaluate func(windowed []QueryResult) (float64, bool)) ([]Series, error) {
+	window := rangeWindow(q)
+	defer v.executor.enterPlan(planExecutor, fmt.Sprintf("%s per series over %s windows", fn, window))()
+
+	sampled, err := v.fetchSeries(q, window)
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]Series, 0, len(sampled))
+	for _, s := range sampled {
+		var points []Point
+		first, next := 0, 0
+		for _, t := range v.steps {
+			for next < len(s.samples) && !s.samples[next].Timestamp.After(t) {
+				next++
+			}
+			for first < next && !s.samples[first].Timestamp.After(t.Add(-window)) {
+				first++
+			}
+			windowed := s.samples[first:next]
+			if len(windowed) == 0 {
+				continue
+			}
+
+			value, ok := evaluate(windowed)
+			if !ok {
+				continue
+			}
+			points = append(points, Point{Timestamp: t, Value: value})
+		}
+		if len(points) > 0 {
+			series = append(series, Series{Type: q.Type, Labels: s.labels, Points: points})
+		}
+	}
+	return series, nil
+}
+
+// stepwise evaluates a function of instant vectors at every step, one
+// vector per input holding the points of its series at the step, and
+// collects the results into series
+func (v *RangeVisitor) stepwise(inputs [][]Series, apply func(vectors [][]QueryResult) ([]QueryResult, error)) ([]Series, error) {
+	indexed := make([][]map[int64]float64, len(inputs))
+	for i, input := range inputs {
+		indexed[i] = make([]map[int64]float64, len(input))
+		for j, s := range input {
+			indexed[i][j] = make(map[int64]float64, len(s.Points))
+			for _, p := range s.Points {
+				indexed[i][j][p.Timestamp.UnixNano()] = p.Value
+			}
+		}
+	}
+
+	byKey := make(map[string]*Series)
+	var order []string
+	for _, t := range v.steps {
+		vectors := make([][]QueryResult, len(inputs))
+		for i, input := range inputs {
+			for j, s := range input {
+				if value, ok := indexed[i][j][t.UnixNano()]; ok {
+					vectors[i] = append(vectors[i], QueryResult{Type: s.Type, Timestamp: t, Labels: s.Labels, Value: value})
+				}
+			}
+		}
+
+		results, err := apply(vectors)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			value, err := toFloat64(r.Value)
+			if err != nil {
+				continue
+			}
+			key := labelsKey(r.Labels)
+			s, ok := byKey[key]
+			if !ok {
+				s = &Series{Type: r.Type, Labels: r.Labels}
+				byKey[key] = s
+				order = append(order, key)
+			}
+			if n := len(s.Points); n > 0 && s.Points[n-1].Timestamp.Equal(t) {
+				return nil, fmt.Errorf("evaluation produced duplicate series {%s}", key)
+			}
+			s.Points = append(s.Points, Point{Timestamp: t, Value: value})
+		}
+	}
+
+	series := make([]Series, len(order))
+	for i, key := range order {
+		series[i] = *byKey[key]
+	}
+	return series, nil
+}
+
+// appliedResults applies a function and returns its results
+func appliedResults(function *Function, results []QueryResult) ([]QueryResult, error) {
+	applied, err := function.Apply(results)
+	if err != nil {
+		return nil, err
+	}
+	queryResults, ok := applied.([]QueryResult)
+	if !ok {
+		return nil, fmt.Errorf("function %s did not return query results", function.Name)
+	}
+	return queryResults, nil
+}
+
+// seriesQuantile estimates a quantile at every step from the samples within
+// the window before it. Histogram buckets are merged across the series of
+// each distinct combination of the by labels.
+func (v *RangeVisitor) seriesQuantile(params []Query, arg Query, by []string) ([]Series, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("histogram_quantile requires a quantile in range queries")
+	}
+	function, err := resolveFunction("histogram_quantile", params, arg)
+	if err != nil {
+		return nil, err
+	}
+	q, ok := arg.(*TelemetryQuery)
+	if !ok {
+		return nil, fmt.Errorf("histogram_quantile() requires a selector in range queries")
+	}
+	window := rangeWindow(q)
+	defer v.executor.enterPlan(planExecutor, fmt.Sprintf("%s per step over %s windows", describeBy("histogram_quantile", by), window))()
+
+	sampled, err := v.fetchSeries(q, window)
+	if err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		labels map[string]string
+		series []sampleSeries
+	}
+	groups := make(map[string]*group)
+	var order []string
+	for _, s := range sampled {
+		labels := make(map[string]string, len(by))
+		for _, label := range by {
+			if value, ok := s.labels[label]; ok {
+				labels[label] = value
+			}
+		}
+		key := labelsKey(labels)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{labels: labels}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.series = append(g.series, s)
+	}
+
+	series := make([]Series, 0, len(groups))
+	for _, key := range order {
+		g := groups[key]
+		var points []Point
+		for _, t := range v.steps {
+			var windowed []QueryResult
+			for _, s := range g.series {
+				first := sort.Search(len(s.samples), func(i int) bool { return s.samples[i].Timestamp.After(t.Add(-window)) })
+				next := sort.Search(len(s.samples), func(i int) bool { return s.samples[i].Timestamp.After(t) })
+				windowed = append(windowed, s.samples[first:next]...)
+			}
+			if len(windowed) == 0 {
+				continue
+			}
+
+			applied, err := function.Apply(windowed)
+			if err != nil {
+				return nil, err
+			}
+			results, _ := applied.([]QueryResult)
+			if len(results) == 0 {
+				continue
+			}
+			points = append(points, Point{Timestamp: t, Value: results[0].Value.(float64)})
+		}
+		if len(points) > 0 {
+			series = append(series, Series{Type: q.Type, Labels: g.labels, Points: points})
+		}
+	}
+	return series, nil
+}
+
+// fetchSeries reads the raw samples of a selector from the window before
+// the first step to the last step and splits them into series. Selectors
+// matching sampleLimit samples or more fail.
+func (v *RangeVisitor) fetchSeries(q *TelemetryQuery, window time.Duration) ([]sampleSeries, error) {
+	if q.Type != TelemetryTypeMetrics {
+		return nil, fmt.Errorf("range queries only support metrics, not %s", q.Type)
+	}
+	if len(v.steps) == 0 {
+		return nil, nil
+	}
+
+	metricQuery, err := v.executor.planMetricQuery(q, v.steps[0].Add(-window), v.steps[len(v.steps)-1])
+	if err != nil {
+		return nil, err
+	}
+	metricQuery.Limit = v.sampleLimit
+	results, err := v.executor.executeRawMetricsQuery(metricQuery)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) >= v.sampleLimit {
+		return nil, sampleLimitError(v.sampleLimit)
+	}
+
+	byKey := make(map[string]*sampleSeries)
+	var sampled []*sampleSeries
+	for _, r := range results {
+		labels := seriesLabels(r)
+		key := labelsKey(labels)
+		s, ok := byKey[key]
+		if !ok {
+			s = &sampleSeries{labels: labels}
+			byKey[key] = s
+			sampled = append(sampled, s)
+		}
+		s.samples = append(s.samples, r)
+	}
+
+	series := make([]sampleSeries, len(sampled))
+	for i, s := range sampled {
+		sort.SliceStable(s.samples, func(a, b int) bool {
+			return s.samples[a].Timestamp.Before(s.samples[b].Timestamp)
+		})
+		series[i] = *s
+	}
+	return series, nil
+}
+
+// aggregate applies an aggregate function at every step to the points of
+// the series grouped by the by labels
+func (v *RangeVisitor) aggregate(function string, by []string, series []Series) []Series {
+	type group struct {
+		labels map[string]string
+		values map[int64][]float64
+	}
+	groups := make(map[string]*group)
+	var order []string
+	var seriesType TelemetryType
+	for _, s := range series {
+		seriesType = s.Type
+		labels := make(map[string]string, len(by))
+		for _, label := range by {
+			if value, ok := s.Labels[label]; ok {
+				labels[label] = value
+			}
+		}
+		key := labelsKey(labels)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{labels: labels, values: make(map[int64][]float64)}
+			groups[key] = g
+			order = append(order, key)
+		}
+		for _, p := range s.Points {
+			g.values[p.Timestamp.UnixNano()] = append(g.values[p.Timestamp.UnixNano()], p.Value)
+		}
+	}
+
+	aggregated := make([]Series, 0, len(groups))
+	for _, key := range order {
+		g := groups[key]
+		var points []Point
+		for _, t := range v.steps {
+			values := g.values[t.UnixNano()]
+			if len(values) == 0 {
+				continue
+			}
+			points = append(points, Point{Timestamp: t, Value: aggregateValues(function, values)})
+		}
+		if len(points) > 0 {
+			aggregated = append(aggregated, Series{Type: seriesType, Labels: g.labels, Points: points})
+		}
+	}
+	return aggregated
+}
+
+// aggregateValues applies an aggregate function to the values at one step
+func aggregateValues(function string, values []float64) float64 {
+	result := values[0]
+	switch function {
+	case "sum", "avg":
+		for _, value := range values[1:] {
+			result += value
+		}
+		if function == "avg" {
+			result /= float64(len(values))
+		}
+	case "min":
+		for _, value := range values[1:] {
+			if value < result {
+				result = value
+			}
+		}
+	case "max":
+		for _, value := range values[1:] {
+			if value > result {
+				result = value
+			}
+		}
+	case "count":
+		result = float64(len(values))
+	}
+	return result
+}
+
+// rangeWindow returns the [range] of a selector, or the default lookback
+func rangeWindow(q *TelemetryQuery) time.Duration {
+	if q.Duration > 0 {
+		return q.Duration
+	}
+	return defaultLookback
+}
+
+// seriesLabels returns the label set identifying the series of a raw
+// metric: its attributes, name and agent
+func seriesLabels(r QueryResult) map[string]string {
+	labels := make(map[string]string, len(r.Labels)+2)
+	for key, value := range r.Labels {
+		labels[key] = value
+	}
+	// Samples written without an agent, such as by recording rules that
+	// aggregate agents away, carry the nil agent ID
+	for _, key := range []string{"name", "agent_id"} {
+		if value, ok := r.Data[key].(string); ok && value != "" && value != uuid.Nil.String() {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// labelsKey returns a canonical string for a label set
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%q=%q,", key, labels[key])
+	}
+	return b.String()
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+func executeRangeQuery(t *testing.T, service services.TelemetryQueryService, input string, start, end time.Time, step time.Duration) []Series {
+	parsed, err := NewParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	series, _, err := NewExecutor(service, zap.NewNop()).ExecuteRange(context.Background(), parsed, &ExecutionContext{
+		StartTime: &start,
+		EndTime:   &end,
+		Step:      step,
+	})
+	if err != nil {
+		t.Fatalf("Failed to execute range query: %v", err)
+	}
+	return series
+}
+
+// counterSeries returns cumulative counter samples one minute apart
+func counterSeries(start time.Time, host string, values ...float64) []services.Metric {
+	metrics := make([]services.Metric, len(values))
+	for i, value := range values {
+		metrics[i] = services.Metric{
+			Timestamp: start.Add(time.Duration(i) * time.Minute), Name: "requests", Value: value,
+			Type: services.MetricTypeCounter, Temporality: "cumulative", IsMonotonic: true,
+			Labels: map[string]string{"host": host},
+		}
+	}
+	return metrics
+}
+
+func pointValues(points []Point) []float64 {
+	values := make([]float64, len(points))
+	for i, p := range points {
+		values[i] = p.Value
+	}
+	return values
+}
+
+func TestStepTimestamps_Aligned(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 20, 0, time.UTC)
+	steps, err := stepTimestamps(start, start.Add(3*time.Minute), time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(steps) != 3 || !steps[0].Equal(time.Date(2024, 1, 8, 12, 1, 0, 0, time.UTC)) {
+		t.Errorf("Expected three steps from 12:01, got %v", steps)
+	}
+
+	if _, err := stepTimestamps(start, start.Add(24*time.Hour), time.Second); err == nil {
+		t.Error("Expected too many steps to be rejected")
+	}
+}
+
+func TestExecuteRange_SelectorLookback(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	metrics := append(counterSeries(start, "a", 1, 2), counterSeries(start.Add(30*time.Second), "b", 5)...)
+	service := &stubTelemetryService{metrics: metrics}
+
+	series := executeRangeQuery(t, service, `metrics{metric="requests"} [2m]`, start, start.Add(4*time.Minute), time.Minute)
+	if len(series) != 2 {
+		t.Fatalf("Expected one series per host, got %+v", series)
+	}
+	// Host a is seen until two minutes after its last sample at 12:01
+	if got := pointValues(series[0].Points); len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 2 {
+		t.Errorf("Unexpected points for host a: %v", series[0].Points)
+	}
+	if series[0].Labels["host"] != "a" || series[0].Labels["name"] != "requests" {
+		t.Errorf("Unexpected labels: %v", series[0].Labels)
+	}
+	if got := pointValues(series[1].Points); len(got) != 2 || got[0] != 5 {
+		t.Errorf("Unexpected points for host b: %v", series[1].Points)
+	}
+}
+
+func TestExecuteRange_RatePerSeries(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	metrics := append(counterSeries(start, "a", 10, 20, 5, 15), counterSeries(start, "b", 100, 160, 220, 280)...)
+	service := &stubTelemetryService{metrics: metrics}
+
+	series := executeRangeQuery(t, service, `increase(metrics{metric="requests"} [2m])`, start, start.Add(3*time.Minute), time.Minute)
+	if len(series) != 2 {
+		t.Fatalf("Expected one series per host, got %+v", series)
+	}
+	// Each window holds the samples of the step and the one before. The
+	// reset at 12:02 counts the new value as the increase.
+	if got := pointValues(series[0].Points); len(got) != 3 || got[0] != 10 || got[1] != 5 || got[2] != 10 {
+		t.Errorf("Unexpected increase for host a: %v", got)
+	}
+	if got := pointValues(series[1].Points); len(got) != 3 || got[0] != 60 {
+		t.Errorf("Unexpected increase for host b: %v", got)
+	}
+
+	series = executeRangeQuery(t, service, `sum(rate(metrics{metric="requests"} [2m])) by (name)`, start, start.Add(3*time.Minute), time.Minute)
+	if len(series) != 1 || series[0].Labels["name"] != "requests" {
+		t.Fatalf("Expected one summed series, got %+v", series)
+	}
+	if got := pointValues(series[0].Points); len(got) != 3 || got[0] != 70.0/60 {
+		t.Errorf("Unexpected summed rate: %v", got)
+	}
+}
+
+func TestExecuteRange_SampleLimit(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	service := &stubTelemetryService{metrics: append(counterSeries(start, "a", 1, 2, 3), counterSeries(start, "b", 4, 5, 6)...)}
+	executor := &ExecutorVisitor{executor: NewExecutor(service, zap.NewNop()), ctx: context.Background(), execCtx: &ExecutionContext{Step: time.Minute}}
+	selector := &TelemetryQuery{Type: TelemetryTypeMetrics, Selectors: map[string]*Selector{}}
+
+	// Reading fewer samples than the selector matches would drop series
+	visitor := &RangeVisitor{executor: executor, steps: []time.Time{start.Add(2 * time.Minute)}, sampleLimit: 4}
+	if _, err := visitor.fetchSeries(selector, 5*time.Minute); err == nil || !strings.Contains(err.Error(), "more than 4 samples") {
+		t.Errorf("Expected the selector to fail at the sample limit, got %v", err)
+	}
+
+	visitor.sampleLimit = 7
+	series, err := visitor.fetchSeries(selector, 5*time.Minute)
+	if err != nil || len(series) != 2 {
+		t.Errorf("Expected both series within the sample limit, got %+v, %v", series, err)
+	}
+}
+
+func TestExecuteRange_HistogramQuantile(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	var metrics []services.Metric
+	for i, counts := range [][]int64{{0, 0, 0}, {4, 0, 0}, {4, 4, 0}} {
+		metrics = append(metrics, services.Metric{
+			Timestamp: start.Add(time.Duration(i) * time.Minute), Name: "latency", Type: services.MetricTypeHistogram,
+			Temporality: "cumulative", BucketCounts: counts, ExplicitBounds: []float64{1, 2},
+		})
+	}
+	service := &stubTelemetryService{metrics: metrics}
+
+	series := executeRangeQuery(t, service, `histogram_quantile(0.5, metrics{metric="latency"} [2m])`, start.Add(time.Minute), start.Add(2*time.Minute), time.Minute)
+	if len(series) != 1 {
+		t.Fatalf("Expected one series, got %+v", series)
+	}
+	// The window before 12:01 sees the four observations up to 1, and the
+	// one before 12:02 only the four added in (1, 2]
+	if got := pointValues(series[0].Points); len(got) != 2 || got[0] != 0.5 || got[1] != 1.5 {
+		t.Errorf("Unexpected quantiles: %v", got)
+	}
+
+	parsed, err := NewParser(`histogram_quantile(metrics{metric="latency"})`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	end := start.Add(time.Minute)
+	if _, _, err := NewExecutor(service, zap.NewNop()).ExecuteRange(context.Background(), parsed, &ExecutionContext{StartTime: &start, EndTime: &end, Step: time.Minute}); err == nil {
+		t.Error("Expected histogram_quantile without a quantile to be rejected in range queries")
+	}
+}
+
+func TestExecuteRange_VectorMatching(t *testing.T) {
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	var metrics []services.Metric
+	for i, values := range [][3]float64{{2, 10, 20}, {6, 30, 20}} {
+		at := start.Add(time.Duration(i) * time.Minute)
+		metrics = append(metrics,
+			gauge(at, "errors", values[0], map[string]string{"service": "api"}),
+			gauge(at, "requests", values[1], map[string]string{"service": "api"}),
+			gauge(at, "requests", values[2], map[string]string{"service": "web"}),
+		)
+	}
+	service := &stubTelemetryService{metrics: metrics}
+
+	series := executeRangeQuery(t, service, `metrics{metric="errors"} / on(service) metrics{metric="requests"} [1m]`, start, start.Add(time.Minute), time.Minute)
+	if len(series) != 1 || series[0].Labels["service"] != "api" || len(series[0].Labels) != 1 {
+		t.Fatalf("Expected the api error ratio, got %+v", series)
+	}
+	if got := pointValues(series[0].Points); len(got) != 2 || got[0] != 0.2 || got[1] != 0.2 {
+		t.Errorf("Unexpected ratios: %v", got)
+	}
+
+	// topk picks the largest series at every step
+	series = executeRangeQuery(t, service, `topk(1, metrics{metric="requests"} [1m])`, start, start.Add(time.Minute), time.Minute)
+	if len(series) != 2 || len(series[0].Points) != 1 || len(series[1].Points) != 1 {
+		t.Fatalf("Expected web then api on top, got %+v", series)
+	}
+
+	series = executeRangeQuery(t, service, `avg_over_time(metrics{metric="requests", service="api"} [2m])`, start, start.Add(time.Minute), time.Minute)
+	if len(series) != 1 || series[0].Labels["name"] != "" {
+		t.Fatalf("Expected one series without the metric name, got %+v", series)
+	}
+	if got := pointValues(series[0].Points); len(got) != 2 || got[0] != 10 || got[1] != 20 {
+		t.Errorf("Unexpected averages: %v", got)
+	}
+
+	// absent has a point at the steps without samples
+	series = executeRangeQuery(t, service, `absent(metrics{metric="errors", service="web"} [1m])`, start, start.Add(time.Minute), time.Minute)
+	if len(series) != 1 || len(series[0].Points) != 2 || series[0].Labels["service"] != "web" {
+		t.Errorf("Unexpected absent series: %+v", series)
+	}
+}
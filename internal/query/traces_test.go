@@ -0,0 +1,89 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+func TestExecutor_TracesQuerySearchesSpans(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	service := &stubTelemetryService{traceMatches: []services.TraceMatch{
+		{TraceID: "t1", Spans: []services.Trace{
+			{TraceID: "t1", SpanID: "a", Name: "SELECT", ServiceName: "db", Duration: 900, Timestamp: end.Add(-2 * time.Minute)},
+			{TraceID: "t1", SpanID: "b", Name: "SELECT", ServiceName: "db", Duration: 700, Timestamp: end.Add(-time.Minute)},
+		}},
+	}}
+
+	results, meta := executeQuery(t, service, `traces{service="db", status="error", duration >= 1us, http.method=~"GET|POST"} [30m]`, end.Add(-time.Hour), end)
+	if len(results) != 2 || results[0].Data["span_id"] != "a" || results[1].Data["service_name"] != "db" || results[0].Value != int64(900) {
+		t.Fatalf("Expected the matched spans, got %+v", results)
+	}
+
+	// Every selector is evaluated by the store, with the status names
+	// mapped to OpenTelemetry status codes
+	search := service.traceSearches[0]
+	want := []services.Filter{
+		{Field: "duration", Operator: services.FilterOpGTE, Value: "1000", Numeric: true},
+		{Field: "http.method", Operator: services.FilterOpRegex, Value: "GET|POST"},
+		{Field: "service", Operator: services.FilterOpEqual, Value: "db"},
+		{Field: "status", Operator: services.FilterOpEqual, Value: "STATUS_CODE_ERROR"},
+	}
+	if search.Spans.Operator != "" || len(search.Spans.Filters) != len(want) || search.Limit != 1000 {
+		t.Fatalf("Unexpected trace search: %+v", search)
+	}
+	for i, filter := range want {
+		if search.Spans.Filters[i] != filter {
+			t.Errorf("Expected filter %+v, got %+v", filter, search.Spans.Filters[i])
+		}
+	}
+	if !search.StartTime.Equal(end.Add(-time.Hour)) || !search.EndTime.Equal(end) {
+		t.Errorf("Unexpected time range: %v..%v", search.StartTime, search.EndTime)
+	}
+	if !strings.HasPrefix(meta.Plan, `[storage] search traces where spans {duration >= 1000, http.method =~ "GET|POST", service = "db", status = "STATUS_CODE_ERROR"}`) {
+		t.Errorf("Unexpected plan:\n%s", meta.Plan)
+	}
+}
+
+func TestExecutor_StructuralQuery(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	service := &stubTelemetryService{traceMatches: []services.TraceMatch{
+		{TraceID: "t2", Spans: []services.Trace{
+			{TraceID: "t2", SpanID: "c", ServiceName: "db", Timestamp: end.Add(-time.Minute)},
+		}},
+		{TraceID: "t1", Spans: []services.Trace{
+			{TraceID: "t1", SpanID: "a", ServiceName: "db", Timestamp: end.Add(-3 * time.Minute)},
+			{TraceID: "t1", SpanID: "b", ServiceName: "db", Timestamp: end.Add(-2 * time.Minute)},
+		}},
+	}}
+
+	results, meta := executeQuery(t, service, `traces{service="frontend"} [1h] >> traces{service="db", agent_id="00000000-0000-0000-0000-000000000001"} [5m]`, end.Add(-time.Hour), end)
+	if len(results) != 2 {
+		t.Fatalf("Expected one result per trace, got %+v", results)
+	}
+	trace := results[1]
+	spans, ok := trace.Data["spans"].([]QueryResult)
+	if trace.Labels["trace_id"] != "t1" || trace.Value != 2 || !ok || len(spans) != 2 || spans[1].Data["span_id"] != "b" {
+		t.Errorf("Unexpected trace result: %+v", trace)
+	}
+	if !trace.Timestamp.Equal(end.Add(-2 * time.Minute)) {
+		t.Errorf("Expected the newest span timestamp, got %v", trace.Timestamp)
+	}
+
+	// Scope selectors only apply to the spans of their own query
+	search := service.traceSearches[0]
+	if search.Spans.Operator != services.StructuralDescendant || search.Spans.Left == nil || search.Spans.Right == nil {
+		t.Fatalf("Unexpected span set: %+v", search.Spans)
+	}
+	if search.AgentID != nil || len(search.Spans.Right.Filters) != 2 || search.Spans.Right.Filters[0].Field != "agent_id" {
+		t.Errorf("Expected agent_id to filter the right spans, got %+v", search)
+	}
+	if !strings.HasPrefix(meta.Plan, `[storage] search traces where spans ({service = "frontend"} >> {agent_id = "00000000-0000-0000-0000-000000000001", service = "db"})`) {
+		t.Errorf("Unexpected plan:\n%s", meta.Plan)
+	}
+}
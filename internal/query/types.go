@@ -20,6 +20,9 @@ type QueryVisitor interface {
 	VisitBinaryOp(*BinaryOp) (interface{}, error)
 	VisitFunctionCall(*FunctionCall) (interface{}, error)
 	VisitAggregation(*Aggregation) (interface{}, error)
+	VisitNumberLiteral(*NumberLiteral) (interface{}, error)
+	VisitStringLiteral(*StringLiteral) (interface{}, error)
+	VisitStructuralQuery(*StructuralQuery) (interface{}, error)
 }
 
 // TelemetryType represents the type of telemetry data
@@ -39,6 +42,8 @@ type TelemetryQuery struct {
 	StartTime *time.Time
 	EndTime   *time.Time
 	Limit     int
+	// Pipeline holds the stages applied to each log line, in order
+	Pipeline []*PipelineStage
 }
 
 // Accept implements Query interface
@@ -51,6 +56,9 @@ type Selector struct {
 	Label    string
 	Operator SelectorOperator
 	Value    string
+	// Numeric is set when the value is an unquoted number literal, which is
+	// compared numerically against typed attribute values
+	Numeric bool
 }
 
 // SelectorOperator represents the type of selector operation
@@ -61,6 +69,64 @@ const (
 	SelectorOpNotEqual SelectorOperator = "!="
 	SelectorOpRegex    SelectorOperator = "=~"
 	SelectorOpNotRegex SelectorOperator = "!~"
+	SelectorOpGT       SelectorOperator = ">"
+	SelectorOpGTE      SelectorOperator = ">="
+	SelectorOpLT       SelectorOperator = "<"
+	SelectorOpLTE      SelectorOperator = "<="
+)
+
+// PipelineStageKind represents the kind of a logs pipeline stage
+type PipelineStageKind string
+
+const (
+	// StageLineFilter keeps lines that contain (|=, !=) or match (|~, !~) a value
+	StageLineFilter PipelineStageKind = "line_filter"
+	// StageParser extracts labels from the line with json, logfmt or regexp
+	StageParser PipelineStageKind = "parser"
+	// StageLabelFilter keeps lines whose labels match all of its filters
+	StageLabelFilter PipelineStageKind = "label_filter"
+	// StageLineFormat rewrites the line from a template over its labels
+	StageLineFormat PipelineStageKind = "line_format"
+)
+
+// PipelineStage represents a stage of a logs pipeline such as
+// logs{service="api"} |= "error" | json | status >= 500
+type PipelineStage struct {
+	Kind PipelineStageKind
+	// Operator is the line filter operator: = for contains, != for does not
+	// contain, =~ and !~ for regex matches
+	Operator SelectorOperator
+	// Value is the line filter value, the regexp parser pattern or the
+	// line_format template
+	Value string
+	// Parser names the parser of parser stages: json, logfmt or regexp
+	Parser string
+	// Filters are the label filters of label filter stages
+	Filters []*Selector
+}
+
+// StructuralQuery relates the spans of two traces queries within the same
+// trace, as in traces{service="api"} > traces{status="error"}. It selects
+// the spans of Right that Operator relates to a span of Left.
+type StructuralQuery struct {
+	Left     Query
+	Operator StructuralOperator
+	Right    Query
+}
+
+// Accept implements Query interface
+func (s *StructuralQuery) Accept(visitor QueryVisitor) (interface{}, error) {
+	return visitor.VisitStructuralQuery(s)
+}
+
+// StructuralOperator represents the relation between spans of a structural
+// query
+type StructuralOperator string
+
+const (
+	StructuralChild      StructuralOperator = ">"  // child of a left span
+	StructuralDescendant StructuralOperator = ">>" // descendant of a left span
+	StructuralSibling    StructuralOperator = "~"  // sibling of a left span
 )
 
 // BinaryOp represents a binary operation between two queries
@@ -68,6 +134,9 @@ type BinaryOp struct {
 	Left     Query
 	Operator BinaryOperator
 	Right    Query
+	// Matching is the on()/ignoring() and group_left/group_right clause
+	// pairing the series of both operands, if any
+	Matching *VectorMatching
 }
 
 // Accept implements Query interface
@@ -91,8 +160,33 @@ const (
 	BinaryOpGTE      BinaryOperator = ">="
 	BinaryOpAnd      BinaryOperator = "and"
 	BinaryOpOr       BinaryOperator = "or"
+	BinaryOpUnless   BinaryOperator = "unless"
+)
+
+// MatchCardinality is how many series of each operand of a binary
+// operation may share a match group
+type MatchCardinality string
+
+const (
+	MatchOneToOne  MatchCardinality = "one-to-one"
+	MatchManyToOne MatchCardinality = "many-to-one" // group_left
+	MatchOneToMany MatchCardinality = "one-to-many" // group_right
 )
 
+// VectorMatching describes how a binary operation pairs the series of its
+// operands. Series match when they agree on the On labels, or on all
+// labels but the ignored ones and the metric name.
+type VectorMatching struct {
+	Card MatchCardinality
+	// On reports whether Labels lists the labels to match on rather than
+	// the labels to ignore
+	On     bool
+	Labels []string
+	// Include lists the labels of the one side copied onto the results of
+	// group_left and group_right
+	Include []string
+}
+
 // FunctionCall represents a function call on a query
 type FunctionCall struct {
 	Name string
@@ -109,6 +203,9 @@ type Aggregation struct {
 	Function string
 	Query    Query
 	By       []string // group by labels
+	// Param is the scalar argument of parametric aggregations such as
+	// histogram_quantile(0.99, ...)
+	Param *NumberLiteral
 }
 
 // Accept implements Query interface
@@ -116,6 +213,27 @@ func (a *Aggregation) Accept(visitor QueryVisitor) (interface{}, error) {
 	return visitor.VisitAggregation(a)
 }
 
+// NumberLiteral represents a scalar number argument
+type NumberLiteral struct {
+	Value float64
+}
+
+// Accept implements Query interface
+func (n *NumberLiteral) Accept(visitor QueryVisitor) (interface{}, error) {
+	return visitor.VisitNumberLiteral(n)
+}
+
+// StringLiteral represents a string function argument, such as the
+// replacement of label_replace
+type StringLiteral struct {
+	Value string
+}
+
+// Accept implements Query interface
+func (s *StringLiteral) Accept(visitor QueryVisitor) (interface{}, error) {
+	return visitor.VisitStringLiteral(s)
+}
+
 // QueryResult represents the result of a Lawrence QL query
 type QueryResult struct {
 	Type      TelemetryType          `json:"type"`
@@ -123,6 +241,8 @@ type QueryResult struct {
 	Labels    map[string]string      `json:"labels"`
 	Value     interface{}            `json:"value"`
 	Data      map[string]interface{} `json:"data,omitempty"`
+	// Attributes holds the typed attribute values of raw telemetry results
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
 }
 
 // QueryMeta contains metadata about query execution
@@ -131,6 +251,12 @@ type QueryMeta struct {
 	RowCount      int           `json:"row_count"`
 	QueryType     string        `json:"query_type"`
 	UsedRollups   bool          `json:"used_rollups"`
+	// Plan describes the steps the query ran, marking those the telemetry
+	// store evaluated as [storage] and those evaluated in memory as [executor]
+	Plan string `json:"plan,omitempty"`
+	// CachedChunks counts the chunks of a range query served from the
+	// results cache
+	CachedChunks int `json:"cached_chunks,omitempty"`
 }
 
 // ExecutionContext contains context for query execution
@@ -140,4 +266,22 @@ type ExecutionContext struct {
 	Limit     int
 	AgentID   *uuid.UUID
 	GroupID   *string
+	// DisableRollups forces metrics queries to read raw data
+	DisableRollups bool
+	// Step is the resolution of range queries
+	Step time.Duration
+}
+
+// Series is a range query result: the values of one label set at each
+// evaluation timestamp that has one
+type Series struct {
+	Type   TelemetryType     `json:"type"`
+	Labels map[string]string `json:"labels"`
+	Points []Point           `json:"points"`
+}
+
+// Point is the value of a series at an evaluation timestamp
+type Point struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
 }
@@ -0,0 +1,357 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// isSample reports whether a result is a numeric sample rather than a raw
+// log line or span: metrics, and the results of functions and operators
+func isSample(r QueryResult) bool {
+	return r.Type == TelemetryTypeMetrics || r.Data["function"] != nil || r.Data["operator"] != nil
+}
+
+// instantVector reduces results to the latest sample of each series,
+// labelled with the labels identifying the series. Series keep the order
+// they first appear in.
+func instantVector(results []QueryResult) []QueryResult {
+	latest := make(map[string]int)
+	var vector []QueryResult
+	for _, r := range results {
+		labels := seriesLabels(r)
+		key := labelsKey(labels)
+		r.Labels = labels
+		i, ok := latest[key]
+		if !ok {
+			latest[key] = len(vector)
+			vector = append(vector, r)
+		} else if r.Timestamp.After(vector[i].Timestamp) {
+			vector[i] = r
+		}
+	}
+	return vector
+}
+
+// InstantVector reduces query results to the latest numeric sample of each
+// series, labelled with the labels identifying the series. Raw log lines and
+// spans are dropped. It is the form of a result stored or compared outside
+// of a query, such as by recording rules.
+func InstantVector(results []QueryResult) []QueryResult {
+	samples := make([]QueryResult, 0, len(results))
+	for _, r := range results {
+		if isSample(r) {
+			samples = append(samples, r)
+		}
+	}
+	return instantVector(samples)
+}
+
+// SampleValue returns the value of a sample of an instant vector as a float,
+// reporting false for values that are not numeric
+func SampleValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// scalarValue returns the value of a number literal, or of arithmetic
+// between number literals
+func scalarValue(q Query) (float64, bool) {
+	switch q := q.(type) {
+	case *NumberLiteral:
+		return q.Value, true
+	case *BinaryOp:
+		if isComparison(q.Operator) || isSetOperator(q.Operator) {
+			return 0, false
+		}
+		left, ok := scalarValue(q.Left)
+		if !ok {
+			return 0, false
+		}
+		right, ok := scalarValue(q.Right)
+		if !ok {
+			return 0, false
+		}
+		return arithmetic(q.Operator, left, right), true
+	}
+	return 0, false
+}
+
+// isComparison reports whether an operator compares values, keeping the
+// samples for which the comparison holds
+func isComparison(op BinaryOperator) bool {
+	switch op {
+	case BinaryOpEqual, BinaryOpNotEqual, BinaryOpLT, BinaryOpGT, BinaryOpLTE, BinaryOpGTE:
+		return true
+	}
+	return false
+}
+
+// arithmetic applies an arithmetic operator. Division by zero follows IEEE
+// 754 like PromQL.
+func arithmetic(op BinaryOperator, left, right float64) float64 {
+	switch op {
+	case BinaryOpAdd:
+		return left + right
+	case BinaryOpSubtract:
+		return left - right
+	case BinaryOpMultiply:
+		return left * right
+	case BinaryOpDivide:
+		return left / right
+	}
+	return math.NaN()
+}
+
+// compare applies a comparison operator
+func compare(op BinaryOperator, left, right float64) bool {
+	switch op {
+	case BinaryOpEqual:
+		return left == right
+	case BinaryOpNotEqual:
+		return left != right
+	case BinaryOpLT:
+		return left < right
+	case BinaryOpGT:
+		return left > right
+	case BinaryOpLTE:
+		return left <= right
+	case BinaryOpGTE:
+		return left >= right
+	}
+	return false
+}
+
+// binaryScalar applies an operator between each sample of a vector and a
+// scalar. Arithmetic drops the metric name and comparisons keep the samples
+// for which they hold.
+func binaryScalar(op BinaryOperator, vector []QueryResult, scalar float64, scalarLeft bool) ([]QueryResult, error) {
+	if isSetOperator(op) {
+		return nil, fmt.Errorf("set operator %s is not defined between a query and a number", op)
+	}
+	results := make([]QueryResult, 0, len(vector))
+	for _, r := range vector {
+		value, err := toFloat64(r.Value)
+		if err != nil {
+			continue
+		}
+		left, right := value, scalar
+		if scalarLeft {
+			left, right = scalar, value
+		}
+
+		labels := r.Labels
+		if isComparison(op) {
+			if !compare(op, left, right) {
+				continue
+			}
+		} else {
+			value = arithmetic(op, left, right)
+			labels = withoutLabels(labels, "name")
+		}
+		results = append(results, operatorResult(op, r, labels, value))
+	}
+	return results, nil
+}
+
+// binaryVectors applies an operator between the samples of two vectors
+// that match. Set operators keep samples by whether their match group is
+// present on the other side. Arithmetic and comparisons pair the samples of
+// a match group: one to one by default, or each sample of the many side
+// with the single sample of the one side for group_left and group_right.
+func binaryVectors(op BinaryOperator, matching *VectorMatching, left, right []QueryResult) ([]QueryResult, error) {
+	if matching == nil {
+		matching = &VectorMatching{Card: MatchOneToOne}
+	}
+
+	switch op {
+	case BinaryOpAnd, BinaryOpUnless:
+		present := make(map[string]bool, len(right))
+		for _, r := range right {
+			present[matchSignature(matching, r.Labels)] = true
+		}
+		var results []QueryResult
+		for _, l := range left {
+			if present[matchSignature(matching, l.Labels)] == (op == BinaryOpAnd) {
+				results = append(results, l)
+			}
+		}
+		return results, nil
+	case BinaryOpOr:
+		present := make(map[string]bool, len(left))
+		for _, l := range left {
+			present[matchSignature(matching, l.Labels)] = true
+		}
+		results := append([]QueryResult{}, left...)
+		for _, r := range right {
+			if !present[matchSignature(matching, r.Labels)] {
+				results = append(results, r)
+			}
+		}
+		return results, nil
+	}
+
+	// The many side is paired with the sample of its match group on the one
+	// side, which has to be unique
+	many, one, oneSide := left, right, "right"
+	if matching.Card == MatchOneToMany {
+		many, one, oneSide = right, left, "left"
+	}
+	groups := make(map[string]QueryResult, len(one))
+	for _, r := range one {
+		signature := matchSignature(matching, r.Labels)
+		if _, ok := groups[signature]; ok {
+			return nil, fmt.Errorf("found duplicate series for the match group {%s} on the %s hand side of %s; matching labels must be unique on one side", signature, oneSide, op)
+		}
+		groups[signature] = r
+	}
+
+	paired := make(map[string]bool)
+	var results []QueryResult
+	for _, m := range many {
+		signature := matchSignature(matching, m.Labels)
+		o, ok := groups[signature]
+		if !ok {
+			continue
+		}
+		if matching.Card == MatchOneToOne {
+			if paired[signature] {
+				return nil, fmt.Errorf("multiple matches for the match group {%s} on the left hand side of %s; many-to-one matching must be explicit with group_left", signature, op)
+			}
+			paired[signature] = true
+		}
+
+		l, r := m, o
+		if matching.Card == MatchOneToMany {
+			l, r = o, m
+		}
+		lv, err := toFloat64(l.Value)
+		if err != nil {
+			continue
+		}
+		rv, err := toFloat64(r.Value)
+		if err != nil {
+			continue
+		}
+
+		value := lv
+		if isComparison(op) {
+			if !compare(op, lv, rv) {
+				continue
+			}
+		} else {
+			value = arithmetic(op, lv, rv)
+		}
+		result := operatorResult(op, m, matchedLabels(op, matching, m.Labels, o.Labels), value)
+		if o.Timestamp.After(result.Timestamp) {
+			result.Timestamp = o.Timestamp
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// matchSignature returns the key of the match group of a label set: its
+// on() labels, or every label but the ignored ones and the metric name
+func matchSignature(matching *VectorMatching, labels map[string]string) string {
+	signature := make(map[string]string, len(labels))
+	if matching.On {
+		for _, label := range matching.Labels {
+			if value, ok := labels[label]; ok {
+				signature[label] = value
+			}
+		}
+		return labelsKey(signature)
+	}
+	for label, value := range labels {
+		if label != "name" && !slices.Contains(matching.Labels, label) {
+			signature[label] = value
+		}
+	}
+	return labelsKey(signature)
+}
+
+// matchedLabels returns the labels of the result of pairing a sample of the
+// many side with one of the one side. Arithmetic drops the metric name.
+// One-to-one matching keeps only the labels matched on, and group_left and
+// group_right copy the included labels of the one side.
+func matchedLabels(op BinaryOperator, matching *VectorMatching, many, one map[string]string) map[string]string {
+	labels := make(map[string]string, len(many))
+	for label, value := range many {
+		labels[label] = value
+	}
+	if !isComparison(op) {
+		delete(labels, "name")
+	}
+	if matching.Card == MatchOneToOne {
+		for label := range labels {
+			if slices.Contains(matching.Labels, label) != matching.On {
+				delete(labels, label)
+			}
+		}
+	}
+	for _, label := range matching.Include {
+		if value, ok := one[label]; ok {
+			labels[label] = value
+		} else {
+			delete(labels, label)
+		}
+	}
+	return labels
+}
+
+// withoutLabels returns a copy of a label set without the given labels
+func withoutLabels(labels map[string]string, drop ...string) map[string]string {
+	kept := make(map[string]string, len(labels))
+	for label, value := range labels {
+		if !slices.Contains(drop, label) {
+			kept[label] = value
+		}
+	}
+	return kept
+}
+
+// operatorResult returns the result of an operator applied to a sample
+func operatorResult(op BinaryOperator, sample QueryResult, labels map[string]string, value float64) QueryResult {
+	return QueryResult{
+		Type:      sample.Type,
+		Timestamp: sample.Timestamp,
+		Labels:    labels,
+		Value:     value,
+		Data: map[string]interface{}{
+			"operator": string(op),
+		},
+	}
+}
+
+// describeBinaryOp describes a binary operator with its matching clause
+func describeBinaryOp(op BinaryOperator, matching *VectorMatching) string {
+	if matching == nil {
+		return string(op)
+	}
+	keyword := "ignoring"
+	if matching.On {
+		keyword = "on"
+	}
+	description := fmt.Sprintf("%s %s (%s)", op, keyword, strings.Join(matching.Labels, ", "))
+	switch matching.Card {
+	case MatchManyToOne:
+		description += fmt.Sprintf(" group_left (%s)", strings.Join(matching.Include, ", "))
+	case MatchOneToMany:
+		description += fmt.Sprintf(" group_right (%s)", strings.Join(matching.Include, ", "))
+	}
+	return description
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// vectorAgentID is the agent reporting the samples of the vector tests
+var vectorAgentID = uuid.MustParse("7d1c2e4a-5b6f-4a8e-9c0d-1e2f3a4b5c6d")
+
+// gauge returns a gauge sample with its labels as attributes
+func gauge(timestamp time.Time, name string, value float64, labels map[string]string) services.Metric {
+	attributes := make(map[string]interface{}, len(labels))
+	for label, value := range labels {
+		attributes[label] = value
+	}
+	return services.Metric{Timestamp: timestamp, Name: name, AgentID: vectorAgentID, Type: services.MetricTypeGauge, Value: value, Labels: labels, MetricAttributes: attributes}
+}
+
+// valuesBy returns result values keyed by a label
+func valuesBy(results []QueryResult, label string) map[string]float64 {
+	values := make(map[string]float64, len(results))
+	for _, r := range results {
+		values[r.Labels[label]] = r.Value.(float64)
+	}
+	return values
+}
+
+func vectorService(end time.Time) *stubTelemetryService {
+	return &stubTelemetryService{metrics: []services.Metric{
+		// Only the latest sample of each series takes part
+		gauge(end.Add(-5*time.Minute), "errors", 99, map[string]string{"service": "api"}),
+		gauge(end.Add(-time.Minute), "errors", 5, map[string]string{"service": "api"}),
+		gauge(end.Add(-time.Minute), "errors", 1, map[string]string{"service": "web"}),
+		gauge(end.Add(-time.Minute), "requests", 50, map[string]string{"service": "api"}),
+		gauge(end.Add(-time.Minute), "requests", 40, map[string]string{"service": "web"}),
+		gauge(end.Add(-time.Minute), "requests", 30, map[string]string{"service": "db"}),
+		gauge(end.Add(-time.Minute), "owner", 1, map[string]string{"service": "api", "team": "core"}),
+		gauge(end.Add(-time.Minute), "owner", 1, map[string]string{"service": "web", "team": "edge"}),
+	}}
+}
+
+func TestExecutor_VectorMatching(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	service := vectorService(end)
+	start := end.Add(-time.Hour)
+
+	// Series match on all labels but the metric name, which arithmetic drops
+	results, meta := executeQuery(t, service, `metrics{metric="errors"} / metrics{metric="requests"}`, start, end)
+	values := valuesBy(results, "service")
+	if len(results) != 2 || values["api"] != 0.1 || values["web"] != 0.025 {
+		t.Fatalf("Unexpected error ratios: %+v", results)
+	}
+	if _, ok := results[0].Labels["name"]; ok {
+		t.Errorf("Expected arithmetic to drop the metric name, got %v", results[0].Labels)
+	}
+	if !strings.HasPrefix(meta.Plan, "[executor] /\n") {
+		t.Errorf("Unexpected plan:\n%s", meta.Plan)
+	}
+
+	// group_left pairs every series with the one of its service and copies
+	// the team label
+	results, meta = executeQuery(t, service, `metrics{metric="requests"} * on(service) group_left(team) metrics{metric="owner"}`, start, end)
+	if len(results) != 2 {
+		t.Fatalf("Expected the services with an owner, got %+v", results)
+	}
+	for _, r := range results {
+		if (r.Labels["service"] == "api") != (r.Labels["team"] == "core") || r.Labels["agent_id"] == "" {
+			t.Errorf("Unexpected labels: %v", r.Labels)
+		}
+	}
+	if !strings.HasPrefix(meta.Plan, "[executor] * on (service) group_left (team)") {
+		t.Errorf("Unexpected plan:\n%s", meta.Plan)
+	}
+
+	// One-to-one matching on service keeps only the service label
+	results, _ = executeQuery(t, service, `metrics{metric="requests"} - on(service) metrics{metric="errors"}`, start, end)
+	if values := valuesBy(results, "service"); len(results) != 2 || values["api"] != 45 || len(results[0].Labels) != 1 {
+		t.Errorf("Unexpected differences: %+v", results)
+	}
+
+	// Comparisons keep the left samples for which they hold
+	results, _ = executeQuery(t, service, `metrics{metric="requests"} > ignoring(team) group_left metrics{metric="owner"} * 45`, start, end)
+	if len(results) != 1 || results[0].Labels["service"] != "api" || results[0].Value != 50.0 || results[0].Labels["name"] != "requests" {
+		t.Errorf("Unexpected comparison: %+v", results)
+	}
+}
+
+func TestExecutor_VectorMatchingDuplicates(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	service := vectorService(end)
+	service.metrics = append(service.metrics, gauge(end.Add(-time.Minute), "owner", 1, map[string]string{"service": "api", "team": "billing"}))
+
+	parsed, err := NewParser(`metrics{metric="requests"} * on(service) group_left(team) metrics{metric="owner"}`).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	start := end.Add(-time.Hour)
+	_, _, err = NewExecutor(service, zap.NewNop()).Execute(context.Background(), parsed, &ExecutionContext{StartTime: &start, EndTime: &end})
+	if err == nil || !strings.Contains(err.Error(), "duplicate series") {
+		t.Errorf("Expected two owners of api to be rejected, got %v", err)
+	}
+}
+
+func TestExecutor_ScalarAndSetOperations(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	service := vectorService(end)
+	start := end.Add(-time.Hour)
+
+	results, _ := executeQuery(t, service, `metrics{metric="errors"} * 100 / 2`, start, end)
+	if values := valuesBy(results, "service"); len(results) != 2 || values["api"] != 250 || values["web"] != 50 {
+		t.Errorf("Unexpected scaled errors: %+v", results)
+	}
+
+	results, _ = executeQuery(t, service, `100 - metrics{metric="requests"} >= 55`, start, end)
+	if values := valuesBy(results, "service"); len(results) != 2 || values["web"] != 60 || values["db"] != 70 {
+		t.Errorf("Unexpected filtered values: %+v", results)
+	}
+
+	results, _ = executeQuery(t, service, `metrics{metric="requests"} and metrics{metric="errors"}`, start, end)
+	if values := valuesBy(results, "service"); len(results) != 2 || values["api"] != 50 || values["web"] != 40 {
+		t.Errorf("Unexpected intersection: %+v", results)
+	}
+	results, _ = executeQuery(t, service, `metrics{metric="requests"} unless metrics{metric="errors"}`, start, end)
+	if len(results) != 1 || results[0].Labels["service"] != "db" {
+		t.Errorf("Unexpected difference: %+v", results)
+	}
+	results, _ = executeQuery(t, service, `metrics{metric="errors"} or metrics{metric="requests"}`, start, end)
+	if values := valuesBy(results, "service"); len(results) != 3 || values["api"] != 5 || values["db"] != 30 {
+		t.Errorf("Unexpected union: %+v", results)
+	}
+}
+
+func TestExecutor_VectorFunctions(t *testing.T) {
+	end := time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC)
+	service := vectorService(end)
+	start := end.Add(-time.Hour)
+
+	results, _ := executeQuery(t, service, `topk(2, metrics{metric="requests"})`, start, end)
+	if len(results) != 2 || results[0].Labels["service"] != "api" || results[1].Labels["service"] != "web" {
+		t.Errorf("Unexpected topk: %+v", results)
+	}
+	results, _ = executeQuery(t, service, `bottomk(1, metrics{metric="owner"}) by (team)`, start, end)
+	if len(results) != 2 {
+		t.Errorf("Expected one owner per team, got %+v", results)
+	}
+
+	results, _ = executeQuery(t, service, `clamp(abs(0 - metrics{metric="requests"}), 35, 45)`, start, end)
+	if values := valuesBy(results, "service"); values["api"] != 45 || values["web"] != 40 || values["db"] != 35 {
+		t.Errorf("Unexpected clamped values: %+v", results)
+	}
+
+	results, _ = executeQuery(t, service, `label_replace(metrics{metric="owner"}, "tier", "$1-tier", "team", "(c.*)")`, start, end)
+	tiers := make(map[string]string)
+	for _, r := range results {
+		tiers[r.Labels["service"]] = r.Labels["tier"]
+	}
+	if len(results) != 2 || tiers["api"] != "core-tier" || tiers["web"] != "" {
+		t.Errorf("Unexpected replaced labels: %+v", results)
+	}
+
+	results, _ = executeQuery(t, service, `absent(metrics{metric="requests", service="cache"})`, start, end)
+	if len(results) != 1 || results[0].Value != 1.0 || results[0].Labels["service"] != "cache" || len(results[0].Labels) != 1 {
+		t.Errorf("Unexpected absent result: %+v", results)
+	}
+	results, _ = executeQuery(t, service, `absent(metrics{metric="requests"})`, start, end)
+	if len(results) != 0 {
+		t.Errorf("Expected no result for present series, got %+v", results)
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package recording
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/metrics"
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/query"
+	"github.com/getlawrence/lawrence-oss/internal/schedule"
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// ScopeName is the instrumentation scope of the samples recording rules write
+const ScopeName = "lawrence.recording_rules"
+
+// Querier executes parsed Lawrence QL queries
+type Querier interface {
+	Execute(ctx context.Context, q query.Query, execCtx *query.ExecutionContext) ([]query.QueryResult, *query.QueryMeta, error)
+}
+
+// Writer persists the samples recording rules produce
+type Writer interface {
+	WriteMetrics(ctx context.Context, metrics *otlp.MetricsData) error
+}
+
+// Manager evaluates the enabled recording rules, each on its own interval,
+// and writes their samples back as gauges. It implements
+// services.RecordingRuleEvaluator.
+type Manager struct {
+	querier Querier
+	writer  Writer
+	metrics *metrics.RecordingRuleMetrics
+	logger  *zap.Logger
+	now     func() time.Time
+	mu      sync.Mutex
+	rules   map[string]*ruleState
+	loop    *schedule.Loop
+}
+
+// ruleState is an enabled rule with its parsed expression, next evaluation
+// and the status of its last evaluation
+type ruleState struct {
+	schedule.Entry
+	rule   services.RecordingRule
+	query  query.Query
+	status services.RecordingRuleStatus
+}
+
+// NewManager creates a new recording rule manager
+func NewManager(querier Querier, writer Writer, recordingMetrics *metrics.RecordingRuleMetrics, logger *zap.Logger) *Manager {
+	if recordingMetrics == nil {
+		recordingMetrics = metrics.NewRecordingRuleMetrics(metrics.NullFactory)
+	}
+	m := &Manager{
+		querier: querier,
+		writer:  writer,
+		metrics: recordingMetrics,
+		logger:  logger,
+		now:     time.Now,
+		rules:   make(map[string]*ruleState),
+	}
+	m.loop = schedule.NewLoop(func() time.Time { return m.now() }, m.nextRun, m.run)
+	return m
+}
+
+// LoadRecordingRules replaces the evaluated rule set with the enabled rules.
+// Rules whose definition did not change keep their schedule and status; new
+// and changed rules are evaluated on the next run. The rule set is left
+// unchanged if any expression fails to parse.
+func (m *Manager) LoadRecordingRules(rules []*services.RecordingRule) error {
+	now := m.now()
+	loaded := make(map[string]*ruleState, len(rules))
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		state, err := newRuleState(rule)
+		if err != nil {
+			return fmt.Errorf("recording rule %q: %w", rule.Name, err)
+		}
+		state.Next = now
+		loaded[rule.ID] = state
+	}
+
+	m.mu.Lock()
+	for id, state := range loaded {
+		if existing, ok := m.rules[id]; ok && sameDefinition(&existing.rule, &state.rule) {
+			loaded[id] = existing
+		}
+	}
+	m.rules = loaded
+	m.mu.Unlock()
+
+	m.metrics.ActiveRules.Update(int64(len(loaded)))
+	m.loop.Reload()
+	return nil
+}
+
+// RecordingRuleStatus returns the status of an enabled rule, or nil for
+// rules that are disabled or unknown
+func (m *Manager) RecordingRuleStatus(id string) *services.RecordingRuleStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.rules[id]
+	if !ok {
+		return nil
+	}
+	status := state.status
+	return &status
+}
+
+// newRuleState parses the expression and interval of a rule
+func newRuleState(rule *services.RecordingRule) (*ruleState, error) {
+	if err := services.ValidateRecordingRule(rule); err != nil {
+		return nil, err
+	}
+	interval, err := time.ParseDuration(rule.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interval %q: %w", rule.Interval, err)
+	}
+	parsed, err := query.NewParser(rule.Expr).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+	return &ruleState{
+		Entry:  schedule.Entry{Interval: interval},
+		rule:   *rule,
+		query:  parsed,
+		status: services.RecordingRuleStatus{Health: services.RuleHealthUnknown},
+	}, nil
+}
+
+// sameDefinition reports whether two rules evaluate and write the same way
+func sameDefinition(a, b *services.RecordingRule) bool {
+	return a.Name == b.Name && a.Description == b.Description && a.Expr == b.Expr &&
+		a.Interval == b.Interval && maps.Equal(a.Labels, b.Labels)
+}
+
+// Start evaluates each rule when it is due until the manager is stopped
+func (m *Manager) Start() {
+	m.logger.Info("Starting recording rule manager")
+	m.loop.Start()
+}
+
+// Stop stops the manager, cancelling an in-progress evaluation
+func (m *Manager) Stop(ctx context.Context) error {
+	return m.loop.Stop(ctx)
+}
+
+// nextRun returns the earliest time a rule is due, or a minute from now
+// without rules
+func (m *Manager) nextRun(now time.Time) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return schedule.Next(now, maps.Values(m.rules))
+}
+
+// run evaluates every rule due at now, one at a time
+func (m *Manager) run(ctx context.Context, now time.Time) {
+	m.mu.Lock()
+	due := schedule.Due(now, maps.Values(m.rules))
+	m.mu.Unlock()
+
+	for _, state := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		m.evaluate(ctx, state, now)
+	}
+}
+
+// evaluate runs a rule once and records the outcome in its status
+func (m *Manager) evaluate(ctx context.Context, state *ruleState, now time.Time) {
+	start := time.Now()
+	samples, err := m.record(ctx, state, now)
+	duration := time.Since(start)
+
+	m.metrics.Evaluations.Inc(1)
+	m.metrics.EvaluationDuration.Record(duration)
+	status := services.RecordingRuleStatus{
+		Health:         services.RuleHealthOK,
+		LastEvaluation: &now,
+		LastDuration:   duration,
+		Samples:        samples,
+	}
+	if err != nil {
+		m.metrics.Failures.Inc(1)
+		status.Health = services.RuleHealthError
+		status.LastError = err.Error()
+		m.logger.Warn("Failed to evaluate recording rule",
+			zap.String("rule_id", state.rule.ID),
+			zap.String("name", state.rule.Name),
+			zap.Error(err))
+	} else {
+		m.metrics.SamplesWritten.Inc(int64(samples))
+	}
+
+	m.mu.Lock()
+	state.status = status
+	m.mu.Unlock()
+}
+
+// record evaluates the expression of a rule at now and writes its samples,
+// returning how many were written
+func (m *Manager) record(ctx context.Context, state *ruleState, now time.Time) (int, error) {
+	results, _, err := m.querier.Execute(ctx, state.query, &query.ExecutionContext{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute expression: %w", err)
+	}
+
+	samples := query.InstantVector(results)
+	if len(samples) == 0 {
+		if len(results) > 0 {
+			return 0, fmt.Errorf("expression returned %d log or trace results, recording rules need numeric samples", len(results))
+		}
+		return 0, nil
+	}
+
+	data := &otlp.MetricsData{Gauges: make([]otlp.MetricGaugeData, 0, len(samples))}
+	for _, sample := range samples {
+		value, ok := query.SampleValue(sample.Value)
+		if !ok {
+			continue
+		}
+		data.Gauges = append(data.Gauges, gauge(&state.rule, sample.Labels, value, now))
+	}
+
+	if err := m.writer.WriteMetrics(ctx, data); err != nil {
+		return 0, fmt.Errorf("failed to write samples: %w", err)
+	}
+	return len(data.Gauges), nil
+}
+
+// gauge returns the gauge a rule writes for a sample. The sample's labels
+// become attributes, overridden by the rule's labels; agent_id and group_id
+// are stored in their own columns.
+func gauge(rule *services.RecordingRule, labels map[string]string, value float64, now time.Time) otlp.MetricGaugeData {
+	attributes := make(map[string]interface{}, len(labels)+len(rule.Labels))
+	for key, value := range labels {
+		switch key {
+		case "name", "agent_id", "group_id":
+		default:
+			attributes[key] = value
+		}
+	}
+	for key, value := range rule.Labels {
+		attributes[key] = value
+	}
+
+	return otlp.MetricGaugeData{
+		ScopeName:         ScopeName,
+		ServiceName:       labels["service_name"],
+		MetricName:        rule.Name,
+		MetricDescription: rule.Description,
+		Attributes:        attributes,
+		TimeUnix:          now,
+		Value:             value,
+		AgentID:           labels["agent_id"],
+		GroupID:           labels["group_id"],
+	}
+}
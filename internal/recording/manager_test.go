@@ -0,0 +1,154 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package recording
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/query"
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// stubQuerier returns fixed results and records the queries it executes
+type stubQuerier struct {
+	results []query.QueryResult
+	err     error
+	queries int
+}
+
+func (q *stubQuerier) Execute(ctx context.Context, parsed query.Query, execCtx *query.ExecutionContext) ([]query.QueryResult, *query.QueryMeta, error) {
+	q.queries++
+	return q.results, &query.QueryMeta{}, q.err
+}
+
+// stubWriter captures written metrics
+type stubWriter struct {
+	gauges []otlp.MetricGaugeData
+}
+
+func (w *stubWriter) WriteMetrics(ctx context.Context, metrics *otlp.MetricsData) error {
+	w.gauges = append(w.gauges, metrics.Gauges...)
+	return nil
+}
+
+func makeRule(id string) *services.RecordingRule {
+	return &services.RecordingRule{
+		ID:       id,
+		Name:     "service:requests:rate5m",
+		Expr:     `sum(rate(metrics{metric="requests_total"} [5m])) by (service)`,
+		Interval: "1m",
+		Labels:   map[string]string{"team": "core"},
+		Enabled:  true,
+	}
+}
+
+func sample(labels map[string]string, value float64) query.QueryResult {
+	return query.QueryResult{
+		Type:   query.TelemetryTypeMetrics,
+		Labels: labels,
+		Value:  value,
+		Data:   map[string]interface{}{"function": "sum"},
+	}
+}
+
+func newTestManager(querier Querier, writer Writer, now time.Time) *Manager {
+	m := NewManager(querier, writer, nil, zap.NewNop())
+	m.now = func() time.Time { return now }
+	return m
+}
+
+func TestManager_WritesSamplesAsGauges(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	querier := &stubQuerier{results: []query.QueryResult{
+		sample(map[string]string{"service": "api", "team": "edge"}, 2.5),
+		sample(map[string]string{"service": "web", "agent_id": "7d1c2e4a-5b6f-4a8e-9c0d-1e2f3a4b5c6d"}, 1),
+	}}
+	writer := &stubWriter{}
+	m := newTestManager(querier, writer, now)
+	require.NoError(t, m.LoadRecordingRules([]*services.RecordingRule{makeRule("rule-1")}))
+
+	status := m.RecordingRuleStatus("rule-1")
+	require.NotNil(t, status)
+	assert.Equal(t, services.RuleHealthUnknown, status.Health)
+
+	m.run(context.Background(), now)
+	require.Len(t, writer.gauges, 2)
+	api := writer.gauges[0]
+	if api.Attributes["service"] != "api" {
+		api = writer.gauges[1]
+	}
+	assert.Equal(t, "service:requests:rate5m", api.MetricName)
+	assert.Equal(t, ScopeName, api.ScopeName)
+	assert.Equal(t, now, api.TimeUnix)
+	assert.Equal(t, 2.5, api.Value)
+	// The rule's labels override those of the sample
+	assert.Equal(t, map[string]interface{}{"service": "api", "team": "core"}, api.Attributes)
+
+	status = m.RecordingRuleStatus("rule-1")
+	assert.Equal(t, services.RuleHealthOK, status.Health)
+	assert.Equal(t, 2, status.Samples)
+	assert.Equal(t, now, *status.LastEvaluation)
+
+	// The rule is not due again until its interval has passed
+	m.run(context.Background(), now.Add(30*time.Second))
+	assert.Equal(t, 1, querier.queries)
+	assert.Equal(t, now.Add(time.Minute), m.nextRun(now))
+	m.run(context.Background(), now.Add(time.Minute))
+	assert.Equal(t, 2, querier.queries)
+}
+
+func TestManager_RecordsFailures(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	querier := &stubQuerier{err: errors.New("query timed out")}
+	m := newTestManager(querier, &stubWriter{}, now)
+	require.NoError(t, m.LoadRecordingRules([]*services.RecordingRule{makeRule("rule-1")}))
+
+	m.run(context.Background(), now)
+	status := m.RecordingRuleStatus("rule-1")
+	assert.Equal(t, services.RuleHealthError, status.Health)
+	assert.Contains(t, status.LastError, "query timed out")
+
+	// Raw logs are not samples a rule can record
+	querier.err = nil
+	querier.results = []query.QueryResult{{Type: query.TelemetryTypeLogs, Value: "connection refused"}}
+	m.run(context.Background(), now.Add(time.Minute))
+	status = m.RecordingRuleStatus("rule-1")
+	assert.Equal(t, services.RuleHealthError, status.Health)
+	assert.Contains(t, status.LastError, "need numeric samples")
+}
+
+func TestManager_LoadRecordingRules(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	m := newTestManager(&stubQuerier{}, &stubWriter{}, now)
+	disabled := makeRule("rule-2")
+	disabled.Enabled = false
+	require.NoError(t, m.LoadRecordingRules([]*services.RecordingRule{makeRule("rule-1"), disabled}))
+	assert.Nil(t, m.RecordingRuleStatus("rule-2"))
+
+	m.run(context.Background(), now)
+	require.Equal(t, services.RuleHealthOK, m.RecordingRuleStatus("rule-1").Health)
+
+	// Reloading an unchanged rule keeps its status, a changed rule starts over
+	require.NoError(t, m.LoadRecordingRules([]*services.RecordingRule{makeRule("rule-1")}))
+	assert.Equal(t, services.RuleHealthOK, m.RecordingRuleStatus("rule-1").Health)
+	changed := makeRule("rule-1")
+	changed.Interval = "5m"
+	require.NoError(t, m.LoadRecordingRules([]*services.RecordingRule{changed}))
+	assert.Equal(t, services.RuleHealthUnknown, m.RecordingRuleStatus("rule-1").Health)
+
+	// An invalid expression leaves the rule set unchanged
+	invalid := makeRule("rule-3")
+	invalid.Expr = "sum(metrics{"
+	err := m.LoadRecordingRules([]*services.RecordingRule{invalid})
+	assert.ErrorContains(t, err, "invalid expression")
+	assert.NotNil(t, m.RecordingRuleStatus("rule-1"))
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package rollup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule_Matches(t *testing.T) {
+	// Monday 2024-01-01 00:00
+	monday := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr    string
+		time    time.Time
+		matches bool
+	}{
+		{"* * * * *", monday.Add(17 * time.Minute), true},
+		{"*/5 * * * *", monday.Add(15 * time.Minute), true},
+		{"*/5 * * * *", monday.Add(16 * time.Minute), false},
+		{"0 * * * *", monday.Add(3 * time.Hour), true},
+		{"0 * * * *", monday.Add(3*time.Hour + time.Minute), false},
+		{"15,45 9-17 * * *", monday.Add(9*time.Hour + 45*time.Minute), true},
+		{"15,45 9-17 * * *", monday.Add(18*time.Hour + 15*time.Minute), false},
+		{"10-30/10 * * * *", monday.Add(20 * time.Minute), true},
+		{"10-30/10 * * * *", monday.Add(40 * time.Minute), false},
+		{"0 0 * * 1-5", monday, true},
+		{"0 0 * * 0", monday, false},
+		{"0 0 * * 7", monday.AddDate(0, 0, 6), true},
+		// Both day fields restricted: either one matching is enough
+		{"0 0 15 * 1", monday, true},
+		{"0 0 1 * 3", monday, true},
+		{"0 0 2 * 3", monday, false},
+		{"@daily", monday, true},
+		{"@hourly", monday.Add(30 * time.Minute), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr+" "+tt.time.Format(time.RFC3339), func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.matches, schedule.Matches(tt.time))
+		})
+	}
+}
+
+func TestParseSchedule_Next(t *testing.T) {
+	schedule, err := ParseSchedule("*/5 * * * *")
+	require.NoError(t, err)
+
+	from := time.Date(2024, 1, 1, 12, 3, 30, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 1, 1, 12, 5, 0, 0, time.UTC), schedule.Next(from))
+	assert.Equal(t, time.Date(2024, 1, 1, 12, 10, 0, 0, time.UTC), schedule.Next(time.Date(2024, 1, 1, 12, 5, 0, 0, time.UTC)))
+
+	never, err := ParseSchedule("0 0 31 2 *")
+	require.NoError(t, err)
+	assert.True(t, never.Next(from).IsZero())
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := ParseSchedule(expr)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/metrics"
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// Tier describes one rollup interval and the data it is built from
+type Tier struct {
+	Interval services.RollupInterval
+	Window   time.Duration
+	// Source is the finer tier this tier is aggregated from. Empty means raw metrics.
+	Source services.RollupInterval
+	// DefaultSchedule is used when no schedule is configured for the tier
+	DefaultSchedule string
+}
+
+// Tiers lists the rollup tiers from finest to coarsest. Each tier is built
+// from the one before it, so a window is only generated once its source
+// tier covers it.
+var Tiers = []Tier{
+	{Interval: services.RollupInterval1m, Window: time.Minute, DefaultSchedule: "* * * * *"},
+	{Interval: services.RollupInterval5m, Window: 5 * time.Minute, Source: services.RollupInterval1m, DefaultSchedule: "*/5 * * * *"},
+	{Interval: services.RollupInterval1h, Window: time.Hour, Source: services.RollupInterval5m, DefaultSchedule: "0 * * * *"},
+	{Interval: services.RollupInterval1d, Window: 24 * time.Hour, Source: services.RollupInterval1h, DefaultSchedule: "0 0 * * *"},
+}
+
+// Config contains rollup scheduler configuration
+type Config struct {
+	// Schedules maps an interval to a cron expression. Missing intervals use
+	// the tier's default schedule.
+	Schedules map[services.RollupInterval]string
+	// Delay is how long after a raw window closes before it is rolled up,
+	// allowing late data to arrive
+	Delay time.Duration
+	// MaxBackfill bounds how far back missed windows are generated
+	MaxBackfill time.Duration
+}
+
+// Store generates rollups and records how far each tier is complete
+type Store interface {
+	CreateRollups(ctx context.Context, window time.Time, interval services.RollupInterval) error
+	GetRollupWatermark(ctx context.Context, interval services.RollupInterval) (time.Time, error)
+	SetRollupWatermark(ctx context.Context, interval services.RollupInterval, completedUntil time.Time) error
+}
+
+// Scheduler fills the rollup tiers on their cron schedules. Every run
+// generates all complete windows since the tier's watermark, so windows
+// missed while the server was down are backfilled on the next run.
+type Scheduler struct {
+	tiers     []scheduledTier
+	config    Config
+	store     Store
+	metrics   *metrics.RollupMetrics
+	logger    *zap.Logger
+	now       func() time.Time
+	runMu     sync.Mutex
+	shutdown  chan struct{}
+	wg        sync.WaitGroup
+	runCancel context.CancelFunc
+}
+
+// scheduledTier is a tier with its parsed schedule and metrics
+type scheduledTier struct {
+	Tier
+	schedule *Schedule
+	windows  metrics.Counter
+	failures metrics.Counter
+}
+
+// NewScheduler creates a new rollup scheduler
+func NewScheduler(config Config, store Store, rollupMetrics *metrics.RollupMetrics, logger *zap.Logger) (*Scheduler, error) {
+	if config.Delay < 0 {
+		config.Delay = 0
+	}
+	if config.MaxBackfill <= 0 {
+		config.MaxBackfill = 24 * time.Hour
+	}
+	if rollupMetrics == nil {
+		rollupMetrics = metrics.NewRollupMetrics(metrics.NullFactory)
+	}
+
+	s := &Scheduler{
+		config:   config,
+		store:    store,
+		metrics:  rollupMetrics,
+		logger:   logger,
+		now:      time.Now,
+		shutdown: make(chan struct{}),
+	}
+
+	for _, tier := range Tiers {
+		expr := config.Schedules[tier.Interval]
+		if expr == "" {
+			expr = tier.DefaultSchedule
+		}
+		schedule, err := ParseSchedule(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule for %s rollups: %w", tier.Interval, err)
+		}
+
+		windows, failures := s.tierCounters(tier.Interval)
+		s.tiers = append(s.tiers, scheduledTier{
+			Tier:     tier,
+			schedule: schedule,
+			windows:  windows,
+			failures: failures,
+		})
+	}
+	return s, nil
+}
+
+// tierCounters returns the metrics of a tier
+func (s *Scheduler) tierCounters(interval services.RollupInterval) (metrics.Counter, metrics.Counter) {
+	switch interval {
+	case services.RollupInterval1m:
+		return s.metrics.Windows1m, s.metrics.Failures1m
+	case services.RollupInterval5m:
+		return s.metrics.Windows5m, s.metrics.Failures5m
+	case services.RollupInterval1h:
+		return s.metrics.Windows1h, s.metrics.Failures1h
+	default:
+		return s.metrics.Windows1d, s.metrics.Failures1d
+	}
+}
+
+// Start catches up on missed windows and then runs each tier on its schedule
+func (s *Scheduler) Start() {
+	s.logger.Info("Starting rollup scheduler",
+		zap.Duration("delay", s.config.Delay),
+		zap.Duration("max_backfill", s.config.MaxBackfill))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.runCancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		// Catch up on every tier at startup regardless of schedule
+		s.run(ctx, s.now(), true)
+
+		for {
+			now := s.now()
+			timer := time.NewTimer(s.nextRun(now).Sub(now))
+			select {
+			case <-timer.C:
+				s.run(ctx, s.now(), false)
+			case <-s.shutdown:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler, cancelling an in-progress run
+func (s *Scheduler) Stop(ctx context.Context) error {
+	close(s.shutdown)
+	if s.runCancel != nil {
+		s.runCancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// nextRun returns the earliest time any tier is scheduled after now
+func (s *Scheduler) nextRun(now time.Time) time.Time {
+	var next time.Time
+	for _, tier := range s.tiers {
+		t := tier.schedule.Next(now)
+		if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	if next.IsZero() {
+		next = now.Add(time.Minute)
+	}
+	return next
+}
+
+// run processes every tier scheduled at now, or all tiers if force is set.
+// Tiers run from finest to coarsest so a coarse tier sees the windows its
+// source tier produced in the same run.
+func (s *Scheduler) run(ctx context.Context, now time.Time, force bool) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	for _, tier := range s.tiers {
+		if !force && !tier.schedule.Matches(now) {
+			continue
+		}
+		if err := s.catchUp(ctx, tier, now); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.logger.Error("Failed to generate rollups",
+				zap.String("interval", string(tier.Interval)),
+				zap.Error(err))
+		}
+	}
+}
+
+// catchUp generates every complete window of a tier after its watermark
+func (s *Scheduler) catchUp(ctx context.Context, tier scheduledTier, now time.Time) error {
+	readyUntil, err := s.readyUntil(ctx, tier, now)
+	if err != nil {
+		return err
+	}
+
+	watermark, err := s.store.GetRollupWatermark(ctx, tier.Interval)
+	if err != nil {
+		return fmt.Errorf("failed to get rollup watermark: %w", err)
+	}
+
+	earliest := now.Add(-s.config.MaxBackfill).Truncate(tier.Window)
+	start := watermark.Truncate(tier.Window)
+	if start.Before(earliest) {
+		start = earliest
+	}
+
+	generated := 0
+	for window := start; !window.Add(tier.Window).After(readyUntil); window = window.Add(tier.Window) {
+		if err := s.store.CreateRollups(ctx, window, tier.Interval); err != nil {
+			tier.failures.Inc(1)
+			return fmt.Errorf("failed to create %s rollup for %s: %w", tier.Interval, window.Format(time.RFC3339), err)
+		}
+		if err := s.store.SetRollupWatermark(ctx, tier.Interval, window.Add(tier.Window)); err != nil {
+			return fmt.Errorf("failed to set rollup watermark: %w", err)
+		}
+		tier.windows.Inc(1)
+		generated++
+	}
+
+	// More than one window in a run means earlier runs were missed
+	if generated > 1 {
+		s.metrics.Backfilled.Inc(int64(generated - 1))
+		s.logger.Info("Backfilled rollups",
+			zap.String("interval", string(tier.Interval)),
+			zap.Int("windows", generated),
+			zap.Time("from", start))
+	}
+	return nil
+}
+
+// readyUntil returns the time up to which a tier's source data is complete.
+// Raw data is complete once the configured delay has passed; a finer tier is
+// complete up to its watermark.
+func (s *Scheduler) readyUntil(ctx context.Context, tier scheduledTier, now time.Time) (time.Time, error) {
+	if tier.Source == "" {
+		return now.Add(-s.config.Delay), nil
+	}
+	watermark, err := s.store.GetRollupWatermark(ctx, tier.Source)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get %s rollup watermark: %w", tier.Source, err)
+	}
+	return watermark, nil
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/getlawrence/lawrence-oss/internal/services"
+)
+
+// memoryStore records generated windows and watermarks
+type memoryStore struct {
+	mu         sync.Mutex
+	windows    map[services.RollupInterval][]time.Time
+	watermarks map[services.RollupInterval]time.Time
+	failAt     time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		windows:    make(map[services.RollupInterval][]time.Time),
+		watermarks: make(map[services.RollupInterval]time.Time),
+	}
+}
+
+func (m *memoryStore) CreateRollups(ctx context.Context, window time.Time, interval services.RollupInterval) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if window.Equal(m.failAt) {
+		return fmt.Errorf("storage unavailable")
+	}
+	m.windows[interval] = append(m.windows[interval], window)
+	return nil
+}
+
+func (m *memoryStore) GetRollupWatermark(ctx context.Context, interval services.RollupInterval) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.watermarks[interval], nil
+}
+
+func (m *memoryStore) SetRollupWatermark(ctx context.Context, interval services.RollupInterval, completedUntil time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watermarks[interval] = completedUntil
+	return nil
+}
+
+func newTestScheduler(t *testing.T, store Store, config Config) *Scheduler {
+	scheduler, err := NewScheduler(config, store, nil, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	return scheduler
+}
+
+func TestScheduler_BackfillsMissedWindows(t *testing.T) {
+	store := newMemoryStore()
+	now := time.Date(2024, 1, 1, 12, 0, 45, 0, time.UTC)
+	// The server was down for ten minutes
+	store.watermarks[services.RollupInterval1m] = now.Add(-10 * time.Minute).Truncate(time.Minute)
+
+	scheduler := newTestScheduler(t, store, Config{Delay: 30 * time.Second, MaxBackfill: 10 * time.Minute})
+	scheduler.run(context.Background(), now, true)
+
+	windows := store.windows[services.RollupInterval1m]
+	require.Len(t, windows, 10)
+	assert.Equal(t, now.Add(-10*time.Minute).Truncate(time.Minute), windows[0])
+	assert.Equal(t, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), store.watermarks[services.RollupInterval1m])
+
+	// Coarser tiers only cover windows their source tier completed
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 1, 1, 11, 50, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 11, 55, 0, 0, time.UTC),
+	}, store.windows[services.RollupInterval5m])
+	assert.Equal(t, []time.Time{time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)}, store.windows[services.RollupInterval1h])
+	assert.Empty(t, store.windows[services.RollupInterval1d])
+}
+
+func TestScheduler_RespectsDelayAndMaxBackfill(t *testing.T) {
+	store := newMemoryStore()
+	now := time.Date(2024, 1, 1, 12, 0, 10, 0, time.UTC)
+
+	scheduler := newTestScheduler(t, store, Config{Delay: 30 * time.Second, MaxBackfill: 5 * time.Minute})
+	scheduler.run(context.Background(), now, true)
+
+	// 11:59 is not complete until 12:00:30, and nothing before 11:55 is generated
+	windows := store.windows[services.RollupInterval1m]
+	require.Len(t, windows, 4)
+	assert.Equal(t, time.Date(2024, 1, 1, 11, 55, 0, 0, time.UTC), windows[0])
+	assert.Equal(t, time.Date(2024, 1, 1, 11, 58, 0, 0, time.UTC), windows[3])
+}
+
+func TestScheduler_OnlyRunsScheduledTiers(t *testing.T) {
+	store := newMemoryStore()
+	now := time.Date(2024, 1, 1, 12, 7, 0, 0, time.UTC)
+	store.watermarks[services.RollupInterval1m] = now.Add(-3 * time.Minute)
+	store.watermarks[services.RollupInterval5m] = time.Date(2024, 1, 1, 11, 55, 0, 0, time.UTC)
+
+	scheduler := newTestScheduler(t, store, Config{})
+	scheduler.run(context.Background(), now, false)
+
+	assert.Len(t, store.windows[services.RollupInterval1m], 3)
+	// 12:07 does not match */5, so the 5m tier waits for its next run
+	assert.Empty(t, store.windows[services.RollupInterval5m])
+}
+
+func TestScheduler_RetriesFailedWindow(t *testing.T) {
+	store := newMemoryStore()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.watermarks[services.RollupInterval1m] = now.Add(-3 * time.Minute)
+	store.failAt = now.Add(-2 * time.Minute)
+
+	scheduler := newTestScheduler(t, store, Config{})
+	scheduler.run(context.Background(), now, true)
+	assert.Len(t, store.windows[services.RollupInterval1m], 1)
+	assert.Equal(t, now.Add(-2*time.Minute), store.watermarks[services.RollupInterval1m])
+
+	store.failAt = time.Time{}
+	scheduler.run(context.Background(), now, true)
+	assert.Len(t, store.windows[services.RollupInterval1m], 3)
+	assert.Equal(t, now, store.watermarks[services.RollupInterval1m])
+}
+
+func TestNewScheduler_InvalidSchedule(t *testing.T) {
+	_, err := NewScheduler(Config{
+		Schedules: map[services.RollupInterval]string{services.RollupInterval5m: "every five minutes"},
+	}, newMemoryStore(), nil, zaptest.NewLogger(t))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "5m")
+}
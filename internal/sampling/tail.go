@@ -0,0 +1,410 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package sampling
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/metrics"
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+)
+
+// PolicyType identifies how a sampling policy evaluates a trace
+type PolicyType string
+
+const (
+	// PolicyErrors keeps traces containing a span with an error status
+	PolicyErrors PolicyType = "errors"
+	// PolicyLatency keeps traces whose duration reaches a threshold
+	PolicyLatency PolicyType = "latency"
+	// PolicyAttribute keeps traces with a span or resource attribute matching a value
+	PolicyAttribute PolicyType = "attribute"
+	// PolicyProbabilistic keeps a fixed fraction of traces, chosen by trace ID
+	PolicyProbabilistic PolicyType = "probabilistic"
+)
+
+const (
+	statusCodeError = "STATUS_CODE_ERROR"
+	writeTimeout    = 10 * time.Second
+)
+
+// Policy decides whether a trace is kept. A trace is kept if any policy matches.
+type Policy struct {
+	Name string
+	Type PolicyType
+	// Threshold is the minimum trace duration for latency policies
+	Threshold time.Duration
+	// Key is the attribute inspected by attribute policies
+	Key string
+	// Values are the accepted attribute values. Empty means the key must be present.
+	Values []string
+	// Rate is the fraction of traces kept by probabilistic policies, between 0 and 1
+	Rate float64
+}
+
+// Config contains tail sampler configuration
+type Config struct {
+	// DecisionWait is how long spans of a trace are buffered before deciding
+	DecisionWait time.Duration
+	// MaxBufferedSpans bounds the buffer. When it is full, the oldest traces
+	// are decided early.
+	MaxBufferedSpans int
+	// DecidedCacheSize is the number of recent decisions remembered so late
+	// spans follow the decision of their trace
+	DecidedCacheSize int
+	Policies         []Policy
+}
+
+// Writer persists spans of kept traces
+type Writer interface {
+	WriteTraces(ctx context.Context, traces []otlp.TraceData) error
+}
+
+// TailSampler buffers spans by trace ID for a decision window, then keeps or
+// drops whole traces according to the configured policies
+type TailSampler struct {
+	mu            sync.Mutex
+	traces        map[string]*traceBuffer
+	order         *list.List // *traceBuffer in order of first arrival
+	bufferedSpans int
+	decided       map[string]bool
+	decidedRing   []string
+	decidedNext   int
+	config        Config
+	writer        Writer
+	metrics       *metrics.SamplingMetrics
+	logger        *zap.Logger
+	now           func() time.Time
+	shutdown      chan struct{}
+	wg            sync.WaitGroup
+}
+
+// traceBuffer holds the spans of a single undecided trace
+type traceBuffer struct {
+	traceID   string
+	spans     []otlp.TraceData
+	firstSeen time.Time
+	element   *list.Element
+}
+
+// NewTailSampler creates a new tail sampler
+func NewTailSampler(config Config, writer Writer, samplingMetrics *metrics.SamplingMetrics, logger *zap.Logger) (*TailSampler, error) {
+	if config.DecisionWait <= 0 {
+		config.DecisionWait = 10 * time.Second
+	}
+	if config.MaxBufferedSpans <= 0 {
+		config.MaxBufferedSpans = 100000
+	}
+	if config.DecidedCacheSize <= 0 {
+		config.DecidedCacheSize = 50000
+	}
+	if err := validatePolicies(config.Policies); err != nil {
+		return nil, err
+	}
+	if samplingMetrics == nil {
+		samplingMetrics = metrics.NewSamplingMetrics(metrics.NullFactory)
+	}
+
+	return &TailSampler{
+		traces:      make(map[string]*traceBuffer),
+		order:       list.New(),
+		decided:     make(map[string]bool, config.DecidedCacheSize),
+		decidedRing: make([]string, config.DecidedCacheSize),
+		config:      config,
+		writer:      writer,
+		metrics:     samplingMetrics,
+		logger:      logger,
+		now:         time.Now,
+		shutdown:    make(chan struct{}),
+	}, nil
+}
+
+// validatePolicies checks that every policy is complete
+func validatePolicies(policies []Policy) error {
+	if len(policies) == 0 {
+		return fmt.Errorf("at least one sampling policy is required")
+	}
+	for _, policy := range policies {
+		switch policy.Type {
+		case PolicyErrors:
+		case PolicyLatency:
+			if policy.Threshold <= 0 {
+				return fmt.Errorf("policy %q: latency threshold must be positive", policy.Name)
+			}
+		case PolicyAttribute:
+			if policy.Key == "" {
+				return fmt.Errorf("policy %q: attribute key is required", policy.Name)
+			}
+		case PolicyProbabilistic:
+			if policy.Rate < 0 || policy.Rate > 1 {
+				return fmt.Errorf("policy %q: rate must be between 0 and 1", policy.Name)
+			}
+		default:
+			return fmt.Errorf("policy %q: invalid type %q", policy.Name, policy.Type)
+		}
+	}
+	return nil
+}
+
+// Start starts the decision loop
+func (s *TailSampler) Start() {
+	s.logger.Info("Starting tail sampler",
+		zap.Duration("decision_wait", s.config.DecisionWait),
+		zap.Int("max_buffered_spans", s.config.MaxBufferedSpans),
+		zap.Int("policies", len(s.config.Policies)))
+
+	tick := time.Second
+	if s.config.DecisionWait < tick {
+		tick = s.config.DecisionWait
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.write(s.decideExpired(s.now()))
+			case <-s.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the decision loop and decides all buffered traces immediately
+func (s *TailSampler) Stop(ctx context.Context) error {
+	close(s.shutdown)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	var kept []otlp.TraceData
+	for s.order.Len() > 0 {
+		kept = append(kept, s.decideOldest()...)
+	}
+	s.updateGauges()
+	s.mu.Unlock()
+
+	if len(kept) == 0 {
+		return nil
+	}
+	if err := s.writer.WriteTraces(ctx, kept); err != nil {
+		s.metrics.WriteErrors.Inc(1)
+		return fmt.Errorf("failed to write sampled traces: %w", err)
+	}
+	return nil
+}
+
+// AddTraces buffers spans until their trace is decided. Spans of traces that
+// were already decided follow the earlier decision.
+func (s *TailSampler) AddTraces(traces []otlp.TraceData) {
+	var kept []otlp.TraceData
+
+	s.mu.Lock()
+	now := s.now()
+	for i := range traces {
+		span := traces[i]
+
+		// Spans without a trace ID cannot be grouped and are passed through
+		if span.TraceId == "" {
+			kept = append(kept, span)
+			continue
+		}
+
+		if keep, ok := s.decided[span.TraceId]; ok {
+			s.metrics.LateSpans.Inc(1)
+			if keep {
+				s.metrics.SpansKept.Inc(1)
+				kept = append(kept, span)
+			} else {
+				s.metrics.SpansDropped.Inc(1)
+			}
+			continue
+		}
+
+		buf, ok := s.traces[span.TraceId]
+		if !ok {
+			buf = &traceBuffer{traceID: span.TraceId, firstSeen: now}
+			buf.element = s.order.PushBack(buf)
+			s.traces[span.TraceId] = buf
+		}
+		buf.spans = append(buf.spans, span)
+		s.bufferedSpans++
+	}
+
+	// Decide the oldest traces early to keep the buffer bounded
+	for s.bufferedSpans > s.config.MaxBufferedSpans && s.order.Len() > 0 {
+		s.metrics.EarlyDecisions.Inc(1)
+		kept = append(kept, s.decideOldest()...)
+	}
+	s.updateGauges()
+	s.mu.Unlock()
+
+	s.write(kept)
+}
+
+// decideExpired decides every trace whose decision window has elapsed and
+// returns the spans to keep
+func (s *TailSampler) decideExpired(now time.Time) []otlp.TraceData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var kept []otlp.TraceData
+	for s.order.Len() > 0 {
+		buf := s.order.Front().Value.(*traceBuffer)
+		if now.Sub(buf.firstSeen) < s.config.DecisionWait {
+			break
+		}
+		kept = append(kept, s.decideOldest()...)
+	}
+	s.updateGauges()
+	return kept
+}
+
+// decideOldest removes the oldest buffered trace, records the decision and
+// returns its spans if kept. The caller must hold the lock.
+func (s *TailSampler) decideOldest() []otlp.TraceData {
+	buf := s.order.Remove(s.order.Front()).(*traceBuffer)
+	delete(s.traces, buf.traceID)
+	s.bufferedSpans -= len(buf.spans)
+
+	keep := s.evaluate(buf)
+	s.remember(buf.traceID, keep)
+
+	if keep {
+		s.metrics.TracesKept.Inc(1)
+		s.metrics.SpansKept.Inc(int64(len(buf.spans)))
+		return buf.spans
+	}
+	s.metrics.TracesDropped.Inc(1)
+	s.metrics.SpansDropped.Inc(int64(len(buf.spans)))
+	return nil
+}
+
+// remember records a decision in the bounded decision cache
+func (s *TailSampler) remember(traceID string, keep bool) {
+	if evicted := s.decidedRing[s.decidedNext]; evicted != "" {
+		delete(s.decided, evicted)
+	}
+	s.decidedRing[s.decidedNext] = traceID
+	s.decidedNext = (s.decidedNext + 1) % len(s.decidedRing)
+	s.decided[traceID] = keep
+}
+
+// evaluate applies all policies to a trace and reports whether any matched
+func (s *TailSampler) evaluate(buf *traceBuffer) bool {
+	keep := false
+	for _, policy := range s.config.Policies {
+		if !policyMatches(policy, buf) {
+			continue
+		}
+		keep = true
+		switch policy.Type {
+		case PolicyErrors:
+			s.metrics.KeptByErrors.Inc(1)
+		case PolicyLatency:
+			s.metrics.KeptByLatency.Inc(1)
+		case PolicyAttribute:
+			s.metrics.KeptByAttribute.Inc(1)
+		case PolicyProbabilistic:
+			s.metrics.KeptByProbabilistic.Inc(1)
+		}
+	}
+	return keep
+}
+
+// policyMatches reports whether a single policy matches a trace
+func policyMatches(policy Policy, buf *traceBuffer) bool {
+	switch policy.Type {
+	case PolicyErrors:
+		for i := range buf.spans {
+			if buf.spans[i].StatusCode == statusCodeError {
+				return true
+			}
+		}
+	case PolicyLatency:
+		return traceDuration(buf.spans) >= policy.Threshold
+	case PolicyAttribute:
+		for i := range buf.spans {
+			if attributeMatches(policy, buf.spans[i].SpanAttributes) || attributeMatches(policy, buf.spans[i].ResourceAttributes) {
+				return true
+			}
+		}
+	case PolicyProbabilistic:
+		return sampledByTraceID(buf.traceID, policy.Rate)
+	}
+	return false
+}
+
+// traceDuration returns the time between the earliest span start and the latest span end
+func traceDuration(spans []otlp.TraceData) time.Duration {
+	var start, end time.Time
+	for i := range spans {
+		spanStart := spans[i].Timestamp
+		spanEnd := spanStart.Add(time.Duration(spans[i].Duration))
+		if start.IsZero() || spanStart.Before(start) {
+			start = spanStart
+		}
+		if spanEnd.After(end) {
+			end = spanEnd
+		}
+	}
+	return end.Sub(start)
+}
+
+// attributeMatches reports whether an attribute map satisfies an attribute
+// policy. Typed values are compared by their string form.
+func attributeMatches(policy Policy, attrs map[string]interface{}) bool {
+	value, ok := otlp.StringAttribute(attrs, policy.Key)
+	if !ok {
+		return false
+	}
+	if len(policy.Values) == 0 {
+		return true
+	}
+	for _, accepted := range policy.Values {
+		if value == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// sampledByTraceID deterministically selects a fraction of trace IDs so that
+// every span of a trace gets the same outcome
+func sampledByTraceID(traceID string, rate float64) bool {
+	const buckets = 10000
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(traceID))
+	return h.Sum64()%buckets < uint64(rate*buckets)
+}
+
+// updateGauges publishes the buffer size. The caller must hold the lock.
+func (s *TailSampler) updateGauges() {
+	s.metrics.BufferedTraces.Update(int64(s.order.Len()))
+	s.metrics.BufferedSpans.Update(int64(s.bufferedSpans))
+}
+
+// write stores kept spans, logging failures
+func (s *TailSampler) write(spans []otlp.TraceData) {
+	if len(spans) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	if err := s.writer.WriteTraces(ctx, spans); err != nil {
+		s.metrics.WriteErrors.Inc(1)
+		s.logger.Error("Failed to write sampled traces", zap.Int("spans", len(spans)), zap.Error(err))
+	}
+}
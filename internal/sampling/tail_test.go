@@ -0,0 +1,191 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package sampling
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+)
+
+// recordingWriter captures spans written by the sampler
+type recordingWriter struct {
+	mu    sync.Mutex
+	spans []otlp.TraceData
+}
+
+func (w *recordingWriter) WriteTraces(ctx context.Context, traces []otlp.TraceData) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.spans = append(w.spans, traces...)
+	return nil
+}
+
+func (w *recordingWriter) traceIDs() map[string]int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ids := make(map[string]int)
+	for _, span := range w.spans {
+		ids[span.TraceId]++
+	}
+	return ids
+}
+
+func newTestSampler(t *testing.T, config Config) (*TailSampler, *recordingWriter, *time.Time) {
+	writer := &recordingWriter{}
+	sampler, err := NewTailSampler(config, writer, nil, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	sampler.now = func() time.Time { return now }
+	return sampler, writer, &now
+}
+
+func span(traceID string, start time.Time, duration time.Duration, status string) otlp.TraceData {
+	return otlp.TraceData{
+		TraceId:    traceID,
+		Timestamp:  start,
+		Duration:   int64(duration),
+		StatusCode: status,
+	}
+}
+
+func TestTailSampler_KeepsWholeTracesByPolicy(t *testing.T) {
+	sampler, writer, now := newTestSampler(t, Config{
+		DecisionWait: 10 * time.Second,
+		Policies: []Policy{
+			{Name: "errors", Type: PolicyErrors},
+			{Name: "slow", Type: PolicyLatency, Threshold: time.Second},
+			{Name: "checkout", Type: PolicyAttribute, Key: "http.route", Values: []string{"/checkout"}},
+		},
+	})
+
+	start := *now
+	checkout := span("checkout", start, 10*time.Millisecond, "STATUS_CODE_OK")
+	checkout.SpanAttributes = map[string]interface{}{"http.route": "/checkout"}
+
+	sampler.AddTraces([]otlp.TraceData{
+		span("error", start, 10*time.Millisecond, "STATUS_CODE_UNSET"),
+		span("error", start, 5*time.Millisecond, "STATUS_CODE_ERROR"),
+		// Individually fast spans that together exceed the threshold
+		span("slow", start, 600*time.Millisecond, "STATUS_CODE_OK"),
+		span("slow", start.Add(700*time.Millisecond), 600*time.Millisecond, "STATUS_CODE_OK"),
+		span("fast", start, 10*time.Millisecond, "STATUS_CODE_OK"),
+		checkout,
+	})
+
+	// Nothing is written before the decision window elapses
+	sampler.write(sampler.decideExpired(start.Add(5 * time.Second)))
+	assert.Empty(t, writer.traceIDs())
+
+	sampler.write(sampler.decideExpired(start.Add(10 * time.Second)))
+	assert.Equal(t, map[string]int{"error": 2, "slow": 2, "checkout": 1}, writer.traceIDs())
+}
+
+func TestTailSampler_LateSpansFollowDecision(t *testing.T) {
+	sampler, writer, now := newTestSampler(t, Config{
+		DecisionWait: time.Second,
+		Policies:     []Policy{{Name: "errors", Type: PolicyErrors}},
+	})
+
+	sampler.AddTraces([]otlp.TraceData{
+		span("kept", *now, time.Millisecond, "STATUS_CODE_ERROR"),
+		span("dropped", *now, time.Millisecond, "STATUS_CODE_OK"),
+	})
+	sampler.write(sampler.decideExpired(now.Add(time.Second)))
+
+	sampler.AddTraces([]otlp.TraceData{
+		span("kept", *now, time.Millisecond, "STATUS_CODE_OK"),
+		span("dropped", *now, time.Millisecond, "STATUS_CODE_ERROR"),
+	})
+	assert.Equal(t, map[string]int{"kept": 2}, writer.traceIDs())
+}
+
+func TestTailSampler_BufferIsBounded(t *testing.T) {
+	sampler, writer, now := newTestSampler(t, Config{
+		DecisionWait:     time.Minute,
+		MaxBufferedSpans: 3,
+		Policies:         []Policy{{Name: "errors", Type: PolicyErrors}},
+	})
+
+	sampler.AddTraces([]otlp.TraceData{
+		span("oldest", *now, time.Millisecond, "STATUS_CODE_ERROR"),
+		span("oldest", *now, time.Millisecond, "STATUS_CODE_OK"),
+		span("middle", *now, time.Millisecond, "STATUS_CODE_OK"),
+	})
+	assert.Empty(t, writer.traceIDs())
+
+	// Exceeding the bound decides the oldest trace early
+	sampler.AddTraces([]otlp.TraceData{span("newest", *now, time.Millisecond, "STATUS_CODE_OK")})
+	assert.Equal(t, map[string]int{"oldest": 2}, writer.traceIDs())
+
+	sampler.mu.Lock()
+	assert.Equal(t, 2, sampler.bufferedSpans)
+	assert.Equal(t, 2, sampler.order.Len())
+	sampler.mu.Unlock()
+}
+
+func TestTailSampler_ProbabilisticIsDeterministic(t *testing.T) {
+	kept := 0
+	for i := 0; i < 10000; i++ {
+		traceID := fmt.Sprintf("%032x", i)
+		first := sampledByTraceID(traceID, 0.25)
+		assert.Equal(t, first, sampledByTraceID(traceID, 0.25))
+		if first {
+			kept++
+		}
+	}
+	assert.InDelta(t, 2500, kept, 250)
+
+	assert.False(t, sampledByTraceID("abc", 0))
+	assert.True(t, sampledByTraceID("abc", 1))
+}
+
+func TestTailSampler_StopDecidesBufferedTraces(t *testing.T) {
+	sampler, writer, now := newTestSampler(t, Config{
+		DecisionWait: time.Hour,
+		Policies:     []Policy{{Name: "all", Type: PolicyProbabilistic, Rate: 1}},
+	})
+	sampler.Start()
+
+	sampler.AddTraces([]otlp.TraceData{
+		span("a", *now, time.Millisecond, "STATUS_CODE_OK"),
+		span("", *now, time.Millisecond, "STATUS_CODE_OK"),
+	})
+	// Spans without a trace ID are passed through immediately
+	assert.Equal(t, map[string]int{"": 1}, writer.traceIDs())
+
+	require.NoError(t, sampler.Stop(context.Background()))
+	assert.Equal(t, map[string]int{"": 1, "a": 1}, writer.traceIDs())
+}
+
+func TestNewTailSampler_InvalidPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies []Policy
+		errMsg   string
+	}{
+		{"no policies", nil, "at least one"},
+		{"latency without threshold", []Policy{{Name: "slow", Type: PolicyLatency}}, "threshold"},
+		{"attribute without key", []Policy{{Name: "attr", Type: PolicyAttribute}}, "key is required"},
+		{"rate out of range", []Policy{{Name: "p", Type: PolicyProbabilistic, Rate: 1.5}}, "between 0 and 1"},
+		{"unknown type", []Policy{{Name: "x", Type: "rate_limit"}}, "invalid type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTailSampler(Config{Policies: tt.policies}, &recordingWriter{}, nil, zaptest.NewLogger(t))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package schedule runs tasks that are each due on their own interval from a
+// single evaluation loop, as alert and recording rules are.
+package schedule
+
+import (
+	"context"
+	"iter"
+	"sync"
+	"time"
+)
+
+// idleInterval is how long the loop sleeps without any task due sooner
+const idleInterval = time.Minute
+
+// Entry is how often a task runs and when it is due next
+type Entry struct {
+	Interval time.Duration
+	Next     time.Time
+}
+
+// ScheduleEntry returns the entry, so that types embedding it are tasks
+func (e *Entry) ScheduleEntry() *Entry {
+	return e
+}
+
+// Task is anything scheduled by an entry
+type Task interface {
+	ScheduleEntry() *Entry
+}
+
+// Next returns the earliest time a task is due, or a minute from now
+// without tasks
+func Next[T Task](now time.Time, tasks iter.Seq[T]) time.Time {
+	next := now.Add(idleInterval)
+	for task := range tasks {
+		if entry := task.ScheduleEntry(); entry.Next.Before(next) {
+			next = entry.Next
+		}
+	}
+	return next
+}
+
+// Due returns the tasks due at now and schedules their next run an interval
+// later
+func Due[T Task](now time.Time, tasks iter.Seq[T]) []T {
+	var due []T
+	for task := range tasks {
+		entry := task.ScheduleEntry()
+		if !entry.Next.After(now) {
+			due = append(due, task)
+			entry.Next = now.Add(entry.Interval)
+		}
+	}
+	return due
+}
+
+// Loop calls run whenever the time returned by nextRun has come, until it is
+// stopped. Reload wakes it up to ask nextRun again.
+type Loop struct {
+	now      func() time.Time
+	nextRun  func(now time.Time) time.Time
+	run      func(ctx context.Context, now time.Time)
+	reload   chan struct{}
+	shutdown chan struct{}
+	wg       sync.WaitGroup
+	cancel   context.CancelFunc
+}
+
+// NewLoop creates a new evaluation loop
+func NewLoop(now func() time.Time, nextRun func(now time.Time) time.Time, run func(ctx context.Context, now time.Time)) *Loop {
+	return &Loop{
+		now:      now,
+		nextRun:  nextRun,
+		run:      run,
+		reload:   make(chan struct{}, 1),
+		shutdown: make(chan struct{}),
+	}
+}
+
+// Start runs the loop in its own goroutine
+func (l *Loop) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+
+		for {
+			now := l.now()
+			timer := time.NewTimer(l.nextRun(now).Sub(now))
+			select {
+			case <-timer.C:
+				l.run(ctx, l.now())
+			case <-l.reload:
+				timer.Stop()
+			case <-l.shutdown:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Reload wakes the loop to reschedule
+func (l *Loop) Reload() {
+	select {
+	case l.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stops the loop, cancelling an in-progress run, and waits for it to
+// return
+func (l *Loop) Stop(ctx context.Context) error {
+	close(l.shutdown)
+	if l.cancel != nil {
+		l.cancel()
+	}
+	return Wait(ctx, l.wg.Wait)
+}
+
+// Wait calls wait and returns once it returned or ctx is done
+func Wait(ctx context.Context, wait func()) error {
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package schedule
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// task is a named task scheduled by an entry
+type task struct {
+	Entry
+	name string
+}
+
+func TestDueAndNext(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	fast := &task{Entry: Entry{Interval: 10 * time.Second, Next: now}, name: "fast"}
+	slow := &task{Entry: Entry{Interval: time.Hour, Next: now.Add(30 * time.Second)}, name: "slow"}
+	tasks := []*task{fast, slow}
+
+	due := Due(now, slices.Values(tasks))
+	require.Len(t, due, 1)
+	assert.Equal(t, "fast", due[0].name)
+	assert.Equal(t, now.Add(10*time.Second), fast.Next)
+	assert.Equal(t, now.Add(10*time.Second), Next(now, slices.Values(tasks)))
+
+	// Both are due once their time has come, and are rescheduled from then
+	due = Due(now.Add(time.Minute), slices.Values(tasks))
+	assert.Len(t, due, 2)
+	assert.Equal(t, now.Add(time.Hour+time.Minute), slow.Next)
+
+	// Without tasks the loop checks back in a minute
+	assert.Equal(t, now.Add(time.Minute), Next(now, slices.Values([]*task{})))
+}
+
+func TestLoop_RunsUntilStopped(t *testing.T) {
+	var runs atomic.Int32
+	loop := NewLoop(time.Now, func(now time.Time) time.Time { return now.Add(time.Millisecond) }, func(ctx context.Context, now time.Time) {
+		runs.Add(1)
+	})
+	loop.Start()
+	assert.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, time.Millisecond)
+
+	require.NoError(t, loop.Stop(context.Background()))
+	stopped := runs.Load()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, stopped, runs.Load())
+}
+
+func TestWait_ReturnsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	block := make(chan struct{})
+	defer close(block)
+
+	assert.ErrorIs(t, Wait(ctx, func() { <-block }), context.Canceled)
+}
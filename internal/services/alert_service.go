@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+// AlertService defines the interface for managing alert rules, silences and
+// the alerts they produce
+type AlertService interface {
+	ListAlertRules(ctx context.Context) ([]*AlertRule, error)
+	GetAlertRule(ctx context.Context, id string) (*AlertRule, error)
+	CreateAlertRule(ctx context.Context, rule *AlertRule) (*AlertRule, error)
+	UpdateAlertRule(ctx context.Context, rule *AlertRule) (*AlertRule, error)
+	DeleteAlertRule(ctx context.Context, id string) error
+
+	ListSilences(ctx context.Context) ([]*Silence, error)
+	CreateSilence(ctx context.Context, silence *Silence) (*Silence, error)
+	DeleteSilence(ctx context.Context, id string) error
+
+	// ListAlerts lists the pending and firing alerts
+	ListAlerts(ctx context.Context) ([]*Alert, error)
+
+	// ReloadAlerting pushes the stored rules and silences to the evaluator
+	ReloadAlerting(ctx context.Context) error
+}
+
+// AlertEvaluator evaluates the complete rule set it receives whenever rules
+// change, suppresses notifications for alerts matching the silences it
+// receives and reports the state of each rule and alert
+type AlertEvaluator interface {
+	LoadAlertRules(rules []*AlertRule) error
+	LoadSilences(silences []*Silence) error
+	AlertRuleStatus(id string) *AlertRuleStatus
+	Alerts() []*Alert
+}
+
+// AlertRule represents a Lawrence QL expression evaluated on an interval.
+// Every sample of the expression that compares true against the threshold
+// is an alert, labelled with the sample's labels, the rule's labels and
+// alertname. An alert is pending until it has been active for the rule's
+// For duration, then firing until its sample no longer compares true.
+type AlertRule struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Expr        string            `json:"expr"`
+	Operator    string            `json:"operator"` // One of >, >=, <, <=, ==, !=
+	Threshold   float64           `json:"threshold"`
+	For         string            `json:"for,omitempty"` // Duration string like "5m"; empty fires immediately
+	Interval    string            `json:"interval"`      // Duration string like "30s", "1m"
+	Labels      map[string]string `json:"labels,omitempty"`
+	// Annotations are text/template strings rendered with .Labels and .Value
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Enabled     bool              `json:"enabled"`
+	Status      *AlertRuleStatus  `json:"status,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// AlertRuleStatus describes the last evaluation of an alert rule
+type AlertRuleStatus struct {
+	Health         RuleHealth    `json:"health"`
+	LastEvaluation *time.Time    `json:"last_evaluation,omitempty"`
+	LastDuration   time.Duration `json:"last_duration"`
+	LastError      string        `json:"last_error,omitempty"`
+	// ActiveAlerts is the number of pending and firing alerts of the rule
+	ActiveAlerts int `json:"active_alerts"`
+}
+
+// AlertState represents the lifecycle state of an alert
+type AlertState string
+
+const (
+	AlertStatePending  AlertState = "pending"
+	AlertStateFiring   AlertState = "firing"
+	AlertStateResolved AlertState = "resolved"
+)
+
+// Alert represents a single alert, identified by the fingerprint of its labels
+type Alert struct {
+	Fingerprint string            `json:"fingerprint"`
+	RuleID      string            `json:"rule_id,omitempty"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	State       AlertState        `json:"state"`
+	Value       float64           `json:"value"`
+	ActiveAt    time.Time         `json:"active_at"`
+	FiredAt     *time.Time        `json:"fired_at,omitempty"`
+	ResolvedAt  *time.Time        `json:"resolved_at,omitempty"`
+	// SilencedBy lists the IDs of the active silences matching the alert
+	SilencedBy []string `json:"silenced_by,omitempty"`
+}
+
+// Silence suppresses notifications for alerts matching all of its matchers
+// between StartsAt and EndsAt
+type Silence struct {
+	ID        string           `json:"id"`
+	Matchers  []SilenceMatcher `json:"matchers"`
+	StartsAt  time.Time        `json:"starts_at"`
+	EndsAt    time.Time        `json:"ends_at"`
+	CreatedBy string           `json:"created_by,omitempty"`
+	Comment   string           `json:"comment,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// SilenceMatcher matches an alert label. Operators are those of Lawrence QL
+// label selectors: =, !=, =~ and !~. Regular expressions are anchored.
+type SilenceMatcher struct {
+	Name     string `json:"name"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+// Active reports whether the silence is in effect at the given time
+func (s *Silence) Active(at time.Time) bool {
+	return !at.Before(s.StartsAt) && at.Before(s.EndsAt)
+}
@@ -0,0 +1,431 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/applicationstore"
+)
+
+var (
+	// alertNamePattern matches alert rule names, which become the alertname label
+	alertNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_:.-]*$`)
+	// alertLabelPattern matches the label names alert rules and silences use
+	alertLabelPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]*$`)
+)
+
+// alertOperators are the comparisons an alert rule may apply to its samples
+var alertOperators = map[string]bool{">": true, ">=": true, "<": true, "<=": true, "==": true, "!=": true}
+
+// AlertServiceImpl implements the AlertService interface
+type AlertServiceImpl struct {
+	appStore  applicationstore.ApplicationStore
+	evaluator AlertEvaluator
+	logger    *zap.Logger
+}
+
+// NewAlertService creates a new alert service. The evaluator may be nil, in
+// which case rules and silences are only persisted.
+func NewAlertService(appStore applicationstore.ApplicationStore, evaluator AlertEvaluator, logger *zap.Logger) AlertService {
+	return &AlertServiceImpl{
+		appStore:  appStore,
+		evaluator: evaluator,
+		logger:    logger,
+	}
+}
+
+// ListAlertRules lists the stored rules with the status of their last evaluation
+func (s *AlertServiceImpl) ListAlertRules(ctx context.Context) ([]*AlertRule, error) {
+	storageRules, err := s.appStore.ListAlertRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*AlertRule, 0, len(storageRules))
+	for _, rule := range storageRules {
+		rules = append(rules, s.withStatus(fromStorageAlertRule(rule)))
+	}
+	return rules, nil
+}
+
+// GetAlertRule gets an alert rule by ID
+func (s *AlertServiceImpl) GetAlertRule(ctx context.Context, id string) (*AlertRule, error) {
+	rule, err := s.appStore.GetAlertRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, nil
+	}
+	return s.withStatus(fromStorageAlertRule(rule)), nil
+}
+
+// CreateAlertRule validates and stores a new rule, then reloads the evaluated rule set
+func (s *AlertServiceImpl) CreateAlertRule(ctx context.Context, rule *AlertRule) (*AlertRule, error) {
+	if err := ValidateAlertRule(rule); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	created := *rule
+	created.ID = uuid.New().String()
+	created.Status = nil
+	created.CreatedAt = now
+	created.UpdatedAt = now
+
+	if err := s.appStore.CreateAlertRule(ctx, toStorageAlertRule(&created)); err != nil {
+		return nil, fmt.Errorf("failed to store alert rule: %w", err)
+	}
+
+	if err := s.reloadAlertRules(ctx); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Created alert rule", zap.String("rule_id", created.ID), zap.String("name", created.Name))
+	return &created, nil
+}
+
+// UpdateAlertRule validates and replaces a stored rule, then reloads the evaluated rule set
+func (s *AlertServiceImpl) UpdateAlertRule(ctx context.Context, rule *AlertRule) (*AlertRule, error) {
+	if err := ValidateAlertRule(rule); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.appStore.GetAlertRule(ctx, rule.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert rule: %w", err)
+	}
+	if existing == nil {
+		return nil, fmt.Errorf("alert rule not found")
+	}
+
+	updated := *rule
+	updated.Status = nil
+	updated.CreatedAt = existing.CreatedAt
+	updated.UpdatedAt = time.Now()
+
+	if err := s.appStore.UpdateAlertRule(ctx, toStorageAlertRule(&updated)); err != nil {
+		return nil, fmt.Errorf("failed to update alert rule: %w", err)
+	}
+
+	if err := s.reloadAlertRules(ctx); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Updated alert rule", zap.String("rule_id", updated.ID), zap.String("name", updated.Name))
+	return &updated, nil
+}
+
+// DeleteAlertRule deletes a stored rule and reloads the evaluated rule set
+func (s *AlertServiceImpl) DeleteAlertRule(ctx context.Context, id string) error {
+	existing, err := s.appStore.GetAlertRule(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get alert rule: %w", err)
+	}
+	if existing == nil {
+		return fmt.Errorf("alert rule not found")
+	}
+
+	if err := s.appStore.DeleteAlertRule(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+
+	if err := s.reloadAlertRules(ctx); err != nil {
+		return err
+	}
+
+	s.logger.Info("Deleted alert rule", zap.String("rule_id", id))
+	return nil
+}
+
+// ListSilences lists all stored silences, including expired ones
+func (s *AlertServiceImpl) ListSilences(ctx context.Context) ([]*Silence, error) {
+	storageSilences, err := s.appStore.ListSilences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	silences := make([]*Silence, 0, len(storageSilences))
+	for _, silence := range storageSilences {
+		silences = append(silences, fromStorageSilence(silence))
+	}
+	return silences, nil
+}
+
+// CreateSilence validates and stores a new silence starting now unless a
+// start is given, then reloads the silences of the evaluator
+func (s *AlertServiceImpl) CreateSilence(ctx context.Context, silence *Silence) (*Silence, error) {
+	now := time.Now()
+	created := *silence
+	created.ID = uuid.New().String()
+	created.CreatedAt = now
+	if created.StartsAt.IsZero() {
+		created.StartsAt = now
+	}
+
+	if err := ValidateSilence(&created); err != nil {
+		return nil, err
+	}
+	if !created.EndsAt.After(now) {
+		return nil, fmt.Errorf("silence end %s is in the past", created.EndsAt.Format(time.RFC3339))
+	}
+
+	if err := s.appStore.CreateSilence(ctx, toStorageSilence(&created)); err != nil {
+		return nil, fmt.Errorf("failed to store silence: %w", err)
+	}
+
+	if err := s.reloadSilences(ctx); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Created silence",
+		zap.String("silence_id", created.ID),
+		zap.Time("ends_at", created.EndsAt),
+		zap.String("created_by", created.CreatedBy))
+	return &created, nil
+}
+
+// DeleteSilence deletes a silence and reloads the silences of the evaluator
+func (s *AlertServiceImpl) DeleteSilence(ctx context.Context, id string) error {
+	silences, err := s.appStore.ListSilences(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list silences: %w", err)
+	}
+	found := false
+	for _, silence := range silences {
+		if silence.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("silence not found")
+	}
+
+	if err := s.appStore.DeleteSilence(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete silence: %w", err)
+	}
+
+	if err := s.reloadSilences(ctx); err != nil {
+		return err
+	}
+
+	s.logger.Info("Deleted silence", zap.String("silence_id", id))
+	return nil
+}
+
+// ListAlerts lists the pending and firing alerts of the evaluator
+func (s *AlertServiceImpl) ListAlerts(ctx context.Context) ([]*Alert, error) {
+	if s.evaluator == nil {
+		return []*Alert{}, nil
+	}
+	return s.evaluator.Alerts(), nil
+}
+
+// ReloadAlerting pushes the stored rules and silences to the evaluator
+func (s *AlertServiceImpl) ReloadAlerting(ctx context.Context) error {
+	if err := s.reloadAlertRules(ctx); err != nil {
+		return err
+	}
+	return s.reloadSilences(ctx)
+}
+
+// reloadAlertRules pushes the stored rules to the evaluator
+func (s *AlertServiceImpl) reloadAlertRules(ctx context.Context) error {
+	if s.evaluator == nil {
+		return nil
+	}
+
+	rules, err := s.ListAlertRules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list alert rules: %w", err)
+	}
+
+	if err := s.evaluator.LoadAlertRules(rules); err != nil {
+		return fmt.Errorf("failed to load alert rules: %w", err)
+	}
+	return nil
+}
+
+// reloadSilences pushes the stored silences to the evaluator
+func (s *AlertServiceImpl) reloadSilences(ctx context.Context) error {
+	if s.evaluator == nil {
+		return nil
+	}
+
+	silences, err := s.ListSilences(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list silences: %w", err)
+	}
+
+	if err := s.evaluator.LoadSilences(silences); err != nil {
+		return fmt.Errorf("failed to load silences: %w", err)
+	}
+	return nil
+}
+
+// withStatus sets the evaluation status of a rule from the evaluator
+func (s *AlertServiceImpl) withStatus(rule *AlertRule) *AlertRule {
+	if s.evaluator != nil {
+		rule.Status = s.evaluator.AlertRuleStatus(rule.ID)
+	}
+	return rule
+}
+
+// ValidateAlertRule checks that a rule has a valid name, expression,
+// comparison, durations, labels and annotation templates. The expression is
+// only checked to be present; it is parsed when the rule is loaded for
+// evaluation.
+func ValidateAlertRule(rule *AlertRule) error {
+	if !alertNamePattern.MatchString(rule.Name) {
+		return fmt.Errorf("invalid rule name %q, must be an alert name like HighErrorRate", rule.Name)
+	}
+	if strings.TrimSpace(rule.Expr) == "" {
+		return fmt.Errorf("rule expression is required")
+	}
+	if !alertOperators[rule.Operator] {
+		return fmt.Errorf("invalid operator %q, must be one of >, >=, <, <=, ==, !=", rule.Operator)
+	}
+
+	interval, err := time.ParseDuration(rule.Interval)
+	if err != nil {
+		return fmt.Errorf("invalid interval %q: %w", rule.Interval, err)
+	}
+	if interval < time.Second {
+		return fmt.Errorf("interval %s is too short, must be at least 1s", interval)
+	}
+	if rule.For != "" {
+		forDuration, err := time.ParseDuration(rule.For)
+		if err != nil {
+			return fmt.Errorf("invalid for duration %q: %w", rule.For, err)
+		}
+		if forDuration < 0 {
+			return fmt.Errorf("for duration %s must not be negative", forDuration)
+		}
+	}
+
+	for label := range rule.Labels {
+		if !alertLabelPattern.MatchString(label) {
+			return fmt.Errorf("invalid label name %q", label)
+		}
+		if label == "alertname" {
+			return fmt.Errorf("label alertname is reserved for the rule name")
+		}
+	}
+	for name, text := range rule.Annotations {
+		if _, err := ParseAnnotationTemplate(name, text); err != nil {
+			return fmt.Errorf("invalid annotation %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ParseAnnotationTemplate parses an alert annotation. Annotations are
+// text/template strings executed with the alert's .Labels and .Value.
+func ParseAnnotationTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=zero").Parse(text)
+}
+
+// ValidateSilence checks that a silence has valid matchers and ends after it starts
+func ValidateSilence(silence *Silence) error {
+	if len(silence.Matchers) == 0 {
+		return fmt.Errorf("silence needs at least one matcher")
+	}
+	for _, matcher := range silence.Matchers {
+		if !alertLabelPattern.MatchString(matcher.Name) {
+			return fmt.Errorf("invalid matcher label name %q", matcher.Name)
+		}
+		switch matcher.Operator {
+		case "=", "!=":
+		case "=~", "!~":
+			if _, err := regexp.Compile("^(?:" + matcher.Value + ")$"); err != nil {
+				return fmt.Errorf("invalid matcher regex %q: %w", matcher.Value, err)
+			}
+		default:
+			return fmt.Errorf("invalid matcher operator %q, must be one of =, !=, =~, !~", matcher.Operator)
+		}
+	}
+	if !silence.EndsAt.After(silence.StartsAt) {
+		return fmt.Errorf("silence must end after it starts")
+	}
+	return nil
+}
+
+// toStorageAlertRule converts a service alert rule to a storage rule
+func toStorageAlertRule(rule *AlertRule) *applicationstore.AlertRule {
+	return &applicationstore.AlertRule{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		Description: rule.Description,
+		Expr:        rule.Expr,
+		Operator:    rule.Operator,
+		Threshold:   rule.Threshold,
+		For:         rule.For,
+		Interval:    rule.Interval,
+		Labels:      rule.Labels,
+		Annotations: rule.Annotations,
+		Enabled:     rule.Enabled,
+		CreatedAt:   rule.CreatedAt,
+		UpdatedAt:   rule.UpdatedAt,
+	}
+}
+
+// fromStorageAlertRule converts a storage alert rule to a service rule
+func fromStorageAlertRule(rule *applicationstore.AlertRule) *AlertRule {
+	return &AlertRule{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		Description: rule.Description,
+		Expr:        rule.Expr,
+		Operator:    rule.Operator,
+		Threshold:   rule.Threshold,
+		For:         rule.For,
+		Interval:    rule.Interval,
+		Labels:      rule.Labels,
+		Annotations: rule.Annotations,
+		Enabled:     rule.Enabled,
+		CreatedAt:   rule.CreatedAt,
+		UpdatedAt:   rule.UpdatedAt,
+	}
+}
+
+// toStorageSilence converts a service silence to a storage silence
+func toStorageSilence(silence *Silence) *applicationstore.Silence {
+	matchers := make([]applicationstore.SilenceMatcher, len(silence.Matchers))
+	for i, matcher := range silence.Matchers {
+		matchers[i] = applicationstore.SilenceMatcher(matcher)
+	}
+	return &applicationstore.Silence{
+		ID:        silence.ID,
+		Matchers:  matchers,
+		StartsAt:  silence.StartsAt,
+		EndsAt:    silence.EndsAt,
+		CreatedBy: silence.CreatedBy,
+		Comment:   silence.Comment,
+		CreatedAt: silence.CreatedAt,
+	}
+}
+
+// fromStorageSilence converts a storage silence to a service silence
+func fromStorageSilence(silence *applicationstore.Silence) *Silence {
+	matchers := make([]SilenceMatcher, len(silence.Matchers))
+	for i, matcher := range silence.Matchers {
+		matchers[i] = SilenceMatcher(matcher)
+	}
+	return &Silence{
+		ID:        silence.ID,
+		Matchers:  matchers,
+		StartsAt:  silence.StartsAt,
+		EndsAt:    silence.EndsAt,
+		CreatedBy: silence.CreatedBy,
+		Comment:   silence.Comment,
+		CreatedAt: silence.CreatedAt,
+	}
+}
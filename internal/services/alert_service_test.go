@@ -0,0 +1,178 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/applicationstore/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// stubAlertEvaluator captures the rule sets and silences pushed by the service
+type stubAlertEvaluator struct {
+	ruleLoads    [][]*AlertRule
+	silenceLoads [][]*Silence
+}
+
+func (e *stubAlertEvaluator) LoadAlertRules(rules []*AlertRule) error {
+	e.ruleLoads = append(e.ruleLoads, rules)
+	return nil
+}
+
+func (e *stubAlertEvaluator) LoadSilences(silences []*Silence) error {
+	e.silenceLoads = append(e.silenceLoads, silences)
+	return nil
+}
+
+func (e *stubAlertEvaluator) AlertRuleStatus(id string) *AlertRuleStatus {
+	return &AlertRuleStatus{Health: RuleHealthOK, ActiveAlerts: 2}
+}
+
+func (e *stubAlertEvaluator) Alerts() []*Alert {
+	return []*Alert{{Fingerprint: "abc", State: AlertStateFiring}}
+}
+
+func makeTestAlertRule() *AlertRule {
+	return &AlertRule{
+		Name:        "HighErrorRate",
+		Expr:        `sum(rate(metrics{metric="errors_total"} [5m])) by (service)`,
+		Operator:    ">",
+		Threshold:   0.5,
+		For:         "5m",
+		Interval:    "1m",
+		Labels:      map[string]string{"severity": "page"},
+		Annotations: map[string]string{"summary": "{{ .Labels.service }} is failing at {{ .Value }}/s"},
+		Enabled:     true,
+	}
+}
+
+func TestAlertService_Rules(t *testing.T) {
+	evaluator := &stubAlertEvaluator{}
+	service := NewAlertService(memory.NewStore(), evaluator, zap.NewNop())
+	ctx := context.Background()
+
+	created, err := service.CreateAlertRule(ctx, makeTestAlertRule())
+	require.NoError(t, err)
+	require.Len(t, evaluator.ruleLoads, 1)
+	require.Len(t, evaluator.ruleLoads[0], 1)
+
+	rules, err := service.ListAlertRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.NotNil(t, rules[0].Status)
+	assert.Equal(t, 2, rules[0].Status.ActiveAlerts)
+
+	created.Threshold = 1
+	updated, err := service.UpdateAlertRule(ctx, created)
+	require.NoError(t, err)
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+
+	missing := makeTestAlertRule()
+	missing.ID = "missing"
+	_, err = service.UpdateAlertRule(ctx, missing)
+	assert.EqualError(t, err, "alert rule not found")
+
+	require.NoError(t, service.DeleteAlertRule(ctx, created.ID))
+	assert.EqualError(t, service.DeleteAlertRule(ctx, created.ID), "alert rule not found")
+	assert.Len(t, evaluator.ruleLoads, 3)
+
+	alerts, err := service.ListAlerts(ctx)
+	require.NoError(t, err)
+	assert.Len(t, alerts, 1)
+}
+
+func TestAlertService_Silences(t *testing.T) {
+	evaluator := &stubAlertEvaluator{}
+	service := NewAlertService(memory.NewStore(), evaluator, zap.NewNop())
+	ctx := context.Background()
+
+	created, err := service.CreateSilence(ctx, &Silence{
+		Matchers: []SilenceMatcher{{Name: "service", Operator: "=", Value: "api"}},
+		EndsAt:   time.Now().Add(time.Hour),
+		Comment:  "Deploy",
+	})
+	require.NoError(t, err)
+	assert.False(t, created.StartsAt.IsZero())
+	require.Len(t, evaluator.silenceLoads, 1)
+	assert.Len(t, evaluator.silenceLoads[0], 1)
+
+	_, err = service.CreateSilence(ctx, &Silence{
+		Matchers: []SilenceMatcher{{Name: "service", Operator: "=", Value: "api"}},
+		StartsAt: time.Now().Add(-2 * time.Hour),
+		EndsAt:   time.Now().Add(-time.Hour),
+	})
+	assert.ErrorContains(t, err, "in the past")
+
+	require.NoError(t, service.DeleteSilence(ctx, created.ID))
+	assert.EqualError(t, service.DeleteSilence(ctx, created.ID), "silence not found")
+	require.Len(t, evaluator.silenceLoads, 2)
+	assert.Empty(t, evaluator.silenceLoads[1])
+}
+
+func TestValidateAlertRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*AlertRule)
+		wantErr string
+	}{
+		{"valid", func(r *AlertRule) {}, ""},
+		{"fires immediately", func(r *AlertRule) { r.For = "" }, ""},
+		{"invalid name", func(r *AlertRule) { r.Name = "high error rate" }, "invalid rule name"},
+		{"missing expression", func(r *AlertRule) { r.Expr = "" }, "expression is required"},
+		{"invalid operator", func(r *AlertRule) { r.Operator = "=>" }, "invalid operator"},
+		{"short interval", func(r *AlertRule) { r.Interval = "10ms" }, "too short"},
+		{"invalid for", func(r *AlertRule) { r.For = "soon" }, "invalid for duration"},
+		{"reserved label", func(r *AlertRule) { r.Labels = map[string]string{"alertname": "x"} }, "reserved"},
+		{"invalid annotation", func(r *AlertRule) { r.Annotations = map[string]string{"summary": "{{ .Value"} }, "invalid annotation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := makeTestAlertRule()
+			tt.modify(rule)
+			err := ValidateAlertRule(rule)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSilence(t *testing.T) {
+	now := time.Now()
+	valid := func() *Silence {
+		return &Silence{
+			Matchers: []SilenceMatcher{{Name: "alertname", Operator: "=~", Value: "High.*"}},
+			StartsAt: now,
+			EndsAt:   now.Add(time.Hour),
+		}
+	}
+
+	assert.NoError(t, ValidateSilence(valid()))
+
+	silence := valid()
+	silence.Matchers = nil
+	assert.ErrorContains(t, ValidateSilence(silence), "at least one matcher")
+
+	silence = valid()
+	silence.Matchers[0].Value = "(unclosed"
+	assert.ErrorContains(t, ValidateSilence(silence), "invalid matcher regex")
+
+	silence = valid()
+	silence.Matchers[0].Operator = "~"
+	assert.ErrorContains(t, ValidateSilence(silence), "invalid matcher operator")
+
+	silence = valid()
+	silence.EndsAt = now
+	assert.ErrorContains(t, ValidateSilence(silence), "must end after it starts")
+
+	assert.True(t, valid().Active(now))
+	assert.False(t, valid().Active(now.Add(time.Hour)))
+}
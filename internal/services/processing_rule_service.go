@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+// ProcessingRuleService defines the interface for managing ingest-time processing rules
+type ProcessingRuleService interface {
+	ListProcessingRules(ctx context.Context) ([]*ProcessingRule, error)
+	GetProcessingRule(ctx context.Context, id string) (*ProcessingRule, error)
+	CreateProcessingRule(ctx context.Context, rule *ProcessingRule) (*ProcessingRule, error)
+	UpdateProcessingRule(ctx context.Context, rule *ProcessingRule) (*ProcessingRule, error)
+	DeleteProcessingRule(ctx context.Context, id string) error
+
+	// ReloadProcessingRules pushes the stored rule set to the rule loader
+	ReloadProcessingRules(ctx context.Context) error
+}
+
+// RuleLoader receives the complete rule set whenever rules change
+type RuleLoader interface {
+	LoadRules(rules []*ProcessingRule) error
+}
+
+// ProcessingRule represents an ingest-time rule applied to telemetry of a single signal.
+// All conditions must match for the actions to be applied, in order.
+type ProcessingRule struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Signal      RuleSignal      `json:"signal"`
+	Enabled     bool            `json:"enabled"`
+	Priority    int             `json:"priority"`
+	Conditions  []RuleCondition `json:"conditions"`
+	Actions     []RuleAction    `json:"actions"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// RuleSignal represents the telemetry signal a rule applies to
+type RuleSignal string
+
+const (
+	RuleSignalTraces  RuleSignal = "traces"
+	RuleSignalMetrics RuleSignal = "metrics"
+	RuleSignalLogs    RuleSignal = "logs"
+)
+
+// RuleCondition represents a single match condition.
+//
+// Field is one of service_name, agent_id, group_id, group_name, body and
+// severity_text (logs), span_name and status_code (traces), metric_name
+// (metrics), or attributes.<key> / resource.<key> for attribute lookups.
+type RuleCondition struct {
+	Field    string       `json:"field"`
+	Operator RuleOperator `json:"operator"`
+	Value    string       `json:"value,omitempty"`
+}
+
+// RuleOperator represents the comparison performed by a condition
+type RuleOperator string
+
+const (
+	RuleOperatorEquals    RuleOperator = "equals"
+	RuleOperatorNotEquals RuleOperator = "not_equals"
+	RuleOperatorRegex     RuleOperator = "regex"
+	RuleOperatorNotRegex  RuleOperator = "not_regex"
+	RuleOperatorExists    RuleOperator = "exists"
+	RuleOperatorNotExists RuleOperator = "not_exists"
+)
+
+// RuleAction represents a single action applied to matching records
+type RuleAction struct {
+	Type        RuleActionType `json:"type"`
+	Key         string         `json:"key,omitempty"`
+	NewKey      string         `json:"new_key,omitempty"`
+	Scope       RuleScope      `json:"scope,omitempty"`
+	Pattern     string         `json:"pattern,omitempty"`
+	Replacement string         `json:"replacement,omitempty"`
+}
+
+// RuleActionType represents the kind of action
+type RuleActionType string
+
+const (
+	RuleActionDrop            RuleActionType = "drop"
+	RuleActionDeleteAttribute RuleActionType = "delete_attribute"
+	RuleActionRenameAttribute RuleActionType = "rename_attribute"
+	RuleActionHashAttribute   RuleActionType = "hash_attribute"
+	RuleActionRedactAttribute RuleActionType = "redact_attribute"
+	RuleActionMaskBody        RuleActionType = "mask_body"
+)
+
+// RuleScope selects which attribute map an action operates on
+type RuleScope string
+
+const (
+	RuleScopeAttributes RuleScope = "attributes"
+	RuleScopeResource   RuleScope = "resource"
+)
+
+// DefaultRedactionText replaces redacted values when no replacement is configured
+const DefaultRedactionText = "[REDACTED]"
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/applicationstore"
+)
+
+// ProcessingRuleServiceImpl implements the ProcessingRuleService interface
+type ProcessingRuleServiceImpl struct {
+	appStore applicationstore.ApplicationStore
+	loader   RuleLoader
+	logger   *zap.Logger
+}
+
+// NewProcessingRuleService creates a new processing rule service. The loader
+// may be nil, in which case rules are only persisted.
+func NewProcessingRuleService(appStore applicationstore.ApplicationStore, loader RuleLoader, logger *zap.Logger) ProcessingRuleService {
+	return &ProcessingRuleServiceImpl{
+		appStore: appStore,
+		loader:   loader,
+		logger:   logger,
+	}
+}
+
+// ListProcessingRules lists all processing rules in evaluation order
+func (s *ProcessingRuleServiceImpl) ListProcessingRules(ctx context.Context) ([]*ProcessingRule, error) {
+	storageRules, err := s.appStore.ListProcessingRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*ProcessingRule, len(storageRules))
+	for i, rule := range storageRules {
+		rules[i] = fromStorageRule(rule)
+	}
+	return rules, nil
+}
+
+// GetProcessingRule gets a processing rule by ID
+func (s *ProcessingRuleServiceImpl) GetProcessingRule(ctx context.Context, id string) (*ProcessingRule, error) {
+	rule, err := s.appStore.GetProcessingRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, nil
+	}
+	return fromStorageRule(rule), nil
+}
+
+// CreateProcessingRule validates and stores a new rule, then reloads the active rule set
+func (s *ProcessingRuleServiceImpl) CreateProcessingRule(ctx context.Context, rule *ProcessingRule) (*ProcessingRule, error) {
+	if err := ValidateProcessingRule(rule); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	created := *rule
+	created.ID = uuid.New().String()
+	created.CreatedAt = now
+	created.UpdatedAt = now
+
+	if err := s.appStore.CreateProcessingRule(ctx, toStorageRule(&created)); err != nil {
+		return nil, fmt.Errorf("failed to store processing rule: %w", err)
+	}
+
+	if err := s.ReloadProcessingRules(ctx); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Created processing rule", zap.String("rule_id", created.ID), zap.String("name", created.Name))
+	return &created, nil
+}
+
+// UpdateProcessingRule validates and replaces an existing rule, then reloads the active rule set
+func (s *ProcessingRuleServiceImpl) UpdateProcessingRule(ctx context.Context, rule *ProcessingRule) (*ProcessingRule, error) {
+	if err := ValidateProcessingRule(rule); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.appStore.GetProcessingRule(ctx, rule.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get processing rule: %w", err)
+	}
+	if existing == nil {
+		return nil, fmt.Errorf("processing rule not found")
+	}
+
+	updated := *rule
+	updated.CreatedAt = existing.CreatedAt
+	updated.UpdatedAt = time.Now()
+
+	if err := s.appStore.UpdateProcessingRule(ctx, toStorageRule(&updated)); err != nil {
+		return nil, fmt.Errorf("failed to update processing rule: %w", err)
+	}
+
+	if err := s.ReloadProcessingRules(ctx); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Updated processing rule", zap.String("rule_id", updated.ID), zap.String("name", updated.Name))
+	return &updated, nil
+}
+
+// DeleteProcessingRule deletes a rule and reloads the active rule set
+func (s *ProcessingRuleServiceImpl) DeleteProcessingRule(ctx context.Context, id string) error {
+	existing, err := s.appStore.GetProcessingRule(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get processing rule: %w", err)
+	}
+	if existing == nil {
+		return fmt.Errorf("processing rule not found")
+	}
+
+	if err := s.appStore.DeleteProcessingRule(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete processing rule: %w", err)
+	}
+
+	if err := s.ReloadProcessingRules(ctx); err != nil {
+		return err
+	}
+
+	s.logger.Info("Deleted processing rule", zap.String("rule_id", id))
+	return nil
+}
+
+// ReloadProcessingRules pushes the stored rule set to the rule loader
+func (s *ProcessingRuleServiceImpl) ReloadProcessingRules(ctx context.Context) error {
+	if s.loader == nil {
+		return nil
+	}
+
+	rules, err := s.ListProcessingRules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list processing rules: %w", err)
+	}
+
+	if err := s.loader.LoadRules(rules); err != nil {
+		return fmt.Errorf("failed to load processing rules: %w", err)
+	}
+	return nil
+}
+
+// ValidateProcessingRule checks that a rule is well formed for its signal
+func ValidateProcessingRule(rule *ProcessingRule) error {
+	if strings.TrimSpace(rule.Name) == "" {
+		return fmt.Errorf("rule name is required")
+	}
+
+	switch rule.Signal {
+	case RuleSignalTraces, RuleSignalMetrics, RuleSignalLogs:
+	default:
+		return fmt.Errorf("invalid signal %q, must be one of traces, metrics, logs", rule.Signal)
+	}
+
+	for i, cond := range rule.Conditions {
+		if err := validateRuleCondition(rule.Signal, cond); err != nil {
+			return fmt.Errorf("condition %d: %w", i, err)
+		}
+	}
+
+	if len(rule.Actions) == 0 {
+		return fmt.Errorf("at least one action is required")
+	}
+	for i, action := range rule.Actions {
+		if err := validateRuleAction(rule.Signal, action); err != nil {
+			return fmt.Errorf("action %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// validateRuleCondition checks a single condition
+func validateRuleCondition(signal RuleSignal, cond RuleCondition) error {
+	if !IsValidRuleField(signal, cond.Field) {
+		return fmt.Errorf("field %q is not available for %s", cond.Field, signal)
+	}
+
+	switch cond.Operator {
+	case RuleOperatorEquals, RuleOperatorNotEquals, RuleOperatorExists, RuleOperatorNotExists:
+	case RuleOperatorRegex, RuleOperatorNotRegex:
+		if _, err := regexp.Compile(cond.Value); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", cond.Value, err)
+		}
+	default:
+		return fmt.Errorf("invalid operator %q", cond.Operator)
+	}
+	return nil
+}
+
+// validateRuleAction checks a single action
+func validateRuleAction(signal RuleSignal, action RuleAction) error {
+	switch action.Scope {
+	case "", RuleScopeAttributes, RuleScopeResource:
+	default:
+		return fmt.Errorf("invalid scope %q, must be attributes or resource", action.Scope)
+	}
+
+	switch action.Type {
+	case RuleActionDrop:
+	case RuleActionDeleteAttribute, RuleActionHashAttribute, RuleActionRedactAttribute:
+		if action.Key == "" {
+			return fmt.Errorf("%s requires a key", action.Type)
+		}
+	case RuleActionRenameAttribute:
+		if action.Key == "" || action.NewKey == "" {
+			return fmt.Errorf("%s requires key and new_key", action.Type)
+		}
+	case RuleActionMaskBody:
+		if signal != RuleSignalLogs {
+			return fmt.Errorf("%s is only available for logs", action.Type)
+		}
+		if action.Pattern == "" {
+			return fmt.Errorf("%s requires a pattern", action.Type)
+		}
+		if _, err := regexp.Compile(action.Pattern); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", action.Pattern, err)
+		}
+	default:
+		return fmt.Errorf("invalid action type %q", action.Type)
+	}
+	return nil
+}
+
+// IsValidRuleField reports whether a condition field can be evaluated for the signal
+func IsValidRuleField(signal RuleSignal, field string) bool {
+	if key, ok := strings.CutPrefix(field, "attributes."); ok {
+		return key != ""
+	}
+	if key, ok := strings.CutPrefix(field, "resource."); ok {
+		return key != ""
+	}
+
+	switch field {
+	case "service_name", "agent_id", "group_id", "group_name":
+		return true
+	case "body", "severity_text":
+		return signal == RuleSignalLogs
+	case "span_name", "status_code":
+		return signal == RuleSignalTraces
+	case "metric_name":
+		return signal == RuleSignalMetrics
+	}
+	return false
+}
+
+// toStorageRule converts a service rule to a storage rule
+func toStorageRule(rule *ProcessingRule) *applicationstore.ProcessingRule {
+	conditions := make([]applicationstore.RuleCondition, len(rule.Conditions))
+	for i, cond := range rule.Conditions {
+		conditions[i] = applicationstore.RuleCondition{
+			Field:    cond.Field,
+			Operator: string(cond.Operator),
+			Value:    cond.Value,
+		}
+	}
+
+	actions := make([]applicationstore.RuleAction, len(rule.Actions))
+	for i, action := range rule.Actions {
+		actions[i] = applicationstore.RuleAction{
+			Type:        string(action.Type),
+			Key:         action.Key,
+			NewKey:      action.NewKey,
+			Scope:       string(action.Scope),
+			Pattern:     action.Pattern,
+			Replacement: action.Replacement,
+		}
+	}
+
+	return &applicationstore.ProcessingRule{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		Description: rule.Description,
+		Signal:      string(rule.Signal),
+		Enabled:     rule.Enabled,
+		Priority:    rule.Priority,
+		Conditions:  conditions,
+		Actions:     actions,
+		CreatedAt:   rule.CreatedAt,
+		UpdatedAt:   rule.UpdatedAt,
+	}
+}
+
+// fromStorageRule converts a storage rule to a service rule
+func fromStorageRule(rule *applicationstore.ProcessingRule) *ProcessingRule {
+	conditions := make([]RuleCondition, len(rule.Conditions))
+	for i, cond := range rule.Conditions {
+		conditions[i] = RuleCondition{
+			Field:    cond.Field,
+			Operator: RuleOperator(cond.Operator),
+			Value:    cond.Value,
+		}
+	}
+
+	actions := make([]RuleAction, len(rule.Actions))
+	for i, action := range rule.Actions {
+		actions[i] = RuleAction{
+			Type:        RuleActionType(action.Type),
+			Key:         action.Key,
+			NewKey:      action.NewKey,
+			Scope:       RuleScope(action.Scope),
+			Pattern:     action.Pattern,
+			Replacement: action.Replacement,
+		}
+	}
+
+	return &ProcessingRule{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		Description: rule.Description,
+		Signal:      RuleSignal(rule.Signal),
+		Enabled:     rule.Enabled,
+		Priority:    rule.Priority,
+		Conditions:  conditions,
+		Actions:     actions,
+		CreatedAt:   rule.CreatedAt,
+		UpdatedAt:   rule.UpdatedAt,
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/applicationstore/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recordingLoader captures the rule sets pushed by the service
+type recordingLoader struct {
+	loads [][]*ProcessingRule
+}
+
+func (l *recordingLoader) LoadRules(rules []*ProcessingRule) error {
+	l.loads = append(l.loads, rules)
+	return nil
+}
+
+func makeTestRule() *ProcessingRule {
+	return &ProcessingRule{
+		Name:    "strip-auth",
+		Signal:  RuleSignalTraces,
+		Enabled: true,
+		Actions: []RuleAction{
+			{Type: RuleActionDeleteAttribute, Key: "http.request.header.authorization"},
+		},
+	}
+}
+
+func TestProcessingRuleService_CreateReloadsRules(t *testing.T) {
+	loader := &recordingLoader{}
+	service := NewProcessingRuleService(memory.NewStore(), loader, zap.NewNop())
+
+	created, err := service.CreateProcessingRule(context.Background(), makeTestRule())
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	assert.False(t, created.CreatedAt.IsZero())
+
+	require.Len(t, loader.loads, 1)
+	require.Len(t, loader.loads[0], 1)
+	assert.Equal(t, created.ID, loader.loads[0][0].ID)
+}
+
+func TestProcessingRuleService_UpdateAndDelete(t *testing.T) {
+	loader := &recordingLoader{}
+	service := NewProcessingRuleService(memory.NewStore(), loader, zap.NewNop())
+	ctx := context.Background()
+
+	created, err := service.CreateProcessingRule(ctx, makeTestRule())
+	require.NoError(t, err)
+
+	created.Enabled = false
+	updated, err := service.UpdateProcessingRule(ctx, created)
+	require.NoError(t, err)
+	assert.False(t, updated.Enabled)
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+
+	require.NoError(t, service.DeleteProcessingRule(ctx, created.ID))
+	assert.Len(t, loader.loads, 3)
+	assert.Empty(t, loader.loads[2])
+
+	err = service.DeleteProcessingRule(ctx, created.ID)
+	require.Error(t, err)
+	assert.Equal(t, "processing rule not found", err.Error())
+}
+
+func TestProcessingRuleService_RejectsInvalidRule(t *testing.T) {
+	loader := &recordingLoader{}
+	service := NewProcessingRuleService(memory.NewStore(), loader, zap.NewNop())
+
+	rule := makeTestRule()
+	rule.Signal = "events"
+	_, err := service.CreateProcessingRule(context.Background(), rule)
+	require.Error(t, err)
+	assert.Empty(t, loader.loads)
+}
+
+func TestValidateProcessingRule(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(rule *ProcessingRule)
+		errMsg string
+	}{
+		{"valid", func(rule *ProcessingRule) {}, ""},
+		{"missing name", func(rule *ProcessingRule) { rule.Name = " " }, "name is required"},
+		{"no actions", func(rule *ProcessingRule) { rule.Actions = nil }, "at least one action"},
+		{"field for other signal", func(rule *ProcessingRule) {
+			rule.Conditions = []RuleCondition{{Field: "body", Operator: RuleOperatorExists}}
+		}, "not available for traces"},
+		{"bad regex", func(rule *ProcessingRule) {
+			rule.Conditions = []RuleCondition{{Field: "span_name", Operator: RuleOperatorRegex, Value: "("}}
+		}, "invalid regex"},
+		{"bad operator", func(rule *ProcessingRule) {
+			rule.Conditions = []RuleCondition{{Field: "span_name", Operator: "contains"}}
+		}, "invalid operator"},
+		{"rename without new key", func(rule *ProcessingRule) {
+			rule.Actions = []RuleAction{{Type: RuleActionRenameAttribute, Key: "a"}}
+		}, "requires key and new_key"},
+		{"mask body on traces", func(rule *ProcessingRule) {
+			rule.Actions = []RuleAction{{Type: RuleActionMaskBody, Pattern: "x"}}
+		}, "only available for logs"},
+		{"bad scope", func(rule *ProcessingRule) {
+			rule.Actions = []RuleAction{{Type: RuleActionDeleteAttribute, Key: "a", Scope: "scope"}}
+		}, "invalid scope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := makeTestRule()
+			tt.modify(rule)
+			err := ValidateProcessingRule(rule)
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
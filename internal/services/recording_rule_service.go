@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+// RecordingRuleService defines the interface for managing recording rules
+type RecordingRuleService interface {
+	ListRecordingRules(ctx context.Context) ([]*RecordingRule, error)
+	GetRecordingRule(ctx context.Context, id string) (*RecordingRule, error)
+	CreateRecordingRule(ctx context.Context, rule *RecordingRule) (*RecordingRule, error)
+	UpdateRecordingRule(ctx context.Context, rule *RecordingRule) (*RecordingRule, error)
+	DeleteRecordingRule(ctx context.Context, id string) error
+
+	// ReloadRecordingRules pushes the configured and stored rules to the evaluator
+	ReloadRecordingRules(ctx context.Context) error
+}
+
+// RecordingRuleEvaluator evaluates the complete rule set it receives whenever
+// rules change and reports the status of each rule
+type RecordingRuleEvaluator interface {
+	LoadRecordingRules(rules []*RecordingRule) error
+	RecordingRuleStatus(id string) *RecordingRuleStatus
+}
+
+// RecordingRule represents a Lawrence QL expression evaluated on an interval.
+// Each evaluation writes the samples of the expression as a gauge named after
+// the rule, keeping their labels and adding the rule's labels.
+type RecordingRule struct {
+	ID          string               `json:"id"`
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	Expr        string               `json:"expr"`
+	Interval    string               `json:"interval"` // Duration string like "30s", "1m"
+	Labels      map[string]string    `json:"labels,omitempty"`
+	Enabled     bool                 `json:"enabled"`
+	Source      RecordingRuleSource  `json:"source"`
+	Status      *RecordingRuleStatus `json:"status,omitempty"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+// RecordingRuleSource represents where a recording rule is defined
+type RecordingRuleSource string
+
+const (
+	// RecordingRuleSourceConfig rules come from the configuration file and are read-only
+	RecordingRuleSourceConfig RecordingRuleSource = "config"
+	// RecordingRuleSourceAPI rules are managed through the API
+	RecordingRuleSourceAPI RecordingRuleSource = "api"
+)
+
+// RuleHealth represents the outcome of the last evaluation of a rule
+type RuleHealth string
+
+const (
+	RuleHealthUnknown RuleHealth = "unknown"
+	RuleHealthOK      RuleHealth = "ok"
+	RuleHealthError   RuleHealth = "error"
+)
+
+// RecordingRuleStatus describes the last evaluation of a recording rule
+type RecordingRuleStatus struct {
+	Health         RuleHealth    `json:"health"`
+	LastEvaluation *time.Time    `json:"last_evaluation,omitempty"`
+	LastDuration   time.Duration `json:"last_duration"`
+	LastError      string        `json:"last_error,omitempty"`
+	// Samples is the number of samples the last evaluation wrote
+	Samples int `json:"samples"`
+}
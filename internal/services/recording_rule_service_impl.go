@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/applicationstore"
+)
+
+var (
+	// recordingRuleNamePattern matches the names recording rules may write,
+	// such as service:requests:rate5m or http.server.errors
+	recordingRuleNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:.]*$`)
+	// recordingRuleLabelPattern matches the label names recording rules may set
+	recordingRuleLabelPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]*$`)
+)
+
+// RecordingRuleServiceImpl implements the RecordingRuleService interface
+type RecordingRuleServiceImpl struct {
+	appStore    applicationstore.ApplicationStore
+	configRules []*RecordingRule
+	evaluator   RecordingRuleEvaluator
+	logger      *zap.Logger
+}
+
+// NewRecordingRuleService creates a new recording rule service. Config rules
+// are listed alongside stored rules but cannot be changed through the
+// service. The evaluator may be nil, in which case rules are only persisted.
+func NewRecordingRuleService(appStore applicationstore.ApplicationStore, configRules []*RecordingRule, evaluator RecordingRuleEvaluator, logger *zap.Logger) RecordingRuleService {
+	rules := make([]*RecordingRule, len(configRules))
+	for i, rule := range configRules {
+		configRule := *rule
+		configRule.Source = RecordingRuleSourceConfig
+		rules[i] = &configRule
+	}
+	return &RecordingRuleServiceImpl{
+		appStore:    appStore,
+		configRules: rules,
+		evaluator:   evaluator,
+		logger:      logger,
+	}
+}
+
+// ListRecordingRules lists the config rules followed by the stored rules,
+// with the status of their last evaluation
+func (s *RecordingRuleServiceImpl) ListRecordingRules(ctx context.Context) ([]*RecordingRule, error) {
+	storageRules, err := s.appStore.ListRecordingRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*RecordingRule, 0, len(s.configRules)+len(storageRules))
+	for _, rule := range s.configRules {
+		configRule := *rule
+		rules = append(rules, &configRule)
+	}
+	for _, rule := range storageRules {
+		rules = append(rules, fromStorageRecordingRule(rule))
+	}
+	for _, rule := range rules {
+		s.withStatus(rule)
+	}
+	return rules, nil
+}
+
+// GetRecordingRule gets a recording rule by ID
+func (s *RecordingRuleServiceImpl) GetRecordingRule(ctx context.Context, id string) (*RecordingRule, error) {
+	if rule := s.configRule(id); rule != nil {
+		configRule := *rule
+		return s.withStatus(&configRule), nil
+	}
+
+	rule, err := s.appStore.GetRecordingRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, nil
+	}
+	return s.withStatus(fromStorageRecordingRule(rule)), nil
+}
+
+// CreateRecordingRule validates and stores a new rule, then reloads the evaluated rule set
+func (s *RecordingRuleServiceImpl) CreateRecordingRule(ctx context.Context, rule *RecordingRule) (*RecordingRule, error) {
+	if err := ValidateRecordingRule(rule); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	created := *rule
+	created.ID = uuid.New().String()
+	created.Source = RecordingRuleSourceAPI
+	created.Status = nil
+	created.CreatedAt = now
+	created.UpdatedAt = now
+
+	if err := s.appStore.CreateRecordingRule(ctx, toStorageRecordingRule(&created)); err != nil {
+		return nil, fmt.Errorf("failed to store recording rule: %w", err)
+	}
+
+	if err := s.ReloadRecordingRules(ctx); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Created recording rule", zap.String("rule_id", created.ID), zap.String("name", created.Name))
+	return &created, nil
+}
+
+// UpdateRecordingRule validates and replaces a stored rule, then reloads the evaluated rule set
+func (s *RecordingRuleServiceImpl) UpdateRecordingRule(ctx context.Context, rule *RecordingRule) (*RecordingRule, error) {
+	if err := ValidateRecordingRule(rule); err != nil {
+		return nil, err
+	}
+	if s.configRule(rule.ID) != nil {
+		return nil, fmt.Errorf("recording rule is defined in configuration")
+	}
+
+	existing, err := s.appStore.GetRecordingRule(ctx, rule.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recording rule: %w", err)
+	}
+	if existing == nil {
+		return nil, fmt.Errorf("recording rule not found")
+	}
+
+	updated := *rule
+	updated.Source = RecordingRuleSourceAPI
+	updated.Status = nil
+	updated.CreatedAt = existing.CreatedAt
+	updated.UpdatedAt = time.Now()
+
+	if err := s.appStore.UpdateRecordingRule(ctx, toStorageRecordingRule(&updated)); err != nil {
+		return nil, fmt.Errorf("failed to update recording rule: %w", err)
+	}
+
+	if err := s.ReloadRecordingRules(ctx); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Updated recording rule", zap.String("rule_id", updated.ID), zap.String("name", updated.Name))
+	return &updated, nil
+}
+
+// DeleteRecordingRule deletes a stored rule and reloads the evaluated rule set
+func (s *RecordingRuleServiceImpl) DeleteRecordingRule(ctx context.Context, id string) error {
+	if s.configRule(id) != nil {
+		return fmt.Errorf("recording rule is defined in configuration")
+	}
+
+	existing, err := s.appStore.GetRecordingRule(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get recording rule: %w", err)
+	}
+	if existing == nil {
+		return fmt.Errorf("recording rule not found")
+	}
+
+	if err := s.appStore.DeleteRecordingRule(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete recording rule: %w", err)
+	}
+
+	if err := s.ReloadRecordingRules(ctx); err != nil {
+		return err
+	}
+
+	s.logger.Info("Deleted recording rule", zap.String("rule_id", id))
+	return nil
+}
+
+// ReloadRecordingRules pushes the configured and stored rules to the evaluator
+func (s *RecordingRuleServiceImpl) ReloadRecordingRules(ctx context.Context) error {
+	if s.evaluator == nil {
+		return nil
+	}
+
+	rules, err := s.ListRecordingRules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list recording rules: %w", err)
+	}
+
+	if err := s.evaluator.LoadRecordingRules(rules); err != nil {
+		return fmt.Errorf("failed to load recording rules: %w", err)
+	}
+	return nil
+}
+
+// configRule returns the config rule with the given ID, if any
+func (s *RecordingRuleServiceImpl) configRule(id string) *RecordingRule {
+	for _, rule := range s.configRules {
+		if rule.ID == id {
+			return rule
+		}
+	}
+	return nil
+}
+
+// withStatus sets the evaluation status of a rule from the evaluator
+func (s *RecordingRuleServiceImpl) withStatus(rule *RecordingRule) *RecordingRule {
+	if s.evaluator != nil {
+		rule.Status = s.evaluator.RecordingRuleStatus(rule.ID)
+	}
+	return rule
+}
+
+// ValidateRecordingRule checks that a rule has a valid name, expression,
+// interval and labels. The expression is only checked to be present; it is
+// parsed when the rule is loaded for evaluation.
+func ValidateRecordingRule(rule *RecordingRule) error {
+	if !recordingRuleNamePattern.MatchString(rule.Name) {
+		return fmt.Errorf("invalid rule name %q, must be a metric name like service:requests:rate5m", rule.Name)
+	}
+	if strings.TrimSpace(rule.Expr) == "" {
+		return fmt.Errorf("rule expression is required")
+	}
+
+	interval, err := time.ParseDuration(rule.Interval)
+	if err != nil {
+		return fmt.Errorf("invalid interval %q: %w", rule.Interval, err)
+	}
+	if interval < time.Second {
+		return fmt.Errorf("interval %s is too short, must be at least 1s", interval)
+	}
+
+	for label := range rule.Labels {
+		if !recordingRuleLabelPattern.MatchString(label) {
+			return fmt.Errorf("invalid label name %q", label)
+		}
+		if label == "name" {
+			return fmt.Errorf("label name is reserved for the rule name")
+		}
+	}
+	return nil
+}
+
+// toStorageRecordingRule converts a service recording rule to a storage rule
+func toStorageRecordingRule(rule *RecordingRule) *applicationstore.RecordingRule {
+	return &applicationstore.RecordingRule{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		Description: rule.Description,
+		Expr:        rule.Expr,
+		Interval:    rule.Interval,
+		Labels:      rule.Labels,
+		Enabled:     rule.Enabled,
+		CreatedAt:   rule.CreatedAt,
+		UpdatedAt:   rule.UpdatedAt,
+	}
+}
+
+// fromStorageRecordingRule converts a storage recording rule to a service rule
+func fromStorageRecordingRule(rule *applicationstore.RecordingRule) *RecordingRule {
+	return &RecordingRule{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		Description: rule.Description,
+		Expr:        rule.Expr,
+		Interval:    rule.Interval,
+		Labels:      rule.Labels,
+		Enabled:     rule.Enabled,
+		Source:      RecordingRuleSourceAPI,
+		CreatedAt:   rule.CreatedAt,
+		UpdatedAt:   rule.UpdatedAt,
+	}
+}
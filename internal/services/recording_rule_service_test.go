@@ -0,0 +1,105 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/applicationstore/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// stubEvaluator captures the recording rule sets pushed by the service
+type stubEvaluator struct {
+	loads [][]*RecordingRule
+}
+
+func (e *stubEvaluator) LoadRecordingRules(rules []*RecordingRule) error {
+	e.loads = append(e.loads, rules)
+	return nil
+}
+
+func (e *stubEvaluator) RecordingRuleStatus(id string) *RecordingRuleStatus {
+	return &RecordingRuleStatus{Health: RuleHealthOK, Samples: 3}
+}
+
+func makeTestRecordingRule() *RecordingRule {
+	return &RecordingRule{
+		Name:     "service:requests:rate5m",
+		Expr:     `sum(rate(metrics{metric="requests_total"} [5m])) by (service)`,
+		Interval: "1m",
+		Labels:   map[string]string{"team": "core"},
+		Enabled:  true,
+	}
+}
+
+func TestRecordingRuleService_ConfigAndStoredRules(t *testing.T) {
+	evaluator := &stubEvaluator{}
+	configRule := makeTestRecordingRule()
+	configRule.ID = "config-service:requests:rate5m"
+	service := NewRecordingRuleService(memory.NewStore(), []*RecordingRule{configRule}, evaluator, zap.NewNop())
+	ctx := context.Background()
+
+	created, err := service.CreateRecordingRule(ctx, makeTestRecordingRule())
+	require.NoError(t, err)
+	assert.Equal(t, RecordingRuleSourceAPI, created.Source)
+
+	// Every change pushes config and stored rules to the evaluator
+	require.Len(t, evaluator.loads, 1)
+	require.Len(t, evaluator.loads[0], 2)
+	assert.Equal(t, RecordingRuleSourceConfig, evaluator.loads[0][0].Source)
+
+	rules, err := service.ListRecordingRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, configRule.ID, rules[0].ID)
+	require.NotNil(t, rules[1].Status)
+	assert.Equal(t, 3, rules[1].Status.Samples)
+
+	// Config rules are read-only
+	_, err = service.UpdateRecordingRule(ctx, rules[0])
+	assert.EqualError(t, err, "recording rule is defined in configuration")
+	assert.EqualError(t, service.DeleteRecordingRule(ctx, configRule.ID), "recording rule is defined in configuration")
+
+	created.Interval = "5m"
+	updated, err := service.UpdateRecordingRule(ctx, created)
+	require.NoError(t, err)
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+
+	require.NoError(t, service.DeleteRecordingRule(ctx, created.ID))
+	assert.EqualError(t, service.DeleteRecordingRule(ctx, created.ID), "recording rule not found")
+	assert.Len(t, evaluator.loads, 3)
+}
+
+func TestValidateRecordingRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*RecordingRule)
+		wantErr string
+	}{
+		{"valid", func(r *RecordingRule) {}, ""},
+		{"dotted name", func(r *RecordingRule) { r.Name = "http.server.error_ratio" }, ""},
+		{"invalid name", func(r *RecordingRule) { r.Name = "5xx rate" }, "invalid rule name"},
+		{"missing expression", func(r *RecordingRule) { r.Expr = " " }, "expression is required"},
+		{"invalid interval", func(r *RecordingRule) { r.Interval = "often" }, "invalid interval"},
+		{"short interval", func(r *RecordingRule) { r.Interval = "100ms" }, "too short"},
+		{"invalid label", func(r *RecordingRule) { r.Labels = map[string]string{"bad-label": "x"} }, "invalid label name"},
+		{"reserved label", func(r *RecordingRule) { r.Labels = map[string]string{"name": "x"} }, "reserved"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := makeTestRecordingRule()
+			tt.modify(rule)
+			err := ValidateRecordingRule(rule)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"go.uber.org/zap"
@@ -27,17 +28,7 @@ func NewTelemetryQueryService(telemetryReader telemetrystore.Reader, agentServic
 
 // QueryMetrics queries metrics data
 func (s *TelemetryQueryServiceImpl) QueryMetrics(ctx context.Context, query MetricQuery) ([]Metric, error) {
-	// Convert service query to storage query
-	storageQuery := telemetrystore.MetricQuery{
-		AgentID:    query.AgentID,
-		GroupID:    query.GroupID,
-		MetricName: query.MetricName,
-		StartTime:  query.StartTime,
-		EndTime:    query.EndTime,
-		Limit:      query.Limit,
-	}
-
-	storageMetrics, err := s.telemetryReader.QueryMetrics(ctx, storageQuery)
+	storageMetrics, err := s.telemetryReader.QueryMetrics(ctx, storageMetricQuery(query))
 	if err != nil {
 		return nil, err
 	}
@@ -56,6 +47,18 @@ func (s *TelemetryQueryServiceImpl) QueryMetrics(ctx context.Context, query Metr
 			MetricAttributes: metric.MetricAttributes,
 			Labels:           metric.Labels,
 			Type:             MetricType(metric.Type),
+			StartTime:        metric.StartTime,
+			Unit:             metric.Unit,
+			ScopeName:        metric.ScopeName,
+			ScopeVersion:     metric.ScopeVersion,
+			Temporality:      metric.Temporality,
+			IsMonotonic:      metric.IsMonotonic,
+			Count:            metric.Count,
+			Sum:              metric.Sum,
+			BucketCounts:     metric.BucketCounts,
+			ExplicitBounds:   metric.ExplicitBounds,
+			Exponential:      metric.Exponential,
+			Quantiles:        metric.Quantiles,
 		}
 	}
 
@@ -64,18 +67,7 @@ func (s *TelemetryQueryServiceImpl) QueryMetrics(ctx context.Context, query Metr
 
 // QueryLogs queries logs data
 func (s *TelemetryQueryServiceImpl) QueryLogs(ctx context.Context, query LogQuery) ([]Log, error) {
-	// Convert service query to storage query
-	storageQuery := telemetrystore.LogQuery{
-		AgentID:   query.AgentID,
-		GroupID:   query.GroupID,
-		Severity:  query.Severity,
-		Search:    query.Search,
-		StartTime: query.StartTime,
-		EndTime:   query.EndTime,
-		Limit:     query.Limit,
-	}
-
-	storageLogs, err := s.telemetryReader.QueryLogs(ctx, storageQuery)
+	storageLogs, err := s.telemetryReader.QueryLogs(ctx, storageLogQuery(query))
 	if err != nil {
 		return nil, err
 	}
@@ -95,12 +87,112 @@ func (s *TelemetryQueryServiceImpl) QueryLogs(ctx context.Context, query LogQuer
 			SpanID:         log.SpanID,
 			LogAttributes:  log.LogAttributes,
 			ConfigHash:     log.ConfigHash,
+			ScopeName:      log.ScopeName,
+			ScopeVersion:   log.ScopeVersion,
+			Flags:          log.Flags,
 		}
 	}
 
 	return logs, nil
 }
 
+// storageMetricQuery converts a service metric query to a storage query
+func storageMetricQuery(query MetricQuery) telemetrystore.MetricQuery {
+	return telemetrystore.MetricQuery{
+		AgentID:     query.AgentID,
+		GroupID:     query.GroupID,
+		MetricName:  query.MetricName,
+		Unit:        query.Unit,
+		ScopeName:   query.ScopeName,
+		Temporality: query.Temporality,
+		Filters:     storageFilters(query.Filters),
+		StartTime:   query.StartTime,
+		EndTime:     query.EndTime,
+		Limit:       query.Limit,
+	}
+}
+
+// storageLogQuery converts a service log query to a storage query
+func storageLogQuery(query LogQuery) telemetrystore.LogQuery {
+	return telemetrystore.LogQuery{
+		AgentID:   query.AgentID,
+		GroupID:   query.GroupID,
+		Severity:  query.Severity,
+		Search:    query.Search,
+		Filters:   storageFilters(query.Filters),
+		StartTime: query.StartTime,
+		EndTime:   query.EndTime,
+		Limit:     query.Limit,
+	}
+}
+
+// storageFilters converts service filters to storage filters
+func storageFilters(filters []Filter) []telemetrystore.Filter {
+	if len(filters) == 0 {
+		return nil
+	}
+	converted := make([]telemetrystore.Filter, len(filters))
+	for i, filter := range filters {
+		converted[i] = telemetrystore.Filter{
+			Field:    filter.Field,
+			Operator: telemetrystore.FilterOperator(filter.Operator),
+			Value:    filter.Value,
+			Numeric:  filter.Numeric,
+		}
+	}
+	return converted
+}
+
+// AggregateMetrics aggregates the metrics matching a query in the store
+func (s *TelemetryQueryServiceImpl) AggregateMetrics(ctx context.Context, query MetricAggregateQuery) ([]Aggregate, error) {
+	storageAggregates, err := s.telemetryReader.AggregateMetrics(ctx, telemetrystore.MetricAggregateQuery{
+		Query:    storageMetricQuery(query.Query),
+		Function: telemetrystore.AggregateFunction(query.Function),
+		By:       query.By,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return serviceAggregates(storageAggregates), nil
+}
+
+// AggregateLogs aggregates the logs matching a query in the store
+func (s *TelemetryQueryServiceImpl) AggregateLogs(ctx context.Context, query LogAggregateQuery) ([]Aggregate, error) {
+	storageAggregates, err := s.telemetryReader.AggregateLogs(ctx, telemetrystore.LogAggregateQuery{
+		Query:    storageLogQuery(query.Query),
+		Function: telemetrystore.AggregateFunction(query.Function),
+		By:       query.By,
+		BySeries: query.BySeries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return serviceAggregates(storageAggregates), nil
+}
+
+// serviceAggregates converts storage aggregates to service aggregates
+func serviceAggregates(storageAggregates []telemetrystore.Aggregate) []Aggregate {
+	aggregates := make([]Aggregate, len(storageAggregates))
+	for i, aggregate := range storageAggregates {
+		aggregates[i] = Aggregate(aggregate)
+	}
+	return aggregates
+}
+
+// QueryMetricIncrease summarizes the points of each series of a metrics query
+// for rate and increase
+func (s *TelemetryQueryServiceImpl) QueryMetricIncrease(ctx context.Context, query MetricQuery) ([]MetricIncrease, error) {
+	increases, err := s.telemetryReader.QueryMetricIncrease(ctx, storageMetricQuery(query))
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]MetricIncrease, len(increases))
+	for i, increase := range increases {
+		converted[i] = MetricIncrease(increase)
+	}
+	return converted, nil
+}
+
 // QueryTraces queries traces data
 func (s *TelemetryQueryServiceImpl) QueryTraces(ctx context.Context, query TraceQuery) ([]Trace, error) {
 	// Convert service query to storage query
@@ -118,13 +210,18 @@ func (s *TelemetryQueryServiceImpl) QueryTraces(ctx context.Context, query Trace
 		return nil, err
 	}
 
-	// Convert storage traces to service traces
+	return serviceTraces(storageTraces), nil
+}
+
+// serviceTraces converts storage traces to service traces
+func serviceTraces(storageTraces []telemetrystore.Trace) []Trace {
 	traces := make([]Trace, len(storageTraces))
 	for i, trace := range storageTraces {
 		traces[i] = Trace{
 			Timestamp:     trace.Timestamp,
 			AgentID:       trace.AgentID,
 			ConfigHash:    trace.ConfigHash,
+			ServiceName:   trace.ServiceName,
 			TraceID:       trace.TraceID,
 			SpanID:        trace.SpanID,
 			ParentSpanID:  trace.ParentSpanID,
@@ -133,10 +230,51 @@ func (s *TelemetryQueryServiceImpl) QueryTraces(ctx context.Context, query Trace
 			StatusCode:    trace.StatusCode,
 			StatusMessage: trace.StatusMessage,
 			Attributes:    trace.Attributes,
+			SpanKind:      trace.SpanKind,
+			TraceState:    trace.TraceState,
+			ScopeName:     trace.ScopeName,
+			ScopeVersion:  trace.ScopeVersion,
 		}
 	}
+	return traces
+}
+
+// SearchTraces searches for traces with spans in a span set
+func (s *TelemetryQueryServiceImpl) SearchTraces(ctx context.Context, query TraceSearchQuery) ([]TraceMatch, error) {
+	storageMatches, err := s.telemetryReader.SearchTraces(ctx, telemetrystore.TraceSearchQuery{
+		AgentID:   query.AgentID,
+		GroupID:   query.GroupID,
+		Spans:     storageSpanSet(query.Spans),
+		StartTime: query.StartTime,
+		EndTime:   query.EndTime,
+		Limit:     query.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]TraceMatch, len(storageMatches))
+	for i, match := range storageMatches {
+		matches[i] = TraceMatch{TraceID: match.TraceID, Spans: serviceTraces(match.Spans)}
+	}
+	return matches, nil
+}
 
-	return traces, nil
+// storageSpanSet converts a service span set to a storage span set
+func storageSpanSet(spans SpanSet) telemetrystore.SpanSet {
+	converted := telemetrystore.SpanSet{
+		Filters:  storageFilters(spans.Filters),
+		Operator: telemetrystore.StructuralOperator(spans.Operator),
+	}
+	if spans.Left != nil {
+		left := storageSpanSet(*spans.Left)
+		converted.Left = &left
+	}
+	if spans.Right != nil {
+		right := storageSpanSet(*spans.Right)
+		converted.Right = &right
+	}
+	return converted
 }
 
 // QueryRaw executes a raw SQL query
@@ -170,25 +308,139 @@ func (s *TelemetryQueryServiceImpl) QueryRollups(ctx context.Context, query Roll
 	rollups := make([]Rollup, len(storageRollups))
 	for i, rollup := range storageRollups {
 		rollups[i] = Rollup{
-			WindowStart: rollup.WindowStart,
-			AgentID:     rollup.AgentID,
-			GroupID:     rollup.GroupID,
-			MetricName:  rollup.MetricName,
-			Count:       rollup.Count,
-			Sum:         rollup.Sum,
-			Avg:         rollup.Avg,
-			Min:         rollup.Min,
-			Max:         rollup.Max,
-			Interval:    RollupInterval(rollup.Interval),
+			WindowStart:    rollup.WindowStart,
+			AgentID:        rollup.AgentID,
+			GroupID:        rollup.GroupID,
+			MetricName:     rollup.MetricName,
+			Count:          rollup.Count,
+			Sum:            rollup.Sum,
+			Avg:            rollup.Avg,
+			Min:            rollup.Min,
+			Max:            rollup.Max,
+			Interval:       RollupInterval(rollup.Interval),
+			MetricType:     rollup.MetricType,
+			BucketCounts:   rollup.BucketCounts,
+			ExplicitBounds: rollup.ExplicitBounds,
+			Exponential:    rollup.Exponential,
+			Quantiles:      rollup.Quantiles,
 		}
 	}
 
 	return rollups, nil
 }
 
-// CleanupOldData cleans up old telemetry data
-func (s *TelemetryQueryServiceImpl) CleanupOldData(ctx context.Context, retention time.Duration) error {
-	return s.telemetryReader.CleanupOldData(ctx, retention)
+// GetRollupWatermark returns the end of the last generated window of an interval
+func (s *TelemetryQueryServiceImpl) GetRollupWatermark(ctx context.Context, interval RollupInterval) (time.Time, error) {
+	return s.telemetryReader.GetRollupWatermark(ctx, telemetrystore.RollupInterval(interval))
+}
+
+// SetRollupWatermark records the end of the last generated window of an interval
+func (s *TelemetryQueryServiceImpl) SetRollupWatermark(ctx context.Context, interval RollupInterval, completedUntil time.Time) error {
+	return s.telemetryReader.SetRollupWatermark(ctx, telemetrystore.RollupInterval(interval), completedUntil)
+}
+
+// GetTopUsage returns the top ingestion consumers for the requested dimension
+func (s *TelemetryQueryServiceImpl) GetTopUsage(ctx context.Context, query UsageQuery) ([]UsageSummary, error) {
+	// Convert service query to storage query
+	storageQuery := telemetrystore.UsageQuery{
+		GroupBy:   telemetrystore.UsageGroupBy(query.GroupBy),
+		OrderBy:   telemetrystore.UsageOrderBy(query.OrderBy),
+		Signal:    query.Signal,
+		AgentID:   query.AgentID,
+		GroupID:   query.GroupID,
+		StartTime: query.StartTime,
+		EndTime:   query.EndTime,
+		Limit:     query.Limit,
+	}
+
+	storageSummaries, err := s.telemetryReader.QueryTopUsage(ctx, storageQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert storage summaries to service summaries
+	summaries := make([]UsageSummary, len(storageSummaries))
+	for i, summary := range storageSummaries {
+		signals := make(map[string]SignalUsage, len(summary.Signals))
+		for signal, usage := range summary.Signals {
+			signals[signal] = SignalUsage{Records: usage.Records, Bytes: usage.Bytes}
+		}
+		summaries[i] = UsageSummary{
+			Key:       summary.Key,
+			GroupName: summary.GroupName,
+			Records:   summary.Records,
+			Bytes:     summary.Bytes,
+			Signals:   signals,
+		}
+	}
+
+	return summaries, nil
+}
+
+// SealPartitions moves whole days of raw telemetry before the given time to cold storage
+func (s *TelemetryQueryServiceImpl) SealPartitions(ctx context.Context, before time.Time) ([]Partition, error) {
+	storagePartitions, err := s.telemetryReader.SealPartitions(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := make([]Partition, len(storagePartitions))
+	for i, partition := range storagePartitions {
+		partitions[i] = Partition(partition)
+	}
+	return partitions, nil
+}
+
+// Export streams raw telemetry matching the query to w
+func (s *TelemetryQueryServiceImpl) Export(ctx context.Context, query ExportQuery, w io.Writer) (int64, error) {
+	storageQuery := telemetrystore.ExportQuery{
+		Signal:      telemetrystore.ExportSignal(query.Signal),
+		Format:      telemetrystore.ExportFormat(query.Format),
+		AgentID:     query.AgentID,
+		GroupID:     query.GroupID,
+		ServiceName: query.ServiceName,
+		StartTime:   query.StartTime,
+		EndTime:     query.EndTime,
+	}
+	return s.telemetryReader.Export(ctx, storageQuery, w)
+}
+
+// ApplyRetention deletes telemetry that falls outside the retention policy
+func (s *TelemetryQueryServiceImpl) ApplyRetention(ctx context.Context, policy RetentionPolicy, dryRun bool) (*RetentionReport, error) {
+	// Convert service policy to storage policy
+	storagePolicy := telemetrystore.RetentionPolicy{
+		RawMetrics:   policy.RawMetrics,
+		RawLogs:      policy.RawLogs,
+		RawTraces:    policy.RawTraces,
+		Rollups:      make(map[telemetrystore.RollupInterval]time.Duration, len(policy.Rollups)),
+		Usage:        policy.Usage,
+		MaxDiskBytes: policy.MaxDiskBytes,
+	}
+	for interval, retention := range policy.Rollups {
+		storagePolicy.Rollups[telemetrystore.RollupInterval(interval)] = retention
+	}
+	for _, override := range policy.Overrides {
+		storagePolicy.Overrides = append(storagePolicy.Overrides, telemetrystore.RetentionOverride(override))
+	}
+
+	storageReport, err := s.telemetryReader.ApplyRetention(ctx, storagePolicy, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert storage report to service report
+	report := &RetentionReport{
+		DryRun:         storageReport.DryRun,
+		Tables:         make([]TableRetention, len(storageReport.Tables)),
+		DiskUsageBytes: storageReport.DiskUsageBytes,
+		MaxDiskBytes:   storageReport.MaxDiskBytes,
+		EvictionCutoff: storageReport.EvictionCutoff,
+	}
+	for i, table := range storageReport.Tables {
+		report.Tables[i] = TableRetention(table)
+	}
+
+	return report, nil
 }
 
 // GetTelemetryOverview gets the telemetry overview
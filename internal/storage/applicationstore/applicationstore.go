@@ -10,6 +10,13 @@ type AgentStatus = types.AgentStatus
 type Group = types.Group
 type Config = types.Config
 type ConfigFilter = types.ConfigFilter
+type ProcessingRule = types.ProcessingRule
+type RuleCondition = types.RuleCondition
+type RuleAction = types.RuleAction
+type RecordingRule = types.RecordingRule
+type AlertRule = types.AlertRule
+type Silence = types.Silence
+type SilenceMatcher = types.SilenceMatcher
 
 // Re-export constants
 const (
@@ -125,6 +125,24 @@ func (f *Factory) Close() error {
 	return nil
 }
 
+// Backupper returns the backup support of the configured storage type
+func (f *Factory) Backupper() (Backupper, error) {
+	backupper, ok := f.factories[f.Config.Type].(Backupper)
+	if !ok {
+		return nil, fmt.Errorf("application storage type %s does not support backups", f.Config.Type)
+	}
+	return backupper, nil
+}
+
+// Migrator returns the schema migration support of the configured storage type
+func (f *Factory) Migrator() (Migrator, error) {
+	migrator, ok := f.factories[f.Config.Type].(Migrator)
+	if !ok {
+		return nil, fmt.Errorf("application storage type %s does not support migrations", f.Config.Type)
+	}
+	return migrator, nil
+}
+
 // GetStorageType returns the configured storage type
 func (f *Factory) GetStorageType() string {
 	return f.Config.Type
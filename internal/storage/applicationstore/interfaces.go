@@ -28,3 +28,39 @@ type Closer interface {
 	// Close closes the storage and releases resources.
 	Close() error
 }
+
+// Backupper defines an interface for taking and restoring snapshots of the storage.
+type Backupper interface {
+	// SchemaVersion returns the schema version created by this build of the storage.
+	SchemaVersion() int
+
+	// Backup writes a consistent snapshot of the running storage into dir.
+	Backup(ctx context.Context, dir string) error
+
+	// PrepareRestore stages the snapshot in dir next to the storage without
+	// replacing it. It must be called before the factory is initialized.
+	PrepareRestore(ctx context.Context, dir string) error
+
+	// CommitRestore replaces the storage with the snapshot staged by PrepareRestore.
+	CommitRestore() error
+
+	// DiscardRestore removes the snapshot staged by PrepareRestore.
+	DiscardRestore() error
+}
+
+// Migrator defines an interface for inspecting, applying and rolling back
+// schema migrations outside of a running server. All methods must be called
+// before the factory is initialized, which applies pending migrations itself.
+type Migrator interface {
+	// MigrationStatus returns every known migration with when it was applied.
+	MigrationStatus(ctx context.Context) ([]types.MigrationStatus, error)
+
+	// Migrate applies the pending migrations and returns them. With dryRun
+	// it only returns the migrations that would be applied.
+	Migrate(ctx context.Context, dryRun bool) ([]types.MigrationStatus, error)
+
+	// Rollback undoes the applied migrations above version, newest first, and
+	// returns them. With dryRun it only returns the migrations that would be
+	// undone.
+	Rollback(ctx context.Context, version int, dryRun bool) ([]types.MigrationStatus, error)
+}
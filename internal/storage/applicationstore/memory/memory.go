@@ -6,6 +6,7 @@ package memory
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -15,18 +16,26 @@ import (
 
 // Store is an in-memory implementation of ApplicationStore
 type Store struct {
-	mu      sync.RWMutex
-	agents  map[uuid.UUID]*types.Agent
-	groups  map[string]*types.Group
-	configs map[string]*types.Config
+	mu             sync.RWMutex
+	agents         map[uuid.UUID]*types.Agent
+	groups         map[string]*types.Group
+	configs        map[string]*types.Config
+	rules          map[string]*types.ProcessingRule
+	recordingRules map[string]*types.RecordingRule
+	alertRules     map[string]*types.AlertRule
+	silences       map[string]*types.Silence
 }
 
 // NewStore creates a new in-memory store
 func NewStore() *Store {
 	return &Store{
-		agents:  make(map[uuid.UUID]*types.Agent),
-		groups:  make(map[string]*types.Group),
-		configs: make(map[string]*types.Config),
+		agents:         make(map[uuid.UUID]*types.Agent),
+		groups:         make(map[string]*types.Group),
+		configs:        make(map[string]*types.Config),
+		rules:          make(map[string]*types.ProcessingRule),
+		recordingRules: make(map[string]*types.RecordingRule),
+		alertRules:     make(map[string]*types.AlertRule),
+		silences:       make(map[string]*types.Silence),
 	}
 }
 
@@ -339,6 +348,322 @@ func (s *Store) ListConfigs(ctx context.Context, filter types.ConfigFilter) ([]*
 	return configs, nil
 }
 
+// Processing rule management
+
+func (s *Store) CreateProcessingRule(ctx context.Context, rule *types.ProcessingRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.rules[rule.ID]; exists {
+		return fmt.Errorf("processing rule already exists: %s", rule.ID)
+	}
+
+	s.rules[rule.ID] = copyProcessingRule(rule)
+	return nil
+}
+
+func (s *Store) GetProcessingRule(ctx context.Context, id string) (*types.ProcessingRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rule, exists := s.rules[id]
+	if !exists {
+		return nil, nil
+	}
+
+	return copyProcessingRule(rule), nil
+}
+
+func (s *Store) ListProcessingRules(ctx context.Context) ([]*types.ProcessingRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rules := make([]*types.ProcessingRule, 0, len(s.rules))
+	for _, rule := range s.rules {
+		rules = append(rules, copyProcessingRule(rule))
+	}
+
+	// Match the SQLite ordering: priority first, then creation time
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority < rules[j].Priority
+		}
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+
+	return rules, nil
+}
+
+func (s *Store) UpdateProcessingRule(ctx context.Context, rule *types.ProcessingRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, exists := s.rules[rule.ID]
+	if !exists {
+		return fmt.Errorf("processing rule not found: %s", rule.ID)
+	}
+
+	updated := copyProcessingRule(rule)
+	updated.CreatedAt = existing.CreatedAt
+	s.rules[rule.ID] = updated
+	return nil
+}
+
+func (s *Store) DeleteProcessingRule(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.rules[id]; !exists {
+		return fmt.Errorf("processing rule not found: %s", id)
+	}
+
+	delete(s.rules, id)
+	return nil
+}
+
+// copyProcessingRule deep copies a processing rule
+func copyProcessingRule(rule *types.ProcessingRule) *types.ProcessingRule {
+	ruleCopy := *rule
+	if rule.Conditions != nil {
+		ruleCopy.Conditions = make([]types.RuleCondition, len(rule.Conditions))
+		copy(ruleCopy.Conditions, rule.Conditions)
+	}
+	if rule.Actions != nil {
+		ruleCopy.Actions = make([]types.RuleAction, len(rule.Actions))
+		copy(ruleCopy.Actions, rule.Actions)
+	}
+	return &ruleCopy
+}
+
+// Recording rule management
+
+func (s *Store) CreateRecordingRule(ctx context.Context, rule *types.RecordingRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.recordingRules[rule.ID]; exists {
+		return fmt.Errorf("recording rule already exists: %s", rule.ID)
+	}
+
+	s.recordingRules[rule.ID] = copyRecordingRule(rule)
+	return nil
+}
+
+func (s *Store) GetRecordingRule(ctx context.Context, id string) (*types.RecordingRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rule, exists := s.recordingRules[id]
+	if !exists {
+		return nil, nil
+	}
+
+	return copyRecordingRule(rule), nil
+}
+
+func (s *Store) ListRecordingRules(ctx context.Context) ([]*types.RecordingRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rules := make([]*types.RecordingRule, 0, len(s.recordingRules))
+	for _, rule := range s.recordingRules {
+		rules = append(rules, copyRecordingRule(rule))
+	}
+
+	// Match the SQLite ordering: name first, then creation time
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Name != rules[j].Name {
+			return rules[i].Name < rules[j].Name
+		}
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+
+	return rules, nil
+}
+
+func (s *Store) UpdateRecordingRule(ctx context.Context, rule *types.RecordingRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, exists := s.recordingRules[rule.ID]
+	if !exists {
+		return fmt.Errorf("recording rule not found: %s", rule.ID)
+	}
+
+	updated := copyRecordingRule(rule)
+	updated.CreatedAt = existing.CreatedAt
+	s.recordingRules[rule.ID] = updated
+	return nil
+}
+
+func (s *Store) DeleteRecordingRule(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.recordingRules[id]; !exists {
+		return fmt.Errorf("recording rule not found: %s", id)
+	}
+
+	delete(s.recordingRules, id)
+	return nil
+}
+
+// copyRecordingRule deep copies a recording rule
+func copyRecordingRule(rule *types.RecordingRule) *types.RecordingRule {
+	ruleCopy := *rule
+	if rule.Labels != nil {
+		ruleCopy.Labels = make(map[string]string, len(rule.Labels))
+		for key, value := range rule.Labels {
+			ruleCopy.Labels[key] = value
+		}
+	}
+	return &ruleCopy
+}
+
+// Alert rule management
+
+func (s *Store) CreateAlertRule(ctx context.Context, rule *types.AlertRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.alertRules[rule.ID]; exists {
+		return fmt.Errorf("alert rule already exists: %s", rule.ID)
+	}
+
+	s.alertRules[rule.ID] = copyAlertRule(rule)
+	return nil
+}
+
+func (s *Store) GetAlertRule(ctx context.Context, id string) (*types.AlertRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rule, exists := s.alertRules[id]
+	if !exists {
+		return nil, nil
+	}
+
+	return copyAlertRule(rule), nil
+}
+
+func (s *Store) ListAlertRules(ctx context.Context) ([]*types.AlertRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rules := make([]*types.AlertRule, 0, len(s.alertRules))
+	for _, rule := range s.alertRules {
+		rules = append(rules, copyAlertRule(rule))
+	}
+
+	// Match the SQLite ordering: name first, then creation time
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Name != rules[j].Name {
+			return rules[i].Name < rules[j].Name
+		}
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+
+	return rules, nil
+}
+
+func (s *Store) UpdateAlertRule(ctx context.Context, rule *types.AlertRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, exists := s.alertRules[rule.ID]
+	if !exists {
+		return fmt.Errorf("alert rule not found: %s", rule.ID)
+	}
+
+	updated := copyAlertRule(rule)
+	updated.CreatedAt = existing.CreatedAt
+	s.alertRules[rule.ID] = updated
+	return nil
+}
+
+func (s *Store) DeleteAlertRule(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.alertRules[id]; !exists {
+		return fmt.Errorf("alert rule not found: %s", id)
+	}
+
+	delete(s.alertRules, id)
+	return nil
+}
+
+// copyAlertRule deep copies an alert rule
+func copyAlertRule(rule *types.AlertRule) *types.AlertRule {
+	ruleCopy := *rule
+	if rule.Labels != nil {
+		ruleCopy.Labels = make(map[string]string, len(rule.Labels))
+		for key, value := range rule.Labels {
+			ruleCopy.Labels[key] = value
+		}
+	}
+	if rule.Annotations != nil {
+		ruleCopy.Annotations = make(map[string]string, len(rule.Annotations))
+		for key, value := range rule.Annotations {
+			ruleCopy.Annotations[key] = value
+		}
+	}
+	return &ruleCopy
+}
+
+// Silence management
+
+func (s *Store) CreateSilence(ctx context.Context, silence *types.Silence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.silences[silence.ID]; exists {
+		return fmt.Errorf("silence already exists: %s", silence.ID)
+	}
+
+	s.silences[silence.ID] = copySilence(silence)
+	return nil
+}
+
+func (s *Store) ListSilences(ctx context.Context) ([]*types.Silence, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	silences := make([]*types.Silence, 0, len(s.silences))
+	for _, silence := range s.silences {
+		silences = append(silences, copySilence(silence))
+	}
+
+	// Match the SQLite ordering: start first, then creation time
+	sort.Slice(silences, func(i, j int) bool {
+		if !silences[i].StartsAt.Equal(silences[j].StartsAt) {
+			return silences[i].StartsAt.Before(silences[j].StartsAt)
+		}
+		return silences[i].CreatedAt.Before(silences[j].CreatedAt)
+	})
+
+	return silences, nil
+}
+
+func (s *Store) DeleteSilence(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.silences[id]; !exists {
+		return fmt.Errorf("silence not found: %s", id)
+	}
+
+	delete(s.silences, id)
+	return nil
+}
+
+// copySilence deep copies a silence
+func copySilence(silence *types.Silence) *types.Silence {
+	silenceCopy := *silence
+	silenceCopy.Matchers = append([]types.SilenceMatcher(nil), silence.Matchers...)
+	return &silenceCopy
+}
+
 // purge removes all data from the store (for testing)
 func (s *Store) purge(context.Context) {
 	s.mu.Lock()
@@ -347,4 +672,8 @@ func (s *Store) purge(context.Context) {
 	s.agents = make(map[uuid.UUID]*types.Agent)
 	s.groups = make(map[string]*types.Group)
 	s.configs = make(map[string]*types.Config)
+	s.rules = make(map[string]*types.ProcessingRule)
+	s.recordingRules = make(map[string]*types.RecordingRule)
+	s.alertRules = make(map[string]*types.AlertRule)
+	s.silences = make(map[string]*types.Silence)
 }
@@ -419,6 +419,176 @@ func TestStoreListConfigsWithLimit(t *testing.T) {
 	})
 }
 
+// Processing rule tests
+
+func makeTestProcessingRule(id string, priority int) *types.ProcessingRule {
+	return &types.ProcessingRule{
+		ID:       id,
+		Name:     "drop-debug-" + id,
+		Signal:   "logs",
+		Enabled:  true,
+		Priority: priority,
+		Conditions: []types.RuleCondition{
+			{Field: "severity_text", Operator: "equals", Value: "DEBUG"},
+		},
+		Actions: []types.RuleAction{
+			{Type: "drop"},
+		},
+		CreatedAt: testTimestamp,
+		UpdatedAt: testTimestamp,
+	}
+}
+
+func TestStoreProcessingRuleCRUD(t *testing.T) {
+	withMemoryStore(func(store *Store) {
+		ctx := context.Background()
+		rule := makeTestProcessingRule("rule-1", 0)
+		require.NoError(t, store.CreateProcessingRule(ctx, rule))
+
+		err := store.CreateProcessingRule(ctx, rule)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+
+		retrieved, err := store.GetProcessingRule(ctx, "rule-1")
+		require.NoError(t, err)
+		require.NotNil(t, retrieved)
+		assert.Equal(t, rule.Actions, retrieved.Actions)
+
+		// Modifying the returned copy must not affect the store
+		retrieved.Actions[0].Type = "delete_attribute"
+		again, err := store.GetProcessingRule(ctx, "rule-1")
+		require.NoError(t, err)
+		assert.Equal(t, "drop", again.Actions[0].Type)
+
+		rule.Name = "renamed"
+		require.NoError(t, store.UpdateProcessingRule(ctx, rule))
+		retrieved, err = store.GetProcessingRule(ctx, "rule-1")
+		require.NoError(t, err)
+		assert.Equal(t, "renamed", retrieved.Name)
+
+		require.NoError(t, store.DeleteProcessingRule(ctx, "rule-1"))
+		err = store.DeleteProcessingRule(ctx, "rule-1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
+func TestStoreListProcessingRulesOrderedByPriority(t *testing.T) {
+	withMemoryStore(func(store *Store) {
+		ctx := context.Background()
+		require.NoError(t, store.CreateProcessingRule(ctx, makeTestProcessingRule("late", 20)))
+		require.NoError(t, store.CreateProcessingRule(ctx, makeTestProcessingRule("early", 1)))
+
+		rules, err := store.ListProcessingRules(ctx)
+		require.NoError(t, err)
+		require.Len(t, rules, 2)
+		assert.Equal(t, "early", rules[0].ID)
+		assert.Equal(t, "late", rules[1].ID)
+	})
+}
+
+func TestStoreRecordingRuleCRUD(t *testing.T) {
+	withMemoryStore(func(store *Store) {
+		ctx := context.Background()
+		rule := &types.RecordingRule{
+			ID:        "rule-1",
+			Name:      "service:errors:ratio",
+			Expr:      `metrics{metric="errors"} / metrics{metric="requests"}`,
+			Interval:  "1m",
+			Labels:    map[string]string{"team": "core"},
+			Enabled:   true,
+			CreatedAt: time.Now(),
+		}
+		require.NoError(t, store.CreateRecordingRule(ctx, rule))
+
+		err := store.CreateRecordingRule(ctx, rule)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+
+		// Modifying the returned copy must not affect the store
+		retrieved, err := store.GetRecordingRule(ctx, "rule-1")
+		require.NoError(t, err)
+		retrieved.Labels["team"] = "edge"
+		again, err := store.GetRecordingRule(ctx, "rule-1")
+		require.NoError(t, err)
+		assert.Equal(t, "core", again.Labels["team"])
+
+		rule.Interval = "5m"
+		require.NoError(t, store.UpdateRecordingRule(ctx, rule))
+		rules, err := store.ListRecordingRules(ctx)
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		assert.Equal(t, "5m", rules[0].Interval)
+
+		require.NoError(t, store.DeleteRecordingRule(ctx, "rule-1"))
+		err = store.DeleteRecordingRule(ctx, "rule-1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
+func TestStoreAlertRuleAndSilenceCRUD(t *testing.T) {
+	withMemoryStore(func(store *Store) {
+		ctx := context.Background()
+		rule := &types.AlertRule{
+			ID:          "alert-1",
+			Name:        "HighErrorRate",
+			Expr:        `metrics{metric="errors"}`,
+			Operator:    ">",
+			Threshold:   10,
+			Interval:    "1m",
+			Annotations: map[string]string{"summary": "Too many errors"},
+			Enabled:     true,
+			CreatedAt:   time.Now(),
+		}
+		require.NoError(t, store.CreateAlertRule(ctx, rule))
+
+		err := store.CreateAlertRule(ctx, rule)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+
+		// Modifying the returned copy must not affect the store
+		retrieved, err := store.GetAlertRule(ctx, "alert-1")
+		require.NoError(t, err)
+		retrieved.Annotations["summary"] = "changed"
+		again, err := store.GetAlertRule(ctx, "alert-1")
+		require.NoError(t, err)
+		assert.Equal(t, "Too many errors", again.Annotations["summary"])
+
+		rule.Threshold = 20
+		require.NoError(t, store.UpdateAlertRule(ctx, rule))
+		rules, err := store.ListAlertRules(ctx)
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		assert.Equal(t, float64(20), rules[0].Threshold)
+
+		require.NoError(t, store.DeleteAlertRule(ctx, "alert-1"))
+		err = store.DeleteAlertRule(ctx, "alert-1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+
+		silence := &types.Silence{
+			ID:       "silence-1",
+			Matchers: []types.SilenceMatcher{{Name: "alertname", Operator: "=", Value: "HighErrorRate"}},
+			StartsAt: time.Now(),
+			EndsAt:   time.Now().Add(time.Hour),
+		}
+		require.NoError(t, store.CreateSilence(ctx, silence))
+		silences, err := store.ListSilences(ctx)
+		require.NoError(t, err)
+		require.Len(t, silences, 1)
+		silences[0].Matchers[0].Value = "changed"
+		silences, err = store.ListSilences(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "HighErrorRate", silences[0].Matchers[0].Value)
+
+		require.NoError(t, store.DeleteSilence(ctx, "silence-1"))
+		err = store.DeleteSilence(ctx, "silence-1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
 // Purge test
 
 func TestStorePurge(t *testing.T) {
@@ -0,0 +1,82 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// backupFile is the name of the database snapshot within a backup
+const backupFile = "application.db"
+
+// Backup writes a consistent snapshot of the database into dir. VACUUM INTO
+// reads in a single transaction, so writers are not blocked while it runs.
+func (s *Storage) Backup(ctx context.Context, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, "VACUUM INTO ?", filepath.Join(dir, backupFile)); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	s.logger.Info("Backed up SQLite application store", zap.String("dir", dir))
+	return nil
+}
+
+// prepareRestore copies the snapshot in dir next to the database at dbPath,
+// leaving the existing database untouched until commitRestore swaps it
+func prepareRestore(dbPath, dir string) error {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+	if err := copyFile(filepath.Join(dir, backupFile), dbPath+".restore"); err != nil {
+		_ = discardRestore(dbPath)
+		return fmt.Errorf("failed to restore database: %w", err)
+	}
+	return nil
+}
+
+// commitRestore replaces the database at dbPath with the snapshot staged by
+// prepareRestore. The database must not be open.
+func commitRestore(dbPath string) error {
+	// The WAL of the replaced database must not be applied to the snapshot
+	_ = os.Remove(dbPath + "-wal")
+	_ = os.Remove(dbPath + "-shm")
+	if err := os.Rename(dbPath+".restore", dbPath); err != nil {
+		_ = discardRestore(dbPath)
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+	return nil
+}
+
+// discardRestore removes the snapshot staged by prepareRestore
+func discardRestore(dbPath string) error {
+	if err := os.Remove(dbPath + ".restore"); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to discard restored database: %w", err)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -5,6 +5,10 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -63,6 +67,129 @@ func (f *Factory) Purge(ctx context.Context) error {
 	return nil
 }
 
+// SchemaVersion implements applicationstore.Backupper
+func (f *Factory) SchemaVersion() int {
+	return SchemaVersion
+}
+
+// Backup implements applicationstore.Backupper
+func (f *Factory) Backup(ctx context.Context, dir string) error {
+	if f.store == nil {
+		return fmt.Errorf("application store is not initialized")
+	}
+	return f.store.Backup(ctx, dir)
+}
+
+// PrepareRestore implements applicationstore.Backupper. The database is
+// replaced on commit, so it must run before the factory is initialized.
+func (f *Factory) PrepareRestore(ctx context.Context, dir string) error {
+	if f.store != nil {
+		return fmt.Errorf("cannot restore an open application store")
+	}
+	return prepareRestore(f.dbPath, dir)
+}
+
+// CommitRestore implements applicationstore.Backupper
+func (f *Factory) CommitRestore() error {
+	if f.store != nil {
+		return fmt.Errorf("cannot restore an open application store")
+	}
+	return commitRestore(f.dbPath)
+}
+
+// DiscardRestore implements applicationstore.Backupper
+func (f *Factory) DiscardRestore() error {
+	return discardRestore(f.dbPath)
+}
+
+// MigrationStatus implements applicationstore.Migrator
+func (f *Factory) MigrationStatus(ctx context.Context) ([]types.MigrationStatus, error) {
+	applied, db, err := f.openForMigration(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		defer db.Close()
+	}
+	return migrationStatus(applied), nil
+}
+
+// Migrate implements applicationstore.Migrator. It returns the migrations that
+// were applied, or with dryRun the ones that would be.
+func (f *Factory) Migrate(ctx context.Context, dryRun bool) ([]types.MigrationStatus, error) {
+	applied, db, err := f.openForMigration(ctx, !dryRun)
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		defer db.Close()
+	}
+
+	pending, err := pendingMigrations(applied)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		if pending, err = migrate(ctx, db, f.migrationLogger()); err != nil {
+			return nil, err
+		}
+	}
+	return toMigrationStatus(pending, false), nil
+}
+
+// Rollback implements applicationstore.Migrator. It returns the migrations
+// that were undone, or with dryRun the ones that would be.
+func (f *Factory) Rollback(ctx context.Context, version int, dryRun bool) ([]types.MigrationStatus, error) {
+	applied, db, err := f.openForMigration(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		defer db.Close()
+	}
+
+	migrations, err := rollbackMigrations(applied, version)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun && len(migrations) > 0 {
+		if migrations, err = rollback(ctx, db, version, f.migrationLogger()); err != nil {
+			return nil, err
+		}
+	}
+	return toMigrationStatus(migrations, true), nil
+}
+
+// openForMigration opens the database without migrating it and reads the
+// applied migrations. A missing database is only created when create is set,
+// otherwise it is reported with a nil database and no applied migrations.
+func (f *Factory) openForMigration(ctx context.Context, create bool) (map[int]time.Time, *sql.DB, error) {
+	if f.store != nil {
+		return nil, nil, fmt.Errorf("cannot migrate an open application store")
+	}
+	if _, err := os.Stat(f.dbPath); os.IsNotExist(err) && !create {
+		return map[int]time.Time{}, nil, nil
+	}
+
+	db, err := openDB(f.dbPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, err
+	}
+	return applied, db, nil
+}
+
+func (f *Factory) migrationLogger() *zap.Logger {
+	if f.logger != nil {
+		return f.logger
+	}
+	return zap.NewNop()
+}
+
 // Close implements storage.Closer
 func (f *Factory) Close() error {
 	if f.store != nil {
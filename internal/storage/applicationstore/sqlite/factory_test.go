@@ -87,3 +87,36 @@ func TestFactoryClose(t *testing.T) {
 	err = factory.Close()
 	require.NoError(t, err)
 }
+
+func TestFactoryBackupAndRestore(t *testing.T) {
+	ctx := context.Background()
+	dbPath := makeTempDB(t)
+	backupDir := t.TempDir()
+
+	factory := NewFactory(dbPath)
+	require.NoError(t, factory.Initialize(zap.NewNop()))
+	store, err := factory.CreateApplicationStore()
+	require.NoError(t, err)
+
+	agent := makeTestAgent(uuid.New())
+	require.NoError(t, store.CreateAgent(ctx, agent))
+	require.NoError(t, factory.Backup(ctx, backupDir))
+	assert.ErrorContains(t, factory.PrepareRestore(ctx, backupDir), "cannot restore an open application store")
+
+	// Changes after the backup are discarded by the restore
+	require.NoError(t, factory.Purge(ctx))
+	require.NoError(t, factory.Close())
+
+	restored := NewFactory(dbPath)
+	require.NoError(t, restored.PrepareRestore(ctx, backupDir))
+	require.NoError(t, restored.CommitRestore())
+	require.NoError(t, restored.Initialize(zap.NewNop()))
+	defer restored.Close()
+
+	store, err = restored.CreateApplicationStore()
+	require.NoError(t, err)
+	got, err := store.GetAgent(ctx, agent.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, agent.Name, got.Name)
+}
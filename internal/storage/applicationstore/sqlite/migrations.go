@@ -1,117 +1,394 @@
 package sqlite
 
-const SchemaVersion = 1
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
 
-// InitialSchema creates the initial SQLite database schema
-const InitialSchema = `
--- Schema version tracking
-CREATE TABLE IF NOT EXISTS schema_version (
-	version INTEGER PRIMARY KEY,
-	applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
-);
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/applicationstore/types"
+)
 
--- Agents table
+// SchemaVersion is the version of the application schema created by this build
+const SchemaVersion = 4
+
+// Migration is a single ordered change to the application schema with the
+// statements that undo it
+type Migration struct {
+	Version     int
+	Description string
+	Up          string
+	Down        string
+}
+
+// Migrations lists the changes to the application schema in the order they
+// are applied. Append new migrations with the next version and never edit one
+// that has been released. The first two predate the schema_version table, so
+// they must stay idempotent: databases created before it run them all again.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create agent, group and config tables",
+		Up: `
 CREATE TABLE IF NOT EXISTS agents (
 	id TEXT PRIMARY KEY,
-	instance_id_str TEXT NOT NULL,
-	name TEXT,
+	name TEXT NOT NULL,
+	labels TEXT,
+	status TEXT NOT NULL DEFAULT 'offline',
+	last_seen DATETIME NOT NULL,
 	group_id TEXT,
 	group_name TEXT,
 	version TEXT,
-	status TEXT NOT NULL DEFAULT 'offline',
-	last_seen DATETIME,
-	started_at DATETIME NOT NULL,
+	capabilities TEXT,
 	effective_config TEXT,
-	custom_config TEXT,
-	remote_config_status TEXT,
-	health_status TEXT,
-	error_message TEXT,
-	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-	FOREIGN KEY (group_id) REFERENCES groups(id)
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
--- Groups table
 CREATE TABLE IF NOT EXISTS groups (
 	id TEXT PRIMARY KEY,
-	name TEXT NOT NULL UNIQUE,
-	description TEXT,
-	config TEXT,
-	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	name TEXT NOT NULL,
+	labels TEXT,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
--- Configs table (config history for agents and groups)
 CREATE TABLE IF NOT EXISTS configs (
 	id TEXT PRIMARY KEY,
+	name TEXT,
 	agent_id TEXT,
 	group_id TEXT,
-	config_type TEXT NOT NULL, -- 'agent' or 'group'
-	config_body TEXT NOT NULL,
+	config_hash TEXT NOT NULL,
+	content TEXT NOT NULL,
 	version INTEGER NOT NULL DEFAULT 1,
-	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-	created_by TEXT,
-	FOREIGN KEY (agent_id) REFERENCES agents(id),
-	FOREIGN KEY (group_id) REFERENCES groups(id),
-	CHECK (
-		(config_type = 'agent' AND agent_id IS NOT NULL AND group_id IS NULL) OR
-		(config_type = 'group' AND group_id IS NOT NULL AND agent_id IS NULL)
-	)
-);
-
--- Agent capabilities table
-CREATE TABLE IF NOT EXISTS agent_capabilities (
-	agent_id TEXT NOT NULL,
-	capability TEXT NOT NULL,
-	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-	PRIMARY KEY (agent_id, capability),
-	FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
-);
-
--- Agent attributes table
-CREATE TABLE IF NOT EXISTS agent_attributes (
-	agent_id TEXT NOT NULL,
-	key TEXT NOT NULL,
-	value TEXT NOT NULL,
-	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-	PRIMARY KEY (agent_id, key),
-	FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE,
+	FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE
 );
 
--- Indexes for performance
 CREATE INDEX IF NOT EXISTS idx_agents_group_id ON agents(group_id);
 CREATE INDEX IF NOT EXISTS idx_agents_status ON agents(status);
-CREATE INDEX IF NOT EXISTS idx_agents_last_seen ON agents(last_seen);
 CREATE INDEX IF NOT EXISTS idx_configs_agent_id ON configs(agent_id);
 CREATE INDEX IF NOT EXISTS idx_configs_group_id ON configs(group_id);
-CREATE INDEX IF NOT EXISTS idx_configs_created_at ON configs(created_at DESC);
-
--- Triggers to update updated_at timestamps
-CREATE TRIGGER IF NOT EXISTS update_agents_timestamp
-AFTER UPDATE ON agents
-BEGIN
-	UPDATE agents SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
-END;
-
-CREATE TRIGGER IF NOT EXISTS update_groups_timestamp
-AFTER UPDATE ON groups
-BEGIN
-	UPDATE groups SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
-END;
-
-CREATE TRIGGER IF NOT EXISTS update_agent_attributes_timestamp
-AFTER UPDATE ON agent_attributes
-BEGIN
-	UPDATE agent_attributes SET updated_at = CURRENT_TIMESTAMP
-	WHERE agent_id = NEW.agent_id AND key = NEW.key;
-END;
-
--- Insert initial schema version
-INSERT OR IGNORE INTO schema_version (version) VALUES (1);
-`
-
-// Migrations is a list of all schema migrations
-var Migrations = []string{
-	InitialSchema,
+CREATE INDEX IF NOT EXISTS idx_configs_config_hash ON configs(config_hash);
+`,
+		Down: `
+DROP TABLE IF EXISTS configs;
+DROP TABLE IF EXISTS groups;
+DROP TABLE IF EXISTS agents;
+`,
+	},
+	{
+		Version:     2,
+		Description: "create processing rule table",
+		Up: `
+CREATE TABLE IF NOT EXISTS processing_rules (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	description TEXT,
+	signal TEXT NOT NULL,
+	enabled INTEGER NOT NULL DEFAULT 1,
+	priority INTEGER NOT NULL DEFAULT 0,
+	conditions TEXT,
+	actions TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS processing_rules;
+`,
+	},
+	{
+		Version:     3,
+		Description: "create recording rule table",
+		Up: `
+CREATE TABLE recording_rules (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	description TEXT,
+	expr TEXT NOT NULL,
+	interval TEXT NOT NULL,
+	labels TEXT,
+	enabled INTEGER NOT NULL DEFAULT 1,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS recording_rules;
+`,
+	},
+	{
+		Version:     4,
+		Description: "create alert rule and silence tables",
+		Up: `
+CREATE TABLE alert_rules (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	description TEXT,
+	expr TEXT NOT NULL,
+	operator TEXT NOT NULL,
+	threshold REAL NOT NULL,
+	for_duration TEXT,
+	interval TEXT NOT NULL,
+	labels TEXT,
+	annotations TEXT,
+	enabled INTEGER NOT NULL DEFAULT 1,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE silences (
+	id TEXT PRIMARY KEY,
+	matchers TEXT NOT NULL,
+	starts_at DATETIME NOT NULL,
+	ends_at DATETIME NOT NULL,
+	created_by TEXT,
+	comment TEXT,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_silences_ends_at ON silences(ends_at);
+`,
+		Down: `
+DROP TABLE IF EXISTS silences;
+DROP TABLE IF EXISTS alert_rules;
+`,
+	},
+}
+
+// schemaVersionTable records the migrations applied to a database
+const schemaVersionTable = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER PRIMARY KEY,
+	applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// queryer is implemented by *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// tableExists reports whether the database has the given table
+func tableExists(ctx context.Context, q queryer, table string) (bool, error) {
+	rows, err := q.QueryContext(ctx, `SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	return rows.Next(), rows.Err()
+}
+
+// hasColumn reports whether a table has the given column
+func hasColumn(ctx context.Context, q queryer, table, column string) (bool, error) {
+	rows, err := q.QueryContext(ctx, `SELECT 1 FROM pragma_table_info(?) WHERE name = ?`, table, column)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	return rows.Next(), rows.Err()
+}
+
+// appliedMigrations returns when each applied migration ran. A database
+// without the schema_version table has none applied.
+func appliedMigrations(ctx context.Context, q queryer) (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+	exists, err := tableExists(ctx, q, "schema_version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check schema version: %w", err)
+	}
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT version, applied_at FROM schema_version`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema version: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return applied, nil
+}
+
+// migrationStatus returns every known migration with when it was applied
+func migrationStatus(applied map[int]time.Time) []types.MigrationStatus {
+	status := make([]types.MigrationStatus, len(Migrations))
+	for i, migration := range Migrations {
+		status[i] = types.MigrationStatus{
+			Version:     migration.Version,
+			Description: migration.Description,
+			SQL:         migration.Up,
+		}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status[i].AppliedAt = &appliedAt
+		}
+	}
+	return status
+}
+
+// pendingMigrations returns the migrations not yet applied, refusing
+// databases written by a newer build
+func pendingMigrations(applied map[int]time.Time) ([]Migration, error) {
+	for version := range applied {
+		if version > SchemaVersion {
+			return nil, fmt.Errorf("database schema version %d is newer than supported version %d", version, SchemaVersion)
+		}
+	}
+	var pending []Migration
+	for _, migration := range Migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// rollbackMigrations returns the applied migrations above version, newest first
+func rollbackMigrations(applied map[int]time.Time, version int) ([]Migration, error) {
+	if version < 0 || version > SchemaVersion {
+		return nil, fmt.Errorf("invalid target schema version %d, expected 0 to %d", version, SchemaVersion)
+	}
+	if _, err := pendingMigrations(applied); err != nil {
+		return nil, err
+	}
+	var rollback []Migration
+	for i := len(Migrations) - 1; i >= 0; i-- {
+		migration := Migrations[i]
+		if _, ok := applied[migration.Version]; ok && migration.Version > version {
+			rollback = append(rollback, migration)
+		}
+	}
+	return rollback, nil
+}
+
+// migrate applies the pending migrations, each in its own transaction, and
+// returns the ones applied
+func migrate(ctx context.Context, db *sql.DB, logger *zap.Logger) ([]Migration, error) {
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := pendingMigrations(applied)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	if len(applied) == 0 {
+		if err := adoptUnversionedSchema(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := db.ExecContext(ctx, schemaVersionTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema version table: %w", err)
+	}
+
+	for _, migration := range pending {
+		err := runMigration(ctx, db, migration.Up,
+			`INSERT INTO schema_version (version, applied_at) VALUES (?, ?)`, migration.Version, time.Now().UTC())
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		logger.Info("Applied application schema migration",
+			zap.Int("version", migration.Version),
+			zap.String("description", migration.Description))
+	}
+	return pending, nil
+}
+
+// rollback undoes the applied migrations above version, newest first, each in
+// its own transaction, and returns the ones undone
+func rollback(ctx context.Context, db *sql.DB, version int, logger *zap.Logger) ([]Migration, error) {
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := rollbackMigrations(applied, version)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range migrations {
+		err := runMigration(ctx, db, migration.Down,
+			`DELETE FROM schema_version WHERE version = ?`, migration.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to roll back migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		logger.Info("Rolled back application schema migration",
+			zap.Int("version", migration.Version),
+			zap.String("description", migration.Description))
+	}
+	return migrations, nil
+}
+
+// runMigration runs the statements of a migration and records the change in
+// one transaction
+func runMigration(ctx context.Context, db *sql.DB, statements, record string, args ...interface{}) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// adoptUnversionedSchema prepares a database created before the
+// schema_version table. The early migrations are idempotent, so only changes
+// they cannot express are made here.
+func adoptUnversionedSchema(ctx context.Context, db *sql.DB) error {
+	exists, err := tableExists(ctx, db, "configs")
+	if err != nil {
+		return fmt.Errorf("failed to inspect existing schema: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	// The configs name column was added before migrations were versioned
+	named, err := hasColumn(ctx, db, "configs", "name")
+	if err != nil {
+		return fmt.Errorf("failed to inspect existing schema: %w", err)
+	}
+	if !named {
+		if _, err := db.ExecContext(ctx, `ALTER TABLE configs ADD COLUMN name TEXT`); err != nil {
+			return fmt.Errorf("failed to add configs name column: %w", err)
+		}
+	}
+	return nil
+}
+
+// toMigrationStatus converts migrations to their status, using the statements
+// that will run
+func toMigrationStatus(migrations []Migration, down bool) []types.MigrationStatus {
+	status := make([]types.MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		status[i] = types.MigrationStatus{Version: migration.Version, Description: migration.Description, SQL: migration.Up}
+		if down {
+			status[i].SQL = migration.Down
+		}
+	}
+	return status
 }
@@ -0,0 +1,230 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// v1Schema is the schema written by releases before versioned migrations
+const v1Schema = `
+CREATE TABLE agents (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	labels TEXT,
+	status TEXT NOT NULL DEFAULT 'offline',
+	last_seen DATETIME NOT NULL,
+	group_id TEXT,
+	group_name TEXT,
+	version TEXT,
+	capabilities TEXT,
+	effective_config TEXT,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE groups (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	labels TEXT,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE configs (
+	id TEXT PRIMARY KEY,
+	%s
+	agent_id TEXT,
+	group_id TEXT,
+	config_hash TEXT NOT NULL,
+	content TEXT NOT NULL,
+	version INTEGER NOT NULL DEFAULT 1,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO agents (id, name, labels, status, last_seen, group_id, group_name, version, capabilities)
+VALUES ('%s', 'v1-agent', '{}', 'online', CURRENT_TIMESTAMP, 'group', 'group', '1.0.0', '[]');
+`
+
+// writeV1Database creates a database file as written by a v1 release
+func writeV1Database(t *testing.T, agentID uuid.UUID, withConfigName bool) string {
+	dbPath := makeTempDB(t)
+	db, err := sql.Open("sqlite3", dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+
+	nameColumn := ""
+	if withConfigName {
+		nameColumn = "name TEXT,"
+	}
+	_, err = db.Exec(fmt.Sprintf(v1Schema, nameColumn, agentID))
+	require.NoError(t, err)
+	return dbPath
+}
+
+func appliedVersions(t *testing.T, db *sql.DB) []int {
+	applied, err := appliedMigrations(context.Background(), db)
+	require.NoError(t, err)
+	var versions []int
+	for _, migration := range Migrations {
+		if _, ok := applied[migration.Version]; ok {
+			versions = append(versions, migration.Version)
+		}
+	}
+	return versions
+}
+
+func TestMigrations_AreOrdered(t *testing.T) {
+	for i, migration := range Migrations {
+		assert.Equal(t, i+1, migration.Version)
+		assert.NotEmpty(t, migration.Description)
+		assert.NotEmpty(t, migration.Up)
+		assert.NotEmpty(t, migration.Down)
+	}
+	assert.Equal(t, SchemaVersion, Migrations[len(Migrations)-1].Version)
+}
+
+func TestMigrate_FromV1DatabaseFile(t *testing.T) {
+	for _, withConfigName := range []bool{true, false} {
+		t.Run(fmt.Sprintf("config name=%t", withConfigName), func(t *testing.T) {
+			ctx := context.Background()
+			agentID := uuid.New()
+			dbPath := writeV1Database(t, agentID, withConfigName)
+
+			store, err := NewSQLiteStorage(dbPath, zap.NewNop())
+			require.NoError(t, err)
+			storage := store.(*Storage)
+			defer storage.Close()
+
+			assert.Equal(t, []int{1, 2, 3, 4}, appliedVersions(t, storage.db))
+
+			agent, err := storage.GetAgent(ctx, agentID)
+			require.NoError(t, err)
+			assert.Equal(t, "v1-agent", agent.Name)
+
+			named, err := hasColumn(ctx, storage.db, "configs", "name")
+			require.NoError(t, err)
+			assert.True(t, named)
+			exists, err := tableExists(ctx, storage.db, "processing_rules")
+			require.NoError(t, err)
+			assert.True(t, exists)
+		})
+	}
+}
+
+func TestMigrate_FromRecordedVersion(t *testing.T) {
+	ctx := context.Background()
+	dbPath := makeTempDB(t)
+	db, err := openDB(dbPath)
+	require.NoError(t, err)
+	_, err = db.Exec(schemaVersionTable)
+	require.NoError(t, err)
+	require.NoError(t, runMigration(ctx, db, Migrations[0].Up, `INSERT INTO schema_version (version) VALUES (1)`))
+	require.NoError(t, db.Close())
+
+	factory := NewFactory(dbPath)
+	pending, err := factory.Migrate(ctx, true)
+	require.NoError(t, err)
+	require.Len(t, pending, 3)
+	assert.Equal(t, 2, pending[0].Version)
+	assert.Equal(t, 3, pending[1].Version)
+	assert.Equal(t, 4, pending[2].Version)
+
+	applied, err := factory.Migrate(ctx, false)
+	require.NoError(t, err)
+	require.Len(t, applied, 3)
+
+	status, err := factory.MigrationStatus(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, SchemaVersion)
+	for _, migration := range status {
+		assert.NotNil(t, migration.AppliedAt, "migration %d", migration.Version)
+	}
+}
+
+func TestMigrate_RefusesNewerSchema(t *testing.T) {
+	dbPath := makeTempDB(t)
+	store, err := NewSQLiteStorage(dbPath, zap.NewNop())
+	require.NoError(t, err)
+	storage := store.(*Storage)
+	_, err = storage.db.Exec(`INSERT INTO schema_version (version) VALUES (?)`, SchemaVersion+1)
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+
+	_, err = NewSQLiteStorage(dbPath, zap.NewNop())
+	assert.ErrorContains(t, err, fmt.Sprintf("database schema version %d is newer than supported version %d", SchemaVersion+1, SchemaVersion))
+}
+
+func TestMigrate_FailedMigrationIsRolledBack(t *testing.T) {
+	migrations := Migrations
+	t.Cleanup(func() { Migrations = migrations })
+	Migrations = append(append([]Migration(nil), migrations...), Migration{
+		Version:     SchemaVersion + 1,
+		Description: "broken",
+		Up:          `CREATE TABLE partial (id TEXT); INSERT INTO missing VALUES (1);`,
+		Down:        `DROP TABLE partial;`,
+	})
+
+	ctx := context.Background()
+	db, err := openDB(makeTempDB(t))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = migrate(ctx, db, zap.NewNop())
+	assert.ErrorContains(t, err, fmt.Sprintf("failed to apply migration %d (broken)", SchemaVersion+1))
+
+	exists, err := tableExists(ctx, db, "partial")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, []int{1, 2, 3, 4}, appliedVersions(t, db))
+}
+
+func TestFactoryRollback(t *testing.T) {
+	ctx := context.Background()
+	dbPath := makeTempDB(t)
+	store, err := NewSQLiteStorage(dbPath, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, store.(*Storage).Close())
+
+	factory := NewFactory(dbPath)
+	planned, err := factory.Rollback(ctx, 1, true)
+	require.NoError(t, err)
+	require.Len(t, planned, 3)
+	assert.Equal(t, 4, planned[0].Version)
+	assert.Contains(t, planned[0].SQL, "DROP TABLE IF EXISTS silences")
+	assert.Equal(t, 3, planned[1].Version)
+	assert.Contains(t, planned[1].SQL, "DROP TABLE IF EXISTS recording_rules")
+	assert.Equal(t, 2, planned[2].Version)
+	assert.Contains(t, planned[2].SQL, "DROP TABLE IF EXISTS processing_rules")
+
+	rolledBack, err := factory.Rollback(ctx, 1, false)
+	require.NoError(t, err)
+	require.Len(t, rolledBack, 3)
+
+	db, err := openDB(dbPath)
+	require.NoError(t, err)
+	exists, err := tableExists(ctx, db, "processing_rules")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, []int{1}, appliedVersions(t, db))
+	require.NoError(t, db.Close())
+
+	_, err = factory.Rollback(ctx, SchemaVersion+1, false)
+	assert.ErrorContains(t, err, "invalid target schema version")
+
+	// Opening the store migrates it forward again
+	require.NoError(t, factory.Initialize(zap.NewNop()))
+	defer factory.Close()
+	assert.Equal(t, []int{1, 2, 3, 4}, appliedVersions(t, factory.store.db))
+	_, err = factory.Rollback(ctx, 0, false)
+	assert.ErrorContains(t, err, "cannot migrate an open application store")
+}
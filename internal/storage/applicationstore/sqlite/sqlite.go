@@ -21,6 +21,32 @@ type Storage struct {
 
 // NewSQLiteStorage creates a new SQLite storage instance
 func NewSQLiteStorage(dbPath string, logger *zap.Logger) (types.ApplicationStore, error) {
+	db, err := openDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run migrations
+	applied, err := migrate(context.Background(), db, logger)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	storage := &Storage{
+		db:     db,
+		logger: logger,
+	}
+
+	logger.Info("SQLite storage initialized",
+		zap.String("path", dbPath),
+		zap.Int("schema_version", SchemaVersion),
+		zap.Int("applied_migrations", len(applied)))
+	return storage, nil
+}
+
+// openDB opens the SQLite database at dbPath without migrating it
+func openDB(dbPath string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
@@ -33,102 +59,17 @@ func NewSQLiteStorage(dbPath string, logger *zap.Logger) (types.ApplicationStore
 
 	// Enable WAL mode for better concurrency
 	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
 	}
 
 	// Enable foreign keys
 	if _, err := db.Exec("PRAGMA foreign_keys=ON"); err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
 	}
 
-	storage := &Storage{
-		db:     db,
-		logger: logger,
-	}
-
-	// Run migrations
-	if err := storage.migrate(); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
-	}
-
-	logger.Info("SQLite storage initialized", zap.String("path", dbPath))
-	return storage, nil
-}
-
-// migrate runs database migrations
-func (s *Storage) migrate() error {
-	// Create tables if they don't exist
-	createTables := `
-		CREATE TABLE IF NOT EXISTS agents (
-			id TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			labels TEXT,
-			status TEXT NOT NULL DEFAULT 'offline',
-			last_seen DATETIME NOT NULL,
-			group_id TEXT,
-			group_name TEXT,
-			version TEXT,
-			capabilities TEXT,
-			effective_config TEXT,
-			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-		);
-
-		CREATE TABLE IF NOT EXISTS groups (
-			id TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			labels TEXT,
-			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-		);
-
-		CREATE TABLE IF NOT EXISTS configs (
-			id TEXT PRIMARY KEY,
-			name TEXT,
-			agent_id TEXT,
-			group_id TEXT,
-			config_hash TEXT NOT NULL,
-			content TEXT NOT NULL,
-			version INTEGER NOT NULL DEFAULT 1,
-			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE,
-			FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE
-		);
-
-		CREATE INDEX IF NOT EXISTS idx_agents_group_id ON agents(group_id);
-		CREATE INDEX IF NOT EXISTS idx_agents_status ON agents(status);
-		CREATE INDEX IF NOT EXISTS idx_configs_agent_id ON configs(agent_id);
-		CREATE INDEX IF NOT EXISTS idx_configs_group_id ON configs(group_id);
-		CREATE INDEX IF NOT EXISTS idx_configs_config_hash ON configs(config_hash);
-	`
-
-	if _, err := s.db.Exec(createTables); err != nil {
-		return fmt.Errorf("failed to create tables: %w", err)
-	}
-
-	// Run migrations for schema changes
-	migrations := []string{
-		// Add name column to configs table if it doesn't exist
-		`ALTER TABLE configs ADD COLUMN name TEXT`,
-	}
-
-	for _, migration := range migrations {
-		if _, err := s.db.Exec(migration); err != nil {
-			// Ignore errors for columns that already exist
-			if !isColumnExistsError(err) {
-				s.logger.Debug("Migration skipped or failed", zap.Error(err))
-			}
-		}
-	}
-
-	s.logger.Debug("Database migrations completed")
-	return nil
-}
-
-// isColumnExistsError checks if the error is due to a column already existing
-func isColumnExistsError(err error) bool {
-	return err != nil && (err.Error() == "duplicate column name: name" ||
-		err.Error() == "column name already exists")
+	return db, nil
 }
 
 // Agent management
@@ -641,6 +582,578 @@ func (s *Storage) ListConfigs(ctx context.Context, filter types.ConfigFilter) ([
 	return configs, nil
 }
 
+// Processing rule management
+func (s *Storage) CreateProcessingRule(ctx context.Context, rule *types.ProcessingRule) error {
+	conditionsJSON, _ := json.Marshal(rule.Conditions)
+	actionsJSON, _ := json.Marshal(rule.Actions)
+
+	query := `
+		INSERT INTO processing_rules (id, name, description, signal, enabled, priority, conditions, actions, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		rule.ID,
+		rule.Name,
+		rule.Description,
+		rule.Signal,
+		rule.Enabled,
+		rule.Priority,
+		string(conditionsJSON),
+		string(actionsJSON),
+		rule.CreatedAt,
+		rule.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create processing rule: %w", err)
+	}
+
+	s.logger.Debug("Created processing rule", zap.String("rule_id", rule.ID))
+	return nil
+}
+
+func (s *Storage) GetProcessingRule(ctx context.Context, id string) (*types.ProcessingRule, error) {
+	query := `
+		SELECT id, name, description, signal, enabled, priority, conditions, actions, created_at, updated_at
+		FROM processing_rules WHERE id = ?
+	`
+
+	rule, err := scanProcessingRule(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get processing rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+func (s *Storage) ListProcessingRules(ctx context.Context) ([]*types.ProcessingRule, error) {
+	query := `
+		SELECT id, name, description, signal, enabled, priority, conditions, actions, created_at, updated_at
+		FROM processing_rules ORDER BY priority ASC, created_at ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processing rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*types.ProcessingRule
+	for rows.Next() {
+		rule, err := scanProcessingRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan processing rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func (s *Storage) UpdateProcessingRule(ctx context.Context, rule *types.ProcessingRule) error {
+	conditionsJSON, _ := json.Marshal(rule.Conditions)
+	actionsJSON, _ := json.Marshal(rule.Actions)
+
+	query := `
+		UPDATE processing_rules
+		SET name = ?, description = ?, signal = ?, enabled = ?, priority = ?, conditions = ?, actions = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	result, err := s.db.ExecContext(ctx, query,
+		rule.Name,
+		rule.Description,
+		rule.Signal,
+		rule.Enabled,
+		rule.Priority,
+		string(conditionsJSON),
+		string(actionsJSON),
+		rule.UpdatedAt,
+		rule.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update processing rule: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("processing rule not found: %s", rule.ID)
+	}
+
+	s.logger.Debug("Updated processing rule", zap.String("rule_id", rule.ID))
+	return nil
+}
+
+func (s *Storage) DeleteProcessingRule(ctx context.Context, id string) error {
+	query := `DELETE FROM processing_rules WHERE id = ?`
+
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete processing rule: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("processing rule not found: %s", id)
+	}
+
+	s.logger.Debug("Deleted processing rule", zap.String("rule_id", id))
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProcessingRule scans a single processing rule row
+func scanProcessingRule(row rowScanner) (*types.ProcessingRule, error) {
+	var rule types.ProcessingRule
+	var description, conditionsJSON sql.NullString
+	var actionsJSON string
+
+	err := row.Scan(
+		&rule.ID,
+		&rule.Name,
+		&description,
+		&rule.Signal,
+		&rule.Enabled,
+		&rule.Priority,
+		&conditionsJSON,
+		&actionsJSON,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if description.Valid {
+		rule.Description = description.String
+	}
+	if conditionsJSON.Valid {
+		_ = json.Unmarshal([]byte(conditionsJSON.String), &rule.Conditions)
+	}
+	_ = json.Unmarshal([]byte(actionsJSON), &rule.Actions)
+
+	return &rule, nil
+}
+
+// Recording rule management
+func (s *Storage) CreateRecordingRule(ctx context.Context, rule *types.RecordingRule) error {
+	labelsJSON, _ := json.Marshal(rule.Labels)
+
+	query := `
+		INSERT INTO recording_rules (id, name, description, expr, interval, labels, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		rule.ID,
+		rule.Name,
+		rule.Description,
+		rule.Expr,
+		rule.Interval,
+		string(labelsJSON),
+		rule.Enabled,
+		rule.CreatedAt,
+		rule.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create recording rule: %w", err)
+	}
+
+	s.logger.Debug("Created recording rule", zap.String("rule_id", rule.ID))
+	return nil
+}
+
+func (s *Storage) GetRecordingRule(ctx context.Context, id string) (*types.RecordingRule, error) {
+	query := `
+		SELECT id, name, description, expr, interval, labels, enabled, created_at, updated_at
+		FROM recording_rules WHERE id = ?
+	`
+
+	rule, err := scanRecordingRule(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get recording rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+func (s *Storage) ListRecordingRules(ctx context.Context) ([]*types.RecordingRule, error) {
+	query := `
+		SELECT id, name, description, expr, interval, labels, enabled, created_at, updated_at
+		FROM recording_rules ORDER BY name ASC, created_at ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recording rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*types.RecordingRule
+	for rows.Next() {
+		rule, err := scanRecordingRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recording rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func (s *Storage) UpdateRecordingRule(ctx context.Context, rule *types.RecordingRule) error {
+	labelsJSON, _ := json.Marshal(rule.Labels)
+
+	query := `
+		UPDATE recording_rules
+		SET name = ?, description = ?, expr = ?, interval = ?, labels = ?, enabled = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	result, err := s.db.ExecContext(ctx, query,
+		rule.Name,
+		rule.Description,
+		rule.Expr,
+		rule.Interval,
+		string(labelsJSON),
+		rule.Enabled,
+		rule.UpdatedAt,
+		rule.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update recording rule: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("recording rule not found: %s", rule.ID)
+	}
+
+	s.logger.Debug("Updated recording rule", zap.String("rule_id", rule.ID))
+	return nil
+}
+
+func (s *Storage) DeleteRecordingRule(ctx context.Context, id string) error {
+	query := `DELETE FROM recording_rules WHERE id = ?`
+
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete recording rule: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("recording rule not found: %s", id)
+	}
+
+	s.logger.Debug("Deleted recording rule", zap.String("rule_id", id))
+	return nil
+}
+
+// scanRecordingRule scans a single recording rule row
+func scanRecordingRule(row rowScanner) (*types.RecordingRule, error) {
+	var rule types.RecordingRule
+	var description, labelsJSON sql.NullString
+
+	err := row.Scan(
+		&rule.ID,
+		&rule.Name,
+		&description,
+		&rule.Expr,
+		&rule.Interval,
+		&labelsJSON,
+		&rule.Enabled,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if description.Valid {
+		rule.Description = description.String
+	}
+	if labelsJSON.Valid {
+		_ = json.Unmarshal([]byte(labelsJSON.String), &rule.Labels)
+	}
+
+	return &rule, nil
+}
+
+// Alert rule management
+func (s *Storage) CreateAlertRule(ctx context.Context, rule *types.AlertRule) error {
+	labelsJSON, _ := json.Marshal(rule.Labels)
+	annotationsJSON, _ := json.Marshal(rule.Annotations)
+
+	query := `
+		INSERT INTO alert_rules (id, name, description, expr, operator, threshold, for_duration, interval, labels, annotations, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		rule.ID,
+		rule.Name,
+		rule.Description,
+		rule.Expr,
+		rule.Operator,
+		rule.Threshold,
+		rule.For,
+		rule.Interval,
+		string(labelsJSON),
+		string(annotationsJSON),
+		rule.Enabled,
+		rule.CreatedAt,
+		rule.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create alert rule: %w", err)
+	}
+
+	s.logger.Debug("Created alert rule", zap.String("rule_id", rule.ID))
+	return nil
+}
+
+func (s *Storage) GetAlertRule(ctx context.Context, id string) (*types.AlertRule, error) {
+	query := `
+		SELECT id, name, description, expr, operator, threshold, for_duration, interval, labels, annotations, enabled, created_at, updated_at
+		FROM alert_rules WHERE id = ?
+	`
+
+	rule, err := scanAlertRule(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get alert rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+func (s *Storage) ListAlertRules(ctx context.Context) ([]*types.AlertRule, error) {
+	query := `
+		SELECT id, name, description, expr, operator, threshold, for_duration, interval, labels, annotations, enabled, created_at, updated_at
+		FROM alert_rules ORDER BY name ASC, created_at ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*types.AlertRule
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func (s *Storage) UpdateAlertRule(ctx context.Context, rule *types.AlertRule) error {
+	labelsJSON, _ := json.Marshal(rule.Labels)
+	annotationsJSON, _ := json.Marshal(rule.Annotations)
+
+	query := `
+		UPDATE alert_rules
+		SET name = ?, description = ?, expr = ?, operator = ?, threshold = ?, for_duration = ?, interval = ?,
+			labels = ?, annotations = ?, enabled = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	result, err := s.db.ExecContext(ctx, query,
+		rule.Name,
+		rule.Description,
+		rule.Expr,
+		rule.Operator,
+		rule.Threshold,
+		rule.For,
+		rule.Interval,
+		string(labelsJSON),
+		string(annotationsJSON),
+		rule.Enabled,
+		rule.UpdatedAt,
+		rule.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update alert rule: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("alert rule not found: %s", rule.ID)
+	}
+
+	s.logger.Debug("Updated alert rule", zap.String("rule_id", rule.ID))
+	return nil
+}
+
+func (s *Storage) DeleteAlertRule(ctx context.Context, id string) error {
+	query := `DELETE FROM alert_rules WHERE id = ?`
+
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("alert rule not found: %s", id)
+	}
+
+	s.logger.Debug("Deleted alert rule", zap.String("rule_id", id))
+	return nil
+}
+
+// scanAlertRule scans a single alert rule row
+func scanAlertRule(row rowScanner) (*types.AlertRule, error) {
+	var rule types.AlertRule
+	var description, forDuration, labelsJSON, annotationsJSON sql.NullString
+
+	err := row.Scan(
+		&rule.ID,
+		&rule.Name,
+		&description,
+		&rule.Expr,
+		&rule.Operator,
+		&rule.Threshold,
+		&forDuration,
+		&rule.Interval,
+		&labelsJSON,
+		&annotationsJSON,
+		&rule.Enabled,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if description.Valid {
+		rule.Description = description.String
+	}
+	if forDuration.Valid {
+		rule.For = forDuration.String
+	}
+	if labelsJSON.Valid {
+		_ = json.Unmarshal([]byte(labelsJSON.String), &rule.Labels)
+	}
+	if annotationsJSON.Valid {
+		_ = json.Unmarshal([]byte(annotationsJSON.String), &rule.Annotations)
+	}
+
+	return &rule, nil
+}
+
+// Silence management
+func (s *Storage) CreateSilence(ctx context.Context, silence *types.Silence) error {
+	matchersJSON, err := json.Marshal(silence.Matchers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal silence matchers: %w", err)
+	}
+
+	query := `
+		INSERT INTO silences (id, matchers, starts_at, ends_at, created_by, comment, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err = s.db.ExecContext(ctx, query,
+		silence.ID,
+		string(matchersJSON),
+		silence.StartsAt,
+		silence.EndsAt,
+		silence.CreatedBy,
+		silence.Comment,
+		silence.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create silence: %w", err)
+	}
+
+	s.logger.Debug("Created silence", zap.String("silence_id", silence.ID))
+	return nil
+}
+
+func (s *Storage) ListSilences(ctx context.Context) ([]*types.Silence, error) {
+	query := `
+		SELECT id, matchers, starts_at, ends_at, created_by, comment, created_at
+		FROM silences ORDER BY starts_at ASC, created_at ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list silences: %w", err)
+	}
+	defer rows.Close()
+
+	var silences []*types.Silence
+	for rows.Next() {
+		var silence types.Silence
+		var matchersJSON string
+		var createdBy, comment sql.NullString
+
+		err := rows.Scan(
+			&silence.ID,
+			&matchersJSON,
+			&silence.StartsAt,
+			&silence.EndsAt,
+			&createdBy,
+			&comment,
+			&silence.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan silence: %w", err)
+		}
+
+		if createdBy.Valid {
+			silence.CreatedBy = createdBy.String
+		}
+		if comment.Valid {
+			silence.Comment = comment.String
+		}
+		_ = json.Unmarshal([]byte(matchersJSON), &silence.Matchers)
+
+		silences = append(silences, &silence)
+	}
+
+	return silences, nil
+}
+
+func (s *Storage) DeleteSilence(ctx context.Context, id string) error {
+	query := `DELETE FROM silences WHERE id = ?`
+
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete silence: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("silence not found: %s", id)
+	}
+
+	s.logger.Debug("Deleted silence", zap.String("silence_id", id))
+	return nil
+}
+
 // Close closes the database connection
 func (s *Storage) Close() error {
 	if err := s.db.Close(); err != nil {
@@ -387,6 +387,203 @@ func TestSQLiteListConfigsWithLimit(t *testing.T) {
 	})
 }
 
+// Processing rule tests
+
+func makeTestProcessingRule(id string, priority int) *types.ProcessingRule {
+	return &types.ProcessingRule{
+		ID:       id,
+		Name:     "strip-auth-" + id,
+		Signal:   "traces",
+		Enabled:  true,
+		Priority: priority,
+		Conditions: []types.RuleCondition{
+			{Field: "service_name", Operator: "equals", Value: "api"},
+		},
+		Actions: []types.RuleAction{
+			{Type: "delete_attribute", Key: "http.request.header.authorization"},
+		},
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+}
+
+func TestSQLiteProcessingRuleCRUD(t *testing.T) {
+	withSQLiteStore(t, func(store types.ApplicationStore) {
+		ctx := context.Background()
+		rule := makeTestProcessingRule("rule-1", 10)
+		require.NoError(t, store.CreateProcessingRule(ctx, rule))
+
+		retrieved, err := store.GetProcessingRule(ctx, "rule-1")
+		require.NoError(t, err)
+		require.NotNil(t, retrieved)
+		assert.Equal(t, rule.Name, retrieved.Name)
+		assert.Equal(t, rule.Conditions, retrieved.Conditions)
+		assert.Equal(t, rule.Actions, retrieved.Actions)
+		assert.True(t, retrieved.Enabled)
+
+		rule.Enabled = false
+		rule.Actions = append(rule.Actions, types.RuleAction{Type: "hash_attribute", Key: "user.id"})
+		require.NoError(t, store.UpdateProcessingRule(ctx, rule))
+
+		retrieved, err = store.GetProcessingRule(ctx, "rule-1")
+		require.NoError(t, err)
+		assert.False(t, retrieved.Enabled)
+		assert.Len(t, retrieved.Actions, 2)
+
+		require.NoError(t, store.DeleteProcessingRule(ctx, "rule-1"))
+		retrieved, err = store.GetProcessingRule(ctx, "rule-1")
+		require.NoError(t, err)
+		assert.Nil(t, retrieved)
+	})
+}
+
+func TestSQLiteListProcessingRulesOrderedByPriority(t *testing.T) {
+	withSQLiteStore(t, func(store types.ApplicationStore) {
+		ctx := context.Background()
+		require.NoError(t, store.CreateProcessingRule(ctx, makeTestProcessingRule("late", 20)))
+		require.NoError(t, store.CreateProcessingRule(ctx, makeTestProcessingRule("early", 1)))
+
+		rules, err := store.ListProcessingRules(ctx)
+		require.NoError(t, err)
+		require.Len(t, rules, 2)
+		assert.Equal(t, "early", rules[0].ID)
+		assert.Equal(t, "late", rules[1].ID)
+	})
+}
+
+func TestSQLiteProcessingRuleNotFound(t *testing.T) {
+	withSQLiteStore(t, func(store types.ApplicationStore) {
+		err := store.UpdateProcessingRule(context.Background(), makeTestProcessingRule("missing", 0))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+
+		err = store.DeleteProcessingRule(context.Background(), "missing")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
+func TestSQLiteRecordingRuleCRUD(t *testing.T) {
+	withSQLiteStore(t, func(store types.ApplicationStore) {
+		ctx := context.Background()
+		rule := &types.RecordingRule{
+			ID:        "rule-1",
+			Name:      "service:requests:rate5m",
+			Expr:      `sum(rate(metrics{metric="requests_total"} [5m])) by (service)`,
+			Interval:  "1m",
+			Labels:    map[string]string{"team": "core"},
+			Enabled:   true,
+			CreatedAt: time.Now().UTC(),
+			UpdatedAt: time.Now().UTC(),
+		}
+		require.NoError(t, store.CreateRecordingRule(ctx, rule))
+
+		retrieved, err := store.GetRecordingRule(ctx, "rule-1")
+		require.NoError(t, err)
+		require.NotNil(t, retrieved)
+		assert.Equal(t, rule.Expr, retrieved.Expr)
+		assert.Equal(t, rule.Labels, retrieved.Labels)
+		assert.True(t, retrieved.Enabled)
+
+		rule.Interval = "5m"
+		rule.Enabled = false
+		require.NoError(t, store.UpdateRecordingRule(ctx, rule))
+
+		rules, err := store.ListRecordingRules(ctx)
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		assert.Equal(t, "5m", rules[0].Interval)
+		assert.False(t, rules[0].Enabled)
+
+		require.NoError(t, store.DeleteRecordingRule(ctx, "rule-1"))
+		retrieved, err = store.GetRecordingRule(ctx, "rule-1")
+		require.NoError(t, err)
+		assert.Nil(t, retrieved)
+
+		err = store.DeleteRecordingRule(ctx, "rule-1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
+func TestSQLiteAlertRuleCRUD(t *testing.T) {
+	withSQLiteStore(t, func(store types.ApplicationStore) {
+		ctx := context.Background()
+		rule := &types.AlertRule{
+			ID:          "alert-1",
+			Name:        "HighErrorRate",
+			Expr:        `sum(rate(metrics{metric="errors_total"} [5m])) by (service)`,
+			Operator:    ">",
+			Threshold:   0.5,
+			For:         "5m",
+			Interval:    "1m",
+			Labels:      map[string]string{"severity": "page"},
+			Annotations: map[string]string{"summary": "Errors on {{ .Labels.service }}"},
+			Enabled:     true,
+			CreatedAt:   time.Now().UTC(),
+			UpdatedAt:   time.Now().UTC(),
+		}
+		require.NoError(t, store.CreateAlertRule(ctx, rule))
+
+		retrieved, err := store.GetAlertRule(ctx, "alert-1")
+		require.NoError(t, err)
+		require.NotNil(t, retrieved)
+		assert.Equal(t, rule.Expr, retrieved.Expr)
+		assert.Equal(t, 0.5, retrieved.Threshold)
+		assert.Equal(t, "5m", retrieved.For)
+		assert.Equal(t, rule.Labels, retrieved.Labels)
+		assert.Equal(t, rule.Annotations, retrieved.Annotations)
+
+		rule.Threshold = 1
+		rule.Enabled = false
+		require.NoError(t, store.UpdateAlertRule(ctx, rule))
+
+		rules, err := store.ListAlertRules(ctx)
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		assert.Equal(t, float64(1), rules[0].Threshold)
+		assert.False(t, rules[0].Enabled)
+
+		require.NoError(t, store.DeleteAlertRule(ctx, "alert-1"))
+		retrieved, err = store.GetAlertRule(ctx, "alert-1")
+		require.NoError(t, err)
+		assert.Nil(t, retrieved)
+
+		err = store.DeleteAlertRule(ctx, "alert-1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
+func TestSQLiteSilenceCRUD(t *testing.T) {
+	withSQLiteStore(t, func(store types.ApplicationStore) {
+		ctx := context.Background()
+		now := time.Now().UTC()
+		silence := &types.Silence{
+			ID:        "silence-1",
+			Matchers:  []types.SilenceMatcher{{Name: "service", Operator: "=~", Value: "api|web"}},
+			StartsAt:  now,
+			EndsAt:    now.Add(time.Hour),
+			CreatedBy: "oncall",
+			Comment:   "Deploy in progress",
+			CreatedAt: now,
+		}
+		require.NoError(t, store.CreateSilence(ctx, silence))
+
+		silences, err := store.ListSilences(ctx)
+		require.NoError(t, err)
+		require.Len(t, silences, 1)
+		assert.Equal(t, silence.Matchers, silences[0].Matchers)
+		assert.Equal(t, "oncall", silences[0].CreatedBy)
+		assert.True(t, silence.EndsAt.Equal(silences[0].EndsAt))
+
+		require.NoError(t, store.DeleteSilence(ctx, "silence-1"))
+		err = store.DeleteSilence(ctx, "silence-1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
 // Schema migration tests
 
 func TestSQLiteMigration(t *testing.T) {
@@ -29,6 +29,32 @@ type ApplicationStore interface {
 	GetLatestConfigForAgent(ctx context.Context, agentID uuid.UUID) (*Config, error)
 	GetLatestConfigForGroup(ctx context.Context, groupID string) (*Config, error)
 	ListConfigs(ctx context.Context, filter ConfigFilter) ([]*Config, error)
+
+	// Processing rule management
+	CreateProcessingRule(ctx context.Context, rule *ProcessingRule) error
+	GetProcessingRule(ctx context.Context, id string) (*ProcessingRule, error)
+	ListProcessingRules(ctx context.Context) ([]*ProcessingRule, error)
+	UpdateProcessingRule(ctx context.Context, rule *ProcessingRule) error
+	DeleteProcessingRule(ctx context.Context, id string) error
+
+	// Recording rule management
+	CreateRecordingRule(ctx context.Context, rule *RecordingRule) error
+	GetRecordingRule(ctx context.Context, id string) (*RecordingRule, error)
+	ListRecordingRules(ctx context.Context) ([]*RecordingRule, error)
+	UpdateRecordingRule(ctx context.Context, rule *RecordingRule) error
+	DeleteRecordingRule(ctx context.Context, id string) error
+
+	// Alert rule management
+	CreateAlertRule(ctx context.Context, rule *AlertRule) error
+	GetAlertRule(ctx context.Context, id string) (*AlertRule, error)
+	ListAlertRules(ctx context.Context) ([]*AlertRule, error)
+	UpdateAlertRule(ctx context.Context, rule *AlertRule) error
+	DeleteAlertRule(ctx context.Context, id string) error
+
+	// Silence management
+	CreateSilence(ctx context.Context, silence *Silence) error
+	ListSilences(ctx context.Context) ([]*Silence, error)
+	DeleteSilence(ctx context.Context, id string) error
 }
 
 // Agent represents an OpenTelemetry agent
@@ -83,3 +109,93 @@ type ConfigFilter struct {
 	GroupID *string
 	Limit   int
 }
+
+// ProcessingRule represents an ingest-time rule applied to telemetry of a single signal
+type ProcessingRule struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Signal      string          `json:"signal"`
+	Enabled     bool            `json:"enabled"`
+	Priority    int             `json:"priority"`
+	Conditions  []RuleCondition `json:"conditions"`
+	Actions     []RuleAction    `json:"actions"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// RuleCondition represents a single match condition of a processing rule
+type RuleCondition struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    string `json:"value,omitempty"`
+}
+
+// RuleAction represents a single action of a processing rule
+type RuleAction struct {
+	Type        string `json:"type"`
+	Key         string `json:"key,omitempty"`
+	NewKey      string `json:"new_key,omitempty"`
+	Scope       string `json:"scope,omitempty"`
+	Pattern     string `json:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// RecordingRule represents a Lawrence QL expression evaluated on an interval
+// whose results are stored as a new metric
+type RecordingRule struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Expr        string            `json:"expr"`
+	Interval    string            `json:"interval"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Enabled     bool              `json:"enabled"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// AlertRule represents a Lawrence QL expression evaluated on an interval
+// whose samples fire alerts while they compare true against a threshold
+type AlertRule struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Expr        string            `json:"expr"`
+	Operator    string            `json:"operator"`
+	Threshold   float64           `json:"threshold"`
+	For         string            `json:"for,omitempty"`
+	Interval    string            `json:"interval"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Enabled     bool              `json:"enabled"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// Silence represents a time window during which notifications for alerts
+// matching every matcher are suppressed
+type Silence struct {
+	ID        string           `json:"id"`
+	Matchers  []SilenceMatcher `json:"matchers"`
+	StartsAt  time.Time        `json:"starts_at"`
+	EndsAt    time.Time        `json:"ends_at"`
+	CreatedBy string           `json:"created_by,omitempty"`
+	Comment   string           `json:"comment,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// SilenceMatcher represents a single label match of a silence
+type SilenceMatcher struct {
+	Name     string `json:"name"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+// MigrationStatus describes a schema migration and when it was applied
+type MigrationStatus struct {
+	Version     int        `json:"version"`
+	Description string     `json:"description"`
+	SQL         string     `json:"sql,omitempty"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+}
@@ -2,19 +2,31 @@ package telemetrystore
 
 import (
 	"github.com/getlawrence/lawrence-oss/internal/config"
+	"github.com/getlawrence/lawrence-oss/internal/metrics"
 )
 
 // Config represents the configuration for the telemetry store meta factory
 type Config struct {
-	Type string `yaml:"type"`
-	Path string `yaml:"path"`
+	Type     string `yaml:"type"`
+	Path     string `yaml:"path"`
+	ColdPath string `yaml:"cold_path"`
+
+	ReadConnections int    `yaml:"read_connections"`
+	QueryTimeout    string `yaml:"query_timeout"`
+
+	// MetricsFactory receives storage connection metrics, optional
+	MetricsFactory metrics.Factory `yaml:"-"`
 }
 
 // ConfigFrom creates a Config from the app storage config
 func ConfigFrom(appConfig *config.Config) Config {
 	return Config{
-		Type: appConfig.Storage.Telemetry.Type,
-		Path: appConfig.Storage.Telemetry.Path,
+		Type:     appConfig.Storage.Telemetry.Type,
+		Path:     appConfig.Storage.Telemetry.Path,
+		ColdPath: appConfig.Storage.Telemetry.ColdPath,
+
+		ReadConnections: appConfig.Storage.Telemetry.ReadConnections,
+		QueryTimeout:    appConfig.Storage.Telemetry.QueryTimeout,
 	}
 }
 
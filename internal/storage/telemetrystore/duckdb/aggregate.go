@@ -0,0 +1,279 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	goduckdb "github.com/marcboeker/go-duckdb"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+// metricSeriesColumns identify a metric series
+const metricSeriesColumns = "metric_name, agent_id, service_name, metric_attributes"
+
+// AggregateMetrics aggregates the values of the metrics matching a query,
+// one row per distinct combination of the By fields
+func (s *Storage) AggregateMetrics(ctx context.Context, query types.MetricAggregateQuery) ([]types.Aggregate, error) {
+	ctx, cancel := s.withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := s.beginRead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	source, err := s.metricsSource(ctx, tx, query.Query.StartTime, query.Query.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := metricConditions(query.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.aggregate(ctx, tx, aggregateSpec{
+		source:   source,
+		where:    where,
+		args:     args,
+		fields:   metricFields,
+		function: query.Function,
+		by:       query.By,
+		limit:    query.Query.Limit,
+	})
+}
+
+// AggregateLogs counts the logs matching a query, one row per distinct
+// combination of the By fields, and of log series when BySeries is set
+func (s *Storage) AggregateLogs(ctx context.Context, query types.LogAggregateQuery) ([]types.Aggregate, error) {
+	if query.Function != types.AggregateCount {
+		return nil, fmt.Errorf("unsupported log aggregate: %s", query.Function)
+	}
+
+	ctx, cancel := s.withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := s.beginRead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	source, err := s.tableSource(ctx, tx, "logs", query.Query.StartTime, query.Query.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := logConditions(query.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.aggregate(ctx, tx, aggregateSpec{
+		source:   source,
+		where:    where,
+		args:     args,
+		fields:   logFields,
+		function: query.Function,
+		by:       query.By,
+		bySeries: query.BySeries,
+		limit:    query.Query.Limit,
+	})
+}
+
+// aggregateSpec describes a grouped aggregation over a filtered source
+type aggregateSpec struct {
+	source   string
+	where    string
+	args     []interface{}
+	fields   fieldSet
+	function types.AggregateFunction
+	by       []string
+	// bySeries groups by agent and attribute set as well
+	bySeries bool
+	limit    int
+}
+
+// aggregate runs a grouped aggregation. The timestamp of min and max is that
+// of the extreme point, and of the newest point for the other functions.
+func (s *Storage) aggregate(ctx context.Context, tx *sql.Tx, spec aggregateSpec) ([]types.Aggregate, error) {
+	var valueExpr, timestampExpr string
+	switch spec.function {
+	case types.AggregateSum:
+		valueExpr, timestampExpr = "SUM(value)", "MAX(timestamp)"
+	case types.AggregateAvg:
+		valueExpr, timestampExpr = "AVG(value)", "MAX(timestamp)"
+	case types.AggregateMin:
+		valueExpr, timestampExpr = "MIN(value)", "arg_min(timestamp, value)"
+	case types.AggregateMax:
+		valueExpr, timestampExpr = "MAX(value)", "arg_max(timestamp, value)"
+	case types.AggregateCount:
+		valueExpr, timestampExpr = "COUNT(*)", "MAX(timestamp)"
+	default:
+		return nil, fmt.Errorf("unsupported aggregate: %s", spec.function)
+	}
+
+	columns := make([]string, 0, len(spec.by)+5)
+	groups := make([]string, len(spec.by))
+	for i, field := range spec.by {
+		groups[i] = fmt.Sprintf("group_%d", i)
+		columns = append(columns, fmt.Sprintf("%s AS %s", spec.fields.expr(field), groups[i]))
+	}
+	if spec.bySeries {
+		columns = append(columns, "agent_id AS series_agent", spec.fields.attributesColumn+" AS series_attributes")
+		groups = append(groups, "series_agent", "series_attributes")
+	}
+	columns = append(columns,
+		fmt.Sprintf("CAST(%s AS DOUBLE) AS value", valueExpr),
+		"COUNT(*) AS count",
+		fmt.Sprintf("%s AS timestamp", timestampExpr))
+
+	sqlQuery := fmt.Sprintf(`SELECT %s FROM %s WHERE %s`, strings.Join(columns, ", "), spec.source, spec.where)
+	if len(groups) > 0 {
+		sqlQuery += ` GROUP BY ` + strings.Join(groups, ", ") + ` ORDER BY ` + strings.Join(groups, ", ")
+	} else {
+		// Without groups an empty source still aggregates to one row
+		sqlQuery += ` HAVING COUNT(*) > 0`
+	}
+	args := spec.args
+	if spec.limit > 0 {
+		sqlQuery += ` LIMIT ?`
+		args = append(args, spec.limit)
+	}
+
+	rows, err := tx.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, s.queryError(ctx, fmt.Errorf("failed to aggregate: %w", err))
+	}
+	defer rows.Close()
+
+	var aggregates []types.Aggregate
+	for rows.Next() {
+		var aggregate types.Aggregate
+		labels := make([]sql.NullString, len(spec.by))
+		dest := make([]interface{}, 0, len(groups)+3)
+		for i := range labels {
+			dest = append(dest, &labels[i])
+		}
+		var seriesAgent, seriesAttributes sql.NullString
+		if spec.bySeries {
+			dest = append(dest, &seriesAgent, &seriesAttributes)
+		}
+		var value sql.NullFloat64
+		dest = append(dest, &value, &aggregate.Count, &aggregate.Timestamp)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan aggregate: %w", err)
+		}
+
+		aggregate.Value = value.Float64
+		aggregate.Labels = make(map[string]string, len(groups))
+		if spec.bySeries {
+			aggregate.Labels = otlp.StringifyAttributes(decodeAttributes(seriesAttributes.String))
+			if agentID, err := uuid.Parse(seriesAgent.String); err == nil && agentID != uuid.Nil {
+				aggregate.Labels["agent_id"] = agentID.String()
+			}
+		}
+		for i, label := range labels {
+			if label.Valid {
+				aggregate.Labels[spec.by[i]] = label.String
+			}
+		}
+		aggregates = append(aggregates, aggregate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.queryError(ctx, fmt.Errorf("error iterating aggregates: %w", err))
+	}
+
+	return aggregates, nil
+}
+
+// QueryMetricIncrease summarizes the points of each series matching a query
+// in timestamp order, one summary per series. The query limit does not
+// apply, every matching point is read.
+func (s *Storage) QueryMetricIncrease(ctx context.Context, query types.MetricQuery) ([]types.MetricIncrease, error) {
+	ctx, cancel := s.withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := s.beginRead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	source, err := s.metricsSource(ctx, tx, query.StartTime, query.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := metricConditions(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resets are detected between consecutive points of the same series;
+	// points of different series interleave in time
+	sqlQuery := fmt.Sprintf(`
+		WITH points AS (
+			SELECT %[3]s, timestamp, value, start_timestamp, is_monotonic, temporality,
+			       LAG(value) OVER (PARTITION BY %[3]s ORDER BY timestamp) AS previous
+			FROM %[1]s
+			WHERE %[2]s
+		)
+		SELECT %[3]s, COUNT(*), MIN(timestamp), MAX(timestamp),
+		       first(start_timestamp ORDER BY timestamp), first(value ORDER BY timestamp),
+		       last(value ORDER BY timestamp), COALESCE(SUM(value), 0),
+		       COALESCE(SUM(CASE
+		           WHEN previous IS NULL THEN 0
+		           WHEN value < previous THEN value
+		           ELSE value - previous
+		       END), 0),
+		       first(is_monotonic ORDER BY timestamp),
+		       list(DISTINCT COALESCE(temporality, ''))
+		FROM points
+		GROUP BY %[3]s
+		ORDER BY %[3]s
+	`, source, where, metricSeriesColumns)
+
+	rows, err := tx.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, s.queryError(ctx, fmt.Errorf("failed to query metric increase: %w", err))
+	}
+	defer rows.Close()
+
+	var increases []types.MetricIncrease
+	for rows.Next() {
+		var increase types.MetricIncrease
+		var agentID, attrsJSON sql.NullString
+		var firstTimestamp, lastTimestamp, firstStart sql.NullTime
+		var firstValue, lastValue sql.NullFloat64
+		var monotonic sql.NullBool
+		var temporalities goduckdb.Composite[[]string]
+		err := rows.Scan(&increase.MetricName, &agentID, &increase.ServiceName, &attrsJSON,
+			&increase.Points, &firstTimestamp, &lastTimestamp, &firstStart, &firstValue, &lastValue,
+			&increase.Sum, &increase.ResetIncrease, &monotonic, &temporalities)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan metric increase: %w", err)
+		}
+
+		increase.AgentID, _ = uuid.Parse(agentID.String)
+		increase.Labels = otlp.StringifyAttributes(decodeAttributes(attrsJSON.String))
+		increase.FirstTimestamp = firstTimestamp.Time
+		increase.LastTimestamp = lastTimestamp.Time
+		if firstStart.Valid {
+			increase.FirstStartTime = &firstStart.Time
+		}
+		increase.FirstValue = firstValue.Float64
+		increase.LastValue = lastValue.Float64
+		increase.Monotonic = monotonic.Bool
+		increase.Temporalities = temporalities.Get()
+		increases = append(increases, increase)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.queryError(ctx, fmt.Errorf("error iterating metric increases: %w", err))
+	}
+
+	return increases, nil
+}
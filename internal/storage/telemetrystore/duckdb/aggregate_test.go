@@ -0,0 +1,229 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+// writeFilterFixture writes gauges for three hosts and returns the time range
+// covering them
+func writeFilterFixture(t *testing.T, storage *Storage) (time.Time, time.Time) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	agentID := uuid.New().String()
+	gauges := []otlp.MetricGaugeData{
+		{AgentID: agentID, ServiceName: "api", MetricName: "cpu", TimeUnix: base, Value: 1,
+			Attributes: map[string]interface{}{"host.name": "prod-1", "cores": int64(4)}},
+		{AgentID: agentID, ServiceName: "api", MetricName: "cpu", TimeUnix: base.Add(time.Minute), Value: 3,
+			Attributes: map[string]interface{}{"host.name": "prod-2", "cores": int64(8)}},
+		{AgentID: agentID, ServiceName: "worker", MetricName: "cpu", TimeUnix: base.Add(2 * time.Minute), Value: 8,
+			Attributes: map[string]interface{}{"host.name": "dev-1"}},
+	}
+	require.NoError(t, storage.WriteMetricsFromOTLP(context.Background(), &otlp.MetricsData{Gauges: gauges}))
+	return base, base.Add(time.Hour)
+}
+
+func TestQueryMetrics_Filters(t *testing.T) {
+	storage := newTestStorage(t)
+	start, end := writeFilterFixture(t, storage)
+
+	tests := []struct {
+		name   string
+		filter types.Filter
+		values []float64
+	}{
+		{"equal", types.Filter{Field: "host.name", Operator: types.FilterOpEqual, Value: "prod-2"}, []float64{3}},
+		{"not equal matches missing", types.Filter{Field: "cores", Operator: types.FilterOpNotEqual, Value: "4", Numeric: true}, []float64{8, 3}},
+		{"regex", types.Filter{Field: "host.name", Operator: types.FilterOpRegex, Value: "^prod-"}, []float64{3, 1}},
+		{"not regex", types.Filter{Field: "host.name", Operator: types.FilterOpNotRegex, Value: "^prod-"}, []float64{8}},
+		{"numeric", types.Filter{Field: "cores", Operator: types.FilterOpGT, Value: "4", Numeric: true}, []float64{3}},
+		{"column", types.Filter{Field: "service_name", Operator: types.FilterOpNotEqual, Value: "api"}, []float64{8}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics, err := storage.QueryMetrics(context.Background(), types.MetricQuery{
+				Filters: []types.Filter{tt.filter}, StartTime: start, EndTime: end,
+			})
+			require.NoError(t, err)
+			values := make([]float64, len(metrics))
+			for i, m := range metrics {
+				values[i] = m.Value
+			}
+			assert.Equal(t, tt.values, values)
+		})
+	}
+
+	_, err := storage.QueryMetrics(context.Background(), types.MetricQuery{
+		Filters:   []types.Filter{{Field: "host.name", Operator: types.FilterOpRegex, Value: "("}},
+		StartTime: start, EndTime: end,
+	})
+	assert.Error(t, err)
+}
+
+func TestAggregateMetrics(t *testing.T) {
+	storage := newTestStorage(t)
+	start, end := writeFilterFixture(t, storage)
+	query := types.MetricQuery{MetricName: strPtr("cpu"), StartTime: start, EndTime: end}
+
+	aggregates, err := storage.AggregateMetrics(context.Background(), types.MetricAggregateQuery{
+		Query: query, Function: types.AggregateSum, By: []string{"service_name"},
+	})
+	require.NoError(t, err)
+	require.Len(t, aggregates, 2)
+	assert.Equal(t, map[string]string{"service_name": "api"}, aggregates[0].Labels)
+	assert.Equal(t, 4.0, aggregates[0].Value)
+	assert.Equal(t, int64(2), aggregates[0].Count)
+	assert.Equal(t, start.Add(time.Minute), aggregates[0].Timestamp.UTC())
+	assert.Equal(t, 8.0, aggregates[1].Value)
+
+	// Groups without the field have no label for it
+	aggregates, err = storage.AggregateMetrics(context.Background(), types.MetricAggregateQuery{
+		Query: query, Function: types.AggregateMin, By: []string{"cores"},
+	})
+	require.NoError(t, err)
+	require.Len(t, aggregates, 3)
+	assert.Equal(t, map[string]string{"cores": "4"}, aggregates[0].Labels)
+	assert.Empty(t, aggregates[2].Labels)
+	assert.Equal(t, start.Add(2*time.Minute), aggregates[2].Timestamp.UTC())
+
+	aggregates, err = storage.AggregateMetrics(context.Background(), types.MetricAggregateQuery{
+		Query: query, Function: types.AggregateMax,
+	})
+	require.NoError(t, err)
+	require.Len(t, aggregates, 1)
+	assert.Equal(t, 8.0, aggregates[0].Value)
+	assert.Equal(t, int64(3), aggregates[0].Count)
+
+	// An empty selection has no groups
+	query.MetricName = strPtr("missing")
+	aggregates, err = storage.AggregateMetrics(context.Background(), types.MetricAggregateQuery{
+		Query: query, Function: types.AggregateAvg,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, aggregates)
+}
+
+func TestAggregateLogs(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	agentID := uuid.New().String()
+
+	logs := []otlp.LogData{
+		{AgentID: agentID, Timestamp: base, ServiceName: "api", SeverityText: "ERROR", Body: "timeout"},
+		{AgentID: agentID, Timestamp: base.Add(time.Second), ServiceName: "api", SeverityText: "INFO", Body: "ok"},
+		{AgentID: agentID, Timestamp: base.Add(2 * time.Second), ServiceName: "api", SeverityText: "ERROR", Body: "refused"},
+	}
+	require.NoError(t, storage.WriteLogsFromOTLP(ctx, logs))
+
+	aggregates, err := storage.AggregateLogs(ctx, types.LogAggregateQuery{
+		Query:    types.LogQuery{StartTime: base, EndTime: base.Add(time.Hour)},
+		Function: types.AggregateCount,
+		By:       []string{"severity"},
+	})
+	require.NoError(t, err)
+	require.Len(t, aggregates, 2)
+	assert.Equal(t, map[string]string{"severity": "ERROR"}, aggregates[0].Labels)
+	assert.Equal(t, 2.0, aggregates[0].Value)
+
+	// Grouping by series adds the agent and attributes of each log
+	aggregates, err = storage.AggregateLogs(ctx, types.LogAggregateQuery{
+		Query:    types.LogQuery{StartTime: base, EndTime: base.Add(time.Hour)},
+		Function: types.AggregateCount,
+		By:       []string{"severity"},
+		BySeries: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, aggregates, 2)
+	assert.Equal(t, map[string]string{"severity": "ERROR", "agent_id": agentID}, aggregates[0].Labels)
+	assert.Equal(t, 2.0, aggregates[0].Value)
+
+	_, err = storage.AggregateLogs(ctx, types.LogAggregateQuery{Function: types.AggregateSum})
+	assert.Error(t, err)
+}
+
+func TestQueryMetricIncrease(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	agentID := uuid.New().String()
+
+	var sums []otlp.MetricSumData
+	for i, value := range []float64{10, 15, 4, 9} {
+		sums = append(sums, otlp.MetricSumData{
+			AgentID: agentID, ServiceName: "api", MetricName: "requests", TimeUnix: base.Add(time.Duration(i) * time.Minute),
+			Value: value, IsMonotonic: true, AggregationTemporality: 2,
+		})
+	}
+	require.NoError(t, storage.WriteMetricsFromOTLP(ctx, &otlp.MetricsData{Sums: sums}))
+
+	increases, err := storage.QueryMetricIncrease(ctx, types.MetricQuery{
+		MetricName: strPtr("requests"), StartTime: base, EndTime: base.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Len(t, increases, 1)
+	increase := increases[0]
+	assert.Equal(t, "requests", increase.MetricName)
+	assert.Equal(t, agentID, increase.AgentID.String())
+	assert.Equal(t, int64(4), increase.Points)
+	assert.Equal(t, base, increase.FirstTimestamp.UTC())
+	assert.Equal(t, base.Add(3*time.Minute), increase.LastTimestamp.UTC())
+	assert.Equal(t, 10.0, increase.FirstValue)
+	assert.Equal(t, 9.0, increase.LastValue)
+	assert.Equal(t, 38.0, increase.Sum)
+	// 5, then a reset counting up to 4, then 5
+	assert.Equal(t, 14.0, increase.ResetIncrease)
+	assert.True(t, increase.Monotonic)
+	assert.Equal(t, []string{"cumulative"}, increase.Temporalities)
+
+	increases, err = storage.QueryMetricIncrease(ctx, types.MetricQuery{
+		MetricName: strPtr("missing"), StartTime: base, EndTime: base.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, increases)
+}
+
+func TestQueryMetricIncrease_PerSeries(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	agentID := uuid.New().String()
+
+	// Two counters with interleaved points; across series every step from
+	// host a to host b would look like a reset
+	var sums []otlp.MetricSumData
+	for i := 0; i < 4; i++ {
+		for host, value := range map[string]float64{"a": 100 + float64(i)*10, "b": 5 + float64(i)} {
+			sums = append(sums, otlp.MetricSumData{
+				AgentID: agentID, ServiceName: "api", MetricName: "requests", TimeUnix: base.Add(time.Duration(i) * time.Minute),
+				Value: value, IsMonotonic: true, AggregationTemporality: 2, Attributes: map[string]interface{}{"host": host},
+			})
+		}
+	}
+	require.NoError(t, storage.WriteMetricsFromOTLP(ctx, &otlp.MetricsData{Sums: sums}))
+
+	increases, err := storage.QueryMetricIncrease(ctx, types.MetricQuery{
+		MetricName: strPtr("requests"), StartTime: base, EndTime: base.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Len(t, increases, 2)
+	byHost := make(map[string]types.MetricIncrease)
+	for _, increase := range increases {
+		byHost[increase.Labels["host"]] = increase
+	}
+	assert.Equal(t, 30.0, byHost["a"].ResetIncrease)
+	assert.Equal(t, 3.0, byHost["b"].ResetIncrease)
+	assert.Equal(t, int64(4), byHost["b"].Points)
+	assert.Equal(t, "api", byHost["b"].ServiceName)
+}
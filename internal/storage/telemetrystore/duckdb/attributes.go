@@ -0,0 +1,68 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"go.uber.org/zap"
+)
+
+// encodeAttributes renders attributes for a JSON attribute column. Values
+// JSON cannot represent fail the whole map, so it is stored as an empty
+// object rather than an empty string that breaks every JSON filter on the
+// table.
+func (s *Storage) encodeAttributes(attrs interface{}) string {
+	encoded, err := json.Marshal(attrs)
+	if err != nil {
+		s.logger.Warn("Failed to encode attributes, storing them empty", zap.Error(err))
+		return "{}"
+	}
+	return string(encoded)
+}
+
+// decodeAttributes parses a JSON attribute column. Integers are returned as
+// int64 and other numbers as float64 so attribute types survive a round trip.
+func decodeAttributes(data string) map[string]interface{} {
+	if data == "" {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(data)))
+	decoder.UseNumber()
+
+	var attrs map[string]interface{}
+	if err := decoder.Decode(&attrs); err != nil {
+		return nil
+	}
+	for key, value := range attrs {
+		attrs[key] = normalizeNumbers(value)
+	}
+	return attrs
+}
+
+// normalizeNumbers converts json.Number values to int64 or float64, recursing
+// into arrays and objects
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeNumbers(item)
+		}
+		return v
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeNumbers(item)
+		}
+		return v
+	}
+	return value
+}
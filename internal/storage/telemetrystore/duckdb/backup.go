@@ -0,0 +1,244 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// backupDatabaseDir holds the EXPORT DATABASE output within a backup
+	backupDatabaseDir = "database"
+	// backupColdDir holds copies of the sealed partitions within a backup
+	backupColdDir = "cold"
+)
+
+// Backup writes a consistent snapshot of the database and its sealed
+// partitions into dir. The export runs in a read transaction, so ingestion
+// continues while it runs.
+func (s *Storage) Backup(ctx context.Context, dir string) error {
+	tx, err := s.beginRead(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	exportDir := filepath.Join(dir, backupDatabaseDir)
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("EXPORT DATABASE %s (FORMAT PARQUET)", stringLiteral(exportDir))); err != nil {
+		return fmt.Errorf("failed to export database: %w", err)
+	}
+
+	// Partitions dropped after the snapshot keep their files until the
+	// removal grace period ends, so every listed file can still be copied
+	for _, table := range partitionedTables {
+		partitions, err := s.listPartitions(ctx, tx, table, nil, nil)
+		if err != nil {
+			return err
+		}
+		for _, partition := range partitions {
+			if err := copyFile(partition.Path, filepath.Join(dir, backupPartitionPath(partition.Table, partition.Day.Format(time.DateOnly), partition.Path))); err != nil {
+				return fmt.Errorf("failed to copy partition %s: %w", partition.Path, err)
+			}
+		}
+	}
+
+	s.logger.Info("Backed up DuckDB telemetry store", zap.String("dir", dir))
+	return nil
+}
+
+// backupPartitionPath returns the path of a partition file within a backup
+func backupPartitionPath(table, day, path string) string {
+	return filepath.Join(backupColdDir, table, day, filepath.Base(path))
+}
+
+// loadPathPattern matches the file paths in a load.sql written by EXPORT DATABASE
+var loadPathPattern = regexp.MustCompile(`FROM '(?:[^']|'')*?([^/'\\]+\.parquet)'`)
+
+// restorePaths returns where a restore stages the database and partitions,
+// next to the ones they replace
+func restorePaths(dbPath, coldPath string) (string, string, error) {
+	absColdPath, err := filepath.Abs(coldPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve cold path: %w", err)
+	}
+	return dbPath + ".restore", absColdPath + ".restore", nil
+}
+
+// prepareRestore imports the snapshot in dir into a new database and copies
+// its partitions into a staging directory, leaving the existing database and
+// partitions untouched until commitRestore swaps them
+func prepareRestore(ctx context.Context, dbPath, coldPath, dir string) error {
+	exportDir, err := filepath.Abs(filepath.Join(dir, backupDatabaseDir))
+	if err != nil {
+		return fmt.Errorf("failed to resolve backup path: %w", err)
+	}
+	absColdPath, err := filepath.Abs(coldPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve cold path: %w", err)
+	}
+	restorePath, stagingPath, err := restorePaths(dbPath, coldPath)
+	if err != nil {
+		return err
+	}
+
+	// EXPORT DATABASE writes absolute paths to load.sql, so point them at
+	// where the backup was extracted
+	loadPath := filepath.Join(exportDir, "load.sql")
+	load, err := os.ReadFile(loadPath)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	load = loadPathPattern.ReplaceAllFunc(load, func(match []byte) []byte {
+		name := loadPathPattern.FindSubmatch(match)[1]
+		return []byte("FROM " + stringLiteral(filepath.Join(exportDir, string(name))))
+	})
+	if err := os.WriteFile(loadPath, load, 0o644); err != nil {
+		return fmt.Errorf("failed to prepare backup: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+	if err := discardRestore(dbPath, coldPath); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("duckdb", restorePath)
+	if err != nil {
+		return fmt.Errorf("failed to open DuckDB database: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, "IMPORT DATABASE "+stringLiteral(exportDir)); err != nil {
+		_ = db.Close()
+		return fmt.Errorf("failed to import database: %w", err)
+	}
+	if err := restorePartitions(ctx, db, dir, stagingPath, absColdPath); err != nil {
+		_ = db.Close()
+		return err
+	}
+	if _, err := db.ExecContext(ctx, "CHECKPOINT"); err != nil {
+		_ = db.Close()
+		return fmt.Errorf("failed to checkpoint restored database: %w", err)
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close restored database: %w", err)
+	}
+	_ = os.Remove(restorePath + ".wal")
+	return nil
+}
+
+// commitRestore swaps the database and partitions staged by prepareRestore
+// in. The partitions are swapped only once the database was replaced, and
+// the existing ones are put back if that fails.
+func commitRestore(dbPath, coldPath string) error {
+	restorePath, stagingPath, err := restorePaths(dbPath, coldPath)
+	if err != nil {
+		return err
+	}
+	absColdPath := strings.TrimSuffix(stagingPath, ".restore")
+
+	_ = os.Remove(dbPath + ".wal")
+	if err := os.Rename(restorePath, dbPath); err != nil {
+		_ = discardRestore(dbPath, coldPath)
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+
+	oldPath := absColdPath + ".old"
+	if err := os.RemoveAll(oldPath); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", oldPath, err)
+	}
+	if err := os.Rename(absColdPath, oldPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to move partitions aside: %w", err)
+	}
+	if err := os.Rename(stagingPath, absColdPath); err != nil {
+		_ = os.Rename(oldPath, absColdPath)
+		return fmt.Errorf("failed to replace partitions: %w", err)
+	}
+	_ = os.RemoveAll(oldPath)
+	return nil
+}
+
+// discardRestore removes what prepareRestore staged
+func discardRestore(dbPath, coldPath string) error {
+	restorePath, stagingPath, err := restorePaths(dbPath, coldPath)
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{restorePath, restorePath + ".wal"} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to discard restored database: %w", err)
+		}
+	}
+	if err := os.RemoveAll(stagingPath); err != nil {
+		return fmt.Errorf("failed to discard restored partitions: %w", err)
+	}
+	return nil
+}
+
+// restorePartitions copies the partition files of a backup into the staging
+// path and points the partition records at where they end up in the cold
+// path once the restore is committed
+func restorePartitions(ctx context.Context, db *sql.DB, dir, stagingPath, coldPath string) error {
+	rows, err := db.QueryContext(ctx, `SELECT path, table_name, strftime(day, '%Y-%m-%d') FROM telemetry_partitions`)
+	if err != nil {
+		return fmt.Errorf("failed to list partitions: %w", err)
+	}
+	moved := make(map[string]string)
+	for rows.Next() {
+		var path, table, day string
+		if err := rows.Scan(&path, &table, &day); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to scan partition: %w", err)
+		}
+		relative := backupPartitionPath(table, day, path)
+		target := filepath.Join(table, day, filepath.Base(path))
+		if err := copyFile(filepath.Join(dir, relative), filepath.Join(stagingPath, target)); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to restore partition %s: %w", relative, err)
+		}
+		moved[path] = filepath.Join(coldPath, target)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	for oldPath, newPath := range moved {
+		if _, err := db.ExecContext(ctx, `UPDATE telemetry_partitions SET path = ? WHERE path = ?`, newPath, oldPath); err != nil {
+			return fmt.Errorf("failed to update partition %s: %w", oldPath, err)
+		}
+	}
+	return nil
+}
+
+// copyFile copies a file, creating the parent directories of dst
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
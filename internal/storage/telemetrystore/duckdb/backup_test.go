@@ -0,0 +1,107 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+func TestBackupAndRestore_IncludesSealedPartitions(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	dbPath := filepath.Join(dataDir, "telemetry.db")
+	backupDir := t.TempDir()
+	today := time.Now().UTC().Truncate(partitionDay)
+
+	factory := NewFactory(dbPath, Options{})
+	require.NoError(t, factory.Initialize(zap.NewNop()))
+	writeTestLogsAt(t, factory.storage, "api", "prod", today.Add(-36*time.Hour), today.Add(time.Minute))
+	_, err := factory.storage.SealPartitions(ctx, today)
+	require.NoError(t, err)
+
+	require.NoError(t, factory.Backup(ctx, backupDir))
+	assert.ErrorContains(t, factory.PrepareRestore(ctx, backupDir), "cannot restore an open telemetry store")
+
+	// Changes after the backup are discarded by the restore
+	writeTestLogsAt(t, factory.storage, "api", "prod", today.Add(2*time.Minute))
+	require.NoError(t, factory.Close())
+
+	// Restore into a different location to check paths are re-rooted
+	restoredDir := t.TempDir()
+	restored := NewFactory(filepath.Join(restoredDir, "telemetry.db"), Options{})
+	require.NoError(t, restored.PrepareRestore(ctx, backupDir))
+	require.NoError(t, restored.CommitRestore())
+	require.NoError(t, restored.Initialize(zap.NewNop()))
+	defer restored.Close()
+
+	logs, err := restored.storage.QueryLogs(ctx, types.LogQuery{
+		StartTime: today.Add(-72 * time.Hour),
+		EndTime:   today.Add(time.Hour),
+		Limit:     100,
+	})
+	require.NoError(t, err)
+	assert.Len(t, logs, 2)
+
+	partitions, err := restored.storage.listPartitions(ctx, restored.storage.db, "logs", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, partitions, 1)
+	assert.True(t, strings.HasPrefix(partitions[0].Path, restoredDir), partitions[0].Path)
+	_, err = os.Stat(partitions[0].Path)
+	assert.NoError(t, err)
+}
+
+func TestRestore_KeepsPartitionsWhenRestoringThemFails(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	dbPath := filepath.Join(dataDir, "telemetry.db")
+	backupDir := t.TempDir()
+	today := time.Now().UTC().Truncate(partitionDay)
+
+	factory := NewFactory(dbPath, Options{})
+	require.NoError(t, factory.Initialize(zap.NewNop()))
+	writeTestLogsAt(t, factory.storage, "api", "prod", today.Add(-36*time.Hour), today.Add(time.Minute))
+	_, err := factory.storage.SealPartitions(ctx, today)
+	require.NoError(t, err)
+	require.NoError(t, factory.Backup(ctx, backupDir))
+
+	partitions, err := factory.storage.listPartitions(ctx, factory.storage.db, "logs", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, partitions, 1)
+	require.NoError(t, factory.Close())
+
+	// A partition missing from the backup fails the restore after the
+	// database was imported
+	backupCold := filepath.Join(backupDir, backupColdDir)
+	require.NoError(t, os.RemoveAll(backupCold))
+	require.ErrorContains(t, NewFactory(dbPath, Options{}).PrepareRestore(ctx, backupDir), "failed to restore partition")
+
+	_, err = os.Stat(partitions[0].Path)
+	assert.NoError(t, err, "existing partitions are kept")
+	for _, staged := range []string{dbPath + ".restore", filepath.Join(dataDir, "cold.restore")} {
+		_, err = os.Stat(staged)
+		assert.True(t, os.IsNotExist(err), "%s is discarded", staged)
+	}
+
+	reopened := NewFactory(dbPath, Options{})
+	require.NoError(t, reopened.Initialize(zap.NewNop()))
+	defer reopened.Close()
+	logs, err := reopened.storage.QueryLogs(ctx, types.LogQuery{
+		StartTime: today.Add(-72 * time.Hour),
+		EndTime:   today.Add(time.Hour),
+		Limit:     100,
+	})
+	require.NoError(t, err)
+	assert.Len(t, logs, 2)
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	goduckdb "github.com/marcboeker/go-duckdb"
+	"go.uber.org/zap"
+)
+
+// sharedConnector lets the writer connection and the read pool open
+// connections to the same database. It hides the connector's Close, so
+// closing either pool leaves the database open for the other.
+type sharedConnector struct {
+	driver.Connector
+}
+
+// writerConn acquires the writer connection, waiting for any write in
+// progress to finish
+func (s *Storage) writerConn(ctx context.Context) (*sql.Conn, error) {
+	start := time.Now()
+	conn, err := s.writeDB.Conn(ctx)
+	s.metrics.WriterLockWait.Record(time.Since(start))
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire writer connection: %w", err)
+	}
+	return conn, nil
+}
+
+// beginWrite starts a transaction on the writer connection. The writer is
+// held until the transaction ends, so no other writer helper may be called
+// before then.
+func (s *Storage) beginWrite(ctx context.Context) (*sql.Tx, error) {
+	start := time.Now()
+	tx, err := s.writeDB.BeginTx(ctx, nil)
+	s.metrics.WriterLockWait.Record(time.Since(start))
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// execWrite executes a single statement on the writer connection
+func (s *Storage) execWrite(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	conn, err := s.writerConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ExecContext(ctx, query, args...)
+}
+
+// appendRows bulk loads rows into a table with the DuckDB appender on the
+// writer connection. Each row lists every column of the table in order. The
+// rows are appended in one transaction, so a failed batch leaves nothing behind.
+func (s *Storage) appendRows(ctx context.Context, table string, rows [][]driver.Value) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	conn, err := s.writerConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN TRANSACTION"); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		appender, err := goduckdb.NewAppenderFromConn(driverConn.(driver.Conn), "", table)
+		if err != nil {
+			return fmt.Errorf("failed to create appender for %s: %w", table, err)
+		}
+		for _, row := range rows {
+			if err := appender.AppendRow(row...); err != nil {
+				_ = appender.Close()
+				return fmt.Errorf("failed to append row to %s: %w", table, err)
+			}
+		}
+		if err := appender.Close(); err != nil {
+			return fmt.Errorf("failed to flush rows to %s: %w", table, err)
+		}
+		return nil
+	})
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "COMMIT")
+	}
+	if err != nil {
+		// The request context may be done, but the rollback must still run
+		// before the connection is reused
+		_, _ = conn.ExecContext(context.Background(), "ROLLBACK")
+		return err
+	}
+
+	s.metrics.AppendedRows.Inc(int64(len(rows)))
+	return nil
+}
+
+// withQueryTimeout bounds a read by the configured query timeout. The
+// caller's deadline still applies when it is earlier.
+func (s *Storage) withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+// beginRead starts a transaction on the read pool. Every statement in it sees
+// the same snapshot, so rows moving from a hot table into a sealed partition
+// are never missed nor read twice.
+func (s *Storage) beginRead(ctx context.Context) (*sql.Tx, error) {
+	start := time.Now()
+	tx, err := s.db.BeginTx(ctx, nil)
+	s.metrics.ReaderWait.Record(time.Since(start))
+	if err != nil {
+		return nil, s.queryError(ctx, fmt.Errorf("failed to begin read transaction: %w", err))
+	}
+	return tx, nil
+}
+
+// queryError records why a read failed when its context ended, and wraps
+// the context error so callers can tell timeouts from other failures
+func (s *Storage) queryError(ctx context.Context, err error) error {
+	switch ctxErr := ctx.Err(); {
+	case errors.Is(ctxErr, context.DeadlineExceeded):
+		s.metrics.QueryTimeouts.Inc(1)
+		return fmt.Errorf("%w: %w", ctxErr, err)
+	case errors.Is(ctxErr, context.Canceled):
+		s.metrics.QueryCancellations.Inc(1)
+		return fmt.Errorf("%w: %w", ctxErr, err)
+	}
+	return err
+}
+
+// removeFile deletes a file no longer referenced by the database. Reads that
+// started before it was unreferenced may still be scanning it, so removal
+// waits for them to reach the query timeout.
+func (s *Storage) removeFile(path string) {
+	if s.queryTimeout <= 0 {
+		s.removeFileNow(path)
+		return
+	}
+
+	s.removalsMu.Lock()
+	defer s.removalsMu.Unlock()
+	if s.removals == nil {
+		s.removals = make(map[string]*time.Timer)
+	}
+	s.removals[path] = time.AfterFunc(s.queryTimeout, func() {
+		s.removalsMu.Lock()
+		delete(s.removals, path)
+		s.removalsMu.Unlock()
+		s.removeFileNow(path)
+	})
+}
+
+// flushRemovals deletes files still waiting for removal
+func (s *Storage) flushRemovals() {
+	s.removalsMu.Lock()
+	defer s.removalsMu.Unlock()
+	for path, timer := range s.removals {
+		if timer.Stop() {
+			s.removeFileNow(path)
+		}
+	}
+	s.removals = nil
+}
+
+func (s *Storage) removeFileNow(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		s.logger.Warn("Failed to remove partition file", zap.String("path", path), zap.Error(err))
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/metrics"
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+func newTestStorageWithOptions(t *testing.T, opts Options) *Storage {
+	storage, err := NewStorageWithOptions(filepath.Join(t.TempDir(), "telemetry.db"), opts, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = storage.Close() })
+	return storage
+}
+
+func TestQueryTimeout_CancelsQuery(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	storage := newTestStorageWithOptions(t, Options{
+		QueryTimeout: 50 * time.Millisecond,
+		Metrics:      metrics.NewStorageMetrics(metrics.NewPrometheusFactory("lawrence", registry)),
+	})
+
+	start := time.Now()
+	_, err := storage.QueryRaw(context.Background(), "SELECT COUNT(*) FROM range(100000000000) a, range(10) b")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	expected := `
+# HELP lawrence_storage_query_timeouts_total Total number of telemetry queries that exceeded the query timeout
+# TYPE lawrence_storage_query_timeouts_total counter
+lawrence_storage_query_timeouts_total{component="storage"} 1
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "lawrence_storage_query_timeouts_total"))
+}
+
+func TestQueryCancellation_FromCaller(t *testing.T) {
+	storage := newTestStorage(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := storage.QueryRaw(ctx, "SELECT COUNT(*) FROM range(100000000000) a, range(10) b")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestReadsProceedWhileWriterHeld(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	writeTestLogs(t, storage, "api", "prod", time.Minute)
+
+	// Hold the writer with an uncommitted write
+	tx, err := storage.beginWrite(ctx)
+	require.NoError(t, err)
+	defer func() { _ = tx.Rollback() }()
+	_, err = tx.ExecContext(ctx, "DELETE FROM logs")
+	require.NoError(t, err)
+
+	readCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	logs, err := storage.QueryLogs(readCtx, types.LogQuery{
+		StartTime: time.Now().Add(-time.Hour),
+		EndTime:   time.Now(),
+	})
+	require.NoError(t, err)
+	assert.Len(t, logs, 1, "reads see the last committed snapshot")
+
+	// Other writers queue behind the held connection
+	writeCtx, cancelWrite := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelWrite()
+	err = storage.WriteLogsFromOTLP(writeCtx, []otlp.LogData{{Timestamp: time.Now(), AgentID: "agent", ServiceName: "api"}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, tx.Rollback())
+	writeTestLogs(t, storage, "api", "prod", time.Minute)
+	assert.Equal(t, int64(2), countRows(t, storage, "logs"))
+}
+
+func TestAppendRows_WritesAllSignals(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	storage := newTestStorageWithOptions(t, Options{
+		Metrics: metrics.NewStorageMetrics(metrics.NewPrometheusFactory("lawrence", registry)),
+	})
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, storage.WriteTracesFromOTLP(ctx, []otlp.TraceData{{
+		Timestamp: now, AgentID: "agent", TraceId: "t1", SpanId: "s1", ServiceName: "api",
+		SpanName: "GET /", Duration: 10, StatusCode: "OK",
+		SpanAttributes: map[string]interface{}{"http.status_code": int64(200)},
+	}}))
+	require.NoError(t, storage.WriteMetricsFromOTLP(ctx, &otlp.MetricsData{
+		Sums:   []otlp.MetricSumData{{TimeUnix: now, AgentID: "agent", ServiceName: "api", MetricName: "requests", Value: 1}},
+		Gauges: []otlp.MetricGaugeData{{TimeUnix: now, AgentID: "agent", ServiceName: "api", MetricName: "cpu", Value: 0.5}},
+		Histograms: []otlp.MetricHistogramData{{
+			TimeUnix: now, AgentID: "agent", ServiceName: "api", MetricName: "latency",
+			Count: 3, Sum: 6, BucketCounts: []uint64{1, 2}, ExplicitBounds: []float64{5},
+		}},
+	}))
+
+	for _, table := range []string{"traces", "metrics_sum", "metrics_gauge", "metrics_histogram"} {
+		assert.Equal(t, int64(1), countRows(t, storage, table), table)
+	}
+
+	var parentSpanID *string
+	require.NoError(t, storage.db.QueryRow("SELECT parent_span_id FROM traces").Scan(&parentSpanID))
+	assert.Nil(t, parentSpanID)
+
+	var bucketCounts string
+	require.NoError(t, storage.db.QueryRow("SELECT CAST(bucket_counts AS VARCHAR) FROM metrics_histogram").Scan(&bucketCounts))
+	assert.Equal(t, "[1, 2]", bucketCounts)
+
+	expected := `
+# HELP lawrence_storage_appended_rows_total Total number of rows appended to the telemetry store
+# TYPE lawrence_storage_appended_rows_total counter
+lawrence_storage_appended_rows_total{component="storage"} 4
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "lawrence_storage_appended_rows_total"))
+}
+
+func TestAppendRows_KeepsOTLPMetadata(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	start := now.Add(-time.Minute)
+
+	require.NoError(t, storage.WriteMetricsFromOTLP(ctx, &otlp.MetricsData{
+		Sums: []otlp.MetricSumData{
+			{TimeUnix: now, StartTimeUnix: start, AgentID: "agent", ServiceName: "api", MetricName: "requests", Value: 5,
+				MetricUnit: "1", ScopeName: "otelhttp", ScopeVersion: "0.49.0", AggregationTemporality: 1, IsMonotonic: true},
+		},
+		Gauges: []otlp.MetricGaugeData{
+			{TimeUnix: now, StartTimeUnix: time.Unix(0, 0), AgentID: "agent", ServiceName: "api", MetricName: "cpu", Value: 0.5, MetricUnit: "%"},
+		},
+	}))
+	require.NoError(t, storage.WriteLogsFromOTLP(ctx, []otlp.LogData{{
+		Timestamp: now, AgentID: "agent", ServiceName: "api", Body: "hello",
+		ScopeName: "logger", ScopeVersion: "1.0", TraceFlags: 1,
+	}}))
+	require.NoError(t, storage.WriteTracesFromOTLP(ctx, []otlp.TraceData{{
+		Timestamp: now, AgentID: "agent", TraceId: "t1", SpanId: "s1", ServiceName: "api", SpanName: "GET /",
+		SpanKind: 2, TraceState: "vendor=1", ScopeName: "otelhttp", StatusMessage: "not found",
+	}}))
+
+	metricsByName := map[string]types.Metric{}
+	result, err := storage.QueryMetrics(ctx, types.MetricQuery{StartTime: start, EndTime: now})
+	require.NoError(t, err)
+	for _, m := range result {
+		metricsByName[m.Name] = m
+	}
+
+	requests := metricsByName["requests"]
+	assert.Equal(t, types.MetricTypeCounter, requests.Type)
+	assert.Equal(t, "1", requests.Unit)
+	assert.Equal(t, "otelhttp", requests.ScopeName)
+	assert.Equal(t, "0.49.0", requests.ScopeVersion)
+	assert.Equal(t, "delta", requests.Temporality)
+	assert.True(t, requests.IsMonotonic)
+	require.NotNil(t, requests.StartTime)
+	assert.True(t, start.Equal(*requests.StartTime))
+
+	cpu := metricsByName["cpu"]
+	assert.Equal(t, types.MetricTypeGauge, cpu.Type)
+	assert.Equal(t, "%", cpu.Unit)
+	assert.Empty(t, cpu.Temporality)
+	assert.Nil(t, cpu.StartTime, "an unset start time is stored as NULL")
+
+	temporality := "cumulative"
+	filtered, err := storage.QueryMetrics(ctx, types.MetricQuery{StartTime: start, EndTime: now, Temporality: &temporality})
+	require.NoError(t, err)
+	assert.Empty(t, filtered)
+
+	logs, err := storage.QueryLogs(ctx, types.LogQuery{StartTime: start, EndTime: now})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "logger", logs[0].ScopeName)
+	assert.Equal(t, "1.0", logs[0].ScopeVersion)
+	assert.Equal(t, uint32(1), logs[0].Flags)
+
+	traces, err := storage.QueryTraces(ctx, types.TraceQuery{StartTime: start, EndTime: now})
+	require.NoError(t, err)
+	require.Len(t, traces, 1)
+	assert.Equal(t, "server", traces[0].SpanKind)
+	assert.Equal(t, "vendor=1", traces[0].TraceState)
+	assert.Equal(t, "otelhttp", traces[0].ScopeName)
+	assert.Equal(t, "not found", traces[0].StatusMessage)
+}
@@ -3,24 +3,57 @@ package duckdb
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"database/sql/driver"
 	"fmt"
-	"strings"
+	"io"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/getlawrence/lawrence-oss/internal/histogram"
+	"github.com/getlawrence/lawrence-oss/internal/metrics"
 	"github.com/getlawrence/lawrence-oss/internal/otlp"
 	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
 	"github.com/google/uuid"
-	_ "github.com/marcboeker/go-duckdb"
+	goduckdb "github.com/marcboeker/go-duckdb"
 	"go.uber.org/zap"
 )
 
-// Storage implements the TelemetryStorage interface using DuckDB
+// Storage implements the TelemetryStorage interface using DuckDB. Writes go
+// through a single writer connection while queries use a pool of read
+// connections, so heavy queries do not block ingestion.
 type Storage struct {
-	db     *sql.DB
-	logger *zap.Logger
+	// db is the read pool
+	db *sql.DB
+	// writeDB holds the single writer connection
+	writeDB   *sql.DB
+	connector driver.Connector
+	logger    *zap.Logger
+	metrics   *metrics.StorageMetrics
+	// coldPath is the directory sealed Parquet partitions are written to
+	coldPath     string
+	queryTimeout time.Duration
+
+	removalsMu sync.Mutex
+	removals   map[string]*time.Timer
 }
 
+// Options configures a DuckDB storage instance
+type Options struct {
+	// ColdPath is the directory for sealed partitions, next to the database
+	// when empty
+	ColdPath string
+	// ReadConnections is the size of the read pool
+	ReadConnections int
+	// QueryTimeout bounds every query, zero disables the timeout
+	QueryTimeout time.Duration
+	// Metrics records connection wait times, a no-op when nil
+	Metrics *metrics.StorageMetrics
+}
+
+// defaultReadConnections is the read pool size when none is configured
+const defaultReadConnections = 4
+
 // Reader implements the types.Reader interface
 type Reader struct {
 	*Storage
@@ -49,38 +82,76 @@ func NewDuckDBWriter(dbPath string, logger *zap.Logger) (types.Writer, error) {
 	return &Writer{Storage: storage}, nil
 }
 
-// NewStorage creates a new DuckDB storage instance
+// NewStorage creates a new DuckDB storage instance with default options
 func NewStorage(dbPath string, logger *zap.Logger) (*Storage, error) {
-	db, err := sql.Open("duckdb", dbPath)
+	return NewStorageWithOptions(dbPath, Options{}, logger)
+}
+
+// NewStorageWithOptions creates a new DuckDB storage instance
+func NewStorageWithOptions(dbPath string, opts Options, logger *zap.Logger) (*Storage, error) {
+	connector, err := goduckdb.NewConnector(dbPath, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open DuckDB database: %w", err)
 	}
 
-	// Configure connection pool
-	db.SetMaxOpenConns(5)
-	db.SetMaxIdleConns(1)
+	// DuckDB allows a single writer at a time, so writes queue for one
+	// connection instead of contending inside the database
+	writeDB := sql.OpenDB(sharedConnector{connector})
+	writeDB.SetMaxOpenConns(1)
+	writeDB.SetMaxIdleConns(1)
+	writeDB.SetConnMaxLifetime(0)
+
+	readConnections := opts.ReadConnections
+	if readConnections <= 0 {
+		readConnections = defaultReadConnections
+	}
+	db := sql.OpenDB(sharedConnector{connector})
+	db.SetMaxOpenConns(readConnections)
+	db.SetMaxIdleConns(readConnections)
 	db.SetConnMaxLifetime(30 * time.Minute)
 
+	storageMetrics := opts.Metrics
+	if storageMetrics == nil {
+		storageMetrics = metrics.NewStorageMetrics(nil)
+	}
+
+	coldPath := opts.ColdPath
+	if coldPath == "" {
+		coldPath = filepath.Join(filepath.Dir(dbPath), "cold")
+	}
+
 	storage := &Storage{
-		db:     db,
-		logger: logger,
+		db:           db,
+		writeDB:      writeDB,
+		connector:    connector,
+		logger:       logger,
+		metrics:      storageMetrics,
+		coldPath:     coldPath,
+		queryTimeout: opts.QueryTimeout,
 	}
 
 	// Initialize schema
 	if err := storage.initSchema(); err != nil {
+		_ = storage.Close()
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
-	logger.Info("DuckDB storage initialized", zap.String("path", dbPath))
+	logger.Info("DuckDB storage initialized",
+		zap.String("path", dbPath),
+		zap.Int("read_connections", readConnections),
+		zap.Duration("query_timeout", opts.QueryTimeout))
 	return storage, nil
 }
 
-// initSchema creates the DuckDB tables
+// initSchema brings the DuckDB schema up to date by applying pending migrations
 func (s *Storage) initSchema() error {
-	if _, err := s.db.Exec(TelemetrySchema); err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
+	applied, err := migrate(context.Background(), s.writeDB, s.logger)
+	if err != nil {
+		return err
 	}
-	s.logger.Debug("DuckDB schema initialized")
+	s.logger.Debug("DuckDB schema initialized",
+		zap.Int("schema_version", SchemaVersion),
+		zap.Int("applied_migrations", len(applied)))
 	return nil
 }
 
@@ -123,13 +194,14 @@ func (s *Storage) WriteMetrics(ctx context.Context, metrics []types.Metric) erro
 func (s *Storage) writeMetricSums(ctx context.Context, sums []types.Metric) error {
 	query := `
 		INSERT INTO metrics_sum (
-			timestamp, agent_id, service_name, metric_name, value, metric_attributes
-		) VALUES (?, ?, ?, ?, ?, ?)
+			timestamp, agent_id, service_name, metric_name, value, metric_attributes,
+			start_timestamp, metric_unit, scope_name, scope_version, temporality, is_monotonic
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	tx, err := s.db.BeginTx(ctx, nil)
+	tx, err := s.beginWrite(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
@@ -140,7 +212,7 @@ func (s *Storage) writeMetricSums(ctx context.Context, sums []types.Metric) erro
 	defer stmt.Close()
 
 	for _, m := range sums {
-		attrsJSON, _ := json.Marshal(m.Labels)
+		attrsJSON := s.encodeAttributes(m.Labels)
 		serviceName := m.Labels["service.name"]
 		if serviceName == "" {
 			serviceName = "unknown"
@@ -152,7 +224,13 @@ func (s *Storage) writeMetricSums(ctx context.Context, sums []types.Metric) erro
 			serviceName,
 			m.Name,
 			m.Value,
-			string(attrsJSON),
+			attrsJSON,
+			m.StartTime,
+			m.Unit,
+			m.ScopeName,
+			m.ScopeVersion,
+			m.Temporality,
+			m.IsMonotonic,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert sum metric: %w", err)
@@ -170,13 +248,14 @@ func (s *Storage) writeMetricSums(ctx context.Context, sums []types.Metric) erro
 func (s *Storage) writeMetricGauges(ctx context.Context, gauges []types.Metric) error {
 	query := `
 		INSERT INTO metrics_gauge (
-			timestamp, agent_id, service_name, metric_name, value, metric_attributes
-		) VALUES (?, ?, ?, ?, ?, ?)
+			timestamp, agent_id, service_name, metric_name, value, metric_attributes,
+			start_timestamp, metric_unit, scope_name, scope_version
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	tx, err := s.db.BeginTx(ctx, nil)
+	tx, err := s.beginWrite(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
@@ -187,7 +266,7 @@ func (s *Storage) writeMetricGauges(ctx context.Context, gauges []types.Metric)
 	defer stmt.Close()
 
 	for _, m := range gauges {
-		attrsJSON, _ := json.Marshal(m.Labels)
+		attrsJSON := s.encodeAttributes(m.Labels)
 		serviceName := m.Labels["service.name"]
 		if serviceName == "" {
 			serviceName = "unknown"
@@ -199,7 +278,11 @@ func (s *Storage) writeMetricGauges(ctx context.Context, gauges []types.Metric)
 			serviceName,
 			m.Name,
 			m.Value,
-			string(attrsJSON),
+			attrsJSON,
+			m.StartTime,
+			m.Unit,
+			m.ScopeName,
+			m.ScopeVersion,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert gauge metric: %w", err)
@@ -221,13 +304,14 @@ func (s *Storage) WriteLogs(ctx context.Context, logs []types.Log) error {
 
 	query := `
 		INSERT INTO logs (
-			timestamp, agent_id, service_name, severity_text, body, log_attributes
-		) VALUES (?, ?, ?, ?, ?, ?)
+			timestamp, agent_id, service_name, severity_text, body, log_attributes,
+			scope_name, scope_version, flags
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	tx, err := s.db.BeginTx(ctx, nil)
+	tx, err := s.beginWrite(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
@@ -238,7 +322,7 @@ func (s *Storage) WriteLogs(ctx context.Context, logs []types.Log) error {
 	defer stmt.Close()
 
 	for _, log := range logs {
-		attrsJSON, _ := json.Marshal(log.LogAttributes)
+		attrsJSON := s.encodeAttributes(log.LogAttributes)
 		serviceName := ""
 		if sn, ok := log.LogAttributes["service.name"]; ok {
 			serviceName = fmt.Sprintf("%v", sn)
@@ -253,7 +337,10 @@ func (s *Storage) WriteLogs(ctx context.Context, logs []types.Log) error {
 			serviceName,
 			log.SeverityText,
 			log.Body,
-			string(attrsJSON),
+			attrsJSON,
+			log.ScopeName,
+			log.ScopeVersion,
+			log.Flags,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert log: %w", err)
@@ -278,13 +365,13 @@ func (s *Storage) WriteTraces(ctx context.Context, traces []types.Trace) error {
 		INSERT INTO traces (
 			timestamp, agent_id, trace_id, span_id, parent_span_id,
 			service_name, span_name, duration, status_code, status_message,
-			span_attributes
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			span_attributes, span_kind, trace_state, scope_name, scope_version
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	tx, err := s.db.BeginTx(ctx, nil)
+	tx, err := s.beginWrite(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
@@ -295,8 +382,8 @@ func (s *Storage) WriteTraces(ctx context.Context, traces []types.Trace) error {
 	defer stmt.Close()
 
 	for _, trace := range traces {
-		attrsJSON, _ := json.Marshal(trace.Attributes)
-		serviceName := trace.Attributes["service.name"]
+		attrsJSON := s.encodeAttributes(trace.Attributes)
+		serviceName := otlp.AttributeString(trace.Attributes["service.name"])
 		if serviceName == "" {
 			serviceName = "unknown"
 		}
@@ -317,7 +404,11 @@ func (s *Storage) WriteTraces(ctx context.Context, traces []types.Trace) error {
 			trace.Duration,
 			trace.StatusCode,
 			trace.StatusMessage,
-			string(attrsJSON),
+			attrsJSON,
+			trace.SpanKind,
+			trace.TraceState,
+			trace.ScopeName,
+			trace.ScopeVersion,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert trace: %w", err)
@@ -332,44 +423,49 @@ func (s *Storage) WriteTraces(ctx context.Context, traces []types.Trace) error {
 	return nil
 }
 
+// metricTables are the raw metric tables in the order QueryMetrics reads them
+var metricTables = []string{
+	"metrics_sum", "metrics_gauge", "metrics_histogram", "metrics_exponential_histogram", "metrics_summary",
+}
+
 // QueryMetrics queries metrics from DuckDB
 func (s *Storage) QueryMetrics(ctx context.Context, query types.MetricQuery) ([]types.Metric, error) {
-	sqlQuery := `
-		SELECT timestamp, agent_id, group_id, service_name, metric_name, value, metric_attributes
-		FROM (
-			SELECT timestamp, agent_id, group_id, service_name, metric_name, value, metric_attributes FROM metrics_sum
-			UNION ALL
-			SELECT timestamp, agent_id, group_id, service_name, metric_name, value, metric_attributes FROM metrics_gauge
-		) AS all_metrics
-		WHERE timestamp >= ? AND timestamp <= ?
-	`
-	args := []interface{}{query.StartTime, query.EndTime}
-
-	if query.AgentID != nil {
-		sqlQuery += ` AND agent_id = ?`
-		args = append(args, query.AgentID.String())
+	ctx, cancel := s.withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := s.beginRead(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	if query.GroupID != nil {
-		sqlQuery += ` AND group_id = ?`
-		args = append(args, *query.GroupID)
+	source, err := s.metricsSource(ctx, tx, query.StartTime, query.EndTime)
+	if err != nil {
+		return nil, err
 	}
-
-	if query.MetricName != nil {
-		sqlQuery += ` AND metric_name = ?`
-		args = append(args, *query.MetricName)
+	where, args, err := metricConditions(query)
+	if err != nil {
+		return nil, err
 	}
 
-	sqlQuery += ` ORDER BY timestamp DESC`
+	sqlQuery := fmt.Sprintf(`
+		SELECT timestamp, agent_id, group_id, service_name, metric_name, value, metric_attributes,
+		       metric_type, start_timestamp, metric_unit, scope_name, scope_version, temporality, is_monotonic,
+		       count, sum, bucket_counts, explicit_bounds, scale, zero_count, zero_threshold,
+		       positive_offset, positive_bucket_counts, negative_offset, negative_bucket_counts,
+		       quantiles, quantile_values
+		FROM %s
+		WHERE %s
+		ORDER BY timestamp DESC
+	`, source, where)
 
 	if query.Limit > 0 {
 		sqlQuery += ` LIMIT ?`
 		args = append(args, query.Limit)
 	}
 
-	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+	rows, err := tx.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query metrics: %w", err)
+		return nil, s.queryError(ctx, fmt.Errorf("failed to query metrics: %w", err))
 	}
 	defer rows.Close()
 
@@ -380,73 +476,196 @@ func (s *Storage) QueryMetrics(ctx context.Context, query types.MetricQuery) ([]
 		var groupID sql.NullString
 		var serviceName string
 		var attrsJSON string
-
-		err := rows.Scan(&m.Timestamp, &agentIDStr, &groupID, &serviceName, &m.Name, &m.Value, &attrsJSON)
+		var startTime sql.NullTime
+		var unit, scopeName, scopeVersion, temporality sql.NullString
+		var monotonic sql.NullBool
+		var count, zeroCount sql.NullInt64
+		var sum, zeroThreshold sql.NullFloat64
+		var scale, positiveOffset, negativeOffset sql.NullInt32
+		var bucketCounts, positiveCounts, negativeCounts goduckdb.Composite[[]int64]
+		var explicitBounds, quantiles, quantileValues goduckdb.Composite[[]float64]
+
+		err := rows.Scan(&m.Timestamp, &agentIDStr, &groupID, &serviceName, &m.Name, &m.Value, &attrsJSON,
+			&m.Type, &startTime, &unit, &scopeName, &scopeVersion, &temporality, &monotonic,
+			&count, &sum, &bucketCounts, &explicitBounds, &scale, &zeroCount, &zeroThreshold,
+			&positiveOffset, &positiveCounts, &negativeOffset, &negativeCounts,
+			&quantiles, &quantileValues)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan metric: %w", err)
 		}
 
+		m.Count = count.Int64
+		m.Sum = sum.Float64
+		m.BucketCounts = bucketCounts.Get()
+		m.ExplicitBounds = explicitBounds.Get()
+		if m.Type == types.MetricTypeExponentialHistogram {
+			m.Exponential = &histogram.Exponential{
+				Scale:         scale.Int32,
+				ZeroCount:     zeroCount.Int64,
+				ZeroThreshold: zeroThreshold.Float64,
+				Positive:      histogram.Buckets{Offset: positiveOffset.Int32, Counts: positiveCounts.Get()},
+				Negative:      histogram.Buckets{Offset: negativeOffset.Int32, Counts: negativeCounts.Get()},
+			}
+		}
+		m.Quantiles = quantileList(quantiles.Get(), quantileValues.Get())
+
+		if startTime.Valid {
+			m.StartTime = &startTime.Time
+		}
+		m.Unit = unit.String
+		m.ScopeName = scopeName.String
+		m.ScopeVersion = scopeVersion.String
+		m.Temporality = temporality.String
+		m.IsMonotonic = monotonic.Bool
+
 		m.AgentID, _ = uuid.Parse(agentIDStr)
 		m.ServiceName = serviceName
 		if groupID.Valid {
 			m.GroupID = &groupID.String
 		}
 		if attrsJSON != "" {
-			_ = json.Unmarshal([]byte(attrsJSON), &m.MetricAttributes)
+			m.MetricAttributes = decodeAttributes(attrsJSON)
 
 			// Convert MetricAttributes to Labels (map[string]string)
-			m.Labels = make(map[string]string)
-			for key, value := range m.MetricAttributes {
-				m.Labels[key] = fmt.Sprintf("%v", value)
-			}
+			m.Labels = otlp.StringifyAttributes(m.MetricAttributes)
 		}
 
 		metrics = append(metrics, m)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, s.queryError(ctx, fmt.Errorf("error iterating metrics: %w", err))
+	}
 
 	return metrics, nil
 }
 
-// QueryLogs queries logs from DuckDB
-func (s *Storage) QueryLogs(ctx context.Context, query types.LogQuery) ([]types.Log, error) {
-	sqlQuery := `
-		SELECT timestamp, agent_id, group_id, service_name, severity_text, severity_number, 
-		       body, trace_id, span_id, log_attributes
-		FROM logs
-		WHERE timestamp >= ? AND timestamp <= ?
-	`
+// metricsSource returns a subquery over every raw metric table for the time
+// range. Histograms and summaries read their mean as value, and columns a
+// metric type does not have read as NULL.
+func (s *Storage) metricsSource(ctx context.Context, tx *sql.Tx, start, end time.Time) (string, error) {
+	sources := make([]interface{}, len(metricTables))
+	for i, table := range metricTables {
+		source, err := s.tableSource(ctx, tx, table, start, end)
+		if err != nil {
+			return "", err
+		}
+		sources[i] = source
+	}
+
+	return fmt.Sprintf(`(
+			SELECT timestamp, agent_id, group_id, service_name, metric_name, value, metric_attributes,
+			       'counter' AS metric_type, start_timestamp, metric_unit, scope_name, scope_version,
+			       temporality, is_monotonic
+			FROM %s
+			UNION ALL BY NAME
+			SELECT timestamp, agent_id, group_id, service_name, metric_name, value, metric_attributes,
+			       'gauge' AS metric_type, start_timestamp, metric_unit, scope_name, scope_version
+			FROM %s
+			UNION ALL BY NAME
+			SELECT timestamp, agent_id, group_id, service_name, metric_name,
+			       CASE WHEN count > 0 THEN sum / count ELSE 0 END AS value, metric_attributes,
+			       'histogram' AS metric_type, start_timestamp, metric_unit, scope_name, scope_version,
+			       temporality, count, sum, bucket_counts, explicit_bounds
+			FROM %s
+			UNION ALL BY NAME
+			SELECT timestamp, agent_id, group_id, service_name, metric_name,
+			       CASE WHEN count > 0 THEN sum / count ELSE 0 END AS value, metric_attributes,
+			       'exponential_histogram' AS metric_type, start_timestamp, metric_unit, scope_name, scope_version,
+			       temporality, count, sum, scale, zero_count, zero_threshold,
+			       positive_offset, positive_bucket_counts, negative_offset, negative_bucket_counts
+			FROM %s
+			UNION ALL BY NAME
+			SELECT timestamp, agent_id, group_id, service_name, metric_name,
+			       CASE WHEN count > 0 THEN sum / count ELSE 0 END AS value, metric_attributes,
+			       'summary' AS metric_type, start_timestamp, metric_unit, scope_name, scope_version,
+			       count, sum, quantiles, quantile_values
+			FROM %s
+		) AS all_metrics`, sources...), nil
+}
+
+// metricConditions returns the WHERE clause selecting the metrics of a query
+func metricConditions(query types.MetricQuery) (string, []interface{}, error) {
+	where := `timestamp >= ? AND timestamp <= ?`
 	args := []interface{}{query.StartTime, query.EndTime}
 
 	if query.AgentID != nil {
-		sqlQuery += ` AND agent_id = ?`
+		where += ` AND agent_id = ?`
 		args = append(args, query.AgentID.String())
 	}
 
 	if query.GroupID != nil {
-		sqlQuery += ` AND group_id = ?`
+		where += ` AND group_id = ?`
 		args = append(args, *query.GroupID)
 	}
 
-	if query.Severity != nil {
-		sqlQuery += ` AND severity_text = ?`
-		args = append(args, *query.Severity)
+	if query.MetricName != nil {
+		where += ` AND metric_name = ?`
+		args = append(args, *query.MetricName)
 	}
 
-	if query.Search != nil {
-		sqlQuery += ` AND body LIKE ?`
-		args = append(args, "%"+*query.Search+"%")
+	if query.Unit != nil {
+		where += ` AND metric_unit = ?`
+		args = append(args, *query.Unit)
 	}
 
-	sqlQuery += ` ORDER BY timestamp DESC`
+	if query.ScopeName != nil {
+		where += ` AND scope_name = ?`
+		args = append(args, *query.ScopeName)
+	}
+
+	if query.Temporality != nil {
+		where += ` AND temporality = ?`
+		args = append(args, *query.Temporality)
+	}
+
+	if len(query.Filters) > 0 {
+		filters, filterArgs, err := metricFields.conditions(query.Filters)
+		if err != nil {
+			return "", nil, err
+		}
+		where += ` AND ` + filters
+		args = append(args, filterArgs...)
+	}
+
+	return where, args, nil
+}
+
+// QueryLogs queries logs from DuckDB
+func (s *Storage) QueryLogs(ctx context.Context, query types.LogQuery) ([]types.Log, error) {
+	ctx, cancel := s.withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := s.beginRead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	source, err := s.tableSource(ctx, tx, "logs", query.StartTime, query.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args, err := logConditions(query)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT timestamp, agent_id, group_id, service_name, severity_text, severity_number, 
+		       body, trace_id, span_id, log_attributes, scope_name, scope_version, flags
+		FROM %s
+		WHERE %s
+		ORDER BY timestamp DESC
+	`, source, where)
 
 	if query.Limit > 0 {
 		sqlQuery += ` LIMIT ?`
 		args = append(args, query.Limit)
 	}
 
-	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+	rows, err := tx.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query logs: %w", err)
+		return nil, s.queryError(ctx, fmt.Errorf("failed to query logs: %w", err))
 	}
 	defer rows.Close()
 
@@ -454,16 +673,22 @@ func (s *Storage) QueryLogs(ctx context.Context, query types.LogQuery) ([]types.
 	for rows.Next() {
 		var l types.Log
 		var agentIDStr string
-		var groupID, traceID, spanID sql.NullString
+		var groupID, traceID, spanID, scopeName, scopeVersion sql.NullString
 		var serviceName string
 		var attrsJSON string
+		var flags sql.NullInt64
 
 		err := rows.Scan(&l.Timestamp, &agentIDStr, &groupID, &serviceName,
-			&l.SeverityText, &l.SeverityNumber, &l.Body, &traceID, &spanID, &attrsJSON)
+			&l.SeverityText, &l.SeverityNumber, &l.Body, &traceID, &spanID, &attrsJSON,
+			&scopeName, &scopeVersion, &flags)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan log: %w", err)
 		}
 
+		l.ScopeName = scopeName.String
+		l.ScopeVersion = scopeVersion.String
+		l.Flags = uint32(flags.Int64)
+
 		l.AgentID, _ = uuid.Parse(agentIDStr)
 		l.ServiceName = serviceName
 		if groupID.Valid {
@@ -476,160 +701,75 @@ func (s *Storage) QueryLogs(ctx context.Context, query types.LogQuery) ([]types.
 			l.SpanID = &spanID.String
 		}
 		if attrsJSON != "" {
-			_ = json.Unmarshal([]byte(attrsJSON), &l.LogAttributes)
+			l.LogAttributes = decodeAttributes(attrsJSON)
 		}
 
 		logs = append(logs, l)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, s.queryError(ctx, fmt.Errorf("error iterating logs: %w", err))
+	}
 
 	return logs, nil
 }
 
-// QueryTraces queries traces from DuckDB
-func (s *Storage) QueryTraces(ctx context.Context, query types.TraceQuery) ([]types.Trace, error) {
-	sqlQuery := `
-		SELECT timestamp, agent_id, trace_id, span_id, parent_span_id,
-		       span_name, duration, status_code, status_message, span_attributes
-		FROM traces
-		WHERE timestamp >= ? AND timestamp <= ?
-	`
+// logConditions returns the WHERE clause selecting the logs of a query
+func logConditions(query types.LogQuery) (string, []interface{}, error) {
+	where := `timestamp >= ? AND timestamp <= ?`
 	args := []interface{}{query.StartTime, query.EndTime}
 
 	if query.AgentID != nil {
-		sqlQuery += ` AND agent_id = ?`
+		where += ` AND agent_id = ?`
 		args = append(args, query.AgentID.String())
 	}
 
 	if query.GroupID != nil {
-		sqlQuery += ` AND group_id = ?`
+		where += ` AND group_id = ?`
 		args = append(args, *query.GroupID)
 	}
 
-	if query.TraceID != nil {
-		sqlQuery += ` AND trace_id = ?`
-		args = append(args, *query.TraceID)
-	}
-
-	sqlQuery += ` ORDER BY timestamp DESC`
-
-	if query.Limit > 0 {
-		sqlQuery += ` LIMIT ?`
-		args = append(args, query.Limit)
+	if query.Severity != nil {
+		where += ` AND severity_text = ?`
+		args = append(args, *query.Severity)
 	}
 
-	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query traces: %w", err)
+	if query.Search != nil {
+		where += ` AND body LIKE ?`
+		args = append(args, "%"+*query.Search+"%")
 	}
-	defer rows.Close()
-
-	var traces []types.Trace
-	for rows.Next() {
-		var t types.Trace
-		var agentIDStr string
-		var parentSpanID sql.NullString
-		var attrsJSON string
 
-		err := rows.Scan(
-			&t.Timestamp, &agentIDStr, &t.TraceID, &t.SpanID, &parentSpanID,
-			&t.Name, &t.Duration, &t.StatusCode, &t.StatusMessage, &attrsJSON,
-		)
+	if len(query.Filters) > 0 {
+		filters, filterArgs, err := logFields.conditions(query.Filters)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan trace: %w", err)
+			return "", nil, err
 		}
-
-		t.AgentID, _ = uuid.Parse(agentIDStr)
-		if parentSpanID.Valid {
-			t.ParentSpanID = &parentSpanID.String
-		}
-		_ = json.Unmarshal([]byte(attrsJSON), &t.Attributes)
-
-		traces = append(traces, t)
+		where += ` AND ` + filters
+		args = append(args, filterArgs...)
 	}
 
-	return traces, nil
+	return where, args, nil
 }
 
-// CreateRollups creates pre-aggregated rollup data
-func (s *Storage) CreateRollups(ctx context.Context, window time.Time, interval types.RollupInterval) error {
-	var tableName string
-	var windowDuration time.Duration
-
-	switch interval {
-	case types.RollupInterval1m:
-		tableName = "rollups_1m"
-		windowDuration = 1 * time.Minute
-	case types.RollupInterval5m:
-		tableName = "rollups_5m"
-		windowDuration = 5 * time.Minute
-	case types.RollupInterval1h:
-		tableName = "rollups_1h"
-		windowDuration = 1 * time.Hour
-	case types.RollupInterval1d:
-		tableName = "rollups_1d"
-		windowDuration = 24 * time.Hour
-	default:
-		return fmt.Errorf("invalid rollup interval: %s", interval)
-	}
-
-	windowStart := window.Truncate(windowDuration)
-	windowEnd := windowStart.Add(windowDuration)
-
-	// Create rollups from sum metrics
-	query := fmt.Sprintf(`
-		INSERT INTO %s (window_start, agent_id, group_id, metric_name, count, sum, avg, min, max)
-		SELECT
-			? as window_start,
-			agent_id,
-			group_id,
-			metric_name,
-			COUNT(*) as count,
-			SUM(value) as sum,
-			AVG(value) as avg,
-			MIN(value) as min,
-			MAX(value) as max
-		FROM metrics_sum
-		WHERE timestamp >= ? AND timestamp < ?
-		GROUP BY agent_id, group_id, metric_name
-		ON CONFLICT (window_start, agent_id, group_id, metric_name)
-		DO UPDATE SET
-			count = EXCLUDED.count,
-			sum = EXCLUDED.sum,
-			avg = EXCLUDED.avg,
-			min = EXCLUDED.min,
-			max = EXCLUDED.max
-	`, tableName)
-
-	_, err := s.db.ExecContext(ctx, query, windowStart, windowStart, windowEnd)
+// QueryTraces queries traces from DuckDB
+func (s *Storage) QueryTraces(ctx context.Context, query types.TraceQuery) ([]types.Trace, error) {
+	ctx, cancel := s.withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := s.beginRead(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create rollups: %w", err)
+		return nil, err
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	s.logger.Debug("Created rollups", zap.String("interval", string(interval)), zap.Time("window", windowStart))
-	return nil
-}
-
-// QueryRollups queries rollup data
-func (s *Storage) QueryRollups(ctx context.Context, query types.RollupQuery) ([]types.Rollup, error) {
-	var tableName string
-	switch query.Interval {
-	case types.RollupInterval1m:
-		tableName = "rollups_1m"
-	case types.RollupInterval5m:
-		tableName = "rollups_5m"
-	case types.RollupInterval1h:
-		tableName = "rollups_1h"
-	case types.RollupInterval1d:
-		tableName = "rollups_1d"
-	default:
-		return nil, fmt.Errorf("invalid rollup interval: %s", query.Interval)
+	source, err := s.tableSource(ctx, tx, "traces", query.StartTime, query.EndTime)
+	if err != nil {
+		return nil, err
 	}
 
 	sqlQuery := fmt.Sprintf(`
-		SELECT window_start, agent_id, group_id, metric_name, count, sum, avg, min, max
+		SELECT %s
 		FROM %s
-		WHERE window_start >= ? AND window_start <= ?
-	`, tableName)
+		WHERE timestamp >= ? AND timestamp <= ?
+	`, traceColumns, source)
 	args := []interface{}{query.StartTime, query.EndTime}
 
 	if query.AgentID != nil {
@@ -642,52 +782,82 @@ func (s *Storage) QueryRollups(ctx context.Context, query types.RollupQuery) ([]
 		args = append(args, *query.GroupID)
 	}
 
-	if query.MetricName != nil {
-		sqlQuery += ` AND metric_name = ?`
-		args = append(args, *query.MetricName)
+	if query.TraceID != nil {
+		sqlQuery += ` AND trace_id = ?`
+		args = append(args, *query.TraceID)
 	}
 
-	sqlQuery += ` ORDER BY window_start DESC`
+	sqlQuery += ` ORDER BY timestamp DESC`
+
+	if query.Limit > 0 {
+		sqlQuery += ` LIMIT ?`
+		args = append(args, query.Limit)
+	}
 
-	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+	rows, err := tx.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query rollups: %w", err)
+		return nil, s.queryError(ctx, fmt.Errorf("failed to query traces: %w", err))
 	}
 	defer rows.Close()
 
-	var rollups []types.Rollup
+	traces, err := scanTraces(rows)
+	if err != nil {
+		return nil, s.queryError(ctx, err)
+	}
+	return traces, nil
+}
+
+// traceColumns are the span columns scanTraces reads, in order
+const traceColumns = `timestamp, agent_id, service_name, trace_id, span_id, parent_span_id,
+		       span_name, duration, status_code, status_message, span_attributes,
+		       span_kind, trace_state, scope_name, scope_version`
+
+// scanTraces scans rows of traceColumns into spans
+func scanTraces(rows *sql.Rows) ([]types.Trace, error) {
+	var traces []types.Trace
 	for rows.Next() {
-		var r types.Rollup
-		var agentIDStr, groupIDStr sql.NullString
+		var t types.Trace
+		var agentIDStr string
+		var serviceName, parentSpanID, statusMessage, spanKind, traceState, scopeName, scopeVersion sql.NullString
+		var attrsJSON string
 
 		err := rows.Scan(
-			&r.WindowStart, &agentIDStr, &groupIDStr, &r.MetricName,
-			&r.Count, &r.Sum, &r.Avg, &r.Min, &r.Max,
+			&t.Timestamp, &agentIDStr, &serviceName, &t.TraceID, &t.SpanID, &parentSpanID,
+			&t.Name, &t.Duration, &t.StatusCode, &statusMessage, &attrsJSON,
+			&spanKind, &traceState, &scopeName, &scopeVersion,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan rollup: %w", err)
+			return nil, fmt.Errorf("failed to scan trace: %w", err)
 		}
 
-		if agentIDStr.Valid {
-			agentID, _ := uuid.Parse(agentIDStr.String)
-			r.AgentID = &agentID
-		}
-		if groupIDStr.Valid {
-			r.GroupID = &groupIDStr.String
+		t.AgentID, _ = uuid.Parse(agentIDStr)
+		t.ServiceName = serviceName.String
+		if parentSpanID.Valid {
+			t.ParentSpanID = &parentSpanID.String
 		}
-		r.Interval = query.Interval
+		t.StatusMessage = statusMessage.String
+		t.SpanKind = spanKind.String
+		t.TraceState = traceState.String
+		t.ScopeName = scopeName.String
+		t.ScopeVersion = scopeVersion.String
+		t.Attributes = decodeAttributes(attrsJSON)
 
-		rollups = append(rollups, r)
+		traces = append(traces, t)
 	}
-
-	return rollups, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating traces: %w", err)
+	}
+	return traces, nil
 }
 
 // QueryRaw executes a raw SQL query and returns results as a map
 func (s *Storage) QueryRaw(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	ctx, cancel := s.withQueryTimeout(ctx)
+	defer cancel()
+
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
+		return nil, s.queryError(ctx, fmt.Errorf("failed to execute query: %w", err))
 	}
 	defer rows.Close()
 
@@ -724,39 +894,26 @@ func (s *Storage) QueryRaw(ctx context.Context, query string, args ...interface{
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+		return nil, s.queryError(ctx, fmt.Errorf("error iterating rows: %w", err))
 	}
 
 	return results, nil
 }
 
-// CleanupOldData removes old telemetry data based on retention policy
-func (s *Storage) CleanupOldData(ctx context.Context, retention time.Duration) error {
-	cutoffTime := time.Now().Add(-retention)
-
-	tables := []string{"metrics_sum", "metrics_gauge", "metrics_histogram", "logs", "traces"}
-
-	for _, table := range tables {
-		query := fmt.Sprintf("DELETE FROM %s WHERE timestamp < ?", table)
-		result, err := s.db.ExecContext(ctx, query, cutoffTime)
-		if err != nil {
-			return fmt.Errorf("failed to cleanup %s: %w", table, err)
-		}
-
-		rows, _ := result.RowsAffected()
-		if rows > 0 {
-			s.logger.Info("Cleaned up old data", zap.String("table", table), zap.Int64("rows", rows))
-		}
-	}
-
-	return nil
-}
-
-// Close closes the database connection
+// Close closes the read pool, the writer connection and the database
 func (s *Storage) Close() error {
 	if err := s.db.Close(); err != nil {
-		return fmt.Errorf("failed to close database: %w", err)
+		return fmt.Errorf("failed to close read connections: %w", err)
+	}
+	if err := s.writeDB.Close(); err != nil {
+		return fmt.Errorf("failed to close writer connection: %w", err)
 	}
+	if closer, ok := s.connector.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return fmt.Errorf("failed to close database: %w", err)
+		}
+	}
+	s.flushRemovals()
 	s.logger.Info("DuckDB storage closed")
 	return nil
 }
@@ -767,35 +924,17 @@ func (s *Storage) WriteTracesFromOTLP(ctx context.Context, traces []otlp.TraceDa
 		return nil
 	}
 
-	query := `
-		INSERT INTO traces (
-			timestamp, agent_id, group_id, group_name, trace_id, span_id, parent_span_id,
-			service_name, span_name, duration, status_code, resource_attributes, span_attributes
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer func() { _ = tx.Rollback() }()
-
-	stmt, err := tx.PrepareContext(ctx, query)
-	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
-	}
-	defer stmt.Close()
-
+	rows := make([][]driver.Value, 0, len(traces))
 	for _, trace := range traces {
-		resourceAttrsJSON, _ := json.Marshal(trace.ResourceAttributes)
-		spanAttrsJSON, _ := json.Marshal(trace.SpanAttributes)
+		resourceAttrsJSON := s.encodeAttributes(trace.ResourceAttributes)
+		spanAttrsJSON := s.encodeAttributes(trace.SpanAttributes)
 
-		var parentSpanID interface{}
+		var parentSpanID driver.Value
 		if trace.ParentSpanId != "" {
 			parentSpanID = trace.ParentSpanId
 		}
 
-		_, err = stmt.ExecContext(ctx,
+		rows = append(rows, []driver.Value{
 			trace.Timestamp,
 			trace.AgentID,
 			trace.GroupID,
@@ -805,18 +944,22 @@ func (s *Storage) WriteTracesFromOTLP(ctx context.Context, traces []otlp.TraceDa
 			parentSpanID,
 			trace.ServiceName,
 			trace.SpanName,
+			otlp.SpanKindName(trace.SpanKind),
 			trace.Duration,
 			trace.StatusCode,
-			string(resourceAttrsJSON),
-			string(spanAttrsJSON),
-		)
-		if err != nil {
-			return fmt.Errorf("failed to insert trace: %w", err)
-		}
+			trace.StatusMessage,
+			resourceAttrsJSON,
+			spanAttrsJSON,
+			nil, // events
+			nil, // links
+			trace.TraceState,
+			trace.ScopeName,
+			trace.ScopeVersion,
+		})
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	if err := s.appendRows(ctx, "traces", rows); err != nil {
+		return fmt.Errorf("failed to insert traces: %w", err)
 	}
 
 	s.logger.Debug("Wrote OTLP traces to DuckDB", zap.Int("count", len(traces)))
@@ -829,31 +972,12 @@ func (s *Storage) WriteLogsFromOTLP(ctx context.Context, logs []otlp.LogData) er
 		return nil
 	}
 
-	query := `
-		INSERT INTO logs (
-			timestamp, agent_id, group_id, group_name, service_name,
-			severity_text, severity_number, body, trace_id, span_id,
-			resource_attributes, log_attributes
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer func() { _ = tx.Rollback() }()
-
-	stmt, err := tx.PrepareContext(ctx, query)
-	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
-	}
-	defer stmt.Close()
-
+	rows := make([][]driver.Value, 0, len(logs))
 	for _, log := range logs {
-		resourceAttrsJSON, _ := json.Marshal(log.ResourceAttributes)
-		logAttrsJSON, _ := json.Marshal(log.LogAttributes)
+		resourceAttrsJSON := s.encodeAttributes(log.ResourceAttributes)
+		logAttrsJSON := s.encodeAttributes(log.LogAttributes)
 
-		var traceID, spanID interface{}
+		var traceID, spanID driver.Value
 		if log.TraceId != "" {
 			traceID = log.TraceId
 		}
@@ -861,7 +985,7 @@ func (s *Storage) WriteLogsFromOTLP(ctx context.Context, logs []otlp.LogData) er
 			spanID = log.SpanId
 		}
 
-		_, err = stmt.ExecContext(ctx,
+		rows = append(rows, []driver.Value{
 			log.Timestamp,
 			log.AgentID,
 			log.GroupID,
@@ -872,16 +996,16 @@ func (s *Storage) WriteLogsFromOTLP(ctx context.Context, logs []otlp.LogData) er
 			log.Body,
 			traceID,
 			spanID,
-			string(resourceAttrsJSON),
-			string(logAttrsJSON),
-		)
-		if err != nil {
-			return fmt.Errorf("failed to insert log: %w", err)
-		}
+			resourceAttrsJSON,
+			logAttrsJSON,
+			log.ScopeName,
+			log.ScopeVersion,
+			log.TraceFlags,
+		})
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	if err := s.appendRows(ctx, "logs", rows); err != nil {
+		return fmt.Errorf("failed to insert logs: %w", err)
 	}
 
 	s.logger.Debug("Wrote OTLP logs to DuckDB", zap.Int("count", len(logs)))
@@ -889,282 +1013,260 @@ func (s *Storage) WriteLogsFromOTLP(ctx context.Context, logs []otlp.LogData) er
 }
 
 // WriteMetricsFromOTLP writes metric data from OTLP parser format
-func (s *Storage) WriteMetricsFromOTLP(ctx context.Context, sums []otlp.MetricSumData, gauges []otlp.MetricGaugeData, histograms []otlp.MetricHistogramData) error {
-	s.logger.Debug("WriteMetricsFromOTLP called",
-		zap.Int("sums", len(sums)),
-		zap.Int("gauges", len(gauges)),
-		zap.Int("histograms", len(histograms)))
-
-	// Write sums
-	if len(sums) > 0 {
-		s.logger.Debug("Writing sum metrics", zap.Int("count", len(sums)))
-		if err := s.writeOTLPSums(ctx, sums); err != nil {
-			s.logger.Error("Failed to write sum metrics", zap.Error(err))
-			return err
-		}
-		s.logger.Debug("Successfully wrote sum metrics")
-	}
-
-	// Write gauges
-	if len(gauges) > 0 {
-		s.logger.Debug("Writing gauge metrics", zap.Int("count", len(gauges)))
-		if err := s.writeOTLPGauges(ctx, gauges); err != nil {
-			s.logger.Error("Failed to write gauge metrics", zap.Error(err))
-			return err
+func (s *Storage) WriteMetricsFromOTLP(ctx context.Context, metrics *otlp.MetricsData) error {
+	s.logger.Debug("WriteMetricsFromOTLP called", zap.Int("data_points", metrics.Len()))
+
+	writers := []struct {
+		name  string
+		count int
+		write func(context.Context) error
+	}{
+		{"sum", len(metrics.Sums), func(ctx context.Context) error { return s.writeOTLPSums(ctx, metrics.Sums) }},
+		{"gauge", len(metrics.Gauges), func(ctx context.Context) error { return s.writeOTLPGauges(ctx, metrics.Gauges) }},
+		{"histogram", len(metrics.Histograms), func(ctx context.Context) error { return s.writeOTLPHistograms(ctx, metrics.Histograms) }},
+		{"exponential histogram", len(metrics.ExponentialHistograms), func(ctx context.Context) error {
+			return s.writeOTLPExponentialHistograms(ctx, metrics.ExponentialHistograms)
+		}},
+		{"summary", len(metrics.Summaries), func(ctx context.Context) error { return s.writeOTLPSummaries(ctx, metrics.Summaries) }},
+	}
+	for _, w := range writers {
+		if w.count == 0 {
+			continue
 		}
-		s.logger.Debug("Successfully wrote gauge metrics")
-	}
-
-	// Write histograms
-	if len(histograms) > 0 {
-		s.logger.Debug("Writing histogram metrics", zap.Int("count", len(histograms)))
-		if err := s.writeOTLPHistograms(ctx, histograms); err != nil {
-			s.logger.Error("Failed to write histogram metrics", zap.Error(err))
+		s.logger.Debug("Writing metrics", zap.String("type", w.name), zap.Int("count", w.count))
+		if err := w.write(ctx); err != nil {
+			s.logger.Error("Failed to write metrics", zap.String("type", w.name), zap.Error(err))
 			return err
 		}
-		s.logger.Debug("Successfully wrote histogram metrics")
 	}
 
 	s.logger.Debug("Wrote OTLP metrics to DuckDB",
-		zap.Int("sums", len(sums)),
-		zap.Int("gauges", len(gauges)),
-		zap.Int("histograms", len(histograms)))
+		zap.Int("sums", len(metrics.Sums)),
+		zap.Int("gauges", len(metrics.Gauges)),
+		zap.Int("histograms", len(metrics.Histograms)),
+		zap.Int("exponential_histograms", len(metrics.ExponentialHistograms)),
+		zap.Int("summaries", len(metrics.Summaries)))
 	return nil
 }
 
 func (s *Storage) writeOTLPSums(ctx context.Context, sums []otlp.MetricSumData) error {
-	if len(sums) == 0 {
-		return nil
+	rows := make([][]driver.Value, 0, len(sums))
+	for _, m := range sums {
+		resourceAttrsJSON := s.encodeAttributes(m.ResourceAttributes)
+		metricAttrsJSON := s.encodeAttributes(m.Attributes)
+
+		rows = append(rows, []driver.Value{
+			m.TimeUnix,
+			m.AgentID,
+			m.GroupID,
+			m.GroupName,
+			m.ServiceName,
+			m.MetricName,
+			m.MetricDescription,
+			m.Value,
+			resourceAttrsJSON,
+			metricAttrsJSON,
+			startTimestamp(m.StartTimeUnix),
+			m.MetricUnit,
+			m.ScopeName,
+			m.ScopeVersion,
+			otlp.TemporalityName(m.AggregationTemporality),
+			m.IsMonotonic,
+			m.Flags,
+		})
 	}
 
-	query := `
-		INSERT INTO metrics_sum (
-			timestamp, agent_id, group_id, group_name, service_name,
-			metric_name, metric_description, value, resource_attributes, metric_attributes
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-
-	s.logger.Debug("Starting writeOTLPSums transaction", zap.Int("count", len(sums)))
-
-	// Process in smaller batches to avoid overwhelming the database
-	batchSize := 50
-	for i := 0; i < len(sums); i += batchSize {
-		end := i + batchSize
-		if end > len(sums) {
-			end = len(sums)
-		}
-
-		batch := sums[i:end]
-		s.logger.Debug("Processing batch", zap.Int("start", i), zap.Int("end", end), zap.Int("batch_size", len(batch)))
-
-		tx, err := s.db.BeginTx(ctx, nil)
-		if err != nil {
-			s.logger.Error("Failed to begin transaction", zap.Error(err))
-			return fmt.Errorf("failed to begin transaction: %w", err)
-		}
-
-		stmt, err := tx.PrepareContext(ctx, query)
-		if err != nil {
-			_ = tx.Rollback()
-			s.logger.Error("Failed to prepare statement", zap.Error(err))
-			return fmt.Errorf("failed to prepare statement: %w", err)
-		}
-
-		for j, m := range batch {
-			resourceAttrsJSON, _ := json.Marshal(m.ResourceAttributes)
-			metricAttrsJSON, _ := json.Marshal(m.Attributes)
-
-			_, err = stmt.ExecContext(ctx,
-				m.TimeUnix,
-				m.AgentID,
-				m.GroupID,
-				m.GroupName,
-				m.ServiceName,
-				m.MetricName,
-				m.MetricDescription,
-				m.Value,
-				string(resourceAttrsJSON),
-				string(metricAttrsJSON),
-			)
-			if err != nil {
-				stmt.Close()
-				_ = tx.Rollback()
-				s.logger.Error("Failed to insert sum metric", zap.Error(err), zap.Int("batch_index", j), zap.String("metric_name", m.MetricName))
-				return fmt.Errorf("failed to insert sum metric: %w", err)
-			}
-		}
-
-		stmt.Close()
-		if err := tx.Commit(); err != nil {
-			s.logger.Error("Failed to commit transaction", zap.Error(err))
-			return fmt.Errorf("failed to commit transaction: %w", err)
-		}
-
-		s.logger.Debug("Batch committed successfully", zap.Int("batch_size", len(batch)))
+	if err := s.appendRows(ctx, "metrics_sum", rows); err != nil {
+		return fmt.Errorf("failed to insert sum metrics: %w", err)
 	}
-
-	s.logger.Debug("All batches completed successfully")
 	return nil
 }
 
 func (s *Storage) writeOTLPGauges(ctx context.Context, gauges []otlp.MetricGaugeData) error {
-	if len(gauges) == 0 {
-		return nil
+	rows := make([][]driver.Value, 0, len(gauges))
+	for _, m := range gauges {
+		resourceAttrsJSON := s.encodeAttributes(m.ResourceAttributes)
+		metricAttrsJSON := s.encodeAttributes(m.Attributes)
+
+		rows = append(rows, []driver.Value{
+			m.TimeUnix,
+			m.AgentID,
+			m.GroupID,
+			m.GroupName,
+			m.ServiceName,
+			m.MetricName,
+			m.MetricDescription,
+			m.Value,
+			resourceAttrsJSON,
+			metricAttrsJSON,
+			startTimestamp(m.StartTimeUnix),
+			m.MetricUnit,
+			m.ScopeName,
+			m.ScopeVersion,
+			m.Flags,
+		})
 	}
 
-	query := `
-		INSERT INTO metrics_gauge (
-			timestamp, agent_id, group_id, group_name, service_name,
-			metric_name, metric_description, value, resource_attributes, metric_attributes
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-
-	s.logger.Debug("Starting writeOTLPGauges transaction", zap.Int("count", len(gauges)))
-
-	// Process in smaller batches to avoid overwhelming the database
-	batchSize := 50
-	for i := 0; i < len(gauges); i += batchSize {
-		end := i + batchSize
-		if end > len(gauges) {
-			end = len(gauges)
-		}
-
-		batch := gauges[i:end]
-		s.logger.Debug("Processing gauge batch", zap.Int("start", i), zap.Int("end", end), zap.Int("batch_size", len(batch)))
-
-		tx, err := s.db.BeginTx(ctx, nil)
-		if err != nil {
-			s.logger.Error("Failed to begin transaction", zap.Error(err))
-			return fmt.Errorf("failed to begin transaction: %w", err)
-		}
-
-		stmt, err := tx.PrepareContext(ctx, query)
-		if err != nil {
-			_ = tx.Rollback()
-			s.logger.Error("Failed to prepare statement", zap.Error(err))
-			return fmt.Errorf("failed to prepare statement: %w", err)
-		}
-
-		for j, m := range batch {
-			resourceAttrsJSON, _ := json.Marshal(m.ResourceAttributes)
-			metricAttrsJSON, _ := json.Marshal(m.Attributes)
-
-			_, err = stmt.ExecContext(ctx,
-				m.TimeUnix,
-				m.AgentID,
-				m.GroupID,
-				m.GroupName,
-				m.ServiceName,
-				m.MetricName,
-				m.MetricDescription,
-				m.Value,
-				string(resourceAttrsJSON),
-				string(metricAttrsJSON),
-			)
-			if err != nil {
-				stmt.Close()
-				_ = tx.Rollback()
-				s.logger.Error("Failed to insert gauge metric", zap.Error(err), zap.Int("batch_index", j), zap.String("metric_name", m.MetricName))
-				return fmt.Errorf("failed to insert gauge metric: %w", err)
-			}
-		}
-
-		stmt.Close()
-		if err := tx.Commit(); err != nil {
-			s.logger.Error("Failed to commit transaction", zap.Error(err))
-			return fmt.Errorf("failed to commit transaction: %w", err)
-		}
-
-		s.logger.Debug("Gauge batch committed successfully", zap.Int("batch_size", len(batch)))
+	if err := s.appendRows(ctx, "metrics_gauge", rows); err != nil {
+		return fmt.Errorf("failed to insert gauge metrics: %w", err)
 	}
-
-	s.logger.Debug("All gauge batches completed successfully")
 	return nil
 }
 
 func (s *Storage) writeOTLPHistograms(ctx context.Context, histograms []otlp.MetricHistogramData) error {
-	if len(histograms) == 0 {
-		return nil
-	}
-
-	query := `
-		INSERT INTO metrics_histogram (
-			timestamp, agent_id, group_id, group_name, service_name,
-			metric_name, metric_description, count, sum, min, max,
-			bucket_counts, explicit_bounds, resource_attributes, metric_attributes
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-
-	s.logger.Debug("Starting writeOTLPHistograms transaction", zap.Int("count", len(histograms)))
-
-	// Process in smaller batches to avoid overwhelming the database
-	batchSize := 50
-	for i := 0; i < len(histograms); i += batchSize {
-		end := i + batchSize
-		if end > len(histograms) {
-			end = len(histograms)
+	rows := make([][]driver.Value, 0, len(histograms))
+	for _, m := range histograms {
+		resourceAttrsJSON := s.encodeAttributes(m.ResourceAttributes)
+		metricAttrsJSON := s.encodeAttributes(m.Attributes)
+
+		bucketCounts := bucketCountList(m.BucketCounts)
+		explicitBounds := m.ExplicitBounds
+		if explicitBounds == nil {
+			explicitBounds = []float64{}
 		}
 
-		batch := histograms[i:end]
-		s.logger.Debug("Processing histogram batch", zap.Int("start", i), zap.Int("end", end), zap.Int("batch_size", len(batch)))
+		rows = append(rows, []driver.Value{
+			m.TimeUnix,
+			m.AgentID,
+			m.GroupID,
+			m.GroupName,
+			m.ServiceName,
+			m.MetricName,
+			m.MetricDescription,
+			int64(m.Count),
+			m.Sum,
+			m.Min,
+			m.Max,
+			bucketCounts,
+			explicitBounds,
+			resourceAttrsJSON,
+			metricAttrsJSON,
+			startTimestamp(m.StartTimeUnix),
+			m.MetricUnit,
+			m.ScopeName,
+			m.ScopeVersion,
+			otlp.TemporalityName(m.AggregationTemporality),
+			m.Flags,
+		})
+	}
+
+	if err := s.appendRows(ctx, "metrics_histogram", rows); err != nil {
+		return fmt.Errorf("failed to insert histogram metrics: %w", err)
+	}
+	return nil
+}
 
-		tx, err := s.db.BeginTx(ctx, nil)
-		if err != nil {
-			s.logger.Error("Failed to begin transaction", zap.Error(err))
-			return fmt.Errorf("failed to begin transaction: %w", err)
-		}
+func (s *Storage) writeOTLPExponentialHistograms(ctx context.Context, histograms []otlp.MetricExponentialHistogramData) error {
+	rows := make([][]driver.Value, 0, len(histograms))
+	for _, m := range histograms {
+		resourceAttrsJSON := s.encodeAttributes(m.ResourceAttributes)
+		metricAttrsJSON := s.encodeAttributes(m.Attributes)
+
+		rows = append(rows, []driver.Value{
+			m.TimeUnix,
+			m.AgentID,
+			m.GroupID,
+			m.GroupName,
+			m.ServiceName,
+			m.MetricName,
+			m.MetricDescription,
+			int64(m.Count),
+			m.Sum,
+			m.Min,
+			m.Max,
+			m.Scale,
+			int64(m.ZeroCount),
+			m.ZeroThreshold,
+			m.PositiveOffset,
+			bucketCountList(m.PositiveBucketCounts),
+			m.NegativeOffset,
+			bucketCountList(m.NegativeBucketCounts),
+			resourceAttrsJSON,
+			metricAttrsJSON,
+			startTimestamp(m.StartTimeUnix),
+			m.MetricUnit,
+			m.ScopeName,
+			m.ScopeVersion,
+			otlp.TemporalityName(m.AggregationTemporality),
+			m.Flags,
+		})
+	}
+
+	if err := s.appendRows(ctx, "metrics_exponential_histogram", rows); err != nil {
+		return fmt.Errorf("failed to insert exponential histogram metrics: %w", err)
+	}
+	return nil
+}
 
-		stmt, err := tx.PrepareContext(ctx, query)
-		if err != nil {
-			_ = tx.Rollback()
-			s.logger.Error("Failed to prepare statement", zap.Error(err))
-			return fmt.Errorf("failed to prepare statement: %w", err)
+func (s *Storage) writeOTLPSummaries(ctx context.Context, summaries []otlp.MetricSummaryData) error {
+	rows := make([][]driver.Value, 0, len(summaries))
+	for _, m := range summaries {
+		resourceAttrsJSON := s.encodeAttributes(m.ResourceAttributes)
+		metricAttrsJSON := s.encodeAttributes(m.Attributes)
+
+		quantiles := make([]float64, len(m.QuantileValues))
+		values := make([]float64, len(m.QuantileValues))
+		for i, q := range m.QuantileValues {
+			quantiles[i] = q.Quantile
+			values[i] = q.Value
 		}
 
-		for j, m := range batch {
-			resourceAttrsJSON, _ := json.Marshal(m.ResourceAttributes)
-			metricAttrsJSON, _ := json.Marshal(m.Attributes)
-
-			// Convert slices to arrays for DuckDB
-			bucketCountsStr := fmt.Sprintf("[%s]", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(m.BucketCounts)), ","), "[]"))
-			explicitBoundsStr := fmt.Sprintf("[%s]", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(m.ExplicitBounds)), ","), "[]"))
-
-			_, err = stmt.ExecContext(ctx,
-				m.TimeUnix,
-				m.AgentID,
-				m.GroupID,
-				m.GroupName,
-				m.ServiceName,
-				m.MetricName,
-				m.MetricDescription,
-				m.Count,
-				m.Sum,
-				m.Min,
-				m.Max,
-				bucketCountsStr,
-				explicitBoundsStr,
-				string(resourceAttrsJSON),
-				string(metricAttrsJSON),
-			)
-			if err != nil {
-				stmt.Close()
-				_ = tx.Rollback()
-				s.logger.Error("Failed to insert histogram metric", zap.Error(err), zap.Int("batch_index", j), zap.String("metric_name", m.MetricName))
-				return fmt.Errorf("failed to insert histogram metric: %w", err)
-			}
-		}
+		rows = append(rows, []driver.Value{
+			m.TimeUnix,
+			m.AgentID,
+			m.GroupID,
+			m.GroupName,
+			m.ServiceName,
+			m.MetricName,
+			m.MetricDescription,
+			int64(m.Count),
+			m.Sum,
+			quantiles,
+			values,
+			resourceAttrsJSON,
+			metricAttrsJSON,
+			startTimestamp(m.StartTimeUnix),
+			m.MetricUnit,
+			m.ScopeName,
+			m.ScopeVersion,
+			m.Flags,
+		})
+	}
+
+	if err := s.appendRows(ctx, "metrics_summary", rows); err != nil {
+		return fmt.Errorf("failed to insert summary metrics: %w", err)
+	}
+	return nil
+}
 
-		stmt.Close()
-		if err := tx.Commit(); err != nil {
-			s.logger.Error("Failed to commit transaction", zap.Error(err))
-			return fmt.Errorf("failed to commit transaction: %w", err)
-		}
+// bucketCountList converts OTLP bucket counts to the signed counts DuckDB stores
+func bucketCountList(counts []uint64) []int64 {
+	list := make([]int64, len(counts))
+	for i, count := range counts {
+		list[i] = int64(count)
+	}
+	return list
+}
 
-		s.logger.Debug("Histogram batch committed successfully", zap.Int("batch_size", len(batch)))
+// quantileList pairs the quantiles of a summary with their values
+func quantileList(quantiles, values []float64) []histogram.QuantileValue {
+	if len(quantiles) == 0 || len(quantiles) != len(values) {
+		return nil
 	}
+	list := make([]histogram.QuantileValue, len(quantiles))
+	for i := range quantiles {
+		list[i] = histogram.QuantileValue{Quantile: quantiles[i], Value: values[i]}
+	}
+	return list
+}
 
-	s.logger.Debug("All histogram batches completed successfully")
-	return nil
+// startTimestamp returns the start time of a data point, or nil when the
+// exporter left it unset
+func startTimestamp(start time.Time) driver.Value {
+	if start.UnixNano() <= 0 {
+		return nil
+	}
+	return start
 }
 
 // Writer interface implementations for types.Writer
@@ -1176,8 +1278,8 @@ func (w *Writer) WriteTraces(ctx context.Context, traces []otlp.TraceData) error
 }
 
 // WriteMetrics implements types.Writer
-func (w *Writer) WriteMetrics(ctx context.Context, sums []otlp.MetricSumData, gauges []otlp.MetricGaugeData, histograms []otlp.MetricHistogramData) error {
-	return w.Storage.WriteMetricsFromOTLP(ctx, sums, gauges, histograms)
+func (w *Writer) WriteMetrics(ctx context.Context, metrics *otlp.MetricsData) error {
+	return w.Storage.WriteMetricsFromOTLP(ctx, metrics)
 }
 
 // WriteLogs implements types.Writer
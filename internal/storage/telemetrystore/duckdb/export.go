@@ -0,0 +1,263 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+// Export streams the raw telemetry matching the query to w. Rows are read
+// from hot tables and sealed partitions in one snapshot and written as they
+// are produced, so the result is never held in memory. Exports can run long,
+// so only the caller's context bounds them.
+func (s *Storage) Export(ctx context.Context, query types.ExportQuery, w io.Writer) (int64, error) {
+	switch query.Format {
+	case types.ExportFormatParquet, types.ExportFormatNDJSON, types.ExportFormatCSV:
+	default:
+		return 0, fmt.Errorf("invalid export format: %s", query.Format)
+	}
+
+	tx, err := s.beginRead(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	sqlQuery, args, err := s.exportQuery(ctx, tx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	var rows int64
+	switch query.Format {
+	case types.ExportFormatParquet:
+		rows, err = s.exportParquet(ctx, tx, sqlQuery, args, w)
+	case types.ExportFormatNDJSON:
+		rows, err = exportNDJSON(ctx, tx, sqlQuery, args, w)
+	case types.ExportFormatCSV:
+		rows, err = exportCSV(ctx, tx, sqlQuery, args, w)
+	}
+	if err != nil {
+		return rows, s.queryError(ctx, err)
+	}
+
+	s.logger.Debug("Exported telemetry",
+		zap.String("signal", string(query.Signal)),
+		zap.String("format", string(query.Format)),
+		zap.Int64("rows", rows))
+	return rows, nil
+}
+
+// exportQuery builds the query selecting every column of the exported rows
+func (s *Storage) exportQuery(ctx context.Context, tx querier, query types.ExportQuery) (string, []interface{}, error) {
+	var from string
+	switch query.Signal {
+	case types.ExportSignalMetrics:
+		sumSource, err := s.tableSource(ctx, tx, "metrics_sum", query.StartTime, query.EndTime)
+		if err != nil {
+			return "", nil, err
+		}
+		gaugeSource, err := s.tableSource(ctx, tx, "metrics_gauge", query.StartTime, query.EndTime)
+		if err != nil {
+			return "", nil, err
+		}
+		histogramSource, err := s.tableSource(ctx, tx, "metrics_histogram", query.StartTime, query.EndTime)
+		if err != nil {
+			return "", nil, err
+		}
+		exponentialSource, err := s.tableSource(ctx, tx, "metrics_exponential_histogram", query.StartTime, query.EndTime)
+		if err != nil {
+			return "", nil, err
+		}
+		summarySource, err := s.tableSource(ctx, tx, "metrics_summary", query.StartTime, query.EndTime)
+		if err != nil {
+			return "", nil, err
+		}
+		// Columns of other metric types read as NULL, such as value for histograms
+		from = fmt.Sprintf(`(
+			SELECT 'sum' AS metric_type, * FROM %s
+			UNION ALL BY NAME SELECT 'gauge' AS metric_type, * FROM %s
+			UNION ALL BY NAME SELECT 'histogram' AS metric_type, * FROM %s
+			UNION ALL BY NAME SELECT 'exponential_histogram' AS metric_type, * FROM %s
+			UNION ALL BY NAME SELECT 'summary' AS metric_type, * FROM %s
+		) AS metrics`, sumSource, gaugeSource, histogramSource, exponentialSource, summarySource)
+	case types.ExportSignalLogs, types.ExportSignalTraces:
+		source, err := s.tableSource(ctx, tx, string(query.Signal), query.StartTime, query.EndTime)
+		if err != nil {
+			return "", nil, err
+		}
+		from = source
+	default:
+		return "", nil, fmt.Errorf("invalid export signal: %s", query.Signal)
+	}
+
+	sqlQuery := fmt.Sprintf(`SELECT * FROM %s WHERE timestamp >= ? AND timestamp <= ?`, from)
+	args := []interface{}{query.StartTime, query.EndTime}
+
+	if query.AgentID != nil {
+		sqlQuery += ` AND agent_id = ?`
+		args = append(args, query.AgentID.String())
+	}
+
+	if query.GroupID != nil {
+		sqlQuery += ` AND group_id = ?`
+		args = append(args, *query.GroupID)
+	}
+
+	if query.ServiceName != nil {
+		sqlQuery += ` AND service_name = ?`
+		args = append(args, *query.ServiceName)
+	}
+
+	sqlQuery += ` ORDER BY timestamp`
+	return sqlQuery, args, nil
+}
+
+// exportParquet writes the rows to a temporary Parquet file and copies it to
+// w. Parquet keeps its metadata in a footer, so the file must be complete
+// before any of it can be sent.
+func (s *Storage) exportParquet(ctx context.Context, tx *sql.Tx, sqlQuery string, args []interface{}, w io.Writer) (int64, error) {
+	file, err := os.CreateTemp("", "lawrence-export-*.parquet")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create export file: %w", err)
+	}
+	path := file.Name()
+	_ = file.Close()
+	defer func() { _ = os.Remove(path) }()
+
+	copyQuery := fmt.Sprintf("COPY (%s) TO %s (FORMAT PARQUET, COMPRESSION ZSTD)", sqlQuery, stringLiteral(path))
+	result, err := tx.ExecContext(ctx, copyQuery, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to export parquet: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+
+	file, err = os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open export file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(w, file); err != nil {
+		return 0, fmt.Errorf("failed to write export: %w", err)
+	}
+	return rows, nil
+}
+
+// exportNDJSON writes one JSON object per row. DuckDB renders the rows, so
+// attribute columns are nested as objects rather than quoted strings.
+func exportNDJSON(ctx context.Context, tx *sql.Tx, sqlQuery string, args []interface{}, w io.Writer) (int64, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT CAST(to_json(e) AS VARCHAR) FROM (%s) AS e", sqlQuery), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to export ndjson: %w", err)
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return count, fmt.Errorf("failed to scan export row: %w", err)
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return count, fmt.Errorf("failed to write export: %w", err)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("error iterating export rows: %w", err)
+	}
+	return count, nil
+}
+
+// exportCSV writes a header row followed by one record per row
+func exportCSV(ctx context.Context, tx *sql.Tx, sqlQuery string, args []interface{}, w io.Writer) (int64, error) {
+	rows, err := tx.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to export csv: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get columns: %w", err)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return 0, fmt.Errorf("failed to write export: %w", err)
+	}
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+
+	var count int64
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return count, fmt.Errorf("failed to scan export row: %w", err)
+		}
+		for i, value := range values {
+			record[i] = csvValue(value)
+		}
+		if err := writer.Write(record); err != nil {
+			return count, fmt.Errorf("failed to write export: %w", err)
+		}
+		count++
+
+		// Flush periodically so large exports stream instead of buffering
+		if count%1000 == 0 {
+			writer.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("error iterating export rows: %w", err)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return count, fmt.Errorf("failed to write export: %w", err)
+	}
+	return count, nil
+}
+
+// csvValue formats a scanned column value as a CSV field
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case int64, int32, bool:
+		return fmt.Sprint(v)
+	default:
+		// Lists and structs are written as JSON
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+func TestExport_NDJSONFiltersByService(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	writeTestLogs(t, storage, "api", "prod", time.Minute, 2*time.Minute)
+	writeTestLogs(t, storage, "checkout", "prod", time.Minute)
+
+	service := "api"
+	var buf bytes.Buffer
+	rows, err := storage.Export(ctx, types.ExportQuery{
+		Signal:      types.ExportSignalLogs,
+		Format:      types.ExportFormatNDJSON,
+		ServiceName: &service,
+		StartTime:   time.Now().Add(-time.Hour),
+		EndTime:     time.Now(),
+	}, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rows)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		assert.Equal(t, "api", record["service_name"])
+		assert.Equal(t, "message", record["body"])
+	}
+}
+
+func TestExport_CSVMetricsAcrossTypes(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, storage.WriteMetricsFromOTLP(ctx, &otlp.MetricsData{
+		Sums: []otlp.MetricSumData{{TimeUnix: now.Add(-2 * time.Second), AgentID: "agent", ServiceName: "api", MetricName: "requests", Value: 3}},
+		Histograms: []otlp.MetricHistogramData{{
+			TimeUnix: now.Add(-time.Second), AgentID: "agent", ServiceName: "api", MetricName: "latency",
+			Count: 3, Sum: 6, BucketCounts: []uint64{1, 2}, ExplicitBounds: []float64{5},
+		}},
+	}))
+
+	var buf bytes.Buffer
+	rows, err := storage.Export(ctx, types.ExportQuery{
+		Signal:    types.ExportSignalMetrics,
+		Format:    types.ExportFormatCSV,
+		StartTime: now.Add(-time.Hour),
+		EndTime:   now,
+	}, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rows)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	header := records[0]
+	column := func(record []string, name string) string {
+		for i, col := range header {
+			if col == name {
+				return record[i]
+			}
+		}
+		t.Fatalf("missing column %s", name)
+		return ""
+	}
+	assert.Equal(t, "sum", column(records[1], "metric_type"))
+	assert.Equal(t, "3", column(records[1], "value"))
+	assert.Equal(t, "histogram", column(records[2], "metric_type"))
+	assert.Equal(t, "[1,2]", column(records[2], "bucket_counts"))
+	assert.Empty(t, column(records[2], "value"))
+}
+
+func TestExport_ParquetIncludesSealedPartitions(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	today := time.Now().UTC().Truncate(partitionDay)
+	writeTestLogsAt(t, storage, "api", "prod", today.Add(-36*time.Hour), today.Add(time.Minute))
+
+	_, err := storage.SealPartitions(ctx, today)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	rows, err := storage.Export(ctx, types.ExportQuery{
+		Signal:    types.ExportSignalLogs,
+		Format:    types.ExportFormatParquet,
+		StartTime: today.Add(-72 * time.Hour),
+		EndTime:   today.Add(time.Hour),
+	}, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rows)
+
+	path := filepath.Join(t.TempDir(), "export.parquet")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	var count int64
+	require.NoError(t, storage.db.QueryRow("SELECT COUNT(*) FROM read_parquet("+stringLiteral(path)+")").Scan(&count))
+	assert.Equal(t, int64(2), count)
+}
+
+func TestExport_InvalidQuery(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+
+	_, err := storage.Export(ctx, types.ExportQuery{Signal: "events", Format: types.ExportFormatCSV}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "invalid export signal")
+
+	_, err = storage.Export(ctx, types.ExportQuery{Signal: types.ExportSignalLogs, Format: "xlsx"}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "invalid export format")
+}
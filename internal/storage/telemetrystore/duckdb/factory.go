@@ -6,6 +6,9 @@ package duckdb
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -15,21 +18,23 @@ import (
 // Factory implements TelemetryStoreFactory and creates storage components backed by DuckDB.
 type Factory struct {
 	dbPath  string
+	opts    Options
 	logger  *zap.Logger
 	storage *Storage
 }
 
-// NewFactory creates a new Factory with the given database path.
-func NewFactory(dbPath string) *Factory {
+// NewFactory creates a new Factory with the given database path and options
+func NewFactory(dbPath string, opts Options) *Factory {
 	return &Factory{
 		dbPath: dbPath,
+		opts:   opts,
 	}
 }
 
 // Initialize implements TelemetryStoreFactory
 func (f *Factory) Initialize(logger *zap.Logger) error {
 	f.logger = logger
-	storage, err := NewStorage(f.dbPath, logger)
+	storage, err := NewStorageWithOptions(f.dbPath, f.opts, logger)
 	if err != nil {
 		return err
 	}
@@ -56,26 +61,136 @@ func (f *Factory) Purge(ctx context.Context) error {
 		"metrics_sum",
 		"metrics_gauge",
 		"metrics_histogram",
+		"metrics_exponential_histogram",
+		"metrics_summary",
 		"logs",
 		"traces",
 		"rollups_1m",
 		"rollups_5m",
 		"rollups_1h",
 		"rollups_1d",
+		"rollup_state",
+		"usage_stats",
+		"telemetry_partitions",
 	}
 
 	for _, table := range tables {
 		query := fmt.Sprintf("DELETE FROM %s", table)
-		_, err := f.storage.db.ExecContext(ctx, query)
+		_, err := f.storage.execWrite(ctx, query)
 		if err != nil {
 			// Ignore errors for tables that don't exist
 			f.logger.Warn("Failed to purge table", zap.String("table", table), zap.Error(err))
 		}
 	}
 
+	if err := os.RemoveAll(f.storage.coldPath); err != nil {
+		f.logger.Warn("Failed to purge cold partitions", zap.String("path", f.storage.coldPath), zap.Error(err))
+	}
+
 	return nil
 }
 
+// SchemaVersion implements telemetrystore.Backupper
+func (f *Factory) SchemaVersion() int {
+	return SchemaVersion
+}
+
+// Backup implements telemetrystore.Backupper
+func (f *Factory) Backup(ctx context.Context, dir string) error {
+	if f.storage == nil {
+		return fmt.Errorf("telemetry store is not initialized")
+	}
+	return f.storage.Backup(ctx, dir)
+}
+
+// PrepareRestore implements telemetrystore.Backupper. The database and sealed
+// partitions are replaced on commit, so it must run before the factory is
+// initialized.
+func (f *Factory) PrepareRestore(ctx context.Context, dir string) error {
+	if f.storage != nil {
+		return fmt.Errorf("cannot restore an open telemetry store")
+	}
+	if err := prepareRestore(ctx, f.dbPath, f.coldPath(), dir); err != nil {
+		_ = discardRestore(f.dbPath, f.coldPath())
+		return err
+	}
+	return nil
+}
+
+// CommitRestore implements telemetrystore.Backupper
+func (f *Factory) CommitRestore() error {
+	if f.storage != nil {
+		return fmt.Errorf("cannot restore an open telemetry store")
+	}
+	return commitRestore(f.dbPath, f.coldPath())
+}
+
+// DiscardRestore implements telemetrystore.Backupper
+func (f *Factory) DiscardRestore() error {
+	return discardRestore(f.dbPath, f.coldPath())
+}
+
+// coldPath returns where sealed partitions are stored
+func (f *Factory) coldPath() string {
+	if f.opts.ColdPath != "" {
+		return f.opts.ColdPath
+	}
+	return filepath.Join(filepath.Dir(f.dbPath), "cold")
+}
+
+// MigrationStatus implements telemetrystore.Migrator
+func (f *Factory) MigrationStatus(ctx context.Context) ([]types.MigrationStatus, error) {
+	if f.storage != nil {
+		return nil, fmt.Errorf("cannot inspect migrations of an open telemetry store")
+	}
+	db, err := openMigrationDB(f.dbPath, false)
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int]time.Time{}
+	if db != nil {
+		defer db.Close()
+		if applied, err = appliedMigrations(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+	return migrationStatus(applied), nil
+}
+
+// Migrate implements telemetrystore.Migrator. It returns the migrations that
+// were applied, or with dryRun the ones that would be.
+func (f *Factory) Migrate(ctx context.Context, dryRun bool) ([]types.MigrationStatus, error) {
+	if f.storage != nil {
+		return nil, fmt.Errorf("cannot migrate an open telemetry store")
+	}
+	db, err := openMigrationDB(f.dbPath, !dryRun)
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int]time.Time{}
+	if db != nil {
+		defer db.Close()
+		if applied, err = appliedMigrations(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+	pending, err := pendingMigrations(applied)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		if pending, err = migrate(ctx, db, zap.NewNop()); err != nil {
+			return nil, err
+		}
+	}
+
+	status := make([]types.MigrationStatus, len(pending))
+	for i, migration := range pending {
+		status[i] = types.MigrationStatus{Version: migration.Version, Description: migration.Description, SQL: migration.SQL}
+	}
+	return status, nil
+}
+
 // Close closes the storage connection
 func (f *Factory) Close() error {
 	if f.storage != nil {
@@ -0,0 +1,169 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+// metricFieldColumns maps the metric fields filters and groupings can name
+// to the column that holds them
+var metricFieldColumns = map[string]string{
+	"name":          "metric_name",
+	"metric":        "metric_name",
+	"metric_name":   "metric_name",
+	"type":          "metric_type",
+	"agent_id":      "agent_id",
+	"group_id":      "group_id",
+	"service_name":  "service_name",
+	"unit":          "metric_unit",
+	"scope_name":    "scope_name",
+	"scope_version": "scope_version",
+	"temporality":   "temporality",
+	"monotonic":     "is_monotonic",
+}
+
+// logFieldColumns maps the log fields filters and groupings can name to the
+// column that holds them
+var logFieldColumns = map[string]string{
+	"severity":      "severity_text",
+	"level":         "severity_text",
+	"body":          "body",
+	"message":       "body",
+	"trace_id":      "trace_id",
+	"span_id":       "span_id",
+	"scope_name":    "scope_name",
+	"scope_version": "scope_version",
+	"service_name":  "service_name",
+	"agent_id":      "agent_id",
+	"group_id":      "group_id",
+}
+
+// spanFieldColumns maps the span fields filters can name to the column that
+// holds them
+var spanFieldColumns = map[string]string{
+	"name":           "span_name",
+	"span_name":      "span_name",
+	"service":        "service_name",
+	"service_name":   "service_name",
+	"kind":           "span_kind",
+	"span_kind":      "span_kind",
+	"status":         "status_code",
+	"status_code":    "status_code",
+	"status_message": "status_message",
+	"duration":       "duration",
+	"trace_id":       "trace_id",
+	"span_id":        "span_id",
+	"parent_span_id": "parent_span_id",
+	"trace_state":    "trace_state",
+	"scope_name":     "scope_name",
+	"scope_version":  "scope_version",
+	"agent_id":       "agent_id",
+	"group_id":       "group_id",
+}
+
+// fieldSet resolves field names to SQL expressions for one signal
+type fieldSet struct {
+	columns          map[string]string
+	attributesColumn string
+	// resourceColumn holds resource attributes, which attributes fall back
+	// to. Fields prefixed with span. or resource. read only one of them.
+	resourceColumn string
+}
+
+var (
+	metricFields = fieldSet{columns: metricFieldColumns, attributesColumn: "metric_attributes"}
+	logFields    = fieldSet{columns: logFieldColumns, attributesColumn: "log_attributes"}
+	spanFields   = fieldSet{columns: spanFieldColumns, attributesColumn: "span_attributes", resourceColumn: "resource_attributes"}
+)
+
+// expr returns the SQL expression of a field as a string. Fields with a
+// column read it, falling back to the attribute of the same name when the
+// column is empty, and other fields read the attribute.
+func (f fieldSet) expr(field string) string {
+	attribute := f.attribute(field)
+	column, ok := f.columns[field]
+	if !ok {
+		return attribute
+	}
+	return fmt.Sprintf("COALESCE(NULLIF(CAST(%s AS VARCHAR), ''), %s)", column, attribute)
+}
+
+// attribute returns the SQL expression of an attribute as a string
+func (f fieldSet) attribute(field string) string {
+	extract := func(column, key string) string {
+		return fmt.Sprintf("json_extract_string(%s, %s)", column, stringLiteral(jsonPointer(key)))
+	}
+	if f.resourceColumn == "" {
+		return extract(f.attributesColumn, field)
+	}
+	if key, ok := strings.CutPrefix(field, "span."); ok {
+		return extract(f.attributesColumn, key)
+	}
+	if key, ok := strings.CutPrefix(field, "resource."); ok {
+		return extract(f.resourceColumn, key)
+	}
+	return fmt.Sprintf("COALESCE(%s, %s)", extract(f.attributesColumn, field), extract(f.resourceColumn, field))
+}
+
+// conditions compiles filters to SQL conditions joined with AND
+func (f fieldSet) conditions(filters []types.Filter) (string, []interface{}, error) {
+	var sqlConditions []string
+	var args []interface{}
+	for _, filter := range filters {
+		condition, conditionArgs, err := filterCondition(f.expr(filter.Field), filter)
+		if err != nil {
+			return "", nil, err
+		}
+		sqlConditions = append(sqlConditions, condition)
+		args = append(args, conditionArgs...)
+	}
+	return strings.Join(sqlConditions, " AND "), args, nil
+}
+
+// filterCondition compiles a filter on a string expression. Numeric filters
+// compare numbers when the field holds one, and the string form otherwise.
+// Fields that are missing only match the negated operators.
+func filterCondition(expr string, filter types.Filter) (string, []interface{}, error) {
+	switch filter.Operator {
+	case types.FilterOpEqual, types.FilterOpNotEqual:
+		condition := expr + " = ?"
+		args := []interface{}{filter.Value}
+		if number, err := strconv.ParseFloat(filter.Value, 64); filter.Numeric && err == nil {
+			condition = fmt.Sprintf("COALESCE(TRY_CAST(%s AS DOUBLE) = ?, %s = ?)", expr, expr)
+			args = []interface{}{number, filter.Value}
+		}
+		if filter.Operator == types.FilterOpNotEqual {
+			condition = fmt.Sprintf("(%s IS NULL OR NOT %s)", expr, condition)
+		}
+		return condition, args, nil
+	case types.FilterOpRegex, types.FilterOpNotRegex:
+		if _, err := regexp.Compile(filter.Value); err != nil {
+			return "", nil, fmt.Errorf("invalid regular expression for %s: %w", filter.Field, err)
+		}
+		if filter.Operator == types.FilterOpNotRegex {
+			return fmt.Sprintf("(%s IS NULL OR NOT regexp_matches(%s, ?))", expr, expr), []interface{}{filter.Value}, nil
+		}
+		return fmt.Sprintf("regexp_matches(%s, ?)", expr), []interface{}{filter.Value}, nil
+	case types.FilterOpGT, types.FilterOpGTE, types.FilterOpLT, types.FilterOpLTE:
+		number, err := strconv.ParseFloat(filter.Value, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("filter %s %s requires a numeric value, got %q", filter.Field, filter.Operator, filter.Value)
+		}
+		return fmt.Sprintf("TRY_CAST(%s AS DOUBLE) %s ?", expr, filter.Operator), []interface{}{number}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported filter operator: %s", filter.Operator)
+	}
+}
+
+// jsonPointer returns the JSON pointer to a top-level attribute, which
+// unlike a JSON path needs no quoting for keys containing dots
+func jsonPointer(key string) string {
+	return "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+// Migration is a single ordered change to the telemetry schema
+type Migration struct {
+	Version     int
+	Description string
+	SQL         string
+}
+
+// migrationsTable records the migrations applied to a database
+const migrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	description VARCHAR NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`
+
+// appliedMigrations returns when each applied migration ran. A database
+// without the migrations table has none applied.
+func appliedMigrations(ctx context.Context, q querier) (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+
+	rows, err := q.QueryContext(ctx, `SELECT COUNT(*) FROM information_schema.tables WHERE table_name = 'schema_migrations'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check schema migrations: %w", err)
+	}
+	var tables int
+	if rows.Next() {
+		err = rows.Scan(&tables)
+	}
+	_ = rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check schema migrations: %w", err)
+	}
+	if tables == 0 {
+		return applied, nil
+	}
+
+	rows, err = q.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema migrations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema migration: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// migrationStatus returns every known migration with when it was applied
+func migrationStatus(applied map[int]time.Time) []types.MigrationStatus {
+	status := make([]types.MigrationStatus, len(Migrations))
+	for i, migration := range Migrations {
+		status[i] = types.MigrationStatus{
+			Version:     migration.Version,
+			Description: migration.Description,
+			SQL:         migration.SQL,
+		}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status[i].AppliedAt = &appliedAt
+		}
+	}
+	return status
+}
+
+// pendingMigrations returns the migrations not yet applied, refusing
+// databases written by a newer build
+func pendingMigrations(applied map[int]time.Time) ([]Migration, error) {
+	for version := range applied {
+		if version > SchemaVersion {
+			return nil, fmt.Errorf("database schema version %d is newer than supported version %d", version, SchemaVersion)
+		}
+	}
+	var pending []Migration
+	for _, migration := range Migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// migrate applies the pending migrations, each in its own transaction, and
+// returns the ones applied
+func migrate(ctx context.Context, db *sql.DB, logger *zap.Logger) ([]Migration, error) {
+	if _, err := db.ExecContext(ctx, migrationsTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema migrations table: %w", err)
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := pendingMigrations(applied)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range pending {
+		if err := applyMigration(ctx, db, migration); err != nil {
+			return nil, err
+		}
+		logger.Info("Applied telemetry schema migration",
+			zap.Int("version", migration.Version),
+			zap.String("description", migration.Description))
+	}
+	return pending, nil
+}
+
+// applyMigration runs a migration and records it in one transaction
+func applyMigration(ctx context.Context, db *sql.DB, migration Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", migration.Version, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, migration.SQL); err != nil {
+		return fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Description, err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`,
+		migration.Version, migration.Description, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", migration.Version, err)
+	}
+	return nil
+}
+
+// openMigrationDB opens the database at dbPath for the migrate command. It
+// returns nil when the database does not exist and create is false.
+func openMigrationDB(dbPath string, create bool) (*sql.DB, error) {
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) && !create {
+		return nil, nil
+	}
+	db, err := sql.Open("duckdb", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DuckDB database: %w", err)
+	}
+	// Surface lock conflicts with a running server here rather than on the
+	// first query
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to open DuckDB database, is Lawrence running? %w", err)
+	}
+	return db, nil
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// schemaColumns describes every column of a database for comparing schemas
+func schemaColumns(t *testing.T, db *sql.DB) []string {
+	rows, err := db.Query(`
+		SELECT table_name || '.' || column_name || ' ' || data_type
+		FROM information_schema.columns
+		WHERE table_name != 'schema_migrations'
+		ORDER BY table_name, column_name`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		require.NoError(t, rows.Scan(&column))
+		columns = append(columns, column)
+	}
+	require.NoError(t, rows.Err())
+	return columns
+}
+
+// unversionedMigrations is the number of migrations that predate the
+// migrations table
+const unversionedMigrations = 4
+
+func TestMigrations_AreOrdered(t *testing.T) {
+	for i, migration := range Migrations {
+		assert.Equal(t, i+1, migration.Version)
+		assert.NotEmpty(t, migration.Description)
+	}
+	assert.Equal(t, SchemaVersion, Migrations[len(Migrations)-1].Version)
+}
+
+func TestMigrate_FromEachVersion(t *testing.T) {
+	ctx := context.Background()
+	latest := newTestStorage(t)
+	want := schemaColumns(t, latest.db)
+
+	for version := 0; version <= SchemaVersion; version++ {
+		// Databases created before the migrations table have no record of
+		// the migrations they already contain
+		for _, recorded := range []bool{true, false} {
+			if !recorded && version > unversionedMigrations {
+				continue
+			}
+			t.Run(fmt.Sprintf("v%d recorded=%t", version, recorded), func(t *testing.T) {
+				dbPath := filepath.Join(t.TempDir(), "telemetry.db")
+				db, err := sql.Open("duckdb", dbPath)
+				require.NoError(t, err)
+				if recorded {
+					_, err = db.Exec(migrationsTable)
+					require.NoError(t, err)
+				}
+				for _, migration := range Migrations[:version] {
+					if recorded {
+						require.NoError(t, applyMigration(ctx, db, migration))
+					} else {
+						_, err = db.Exec(migration.SQL)
+						require.NoError(t, err)
+					}
+				}
+				if version > 0 {
+					_, err = db.Exec(`INSERT INTO logs (timestamp, agent_id, service_name, body) VALUES (now(), 'agent', 'api', 'kept')`)
+					require.NoError(t, err)
+				}
+				require.NoError(t, db.Close())
+
+				storage, err := NewStorage(dbPath, zap.NewNop())
+				require.NoError(t, err)
+				defer storage.Close()
+
+				assert.Equal(t, want, schemaColumns(t, storage.db))
+				applied, err := appliedMigrations(ctx, storage.db)
+				require.NoError(t, err)
+				assert.Len(t, applied, SchemaVersion)
+				if version > 0 {
+					assert.Equal(t, int64(1), countRows(t, storage, "logs"))
+				}
+			})
+		}
+	}
+}
+
+func TestMigrate_RefusesNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "telemetry.db")
+	storage, err := NewStorage(dbPath, zap.NewNop())
+	require.NoError(t, err)
+	_, err = storage.execWrite(context.Background(),
+		`INSERT INTO schema_migrations VALUES (?, 'from the future', now())`, SchemaVersion+1)
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+
+	_, err = NewStorage(dbPath, zap.NewNop())
+	assert.ErrorContains(t, err, fmt.Sprintf("database schema version %d is newer than supported version %d", SchemaVersion+1, SchemaVersion))
+}
+
+func TestFactoryMigrate_StatusAndDryRun(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "telemetry.db")
+
+	// A database at version 2 has the later migrations pending
+	db, err := sql.Open("duckdb", dbPath)
+	require.NoError(t, err)
+	_, err = db.Exec(migrationsTable)
+	require.NoError(t, err)
+	for _, migration := range Migrations[:2] {
+		require.NoError(t, applyMigration(ctx, db, migration))
+	}
+	require.NoError(t, db.Close())
+
+	factory := NewFactory(dbPath, Options{})
+	status, err := factory.MigrationStatus(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, SchemaVersion)
+	assert.NotNil(t, status[1].AppliedAt)
+	assert.Nil(t, status[2].AppliedAt)
+
+	pending, err := factory.Migrate(ctx, true)
+	require.NoError(t, err)
+	require.Len(t, pending, SchemaVersion-2)
+	assert.Equal(t, 3, pending[0].Version)
+	assert.Contains(t, pending[0].SQL, "ALTER TABLE rollups_1m")
+
+	// A dry run leaves the database unchanged
+	status, err = factory.MigrationStatus(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, status[2].AppliedAt)
+
+	applied, err := factory.Migrate(ctx, false)
+	require.NoError(t, err)
+	assert.Len(t, applied, SchemaVersion-2)
+
+	applied, err = factory.Migrate(ctx, false)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	require.NoError(t, factory.Initialize(zap.NewNop()))
+	defer factory.Close()
+	_, err = factory.Migrate(ctx, false)
+	assert.ErrorContains(t, err, "cannot migrate an open telemetry store")
+}
+
+func TestFactoryMigrate_MissingDatabase(t *testing.T) {
+	ctx := context.Background()
+	factory := NewFactory(filepath.Join(t.TempDir(), "telemetry.db"), Options{})
+
+	status, err := factory.MigrationStatus(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, SchemaVersion)
+	for _, migration := range status {
+		assert.Nil(t, migration.AppliedAt)
+	}
+
+	pending, err := factory.Migrate(ctx, true)
+	require.NoError(t, err)
+	assert.Len(t, pending, SchemaVersion)
+}
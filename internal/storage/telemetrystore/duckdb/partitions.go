@@ -0,0 +1,274 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+// partitionDay is the time span covered by a partition
+const partitionDay = 24 * time.Hour
+
+// partitionedTables are the raw tables sealed into day partitions
+var partitionedTables = []string{
+	"metrics_sum", "metrics_gauge", "metrics_histogram", "metrics_exponential_histogram", "metrics_summary",
+	"logs", "traces",
+}
+
+// SealPartitions moves every day of raw telemetry before the given time out
+// of the database into compressed Parquet files in the cold path
+func (s *Storage) SealPartitions(ctx context.Context, before time.Time) ([]types.Partition, error) {
+	before = before.UTC().Truncate(partitionDay)
+
+	var sealed []types.Partition
+	for _, table := range partitionedTables {
+		days, err := s.unsealedDays(ctx, table, before)
+		if err != nil {
+			return sealed, err
+		}
+
+		for _, day := range days {
+			partition, err := s.sealPartition(ctx, table, day)
+			if err != nil {
+				return sealed, err
+			}
+			sealed = append(sealed, *partition)
+			s.logger.Info("Sealed partition",
+				zap.String("table", table),
+				zap.String("day", day.Format(time.DateOnly)),
+				zap.Int64("rows", partition.Rows),
+				zap.Int64("bytes", partition.Bytes))
+		}
+	}
+
+	return sealed, nil
+}
+
+// unsealedDays returns the days before the given time with rows in the hot table
+func (s *Storage) unsealedDays(ctx context.Context, table string, before time.Time) ([]time.Time, error) {
+	query := fmt.Sprintf("SELECT DISTINCT CAST(timestamp AS DATE) AS day FROM %s WHERE timestamp < ? ORDER BY day", table)
+	rows, err := s.db.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find unsealed days in %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, fmt.Errorf("failed to scan day: %w", err)
+		}
+		days = append(days, day.UTC())
+	}
+	return days, rows.Err()
+}
+
+// sealPartition copies a day of a hot table to a new Parquet file and deletes
+// it from the table
+func (s *Storage) sealPartition(ctx context.Context, table string, day time.Time) (*types.Partition, error) {
+	path, err := s.newPartitionPath(table, day)
+	if err != nil {
+		return nil, err
+	}
+
+	// Copying and deleting in one transaction leaves rows written concurrently
+	// in the hot table, since neither statement can see them
+	tx, err := s.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	copyQuery := fmt.Sprintf("COPY (SELECT * FROM %s WHERE timestamp >= ? AND timestamp < ?) TO %s (FORMAT PARQUET, COMPRESSION ZSTD)",
+		table, stringLiteral(path))
+	result, err := tx.ExecContext(ctx, copyQuery, day, day.Add(partitionDay))
+	if err != nil {
+		return nil, fmt.Errorf("failed to write partition %s: %w", path, err)
+	}
+	rows, _ := result.RowsAffected()
+
+	partition, err := s.recordPartition(ctx, tx, table, day, path, rows)
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE timestamp >= ? AND timestamp < ?", table)
+	if _, err := tx.ExecContext(ctx, deleteQuery, day, day.Add(partitionDay)); err != nil {
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("failed to delete sealed rows from %s: %w", table, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("failed to commit partition %s: %w", path, err)
+	}
+	return partition, nil
+}
+
+// newPartitionPath returns an absolute path for a new file of a table's day
+func (s *Storage) newPartitionPath(table string, day time.Time) (string, error) {
+	dir := filepath.Join(s.coldPath, table, day.Format(time.DateOnly))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create partition directory: %w", err)
+	}
+	path, err := filepath.Abs(filepath.Join(dir, fmt.Sprintf("part-%d.parquet", time.Now().UnixNano())))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve partition path: %w", err)
+	}
+	return path, nil
+}
+
+// recordPartition registers a written Parquet file as a partition
+func (s *Storage) recordPartition(ctx context.Context, tx execer, table string, day time.Time, path string, rows int64) (*types.Partition, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat partition %s: %w", path, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO telemetry_partitions (path, table_name, day, row_count, size_bytes, sealed_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, path, table, day, rows, info.Size(), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to record partition %s: %w", path, err)
+	}
+
+	return &types.Partition{Table: table, Day: day, Path: path, Rows: rows, Bytes: info.Size()}, nil
+}
+
+// listPartitions returns the sealed partitions of a table, limited to those
+// overlapping the time range when one is given
+func (s *Storage) listPartitions(ctx context.Context, q querier, table string, start, end *time.Time) ([]types.Partition, error) {
+	query := `SELECT path, day, row_count, size_bytes FROM telemetry_partitions WHERE table_name = ?`
+	args := []interface{}{table}
+	if start != nil {
+		query += ` AND day >= ?`
+		args = append(args, start.UTC().Truncate(partitionDay))
+	}
+	if end != nil {
+		query += ` AND day <= ?`
+		args = append(args, *end)
+	}
+	query += ` ORDER BY day, path`
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var partitions []types.Partition
+	for rows.Next() {
+		partition := types.Partition{Table: table}
+		if err := rows.Scan(&partition.Path, &partition.Day, &partition.Rows, &partition.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan partition: %w", err)
+		}
+		partition.Day = partition.Day.UTC()
+		partitions = append(partitions, partition)
+	}
+	return partitions, rows.Err()
+}
+
+// tableSource returns a FROM source reading a hot table together with its
+// sealed partitions that overlap the time range. Listing the partitions in
+// the transaction of the query keeps both on the same snapshot.
+func (s *Storage) tableSource(ctx context.Context, tx querier, table string, start, end time.Time) (string, error) {
+	partitions, err := s.listPartitions(ctx, tx, table, &start, &end)
+	if err != nil {
+		return "", s.queryError(ctx, err)
+	}
+	if len(partitions) == 0 {
+		return table, nil
+	}
+
+	paths := make([]string, len(partitions))
+	for i, partition := range partitions {
+		paths[i] = stringLiteral(partition.Path)
+	}
+
+	// Partitions sealed before a column was added lack it, so columns are
+	// matched by name and missing ones read as NULL
+	return fmt.Sprintf("(SELECT * FROM %s UNION ALL BY NAME SELECT * FROM read_parquet([%s], union_by_name = true)) AS %s",
+		table, strings.Join(paths, ", "), table), nil
+}
+
+// dropPartition removes a partition and its file
+func (s *Storage) dropPartition(ctx context.Context, partition types.Partition) error {
+	if _, err := s.execWrite(ctx, `DELETE FROM telemetry_partitions WHERE path = ?`, partition.Path); err != nil {
+		return fmt.Errorf("failed to drop partition %s: %w", partition.Path, err)
+	}
+	s.removeFile(partition.Path)
+	return nil
+}
+
+// rewritePartition replaces a partition with a copy holding only the rows
+// matching keep. A partition left empty is dropped.
+func (s *Storage) rewritePartition(ctx context.Context, partition types.Partition, keep string, args []interface{}) error {
+	path, err := s.newPartitionPath(partition.Table, partition.Day)
+	if err != nil {
+		return err
+	}
+
+	copyQuery := fmt.Sprintf("COPY (SELECT * FROM read_parquet(%s) WHERE %s) TO %s (FORMAT PARQUET, COMPRESSION ZSTD)",
+		stringLiteral(partition.Path), keep, stringLiteral(path))
+	result, err := s.execWrite(ctx, copyQuery, args...)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite partition %s: %w", partition.Path, err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		_ = os.Remove(path)
+		return s.dropPartition(ctx, partition)
+	}
+
+	tx, err := s.beginWrite(ctx)
+	if err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM telemetry_partitions WHERE path = ?`, partition.Path); err != nil {
+		_ = os.Remove(path)
+		return fmt.Errorf("failed to replace partition %s: %w", partition.Path, err)
+	}
+	if _, err := s.recordPartition(ctx, tx, partition.Table, partition.Day, path, rows); err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		_ = os.Remove(path)
+		return fmt.Errorf("failed to commit partition %s: %w", path, err)
+	}
+
+	s.removeFile(partition.Path)
+	return nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// stringLiteral quotes a value for use as a SQL string literal
+func stringLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+func queryAllLogs(t *testing.T, storage *Storage, start, end time.Time) []types.Log {
+	logs, err := storage.QueryLogs(context.Background(), types.LogQuery{StartTime: start, EndTime: end})
+	require.NoError(t, err)
+	return logs
+}
+
+func TestSealPartitions_QueriesSpanHotAndCold(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	today := time.Now().UTC().Truncate(partitionDay)
+
+	writeTestLogsAt(t, storage, "api", "prod",
+		today.Add(-50*time.Hour), today.Add(-47*time.Hour), today.Add(-20*time.Hour), today.Add(time.Minute))
+	require.NoError(t, storage.WriteMetricsFromOTLP(ctx, &otlp.MetricsData{Gauges: []otlp.MetricGaugeData{
+		{AgentID: "agent", ServiceName: "api", MetricName: "cpu", TimeUnix: today.Add(-30 * time.Hour), Value: 1},
+	}}))
+
+	sealed, err := storage.SealPartitions(ctx, today.Add(-time.Hour))
+	require.NoError(t, err)
+	// Two days of logs and one day of gauges; yesterday and today stay hot
+	require.Len(t, sealed, 3)
+	for _, partition := range sealed {
+		assert.FileExists(t, partition.Path)
+		assert.Positive(t, partition.Bytes)
+	}
+	assert.Equal(t, int64(2), countRows(t, storage, "logs"))
+	assert.Equal(t, int64(0), countRows(t, storage, "metrics_gauge"))
+
+	// Sealing again is a no-op
+	sealed, err = storage.SealPartitions(ctx, today.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, sealed)
+
+	assert.Len(t, queryAllLogs(t, storage, today.Add(-72*time.Hour), today.Add(time.Hour)), 4)
+	assert.Len(t, queryAllLogs(t, storage, today.Add(-48*time.Hour), today.Add(-46*time.Hour)), 1)
+	assert.Len(t, queryAllLogs(t, storage, today.Add(-time.Hour), today.Add(time.Hour)), 1)
+
+	metrics, err := storage.QueryMetrics(ctx, types.MetricQuery{StartTime: today.Add(-72 * time.Hour), EndTime: today})
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "cpu", metrics[0].Name)
+}
+
+func TestApplyRetention_DropsAndRewritesPartitions(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	today := time.Now().UTC().Truncate(partitionDay)
+
+	writeTestLogsAt(t, storage, "api", "prod", today.Add(-100*time.Hour), today.Add(-70*time.Hour))
+	writeTestLogsAt(t, storage, "checkout", "prod", today.Add(-70*time.Hour))
+	sealed, err := storage.SealPartitions(ctx, today)
+	require.NoError(t, err)
+	require.Len(t, sealed, 2)
+
+	policy := types.RetentionPolicy{
+		RawLogs:   24 * time.Hour,
+		Overrides: []types.RetentionOverride{{Service: "checkout", RawLogs: 7 * 24 * time.Hour}},
+	}
+
+	report, err := storage.ApplyRetention(ctx, policy, true)
+	require.NoError(t, err)
+	logs := tableReport(report, "logs")
+	assert.Equal(t, int64(2), logs.ExpiredRows)
+	assert.Equal(t, 1, logs.DroppedPartitions)
+
+	_, err = storage.ApplyRetention(ctx, policy, false)
+	require.NoError(t, err)
+
+	// The older day is dropped whole, the newer one keeps only checkout
+	assert.NoFileExists(t, sealed[0].Path)
+	assert.NoFileExists(t, sealed[1].Path)
+	remaining := queryAllLogs(t, storage, today.Add(-7*24*time.Hour), today)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "checkout", remaining[0].ServiceName)
+
+	partitions, err := storage.listPartitions(ctx, storage.db, "logs", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, partitions, 1)
+	assert.Equal(t, int64(1), partitions[0].Rows)
+	assert.FileExists(t, partitions[0].Path)
+}
+
+func TestApplyRetention_DiskCapEvictsPartitionsFirst(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	today := time.Now().UTC().Truncate(partitionDay)
+
+	writeTestLogsAt(t, storage, "api", "prod",
+		today.Add(-70*time.Hour), today.Add(-46*time.Hour), today.Add(time.Minute))
+	sealed, err := storage.SealPartitions(ctx, today)
+	require.NoError(t, err)
+	require.Len(t, sealed, 2)
+
+	report, err := storage.ApplyRetention(ctx, types.RetentionPolicy{}, true)
+	require.NoError(t, err)
+
+	// Just over the cap: dropping the oldest partition is enough
+	policy := types.RetentionPolicy{MaxDiskBytes: report.DiskUsageBytes - 1}
+	report, err = storage.ApplyRetention(ctx, policy, false)
+	require.NoError(t, err)
+	require.NotNil(t, report.EvictionCutoff)
+	assert.True(t, sealed[0].Day.Add(partitionDay).Equal(*report.EvictionCutoff))
+	assert.Equal(t, int64(1), tableReport(report, "logs").EvictedRows)
+
+	_, err = os.Stat(sealed[0].Path)
+	assert.True(t, os.IsNotExist(err))
+	assert.FileExists(t, sealed[1].Path)
+	assert.Equal(t, int64(1), countRows(t, storage, "logs"))
+}
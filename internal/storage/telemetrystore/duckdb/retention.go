@@ -0,0 +1,391 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+// retentionTable describes how the retention policy applies to a table
+type retentionTable struct {
+	name       string
+	timeColumn string
+	// raw tables carry group and service columns and are evicted by the disk cap
+	raw       bool
+	retention func(types.RetentionPolicy) time.Duration
+	override  func(types.RetentionOverride) time.Duration
+}
+
+var retentionTables = []retentionTable{
+	{"metrics_sum", "timestamp", true, rawMetricsRetention, rawMetricsOverride},
+	{"metrics_gauge", "timestamp", true, rawMetricsRetention, rawMetricsOverride},
+	{"metrics_histogram", "timestamp", true, rawMetricsRetention, rawMetricsOverride},
+	{"metrics_exponential_histogram", "timestamp", true, rawMetricsRetention, rawMetricsOverride},
+	{"metrics_summary", "timestamp", true, rawMetricsRetention, rawMetricsOverride},
+	{"logs", "timestamp", true,
+		func(p types.RetentionPolicy) time.Duration { return p.RawLogs },
+		func(o types.RetentionOverride) time.Duration { return o.RawLogs }},
+	{"traces", "timestamp", true,
+		func(p types.RetentionPolicy) time.Duration { return p.RawTraces },
+		func(o types.RetentionOverride) time.Duration { return o.RawTraces }},
+	{"rollups_1m", "window_start", false, rollupRetention(types.RollupInterval1m), nil},
+	{"rollups_5m", "window_start", false, rollupRetention(types.RollupInterval5m), nil},
+	{"rollups_1h", "window_start", false, rollupRetention(types.RollupInterval1h), nil},
+	{"rollups_1d", "window_start", false, rollupRetention(types.RollupInterval1d), nil},
+	{"usage_stats", "bucket_start", false,
+		func(p types.RetentionPolicy) time.Duration { return p.Usage }, nil},
+}
+
+func rawMetricsRetention(p types.RetentionPolicy) time.Duration { return p.RawMetrics }
+
+func rawMetricsOverride(o types.RetentionOverride) time.Duration { return o.RawMetrics }
+
+func rollupRetention(interval types.RollupInterval) func(types.RetentionPolicy) time.Duration {
+	return func(p types.RetentionPolicy) time.Duration { return p.Rollups[interval] }
+}
+
+// expiry builds the predicate matching expired rows. Overrides become CASE
+// branches so each row is compared against the first cutoff that applies to
+// it. An empty predicate means nothing in the table expires.
+func (t retentionTable) expiry(policy types.RetentionPolicy, now time.Time) tableExpiry {
+	expiry := tableExpiry{table: t}
+	var branches []string
+	cutoffs := func(cutoff time.Time) {
+		if expiry.earliest.IsZero() || cutoff.Before(expiry.earliest) {
+			expiry.earliest = cutoff
+		}
+		if cutoff.After(expiry.latest) {
+			expiry.latest = cutoff
+		}
+	}
+
+	if t.override != nil {
+		for _, override := range policy.Overrides {
+			retention := t.override(override)
+			if retention <= 0 || (override.Group == "" && override.Service == "") {
+				continue
+			}
+			var conditions []string
+			if override.Group != "" {
+				conditions = append(conditions, "(group_id = ? OR group_name = ?)")
+				expiry.args = append(expiry.args, override.Group, override.Group)
+			}
+			if override.Service != "" {
+				conditions = append(conditions, "service_name = ?")
+				expiry.args = append(expiry.args, override.Service)
+			}
+			branches = append(branches, fmt.Sprintf("WHEN %s THEN ?", strings.Join(conditions, " AND ")))
+			expiry.args = append(expiry.args, now.Add(-retention))
+			cutoffs(now.Add(-retention))
+		}
+	}
+
+	fallback := "NULL"
+	if retention := t.retention(policy); retention > 0 {
+		cutoff := now.Add(-retention)
+		expiry.cutoff = &cutoff
+		fallback = "?"
+		expiry.args = append(expiry.args, cutoff)
+		cutoffs(cutoff)
+	}
+
+	switch {
+	case len(branches) > 0:
+		expiry.predicate = fmt.Sprintf("%s < CASE %s ELSE %s END", t.timeColumn, strings.Join(branches, " "), fallback)
+	case expiry.cutoff != nil:
+		expiry.predicate = fmt.Sprintf("%s < ?", t.timeColumn)
+	}
+	return expiry
+}
+
+// tableExpiry holds the evaluated expiry predicate of a table
+type tableExpiry struct {
+	table     retentionTable
+	predicate string
+	args      []interface{}
+	// cutoff is the default cutoff; earliest and latest bound the cutoffs
+	// of the default and the overrides
+	cutoff   *time.Time
+	earliest time.Time
+	latest   time.Time
+}
+
+// notExpired returns a predicate matching rows the policy keeps
+func (e tableExpiry) notExpired() string {
+	if e.predicate == "" {
+		return "true"
+	}
+	return fmt.Sprintf("NOT COALESCE(%s, false)", e.predicate)
+}
+
+// expiresWhole reports whether every row of a partition is expired. Without
+// a default cutoff, rows matching no override never expire.
+func (e tableExpiry) expiresWhole(partition types.Partition) bool {
+	return e.cutoff != nil && !partition.Day.Add(partitionDay).After(e.earliest)
+}
+
+// mayExpire reports whether some rows of a partition could be expired
+func (e tableExpiry) mayExpire(partition types.Partition) bool {
+	return e.predicate != "" && partition.Day.Before(e.latest)
+}
+
+// partitionPlan is the retention action planned for a sealed partition
+type partitionPlan struct {
+	table     int
+	partition types.Partition
+	expired   int64
+	evicted   bool
+	drop      bool
+}
+
+// remainingBytes estimates the size of a partition once expired rows are removed
+func (p partitionPlan) remainingBytes() float64 {
+	if p.drop || p.partition.Rows == 0 {
+		return 0
+	}
+	return float64(p.partition.Bytes) * float64(p.partition.Rows-p.expired) / float64(p.partition.Rows)
+}
+
+// ApplyRetention deletes rows older than their table's retention, then evicts
+// the oldest raw data while the store is estimated to exceed the disk cap.
+// Sealed partitions are dropped whole once all of their rows expire, and are
+// evicted before hot rows since they hold the oldest data. A dry run only
+// counts the rows that would be deleted.
+func (s *Storage) ApplyRetention(ctx context.Context, policy types.RetentionPolicy, dryRun bool) (*types.RetentionReport, error) {
+	now := time.Now()
+	report := &types.RetentionReport{DryRun: dryRun, MaxDiskBytes: policy.MaxDiskBytes}
+
+	usage, err := s.diskUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.DiskUsageBytes = usage
+
+	expiries := make([]tableExpiry, len(retentionTables))
+	var plans []partitionPlan
+	var rawRows, expiredRawRows int64
+	for i, table := range retentionTables {
+		expiry := table.expiry(policy, now)
+		expiries[i] = expiry
+
+		filter := "false"
+		if expiry.predicate != "" {
+			filter = expiry.predicate
+		}
+		query := fmt.Sprintf("SELECT COUNT(*), COUNT(*) FILTER (WHERE %s) FROM %s", filter, table.name)
+		var total, expired int64
+		if err := s.db.QueryRowContext(ctx, query, expiry.args...).Scan(&total, &expired); err != nil {
+			return nil, fmt.Errorf("failed to count expired rows in %s: %w", table.name, err)
+		}
+
+		entry := types.TableRetention{Table: table.name, Cutoff: expiry.cutoff, ExpiredRows: expired}
+		if table.raw {
+			rawRows += total
+			expiredRawRows += expired
+
+			partitions, err := s.listPartitions(ctx, s.db, table.name, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			for _, partition := range partitions {
+				plan := partitionPlan{table: i, partition: partition}
+				switch {
+				case expiry.expiresWhole(partition):
+					plan.expired = partition.Rows
+					plan.drop = true
+					entry.DroppedPartitions++
+				case expiry.mayExpire(partition):
+					query := fmt.Sprintf("SELECT COUNT(*) FROM read_parquet(%s) WHERE %s", stringLiteral(partition.Path), expiry.predicate)
+					if err := s.db.QueryRowContext(ctx, query, expiry.args...).Scan(&plan.expired); err != nil {
+						return nil, fmt.Errorf("failed to count expired rows in %s: %w", partition.Path, err)
+					}
+					if plan.expired == partition.Rows {
+						plan.drop = true
+						entry.DroppedPartitions++
+					}
+				}
+				entry.ExpiredRows += plan.expired
+				report.DiskUsageBytes += partition.Bytes
+				plans = append(plans, plan)
+			}
+		}
+		report.Tables = append(report.Tables, entry)
+	}
+
+	// Database usage is attributed to hot raw rows evenly, which is close
+	// enough since rollups and usage stats are small by comparison
+	var evictionCutoff, hotCutoff *time.Time
+	if policy.MaxDiskBytes > 0 && report.DiskUsageBytes > policy.MaxDiskBytes {
+		var bytesPerRow float64
+		projected := float64(usage)
+		if rawRows > 0 {
+			bytesPerRow = float64(usage) / float64(rawRows)
+			projected -= float64(expiredRawRows) * bytesPerRow
+		}
+		for _, plan := range plans {
+			projected += plan.remainingBytes()
+		}
+
+		// Partitions are listed oldest first within a table; evict across
+		// tables in day order
+		order := make([]int, len(plans))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return plans[order[a]].partition.Day.Before(plans[order[b]].partition.Day)
+		})
+		for _, i := range order {
+			if projected <= float64(policy.MaxDiskBytes) {
+				break
+			}
+			plan := &plans[i]
+			if plan.drop {
+				continue
+			}
+			projected -= plan.remainingBytes()
+			plan.drop = true
+			plan.evicted = true
+			report.Tables[plan.table].EvictedRows += plan.partition.Rows - plan.expired
+			report.Tables[plan.table].DroppedPartitions++
+			dayEnd := plan.partition.Day.Add(partitionDay)
+			evictionCutoff = &dayEnd
+		}
+
+		excess := projected - float64(policy.MaxDiskBytes)
+		if excess > 0 && rawRows > expiredRawRows {
+			evict := int64(math.Ceil(excess / bytesPerRow))
+			if remaining := rawRows - expiredRawRows; evict > remaining {
+				evict = remaining
+			}
+			hotCutoff, err = s.evictionCutoff(ctx, expiries, evict)
+			if err != nil {
+				return nil, err
+			}
+
+			for i, expiry := range expiries {
+				if !expiry.table.raw {
+					continue
+				}
+				query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s <= ? AND %s",
+					expiry.table.name, expiry.table.timeColumn, expiry.notExpired())
+				args := append([]interface{}{*hotCutoff}, expiry.args...)
+				var evicted int64
+				if err := s.db.QueryRowContext(ctx, query, args...).Scan(&evicted); err != nil {
+					return nil, fmt.Errorf("failed to count evicted rows in %s: %w", expiry.table.name, err)
+				}
+				report.Tables[i].EvictedRows += evicted
+			}
+			evictionCutoff = hotCutoff
+		}
+		report.EvictionCutoff = evictionCutoff
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	var deleted int64
+	for i, expiry := range expiries {
+		if expiry.predicate == "" {
+			continue
+		}
+		query := fmt.Sprintf("DELETE FROM %s WHERE %s", expiry.table.name, expiry.predicate)
+		result, err := s.execWrite(ctx, query, expiry.args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to cleanup %s: %w", expiry.table.name, err)
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 {
+			deleted += rows
+			s.logger.Info("Cleaned up old data", zap.String("table", report.Tables[i].Table), zap.Int64("rows", rows))
+		}
+	}
+
+	for _, plan := range plans {
+		switch {
+		case plan.drop:
+			if err := s.dropPartition(ctx, plan.partition); err != nil {
+				return nil, err
+			}
+			s.logger.Info("Dropped partition",
+				zap.String("table", plan.partition.Table),
+				zap.String("day", plan.partition.Day.Format(time.DateOnly)),
+				zap.Bool("evicted", plan.evicted))
+		case plan.expired > 0:
+			expiry := expiries[plan.table]
+			if err := s.rewritePartition(ctx, plan.partition, expiry.notExpired(), expiry.args); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if hotCutoff != nil {
+		for _, expiry := range expiries {
+			if !expiry.table.raw {
+				continue
+			}
+			query := fmt.Sprintf("DELETE FROM %s WHERE %s <= ?", expiry.table.name, expiry.table.timeColumn)
+			result, err := s.execWrite(ctx, query, *hotCutoff)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evict from %s: %w", expiry.table.name, err)
+			}
+			if rows, _ := result.RowsAffected(); rows > 0 {
+				deleted += rows
+				s.logger.Info("Evicted data over disk cap", zap.String("table", expiry.table.name), zap.Int64("rows", rows))
+			}
+		}
+	}
+
+	// Deleted blocks are only reclaimed once they are checkpointed
+	if deleted > 0 {
+		if _, err := s.execWrite(ctx, "CHECKPOINT"); err != nil {
+			return nil, fmt.Errorf("failed to checkpoint after cleanup: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+// evictionCutoff returns the timestamp of the n-th oldest raw row the policy
+// keeps, so deleting rows at or before it evicts at least n rows
+func (s *Storage) evictionCutoff(ctx context.Context, expiries []tableExpiry, n int64) (*time.Time, error) {
+	var selects []string
+	var args []interface{}
+	for _, expiry := range expiries {
+		if !expiry.table.raw {
+			continue
+		}
+		selects = append(selects, fmt.Sprintf("SELECT %s AS ts FROM %s WHERE %s",
+			expiry.table.timeColumn, expiry.table.name, expiry.notExpired()))
+		args = append(args, expiry.args...)
+	}
+
+	query := fmt.Sprintf("SELECT ts FROM (%s) ORDER BY ts LIMIT 1 OFFSET ?", strings.Join(selects, " UNION ALL "))
+	args = append(args, n-1)
+
+	var cutoff time.Time
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&cutoff); err != nil {
+		return nil, fmt.Errorf("failed to find eviction cutoff: %w", err)
+	}
+	return &cutoff, nil
+}
+
+// diskUsage returns the bytes used by the database's allocated blocks
+func (s *Storage) diskUsage(ctx context.Context) (int64, error) {
+	var usage int64
+	query := `SELECT used_blocks * block_size FROM pragma_database_size() WHERE database_name = current_database()`
+	if err := s.db.QueryRowContext(ctx, query).Scan(&usage); err != nil {
+		return 0, fmt.Errorf("failed to get database size: %w", err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+func writeTestLogs(t *testing.T, storage *Storage, service, group string, ages ...time.Duration) {
+	now := time.Now()
+	var timestamps []time.Time
+	for _, age := range ages {
+		timestamps = append(timestamps, now.Add(-age))
+	}
+	writeTestLogsAt(t, storage, service, group, timestamps...)
+}
+
+func writeTestLogsAt(t *testing.T, storage *Storage, service, group string, timestamps ...time.Time) {
+	agentID := uuid.New().String()
+	var logs []otlp.LogData
+	for _, ts := range timestamps {
+		logs = append(logs, otlp.LogData{
+			Timestamp: ts, AgentID: agentID, GroupID: group, GroupName: group,
+			ServiceName: service, Body: "message",
+		})
+	}
+	require.NoError(t, storage.WriteLogsFromOTLP(context.Background(), logs))
+}
+
+func countRows(t *testing.T, storage *Storage, table string) int64 {
+	var count int64
+	require.NoError(t, storage.db.QueryRow("SELECT COUNT(*) FROM "+table).Scan(&count))
+	return count
+}
+
+func tableReport(report *types.RetentionReport, table string) types.TableRetention {
+	for _, entry := range report.Tables {
+		if entry.Table == table {
+			return entry
+		}
+	}
+	return types.TableRetention{}
+}
+
+func TestApplyRetention_OverridesAndDryRun(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+
+	writeTestLogs(t, storage, "api", "prod", time.Hour, 30*time.Hour, 100*time.Hour)
+	writeTestLogs(t, storage, "checkout", "prod", time.Hour, 30*time.Hour, 100*time.Hour)
+	writeTestLogs(t, storage, "batch", "dev", time.Hour, 30*time.Hour, 100*time.Hour)
+
+	policy := types.RetentionPolicy{
+		RawLogs: 24 * time.Hour,
+		Overrides: []types.RetentionOverride{
+			// First match wins, so checkout keeps a week despite the prod override
+			{Service: "checkout", RawLogs: 7 * 24 * time.Hour},
+			{Group: "prod", RawLogs: 48 * time.Hour},
+			// Overrides without a logs retention inherit the default
+			{Group: "dev", RawMetrics: time.Hour},
+		},
+	}
+
+	report, err := storage.ApplyRetention(ctx, policy, true)
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	logs := tableReport(report, "logs")
+	// api drops 100h, checkout drops nothing, batch drops 30h and 100h
+	assert.Equal(t, int64(3), logs.ExpiredRows)
+	require.NotNil(t, logs.Cutoff)
+	assert.Nil(t, tableReport(report, "rollups_1m").Cutoff)
+	assert.Equal(t, int64(9), countRows(t, storage, "logs"))
+
+	report, err = storage.ApplyRetention(ctx, policy, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), tableReport(report, "logs").ExpiredRows)
+	assert.Equal(t, int64(6), countRows(t, storage, "logs"))
+}
+
+func TestApplyRetention_RollupTiers(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	for _, age := range []time.Duration{time.Hour, 48 * time.Hour} {
+		for _, table := range []string{"rollups_1m", "rollups_1h"} {
+			_, err := storage.db.Exec("INSERT INTO "+table+` (window_start, agent_id, group_id, metric_name, count, sum, avg, min, max)
+				VALUES (?, ?, '', 'cpu', 1, 1, 1, 1, 1)`, now.Add(-age), uuid.New().String())
+			require.NoError(t, err)
+		}
+	}
+
+	_, err := storage.ApplyRetention(ctx, types.RetentionPolicy{
+		Rollups: map[types.RollupInterval]time.Duration{types.RollupInterval1m: 24 * time.Hour},
+	}, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), countRows(t, storage, "rollups_1m"))
+	// Tiers without a retention are kept indefinitely
+	assert.Equal(t, int64(2), countRows(t, storage, "rollups_1h"))
+}
+
+func TestApplyRetention_DiskCapEvictsOldestFirst(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+
+	var ages []time.Duration
+	for i := 1; i <= 100; i++ {
+		ages = append(ages, time.Duration(i)*time.Minute)
+	}
+	writeTestLogs(t, storage, "api", "prod", ages...)
+	_, err := storage.db.Exec("CHECKPOINT")
+	require.NoError(t, err)
+
+	usage, err := storage.diskUsage(ctx)
+	require.NoError(t, err)
+	require.Positive(t, usage)
+
+	// Cap at roughly half the current size
+	policy := types.RetentionPolicy{MaxDiskBytes: usage / 2}
+	report, err := storage.ApplyRetention(ctx, policy, true)
+	require.NoError(t, err)
+	require.NotNil(t, report.EvictionCutoff)
+	evicted := tableReport(report, "logs").EvictedRows
+	assert.InDelta(t, 50, evicted, 1)
+	assert.Equal(t, int64(100), countRows(t, storage, "logs"))
+
+	_, err = storage.ApplyRetention(ctx, policy, false)
+	require.NoError(t, err)
+	assert.Equal(t, 100-evicted, countRows(t, storage, "logs"))
+
+	// The newest rows are the ones that remain
+	var oldest time.Time
+	require.NoError(t, storage.db.QueryRow("SELECT MIN(timestamp) FROM logs").Scan(&oldest))
+	assert.True(t, oldest.After(*report.EvictionCutoff))
+}
@@ -0,0 +1,700 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	goduckdb "github.com/marcboeker/go-duckdb"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/histogram"
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+// rollupTier describes a rollup table and the data it is aggregated from
+type rollupTier struct {
+	table  string
+	window time.Duration
+	// source is the finer rollup table this tier is built from. Empty means
+	// the tier is built from raw metrics.
+	source string
+}
+
+var rollupTiers = map[types.RollupInterval]rollupTier{
+	types.RollupInterval1m: {table: "rollups_1m", window: time.Minute},
+	types.RollupInterval5m: {table: "rollups_5m", window: 5 * time.Minute, source: "rollups_1m"},
+	types.RollupInterval1h: {table: "rollups_1h", window: time.Hour, source: "rollups_5m"},
+	types.RollupInterval1d: {table: "rollups_1d", window: 24 * time.Hour, source: "rollups_1h"},
+}
+
+// CreateRollups aggregates one window of an interval. The 1m tier is built
+// from raw sums, gauges, histograms and summaries; coarser tiers are built
+// from the next finer tier. The window is cleared before it is generated, so
+// generating a window again is idempotent.
+func (s *Storage) CreateRollups(ctx context.Context, window time.Time, interval types.RollupInterval) error {
+	tier, ok := rollupTiers[interval]
+	if !ok {
+		return fmt.Errorf("invalid rollup interval: %s", interval)
+	}
+
+	windowStart := window.UTC().Truncate(tier.window)
+	windowEnd := windowStart.Add(tier.window)
+
+	// Histogram buckets are merged in Go, so read them before opening the
+	// write transaction
+	histograms, err := s.loadHistogramRollupSources(ctx, tier, windowStart, windowEnd)
+	if err != nil {
+		return err
+	}
+	exponentials, err := s.loadExponentialRollupSources(ctx, tier, windowStart, windowEnd)
+	if err != nil {
+		return err
+	}
+	summaries, err := s.loadSummaryRollupSources(ctx, tier, windowStart, windowEnd)
+	if err != nil {
+		return err
+	}
+
+	// DuckDB cannot update list columns nor re-insert a key deleted in the
+	// same transaction, so the window is cleared separately. A failure after
+	// this point leaves the window empty until the scheduler retries it.
+	if _, err := s.execWrite(ctx, fmt.Sprintf(`DELETE FROM %s WHERE window_start = ?`, tier.table), windowStart); err != nil {
+		return fmt.Errorf("failed to clear rollup window: %w", err)
+	}
+
+	tx, err := s.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var scalarQuery string
+	var args []interface{}
+	if tier.source == "" {
+		scalarQuery = fmt.Sprintf(`
+			INSERT INTO %s (window_start, agent_id, group_id, metric_name, metric_type, count, sum, avg, min, max)
+			SELECT
+				?,
+				agent_id,
+				COALESCE(group_id, ''),
+				metric_name,
+				MIN(metric_type),
+				COUNT(*),
+				SUM(value),
+				AVG(value),
+				MIN(value),
+				MAX(value)
+			FROM (
+				SELECT agent_id, group_id, metric_name, 'sum' AS metric_type, value
+				FROM metrics_sum WHERE timestamp >= ? AND timestamp < ?
+				UNION ALL
+				SELECT agent_id, group_id, metric_name, 'gauge' AS metric_type, value
+				FROM metrics_gauge WHERE timestamp >= ? AND timestamp < ?
+			)
+			GROUP BY agent_id, COALESCE(group_id, ''), metric_name
+		`, tier.table)
+		args = []interface{}{windowStart, windowStart, windowEnd, windowStart, windowEnd}
+	} else {
+		scalarQuery = fmt.Sprintf(`
+			INSERT INTO %s (window_start, agent_id, group_id, metric_name, metric_type, count, sum, avg, min, max)
+			SELECT
+				?,
+				agent_id,
+				group_id,
+				metric_name,
+				MIN(metric_type),
+				SUM(count),
+				SUM(sum),
+				CASE WHEN SUM(count) > 0 THEN SUM(sum) / SUM(count) ELSE 0 END,
+				MIN(min),
+				MAX(max)
+			FROM %s
+			WHERE window_start >= ? AND window_start < ? AND bucket_counts IS NULL
+				AND COALESCE(metric_type, '') NOT IN ('exponential_histogram', 'summary')
+			GROUP BY agent_id, group_id, metric_name
+		`, tier.table, tier.source)
+		args = []interface{}{windowStart, windowStart, windowEnd}
+	}
+
+	if _, err := tx.ExecContext(ctx, scalarQuery, args...); err != nil {
+		return fmt.Errorf("failed to create rollups: %w", err)
+	}
+
+	// A histogram sharing its name with a sum or gauge of the same series
+	// keeps the scalar row
+	if len(histograms) > 0 {
+		stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`
+			INSERT INTO %s (
+				window_start, agent_id, group_id, metric_name, metric_type,
+				count, sum, avg, min, max, bucket_counts, explicit_bounds
+			) VALUES (?, ?, ?, ?, 'histogram', ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING
+		`, tier.table))
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, h := range histograms {
+			avg := 0.0
+			if h.count > 0 {
+				avg = h.sum / float64(h.count)
+			}
+			_, err := stmt.ExecContext(ctx,
+				windowStart, h.agentID, h.groupID, h.metricName,
+				h.count, h.sum, avg, h.min, h.max,
+				listLiteral(h.bucketCounts), listLiteral(h.explicitBounds),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert histogram rollup: %w", err)
+			}
+		}
+	}
+
+	if len(exponentials) > 0 {
+		stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`
+			INSERT INTO %s (
+				window_start, agent_id, group_id, metric_name, metric_type,
+				count, sum, avg, min, max, scale, zero_count, zero_threshold,
+				positive_offset, positive_bucket_counts, negative_offset, negative_bucket_counts
+			) VALUES (?, ?, ?, ?, 'exponential_histogram', ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING
+		`, tier.table))
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, h := range exponentials {
+			avg := 0.0
+			if h.count > 0 {
+				avg = h.sum / float64(h.count)
+			}
+			_, err := stmt.ExecContext(ctx,
+				windowStart, h.agentID, h.groupID, h.metricName,
+				h.count, h.sum, avg, h.min, h.max,
+				h.histogram.Scale, h.histogram.ZeroCount, h.histogram.ZeroThreshold,
+				h.histogram.Positive.Offset, listLiteral(h.histogram.Positive.Counts),
+				h.histogram.Negative.Offset, listLiteral(h.histogram.Negative.Counts),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert exponential histogram rollup: %w", err)
+			}
+		}
+	}
+
+	if len(summaries) > 0 {
+		stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`
+			INSERT INTO %s (
+				window_start, agent_id, group_id, metric_name, metric_type,
+				count, sum, avg, min, max, quantiles, quantile_values
+			) VALUES (?, ?, ?, ?, 'summary', ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING
+		`, tier.table))
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, sm := range summaries {
+			avg := 0.0
+			if sm.count > 0 {
+				avg = sm.sum / float64(sm.count)
+			}
+			quantiles, values := sm.quantileValues()
+			_, err := stmt.ExecContext(ctx,
+				windowStart, sm.agentID, sm.groupID, sm.metricName,
+				sm.count, sm.sum, avg, sm.min, sm.max,
+				listLiteral(quantiles), listLiteral(values),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert summary rollup: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.Debug("Created rollups",
+		zap.String("interval", string(interval)),
+		zap.Time("window", windowStart),
+		zap.Int("histograms", len(histograms)),
+		zap.Int("exponential_histograms", len(exponentials)),
+		zap.Int("summaries", len(summaries)))
+	return nil
+}
+
+// histogramRollup accumulates the histogram points of one series in a window
+type histogramRollup struct {
+	agentID        string
+	groupID        string
+	metricName     string
+	count          int64
+	sum            float64
+	min            float64
+	max            float64
+	hasMinMax      bool
+	bucketCounts   []int64
+	explicitBounds []float64
+}
+
+// loadHistogramRollupSources reads the histogram points of a window and
+// merges them per series. Bucket counts are summed element-wise. If the
+// bucket layout of a series changes within the window, points with the
+// previous layout are discarded so counts and buckets stay consistent.
+func (s *Storage) loadHistogramRollupSources(ctx context.Context, tier rollupTier, windowStart, windowEnd time.Time) ([]*histogramRollup, error) {
+	var query string
+	if tier.source == "" {
+		query = `
+			SELECT agent_id, COALESCE(group_id, ''), metric_name, count, sum, min, max, bucket_counts, explicit_bounds
+			FROM metrics_histogram
+			WHERE timestamp >= ? AND timestamp < ?
+			ORDER BY timestamp
+		`
+	} else {
+		query = fmt.Sprintf(`
+			SELECT agent_id, group_id, metric_name, count, sum, min, max, bucket_counts, explicit_bounds
+			FROM %s
+			WHERE window_start >= ? AND window_start < ? AND bucket_counts IS NOT NULL
+			ORDER BY window_start
+		`, tier.source)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, windowStart, windowEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query histograms for rollup: %w", err)
+	}
+	defer rows.Close()
+
+	series := make(map[string]*histogramRollup)
+	var ordered []*histogramRollup
+	for rows.Next() {
+		var agentID, groupID, metricName string
+		var count int64
+		var sum float64
+		var min, max sql.NullFloat64
+		var bucketCounts goduckdb.Composite[[]int64]
+		var explicitBounds goduckdb.Composite[[]float64]
+
+		if err := rows.Scan(&agentID, &groupID, &metricName, &count, &sum, &min, &max, &bucketCounts, &explicitBounds); err != nil {
+			return nil, fmt.Errorf("failed to scan histogram: %w", err)
+		}
+
+		key := agentID + "\x00" + groupID + "\x00" + metricName
+		h, ok := series[key]
+		if !ok {
+			h = &histogramRollup{agentID: agentID, groupID: groupID, metricName: metricName}
+			series[key] = h
+			ordered = append(ordered, h)
+		}
+		h.merge(count, sum, min, max, bucketCounts.Get(), explicitBounds.Get())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read histograms: %w", err)
+	}
+	return ordered, nil
+}
+
+// merge adds one histogram point to the accumulator
+func (h *histogramRollup) merge(count int64, sum float64, min, max sql.NullFloat64, bucketCounts []int64, explicitBounds []float64) {
+	if h.bucketCounts != nil && !sameBuckets(h.explicitBounds, explicitBounds, len(h.bucketCounts), len(bucketCounts)) {
+		*h = histogramRollup{agentID: h.agentID, groupID: h.groupID, metricName: h.metricName}
+	}
+
+	if h.bucketCounts == nil {
+		h.bucketCounts = make([]int64, len(bucketCounts))
+		h.explicitBounds = explicitBounds
+	}
+	for i, c := range bucketCounts {
+		h.bucketCounts[i] += c
+	}
+
+	h.count += count
+	h.sum += sum
+	if min.Valid && (!h.hasMinMax || min.Float64 < h.min) {
+		h.min = min.Float64
+	}
+	if max.Valid && (!h.hasMinMax || max.Float64 > h.max) {
+		h.max = max.Float64
+	}
+	if min.Valid || max.Valid {
+		h.hasMinMax = true
+	}
+}
+
+// exponentialRollup accumulates the exponential histogram points of one
+// series in a window
+type exponentialRollup struct {
+	agentID    string
+	groupID    string
+	metricName string
+	count      int64
+	sum        float64
+	min        float64
+	max        float64
+	hasMinMax  bool
+	histogram  histogram.Exponential
+}
+
+// loadExponentialRollupSources reads the exponential histogram points of a
+// window and merges them per series. Points with different scales are merged
+// at the lowest scale among them.
+func (s *Storage) loadExponentialRollupSources(ctx context.Context, tier rollupTier, windowStart, windowEnd time.Time) ([]*exponentialRollup, error) {
+	const columns = `count, sum, min, max, scale, zero_count, zero_threshold,
+		positive_offset, positive_bucket_counts, negative_offset, negative_bucket_counts`
+	var query string
+	if tier.source == "" {
+		query = `
+			SELECT agent_id, COALESCE(group_id, ''), metric_name, ` + columns + `
+			FROM metrics_exponential_histogram
+			WHERE timestamp >= ? AND timestamp < ?
+			ORDER BY timestamp
+		`
+	} else {
+		query = fmt.Sprintf(`
+			SELECT agent_id, group_id, metric_name, `+columns+`
+			FROM %s
+			WHERE window_start >= ? AND window_start < ? AND metric_type = 'exponential_histogram'
+			ORDER BY window_start
+		`, tier.source)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, windowStart, windowEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exponential histograms for rollup: %w", err)
+	}
+	defer rows.Close()
+
+	series := make(map[string]*exponentialRollup)
+	var ordered []*exponentialRollup
+	for rows.Next() {
+		var agentID, groupID, metricName string
+		var count int64
+		var sum float64
+		var min, max sql.NullFloat64
+		var point histogram.Exponential
+		var positiveCounts, negativeCounts goduckdb.Composite[[]int64]
+
+		err := rows.Scan(&agentID, &groupID, &metricName, &count, &sum, &min, &max,
+			&point.Scale, &point.ZeroCount, &point.ZeroThreshold,
+			&point.Positive.Offset, &positiveCounts, &point.Negative.Offset, &negativeCounts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan exponential histogram: %w", err)
+		}
+		point.Positive.Counts = positiveCounts.Get()
+		point.Negative.Counts = negativeCounts.Get()
+
+		key := agentID + "\x00" + groupID + "\x00" + metricName
+		h, ok := series[key]
+		if !ok {
+			h = &exponentialRollup{agentID: agentID, groupID: groupID, metricName: metricName}
+			series[key] = h
+			ordered = append(ordered, h)
+		}
+		h.histogram.Merge(point)
+		h.count += count
+		h.sum += sum
+		if min.Valid && (!h.hasMinMax || min.Float64 < h.min) {
+			h.min = min.Float64
+		}
+		if max.Valid && (!h.hasMinMax || max.Float64 > h.max) {
+			h.max = max.Float64
+		}
+		if min.Valid || max.Valid {
+			h.hasMinMax = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read exponential histograms: %w", err)
+	}
+	return ordered, nil
+}
+
+// summaryRollup accumulates the summary points of one series in a window.
+// Quantiles cannot be merged exactly, so each is rolled up as the mean of its
+// values weighted by the count of the point reporting it.
+type summaryRollup struct {
+	agentID    string
+	groupID    string
+	metricName string
+	count      int64
+	sum        float64
+	min        float64
+	max        float64
+	hasMinMax  bool
+	// weighted and weights hold the weighted value sum and total weight of
+	// each quantile
+	weighted map[float64]float64
+	weights  map[float64]float64
+}
+
+// loadSummaryRollupSources reads the summary points of a window and merges
+// them per series. Raw summaries have no min and max, so the 1m tier takes the
+// lowest and highest quantile values reported.
+func (s *Storage) loadSummaryRollupSources(ctx context.Context, tier rollupTier, windowStart, windowEnd time.Time) ([]*summaryRollup, error) {
+	var query string
+	if tier.source == "" {
+		query = `
+			SELECT agent_id, COALESCE(group_id, ''), metric_name, count, sum,
+				NULL AS min, NULL AS max, quantiles, quantile_values
+			FROM metrics_summary
+			WHERE timestamp >= ? AND timestamp < ?
+			ORDER BY timestamp
+		`
+	} else {
+		query = fmt.Sprintf(`
+			SELECT agent_id, group_id, metric_name, count, sum, min, max, quantiles, quantile_values
+			FROM %s
+			WHERE window_start >= ? AND window_start < ? AND metric_type = 'summary'
+			ORDER BY window_start
+		`, tier.source)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, windowStart, windowEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query summaries for rollup: %w", err)
+	}
+	defer rows.Close()
+
+	series := make(map[string]*summaryRollup)
+	var ordered []*summaryRollup
+	for rows.Next() {
+		var agentID, groupID, metricName string
+		var count int64
+		var sum float64
+		var min, max sql.NullFloat64
+		var quantiles, values goduckdb.Composite[[]float64]
+
+		if err := rows.Scan(&agentID, &groupID, &metricName, &count, &sum, &min, &max, &quantiles, &values); err != nil {
+			return nil, fmt.Errorf("failed to scan summary: %w", err)
+		}
+
+		key := agentID + "\x00" + groupID + "\x00" + metricName
+		sm, ok := series[key]
+		if !ok {
+			sm = &summaryRollup{
+				agentID: agentID, groupID: groupID, metricName: metricName,
+				weighted: make(map[float64]float64), weights: make(map[float64]float64),
+			}
+			series[key] = sm
+			ordered = append(ordered, sm)
+		}
+		sm.merge(count, sum, min, max, quantileList(quantiles.Get(), values.Get()))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read summaries: %w", err)
+	}
+	return ordered, nil
+}
+
+// merge adds one summary point to the accumulator. A point without
+// observations still counts once, so its quantiles are not lost.
+func (sm *summaryRollup) merge(count int64, sum float64, min, max sql.NullFloat64, quantiles []histogram.QuantileValue) {
+	sm.count += count
+	sm.sum += sum
+
+	weight := float64(count)
+	if weight <= 0 {
+		weight = 1
+	}
+	for _, q := range quantiles {
+		sm.weighted[q.Quantile] += q.Value * weight
+		sm.weights[q.Quantile] += weight
+		if !min.Valid {
+			sm.observe(q.Value, q.Value)
+		}
+	}
+	if min.Valid && max.Valid {
+		sm.observe(min.Float64, max.Float64)
+	}
+}
+
+// observe widens the min and max of the accumulator
+func (sm *summaryRollup) observe(min, max float64) {
+	if !sm.hasMinMax || min < sm.min {
+		sm.min = min
+	}
+	if !sm.hasMinMax || max > sm.max {
+		sm.max = max
+	}
+	sm.hasMinMax = true
+}
+
+// quantileValues returns the rolled up quantiles in ascending order with
+// their values
+func (sm *summaryRollup) quantileValues() ([]float64, []float64) {
+	quantiles := make([]float64, 0, len(sm.weights))
+	for q := range sm.weights {
+		quantiles = append(quantiles, q)
+	}
+	sort.Float64s(quantiles)
+
+	values := make([]float64, len(quantiles))
+	for i, q := range quantiles {
+		values[i] = sm.weighted[q] / sm.weights[q]
+	}
+	return quantiles, values
+}
+
+// sameBuckets reports whether two histogram points share a bucket layout
+func sameBuckets(a, b []float64, countsA, countsB int) bool {
+	if len(a) != len(b) || countsA != countsB {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// listLiteral formats a slice as a DuckDB list literal
+func listLiteral[T int64 | float64](values []T) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		switch n := any(v).(type) {
+		case int64:
+			parts[i] = strconv.FormatInt(n, 10)
+		case float64:
+			parts[i] = strconv.FormatFloat(n, 'g', -1, 64)
+		}
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// QueryRollups queries rollup data
+func (s *Storage) QueryRollups(ctx context.Context, query types.RollupQuery) ([]types.Rollup, error) {
+	tier, ok := rollupTiers[query.Interval]
+	if !ok {
+		return nil, fmt.Errorf("invalid rollup interval: %s", query.Interval)
+	}
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT window_start, agent_id, group_id, metric_name, count, sum, avg, min, max,
+			metric_type, bucket_counts, explicit_bounds, scale, zero_count, zero_threshold,
+			positive_offset, positive_bucket_counts, negative_offset, negative_bucket_counts,
+			quantiles, quantile_values
+		FROM %s
+		WHERE window_start >= ? AND window_start <= ?
+	`, tier.table)
+	args := []interface{}{query.StartTime, query.EndTime}
+
+	if query.AgentID != nil {
+		sqlQuery += ` AND agent_id = ?`
+		args = append(args, query.AgentID.String())
+	}
+
+	if query.GroupID != nil {
+		sqlQuery += ` AND group_id = ?`
+		args = append(args, *query.GroupID)
+	}
+
+	if query.MetricName != nil {
+		sqlQuery += ` AND metric_name = ?`
+		args = append(args, *query.MetricName)
+	}
+
+	sqlQuery += ` ORDER BY window_start DESC`
+
+	ctx, cancel := s.withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, s.queryError(ctx, fmt.Errorf("failed to query rollups: %w", err))
+	}
+	defer rows.Close()
+
+	var rollups []types.Rollup
+	for rows.Next() {
+		var r types.Rollup
+		var agentIDStr, groupIDStr, metricType sql.NullString
+		var bucketCounts, positiveCounts, negativeCounts goduckdb.Composite[[]int64]
+		var explicitBounds, quantiles, quantileValues goduckdb.Composite[[]float64]
+		var scale, positiveOffset, negativeOffset sql.NullInt32
+		var zeroCount sql.NullInt64
+		var zeroThreshold sql.NullFloat64
+
+		err := rows.Scan(
+			&r.WindowStart, &agentIDStr, &groupIDStr, &r.MetricName,
+			&r.Count, &r.Sum, &r.Avg, &r.Min, &r.Max,
+			&metricType, &bucketCounts, &explicitBounds, &scale, &zeroCount, &zeroThreshold,
+			&positiveOffset, &positiveCounts, &negativeOffset, &negativeCounts,
+			&quantiles, &quantileValues,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rollup: %w", err)
+		}
+
+		if agentIDStr.Valid {
+			agentID, _ := uuid.Parse(agentIDStr.String)
+			r.AgentID = &agentID
+		}
+		// Rollups store a missing group as an empty string because group_id
+		// is part of the primary key
+		if groupIDStr.Valid && groupIDStr.String != "" {
+			r.GroupID = &groupIDStr.String
+		}
+		r.MetricType = metricType.String
+		r.BucketCounts = bucketCounts.Get()
+		r.ExplicitBounds = explicitBounds.Get()
+		if r.MetricType == string(types.MetricTypeExponentialHistogram) {
+			r.Exponential = &histogram.Exponential{
+				Scale:         scale.Int32,
+				ZeroCount:     zeroCount.Int64,
+				ZeroThreshold: zeroThreshold.Float64,
+				Positive:      histogram.Buckets{Offset: positiveOffset.Int32, Counts: positiveCounts.Get()},
+				Negative:      histogram.Buckets{Offset: negativeOffset.Int32, Counts: negativeCounts.Get()},
+			}
+		}
+		r.Quantiles = quantileList(quantiles.Get(), quantileValues.Get())
+		r.Interval = query.Interval
+
+		rollups = append(rollups, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.queryError(ctx, fmt.Errorf("error iterating rollups: %w", err))
+	}
+
+	return rollups, nil
+}
+
+// GetRollupWatermark returns the time up to which an interval has been
+// rolled up, or the zero time if it never has
+func (s *Storage) GetRollupWatermark(ctx context.Context, interval types.RollupInterval) (time.Time, error) {
+	var completedUntil time.Time
+	err := s.db.QueryRowContext(ctx,
+		`SELECT completed_until FROM rollup_state WHERE tier = ?`, string(interval),
+	).Scan(&completedUntil)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to query rollup state: %w", err)
+	}
+	return completedUntil, nil
+}
+
+// SetRollupWatermark records the time up to which an interval has been rolled up
+func (s *Storage) SetRollupWatermark(ctx context.Context, interval types.RollupInterval, completedUntil time.Time) error {
+	_, err := s.execWrite(ctx, `
+		INSERT INTO rollup_state (tier, completed_until) VALUES (?, ?)
+		ON CONFLICT (tier) DO UPDATE SET completed_until = EXCLUDED.completed_until
+	`, string(interval), completedUntil.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to update rollup state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/histogram"
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	storage, err := NewStorage(filepath.Join(t.TempDir(), "telemetry.db"), zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = storage.Close() })
+	return storage
+}
+
+func TestCreateRollups_ScalarAndHistogramTiers(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	agentID := uuid.New().String()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var gauges []otlp.MetricGaugeData
+	var histograms []otlp.MetricHistogramData
+	for i := 0; i < 10; i++ {
+		ts := base.Add(time.Duration(i) * 30 * time.Second)
+		gauges = append(gauges, otlp.MetricGaugeData{
+			AgentID: agentID, ServiceName: "api", MetricName: "cpu", TimeUnix: ts, Value: float64(i),
+		})
+		histograms = append(histograms, otlp.MetricHistogramData{
+			AgentID: agentID, ServiceName: "api", MetricName: "latency", TimeUnix: ts,
+			Count: 3, Sum: 30, Min: 1, Max: float64(10 + i),
+			BucketCounts: []uint64{1, 1, 1}, ExplicitBounds: []float64{5, 50},
+		})
+	}
+	require.NoError(t, storage.WriteMetricsFromOTLP(ctx, &otlp.MetricsData{Gauges: gauges, Histograms: histograms}))
+
+	for minute := 0; minute < 5; minute++ {
+		require.NoError(t, storage.CreateRollups(ctx, base.Add(time.Duration(minute)*time.Minute), types.RollupInterval1m))
+	}
+	// Regenerating a window replaces it instead of adding to it
+	require.NoError(t, storage.CreateRollups(ctx, base, types.RollupInterval1m))
+	require.NoError(t, storage.CreateRollups(ctx, base, types.RollupInterval5m))
+
+	oneMinute, err := storage.QueryRollups(ctx, types.RollupQuery{
+		StartTime: base, EndTime: base, Interval: types.RollupInterval1m, MetricName: strPtr("cpu"),
+	})
+	require.NoError(t, err)
+	require.Len(t, oneMinute, 1)
+	assert.Equal(t, int64(2), oneMinute[0].Count)
+	assert.Equal(t, 1.0, oneMinute[0].Sum)
+	assert.Equal(t, "gauge", oneMinute[0].MetricType)
+	assert.Nil(t, oneMinute[0].GroupID)
+
+	fiveMinutes, err := storage.QueryRollups(ctx, types.RollupQuery{
+		StartTime: base, EndTime: base.Add(time.Hour), Interval: types.RollupInterval5m,
+	})
+	require.NoError(t, err)
+	require.Len(t, fiveMinutes, 2)
+
+	byName := map[string]types.Rollup{}
+	for _, r := range fiveMinutes {
+		byName[r.MetricName] = r
+	}
+
+	cpu := byName["cpu"]
+	assert.Equal(t, int64(10), cpu.Count)
+	assert.Equal(t, 45.0, cpu.Sum)
+	assert.Equal(t, 4.5, cpu.Avg)
+	assert.Equal(t, 0.0, cpu.Min)
+	assert.Equal(t, 9.0, cpu.Max)
+
+	latency := byName["latency"]
+	assert.Equal(t, "histogram", latency.MetricType)
+	assert.Equal(t, int64(30), latency.Count)
+	assert.Equal(t, 300.0, latency.Sum)
+	assert.Equal(t, 1.0, latency.Min)
+	assert.Equal(t, 19.0, latency.Max)
+	assert.Equal(t, []int64{10, 10, 10}, latency.BucketCounts)
+	assert.Equal(t, []float64{5, 50}, latency.ExplicitBounds)
+}
+
+func TestCreateRollups_ExponentialHistogramsAndSummaries(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+	agentID := uuid.New().String()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	metrics := &otlp.MetricsData{}
+	for i := 0; i < 10; i++ {
+		ts := base.Add(time.Duration(i) * 30 * time.Second)
+		// Alternate scales so merging has to downscale
+		exponential := otlp.MetricExponentialHistogramData{
+			AgentID: agentID, ServiceName: "api", MetricName: "rpc.duration", TimeUnix: ts,
+			Count: 3, Sum: 9, Min: 0, Max: 4, ZeroCount: 1,
+			Scale: 1, PositiveOffset: 2, PositiveBucketCounts: []uint64{1, 1},
+		}
+		if i%2 == 1 {
+			exponential.Scale, exponential.PositiveOffset, exponential.PositiveBucketCounts = 0, 1, []uint64{2}
+		}
+		metrics.ExponentialHistograms = append(metrics.ExponentialHistograms, exponential)
+		metrics.Summaries = append(metrics.Summaries, otlp.MetricSummaryData{
+			AgentID: agentID, ServiceName: "api", MetricName: "gc.pause", TimeUnix: ts,
+			Count: 10, Sum: 100, QuantileValues: []otlp.SummaryQuantileValue{
+				{Quantile: 0.99, Value: float64(10 + i)}, {Quantile: 0.5, Value: float64(i)},
+			},
+		})
+	}
+	require.NoError(t, storage.WriteMetricsFromOTLP(ctx, metrics))
+
+	raw, err := storage.QueryMetrics(ctx, types.MetricQuery{
+		StartTime: base, EndTime: base, MetricName: strPtr("rpc.duration"),
+	})
+	require.NoError(t, err)
+	require.Len(t, raw, 1)
+	assert.Equal(t, types.MetricTypeExponentialHistogram, raw[0].Type)
+	assert.Equal(t, 3.0, raw[0].Value)
+	require.NotNil(t, raw[0].Exponential)
+	assert.Equal(t, int64(1), raw[0].Exponential.ZeroCount)
+	assert.Equal(t, []int64{1, 1}, raw[0].Exponential.Positive.Counts)
+
+	raw, err = storage.QueryMetrics(ctx, types.MetricQuery{
+		StartTime: base, EndTime: base, MetricName: strPtr("gc.pause"),
+	})
+	require.NoError(t, err)
+	require.Len(t, raw, 1)
+	assert.Equal(t, types.MetricTypeSummary, raw[0].Type)
+	assert.Equal(t, int64(10), raw[0].Count)
+	assert.Equal(t, []histogram.QuantileValue{{Quantile: 0.99, Value: 10}, {Quantile: 0.5, Value: 0}}, raw[0].Quantiles)
+
+	for minute := 0; minute < 5; minute++ {
+		require.NoError(t, storage.CreateRollups(ctx, base.Add(time.Duration(minute)*time.Minute), types.RollupInterval1m))
+	}
+	require.NoError(t, storage.CreateRollups(ctx, base, types.RollupInterval5m))
+
+	rollups, err := storage.QueryRollups(ctx, types.RollupQuery{
+		StartTime: base, EndTime: base, Interval: types.RollupInterval5m,
+	})
+	require.NoError(t, err)
+	require.Len(t, rollups, 2)
+
+	byName := map[string]types.Rollup{}
+	for _, r := range rollups {
+		byName[r.MetricName] = r
+	}
+
+	rpc := byName["rpc.duration"]
+	assert.Equal(t, "exponential_histogram", rpc.MetricType)
+	assert.Equal(t, int64(30), rpc.Count)
+	assert.Equal(t, 90.0, rpc.Sum)
+	require.NotNil(t, rpc.Exponential)
+	assert.Equal(t, int32(0), rpc.Exponential.Scale)
+	assert.Equal(t, int64(10), rpc.Exponential.ZeroCount)
+	assert.Equal(t, histogram.Buckets{Offset: 1, Counts: []int64{20}}, rpc.Exponential.Positive)
+
+	gc := byName["gc.pause"]
+	assert.Equal(t, "summary", gc.MetricType)
+	assert.Equal(t, int64(100), gc.Count)
+	assert.Equal(t, 0.0, gc.Min)
+	assert.Equal(t, 19.0, gc.Max)
+	assert.Equal(t, []histogram.QuantileValue{{Quantile: 0.5, Value: 4.5}, {Quantile: 0.99, Value: 14.5}}, gc.Quantiles)
+}
+
+func TestHistogramRollup_LayoutChangeKeepsLatest(t *testing.T) {
+	h := &histogramRollup{}
+	valid := func(v float64) sql.NullFloat64 { return sql.NullFloat64{Float64: v, Valid: true} }
+
+	h.merge(2, 10, valid(1), valid(9), []int64{1, 1}, []float64{5})
+	h.merge(3, 30, valid(2), valid(20), []int64{1, 1, 1}, []float64{5, 10})
+	h.merge(3, 30, valid(2), valid(20), []int64{1, 1, 1}, []float64{5, 10})
+
+	assert.Equal(t, int64(6), h.count)
+	assert.Equal(t, []int64{2, 2, 2}, h.bucketCounts)
+	assert.Equal(t, []float64{5, 10}, h.explicitBounds)
+	assert.Equal(t, 2.0, h.min)
+}
+
+func TestRollupWatermark(t *testing.T) {
+	storage := newTestStorage(t)
+	ctx := context.Background()
+
+	watermark, err := storage.GetRollupWatermark(ctx, types.RollupInterval1h)
+	require.NoError(t, err)
+	assert.True(t, watermark.IsZero())
+
+	until := time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC)
+	require.NoError(t, storage.SetRollupWatermark(ctx, types.RollupInterval1h, until))
+	require.NoError(t, storage.SetRollupWatermark(ctx, types.RollupInterval1h, until.Add(time.Hour)))
+
+	watermark, err = storage.GetRollupWatermark(ctx, types.RollupInterval1h)
+	require.NoError(t, err)
+	assert.True(t, until.Add(time.Hour).Equal(watermark))
+}
+
+func strPtr(s string) *string {
+	return &s
+}
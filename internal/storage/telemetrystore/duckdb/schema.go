@@ -1,7 +1,17 @@
 package duckdb
 
-// TelemetrySchema defines the DuckDB schema for telemetry data
-const TelemetrySchema = `
+// SchemaVersion is the version of the telemetry schema created by this build
+const SchemaVersion = 6
+
+// Migrations lists the changes to the telemetry schema in the order they are
+// applied. Append new migrations with the next version and never edit one that
+// has been released. The first four predate the migrations table, so they must
+// stay idempotent: databases created before it run them all again.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create raw signal and rollup tables",
+		SQL: `
 -- Metrics tables
 CREATE TABLE IF NOT EXISTS metrics_sum (
 	timestamp TIMESTAMP NOT NULL,
@@ -136,4 +146,194 @@ CREATE TABLE IF NOT EXISTS rollups_1d (
 	max DOUBLE NOT NULL,
 	PRIMARY KEY (window_start, agent_id, group_id, metric_name)
 );
-`
+`,
+	},
+	{
+		Version:     2,
+		Description: "create usage accounting table",
+		SQL: `
+-- Ingestion usage accounting per agent, group and service
+CREATE TABLE IF NOT EXISTS usage_stats (
+	bucket_start TIMESTAMP NOT NULL,
+	signal VARCHAR NOT NULL,
+	agent_id VARCHAR NOT NULL,
+	group_id VARCHAR NOT NULL DEFAULT '',
+	group_name VARCHAR,
+	service_name VARCHAR NOT NULL,
+	records BIGINT NOT NULL,
+	bytes BIGINT NOT NULL,
+	PRIMARY KEY (bucket_start, signal, agent_id, group_id, service_name)
+);
+`,
+	},
+	{
+		Version:     3,
+		Description: "add histogram columns and scheduler state to rollups",
+		SQL: `
+ALTER TABLE rollups_1m ADD COLUMN IF NOT EXISTS metric_type VARCHAR;
+ALTER TABLE rollups_1m ADD COLUMN IF NOT EXISTS bucket_counts BIGINT[];
+ALTER TABLE rollups_1m ADD COLUMN IF NOT EXISTS explicit_bounds DOUBLE[];
+ALTER TABLE rollups_5m ADD COLUMN IF NOT EXISTS metric_type VARCHAR;
+ALTER TABLE rollups_5m ADD COLUMN IF NOT EXISTS bucket_counts BIGINT[];
+ALTER TABLE rollups_5m ADD COLUMN IF NOT EXISTS explicit_bounds DOUBLE[];
+ALTER TABLE rollups_1h ADD COLUMN IF NOT EXISTS metric_type VARCHAR;
+ALTER TABLE rollups_1h ADD COLUMN IF NOT EXISTS bucket_counts BIGINT[];
+ALTER TABLE rollups_1h ADD COLUMN IF NOT EXISTS explicit_bounds DOUBLE[];
+ALTER TABLE rollups_1d ADD COLUMN IF NOT EXISTS metric_type VARCHAR;
+ALTER TABLE rollups_1d ADD COLUMN IF NOT EXISTS bucket_counts BIGINT[];
+ALTER TABLE rollups_1d ADD COLUMN IF NOT EXISTS explicit_bounds DOUBLE[];
+
+-- Progress of the rollup scheduler: each tier is complete up to completed_until
+CREATE TABLE IF NOT EXISTS rollup_state (
+	tier VARCHAR PRIMARY KEY,
+	completed_until TIMESTAMP NOT NULL
+);
+`,
+	},
+	{
+		Version:     4,
+		Description: "create sealed partition catalog",
+		SQL: `
+-- Sealed day partitions of raw tables, stored as Parquet files in the cold path.
+-- A day may have several files when late data arrives after it was sealed.
+CREATE TABLE IF NOT EXISTS telemetry_partitions (
+	path VARCHAR PRIMARY KEY,
+	table_name VARCHAR NOT NULL,
+	day DATE NOT NULL,
+	row_count BIGINT NOT NULL,
+	size_bytes BIGINT NOT NULL,
+	sealed_at TIMESTAMP NOT NULL
+);
+`,
+	},
+	{
+		Version:     5,
+		Description: "add scope, unit, temporality and span metadata",
+		SQL: `
+ALTER TABLE metrics_sum ADD COLUMN start_timestamp TIMESTAMP;
+ALTER TABLE metrics_sum ADD COLUMN metric_unit VARCHAR;
+ALTER TABLE metrics_sum ADD COLUMN scope_name VARCHAR;
+ALTER TABLE metrics_sum ADD COLUMN scope_version VARCHAR;
+ALTER TABLE metrics_sum ADD COLUMN temporality VARCHAR;
+ALTER TABLE metrics_sum ADD COLUMN is_monotonic BOOLEAN;
+ALTER TABLE metrics_sum ADD COLUMN flags UINTEGER;
+
+ALTER TABLE metrics_gauge ADD COLUMN start_timestamp TIMESTAMP;
+ALTER TABLE metrics_gauge ADD COLUMN metric_unit VARCHAR;
+ALTER TABLE metrics_gauge ADD COLUMN scope_name VARCHAR;
+ALTER TABLE metrics_gauge ADD COLUMN scope_version VARCHAR;
+ALTER TABLE metrics_gauge ADD COLUMN flags UINTEGER;
+
+ALTER TABLE metrics_histogram ADD COLUMN start_timestamp TIMESTAMP;
+ALTER TABLE metrics_histogram ADD COLUMN metric_unit VARCHAR;
+ALTER TABLE metrics_histogram ADD COLUMN scope_name VARCHAR;
+ALTER TABLE metrics_histogram ADD COLUMN scope_version VARCHAR;
+ALTER TABLE metrics_histogram ADD COLUMN temporality VARCHAR;
+ALTER TABLE metrics_histogram ADD COLUMN flags UINTEGER;
+
+ALTER TABLE logs ADD COLUMN scope_name VARCHAR;
+ALTER TABLE logs ADD COLUMN scope_version VARCHAR;
+ALTER TABLE logs ADD COLUMN flags UINTEGER;
+
+ALTER TABLE traces ADD COLUMN trace_state VARCHAR;
+ALTER TABLE traces ADD COLUMN scope_name VARCHAR;
+ALTER TABLE traces ADD COLUMN scope_version VARCHAR;
+`,
+	},
+	{
+		Version:     6,
+		Description: "create exponential histogram and summary tables",
+		SQL: `
+CREATE TABLE metrics_exponential_histogram (
+	timestamp TIMESTAMP NOT NULL,
+	agent_id VARCHAR NOT NULL,
+	group_id VARCHAR,
+	group_name VARCHAR,
+	service_name VARCHAR NOT NULL,
+	metric_name VARCHAR NOT NULL,
+	metric_description VARCHAR,
+	count BIGINT NOT NULL,
+	sum DOUBLE NOT NULL,
+	min DOUBLE,
+	max DOUBLE,
+	scale INTEGER NOT NULL,
+	zero_count BIGINT NOT NULL,
+	zero_threshold DOUBLE NOT NULL,
+	positive_offset INTEGER NOT NULL,
+	positive_bucket_counts BIGINT[],
+	negative_offset INTEGER NOT NULL,
+	negative_bucket_counts BIGINT[],
+	resource_attributes JSON,
+	metric_attributes JSON,
+	start_timestamp TIMESTAMP,
+	metric_unit VARCHAR,
+	scope_name VARCHAR,
+	scope_version VARCHAR,
+	temporality VARCHAR,
+	flags UINTEGER
+);
+
+CREATE TABLE metrics_summary (
+	timestamp TIMESTAMP NOT NULL,
+	agent_id VARCHAR NOT NULL,
+	group_id VARCHAR,
+	group_name VARCHAR,
+	service_name VARCHAR NOT NULL,
+	metric_name VARCHAR NOT NULL,
+	metric_description VARCHAR,
+	count BIGINT NOT NULL,
+	sum DOUBLE NOT NULL,
+	quantiles DOUBLE[],
+	quantile_values DOUBLE[],
+	resource_attributes JSON,
+	metric_attributes JSON,
+	start_timestamp TIMESTAMP,
+	metric_unit VARCHAR,
+	scope_name VARCHAR,
+	scope_version VARCHAR,
+	flags UINTEGER
+);
+
+-- Merged exponential buckets and averaged summary quantiles of rollup windows
+ALTER TABLE rollups_1m ADD COLUMN scale INTEGER;
+ALTER TABLE rollups_1m ADD COLUMN zero_count BIGINT;
+ALTER TABLE rollups_1m ADD COLUMN zero_threshold DOUBLE;
+ALTER TABLE rollups_1m ADD COLUMN positive_offset INTEGER;
+ALTER TABLE rollups_1m ADD COLUMN positive_bucket_counts BIGINT[];
+ALTER TABLE rollups_1m ADD COLUMN negative_offset INTEGER;
+ALTER TABLE rollups_1m ADD COLUMN negative_bucket_counts BIGINT[];
+ALTER TABLE rollups_1m ADD COLUMN quantiles DOUBLE[];
+ALTER TABLE rollups_1m ADD COLUMN quantile_values DOUBLE[];
+
+ALTER TABLE rollups_5m ADD COLUMN scale INTEGER;
+ALTER TABLE rollups_5m ADD COLUMN zero_count BIGINT;
+ALTER TABLE rollups_5m ADD COLUMN zero_threshold DOUBLE;
+ALTER TABLE rollups_5m ADD COLUMN positive_offset INTEGER;
+ALTER TABLE rollups_5m ADD COLUMN positive_bucket_counts BIGINT[];
+ALTER TABLE rollups_5m ADD COLUMN negative_offset INTEGER;
+ALTER TABLE rollups_5m ADD COLUMN negative_bucket_counts BIGINT[];
+ALTER TABLE rollups_5m ADD COLUMN quantiles DOUBLE[];
+ALTER TABLE rollups_5m ADD COLUMN quantile_values DOUBLE[];
+
+ALTER TABLE rollups_1h ADD COLUMN scale INTEGER;
+ALTER TABLE rollups_1h ADD COLUMN zero_count BIGINT;
+ALTER TABLE rollups_1h ADD COLUMN zero_threshold DOUBLE;
+ALTER TABLE rollups_1h ADD COLUMN positive_offset INTEGER;
+ALTER TABLE rollups_1h ADD COLUMN positive_bucket_counts BIGINT[];
+ALTER TABLE rollups_1h ADD COLUMN negative_offset INTEGER;
+ALTER TABLE rollups_1h ADD COLUMN negative_bucket_counts BIGINT[];
+ALTER TABLE rollups_1h ADD COLUMN quantiles DOUBLE[];
+ALTER TABLE rollups_1h ADD COLUMN quantile_values DOUBLE[];
+
+ALTER TABLE rollups_1d ADD COLUMN scale INTEGER;
+ALTER TABLE rollups_1d ADD COLUMN zero_count BIGINT;
+ALTER TABLE rollups_1d ADD COLUMN zero_threshold DOUBLE;
+ALTER TABLE rollups_1d ADD COLUMN positive_offset INTEGER;
+ALTER TABLE rollups_1d ADD COLUMN positive_bucket_counts BIGINT[];
+ALTER TABLE rollups_1d ADD COLUMN negative_offset INTEGER;
+ALTER TABLE rollups_1d ADD COLUMN negative_bucket_counts BIGINT[];
+ALTER TABLE rollups_1d ADD COLUMN quantiles DOUBLE[];
+ALTER TABLE rollups_1d ADD COLUMN quantile_values DOUBLE[];
+`,
+	},
+}
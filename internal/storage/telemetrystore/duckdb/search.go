@@ -0,0 +1,181 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+// ancestrySQL relates every span to each of its ancestors within the
+// searched spans
+const ancestrySQL = `ancestry AS (
+		SELECT trace_id, span_id, parent_span_id AS ancestor_id
+		FROM spans
+		WHERE COALESCE(parent_span_id, '') <> ''
+		UNION
+		SELECT a.trace_id, a.span_id, p.parent_span_id
+		FROM ancestry a
+		JOIN spans p ON p.trace_id = a.trace_id AND p.span_id = a.ancestor_id
+		WHERE COALESCE(p.parent_span_id, '') <> ''
+	)`
+
+// SearchTraces finds the traces with spans in a span set and returns each
+// with the spans that matched. Traces are ordered by their newest matching
+// span, newest first.
+func (s *Storage) SearchTraces(ctx context.Context, query types.TraceSearchQuery) ([]types.TraceMatch, error) {
+	ctx, cancel := s.withQueryTimeout(ctx)
+	defer cancel()
+	tx, err := s.beginRead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	source, err := s.tableSource(ctx, tx, "traces", query.StartTime, query.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	where := "timestamp >= ? AND timestamp <= ?"
+	args := []interface{}{query.StartTime, query.EndTime}
+	if query.AgentID != nil {
+		where += " AND agent_id = ?"
+		args = append(args, query.AgentID.String())
+	}
+	if query.GroupID != nil {
+		where += " AND group_id = ?"
+		args = append(args, *query.GroupID)
+	}
+
+	builder := &spanSetBuilder{}
+	matched, err := builder.build(query.Spans)
+	if err != nil {
+		return nil, err
+	}
+	ctes := []string{fmt.Sprintf("spans AS (SELECT * FROM %s WHERE %s)", source, where)}
+	if builder.ancestry {
+		ctes = append(ctes, ancestrySQL)
+	}
+	ctes = append(ctes, builder.ctes...)
+	args = append(args, builder.args...)
+
+	limit := ""
+	if query.Limit > 0 {
+		limit = "LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	sqlQuery := fmt.Sprintf(`
+		WITH RECURSIVE %s,
+		matched AS (SELECT DISTINCT trace_id, span_id FROM %s),
+		newest AS (
+			SELECT m.trace_id, MAX(sp.timestamp) AS newest
+			FROM matched m
+			JOIN spans sp ON sp.trace_id = m.trace_id AND sp.span_id = m.span_id
+			GROUP BY m.trace_id
+			ORDER BY newest DESC, m.trace_id
+			%s
+		)
+		SELECT %s
+		FROM spans sp
+		JOIN matched m ON m.trace_id = sp.trace_id AND m.span_id = sp.span_id
+		JOIN newest n ON n.trace_id = sp.trace_id
+		ORDER BY n.newest DESC, sp.trace_id, sp.timestamp
+	`, strings.Join(ctes, ",\n\t\t"), matched, limit, qualifiedColumns("sp", traceColumns))
+
+	rows, err := tx.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, s.queryError(ctx, fmt.Errorf("failed to search traces: %w", err))
+	}
+	defer rows.Close()
+
+	spans, err := scanTraces(rows)
+	if err != nil {
+		return nil, s.queryError(ctx, err)
+	}
+
+	var matches []types.TraceMatch
+	for _, span := range spans {
+		if len(matches) == 0 || matches[len(matches)-1].TraceID != span.TraceID {
+			matches = append(matches, types.TraceMatch{TraceID: span.TraceID})
+		}
+		last := &matches[len(matches)-1]
+		last.Spans = append(last.Spans, span)
+	}
+	return matches, nil
+}
+
+// spanSetBuilder compiles a span set to one common table expression per
+// node, each selecting the trace_id, span_id and parent_span_id of its spans
+type spanSetBuilder struct {
+	ctes     []string
+	args     []interface{}
+	ancestry bool
+}
+
+// build adds the expressions of a span set and returns the name of the one
+// selecting its spans
+func (b *spanSetBuilder) build(set types.SpanSet) (string, error) {
+	if set.Operator == "" {
+		where, args, err := spanFields.conditions(set.Filters)
+		if err != nil {
+			return "", err
+		}
+		if where == "" {
+			where = "TRUE"
+		}
+		b.args = append(b.args, args...)
+		return b.add("SELECT trace_id, span_id, parent_span_id FROM spans WHERE " + where), nil
+	}
+
+	if set.Left == nil || set.Right == nil {
+		return "", fmt.Errorf("structural operator %s requires two span sets", set.Operator)
+	}
+	left, err := b.build(*set.Left)
+	if err != nil {
+		return "", err
+	}
+	right, err := b.build(*set.Right)
+	if err != nil {
+		return "", err
+	}
+
+	var related string
+	switch set.Operator {
+	case types.StructuralChild:
+		related = fmt.Sprintf("SELECT 1 FROM %s l WHERE l.trace_id = r.trace_id AND l.span_id = r.parent_span_id", left)
+	case types.StructuralDescendant:
+		b.ancestry = true
+		related = fmt.Sprintf(`SELECT 1 FROM ancestry a
+			JOIN %s l ON l.trace_id = a.trace_id AND l.span_id = a.ancestor_id
+			WHERE a.trace_id = r.trace_id AND a.span_id = r.span_id`, left)
+	case types.StructuralSibling:
+		related = fmt.Sprintf(`SELECT 1 FROM %s l
+			WHERE l.trace_id = r.trace_id AND l.parent_span_id = r.parent_span_id AND l.span_id <> r.span_id`, left)
+	default:
+		return "", fmt.Errorf("unsupported structural operator: %s", set.Operator)
+	}
+	return b.add(fmt.Sprintf("SELECT r.* FROM %s r WHERE EXISTS (%s)", right, related)), nil
+}
+
+// add adds a common table expression and returns its name
+func (b *spanSetBuilder) add(query string) string {
+	name := fmt.Sprintf("spanset_%d", len(b.ctes))
+	b.ctes = append(b.ctes, fmt.Sprintf("%s AS (%s)", name, query))
+	return name
+}
+
+// qualifiedColumns prefixes each of a comma separated list of columns with
+// a table alias
+func qualifiedColumns(alias, columns string) string {
+	fields := strings.Split(columns, ",")
+	for i, field := range fields {
+		fields[i] = alias + "." + strings.TrimSpace(field)
+	}
+	return strings.Join(fields, ", ")
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+// writeTraceFixture writes two traces and returns the time range covering
+// them. In t1 frontend calls checkout and cart, and checkout calls db with
+// an error. In t2, written later, frontend calls db directly.
+func writeTraceFixture(t *testing.T, storage *Storage) (time.Time, time.Time) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	span := func(offset time.Duration, traceID, spanID, parentID, service string, duration int64, status string) otlp.TraceData {
+		return otlp.TraceData{
+			Timestamp: base.Add(offset), AgentID: "agent", TraceId: traceID, SpanId: spanID, ParentSpanId: parentID,
+			ServiceName: service, SpanName: service + " call", SpanKind: 2, Duration: duration, StatusCode: status,
+			SpanAttributes:     map[string]interface{}{"http.route": "/" + service},
+			ResourceAttributes: map[string]interface{}{"k8s.namespace": "prod"},
+		}
+	}
+	require.NoError(t, storage.WriteTracesFromOTLP(context.Background(), []otlp.TraceData{
+		span(0, "t1", "s1", "", "frontend", 900e6, "STATUS_CODE_OK"),
+		span(time.Second, "t1", "s2", "s1", "checkout", 700e6, "STATUS_CODE_OK"),
+		span(2*time.Second, "t1", "s3", "s2", "db", 500e6, "STATUS_CODE_ERROR"),
+		span(3*time.Second, "t1", "s4", "s1", "cart", 10e6, "STATUS_CODE_OK"),
+		span(time.Minute, "t2", "r1", "", "frontend", 50e6, "STATUS_CODE_OK"),
+		span(time.Minute+time.Second, "t2", "r2", "r1", "db", 20e6, "STATUS_CODE_OK"),
+	}))
+	return base, base.Add(time.Hour)
+}
+
+// serviceSpans returns a span set selecting the spans of a service
+func serviceSpans(service string) *types.SpanSet {
+	return &types.SpanSet{Filters: []types.Filter{{Field: "service", Operator: types.FilterOpEqual, Value: service}}}
+}
+
+func TestSearchTraces(t *testing.T) {
+	storage := newTestStorage(t)
+	start, end := writeTraceFixture(t, storage)
+
+	tests := []struct {
+		name  string
+		spans types.SpanSet
+		want  map[string][]string
+	}{
+		{"status", types.SpanSet{Filters: []types.Filter{{Field: "status", Operator: types.FilterOpEqual, Value: "STATUS_CODE_ERROR"}}},
+			map[string][]string{"t1": {"s3"}}},
+		{"duration", types.SpanSet{Filters: []types.Filter{{Field: "duration", Operator: types.FilterOpGTE, Value: "700000000", Numeric: true}}},
+			map[string][]string{"t1": {"s1", "s2"}}},
+		{"attributes", types.SpanSet{Filters: []types.Filter{
+			{Field: "resource.k8s.namespace", Operator: types.FilterOpEqual, Value: "prod"},
+			{Field: "http.route", Operator: types.FilterOpRegex, Value: "^/ca"},
+		}}, map[string][]string{"t1": {"s4"}}},
+		{"child", types.SpanSet{Operator: types.StructuralChild, Left: serviceSpans("frontend"), Right: serviceSpans("db")},
+			map[string][]string{"t2": {"r2"}}},
+		{"descendant", types.SpanSet{Operator: types.StructuralDescendant, Left: serviceSpans("frontend"), Right: serviceSpans("db")},
+			map[string][]string{"t1": {"s3"}, "t2": {"r2"}}},
+		{"sibling", types.SpanSet{Operator: types.StructuralSibling, Left: serviceSpans("checkout"), Right: serviceSpans("cart")},
+			map[string][]string{"t1": {"s4"}}},
+		{"chained", types.SpanSet{
+			Operator: types.StructuralChild,
+			Left:     &types.SpanSet{Operator: types.StructuralChild, Left: serviceSpans("frontend"), Right: serviceSpans("checkout")},
+			Right:    serviceSpans("db"),
+		}, map[string][]string{"t1": {"s3"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := storage.SearchTraces(context.Background(), types.TraceSearchQuery{
+				Spans: tt.spans, StartTime: start, EndTime: end,
+			})
+			require.NoError(t, err)
+			got := make(map[string][]string)
+			for _, match := range matches {
+				for _, span := range match.Spans {
+					got[match.TraceID] = append(got[match.TraceID], span.SpanID)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSearchTraces_NewestFirstWithLimit(t *testing.T) {
+	storage := newTestStorage(t)
+	start, end := writeTraceFixture(t, storage)
+
+	matches, err := storage.SearchTraces(context.Background(), types.TraceSearchQuery{
+		Spans: *serviceSpans("frontend"), StartTime: start, EndTime: end, Limit: 1,
+	})
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "t2", matches[0].TraceID)
+	assert.Equal(t, "frontend", matches[0].Spans[0].ServiceName)
+	assert.Equal(t, "server", matches[0].Spans[0].SpanKind)
+}
+
+func TestSearchTraces_UnencodableAttributes(t *testing.T) {
+	storage := newTestStorage(t)
+	start, end := writeTraceFixture(t, storage)
+
+	// A NaN attribute cannot be stored as JSON; the span keeps empty
+	// attributes instead of breaking JSON filters on the table
+	require.NoError(t, storage.WriteTracesFromOTLP(context.Background(), []otlp.TraceData{{
+		Timestamp: start.Add(time.Second), AgentID: "agent", TraceId: "t3", SpanId: "n1",
+		ServiceName: "cart", SpanName: "cart call", Duration: 1e6, StatusCode: "STATUS_CODE_OK",
+		SpanAttributes: map[string]interface{}{"http.route": "/cart", "score": math.NaN()},
+	}}))
+
+	matches, err := storage.SearchTraces(context.Background(), types.TraceSearchQuery{
+		Spans:     types.SpanSet{Filters: []types.Filter{{Field: "http.route", Operator: types.FilterOpRegex, Value: "^/ca"}}},
+		StartTime: start, EndTime: end,
+	})
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "t1", matches[0].TraceID)
+
+	traces, err := storage.QueryTraces(context.Background(), types.TraceQuery{TraceID: strPtr("t3"), StartTime: start, EndTime: end})
+	require.NoError(t, err)
+	require.Len(t, traces, 1)
+	assert.Empty(t, traces[0].Attributes)
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
+)
+
+// WriteUsage adds usage buckets to the usage_stats table. Buckets that already
+// exist are incremented so repeated flushes for the same window accumulate.
+func (s *Storage) WriteUsage(ctx context.Context, usage []otlp.UsageData) error {
+	if len(usage) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO usage_stats (
+			bucket_start, signal, agent_id, group_id, group_name, service_name, records, bytes
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (bucket_start, signal, agent_id, group_id, service_name)
+		DO UPDATE SET
+			group_name = EXCLUDED.group_name,
+			records = usage_stats.records + EXCLUDED.records,
+			bytes = usage_stats.bytes + EXCLUDED.bytes
+	`
+
+	tx, err := s.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, u := range usage {
+		_, err = stmt.ExecContext(ctx,
+			u.BucketStart,
+			u.Signal,
+			u.AgentID,
+			u.GroupID,
+			u.GroupName,
+			u.ServiceName,
+			u.Records,
+			u.Bytes,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert usage: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.Debug("Wrote usage buckets to DuckDB", zap.Int("count", len(usage)))
+	return nil
+}
+
+// QueryTopUsage returns the agents, groups or services with the highest
+// ingestion usage in the requested time range
+func (s *Storage) QueryTopUsage(ctx context.Context, query types.UsageQuery) ([]types.UsageSummary, error) {
+	var keyColumn string
+	switch query.GroupBy {
+	case types.UsageGroupByAgent, "":
+		keyColumn = "agent_id"
+	case types.UsageGroupByGroup:
+		keyColumn = "group_id"
+	case types.UsageGroupByService:
+		keyColumn = "service_name"
+	default:
+		return nil, fmt.Errorf("invalid usage group by: %s", query.GroupBy)
+	}
+
+	switch query.OrderBy {
+	case types.UsageOrderByBytes, types.UsageOrderByRecords, "":
+	default:
+		return nil, fmt.Errorf("invalid usage order by: %s", query.OrderBy)
+	}
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT %s AS key, signal, MAX(group_name) AS group_name, SUM(records) AS records, SUM(bytes) AS bytes
+		FROM usage_stats
+		WHERE bucket_start >= ? AND bucket_start <= ?
+	`, keyColumn)
+	args := []interface{}{query.StartTime, query.EndTime}
+
+	if query.Signal != nil {
+		sqlQuery += ` AND signal = ?`
+		args = append(args, *query.Signal)
+	}
+
+	if query.AgentID != nil {
+		sqlQuery += ` AND agent_id = ?`
+		args = append(args, query.AgentID.String())
+	}
+
+	if query.GroupID != nil {
+		sqlQuery += ` AND group_id = ?`
+		args = append(args, *query.GroupID)
+	}
+
+	sqlQuery += fmt.Sprintf(` GROUP BY %s, signal`, keyColumn)
+
+	ctx, cancel := s.withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, s.queryError(ctx, fmt.Errorf("failed to query usage: %w", err))
+	}
+	defer rows.Close()
+
+	summaries := make(map[string]*types.UsageSummary)
+	for rows.Next() {
+		var key, signal string
+		var groupName sql.NullString
+		var records, bytes int64
+
+		if err := rows.Scan(&key, &signal, &groupName, &records, &bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+
+		summary, ok := summaries[key]
+		if !ok {
+			summary = &types.UsageSummary{
+				Key:     key,
+				Signals: make(map[string]types.SignalUsage),
+			}
+			summaries[key] = summary
+		}
+		if query.GroupBy == types.UsageGroupByGroup && groupName.Valid {
+			summary.GroupName = groupName.String
+		}
+		summary.Records += records
+		summary.Bytes += bytes
+		summary.Signals[signal] = types.SignalUsage{Records: records, Bytes: bytes}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, s.queryError(ctx, fmt.Errorf("error iterating rows: %w", err))
+	}
+
+	result := make([]types.UsageSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, *summary)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i].Bytes, result[j].Bytes
+		if query.OrderBy == types.UsageOrderByRecords {
+			a, b = result[i].Records, result[j].Records
+		}
+		if a != b {
+			return a > b
+		}
+		return result[i].Key < result[j].Key
+	})
+
+	if query.Limit > 0 && len(result) > query.Limit {
+		result = result[:query.Limit]
+	}
+
+	return result, nil
+}
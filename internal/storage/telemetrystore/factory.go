@@ -7,6 +7,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/getlawrence/lawrence-oss/internal/config"
+	"github.com/getlawrence/lawrence-oss/internal/metrics"
 	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/duckdb"
 	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
 )
@@ -71,7 +72,11 @@ func NewFactory(config Config) (*Factory, error) {
 func (f *Factory) getFactoryOfType(factoryType string) (TelemetryStoreFactory, error) {
 	switch factoryType {
 	case duckdbStorageType:
-		return duckdb.NewFactory(f.Config.Path), nil
+		opts, err := f.duckdbOptions()
+		if err != nil {
+			return nil, err
+		}
+		return duckdb.NewFactory(f.Config.Path, opts), nil
 	// Add more storage types as they are implemented
 	// case memoryStorageType:
 	//     return memory.NewFactory(), nil
@@ -82,6 +87,25 @@ func (f *Factory) getFactoryOfType(factoryType string) (TelemetryStoreFactory, e
 	}
 }
 
+// duckdbOptions converts the configuration to DuckDB storage options
+func (f *Factory) duckdbOptions() (duckdb.Options, error) {
+	opts := duckdb.Options{
+		ColdPath:        f.Config.ColdPath,
+		ReadConnections: f.Config.ReadConnections,
+	}
+	if f.Config.QueryTimeout != "" {
+		timeout, err := config.ParseDuration(f.Config.QueryTimeout)
+		if err != nil {
+			return opts, fmt.Errorf("invalid query timeout %q: %w", f.Config.QueryTimeout, err)
+		}
+		opts.QueryTimeout = timeout
+	}
+	if f.Config.MetricsFactory != nil {
+		opts.Metrics = metrics.NewStorageMetrics(f.Config.MetricsFactory)
+	}
+	return opts, nil
+}
+
 // Initialize initializes the meta factory and all underlying factories
 func (f *Factory) Initialize(logger *zap.Logger) error {
 	f.logger = logger
@@ -132,6 +156,24 @@ func (f *Factory) Close() error {
 	return nil
 }
 
+// Backupper returns the backup support of the configured storage type
+func (f *Factory) Backupper() (Backupper, error) {
+	backupper, ok := f.factories[f.Config.Type].(Backupper)
+	if !ok {
+		return nil, fmt.Errorf("telemetry storage type %s does not support backups", f.Config.Type)
+	}
+	return backupper, nil
+}
+
+// Migrator returns the schema migration support of the configured storage type
+func (f *Factory) Migrator() (Migrator, error) {
+	migrator, ok := f.factories[f.Config.Type].(Migrator)
+	if !ok {
+		return nil, fmt.Errorf("telemetry storage type %s does not support migrations", f.Config.Type)
+	}
+	return migrator, nil
+}
+
 // GetStorageType returns the configured storage type
 func (f *Factory) GetStorageType() string {
 	return f.Config.Type
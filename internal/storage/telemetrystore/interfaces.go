@@ -1,6 +1,8 @@
 package telemetrystore
 
 import (
+	"context"
+
 	"github.com/getlawrence/lawrence-oss/internal/storage/telemetrystore/types"
 	"go.uber.org/zap"
 )
@@ -17,3 +19,34 @@ type TelemetryStoreFactory interface {
 	// It is called after all configuration of the factory itself has been done.
 	Initialize(logger *zap.Logger) error
 }
+
+// Backupper defines an interface for taking and restoring snapshots of the storage.
+type Backupper interface {
+	// SchemaVersion returns the schema version created by this build of the storage.
+	SchemaVersion() int
+
+	// Backup writes a consistent snapshot of the running storage into dir.
+	Backup(ctx context.Context, dir string) error
+
+	// PrepareRestore stages the snapshot in dir next to the storage without
+	// replacing it. It must be called before the factory is initialized.
+	PrepareRestore(ctx context.Context, dir string) error
+
+	// CommitRestore replaces the storage with the snapshot staged by PrepareRestore.
+	CommitRestore() error
+
+	// DiscardRestore removes the snapshot staged by PrepareRestore.
+	DiscardRestore() error
+}
+
+// Migrator defines an interface for inspecting and applying schema migrations
+// outside of a running server. Both methods must be called before the factory
+// is initialized, which applies pending migrations itself.
+type Migrator interface {
+	// MigrationStatus returns every known migration with when it was applied.
+	MigrationStatus(ctx context.Context) ([]types.MigrationStatus, error)
+
+	// Migrate applies the pending migrations and returns them. With dryRun
+	// it only returns the migrations that would be applied.
+	Migrate(ctx context.Context, dryRun bool) ([]types.MigrationStatus, error)
+}
@@ -16,6 +16,31 @@ type TraceQuery = types.TraceQuery
 type Rollup = types.Rollup
 type RollupInterval = types.RollupInterval
 type RollupQuery = types.RollupQuery
+type UsageGroupBy = types.UsageGroupBy
+type UsageOrderBy = types.UsageOrderBy
+type UsageQuery = types.UsageQuery
+type SignalUsage = types.SignalUsage
+type UsageSummary = types.UsageSummary
+type RetentionPolicy = types.RetentionPolicy
+type RetentionOverride = types.RetentionOverride
+type RetentionReport = types.RetentionReport
+type TableRetention = types.TableRetention
+type Partition = types.Partition
+type ExportSignal = types.ExportSignal
+type ExportFormat = types.ExportFormat
+type ExportQuery = types.ExportQuery
+type MigrationStatus = types.MigrationStatus
+type Filter = types.Filter
+type FilterOperator = types.FilterOperator
+type AggregateFunction = types.AggregateFunction
+type MetricAggregateQuery = types.MetricAggregateQuery
+type LogAggregateQuery = types.LogAggregateQuery
+type Aggregate = types.Aggregate
+type MetricIncrease = types.MetricIncrease
+type StructuralOperator = types.StructuralOperator
+type SpanSet = types.SpanSet
+type TraceSearchQuery = types.TraceSearchQuery
+type TraceMatch = types.TraceMatch
 
 // Re-export constants
 const (
@@ -26,4 +51,15 @@ const (
 	RollupInterval5m    = types.RollupInterval5m
 	RollupInterval1h    = types.RollupInterval1h
 	RollupInterval1d    = types.RollupInterval1d
+	UsageGroupByAgent   = types.UsageGroupByAgent
+	UsageGroupByGroup   = types.UsageGroupByGroup
+	UsageGroupByService = types.UsageGroupByService
+	UsageOrderByBytes   = types.UsageOrderByBytes
+	UsageOrderByRecords = types.UsageOrderByRecords
+	ExportSignalMetrics = types.ExportSignalMetrics
+	ExportSignalLogs    = types.ExportSignalLogs
+	ExportSignalTraces  = types.ExportSignalTraces
+	ExportFormatParquet = types.ExportFormatParquet
+	ExportFormatNDJSON  = types.ExportFormatNDJSON
+	ExportFormatCSV     = types.ExportFormatCSV
 )
@@ -2,8 +2,10 @@ package types
 
 import (
 	"context"
+	"io"
 	"time"
 
+	"github.com/getlawrence/lawrence-oss/internal/histogram"
 	"github.com/getlawrence/lawrence-oss/internal/otlp"
 	"github.com/google/uuid"
 )
@@ -13,6 +15,12 @@ type Reader interface {
 	QueryMetrics(ctx context.Context, query MetricQuery) ([]Metric, error)
 	QueryLogs(ctx context.Context, query LogQuery) ([]Log, error)
 	QueryTraces(ctx context.Context, query TraceQuery) ([]Trace, error)
+	SearchTraces(ctx context.Context, query TraceSearchQuery) ([]TraceMatch, error)
+
+	// Aggregations evaluated by the store
+	AggregateMetrics(ctx context.Context, query MetricAggregateQuery) ([]Aggregate, error)
+	AggregateLogs(ctx context.Context, query LogAggregateQuery) ([]Aggregate, error)
+	QueryMetricIncrease(ctx context.Context, query MetricQuery) ([]MetricIncrease, error)
 
 	// Raw SQL query for flexible querying
 	QueryRaw(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error)
@@ -20,16 +28,28 @@ type Reader interface {
 	// Rollups
 	CreateRollups(ctx context.Context, window time.Time, interval RollupInterval) error
 	QueryRollups(ctx context.Context, query RollupQuery) ([]Rollup, error)
+	GetRollupWatermark(ctx context.Context, interval RollupInterval) (time.Time, error)
+	SetRollupWatermark(ctx context.Context, interval RollupInterval, completedUntil time.Time) error
+
+	// Usage accounting
+	QueryTopUsage(ctx context.Context, query UsageQuery) ([]UsageSummary, error)
+
+	// Partitions
+	SealPartitions(ctx context.Context, before time.Time) ([]Partition, error)
 
-	// Cleanup
-	CleanupOldData(ctx context.Context, retention time.Duration) error
+	// Retention
+	ApplyRetention(ctx context.Context, policy RetentionPolicy, dryRun bool) (*RetentionReport, error)
+
+	// Export streams raw telemetry to w and returns the number of rows written
+	Export(ctx context.Context, query ExportQuery, w io.Writer) (int64, error)
 }
 
 // Writer interface for writing telemetry data using OTLP parsed types
 type Writer interface {
 	WriteTraces(ctx context.Context, traces []otlp.TraceData) error
-	WriteMetrics(ctx context.Context, sums []otlp.MetricSumData, gauges []otlp.MetricGaugeData, histograms []otlp.MetricHistogramData) error
+	WriteMetrics(ctx context.Context, metrics *otlp.MetricsData) error
 	WriteLogs(ctx context.Context, logs []otlp.LogData) error
+	WriteUsage(ctx context.Context, usage []otlp.UsageData) error
 }
 
 // Metric represents a metric data point
@@ -44,6 +64,24 @@ type Metric struct {
 	ConfigHash       *string                `json:"config_hash,omitempty"`
 	Labels           map[string]string      `json:"labels,omitempty"`
 	Type             MetricType             `json:"type,omitempty"`
+	StartTime        *time.Time             `json:"start_time,omitempty"`
+	Unit             string                 `json:"unit,omitempty"`
+	ScopeName        string                 `json:"scope_name,omitempty"`
+	ScopeVersion     string                 `json:"scope_version,omitempty"`
+	// Temporality is "delta" or "cumulative" for sums, empty for gauges
+	Temporality string `json:"temporality,omitempty"`
+	IsMonotonic bool   `json:"is_monotonic,omitempty"`
+	// Count and Sum summarize the observations of histograms and summaries,
+	// whose Value is their mean
+	Count int64   `json:"count,omitempty"`
+	Sum   float64 `json:"sum,omitempty"`
+	// BucketCounts and ExplicitBounds hold the buckets of explicit histograms
+	BucketCounts   []int64   `json:"bucket_counts,omitempty"`
+	ExplicitBounds []float64 `json:"explicit_bounds,omitempty"`
+	// Exponential holds the buckets of exponential histograms
+	Exponential *histogram.Exponential `json:"exponential,omitempty"`
+	// Quantiles holds the quantiles reported by summaries
+	Quantiles []histogram.QuantileValue `json:"quantiles,omitempty"`
 }
 
 // MetricType represents the type of metric
@@ -53,6 +91,9 @@ const (
 	MetricTypeGauge     MetricType = "gauge"
 	MetricTypeCounter   MetricType = "counter"
 	MetricTypeHistogram MetricType = "histogram"
+
+	MetricTypeExponentialHistogram MetricType = "exponential_histogram"
+	MetricTypeSummary              MetricType = "summary"
 )
 
 // Log represents a log entry
@@ -68,6 +109,9 @@ type Log struct {
 	SpanID         *string                `json:"span_id,omitempty"`
 	LogAttributes  map[string]interface{} `json:"log_attributes"`
 	ConfigHash     *string                `json:"config_hash,omitempty"`
+	ScopeName      string                 `json:"scope_name,omitempty"`
+	ScopeVersion   string                 `json:"scope_version,omitempty"`
+	Flags          uint32                 `json:"flags,omitempty"`
 	// Deprecated: use SeverityText instead
 	Severity string `json:"severity,omitempty"`
 	// Deprecated: use LogAttributes instead
@@ -76,17 +120,22 @@ type Log struct {
 
 // Trace represents a trace span
 type Trace struct {
-	Timestamp     time.Time         `json:"timestamp"`
-	AgentID       uuid.UUID         `json:"agent_id"`
-	ConfigHash    *string           `json:"config_hash,omitempty"`
-	TraceID       string            `json:"trace_id"`
-	SpanID        string            `json:"span_id"`
-	ParentSpanID  *string           `json:"parent_span_id,omitempty"`
-	Name          string            `json:"name"`
-	Duration      int64             `json:"duration"`
-	StatusCode    string            `json:"status_code"`
-	StatusMessage string            `json:"status_message"`
-	Attributes    map[string]string `json:"attributes"`
+	Timestamp     time.Time              `json:"timestamp"`
+	AgentID       uuid.UUID              `json:"agent_id"`
+	ConfigHash    *string                `json:"config_hash,omitempty"`
+	ServiceName   string                 `json:"service_name,omitempty"`
+	TraceID       string                 `json:"trace_id"`
+	SpanID        string                 `json:"span_id"`
+	ParentSpanID  *string                `json:"parent_span_id,omitempty"`
+	Name          string                 `json:"name"`
+	Duration      int64                  `json:"duration"`
+	StatusCode    string                 `json:"status_code"`
+	StatusMessage string                 `json:"status_message"`
+	Attributes    map[string]interface{} `json:"attributes"`
+	SpanKind      string                 `json:"span_kind,omitempty"`
+	TraceState    string                 `json:"trace_state,omitempty"`
+	ScopeName     string                 `json:"scope_name,omitempty"`
+	ScopeVersion  string                 `json:"scope_version,omitempty"`
 }
 
 // MetricQuery represents a query for metrics
@@ -94,9 +143,15 @@ type MetricQuery struct {
 	AgentID    *uuid.UUID
 	GroupID    *string
 	MetricName *string
-	StartTime  time.Time
-	EndTime    time.Time
-	Limit      int
+	// Unit, ScopeName and Temporality filter on OTLP metadata
+	Unit        *string
+	ScopeName   *string
+	Temporality *string
+	StartTime   time.Time
+	EndTime     time.Time
+	Limit       int
+	// Filters are further conditions on metric fields and attributes
+	Filters []Filter
 }
 
 // LogQuery represents a query for logs
@@ -108,6 +163,101 @@ type LogQuery struct {
 	StartTime time.Time
 	EndTime   time.Time
 	Limit     int
+	// Filters are further conditions on log fields and attributes
+	Filters []Filter
+}
+
+// FilterOperator compares a field with the value of a filter
+type FilterOperator string
+
+const (
+	FilterOpEqual    FilterOperator = "="
+	FilterOpNotEqual FilterOperator = "!="
+	FilterOpRegex    FilterOperator = "=~"
+	FilterOpNotRegex FilterOperator = "!~"
+	FilterOpGT       FilterOperator = ">"
+	FilterOpGTE      FilterOperator = ">="
+	FilterOpLT       FilterOperator = "<"
+	FilterOpLTE      FilterOperator = "<="
+)
+
+// Filter restricts a query to the rows whose field matches a value. Fields
+// the store has a column for, like service_name, read the column and any
+// other field reads the attribute of that name. Only != and !~ match rows
+// without the field. Numeric filters compare numbers where the field holds
+// one, and ordering operators never match fields that do not.
+type Filter struct {
+	Field    string
+	Operator FilterOperator
+	Value    string
+	Numeric  bool
+}
+
+// AggregateFunction is the function an aggregate query applies to each group
+type AggregateFunction string
+
+const (
+	AggregateSum   AggregateFunction = "sum"
+	AggregateAvg   AggregateFunction = "avg"
+	AggregateMin   AggregateFunction = "min"
+	AggregateMax   AggregateFunction = "max"
+	AggregateCount AggregateFunction = "count"
+)
+
+// MetricAggregateQuery groups the metrics matching Query by the By fields and
+// aggregates the values of each group. Query.Limit caps the number of groups.
+type MetricAggregateQuery struct {
+	Query    MetricQuery
+	Function AggregateFunction
+	By       []string
+}
+
+// LogAggregateQuery groups the logs matching Query by the By fields. Logs only
+// support counting.
+type LogAggregateQuery struct {
+	Query    LogQuery
+	Function AggregateFunction
+	By       []string
+	// BySeries groups by log series as well, the agent and attribute set of
+	// each log. Their attributes and agent_id are added to the group labels.
+	BySeries bool
+}
+
+// Aggregate is the aggregated value of one group
+type Aggregate struct {
+	// Labels holds the By fields the group has
+	Labels map[string]string
+	Value  float64
+	Count  int64
+	// Timestamp is that of the minimum or maximum point for min and max, and
+	// of the newest point otherwise
+	Timestamp time.Time
+}
+
+// MetricIncrease summarizes the points of one series matching a metrics
+// query, oldest first, so that rate and increase can be calculated without
+// reading them. A series is a metric name, agent, service and attribute set.
+type MetricIncrease struct {
+	MetricName  string
+	AgentID     uuid.UUID
+	ServiceName string
+	// Labels holds the metric attributes of the series
+	Labels         map[string]string
+	Points         int64
+	FirstTimestamp time.Time
+	LastTimestamp  time.Time
+	// FirstStartTime is the start time of the first point, if it has one
+	FirstStartTime *time.Time
+	FirstValue     float64
+	LastValue      float64
+	Sum            float64
+	// ResetIncrease is the increase between consecutive points, counting a
+	// decrease as a counter reset
+	ResetIncrease float64
+	// Monotonic reports whether the first point is a monotonic sum
+	Monotonic bool
+	// Temporalities lists the distinct temporalities of the points
+	Temporalities []string
 }
 
 // TraceQuery represents a query for traces
@@ -120,6 +270,45 @@ type TraceQuery struct {
 	Limit     int
 }
 
+// StructuralOperator relates spans of the same trace
+type StructuralOperator string
+
+const (
+	// StructuralChild relates a span to its parent
+	StructuralChild StructuralOperator = ">"
+	// StructuralDescendant relates a span to any of its ancestors
+	StructuralDescendant StructuralOperator = ">>"
+	// StructuralSibling relates a span to other spans with the same parent
+	StructuralSibling StructuralOperator = "~"
+)
+
+// SpanSet selects spans. A leaf selects the spans matching all its Filters.
+// Otherwise it selects the spans of Right that Operator relates to a span of
+// Left, e.g. the children of Left for StructuralChild.
+type SpanSet struct {
+	Filters  []Filter
+	Operator StructuralOperator
+	Left     *SpanSet
+	Right    *SpanSet
+}
+
+// TraceSearchQuery searches for traces with spans in a span set. Limit caps
+// the number of traces, newest first.
+type TraceSearchQuery struct {
+	AgentID   *uuid.UUID
+	GroupID   *string
+	Spans     SpanSet
+	StartTime time.Time
+	EndTime   time.Time
+	Limit     int
+}
+
+// TraceMatch is a trace with the spans a search matched, oldest first
+type TraceMatch struct {
+	TraceID string  `json:"trace_id"`
+	Spans   []Trace `json:"spans"`
+}
+
 // Rollup represents pre-aggregated data
 type Rollup struct {
 	WindowStart time.Time      `json:"window_start"`
@@ -132,6 +321,16 @@ type Rollup struct {
 	Min         float64        `json:"min"`
 	Max         float64        `json:"max"`
 	Interval    RollupInterval `json:"interval"`
+	// MetricType is the type of the source metric: sum, gauge, histogram,
+	// exponential_histogram or summary
+	MetricType string `json:"metric_type,omitempty"`
+	// BucketCounts and ExplicitBounds hold the merged buckets of histogram rollups
+	BucketCounts   []int64   `json:"bucket_counts,omitempty"`
+	ExplicitBounds []float64 `json:"explicit_bounds,omitempty"`
+	// Exponential holds the merged buckets of exponential histogram rollups
+	Exponential *histogram.Exponential `json:"exponential,omitempty"`
+	// Quantiles holds the count-weighted mean quantiles of summary rollups
+	Quantiles []histogram.QuantileValue `json:"quantiles,omitempty"`
 }
 
 // RollupInterval represents the rollup time window
@@ -153,3 +352,141 @@ type RollupQuery struct {
 	EndTime    time.Time
 	Interval   RollupInterval
 }
+
+// UsageGroupBy selects the dimension usage is aggregated by
+type UsageGroupBy string
+
+const (
+	UsageGroupByAgent   UsageGroupBy = "agent"
+	UsageGroupByGroup   UsageGroupBy = "group"
+	UsageGroupByService UsageGroupBy = "service"
+)
+
+// UsageOrderBy selects the measure used to rank usage
+type UsageOrderBy string
+
+const (
+	UsageOrderByBytes   UsageOrderBy = "bytes"
+	UsageOrderByRecords UsageOrderBy = "records"
+)
+
+// UsageQuery represents a query for top-N ingestion usage
+type UsageQuery struct {
+	GroupBy   UsageGroupBy
+	OrderBy   UsageOrderBy
+	Signal    *string
+	AgentID   *uuid.UUID
+	GroupID   *string
+	StartTime time.Time
+	EndTime   time.Time
+	Limit     int
+}
+
+// SignalUsage represents usage of a single signal
+type SignalUsage struct {
+	Records int64 `json:"records"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// UsageSummary represents aggregated ingestion usage for a single agent, group or service
+type UsageSummary struct {
+	Key       string                 `json:"key"`
+	GroupName string                 `json:"group_name,omitempty"`
+	Records   int64                  `json:"records"`
+	Bytes     int64                  `json:"bytes"`
+	Signals   map[string]SignalUsage `json:"signals"`
+}
+
+// RetentionPolicy describes how long each kind of telemetry is kept. A zero
+// duration keeps data indefinitely.
+type RetentionPolicy struct {
+	RawMetrics time.Duration
+	RawLogs    time.Duration
+	RawTraces  time.Duration
+	Rollups    map[RollupInterval]time.Duration
+	Usage      time.Duration
+	// Overrides replace the raw signal retention for matching groups or
+	// services; the first matching override wins
+	Overrides []RetentionOverride
+	// MaxDiskBytes caps the database size by evicting the oldest raw data
+	// once expired data is gone; zero disables the cap
+	MaxDiskBytes int64
+}
+
+// RetentionOverride replaces raw retention for a group, a service or both.
+// Group matches either the group ID or the group name. A zero duration
+// inherits the policy default.
+type RetentionOverride struct {
+	Group      string
+	Service    string
+	RawMetrics time.Duration
+	RawLogs    time.Duration
+	RawTraces  time.Duration
+}
+
+// RetentionReport describes what a retention run deleted, or would delete
+// when run as a dry run
+type RetentionReport struct {
+	DryRun         bool             `json:"dry_run"`
+	Tables         []TableRetention `json:"tables"`
+	DiskUsageBytes int64            `json:"disk_usage_bytes"`
+	MaxDiskBytes   int64            `json:"max_disk_bytes,omitempty"`
+	EvictionCutoff *time.Time       `json:"eviction_cutoff,omitempty"`
+}
+
+// TableRetention describes the rows removed from a single table
+type TableRetention struct {
+	Table string `json:"table"`
+	// Cutoff is the default cutoff; overrides may use a different one
+	Cutoff      *time.Time `json:"cutoff,omitempty"`
+	ExpiredRows int64      `json:"expired_rows"`
+	EvictedRows int64      `json:"evicted_rows"`
+	// DroppedPartitions counts sealed partitions removed whole
+	DroppedPartitions int `json:"dropped_partitions,omitempty"`
+}
+
+// Partition is a sealed day of a raw telemetry table stored as Parquet
+type Partition struct {
+	Table string    `json:"table"`
+	Day   time.Time `json:"day"`
+	Path  string    `json:"path"`
+	Rows  int64     `json:"rows"`
+	Bytes int64     `json:"bytes"`
+}
+
+// ExportSignal selects the telemetry an export reads
+type ExportSignal string
+
+const (
+	ExportSignalMetrics ExportSignal = "metrics"
+	ExportSignalLogs    ExportSignal = "logs"
+	ExportSignalTraces  ExportSignal = "traces"
+)
+
+// ExportFormat selects the file format of an export
+type ExportFormat string
+
+const (
+	ExportFormatParquet ExportFormat = "parquet"
+	ExportFormatNDJSON  ExportFormat = "ndjson"
+	ExportFormatCSV     ExportFormat = "csv"
+)
+
+// ExportQuery selects the raw telemetry to export
+type ExportQuery struct {
+	Signal      ExportSignal
+	Format      ExportFormat
+	AgentID     *uuid.UUID
+	GroupID     *string
+	ServiceName *string
+	StartTime   time.Time
+	EndTime     time.Time
+}
+
+// MigrationStatus describes a schema migration and when it was applied
+type MigrationStatus struct {
+	Version     int        `json:"version"`
+	Description string     `json:"description"`
+	SQL         string     `json:"sql,omitempty"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+}
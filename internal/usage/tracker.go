@@ -0,0 +1,251 @@
+// Copyright (c) 2024 Lawrence OSS Contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package usage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+)
+
+// Signal names used for usage accounting
+const (
+	SignalTraces  = "traces"
+	SignalMetrics = "metrics"
+	SignalLogs    = "logs"
+)
+
+// Writer persists flushed usage buckets
+type Writer interface {
+	WriteUsage(ctx context.Context, usage []otlp.UsageData) error
+}
+
+// bucketKey identifies a single accounting bucket
+type bucketKey struct {
+	bucketStart time.Time
+	signal      string
+	agentID     string
+	groupID     string
+	serviceName string
+}
+
+// sourceKey identifies the producer of a record within a single payload
+type sourceKey struct {
+	agentID     string
+	groupID     string
+	groupName   string
+	serviceName string
+}
+
+// Tracker accumulates records and bytes per signal, agent, group and service
+// in fixed time buckets and periodically flushes them to storage
+type Tracker struct {
+	mu            sync.Mutex
+	buckets       map[bucketKey]*otlp.UsageData
+	writer        Writer
+	bucketSize    time.Duration
+	flushInterval time.Duration
+	logger        *zap.Logger
+	shutdown      chan struct{}
+	wg            sync.WaitGroup
+}
+
+// NewTracker creates a new usage tracker
+func NewTracker(writer Writer, bucketSize, flushInterval time.Duration, logger *zap.Logger) *Tracker {
+	if bucketSize <= 0 {
+		bucketSize = time.Minute
+	}
+	if flushInterval <= 0 {
+		flushInterval = 30 * time.Second
+	}
+	return &Tracker{
+		buckets:       make(map[bucketKey]*otlp.UsageData),
+		writer:        writer,
+		bucketSize:    bucketSize,
+		flushInterval: flushInterval,
+		logger:        logger,
+		shutdown:      make(chan struct{}),
+	}
+}
+
+// Start starts the periodic flush loop
+func (t *Tracker) Start() {
+	t.logger.Info("Starting usage tracker",
+		zap.Duration("bucket_size", t.bucketSize),
+		zap.Duration("flush_interval", t.flushInterval))
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(t.flushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := t.Flush(context.Background()); err != nil {
+					t.logger.Error("Failed to flush usage", zap.Error(err))
+				}
+			case <-t.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the flush loop and flushes any pending buckets
+func (t *Tracker) Stop(ctx context.Context) error {
+	close(t.shutdown)
+	t.wg.Wait()
+	return t.Flush(ctx)
+}
+
+// RecordTraces accounts a batch of parsed spans received in a single payload
+func (t *Tracker) RecordTraces(traces []otlp.TraceData, payloadBytes int, receivedAt time.Time) {
+	counts := make(map[sourceKey]int64)
+	for i := range traces {
+		counts[sourceKey{
+			agentID:     traces[i].AgentID,
+			groupID:     traces[i].GroupID,
+			groupName:   traces[i].GroupName,
+			serviceName: traces[i].ServiceName,
+		}]++
+	}
+	t.record(SignalTraces, counts, payloadBytes, receivedAt)
+}
+
+// RecordMetrics accounts a batch of parsed data points received in a single payload
+func (t *Tracker) RecordMetrics(metrics *otlp.MetricsData, payloadBytes int, receivedAt time.Time) {
+	counts := make(map[sourceKey]int64)
+	for _, m := range metrics.Sums {
+		counts[sourceKey{m.AgentID, m.GroupID, m.GroupName, m.ServiceName}]++
+	}
+	for _, m := range metrics.Gauges {
+		counts[sourceKey{m.AgentID, m.GroupID, m.GroupName, m.ServiceName}]++
+	}
+	for _, m := range metrics.Histograms {
+		counts[sourceKey{m.AgentID, m.GroupID, m.GroupName, m.ServiceName}]++
+	}
+	for _, m := range metrics.ExponentialHistograms {
+		counts[sourceKey{m.AgentID, m.GroupID, m.GroupName, m.ServiceName}]++
+	}
+	for _, m := range metrics.Summaries {
+		counts[sourceKey{m.AgentID, m.GroupID, m.GroupName, m.ServiceName}]++
+	}
+	t.record(SignalMetrics, counts, payloadBytes, receivedAt)
+}
+
+// RecordLogs accounts a batch of parsed log records received in a single payload
+func (t *Tracker) RecordLogs(logs []otlp.LogData, payloadBytes int, receivedAt time.Time) {
+	counts := make(map[sourceKey]int64)
+	for i := range logs {
+		counts[sourceKey{logs[i].AgentID, logs[i].GroupID, logs[i].GroupName, logs[i].ServiceName}]++
+	}
+	t.record(SignalLogs, counts, payloadBytes, receivedAt)
+}
+
+// record adds per-source record counts to the current bucket. The raw payload
+// size is attributed to each source in proportion to its share of records,
+// since individual record sizes are no longer known after parsing.
+func (t *Tracker) record(signal string, counts map[sourceKey]int64, payloadBytes int, receivedAt time.Time) {
+	if len(counts) == 0 {
+		return
+	}
+
+	var total int64
+	sources := make([]sourceKey, 0, len(counts))
+	for source, count := range counts {
+		sources = append(sources, source)
+		total += count
+	}
+
+	// Sort so the rounding remainder always lands on the same source
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].agentID != sources[j].agentID {
+			return sources[i].agentID < sources[j].agentID
+		}
+		if sources[i].groupID != sources[j].groupID {
+			return sources[i].groupID < sources[j].groupID
+		}
+		return sources[i].serviceName < sources[j].serviceName
+	})
+
+	bucketStart := receivedAt.UTC().Truncate(t.bucketSize)
+	remaining := int64(payloadBytes)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, source := range sources {
+		count := counts[source]
+		bytes := int64(payloadBytes) * count / total
+		if i == len(sources)-1 {
+			bytes = remaining
+		}
+		remaining -= bytes
+
+		key := bucketKey{
+			bucketStart: bucketStart,
+			signal:      signal,
+			agentID:     source.agentID,
+			groupID:     source.groupID,
+			serviceName: source.serviceName,
+		}
+		bucket, ok := t.buckets[key]
+		if !ok {
+			bucket = &otlp.UsageData{
+				BucketStart: bucketStart,
+				Signal:      signal,
+				AgentID:     source.agentID,
+				GroupID:     source.groupID,
+				GroupName:   source.groupName,
+				ServiceName: source.serviceName,
+			}
+			t.buckets[key] = bucket
+		}
+		bucket.Records += count
+		bucket.Bytes += bytes
+	}
+}
+
+// Flush writes all pending buckets to storage. Buckets that fail to write are
+// merged back so they are retried on the next flush.
+func (t *Tracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	if len(t.buckets) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	pending := t.buckets
+	t.buckets = make(map[bucketKey]*otlp.UsageData)
+	t.mu.Unlock()
+
+	batch := make([]otlp.UsageData, 0, len(pending))
+	for _, bucket := range pending {
+		batch = append(batch, *bucket)
+	}
+
+	if err := t.writer.WriteUsage(ctx, batch); err != nil {
+		t.mu.Lock()
+		for key, bucket := range pending {
+			if existing, ok := t.buckets[key]; ok {
+				existing.Records += bucket.Records
+				existing.Bytes += bucket.Bytes
+			} else {
+				t.buckets[key] = bucket
+			}
+		}
+		t.mu.Unlock()
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+
+	t.logger.Debug("Flushed usage buckets", zap.Int("count", len(batch)))
+	return nil
+}
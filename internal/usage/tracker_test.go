@@ -0,0 +1,171 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/getlawrence/lawrence-oss/internal/otlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// MockWriter is a mock for the usage Writer interface
+type MockWriter struct {
+	mock.Mock
+}
+
+func (m *MockWriter) WriteUsage(ctx context.Context, usage []otlp.UsageData) error {
+	args := m.Called(ctx, usage)
+	return args.Error(0)
+}
+
+func sortUsage(usage []otlp.UsageData) {
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Signal != usage[j].Signal {
+			return usage[i].Signal < usage[j].Signal
+		}
+		return usage[i].AgentID < usage[j].AgentID
+	})
+}
+
+func TestTracker_AttributesBytesProportionally(t *testing.T) {
+	writer := &MockWriter{}
+	tracker := NewTracker(writer, time.Minute, time.Hour, zaptest.NewLogger(t))
+
+	receivedAt := time.Date(2024, 1, 1, 12, 30, 45, 0, time.UTC)
+	logs := []otlp.LogData{
+		{AgentID: "agent-a", GroupID: "group-1", GroupName: "prod", ServiceName: "api"},
+		{AgentID: "agent-a", GroupID: "group-1", GroupName: "prod", ServiceName: "api"},
+		{AgentID: "agent-b", ServiceName: "worker"},
+	}
+	tracker.RecordLogs(logs, 100, receivedAt)
+
+	var written []otlp.UsageData
+	writer.On("WriteUsage", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		written = args.Get(1).([]otlp.UsageData)
+	}).Return(nil).Once()
+
+	require.NoError(t, tracker.Flush(context.Background()))
+	require.Len(t, written, 2)
+	sortUsage(written)
+
+	bucketStart := time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC)
+	assert.Equal(t, otlp.UsageData{
+		BucketStart: bucketStart,
+		Signal:      SignalLogs,
+		AgentID:     "agent-a",
+		GroupID:     "group-1",
+		GroupName:   "prod",
+		ServiceName: "api",
+		Records:     2,
+		Bytes:       66,
+	}, written[0])
+	assert.Equal(t, int64(1), written[1].Records)
+	assert.Equal(t, int64(34), written[1].Bytes, "rounding remainder goes to the last source")
+
+	// Nothing pending after a successful flush
+	require.NoError(t, tracker.Flush(context.Background()))
+	writer.AssertExpectations(t)
+}
+
+func TestTracker_AccumulatesWithinBucket(t *testing.T) {
+	writer := &MockWriter{}
+	tracker := NewTracker(writer, time.Minute, time.Hour, zaptest.NewLogger(t))
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	traces := []otlp.TraceData{{AgentID: "agent-a", ServiceName: "api"}}
+	tracker.RecordTraces(traces, 10, base.Add(5*time.Second))
+	tracker.RecordTraces(traces, 20, base.Add(50*time.Second))
+	tracker.RecordTraces(traces, 40, base.Add(70*time.Second))
+
+	var written []otlp.UsageData
+	writer.On("WriteUsage", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		written = args.Get(1).([]otlp.UsageData)
+	}).Return(nil).Once()
+
+	require.NoError(t, tracker.Flush(context.Background()))
+	require.Len(t, written, 2)
+	sort.Slice(written, func(i, j int) bool { return written[i].BucketStart.Before(written[j].BucketStart) })
+
+	assert.Equal(t, base, written[0].BucketStart)
+	assert.Equal(t, int64(2), written[0].Records)
+	assert.Equal(t, int64(30), written[0].Bytes)
+	assert.Equal(t, base.Add(time.Minute), written[1].BucketStart)
+	assert.Equal(t, int64(40), written[1].Bytes)
+}
+
+func TestTracker_MetricsCountAllDataPointTypes(t *testing.T) {
+	writer := &MockWriter{}
+	tracker := NewTracker(writer, time.Minute, time.Hour, zaptest.NewLogger(t))
+
+	tracker.RecordMetrics(&otlp.MetricsData{
+		Sums:                  []otlp.MetricSumData{{AgentID: "agent-a", ServiceName: "api"}},
+		Gauges:                []otlp.MetricGaugeData{{AgentID: "agent-a", ServiceName: "api"}},
+		Histograms:            []otlp.MetricHistogramData{{AgentID: "agent-a", ServiceName: "api"}},
+		ExponentialHistograms: []otlp.MetricExponentialHistogramData{{AgentID: "agent-a", ServiceName: "api"}},
+		Summaries:             []otlp.MetricSummaryData{{AgentID: "agent-a", ServiceName: "api"}},
+	}, 300, time.Now())
+
+	var written []otlp.UsageData
+	writer.On("WriteUsage", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		written = args.Get(1).([]otlp.UsageData)
+	}).Return(nil).Once()
+
+	require.NoError(t, tracker.Flush(context.Background()))
+	require.Len(t, written, 1)
+	assert.Equal(t, SignalMetrics, written[0].Signal)
+	assert.Equal(t, int64(5), written[0].Records)
+	assert.Equal(t, int64(300), written[0].Bytes)
+}
+
+func TestTracker_FailedFlushIsRetried(t *testing.T) {
+	writer := &MockWriter{}
+	tracker := NewTracker(writer, time.Minute, time.Hour, zaptest.NewLogger(t))
+
+	receivedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	logs := []otlp.LogData{{AgentID: "agent-a", ServiceName: "api"}}
+	tracker.RecordLogs(logs, 10, receivedAt)
+
+	writer.On("WriteUsage", mock.Anything, mock.Anything).Return(errors.New("database locked")).Once()
+	require.Error(t, tracker.Flush(context.Background()))
+
+	// Records arriving after the failure are merged with the retained bucket
+	tracker.RecordLogs(logs, 10, receivedAt)
+
+	var written []otlp.UsageData
+	writer.On("WriteUsage", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		written = args.Get(1).([]otlp.UsageData)
+	}).Return(nil).Once()
+
+	require.NoError(t, tracker.Flush(context.Background()))
+	require.Len(t, written, 1)
+	assert.Equal(t, int64(2), written[0].Records)
+	assert.Equal(t, int64(20), written[0].Bytes)
+	writer.AssertExpectations(t)
+}
+
+func TestTracker_StopFlushesPending(t *testing.T) {
+	writer := &MockWriter{}
+	tracker := NewTracker(writer, time.Minute, time.Hour, zaptest.NewLogger(t))
+	tracker.Start()
+
+	tracker.RecordLogs([]otlp.LogData{{AgentID: "agent-a", ServiceName: "api"}}, 10, time.Now())
+
+	writer.On("WriteUsage", mock.Anything, mock.Anything).Return(nil).Once()
+	require.NoError(t, tracker.Stop(context.Background()))
+	writer.AssertExpectations(t)
+}
+
+func TestTracker_EmptyBatchIgnored(t *testing.T) {
+	writer := &MockWriter{}
+	tracker := NewTracker(writer, time.Minute, time.Hour, zaptest.NewLogger(t))
+
+	tracker.RecordTraces(nil, 100, time.Now())
+	require.NoError(t, tracker.Flush(context.Background()))
+	writer.AssertNotCalled(t, "WriteUsage", mock.Anything, mock.Anything)
+}
@@ -16,10 +16,22 @@ import (
 // TelemetryWriter defines the interface for writing telemetry data
 type TelemetryWriter interface {
 	WriteTraces(ctx context.Context, traces []otlp.TraceData) error
-	WriteMetrics(ctx context.Context, sums []otlp.MetricSumData, gauges []otlp.MetricGaugeData, histograms []otlp.MetricHistogramData) error
+	WriteMetrics(ctx context.Context, metrics *otlp.MetricsData) error
 	WriteLogs(ctx context.Context, logs []otlp.LogData) error
 }
 
+// UsageRecorder accounts ingested telemetry per agent, group and service
+type UsageRecorder interface {
+	RecordTraces(traces []otlp.TraceData, payloadBytes int, receivedAt time.Time)
+	RecordMetrics(metrics *otlp.MetricsData, payloadBytes int, receivedAt time.Time)
+	RecordLogs(logs []otlp.LogData, payloadBytes int, receivedAt time.Time)
+}
+
+// TraceSampler buffers spans and writes the traces it decides to keep
+type TraceSampler interface {
+	AddTraces(traces []otlp.TraceData)
+}
+
 // WorkItemType represents the type of work item
 type WorkItemType int
 
@@ -44,6 +56,10 @@ type Pool struct {
 	writer        TelemetryWriter
 	parser        *parser.OTLPParser
 	enricher      *processor.Enricher
+	usage         UsageRecorder
+	rules         *processor.RuleEngine
+	redactor      *processor.Redactor
+	sampler       TraceSampler
 	logger        *zap.Logger
 	queueSize     int
 	workerCount   int
@@ -65,6 +81,27 @@ func NewPool(queueSize, workerCount int, submitTimeout time.Duration, writer Tel
 	}
 }
 
+// SetUsageRecorder enables ingestion usage accounting. It must be called before Start.
+func (p *Pool) SetUsageRecorder(recorder UsageRecorder) {
+	p.usage = recorder
+}
+
+// SetRuleEngine enables ingest-time processing rules. It must be called before Start.
+func (p *Pool) SetRuleEngine(engine *processor.RuleEngine) {
+	p.rules = engine
+}
+
+// SetRedactor enables PII redaction for traces and logs. It must be called before Start.
+func (p *Pool) SetRedactor(redactor *processor.Redactor) {
+	p.redactor = redactor
+}
+
+// SetTraceSampler routes traces through a sampler instead of writing them
+// directly. It must be called before Start.
+func (p *Pool) SetTraceSampler(sampler TraceSampler) {
+	p.sampler = sampler
+}
+
 // Start starts the worker pool
 func (p *Pool) Start() {
 	p.logger.Info("Starting worker pool", zap.Int("workers", p.workerCount), zap.Int("queue_size", p.queueSize), zap.Duration("submit_timeout", p.submitTimeout))
@@ -156,6 +193,30 @@ func (p *Pool) processItem(item WorkItem) {
 		// Enrich with group information
 		p.enricher.EnrichTraces(ctx, traces)
 
+		// Account usage per agent, group and service
+		if p.usage != nil {
+			p.usage.RecordTraces(traces, len(item.RawData), item.Timestamp)
+		}
+
+		// Apply processing rules
+		if p.rules != nil {
+			traces = p.rules.ProcessTraces(traces)
+		}
+
+		// Mask PII before it reaches storage
+		if p.redactor != nil {
+			p.redactor.RedactTraces(traces)
+		}
+
+		// Hand off to the sampler, which writes the traces it keeps
+		if p.sampler != nil {
+			p.sampler.AddTraces(traces)
+			p.logger.Debug("Buffered traces for sampling",
+				zap.Int("count", len(traces)),
+				zap.Duration("duration", time.Since(start)))
+			return
+		}
+
 		// Write to storage
 		err = p.writer.WriteTraces(ctx, traces)
 		p.logger.Debug("Processed traces",
@@ -165,21 +226,33 @@ func (p *Pool) processItem(item WorkItem) {
 
 	case WorkItemTypeMetrics:
 		// Parse raw bytes
-		sums, gauges, histograms, err := p.parser.ParseMetrics(item.RawData)
+		metrics, err := p.parser.ParseMetrics(item.RawData)
 		if err != nil {
 			p.logger.Error("Failed to parse metrics", zap.Error(err))
 			return
 		}
 
 		// Enrich with group information
-		p.enricher.EnrichMetrics(ctx, sums, gauges, histograms)
+		p.enricher.EnrichMetrics(ctx, metrics)
+
+		// Account usage per agent, group and service
+		if p.usage != nil {
+			p.usage.RecordMetrics(metrics, len(item.RawData), item.Timestamp)
+		}
+
+		// Apply processing rules
+		if p.rules != nil {
+			p.rules.ProcessMetrics(metrics)
+		}
 
 		// Write to storage
-		err = p.writer.WriteMetrics(ctx, sums, gauges, histograms)
+		err = p.writer.WriteMetrics(ctx, metrics)
 		p.logger.Debug("Processed metrics",
-			zap.Int("sums", len(sums)),
-			zap.Int("gauges", len(gauges)),
-			zap.Int("histograms", len(histograms)),
+			zap.Int("sums", len(metrics.Sums)),
+			zap.Int("gauges", len(metrics.Gauges)),
+			zap.Int("histograms", len(metrics.Histograms)),
+			zap.Int("exponential_histograms", len(metrics.ExponentialHistograms)),
+			zap.Int("summaries", len(metrics.Summaries)),
 			zap.Duration("duration", time.Since(start)),
 			zap.Error(err))
 
@@ -194,6 +267,21 @@ func (p *Pool) processItem(item WorkItem) {
 		// Enrich with group information
 		p.enricher.EnrichLogs(ctx, logs)
 
+		// Account usage per agent, group and service
+		if p.usage != nil {
+			p.usage.RecordLogs(logs, len(item.RawData), item.Timestamp)
+		}
+
+		// Apply processing rules
+		if p.rules != nil {
+			logs = p.rules.ProcessLogs(logs)
+		}
+
+		// Mask PII before it reaches storage
+		if p.redactor != nil {
+			p.redactor.RedactLogs(logs)
+		}
+
 		// Write to storage
 		err = p.writer.WriteLogs(ctx, logs)
 		p.logger.Debug("Processed logs",
@@ -29,8 +29,8 @@ func (m *MockTelemetryWriter) WriteTraces(ctx context.Context, traces []otlp.Tra
 	return err
 }
 
-func (m *MockTelemetryWriter) WriteMetrics(ctx context.Context, sums []otlp.MetricSumData, gauges []otlp.MetricGaugeData, histograms []otlp.MetricHistogramData) error {
-	args := m.Called(ctx, sums, gauges, histograms)
+func (m *MockTelemetryWriter) WriteMetrics(ctx context.Context, metrics *otlp.MetricsData) error {
+	args := m.Called(ctx, metrics)
 	err := args.Error(0)
 	return err
 }
@@ -185,6 +185,94 @@ func TestPoolProcessesTraces(t *testing.T) {
 	// Note: Invalid data causes parsing errors but pool handles them
 }
 
+// recordingUsage captures usage recorded by the pool
+type recordingUsage struct {
+	mu      sync.Mutex
+	records int
+	bytes   int
+}
+
+func (r *recordingUsage) RecordTraces(traces []otlp.TraceData, payloadBytes int, receivedAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records += len(traces)
+	r.bytes += payloadBytes
+}
+
+func (r *recordingUsage) RecordMetrics(metrics *otlp.MetricsData, payloadBytes int, receivedAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records += metrics.Len()
+	r.bytes += payloadBytes
+}
+
+func (r *recordingUsage) RecordLogs(logs []otlp.LogData, payloadBytes int, receivedAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records += len(logs)
+	r.bytes += payloadBytes
+}
+
+// TestPoolRecordsUsage tests that parsed items are reported to the usage recorder
+func TestPoolRecordsUsage(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	writer := &MockTelemetryWriter{}
+	agentService := testutils.NewMockAgentService()
+	writer.On("WriteLogs", mock.Anything, mock.Anything).Return(nil)
+
+	recorder := &recordingUsage{}
+	pool := NewPool(10, 1, 1*time.Second, writer, agentService, logger)
+	pool.SetUsageRecorder(recorder)
+	pool.Start()
+
+	logsData, err := GenerateValidLogsData()
+	require.NoError(t, err)
+
+	require.NoError(t, pool.Submit(WorkItem{Type: WorkItemTypeLogs, RawData: logsData, Timestamp: time.Now()}))
+	require.NoError(t, pool.Submit(WorkItem{Type: WorkItemTypeLogs, RawData: GenerateInvalidData(), Timestamp: time.Now()}))
+	require.NoError(t, pool.Stop(2*time.Second))
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	assert.Greater(t, recorder.records, 0)
+	assert.Equal(t, len(logsData), recorder.bytes, "unparseable payloads are not accounted")
+}
+
+// recordingSampler captures spans handed to the trace sampler
+type recordingSampler struct {
+	mu    sync.Mutex
+	spans int
+}
+
+func (r *recordingSampler) AddTraces(traces []otlp.TraceData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans += len(traces)
+}
+
+// TestPoolRoutesTracesToSampler tests that traces go to the sampler instead of the writer
+func TestPoolRoutesTracesToSampler(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	writer := &MockTelemetryWriter{}
+	agentService := testutils.NewMockAgentService()
+
+	sampler := &recordingSampler{}
+	pool := NewPool(10, 1, 1*time.Second, writer, agentService, logger)
+	pool.SetTraceSampler(sampler)
+	pool.Start()
+
+	traceData, err := GenerateValidTraceData()
+	require.NoError(t, err)
+
+	require.NoError(t, pool.Submit(WorkItem{Type: WorkItemTypeTraces, RawData: traceData, Timestamp: time.Now()}))
+	require.NoError(t, pool.Stop(2*time.Second))
+
+	sampler.mu.Lock()
+	defer sampler.mu.Unlock()
+	assert.Greater(t, sampler.spans, 0)
+	writer.AssertNotCalled(t, "WriteTraces", mock.Anything, mock.Anything)
+}
+
 // TestPoolProcessesMetrics tests that the pool processes metrics items
 func TestPoolProcessesMetrics(t *testing.T) {
 	logger := zaptest.NewLogger(t)
@@ -543,7 +631,7 @@ func TestPoolConcurrentSubmission(t *testing.T) {
 	agentService := testutils.NewMockAgentService()
 
 	writer.On("WriteTraces", mock.Anything, mock.Anything).Return(nil)
-	writer.On("WriteMetrics", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	writer.On("WriteMetrics", mock.Anything, mock.Anything).Return(nil)
 	writer.On("WriteLogs", mock.Anything, mock.Anything).Return(nil)
 
 	pool := NewPool(10000, 20, 10*time.Second, writer, agentService, logger)
@@ -863,15 +951,13 @@ func TestPoolMetricsEndToEnd(t *testing.T) {
 	writer := &MockTelemetryWriter{}
 	agentService := testutils.NewMockAgentService()
 
-	writer.On("WriteMetrics", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+	writer.On("WriteMetrics", mock.Anything, mock.Anything).
 		Run(func(args mock.Arguments) {
-			sums := args.Get(1).([]otlp.MetricSumData)
-			gauges := args.Get(2).([]otlp.MetricGaugeData)
-			histograms := args.Get(3).([]otlp.MetricHistogramData)
+			metrics := args.Get(1).(*otlp.MetricsData)
+			sums := metrics.Sums
 
 			// Should have processed at least one of each type
-			totalMetrics := len(sums) + len(gauges) + len(histograms)
-			assert.Greater(t, totalMetrics, 0, "Should have processed metrics")
+			assert.Greater(t, metrics.Len(), 0, "Should have processed metrics")
 
 			// Verify data integrity for sums
 			if len(sums) > 0 {
@@ -952,7 +1038,7 @@ func TestPoolMixedDataTypes(t *testing.T) {
 		atomic.AddInt64(&tracesCalled, 1)
 	}).Return(nil)
 
-	writer.On("WriteMetrics", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+	writer.On("WriteMetrics", mock.Anything, mock.Anything).
 		Run(func(args mock.Arguments) {
 			atomic.AddInt64(&metricsCalled, 1)
 		}).Return(nil)
@@ -1158,7 +1244,7 @@ func BenchmarkPoolMixedWorkload(b *testing.B) {
 	agentService := testutils.NewMockAgentService()
 
 	writer.On("WriteTraces", mock.Anything, mock.Anything).Return(nil)
-	writer.On("WriteMetrics", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	writer.On("WriteMetrics", mock.Anything, mock.Anything).Return(nil)
 	writer.On("WriteLogs", mock.Anything, mock.Anything).Return(nil)
 
 	pool := NewPool(10000, 8, 5*time.Second, writer, agentService, logger)
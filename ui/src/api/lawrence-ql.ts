@@ -7,6 +7,7 @@ export interface LawrenceQLRequest {
   limit?: number;
   agent_id?: string;
   group_id?: string;
+  step?: string;
 }
 
 export interface QueryResult {
@@ -17,15 +18,28 @@ export interface QueryResult {
   data?: Record<string, unknown>;
 }
 
+export interface SeriesPoint {
+  timestamp: string;
+  value: number;
+}
+
+export interface Series {
+  type: "metrics" | "logs" | "traces";
+  labels: Record<string, string>;
+  points: SeriesPoint[];
+}
+
 export interface QueryMeta {
   execution_time: number;
   row_count: number;
   query_type: string;
   used_rollups: boolean;
+  plan?: string;
 }
 
 export interface LawrenceQLResponse {
   results: QueryResult[];
+  series?: Series[];
   meta: QueryMeta;
 }
 